@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/format"
+)
+
+// --- capture subcommand ---
+
+// runCapture lists debug captures, or prints the bundle attached to the
+// incident (or opening event) ID given as an argument.
+func runCapture(args []string) {
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	limit := fs.Int("limit", 20, "max captures to list")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if fs.NArg() == 0 {
+		captures, err := db.ListCaptures(*limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error listing captures: %v\n", err)
+			os.Exit(1)
+		}
+		if len(captures) == 0 {
+			fmt.Println("No captures found.")
+			return
+		}
+		for _, c := range captures {
+			fmt.Printf("%s  %s  %-8s %s\n",
+				c.Started.Local().Format("2006-01-02 15:04:05"),
+				cmp.Or(c.IncidentID, c.EventID),
+				format.Duration(c.Ended.Sub(c.Started)),
+				scopeLabel(c.Scope),
+			)
+		}
+		return
+	}
+
+	c, err := db.GetCapture(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading capture: %v\n", err)
+		os.Exit(1)
+	}
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "no capture for incident or event %s\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	if c.IncidentID != "" {
+		fmt.Printf("Capture for incident %s, opened by event %s\n", c.IncidentID, c.EventID)
+	} else {
+		fmt.Printf("Capture for event %s\n", c.EventID)
+	}
+	fmt.Printf("Window: %s - %s\n",
+		c.Started.Local().Format("2006-01-02 15:04:05"),
+		c.Ended.Local().Format("15:04:05"),
+	)
+	fmt.Printf("Scope:  %s\n", scopeLabel(c.Scope))
+	fmt.Println("\n--- System samples ---")
+	fmt.Println(c.Samples)
+	fmt.Println("\n--- Journal ---")
+	fmt.Println(c.Journal)
+}
+
+func scopeLabel(scope string) string {
+	if scope == "" {
+		return "(whole journal)"
+	}
+	return scope
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/config"
+)
+
+// --- check-config subcommand ---
+
+// runCheckConfig loads the config and its drop-ins and prints every
+// syntax error, unknown key, and invalid or suspicious setting, with the
+// line it is on, instead of stopping at the first error as the daemon does.
+func runCheckConfig(args []string) {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	fs.Parse(args)
+
+	path := *configPath
+	if path == "" {
+		path = config.DefaultPath()
+	}
+	cfg, problems, err := config.Check(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	lines := make(map[string][]string)
+	var errs, warnings int
+	for _, p := range problems {
+		if p.Warning {
+			warnings++
+		} else {
+			errs++
+		}
+		fmt.Println(p)
+		if p.File == "" || p.Line == 0 {
+			continue
+		}
+		if _, ok := lines[p.File]; !ok {
+			data, _ := os.ReadFile(p.File)
+			lines[p.File] = strings.Split(string(data), "\n")
+		}
+		if src := lines[p.File]; p.Line <= len(src) {
+			fmt.Printf("  %4d | %s\n", p.Line, strings.TrimSpace(src[p.Line-1]))
+		}
+	}
+
+	if len(cfg.Files) == 0 {
+		fmt.Printf("No config file at %s; using defaults.\n", path)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("Config OK (%s).\n", strings.Join(cfg.Files, ", "))
+		return
+	}
+	fmt.Printf("\n%d errors, %d warnings.\n", errs, warnings)
+	if errs > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/control"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/health"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// maxControlEvents caps the events command's n.
+const maxControlEvents = 1000
+
+// pipelineStats counts what the pipeline did since the daemon started.
+type pipelineStats struct {
+	Started      time.Time          `json:"started"`
+	Entries      int                `json:"journal_entries"` // journal entries received
+	Events       map[event.Tier]int `json:"events"`          // events handled, by tier
+	Unclassified int                `json:"unclassified"`    // stored by the catch-all
+	Collapsed    int                `json:"collapsed"`       // folded into an event storm
+	Decisions    map[string]int     `json:"decisions"`       // notification decisions, by outcome
+}
+
+func newPipelineStats() pipelineStats {
+	return pipelineStats{
+		Started:   time.Now(),
+		Events:    make(map[event.Tier]int),
+		Decisions: make(map[string]int),
+	}
+}
+
+func (s *pipelineStats) event(tier event.Tier) {
+	s.Events[tier]++
+}
+
+func (s *pipelineStats) decision(outcome string) {
+	s.Decisions[outcome]++
+}
+
+// statusDaemon is the part of the status report only the running daemon
+// knows.
+type statusDaemon struct {
+	PID               int           `json:"pid"`
+	Version           string        `json:"version"`
+	Started           time.Time     `json:"started"`
+	Events            int           `json:"events"` // handled since start
+	HeldNotifications int           `json:"held_notifications"`
+	Storm             *controlStorm `json:"storm,omitempty"`
+}
+
+// controlStats is the stats command's response.
+type controlStats struct {
+	pipelineStats
+	PID                 int           `json:"pid"`
+	Version             string        `json:"version"`
+	ConfigFiles         []string      `json:"config_files"`
+	Rules               int           `json:"rules"`
+	Dropped             int64         `json:"dropped"`
+	ReporterFailures    int64         `json:"reporter_failures"`
+	HeldNotifications   int           `json:"held_notifications"`   // in quiet hours
+	QueuedNotifications int           `json:"queued_notifications"` // awaiting retry
+	Storm               *controlStorm `json:"storm,omitempty"`
+}
+
+// controlStorm is an event storm in progress.
+type controlStorm struct {
+	Since     time.Time `json:"since"`
+	Collapsed int       `json:"collapsed"`
+}
+
+// controlSuppressions is the suppressions command's response: everything
+// currently keeping events from being notified.
+type controlSuppressions struct {
+	Rules       []controlSuppressRule `json:"rules"`
+	KnownCrashy []string              `json:"known_crashy,omitempty"`
+	Snoozes     []controlSnooze       `json:"snoozes"`
+	Held        []controlHeld         `json:"held"`
+	Storm       *controlStorm         `json:"storm,omitempty"`
+}
+
+type controlSuppressRule struct {
+	Name    string `json:"name"`
+	Stage   string `json:"stage"`
+	Message string `json:"message,omitempty"`
+	Unit    string `json:"unit,omitempty"`
+	Process string `json:"process,omitempty"`
+	Tier    string `json:"tier,omitempty"`
+}
+
+type controlSnooze struct {
+	Instance string    `json:"instance,omitempty"`
+	Tier     string    `json:"tier,omitempty"`
+	Unit     string    `json:"unit,omitempty"`
+	Until    time.Time `json:"until"`
+}
+
+type controlHeld struct {
+	EventID string    `json:"event_id"`
+	Tier    string    `json:"tier"`
+	Summary string    `json:"summary"`
+	Until   time.Time `json:"until"`
+}
+
+// stormState returns the storm in progress, or nil.
+func (p *pipeline) stormState() *controlStorm {
+	if p.storm == nil {
+		return nil
+	}
+	since, collapsed, ok := p.storm.Active()
+	if !ok {
+		return nil
+	}
+	return &controlStorm{Since: since, Collapsed: collapsed}
+}
+
+// serveControl registers the control socket commands. Pipeline state is
+// read on the event loop through ctl.Do; queries and sends run on the
+// socket's goroutines so they do not hold up the loop. reload reloads the
+// config as SIGHUP does.
+func (p *pipeline) serveControl(ctl *control.Server, checker *health.Checker, reload func() error) {
+	ctl.Handle("GET /stats", func(ctx context.Context, _ url.Values) (any, error) {
+		var st controlStats
+		err := ctl.Do(ctx, func() {
+			st.pipelineStats = p.stats
+			st.Events = maps.Clone(p.stats.Events)
+			st.Decisions = maps.Clone(p.stats.Decisions)
+			st.ConfigFiles = p.cfg.Files
+			st.Rules = len(p.cfg.Rules)
+			st.HeldNotifications = len(p.held)
+			st.Storm = p.stormState()
+		})
+		if err != nil {
+			return nil, err
+		}
+		st.PID = os.Getpid()
+		st.Version = version
+		c := selfstat.Snapshot()
+		st.Dropped, st.ReporterFailures = c.Dropped, c.ReporterFailures
+		st.QueuedNotifications, _ = p.db.QueuedNotificationCount()
+		return st, nil
+	})
+
+	ctl.Handle("GET /status", func(ctx context.Context, _ url.Values) (any, error) {
+		var cfg *config.Config
+		d := &statusDaemon{PID: os.Getpid(), Version: version}
+		err := ctl.Do(ctx, func() {
+			cfg = p.cfg
+			d.Started = p.stats.Started
+			for _, n := range p.stats.Events {
+				d.Events += n
+			}
+			d.HeldNotifications = len(p.held)
+			d.Storm = p.stormState()
+		})
+		if err != nil {
+			return nil, err
+		}
+		st := buildStatus(cfg, p.db)
+		st.Daemon = d
+		return st, nil
+	})
+
+	ctl.Handle("GET /events", func(ctx context.Context, args url.Values) (any, error) {
+		n := 20
+		if s := args.Get("n"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil || n < 1 || n > maxControlEvents {
+				return nil, &control.ArgError{Err: fmt.Errorf("n must be a number from 1 to %d", maxControlEvents)}
+			}
+		}
+		evs, err := p.db.Query(store.QueryFilter{Limit: n, Tier: strings.ToUpper(args.Get("tier"))})
+		if evs == nil {
+			evs = []*event.Event{}
+		}
+		return evs, err
+	})
+
+	ctl.Handle("GET /suppressions", func(ctx context.Context, _ url.Values) (any, error) {
+		res := controlSuppressions{Snoozes: []controlSnooze{}, Held: []controlHeld{}}
+		err := ctl.Do(ctx, func() {
+			for _, r := range p.cfg.Suppress.Rules {
+				stage := r.Stage
+				if stage == "" {
+					stage = "alert"
+				}
+				res.Rules = append(res.Rules, controlSuppressRule{
+					Name: r.Name, Stage: stage, Message: r.Message, Unit: r.Unit, Process: r.Process, Tier: r.Tier,
+				})
+			}
+			res.KnownCrashy = p.cfg.Crashes.KnownCrashy
+			for _, h := range p.held {
+				res.Held = append(res.Held, controlHeld{
+					EventID: h.Event.ID, Tier: string(h.Event.Tier), Summary: h.Event.Summary, Until: h.until,
+				})
+			}
+			res.Storm = p.stormState()
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res.Rules == nil {
+			res.Rules = []controlSuppressRule{}
+		}
+		snoozes, err := p.db.ActiveSnoozes(time.Now())
+		for _, s := range snoozes {
+			res.Snoozes = append(res.Snoozes, controlSnooze{Instance: s.InstanceID, Tier: string(s.Tier), Unit: s.Unit, Until: s.Until})
+		}
+		return res, err
+	})
+
+	ctl.Handle("GET /monitors", func(ctx context.Context, _ url.Values) (any, error) {
+		return checker.Run(ctx), nil
+	})
+
+	ctl.Handle("POST /digest", func(ctx context.Context, args url.Values) (any, error) {
+		last := args.Get("last")
+		if last == "" {
+			last = "7d"
+		}
+		d, err := parseDuration(last)
+		if err != nil {
+			return nil, &control.ArgError{Err: fmt.Errorf("invalid last: %w", err)}
+		}
+		var cfg *config.Config
+		if err := ctl.Do(ctx, func() { cfg = p.cfg }); err != nil {
+			return nil, err
+		}
+		via := args.Get("via")
+		if via == "" {
+			via = cfg.Digest.Via
+		}
+		if via != "ntfy" && via != "email" {
+			return nil, &control.ArgError{Err: fmt.Errorf("invalid via %q: must be ntfy or email", via)}
+		}
+
+		until := time.Now()
+		since := until.Add(-d)
+		warn := func(err error) { slog.Warn("digest incomplete", "error", err) }
+		digest, err := collectDigest(cfg, p.db, since, until, cfg.Digest.AllInstances, warn)
+		if err != nil {
+			return nil, err
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+		if err := sendDigest(sendCtx, cfg, via, digest); err != nil {
+			return nil, err
+		}
+		slog.Info("digest sent on request", "via", via, "since", since)
+		return map[string]any{"sent": true, "via": via, "since": since}, nil
+	})
+
+	ctl.Handle("POST /purge", func(ctx context.Context, args url.Values) (any, error) {
+		var retention time.Duration
+		if err := ctl.Do(ctx, func() { retention = p.cfg.DB.Retention.Duration }); err != nil {
+			return nil, err
+		}
+		if s := args.Get("older_than"); s != "" {
+			d, err := parseDuration(s)
+			if err != nil || d <= 0 {
+				return nil, &control.ArgError{Err: fmt.Errorf("invalid older_than %q", s)}
+			}
+			retention = d
+		}
+		if retention <= 0 {
+			return nil, &control.ArgError{Err: errors.New("db.retention is unset; pass older_than")}
+		}
+		n, err := p.db.Purge(retention)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("purged old events on request", "count", n, "older_than", retention)
+		return map[string]any{"purged": n, "older_than": retention.String()}, nil
+	})
+
+	ctl.Handle("POST /reload", func(ctx context.Context, _ url.Values) (any, error) {
+		var rerr error
+		if err := ctl.Do(ctx, func() { rerr = reload() }); err != nil {
+			return nil, err
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+		return map[string]bool{"reloaded": true}, nil
+	})
+}
+
+// --- ctl subcommand ---
+
+// controlCommands lists the ctl commands and whether each acts (POST)
+// rather than only reads.
+var controlCommands = map[string]bool{
+	"stats":        false,
+	"status":       false,
+	"events":       false,
+	"suppressions": false,
+	"monitors":     false,
+	"digest":       true,
+	"purge":        true,
+	"reload":       true,
+}
+
+// runCtl sends a command to the running daemon over its control socket and
+// prints the JSON response. Arguments are key=value pairs, e.g. "logtriage
+// ctl events n=50".
+func runCtl(args []string) {
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: logtriage ctl [--config path] <command> [key=value ...]")
+		fmt.Fprintln(os.Stderr, "commands: stats, status, events [n=20 tier=T1], suppressions, monitors,")
+		fmt.Fprintln(os.Stderr, "          digest [last=7d via=ntfy|email], purge [older_than=90d], reload")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	command := fs.Arg(0)
+	acts, ok := controlCommands[command]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		fs.Usage()
+		os.Exit(1)
+	}
+	values := url.Values{}
+	for _, a := range fs.Args()[1:] {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "invalid argument %q: want key=value\n", a)
+			os.Exit(1)
+		}
+		values.Add(k, v)
+	}
+
+	var res any
+	if err := callDaemon(*configPath, command, acts, values, &res); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	exitOnWriteError(format.WriteJSON(os.Stdout, res))
+}
+
+// runReload has the running daemon reload its config, as SIGHUP does.
+func runReload(args []string) {
+	fs := flag.NewFlagSet("reload", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	fs.Parse(args)
+
+	if err := callDaemon(*configPath, "reload", true, nil, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Config reloaded.")
+}
+
+// callDaemon runs a command on the daemon using the config at configPath,
+// decoding the response into out.
+func callDaemon(configPath, command string, acts bool, args url.Values, out any) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+	call := control.Get
+	if acts {
+		call = control.Post
+	}
+	err = call(ctx, cfg.ControlSocket(), command, args, out)
+	if errors.Is(err, control.ErrNotRunning) {
+		return fmt.Errorf("no daemon is listening on %s", cfg.ControlSocket())
+	}
+	return err
+}
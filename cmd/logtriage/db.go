@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// openDB opens the event database cfg selects: the SQLite file at
+// db.path, or the PostgreSQL database at db.dsn.
+func openDB(cfg *config.Config) (*store.DB, error) {
+	if cfg.DB.Driver == "postgres" {
+		return store.OpenPostgres(cfg.DB.DSN)
+	}
+	return store.Open(cfg.DBPath())
+}
+
+// --- db subcommand ---
+
+// runDB maintains the event database: backup copies it with SQLite's
+// online backup, which is safe while the daemon runs, vacuum returns the
+// space of deleted events to the filesystem, and verify runs SQLite's
+// integrity check, exiting 1 if it finds problems. Backup and verify are
+// for SQLite; a PostgreSQL database is maintained with its own tools.
+func runDB(args []string) {
+	usage := "usage: logtriage db backup <path> | vacuum | verify [--config path]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	op, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("db "+op, flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	fs.Parse(args)
+
+	switch {
+	case op == "backup" && fs.NArg() == 1:
+	case (op == "vacuum" || op == "verify") && fs.NArg() == 0:
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	switch op {
+	case "backup":
+		path := fs.Arg(0)
+		if err := db.Backup(context.Background(), path); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Backed up %s to %s (%s).\n", db.Location(), path, fileSize(path))
+
+	case "vacuum":
+		before := dbSize(db)
+		if err := db.Vacuum(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Vacuumed %s: %s before, %s after.\n", db.Location(), before, dbSize(db))
+
+	case "verify":
+		problems, err := db.Verify()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(problems) > 0 {
+			for _, p := range problems {
+				fmt.Println(p)
+			}
+			fmt.Printf("%s failed its integrity check: %d problem(s).\n", db.Location(), len(problems))
+			db.Close()
+			os.Exit(1)
+		}
+		version, err := db.SchemaVersion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s passed its integrity check (schema version %d).\n", db.Location(), version)
+	}
+}
+
+// fileSize returns the size of the file at path for display, or "?".
+func fileSize(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "?"
+	}
+	return format.Bytes(info.Size())
+}
+
+// dbSize returns the size of the database for display, or "?".
+func dbSize(db *store.DB) string {
+	n, err := db.Size()
+	if err != nil {
+		return "?"
+	}
+	return format.Bytes(n)
+}
+
+// backupScheduler takes the daemon's scheduled database backups (see
+// db.backup_interval).
+type backupScheduler struct {
+	db    *store.DB
+	dir   string
+	keep  int
+	every time.Duration
+	next  time.Time
+
+	mu      sync.Mutex
+	running bool
+	wg      sync.WaitGroup
+}
+
+// newBackupScheduler returns a scheduler whose first backup is due
+// backup_interval after the newest one already in the backup directory, or
+// nil when backups are not scheduled.
+func newBackupScheduler(cfg *config.Config, db *store.DB, now time.Time) *backupScheduler {
+	every := cfg.DB.BackupInterval.Duration
+	if every <= 0 {
+		return nil
+	}
+	s := &backupScheduler{db: db, dir: cfg.BackupDir(), keep: cfg.DB.BackupKeep, every: every}
+	s.next = store.LastBackup(s.dir).Add(every)
+	if s.next.Before(now) {
+		s.next = now
+	}
+	slog.Info("database backups scheduled", "dir", s.dir, "interval", every, "keep", s.keep, "next", s.next)
+	return s
+}
+
+// check starts a backup in the background once one is due, unless the
+// previous one is still running.
+func (s *backupScheduler) check(ctx context.Context, now time.Time) {
+	if now.Before(s.next) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.next = now.Add(s.every)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		start := time.Now()
+		path, err := s.db.BackupRotating(ctx, s.dir, s.keep, start)
+		if err != nil {
+			slog.Error("database backup failed", "error", err)
+		} else {
+			slog.Info("database backed up", "path", path, "took", time.Since(start).Round(time.Millisecond))
+		}
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+}
+
+// wait blocks until a backup in progress has finished.
+func (s *backupScheduler) wait() {
+	s.wg.Wait()
+}
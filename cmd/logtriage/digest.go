@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// collectDigest builds the digest of the events between since and until,
+// with trends against the period before, drive temperatures, and the local
+// daemon's health. With all set it covers every instance in the store
+// rather than this one. Failing to read the previous period, temperatures,
+// or health is passed to warn, and the digest goes without.
+func collectDigest(cfg *config.Config, db *store.DB, since, until time.Time, all bool, warn func(error)) (*reporter.DigestSummary, error) {
+	filter := store.QueryFilter{Since: since, Until: until}
+	if !all {
+		filter.InstanceID = cfg.Instance.ID
+	}
+	digest, err := reporter.QueryDigest(db, cfg.Instance.ID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	if err := digest.CompareQuery(db, filter); err != nil {
+		warn(fmt.Errorf("reading the previous period: %w", err))
+	}
+	// Drive temperatures are recorded per instance, so a fleet digest lists
+	// them under each host. Health is the local daemon's, the hub's in a
+	// fleet digest, and stays with the totals.
+	hosts := digest.Hosts
+	if len(hosts) == 0 {
+		hosts = []*reporter.DigestSummary{digest}
+	}
+	for _, h := range hosts {
+		if temps, err := db.MetricStats(h.InstanceID, store.MetricDiskTemp, since, until); err != nil {
+			warn(fmt.Errorf("reading drive temperatures: %w", err))
+		} else {
+			h.DiskTemps = temps
+		}
+	}
+	if runs, err := db.Runs(cfg.Instance.ID, since); err != nil {
+		warn(fmt.Errorf("reading run history: %w", err))
+	} else {
+		dbSize, _ := db.Size()
+		digest.Health = reporter.BuildSelfHealth(runs, since, until, dbSize)
+	}
+	return digest, nil
+}
+
+// sendDigest delivers a digest over ntfy or email.
+func sendDigest(ctx context.Context, cfg *config.Config, via string, d *reporter.DigestSummary) error {
+	title := reporter.FormatDigestTitle(d.Since, d.Until)
+	body := reporter.FormatDigest(d)
+	if via == "email" {
+		return reporter.NewSMTP(cfg).SendDigest(ctx, title, body)
+	}
+
+	topic := cfg.DigestTopic()
+	if topic == "" {
+		return errors.New("no ntfy URL configured for digest")
+	}
+	topic, err := cfg.ExpandTopic(topic, config.TopicData{Instance: cfg.Instance.ID, Tier: "digest"})
+	if err != nil {
+		return err
+	}
+	return sendDigestNtfy(cfg.Ntfy, topic, title, body)
+}
+
+// digestScheduler sends the digest at the times in digest.schedule, so no
+// external timer is needed.
+type digestScheduler struct {
+	cfg  *config.Config
+	db   *store.DB
+	next time.Time
+}
+
+// newDigestScheduler returns a scheduler for the next digest after now, or
+// nil when digests are not scheduled.
+func newDigestScheduler(cfg *config.Config, db *store.DB, now time.Time) *digestScheduler {
+	if !cfg.Digest.Scheduled() {
+		return nil
+	}
+	s := &digestScheduler{cfg: cfg, db: db, next: cfg.Digest.Next(now)}
+	slog.Info("digest scheduled", "schedule", cfg.Digest.Schedule, "next", s.next)
+	return s
+}
+
+// check sends the digest in the background once its time has come. It is
+// called every minute against the wall clock, so a digest that fell due
+// while the machine was suspended goes out on resume. One missed while the
+// daemon was stopped is not sent.
+func (s *digestScheduler) check(ctx context.Context, now time.Time) {
+	if now.Before(s.next) {
+		return
+	}
+	since := s.cfg.Digest.Prev(s.next)
+	s.next = s.cfg.Digest.Next(now)
+	go s.send(ctx, since, now)
+}
+
+func (s *digestScheduler) send(ctx context.Context, since, until time.Time) {
+	warn := func(err error) { slog.Warn("digest incomplete", "error", err) }
+	d, err := collectDigest(s.cfg, s.db, since, until, s.cfg.Digest.AllInstances, warn)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+		err = sendDigest(ctx, s.cfg, s.cfg.Digest.Via, d)
+	}
+	if err != nil {
+		slog.Error("failed to send scheduled digest", "error", err)
+		selfstat.ReporterFailure()
+		return
+	}
+	slog.Info("scheduled digest sent", "via", s.cfg.Digest.Via, "since", since)
+	pingDigestSent(ctx, s.cfg)
+}
+
+// pingDigestSent reports a delivered digest to the heartbeat's digest URL.
+func pingDigestSent(ctx context.Context, cfg *config.Config) {
+	if err := reporter.NewHeartbeat(cfg).PingDigest(ctx); err != nil {
+		slog.Warn("failed to ping heartbeat after digest", "error", err)
+		selfstat.ReporterFailure()
+	}
+}
+
+// pingHeartbeat pings heartbeat.url, logging a failure. The next interval
+// pings again.
+func pingHeartbeat(ctx context.Context, h *reporter.Heartbeat) {
+	if err := h.Ping(ctx); err != nil {
+		slog.Warn("failed to ping heartbeat", "error", err)
+		selfstat.ReporterFailure()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// newEscalationReporter returns an ntfy reporter that publishes to the
+// escalation topic, or nil when none is configured.
+func newEscalationReporter(cfg *config.Config) *reporter.NtfyReporter {
+	if !cfg.Escalation.Enabled || cfg.Escalation.NtfyURL == "" {
+		return nil
+	}
+	c := *cfg
+	c.Ntfy.URL = cfg.Escalation.NtfyURL
+	c.Ntfy.TierTopics = nil
+	c.Security.Topic = ""
+	slog.Info("escalation topic enabled")
+	return reporter.NewNtfy(&c)
+}
+
+// escalate checks whether ev, suppressed by the cooldown, is the repeat
+// that reaches the escalation threshold (see config.EscalationConfig). If
+// it is, ev's severity is raised, its summary marked, and dec updated, and
+// escalate returns how many times the problem fired within the window,
+// including ev. Otherwise it returns 0.
+func (p *pipeline) escalate(ev *event.Event, dec *store.Decision) int {
+	esc := p.cfg.Escalation
+	if !esc.Applies(string(ev.Tier)) {
+		return 0
+	}
+	n, start, err := p.db.CountSimilar(ev, esc.Window.Duration)
+	if err != nil {
+		slog.Error("escalation check failed", "error", err)
+		return 0
+	}
+	// Like the aggregate alert, escalation fires once, on the repeat that
+	// reaches the threshold.
+	if n+1 != esc.Threshold {
+		return 0
+	}
+
+	from := ev.Severity
+	ev.Severity = from.Raise()
+	if esc.Severity != "" {
+		ev.Severity = event.Severity(esc.Severity)
+	}
+	if err := p.db.SetSeverity(ev.ID, ev.Severity); err != nil {
+		slog.Error("failed to store escalated severity", "error", err)
+	}
+	ev.Summary = fmt.Sprintf("[escalated x%d] %s", n+1, ev.Summary)
+
+	dec.Outcome = store.DecisionEscalated
+	dec.Reason = fmt.Sprintf("%d similar events since %s reached the escalation threshold of %d; severity raised from %s to %s",
+		n+1, start.Local().Format("15:04:05"), esc.Threshold, from, ev.Severity)
+	slog.Warn("alert escalated", "tier", ev.Tier, "summary", ev.Summary, "count", n+1, "severity", ev.Severity)
+	return n + 1
+}
+
+// reportEscalation sends an escalated alert to the escalation topic, if
+// one is configured.
+func (p *pipeline) reportEscalation(ctx context.Context, ev *event.Event) {
+	if p.escalation == nil {
+		return
+	}
+	if err := p.escalation.Report(ctx, ev); err != nil {
+		slog.Error("failed to send escalation notification", "error", err)
+		selfstat.ReporterFailure()
+	}
+}
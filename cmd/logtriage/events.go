@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// eventsPreview caps how many matching events a bulk operation lists.
+const eventsPreview = 10
+
+// --- events subcommand ---
+
+// runEvents applies a bulk operation to the events matching its filters:
+// delete removes them, ack closes their open incidents and drops their
+// queued notification retries, and resolve records a recovery for each
+// unit or process they name, which also resets its cooldown. The operation
+// is given as the first argument or as --delete, --ack, or --resolve.
+func runEvents(args []string) {
+	var action string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	del := fs.Bool("delete", false, "delete the matching events")
+	ack := fs.Bool("ack", false, "close the open incidents of the matching events")
+	resolve := fs.Bool("resolve", false, "record recoveries for the units and processes of the matching events")
+	dryRun := fs.Bool("dry-run", false, "show what would change without changing anything")
+	before := fs.String("before", "", "only events older than this (e.g. 12h, 30d)")
+	last := fs.String("last", "", "only events within this time window (e.g. 24h, 2d)")
+	tier := fs.String("tier", "", "filter by tier (T0-T9)")
+	instance := fs.String("instance", "", "filter by instance ID")
+	incident := fs.String("incident", "", "filter by incident ID")
+	search := fs.String("search", "", "only events whose summary or detail contain all these words")
+	process := fs.String("process", "", "filter by process name")
+	unit := fs.String("unit", "", "filter by systemd unit")
+	severity := fs.String("severity", "", "filter by severity (critical, high, medium, warning)")
+	fs.Parse(args)
+
+	for name, set := range map[string]bool{"delete": *del, "ack": *ack, "resolve": *resolve} {
+		if !set {
+			continue
+		}
+		if action != "" && action != name {
+			fmt.Fprintln(os.Stderr, "choose one of delete, ack, or resolve")
+			os.Exit(1)
+		}
+		action = name
+	}
+	switch action {
+	case "delete", "ack", "resolve":
+	case "":
+		fmt.Fprintln(os.Stderr, "usage: logtriage events delete|ack|resolve [--dry-run] [filters]")
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown events operation %q: want delete, ack, or resolve\n", action)
+		os.Exit(1)
+	}
+
+	sev := event.Severity(strings.ToLower(*severity))
+	switch sev {
+	case "", event.SevCritical, event.SevHigh, event.SevMedium, event.SevWarning:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --severity %q: want critical, high, medium, or warning\n", *severity)
+		os.Exit(1)
+	}
+
+	filter := store.QueryFilter{
+		Tier:       strings.ToUpper(*tier),
+		InstanceID: *instance,
+		IncidentID: *incident,
+		Unit:       *unit,
+		Process:    *process,
+		Severity:   string(sev),
+		Search:     *search,
+	}
+	now := time.Now()
+	if *before != "" {
+		d, err := parseDuration(*before)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --before value %q: %v\n", *before, err)
+			os.Exit(1)
+		}
+		filter.Until = now.Add(-d)
+	}
+	if *last != "" {
+		d, err := parseDuration(*last)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --last value %q: %v\n", *last, err)
+			os.Exit(1)
+		}
+		filter.Since = now.Add(-d)
+	}
+	if filter == (store.QueryFilter{}) {
+		fmt.Fprintf(os.Stderr, "refusing to %s every event: add a filter such as --tier, --unit, or --before\n", action)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	events, err := db.Query(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(events) == 0 {
+		fmt.Println("No matching events.")
+		return
+	}
+
+	if *dryRun {
+		previewEvents(events)
+		switch action {
+		case "delete":
+			fmt.Printf("Would delete %d event(s).\n", len(events))
+		case "ack":
+			open, err := openIncidents(db, events)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error loading incidents: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Would close %d open incident(s) of %d event(s).\n", len(open), len(events))
+		case "resolve":
+			fmt.Printf("Would record %d recovery(ies) for %d event(s).\n", len(recoveriesFor(events, now)), len(events))
+		}
+		return
+	}
+
+	switch action {
+	case "delete":
+		n, err := db.DeleteEvents(filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error deleting events: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted %d event(s).\n", n)
+
+	case "ack":
+		open, err := openIncidents(db, events)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading incidents: %v\n", err)
+			os.Exit(1)
+		}
+		for _, id := range open {
+			if err := db.CloseIncident(id, now); err != nil {
+				fmt.Fprintf(os.Stderr, "error closing incident %s: %v\n", id, err)
+				os.Exit(1)
+			}
+		}
+		dropped, err := dropQueued(db, events)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error dropping queued notifications: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Closed %d incident(s) and dropped %d queued notification(s) for %d event(s).\n",
+			len(open), dropped, len(events))
+
+	case "resolve":
+		closed := 0
+		recoveries := recoveriesFor(events, now)
+		for _, r := range recoveries {
+			ids, err := db.RecordRecovery(r)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error recording recovery: %v\n", err)
+				os.Exit(1)
+			}
+			closed += len(ids)
+		}
+		// Events with no unit or process have no recovery to record; their
+		// incidents are closed directly.
+		var bare []*event.Event
+		for _, ev := range events {
+			if ev.Unit == "" && ev.Process == "" {
+				bare = append(bare, ev)
+			}
+		}
+		open, err := openIncidents(db, bare)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading incidents: %v\n", err)
+			os.Exit(1)
+		}
+		for _, id := range open {
+			if err := db.CloseIncident(id, now); err != nil {
+				fmt.Fprintf(os.Stderr, "error closing incident %s: %v\n", id, err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Recorded %d recovery(ies) and closed %d incident(s) for %d event(s).\n",
+			len(recoveries), closed+len(open), len(events))
+	}
+}
+
+// previewEvents lists the first matching events of a dry run.
+func previewEvents(events []*event.Event) {
+	for _, ev := range events[:min(len(events), eventsPreview)] {
+		fmt.Printf("%s  [%s] %s\n", ev.Timestamp.Local().Format("2006-01-02 15:04:05"), ev.Tier, ev.Summary)
+	}
+	if len(events) > eventsPreview {
+		fmt.Printf("... and %d more\n", len(events)-eventsPreview)
+	}
+	fmt.Println()
+}
+
+// openIncidents returns the IDs of the open incidents the events belong to.
+func openIncidents(db *store.DB, events []*event.Event) ([]string, error) {
+	seen := make(map[string]bool)
+	var open []string
+	for _, ev := range events {
+		if ev.IncidentID == "" || seen[ev.IncidentID] {
+			continue
+		}
+		seen[ev.IncidentID] = true
+		inc, err := db.GetIncident(ev.IncidentID)
+		if err != nil {
+			return nil, err
+		}
+		if inc != nil && inc.IsOpen() {
+			open = append(open, inc.ID)
+		}
+	}
+	return open, nil
+}
+
+// dropQueued removes the queued notification retries of the events and
+// returns how many there were.
+func dropQueued(db *store.DB, events []*event.Event) (int, error) {
+	ids := make(map[string]bool, len(events))
+	for _, ev := range events {
+		ids[ev.ID] = true
+	}
+	queued, err := db.DueNotifications(time.Time{}, 0)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, q := range queued {
+		if !ids[q.EventID] {
+			continue
+		}
+		if err := db.DeleteNotification(q.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// recoveriesFor returns one recovery at the given time for each instance,
+// tier, and unit or process among the events.
+func recoveriesFor(events []*event.Event, at time.Time) []store.Recovery {
+	seen := make(map[store.Recovery]bool)
+	var out []store.Recovery
+	for _, ev := range events {
+		if ev.Unit == "" && ev.Process == "" {
+			continue
+		}
+		r := store.Recovery{InstanceID: ev.InstanceID, Tier: ev.Tier, Unit: ev.Unit, At: at}
+		if r.Unit == "" {
+			r.Process = ev.Process
+		}
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -4,31 +4,53 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/setevik/logtriage/internal/boot"
+	"github.com/setevik/logtriage/internal/bundle"
+	"github.com/setevik/logtriage/internal/capture"
 	"github.com/setevik/logtriage/internal/classifier"
 	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/control"
+	"github.com/setevik/logtriage/internal/cooldown"
 	"github.com/setevik/logtriage/internal/enricher"
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/health"
+	"github.com/setevik/logtriage/internal/hook"
 	"github.com/setevik/logtriage/internal/monitor"
+	"github.com/setevik/logtriage/internal/remediate"
 	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/selfmon"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/server"
 	"github.com/setevik/logtriage/internal/store"
+	"github.com/setevik/logtriage/internal/suppress"
+	"github.com/setevik/logtriage/internal/sysdep"
 	"github.com/setevik/logtriage/internal/watcher"
+	"github.com/setevik/logtriage/internal/web"
 )
 
-var version = "dev"
-
 func main() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -41,11 +63,56 @@ func main() {
 		case "status":
 			runStatus(os.Args[2:])
 			return
+		case "motd":
+			runMotd(os.Args[2:])
+			return
+		case "capture":
+			runCapture(os.Args[2:])
+			return
+		case "record":
+			runRecord(os.Args[2:])
+			return
 		case "test-ntfy":
 			runTestNtfyCmd(os.Args[2:])
 			return
+		case "test-pattern":
+			runTestPattern(os.Args[2:])
+			return
+		case "events":
+			runEvents(os.Args[2:])
+			return
+		case "why":
+			runWhy(os.Args[2:])
+			return
+		case "snooze":
+			runSnooze(os.Args[2:])
+			return
+		case "retry-notifications":
+			runRetryNotifications(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "db":
+			runDB(os.Args[2:])
+			return
+		case "check-config":
+			runCheckConfig(os.Args[2:])
+			return
+		case "ctl":
+			runCtl(os.Args[2:])
+			return
+		case "reload":
+			runReload(os.Args[2:])
+			return
 		case "version":
-			fmt.Println("logtriage", version)
+			runVersion(os.Args[2:])
 			return
 		}
 	}
@@ -72,26 +139,38 @@ func runDaemon(args []string) {
 		os.Exit(1)
 	}
 
-	setupLogging(cfg.Log.Level)
+	// The self-monitor watches the daemon's own log for failures that keep
+	// recurring and raises them as T0 events.
+	var selfMon *selfmon.Monitor
+	if cfg.SelfMonitor.Enabled {
+		selfMon = selfmon.New(cfg.Instance.ID, cfg.SelfMonitor.Repeats, cfg.SelfMonitor.Window.Duration)
+	}
+	slog.SetDefault(slog.New(selfMon.Handler(logHandler(cfg.Log.Level))))
 
 	slog.Info("logtriage starting",
 		"version", version,
 		"instance", cfg.Instance.ID,
 		"role", cfg.Instance.Role,
+		"config_files", cfg.Files,
 	)
+	for _, p := range cfg.Validate() {
+		if p.Warning {
+			slog.Warn("config warning", "problem", p.String())
+		}
+	}
 
 	if *testNtfy {
 		doTestNtfy(cfg)
 		return
 	}
 
-	if err := run(cfg); err != nil {
+	if err := run(cfg, *configPath, selfMon); err != nil {
 		slog.Error("fatal error", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run(cfg *config.Config) error {
+func run(cfg *config.Config, configPath string, selfMon *selfmon.Monitor) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -99,6 +178,10 @@ func run(cfg *config.Config) error {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP reloads the config.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
 	// Create cursor file path for journalctl resume.
 	dataDir, err := dataDirectory()
 	if err != nil {
@@ -107,13 +190,17 @@ func run(cfg *config.Config) error {
 	cursorFile := filepath.Join(dataDir, "journal-cursor")
 
 	// Open event database.
-	db, err := store.Open(cfg.DBPath())
+	db, err := openDB(cfg)
 	if err != nil {
 		return fmt.Errorf("opening event database: %w", err)
 	}
 	defer db.Close()
+	db.SetDedupKeys(cfg.Cooldown.Keys)
+	if cfg.DB.WriteQueue > 0 {
+		db.StartWriter(cfg.DB.WriteQueue)
+	}
 
-	slog.Info("event database opened", "path", cfg.DBPath())
+	slog.Info("event database opened", "location", db.Location(), "write_queue", cfg.DB.WriteQueue)
 
 	// Run retention purge on startup.
 	if cfg.DB.Retention.Duration > 0 {
@@ -127,32 +214,337 @@ func run(cfg *config.Config) error {
 
 	// Set up the pipeline: watcher -> classifier -> enricher -> store + dedup -> reporter.
 	cls := classifier.New(cfg.Instance.ID)
-	enr := enricher.New()
-	rep := reporter.NewNtfy(cfg)
+	if err := cls.SetRules(cfg.Rules); err != nil {
+		return fmt.Errorf("loading classification rules: %w", err)
+	}
+	if len(cfg.Rules) > 0 {
+		slog.Info("user classification rules loaded", "count", len(cfg.Rules))
+	}
+	enr := enricher.New(enricherOptions(cfg))
+	sup, err := suppress.New(cfg.Suppress.Rules)
+	if err != nil {
+		return fmt.Errorf("loading suppression rules: %w", err)
+	}
+	if sup.Len() > 0 {
+		slog.Info("suppression rules loaded", "count", sup.Len())
+	}
+	cd, err := cooldown.New(cfg.Cooldown)
+	if err != nil {
+		return fmt.Errorf("loading cooldown overrides: %w", err)
+	}
+	remedy, err := newRemediator(cfg)
+	if err != nil {
+		return fmt.Errorf("loading remediation actions: %w", err)
+	}
+	if remedy != nil {
+		slog.Info("remediation enabled",
+			"actions", len(cfg.Remediation.Actions),
+			"allowed_units", cfg.Remediation.AllowedUnits,
+		)
+	}
+	hooks, err := newHooks(cfg)
+	if err != nil {
+		return fmt.Errorf("loading hooks: %w", err)
+	}
+	if hooks != nil {
+		slog.Info("hooks loaded", "count", hooks.Len())
+	}
 
-	// Create supervised journal source.
-	supervised := watcher.NewSupervisedSource(
-		func() watcher.JournalSource {
-			return watcher.NewPipeSource(cursorFile)
-		},
-		5*time.Second, // restart wait
-		0,             // unlimited restarts
-	)
+	p := &pipeline{
+		cfg: cfg,
+		cls: cls,
+		enr: enr,
+		db:  db,
+		rep: newReporter(cfg),
+		sup: sup,
+
+		cooldown:   cd,
+		remedy:     remedy,
+		hooks:      hooks,
+		escalation: newEscalationReporter(cfg),
+		selfmon:    selfMon,
+		stats:      newPipelineStats(),
+	}
+	if cfg.Storm.Enabled {
+		p.storm = cls.NewStormGuard(cfg.Storm.Rate)
+	}
+	p.rep.OnBatchFailure(p.batchFailed(cfg))
+	p.rep.OnAttempt(logAttempts(db))
+	// Let running hooks finish, or be stopped, before the daemon exits.
+	defer func() {
+		if p.hooks != nil {
+			cancel()
+			p.hooks.Wait()
+		}
+	}()
+	if cfg.Capture.Enabled {
+		p.capture = capture.New(cfg.Capture, db)
+		// Let an in-flight capture save before the database closes.
+		defer func() {
+			cancel()
+			p.capture.Wait()
+		}()
+		slog.Info("debug capture enabled",
+			"duration", cfg.Capture.Duration.Duration,
+			"priority", cfg.Capture.Priority,
+		)
+	}
+	if cfg.Bundle.Enabled {
+		p.bundle = bundle.New(cfg.Bundle, cfg.BundleDir())
+		defer func() {
+			cancel()
+			p.bundle.Wait()
+		}()
+		slog.Info("diagnostic bundles enabled",
+			"dir", cfg.BundleDir(),
+			"max_bundles", cfg.Bundle.MaxBundles,
+		)
+	}
+	if cfg.Agent.HubURL != "" {
+		fwd, err := reporter.NewForward(cfg)
+		if err != nil {
+			return fmt.Errorf("starting hub forwarder: %w", err)
+		}
+		p.fwd = fwd
+		go fwd.Run(ctx)
+		slog.Info("forwarding events to hub",
+			"url", cfg.Agent.HubURL,
+			"notify_local", cfg.Agent.NotifyLocal,
+			"spool", cfg.SpoolPath(),
+		)
+	}
+	if cfg.Syslog.Enabled {
+		sl, err := reporter.NewSyslog(cfg)
+		if err != nil {
+			return fmt.Errorf("starting syslog export: %w", err)
+		}
+		p.syslog = sl
+		defer sl.Close()
+		slog.Info("exporting events to syslog", "network", cfg.Syslog.Network, "address", cfg.Syslog.Address)
+	}
+	if cfg.OTel.Enabled {
+		p.otel = reporter.NewOTel(cfg)
+		go p.otel.Run(ctx)
+		defer func() {
+			if err := p.otel.Close(); err != nil {
+				slog.Error("failed to export to OpenTelemetry collector on shutdown", "error", err)
+			}
+		}()
+		slog.Info("exporting events to OpenTelemetry collector", "endpoint", cfg.OTel.Endpoint, "spans", cfg.OTel.Spans)
+	}
+	if cfg.Loki.Enabled {
+		p.loki = reporter.NewLoki(cfg)
+		go p.loki.Run(ctx)
+		defer func() {
+			if err := p.loki.Close(); err != nil {
+				slog.Error("failed to push events to Loki on shutdown", "error", err)
+			}
+		}()
+		slog.Info("pushing events to Loki", "url", cfg.Loki.URL, "tenant", cfg.Loki.Tenant)
+	}
 
-	entries, err := supervised.Entries(ctx)
+	// Every external tool is optional; report what this host lacks.
+	for _, t := range sysdep.Missing() {
+		slog.Info("optional tool not found", "tool", t.Name, "disables", t.Feature)
+	}
+
+	// Record this run so the digest can report restarts and losses.
+	selfRun, err := db.StartRun(cfg.Instance.ID, capabilityWarnings(cfg))
 	if err != nil {
-		return fmt.Errorf("starting journal watcher: %w", err)
+		slog.Warn("failed to record run", "error", err)
+	}
+
+	// Track pipeline health; the systemd watchdog is only petted while
+	// every check passes.
+	checker := health.NewChecker()
+	checker.Add("database", db.CheckWritable)
+	var lastEntry atomic.Int64 // unix microseconds of the newest entry received
+
+	// Monitors register how to apply a reloaded config's thresholds.
+	var reloads []func(*config.Config)
+	reloads = append(reloads, func(c *config.Config) { enr.SetOptions(enricherOptions(c)) })
+
+	// Create supervised journal source. Hosts without systemd (routers, some
+	// SBC images) still run the monitors and the hub API.
+	var entries <-chan watcher.JournalEntry
+	if sysdep.Have("journalctl") {
+		supervised := watcher.NewSupervisedSource(
+			func() watcher.JournalSource {
+				return watcher.NewPipeSource(cursorFile)
+			},
+			5*time.Second, // restart wait
+			0,             // unlimited restarts
+		)
+
+		entries, err = supervised.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting journal watcher: %w", err)
+		}
+		checker.Add("journal", health.Journal(
+			func() time.Time {
+				if us := lastEntry.Load(); us > 0 {
+					return time.UnixMicro(us)
+				}
+				return time.Time{}
+			},
+			watcher.LatestTimestamp,
+			cfg.Health.JournalGrace.Duration,
+		))
+	} else {
+		slog.Warn("journalctl not found, journal watching disabled")
+	}
+
+	// On Windows, the Event Log stands in for the journal.
+	var eventLogEntries <-chan watcher.JournalEntry
+	if cfg.EventLog.Enabled && runtime.GOOS == "windows" {
+		src := watcher.NewEventLogSource(dataDir, cfg.EventLog.Channels, cfg.EventLog.PollInterval.Duration)
+		eventLogEntries, err = src.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting event log watcher: %w", err)
+		}
+	}
+
+	// Container runtimes log exits at info level, below the main stream's
+	// priority filter, so they are followed separately. The ranges do not
+	// overlap, so no entry is seen twice.
+	var containerEntries <-chan watcher.JournalEntry
+	if cfg.Containers.Enabled && sysdep.Have("journalctl") && (sysdep.Have("docker") || sysdep.Have("podman")) {
+		containerCursor := filepath.Join(dataDir, "container-cursor")
+		supervised := watcher.NewSupervisedSource(
+			func() watcher.JournalSource {
+				return watcher.NewMatchSource(containerCursor, "4..6", containerMatches)
+			},
+			5*time.Second, // restart wait
+			0,             // unlimited restarts
+		)
+		containerEntries, err = supervised.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting container journal watcher: %w", err)
+		}
+		slog.Info("container runtime watcher started")
+	}
+
+	// Pod container exits are not in the journal in a usable form, so the
+	// runtime is polled with crictl. Its entries join the journal's.
+	var podEntries <-chan watcher.JournalEntry
+	if cfg.Kubernetes.Enabled && sysdep.Have("crictl") {
+		podEntries, err = watcher.NewCRISource(cfg.Kubernetes.PollInterval.Duration).Entries(ctx)
+		if err != nil {
+			slog.Warn("pod container watcher disabled, cannot reach the container runtime", "error", err)
+		}
+	}
+
+	// The main stream already covers the user's own journal at error
+	// priority, but the user service manager reports failed units at
+	// warning level, so user units are followed at that level only.
+	var userEntries <-chan watcher.JournalEntry
+	if cfg.UserJournal.Enabled && sysdep.Have("journalctl") {
+		userCursor := filepath.Join(dataDir, "user-cursor")
+		supervised := watcher.NewSupervisedSource(
+			func() watcher.JournalSource {
+				return watcher.NewUserSource(userCursor, "4", cfg.UserJournal.Units)
+			},
+			5*time.Second, // restart wait
+			0,             // unlimited restarts
+		)
+		userEntries, err = supervised.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting user journal watcher: %w", err)
+		}
+		slog.Info("user journal watcher started", "units", cfg.UserJournal.Units)
+	}
+
+	// Audit records carry no priority, so they are followed separately:
+	// from the journal where journald collects them, otherwise by polling
+	// ausearch. Failed authentications only matter repeated, so they are
+	// counted rather than classified.
+	var auditEntries <-chan watcher.JournalEntry
+	var authGuard *classifier.AuthGuard
+	if cfg.Audit.Enabled {
+		switch {
+		case cfg.Audit.Source == "journal" && sysdep.Have("journalctl"):
+			auditCursor := filepath.Join(dataDir, "audit-cursor")
+			supervised := watcher.NewSupervisedSource(
+				func() watcher.JournalSource {
+					return watcher.NewMatchSource(auditCursor, "", auditMatches)
+				},
+				5*time.Second, // restart wait
+				0,             // unlimited restarts
+			)
+			auditEntries, err = supervised.Entries(ctx)
+			if err != nil {
+				return fmt.Errorf("starting audit journal watcher: %w", err)
+			}
+			slog.Info("audit journal watcher started")
+		case cfg.Audit.Source == "ausearch" && sysdep.Have("ausearch"):
+			auditEntries, err = watcher.NewAuditSource(cfg.Audit.PollInterval.Duration).Entries(ctx)
+			if err != nil {
+				slog.Warn("audit log watcher disabled, cannot search the audit log", "error", err)
+			}
+		default:
+			slog.Warn("audit.enabled is set but its source is not available, audit watching disabled", "source", cfg.Audit.Source)
+		}
+		if cfg.Audit.AuthFailures > 0 {
+			authGuard = cls.NewAuthGuard(cfg.Audit.AuthFailures, cfg.Audit.AuthWindow.Duration)
+		}
+	}
+
+	// sshd and login log failed and root logins at info level, below the
+	// main stream's priority filter, so they are followed separately. Failed
+	// SSH logins are counted per source address rather than classified one
+	// by one.
+	var authEntries <-chan watcher.JournalEntry
+	var sshGuard *classifier.SSHGuard
+	if (cfg.SSH.Enabled || cfg.Security.Enabled) && sysdep.Have("journalctl") {
+		authCursor := filepath.Join(dataDir, "auth-cursor")
+		supervised := watcher.NewSupervisedSource(
+			func() watcher.JournalSource {
+				return watcher.NewMatchSource(authCursor, "4..6", authMatches)
+			},
+			5*time.Second, // restart wait
+			0,             // unlimited restarts
+		)
+		authEntries, err = supervised.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting login journal watcher: %w", err)
+		}
+		if cfg.SSH.Enabled {
+			sshGuard = cls.NewSSHGuard(cfg.SSH.Failures, cfg.SSH.Window.Duration)
+			slog.Info("SSH brute-force watcher started", "failures", cfg.SSH.Failures, "window", cfg.SSH.Window.Duration)
+		}
+	}
+
+	// Kernel warnings and general protection faults are logged at warning
+	// level, below the main stream's priority filter. Only the first line of
+	// each report is handled; the enricher gathers the rest.
+	var kernelEntries <-chan watcher.JournalEntry
+	if sysdep.Have("journalctl") {
+		kernelCursor := filepath.Join(dataDir, "kernel-cursor")
+		supervised := watcher.NewSupervisedSource(
+			func() watcher.JournalSource {
+				return watcher.NewMatchSource(kernelCursor, "4", kernelMatches)
+			},
+			5*time.Second, // restart wait
+			0,             // unlimited restarts
+		)
+		kernelEntries, err = supervised.Entries(ctx)
+		if err != nil {
+			return fmt.Errorf("starting kernel journal watcher: %w", err)
+		}
 	}
 
 	// Start PSI monitor if enabled.
 	var psiEvents <-chan monitor.PSIEvent
 	if cfg.PSI.Enabled {
 		psiMon := monitor.NewPSIMonitor(
+			monitor.PSIMemory,
 			cfg.PSI.PollInterval.Duration,
 			cfg.PSI.WarnSomeAvg10,
 			cfg.PSI.WarnFullAvg10,
 		)
 		psiEvents = psiMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { psiMon.SetThresholds(c.PSI.WarnSomeAvg10, c.PSI.WarnFullAvg10) })
+		checker.Add("psi", health.Fresh(psiMon.LastPoll, monitorStaleAfter(cfg.PSI.PollInterval.Duration)))
 		slog.Info("PSI monitor started",
 			"interval", cfg.PSI.PollInterval.Duration,
 			"warn_some", cfg.PSI.WarnSomeAvg10,
@@ -160,42 +552,371 @@ func run(cfg *config.Config) error {
 		)
 	}
 
+	// Start the CPU and IO pressure monitors if enabled.
+	var cpuPSIEvents, ioPSIEvents <-chan monitor.PSIEvent
+	if cfg.PSI.Enabled && cfg.PSI.CPU.Enabled {
+		cpuMon := monitor.NewPSIMonitor(monitor.PSICPU, cfg.PSI.PollInterval.Duration, cfg.PSI.CPU.WarnSomeAvg10, cfg.PSI.CPU.WarnFullAvg10)
+		cpuPSIEvents = cpuMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { cpuMon.SetThresholds(c.PSI.CPU.WarnSomeAvg10, c.PSI.CPU.WarnFullAvg10) })
+		checker.Add("psi_cpu", health.Fresh(cpuMon.LastPoll, monitorStaleAfter(cfg.PSI.PollInterval.Duration)))
+		slog.Info("CPU pressure monitor started", "warn_some", cfg.PSI.CPU.WarnSomeAvg10, "warn_full", cfg.PSI.CPU.WarnFullAvg10)
+	}
+	if cfg.PSI.Enabled && cfg.PSI.IO.Enabled {
+		ioMon := monitor.NewPSIMonitor(monitor.PSIIO, cfg.PSI.PollInterval.Duration, cfg.PSI.IO.WarnSomeAvg10, cfg.PSI.IO.WarnFullAvg10)
+		ioPSIEvents = ioMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { ioMon.SetThresholds(c.PSI.IO.WarnSomeAvg10, c.PSI.IO.WarnFullAvg10) })
+		checker.Add("psi_io", health.Fresh(ioMon.LastPoll, monitorStaleAfter(cfg.PSI.PollInterval.Duration)))
+		slog.Info("IO pressure monitor started", "warn_some", cfg.PSI.IO.WarnSomeAvg10, "warn_full", cfg.PSI.IO.WarnFullAvg10)
+	}
+
+	// Start swap thrash monitor if enabled.
+	var thrashEvents <-chan monitor.ThrashEvent
+	if cfg.Thrash.Enabled {
+		thrashMon := monitor.NewThrashMonitor(cfg.Thrash.PollInterval.Duration, thrashThresholds(cfg.Thrash))
+		thrashEvents = thrashMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { thrashMon.SetThresholds(thrashThresholds(c.Thrash)) })
+		checker.Add("thrash", health.Fresh(thrashMon.LastPoll, monitorStaleAfter(cfg.Thrash.PollInterval.Duration)))
+		slog.Info("thrash monitor started",
+			"interval", cfg.Thrash.PollInterval.Duration,
+			"majfault_rate", cfg.Thrash.MajFaultRate,
+			"swap_rate", cfg.Thrash.SwapRate,
+			"mem_available_warn_pct", cfg.Thrash.MemAvailableWarnPct,
+			"sustain", cfg.Thrash.Sustain.Duration,
+		)
+	}
+
 	// Start SMART monitor if enabled.
 	var smartEvents <-chan monitor.SMARTEvent
-	if cfg.SMART.Enabled {
-		smartMon := monitor.NewSMARTMonitor(cfg.SMART.PollInterval.Duration)
+	if cfg.SMART.Enabled && !sysdep.Have("smartctl") {
+		slog.Warn("smart.enabled is set but smartctl is not installed, SMART monitor disabled")
+	} else if cfg.SMART.Enabled {
+		smartMon := monitor.NewSMARTMonitor(cfg.SMART.PollInterval.Duration, smartTempLimits(cfg.SMART),
+			func(s monitor.SMARTStatus) {
+				if s.Temperature <= 0 {
+					return
+				}
+				if err := db.RecordMetric(cfg.Instance.ID, store.MetricDiskTemp, s.Device, time.Now(), float64(s.Temperature)); err != nil {
+					slog.Warn("recording drive temperature failed", "device", s.Device, "error", err)
+				}
+			})
 		smartEvents = smartMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { smartMon.SetTempLimits(smartTempLimits(c.SMART)) })
+		checker.Add("smart", health.Fresh(smartMon.LastPoll, monitorStaleAfter(cfg.SMART.PollInterval.Duration)))
 		slog.Info("SMART monitor started", "interval", cfg.SMART.PollInterval.Duration)
 	}
 
+	// Start storage array monitor if enabled.
+	var arrayEvents <-chan monitor.ArrayEvent
+	if cfg.Arrays.Enabled {
+		arrayMon := monitor.NewArrayMonitor(cfg.Arrays.PollInterval.Duration)
+		arrayEvents = arrayMon.Events(ctx)
+		checker.Add("arrays", health.Fresh(arrayMon.LastPoll, monitorStaleAfter(cfg.Arrays.PollInterval.Duration)))
+		slog.Info("storage array monitor started", "interval", cfg.Arrays.PollInterval.Duration)
+	}
+
+	// Start the eBPF tracer if enabled.
+	var traceEvents <-chan monitor.TraceEvent
+	if cfg.EBPF.Enabled && sysdep.Have("bpftrace") {
+		traceEvents = monitor.NewTraceMonitor().Events(ctx)
+		slog.Info("eBPF tracer started")
+	}
+
 	// Start GPU monitor if enabled.
 	var gpuEvents <-chan monitor.GPUEvent
 	if cfg.GPU.Enabled {
-		gpuMon := monitor.NewGPUMonitor(
-			cfg.GPU.PollInterval.Duration,
-			cfg.GPU.TempWarn,
-			cfg.GPU.VRAMWarnPct,
-		)
+		gpuMon := monitor.NewGPUMonitor(cfg.GPU.PollInterval.Duration, gpuThresholds(cfg.GPU))
 		gpuEvents = gpuMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { gpuMon.SetThresholds(gpuThresholds(c.GPU)) })
+		checker.Add("gpu", health.Fresh(gpuMon.LastPoll, monitorStaleAfter(cfg.GPU.PollInterval.Duration)))
 		slog.Info("GPU monitor started",
 			"interval", cfg.GPU.PollInterval.Duration,
 			"temp_warn", cfg.GPU.TempWarn,
 			"vram_warn_pct", cfg.GPU.VRAMWarnPct,
+			"card_overrides", len(cfg.GPU.Cards),
+		)
+	}
+
+	// Start quota monitor if enabled.
+	var quotaEvents <-chan monitor.QuotaEvent
+	if cfg.Quota.Enabled && !sysdep.Have("repquota") && !sysdep.Have("xfs_quota") {
+		slog.Warn("quota.enabled is set but neither repquota nor xfs_quota is installed, quota monitor disabled")
+	} else if cfg.Quota.Enabled {
+		quotaMon := monitor.NewQuotaMonitor(
+			cfg.Quota.PollInterval.Duration,
+			cfg.Quota.WarnPct,
+			cfg.Quota.Subjects,
+		)
+		quotaEvents = quotaMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { quotaMon.SetWarnPct(c.Quota.WarnPct) })
+		checker.Add("quota", health.Fresh(quotaMon.LastPoll, monitorStaleAfter(cfg.Quota.PollInterval.Duration)))
+		slog.Info("quota monitor started",
+			"interval", cfg.Quota.PollInterval.Duration,
+			"warn_pct", cfg.Quota.WarnPct,
 		)
 	}
 
+	// Start unit limit monitor if enabled.
+	var unitLimitEvents <-chan monitor.UnitLimitEvent
+	if cfg.UnitLimits.Enabled && !sysdep.Have("systemctl") {
+		slog.Warn("unit_limits.enabled is set but systemctl is not installed, unit limit monitor disabled")
+	} else if cfg.UnitLimits.Enabled && len(cfg.UnitLimits.Units) == 0 {
+		slog.Warn("unit_limits.enabled is set but no units are listed, unit limit monitor disabled")
+	} else if cfg.UnitLimits.Enabled {
+		unitLimitMon := monitor.NewUnitLimitMonitor(cfg.UnitLimits.PollInterval.Duration, cfg.UnitLimits.Units,
+			monitor.DiskThresholds{WarnPct: cfg.UnitLimits.WarnPct, CritPct: cfg.UnitLimits.CritPct})
+		unitLimitEvents = unitLimitMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) {
+			unitLimitMon.SetThresholds(monitor.DiskThresholds{WarnPct: c.UnitLimits.WarnPct, CritPct: c.UnitLimits.CritPct})
+		})
+		checker.Add("unit_limits", health.Fresh(unitLimitMon.LastPoll, monitorStaleAfter(cfg.UnitLimits.PollInterval.Duration)))
+		slog.Info("unit limit monitor started",
+			"interval", cfg.UnitLimits.PollInterval.Duration,
+			"units", cfg.UnitLimits.Units,
+		)
+	}
+
+	// Start disk space monitor if enabled.
+	var diskEvents <-chan monitor.DiskSpaceEvent
+	if cfg.Disk.Enabled {
+		mounts := diskMounts(cfg.Disk)
+		diskMon := monitor.NewDiskSpaceMonitor(cfg.Disk.PollInterval.Duration, mounts, cfg.Disk.TopDirs)
+		diskEvents = diskMon.Events(ctx)
+		reloads = append(reloads, func(c *config.Config) { diskMon.SetMounts(diskMounts(c.Disk)) })
+		checker.Add("diskspace", health.Fresh(diskMon.LastPoll, monitorStaleAfter(cfg.Disk.PollInterval.Duration)))
+		slog.Info("disk space monitor started",
+			"interval", cfg.Disk.PollInterval.Duration,
+			"mounts", len(mounts),
+		)
+	}
+
+	// Start hardware inventory monitor if enabled.
+	var inventoryEvents <-chan monitor.InventoryEvent
+	if cfg.Inventory.Enabled {
+		last, lastAt := inventoryBaseline(db, cfg.Instance.ID)
+		invMon := monitor.NewInventoryMonitor(cfg.Inventory.PollInterval.Duration, cfg.Inventory.Ignore, last, lastAt,
+			func(inv monitor.Inventory, at time.Time) { saveInventory(db, cfg.Instance.ID, inv, at) })
+		inventoryEvents = invMon.Events(ctx)
+		checker.Add("inventory", health.Fresh(invMon.LastPoll, monitorStaleAfter(cfg.Inventory.PollInterval.Duration)))
+		slog.Info("hardware inventory monitor started", "interval", cfg.Inventory.PollInterval.Duration)
+	}
+
+	// Start systemd unit state monitor if enabled.
+	var unitEvents <-chan monitor.UnitEvent
+	var unitMon *monitor.UnitMonitor
+	if cfg.Units.Enabled {
+		unitMon = monitor.NewUnitMonitor(cfg.Units.Match, cfg.Units.Ignore)
+		unitEvents = unitMon.Events(ctx)
+		slog.Info("systemd unit monitor started", "match", cfg.Units.Match, "ignore", cfg.Units.Ignore)
+	}
+
+	// Watch authorized_keys files for new keys if enabled.
+	var keyEvents <-chan monitor.AuthorizedKeyEvent
+	if cfg.Security.Enabled && len(cfg.Security.AuthorizedKeys) > 0 {
+		keyMon := monitor.NewAuthorizedKeysMonitor(cfg.Security.PollInterval.Duration, cfg.Security.AuthorizedKeys,
+			filepath.Join(dataDir, "authorized-keys.json"))
+		keyEvents = keyMon.Events(ctx)
+		checker.Add("authorized_keys", health.Fresh(keyMon.LastPoll, monitorStaleAfter(cfg.Security.PollInterval.Duration)))
+		slog.Info("authorized_keys monitor started", "interval", cfg.Security.PollInterval.Duration, "files", cfg.Security.AuthorizedKeys)
+	}
+
+	// Start hub ingest API if enabled.
+	var remoteEvents <-chan *event.Event
+	if cfg.Hub.Listen != "" {
+		if cfg.Hub.Token == "" {
+			return fmt.Errorf("hub.listen requires hub.token to be set")
+		}
+		srv := server.New(cfg.Hub.Listen, cfg.Hub.Token)
+		if err := srv.Start(ctx); err != nil {
+			return fmt.Errorf("starting hub API: %w", err)
+		}
+		remoteEvents = srv.Events()
+		slog.Info("hub API listening", "addr", cfg.Hub.Listen)
+	}
+
+	if cfg.Health.Listen != "" {
+		if err := health.Serve(ctx, cfg.Health.Listen, checker); err != nil {
+			return fmt.Errorf("starting health endpoint: %w", err)
+		}
+		slog.Info("health endpoint listening", "addr", cfg.Health.Listen)
+	}
+
+	var ackEvents <-chan *event.Event
+	if cfg.Web.Listen != "" {
+		p.web, err = web.New(cfg.Web.Listen, cfg.Instance.ID, db, cfg.Web.Tokens)
+		if err != nil {
+			return fmt.Errorf("starting web dashboard: %w", err)
+		}
+		if cfg.Ack.Enabled {
+			p.web.EnableAck(cfg.Ack)
+			ackEvents = p.web.Acks()
+			slog.Info("ntfy ack button enabled", "url", cfg.Ack.URL, "duration", cfg.Ack.Duration.Duration)
+		}
+		if err := p.web.Start(ctx); err != nil {
+			return fmt.Errorf("starting web dashboard: %w", err)
+		}
+		slog.Info("web dashboard listening", "addr", p.web.Addr(), "tokens", len(cfg.Web.Tokens))
+	}
+
+	// Report how the previous boot ended, once per boot.
+	if cfg.Boot.Enabled && sysdep.Have("journalctl") {
+		report, err := boot.CheckOnce(ctx, filepath.Join(dataDir, "last-boot-id"))
+		if err != nil {
+			slog.Warn("previous boot analysis failed", "error", err)
+		} else if report != nil {
+			p.handle(ctx, cls.ClassifyRebootEvent(
+				report.LastEntry, report.Panic, report.BootID, report.Summary(), report.Detail(),
+			))
+		}
+	}
+
 	// Notify systemd we are ready (sd_notify).
 	sdNotify("READY=1")
 
 	// Start watchdog ticker if WatchdogSec is configured.
 	var watchdogTicker *time.Ticker
+	var checkInterval time.Duration
 	if wdInterval := watchdogInterval(); wdInterval > 0 {
 		// Ping at half the watchdog interval.
-		watchdogTicker = time.NewTicker(wdInterval / 2)
+		checkInterval = wdInterval / 2
+		watchdogTicker = time.NewTicker(checkInterval)
 		defer watchdogTicker.Stop()
 		slog.Info("systemd watchdog enabled", "interval", wdInterval)
 	}
 
+	// Ping heartbeat.url while healthy, so a dead man's switch notices when
+	// the daemon or host goes down. Like the watchdog, an unhealthy daemon
+	// withholds the ping.
+	var heartbeatTicker *time.Ticker
+	heartbeat := reporter.NewHeartbeat(cfg)
+	if cfg.Heartbeat.URL != "" {
+		heartbeatTicker = time.NewTicker(cfg.Heartbeat.Interval.Duration)
+		defer heartbeatTicker.Stop()
+		go pingHeartbeat(ctx, heartbeat)
+		slog.Info("heartbeat enabled", "interval", cfg.Heartbeat.Interval.Duration)
+		if checkInterval == 0 || cfg.Heartbeat.Interval.Duration < checkInterval {
+			checkInterval = cfg.Heartbeat.Interval.Duration
+		}
+	}
+
+	// The pings read the latest health status, checked on its own
+	// goroutine, so a slow check (a busy disk under the database) never
+	// stalls the event loop. Checks that stop completing read as unhealthy.
+	if checkInterval > 0 {
+		go checker.Loop(ctx, checkInterval)
+	}
+
+	// Close incidents that have gone quiet for a cooldown window.
+	incidentTicker := time.NewTicker(time.Minute)
+	defer incidentTicker.Stop()
+
+	// Send digests on digest.schedule, checked with the incidents.
+	digests := newDigestScheduler(cfg, db, time.Now())
+
+	// Back up the database on db.backup_interval, also checked with the
+	// incidents. A backup in progress finishes before the database closes.
+	backups := newBackupScheduler(cfg, db, time.Now())
+	if backups != nil {
+		defer backups.wait()
+	}
+
+	// Reload when the config files change, for services that are never
+	// sent SIGHUP. Edits that come while a reload is pending are merged.
+	var configWatch *config.Watcher
+	if cfg.Reload.Watch {
+		w, err := config.Watch(configPath, cfg)
+		if err != nil {
+			slog.Warn("not watching config files, reload with SIGHUP", "error", err)
+		} else {
+			defer w.Close()
+			go w.Run(ctx)
+			configWatch = w
+		}
+	}
+
+	// reload re-reads the config and applies it, on SIGHUP, a reload over
+	// the control socket, or an edit to the config files. A config that is
+	// rejected raises a T0 event, as nobody may be watching the log.
+	reload := func() error {
+		prev := p.cfg
+		next, err := config.Load(configPath)
+		if err == nil {
+			err = p.reload(ctx, next)
+		}
+		if err != nil {
+			slog.Error("config reload failed, keeping the running config", "error", err)
+			p.handle(ctx, configRejectedEvent(cfg.Instance.ID, err))
+			return err
+		}
+		if configWatch != nil {
+			configWatch.Update(next)
+		}
+		for _, apply := range reloads {
+			apply(next)
+		}
+		if !reflect.DeepEqual(prev.Digest, next.Digest) {
+			digests = newDigestScheduler(next, db, time.Now())
+		}
+		// Compared with the config started with, which those sections
+		// still run on.
+		for _, key := range restartRequired(cfg, next) {
+			slog.Warn("config change takes effect on restart", "section", key)
+		}
+		slog.Info("config reloaded", "config_files", next.Files, "rules", len(next.Rules))
+		return nil
+	}
+
+	// Answer the CLI on the control socket. Its commands that read
+	// pipeline state run on this loop.
+	var controlCalls <-chan func()
+	if cfg.Control.Enabled {
+		ctl := control.New(cfg.ControlSocket())
+		p.serveControl(ctl, checker, reload)
+		if err := ctl.Start(ctx); err != nil {
+			return fmt.Errorf("starting control socket: %w", err)
+		}
+		controlCalls = ctl.Calls()
+		slog.Info("control socket listening", "path", ctl.Path())
+	}
+
+	// handleEntry classifies a journal entry and runs any event through the
+	// pipeline.
+	ownPID := strconv.Itoa(os.Getpid())
+	handleEntry := func(entry watcher.JournalEntry) {
+		// Never classify our own lines, such as events exported to the
+		// local syslog socket.
+		if entry.PID == ownPID {
+			return
+		}
+		p.stats.Entries++
+		if name := p.sup.MatchEntry(entry); name != "" {
+			slog.Debug("journal entry dropped by suppression rule", "rule", name)
+			return
+		}
+
+		ev := cls.Classify(entry)
+		if ev == nil {
+			if p.cfg.Catchall.Enabled {
+				if ev := cls.ClassifyUnclassified(entry, p.cfg.Catchall.MaxPriority); ev != nil {
+					p.keep(ctx, ev)
+				}
+			}
+			return
+		}
+		if ev.Tier == event.TierSecurity {
+			p.handleSecurity(ctx, ev)
+			return
+		}
+		// D-Bus reports unit failures exactly; the built-in journal
+		// text patterns are only needed while it is unavailable. It
+		// watches the system manager, so user units still need them.
+		if ev.Tier == event.TierServiceFailure && ev.RawFields["_rule"] == "" &&
+			ev.RawFields["_user_unit"] == "" && unitMon != nil && unitMon.Connected() {
+			return
+		}
+
+		p.handle(ctx, ev)
+	}
+
 	slog.Info("pipeline started, watching for events")
 
 	for {
@@ -204,6 +925,18 @@ func run(cfg *config.Config) error {
 		if watchdogTicker != nil {
 			watchdogCh = watchdogTicker.C
 		}
+		var heartbeatCh <-chan time.Time
+		if heartbeatTicker != nil {
+			heartbeatCh = heartbeatTicker.C
+		}
+		var selfEvents <-chan *event.Event
+		if selfMon != nil {
+			selfEvents = selfMon.Events()
+		}
+		var configChanges <-chan struct{}
+		if configWatch != nil {
+			configChanges = configWatch.Changes()
+		}
 
 		select {
 		case entry, ok := <-entries:
@@ -212,29 +945,125 @@ func run(cfg *config.Config) error {
 				return nil
 			}
 
-			ev := cls.Classify(entry)
-			if ev == nil {
+			if t := entry.Time(); t.UnixMicro() > lastEntry.Load() {
+				lastEntry.Store(t.UnixMicro())
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-containerEntries:
+			if !ok {
+				containerEntries = nil
+				continue
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-eventLogEntries:
+			if !ok {
+				eventLogEntries = nil
+				continue
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-podEntries:
+			if !ok {
+				podEntries = nil
+				continue
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-userEntries:
+			if !ok {
+				userEntries = nil
+				continue
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-auditEntries:
+			if !ok {
+				auditEntries = nil
+				continue
+			}
+			if af, ok := classifier.ParseAuthFailure(entry); ok {
+				if authGuard != nil {
+					if ev := authGuard.Observe(af); ev != nil {
+						p.handleSecurity(ctx, ev)
+					}
+				}
+				continue
+			}
+			handleEntry(entry)
+
+		case entry, ok := <-authEntries:
+			if !ok {
+				authEntries = nil
+				continue
+			}
+			if f, ok := classifier.ParseSSHFailure(entry); ok {
+				if sshGuard != nil && p.sup.MatchEntry(entry) == "" {
+					if ev := sshGuard.Observe(f); ev != nil {
+						p.handleSecurity(ctx, ev)
+					}
+				}
 				continue
 			}
+			handleEntry(entry)
 
-			handleEvent(ctx, ev, enr, db, rep, cfg)
+		case entry, ok := <-kernelEntries:
+			if !ok {
+				kernelEntries = nil
+				continue
+			}
+			if classifier.IsKernelReportStart(strings.TrimSpace(entry.Message)) {
+				handleEntry(entry)
+			}
 
 		case psiEv, ok := <-psiEvents:
 			if !ok {
 				psiEvents = nil
 				continue
 			}
+			p.handlePressure(ctx, psiEv, string(event.TierMemPressure))
+
+		case psiEv, ok := <-cpuPSIEvents:
+			if !ok {
+				cpuPSIEvents = nil
+				continue
+			}
+			p.handlePressure(ctx, psiEv, p.cfg.PSI.CPU.Tier)
 
-			// Build T5 detail with top consumers.
-			detail := fmt.Sprintf("PSI some avg10=%.1f%% full avg10=%.1f%%",
-				psiEv.Stats.SomeAvg10, psiEv.Stats.FullAvg10)
-			if len(psiEv.TopConsumers) > 0 {
-				detail += "\n\nTop memory consumers:\n"
-				detail += monitor.FormatTopConsumers(psiEv.TopConsumers)
+		case psiEv, ok := <-ioPSIEvents:
+			if !ok {
+				ioPSIEvents = nil
+				continue
+			}
+			p.handlePressure(ctx, psiEv, p.cfg.PSI.IO.Tier)
+
+		case thrashEv, ok := <-thrashEvents:
+			if !ok {
+				thrashEvents = nil
+				continue
 			}
 
-			ev := cls.ClassifyPSIEvent(psiEv.Stats.SomeAvg10, psiEv.Stats.FullAvg10, detail)
-			handleEvent(ctx, ev, enr, db, rep, cfg)
+			if thrashEv.Kind == monitor.ThrashLowMemory {
+				summary := fmt.Sprintf("Low memory: %s available (%.1f%%), %.0f major faults/s",
+					format.Bytes(thrashEv.Mem.MemAvailable), thrashEv.Mem.AvailablePct(), thrashEv.MajFaultRate)
+				p.handle(ctx, cls.ClassifyLowMemoryEvent(thrashEv.Mem.AvailablePct(), summary, monitor.FormatThrash(thrashEv)))
+				continue
+			}
+
+			summary := fmt.Sprintf("Swap thrashing: %.0f major faults/s, %.0f pages/s swapped",
+				thrashEv.MajFaultRate, thrashEv.SwapInRate+thrashEv.SwapOutRate)
+			var process string
+			if len(thrashEv.Top) > 0 {
+				process = thrashEv.Top[0].Name
+				names := make([]string, 0, 3)
+				for _, p := range thrashEv.Top[:min(3, len(thrashEv.Top))] {
+					names = append(names, p.Name)
+				}
+				summary += " (" + strings.Join(names, ", ") + ")"
+			}
+			ev := cls.ClassifyThrashEvent(thrashEv.MajFaultRate, process, summary, monitor.FormatThrash(thrashEv))
+			p.handle(ctx, ev)
 
 		case smartEv, ok := <-smartEvents:
 			if !ok {
@@ -243,6 +1072,20 @@ func run(cfg *config.Config) error {
 			}
 
 			s := smartEv.Status
+			if smartEv.Reason == monitor.SMARTReasonTemperature {
+				summary := fmt.Sprintf("Drive too hot: %s %d°C (limit %d°C)", s.Device, s.Temperature, smartEv.TempLimit)
+				detail := fmt.Sprintf("Device: %s\nModel: %s\nType: %s\nTemperature: %d°C\nLimit: %d°C\nAbove limit since: %s",
+					s.Device, s.ModelName, s.Kind(), s.Temperature, smartEv.TempLimit,
+					smartEv.HotSince.Local().Format("Jan 02 15:04"))
+				p.handle(ctx, cls.ClassifySMARTTempEvent(s.Device, summary, detail))
+				continue
+			}
+
+			if smartEv.Recovered {
+				p.recovered(ctx, store.Recovery{Tier: event.TierKernelHW, DedupKey: classifier.SMARTDedupKey(s.Device), At: smartEv.Timestamp})
+				continue
+			}
+
 			summary := fmt.Sprintf("SMART: %s (%s)", s.Device, s.ModelName)
 			if !s.Healthy {
 				summary = fmt.Sprintf("SMART FAILING: %s (%s)", s.Device, s.ModelName)
@@ -264,7 +1107,36 @@ func run(cfg *config.Config) error {
 			}
 
 			ev := cls.ClassifySMARTEvent(s.Device, summary, detail.String())
-			handleEvent(ctx, ev, enr, db, rep, cfg)
+			p.handle(ctx, ev)
+
+		case arrayEv, ok := <-arrayEvents:
+			if !ok {
+				arrayEvents = nil
+				continue
+			}
+
+			s := arrayEv.Status
+			if s.Healthy() {
+				p.recovered(ctx, store.Recovery{Tier: event.TierKernelHW, Process: s.Name, At: arrayEv.Timestamp})
+				continue
+			}
+			ev := cls.ClassifyArrayEvent(s.Kind, s.Name, s.Degraded, s.Failed,
+				arraySummary(arrayEv), monitor.FormatArray(s))
+			p.handle(ctx, ev)
+
+		case traceEv, ok := <-traceEvents:
+			if !ok {
+				traceEvents = nil
+				continue
+			}
+
+			detail := monitor.FormatTrace(traceEv)
+			if traceEv.Kind == monitor.TraceOOM {
+				p.handle(ctx, cls.ClassifyTracedOOM(traceEv.Timestamp, traceEv.PID, traceEv.Comm, traceEv.CGroup, detail))
+			} else {
+				p.handle(ctx, cls.ClassifyTracedCrash(traceEv.Timestamp, traceEv.PID, traceEv.Comm,
+					monitor.SignalName(traceEv.Signal), traceEv.CGroup, detail))
+			}
 
 		case gpuEv, ok := <-gpuEvents:
 			if !ok {
@@ -273,64 +1145,773 @@ func run(cfg *config.Config) error {
 			}
 
 			s := gpuEv.Status
+			if gpuEv.Reason == "thermal_normal" {
+				p.recovered(ctx, store.Recovery{Tier: event.TierKernelHW, Process: s.ID(),
+					DedupKey: classifier.GPUDedupKey(s.ID(), "thermal_warning"), At: gpuEv.Timestamp})
+				continue
+			}
 			var summary, detail string
 			switch gpuEv.Reason {
 			case "thermal_warning":
-				summary = fmt.Sprintf("GPU thermal warning: %s %d°C", filepath.Base(s.CardPath), s.Temperature)
+				summary = fmt.Sprintf("GPU thermal warning: %s %d°C", s.Label(), s.Temperature)
 				detail = monitor.FormatGPUStatus(s)
 			case "vram_high":
 				pct := int(s.VRAMUsed * 100 / s.VRAMTotal)
-				summary = fmt.Sprintf("GPU VRAM high: %s %d%%", filepath.Base(s.CardPath), pct)
+				summary = fmt.Sprintf("GPU VRAM high: %s %d%%", s.Label(), pct)
+				s.Processes = nil // listed below
+				detail = monitor.FormatGPUStatus(s)
+				if len(gpuEv.TopConsumers) > 0 {
+					detail += "\nTop VRAM consumers:\n"
+					detail += monitor.FormatGPUConsumers(gpuEv.TopConsumers)
+				}
+			case "ecc_uncorrected":
+				summary = fmt.Sprintf("GPU uncorrectable ECC errors: %s (+%d, %d since driver load)", s.Label(), gpuEv.Growth, s.ECCUncorrected)
+				detail = monitor.FormatGPUStatus(s)
+			case "retired_pages":
+				summary = fmt.Sprintf("GPU memory pages retired: %s (+%d, %d total)", s.Label(), gpuEv.Growth, s.RetiredPages)
 				detail = monitor.FormatGPUStatus(s)
 			default:
-				summary = fmt.Sprintf("GPU event: %s (%s)", filepath.Base(s.CardPath), gpuEv.Reason)
+				summary = fmt.Sprintf("GPU event: %s (%s)", s.Label(), gpuEv.Reason)
 				detail = monitor.FormatGPUStatus(s)
 			}
 
-			ev := cls.ClassifyGPUEvent(filepath.Base(s.CardPath), string(s.Vendor), summary, detail)
-			handleEvent(ctx, ev, enr, db, rep, cfg)
+			ev := cls.ClassifyGPUEvent(s.ID(), s.Card(), string(s.Vendor), gpuEv.Reason, summary, detail)
+			p.handle(ctx, ev)
+
+		case keyEv, ok := <-keyEvents:
+			if !ok {
+				keyEvents = nil
+				continue
+			}
+
+			k := keyEv.Key
+			summary := fmt.Sprintf("SSH key added for %s: %s", keyEv.Account, cmp.Or(k.Comment, k.Fingerprint))
+			detail := fmt.Sprintf("A key was added to %s.\nType: %s\nFingerprint: %s", keyEv.Path, k.Type, k.Fingerprint)
+			if k.Comment != "" {
+				detail += "\nComment: " + k.Comment
+			}
+			if k.Options != "" {
+				detail += "\nOptions: " + k.Options
+			}
+			p.handleSecurity(ctx, cls.ClassifySSHKeyEvent(keyEv.Path, keyEv.Account, k.Fingerprint, summary, detail))
+
+		case quotaEv, ok := <-quotaEvents:
+			if !ok {
+				quotaEvents = nil
+				continue
+			}
+
+			u := quotaEv.Usage
+			subject := u.Kind + " " + u.Subject
+			summary := fmt.Sprintf("Quota %s: %s on %s (%s %.0f%%)",
+				quotaEv.Level, subject, u.Filesystem, quotaEv.Resource, quotaEv.Percent)
+
+			ev := cls.ClassifyQuotaEvent(subject, quotaEv.Level == monitor.QuotaExceeded,
+				summary, monitor.FormatQuotaUsage(u))
+			p.handle(ctx, ev)
+
+		case diskEv, ok := <-diskEvents:
+			if !ok {
+				diskEvents = nil
+				continue
+			}
+			if diskEv.Level == monitor.DiskOK {
+				p.recovered(ctx, store.Recovery{Tier: event.TierResource, Process: diskEv.Usage.Mount, At: diskEv.Timestamp})
+				continue
+			}
+
+			summary := fmt.Sprintf("Disk %s: %s %s %.0f%% used",
+				diskEv.Level, diskEv.Usage.Mount, diskEv.Resource, diskEv.Percent)
+			ev := cls.ClassifyDiskSpaceEvent(diskEv.Usage.Mount, diskEv.Level == monitor.DiskCritical,
+				summary, monitor.FormatDiskSpace(diskEv))
+			p.handle(ctx, ev)
+
+		case invEv, ok := <-inventoryEvents:
+			if !ok {
+				inventoryEvents = nil
+				continue
+			}
+			c := invEv.Change
+			detail := c.Detail
+			if !invEv.Previous.IsZero() {
+				detail += fmt.Sprintf("Last seen: %s\n", invEv.Previous.Local().Format("2006-01-02 15:04"))
+			}
+			p.handle(ctx, cls.ClassifyInventoryEvent(c.Kind, c.Subject, c.Summary, detail))
+
+		case limitEv, ok := <-unitLimitEvents:
+			if !ok {
+				unitLimitEvents = nil
+				continue
+			}
+			if limitEv.Level == monitor.DiskOK {
+				p.recovered(ctx, store.Recovery{Tier: event.TierResource, Unit: limitEv.Usage.Unit, At: limitEv.Timestamp})
+				continue
+			}
+
+			resource := "open files"
+			if limitEv.Resource == "tasks" {
+				resource = "tasks"
+			}
+			summary := fmt.Sprintf("Unit limit %s: %s %s %.0f%% of limit",
+				limitEv.Level, limitEv.Usage.Unit, resource, limitEv.Percent)
+			ev := cls.ClassifyUnitLimitEvent(limitEv.Usage.Unit, limitEv.Resource, limitEv.Level == monitor.DiskCritical,
+				summary, monitor.FormatUnitUsage(limitEv.Usage))
+			p.handle(ctx, ev)
+
+		case unitEv, ok := <-unitEvents:
+			if !ok {
+				unitEvents = nil
+				continue
+			}
+
+			st := unitEv.State
+			if unitEv.Recovered {
+				// Back up after failing: a new failure of any kind alerts anew.
+				p.recovered(ctx, store.Recovery{Unit: st.Unit, At: unitEv.Timestamp})
+				continue
+			}
+			summary := fmt.Sprintf("Service failed: %s", st.Unit)
+			if st.Result != "" {
+				summary = fmt.Sprintf("Service failed: %s (%s)", st.Unit, st.Result)
+			}
+			ev := cls.ClassifyUnitEvent(st.Unit, st.Result, st.NRestarts, summary, monitor.FormatUnitState(st))
+			p.handle(ctx, ev)
+
+		case ev := <-remoteEvents:
+			p.handleRemote(ctx, ev)
+
+		case ev := <-ackEvents:
+			if err := p.rep.ReportTransition(ctx, reporter.Transition{Kind: reporter.TransitionAcked, Count: 1}, ev); err != nil {
+				slog.Error("failed to report ack", "error", err)
+				selfstat.ReporterFailure()
+			}
 
 		case <-watchdogCh:
-			sdNotify("WATCHDOG=1")
+			// A wedged daemon must miss pings so systemd restarts it.
+			if st := checker.LastWithin(2 * checkInterval); st.Healthy {
+				sdNotify("WATCHDOG=1")
+			} else {
+				slog.Warn("pipeline unhealthy, withholding watchdog ping", "failing", st.Failing())
+			}
+
+		case <-heartbeatCh:
+			if st := checker.LastWithin(2 * checkInterval); st.Healthy {
+				go pingHeartbeat(ctx, heartbeat)
+			} else {
+				slog.Warn("pipeline unhealthy, withholding heartbeat", "failing", st.Failing())
+			}
+
+		case <-incidentTicker.C:
+			idle := time.Now().Add(-p.cooldown.MaxWindow())
+			if closed, err := db.CloseIdleIncidents(idle); err != nil {
+				slog.Error("failed to close idle incidents", "error", err)
+			} else if len(closed) > 0 {
+				slog.Debug("closed idle incidents", "count", len(closed))
+				p.publishIncidents(closed)
+			}
+			if p.storm != nil {
+				if ev := p.storm.Check(time.Now()); ev != nil {
+					p.handle(ctx, ev)
+				}
+			}
+			p.retryNotifications(ctx)
+			p.releaseHeld(ctx, time.Now())
+			if digests != nil {
+				digests.check(ctx, time.Now())
+			}
+			if backups != nil {
+				backups.check(ctx, time.Now())
+			}
+			saveRun(db, selfRun)
+
+		case ev := <-selfEvents:
+			p.handle(ctx, ev)
+
+		case <-hupCh:
+			_ = reload() // failures are logged
+
+		case <-configChanges:
+			slog.Info("config files changed, reloading")
+			_ = reload()
+
+		case fn := <-controlCalls:
+			fn()
 
 		case sig := <-sigCh:
 			slog.Info("received signal, shutting down", "signal", sig)
 			sdNotify("STOPPING=1")
 			cancel()
+
+			// Deliver notifications still held in a batching window.
+			flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := p.rep.Flush(flushCtx); err != nil {
+				slog.Error("flushing notifications", "error", err)
+			}
+			p.saveHeld(flushCtx)
+			flushCancel()
+
+			if selfRun != nil {
+				selfRun.Stopped = time.Now()
+			}
+			saveRun(db, selfRun)
 			return nil
 		}
 	}
 }
 
-// handleEvent runs an event through the enrichment, storage, dedup, and notification pipeline.
-func handleEvent(ctx context.Context, ev *event.Event, enr *enricher.Enricher, db *store.DB, rep *reporter.NtfyReporter, cfg *config.Config) {
+// pipeline holds the stages every event passes through after classification.
+type pipeline struct {
+	cfg *config.Config
+	cls *classifier.Classifier
+	enr *enricher.Enricher
+	db  *store.DB
+	rep *reporter.Multi
+	fwd *reporter.ForwardReporter // nil unless forwarding to a hub
+	sup *suppress.Matcher
+
+	cooldown *cooldown.Policy
+	remedy   *remediate.Remediator // nil unless remediation.enabled
+	hooks    *hook.Runner          // nil unless [[hooks]] are configured
+
+	capture *capture.Manager         // nil unless capture.enabled
+	bundle  *bundle.Writer           // nil unless bundle.enabled
+	web     *web.Server              // nil unless web.listen is set
+	syslog  *reporter.SyslogReporter // nil unless syslog.enabled
+	otel    *reporter.OTelExporter   // nil unless otel.enabled
+	loki    *reporter.LokiReporter   // nil unless loki.enabled
+
+	held       []heldNotification     // alerts held for quiet hours
+	escalation *reporter.NtfyReporter // nil unless escalation.ntfy_url is set
+	storm      *classifier.StormGuard // nil unless storm.enabled
+	selfmon    *selfmon.Monitor       // nil unless self_monitor.enabled
+	quiet      bool                   // store without notifying (replay without --notify)
+	stats      pipelineStats
+}
+
+// handleSecurity runs a T9 event through the pipeline with the severity
+// security.severity or security.roles give its kind. Root logins, sudo
+// failures, and added keys are dropped unless [security] is enabled.
+func (p *pipeline) handleSecurity(ctx context.Context, ev *event.Event) {
+	kind := ev.RawFields["_security"]
+	switch kind {
+	case "root_login", "sudo_failure", "ssh_key":
+		if !p.cfg.Security.Enabled {
+			return
+		}
+	}
+	if sev := p.cfg.Security.SeverityFor(kind, p.cfg.Instance.Role); sev != "" {
+		ev.Severity = event.Severity(sev)
+	}
+	p.handle(ctx, ev)
+}
+
+// handlePressure classifies the start or end of a pressure episode into
+// tier. The start has the top consumers of the resource in its detail; the
+// end has the episode's duration and peak, and closes its incident.
+func (p *pipeline) handlePressure(ctx context.Context, psiEv monitor.PSIEvent, tier string) {
+	st := psiEv.Stats
+	if psiEv.Phase == monitor.PSIResolved {
+		peak := psiEv.Peak
+		detail := fmt.Sprintf("Duration: %s\nPeak: some avg10=%.1f%% avg60=%.1f%% full avg10=%.1f%% avg60=%.1f%%\nNow: some avg10=%.1f%% full avg10=%.1f%%",
+			format.Duration(psiEv.Duration()), peak.SomeAvg10, peak.SomeAvg60, peak.FullAvg10, peak.FullAvg60, st.SomeAvg10, st.FullAvg10)
+		ev := p.cls.ClassifyPressureResolved(psiEv.Resource, event.Tier(tier), psiEv.Duration(), peak.SomeAvg10, peak.FullAvg10, detail)
+		p.handle(ctx, ev)
+		p.recovered(ctx, store.Recovery{Tier: ev.Tier, Process: ev.Process, At: psiEv.Timestamp})
+		return
+	}
+
+	detail := fmt.Sprintf("PSI %s some avg10=%.1f%% avg60=%.1f%% full avg10=%.1f%% avg60=%.1f%%", psiEv.Resource,
+		st.SomeAvg10, st.SomeAvg60, st.FullAvg10, st.FullAvg60)
+	if len(psiEv.TopConsumers) > 0 {
+		detail += "\n\nTop memory consumers:\n"
+		detail += monitor.FormatTopConsumers(psiEv.TopConsumers)
+	}
+	if len(psiEv.TopIO) > 0 {
+		detail += "\n\nTop IO consumers:\n"
+		detail += monitor.FormatTopIO(psiEv.TopIO)
+	}
+	if len(psiEv.TopCPU) > 0 {
+		detail += "\n\nTop CPU consumers:\n"
+		detail += monitor.FormatTopCPU(psiEv.TopCPU)
+	}
+	p.handle(ctx, p.cls.ClassifyPressureEvent(psiEv.Resource, event.Tier(tier), st.SomeAvg10, st.FullAvg10, detail))
+}
+
+// newRemediator compiles the remediation actions, or returns nil if
+// remediation is disabled. As the pipeline waits for an action, under a
+// short systemd watchdog its timeout is cut to a quarter of the interval,
+// leaving the pings, due every half, time to go out.
+func newRemediator(cfg *config.Config) (*remediate.Remediator, error) {
+	if !cfg.Remediation.Enabled {
+		return nil, nil
+	}
+	rc := cfg.Remediation
+	if wd := watchdogInterval(); wd > 0 {
+		rc.Timeout.Duration = min(rc.Timeout.Duration, wd/4)
+	}
+	return remediate.New(rc)
+}
+
+// newHooks compiles the [[hooks]], or returns nil if there are none.
+func newHooks(cfg *config.Config) (*hook.Runner, error) {
+	if len(cfg.Hooks) == 0 {
+		return nil, nil
+	}
+	return hook.New(cfg.Hooks)
+}
+
+// handle runs a locally classified event through the enrichment, storage,
+// forwarding, dedup, and notification pipeline.
+func (p *pipeline) handle(ctx context.Context, ev *event.Event) {
+	// In an event storm, events are only counted, sparing the struggling
+	// box the enrichment subprocesses and writes for each.
+	if p.storm != nil {
+		storm, collapsed := p.storm.Observe(ev, time.Now())
+		if storm != nil {
+			p.handle(ctx, storm)
+		}
+		if collapsed {
+			slog.Debug("event collapsed into storm", "tier", ev.Tier, "summary", ev.Summary)
+			p.stats.Collapsed++
+			return
+		}
+	}
+	p.stats.event(ev.Tier)
+
 	slog.Info("event classified",
 		"tier", ev.Tier,
 		"severity", ev.Severity,
 		"summary", ev.Summary,
 	)
 
-	enr.Enrich(ctx, ev)
+	// Mark suppressed events before storing so query shows why they were
+	// quiet. Rules are checked before enrichment so a muted event skips its
+	// subprocesses, and again after it in case a rule matches the unit it
+	// filled in.
+	muted := p.suppressed(ev)
+	if !muted {
+		p.enr.Enrich(ctx, ev)
+	}
+	ev.Fingerprint = event.ComputeFingerprint(ev)
+	muted = muted || p.suppressed(ev) || p.knownCrash(ev)
+
+	// A critical OOM kill or hardware fault gets a diagnostic bundle. It is
+	// written in the background; its path goes out with the notification.
+	if p.bundle != nil && !muted {
+		if path, ok := p.bundle.Start(ctx, ev); ok {
+			ev.RawFields["_bundle"] = path
+			if ev.Detail != "" {
+				ev.Detail = strings.TrimRight(ev.Detail, "\n") + "\n\n"
+			}
+			ev.Detail += "Diagnostic bundle: " + path
+		}
+	}
+
+	// An opt-in remediation action runs before the event is stored, so its
+	// outcome goes out with the notification.
+	if p.remedy != nil && !muted && !p.quiet {
+		if res, ok := p.remedy.Run(ctx, ev); ok {
+			ev.RawFields["_remediation"] = res.Summary()
+			if ev.Detail != "" {
+				ev.Detail = strings.TrimRight(ev.Detail, "\n") + "\n\n"
+			}
+			ev.Detail += "Auto-remediation: " + strings.Join(res.Command, " ") + ": " + res.Summary()
+		}
+	}
 
-	// Store event in database.
-	if err := db.Insert(ev); err != nil {
+	// Store event in database. It is grouped first so it is written with
+	// its incident; the write itself may be queued (see db.write_queue).
+	// Muted events open no incident, so silenced noise does not show up
+	// in status --short or the login banner.
+	opened := !muted && p.group(ev)
+	if err := p.db.InsertAsync(ev); err != nil {
 		slog.Error("failed to store event", "error", err)
+	} else {
+		p.publish(ev)
+	}
+
+	// Failures of a looping unit are alerted once, as the loop.
+	looping := p.restartLoop(ctx, ev)
+
+	// A critical incident opening raises capture scope for a while; its
+	// later events are covered by that capture.
+	if p.capture != nil && opened {
+		p.capture.Trigger(ctx, ev)
+	}
+
+	p.export(ctx, ev)
+	if !muted && !p.quiet {
+		p.fire(ctx, ev)
+	}
+
+	// Forward every event to the hub; it applies its own cooldown.
+	if p.fwd != nil {
+		if err := p.fwd.Report(ctx, ev); err != nil {
+			slog.Error("failed to forward event to hub", "error", err)
+			selfstat.ReporterFailure()
+		}
+		if !p.cfg.Agent.NotifyLocal {
+			p.decide(ev, &store.Decision{
+				Outcome: store.DecisionForwarded,
+				Reason:  "forwarded to the hub, which applies its own cooldown and notifies; agent.notify_local is off",
+			})
+			return
+		}
+	}
+
+	switch {
+	case muted:
+		p.decide(ev, mutedDecision(ev))
+		return
+	case looping:
+		p.decide(ev, &store.Decision{
+			Outcome: store.DecisionLooping,
+			Reason:  fmt.Sprintf("%s is in a restart loop; its failures are alerted once, as the loop", ev.Unit),
+		})
+		return
+	case p.quiet:
+		p.decide(ev, &store.Decision{
+			Outcome: store.DecisionReplayed,
+			Reason:  "back-filled by logtriage replay, which notifies only with --notify",
+		})
+		return
+	}
+	p.notify(ctx, ev)
+}
+
+// keep stores and forwards an unclassified event. Such events only feed the
+// digest's coverage report, so they skip enrichment, incident grouping, and
+// notification.
+func (p *pipeline) keep(ctx context.Context, ev *event.Event) {
+	slog.Debug("unclassified entry stored", "summary", ev.Summary)
+	p.stats.Unclassified++
+	ev.Fingerprint = event.ComputeFingerprint(ev)
+
+	if err := p.db.InsertAsync(ev); err != nil {
+		slog.Error("failed to store event", "error", err)
+	} else {
+		p.publish(ev)
+	}
+	p.export(ctx, ev)
+	if p.fwd != nil {
+		if err := p.fwd.Report(ctx, ev); err != nil {
+			slog.Error("failed to forward event to hub", "error", err)
+			selfstat.ReporterFailure()
+		}
+	}
+}
+
+// restartLoop reports whether ev is a failure of a unit in a restart loop,
+// handling a restart-loop event the first time the loop is seen.
+func (p *pipeline) restartLoop(ctx context.Context, ev *event.Event) bool {
+	lc := p.cfg.Loop
+	if !lc.Enabled || ev.Tier != event.TierServiceFailure || ev.Unit == "" || ev.RawFields["_restart_loop"] != "" {
+		return false
+	}
+	recent, err := p.db.Query(store.QueryFilter{
+		Since:      ev.Timestamp.Add(-lc.Window.Duration),
+		Tier:       string(event.TierServiceFailure),
+		InstanceID: ev.InstanceID,
+		Unit:       ev.Unit,
+	})
+	if err != nil {
+		slog.Error("restart loop check failed", "error", err)
+		return false
+	}
+	loop, looping := p.cls.ClassifyRestartLoop(ev.Unit, recent, lc.Failures, lc.Window.Duration)
+	if loop != nil {
+		p.handle(ctx, loop)
+	}
+	return looping
+}
+
+// handleRemote stores and notifies for an event forwarded by an agent.
+// Agents enrich before forwarding, so enrichment is skipped here.
+func (p *pipeline) handleRemote(ctx context.Context, ev *event.Event) {
+	slog.Info("event received from agent",
+		"instance", ev.InstanceID,
+		"tier", ev.Tier,
+		"summary", ev.Summary,
+	)
+
+	// The hub groups incidents itself, across every agent.
+	ev.IncidentID = ""
+	if ev.Fingerprint == "" {
+		ev.Fingerprint = event.ComputeFingerprint(ev) // from an older agent
+	}
+
+	if ev.Tier == event.TierUnclassified {
+		if err := p.db.Insert(ev); err == nil {
+			p.publish(ev)
+			p.export(ctx, ev)
+		} else if !errors.Is(err, store.ErrDuplicate) {
+			slog.Error("failed to store event", "error", err)
+		}
+		return
+	}
+
+	muted := p.suppressed(ev)
+
+	if err := p.db.Insert(ev); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			slog.Debug("duplicate event from agent ignored", "id", ev.ID)
+			return
+		}
+		slog.Error("failed to store event", "error", err)
+	} else {
+		if !muted {
+			p.group(ev)
+		}
+		p.publish(ev)
+		p.export(ctx, ev)
+	}
+
+	if muted {
+		p.decide(ev, mutedDecision(ev))
+		return
+	}
+	p.fire(ctx, ev)
+	p.notify(ctx, ev)
+}
+
+// publish sends a stored event to the dashboard's live tails.
+func (p *pipeline) publish(ev *event.Event) {
+	if p.web != nil {
+		p.web.Publish(ev)
+	}
+}
+
+// export re-emits an event to syslog and queues it for the OpenTelemetry
+// collector and Loki, for whichever export is enabled.
+func (p *pipeline) export(ctx context.Context, ev *event.Event) {
+	if p.otel != nil {
+		p.otel.Report(ctx, ev)
+	}
+	if p.loki != nil {
+		if err := p.loki.Report(ctx, ev); err != nil {
+			slog.Error("failed to export event to Loki", "error", err)
+		}
+	}
+	if p.syslog == nil {
+		return
+	}
+	if err := p.syslog.Report(ctx, ev); err != nil {
+		slog.Error("failed to export event to syslog", "error", err)
+		selfstat.ReporterFailure()
+	}
+}
+
+// fire starts the [[hooks]] matching ev.
+func (p *pipeline) fire(ctx context.Context, ev *event.Event) {
+	if p.hooks != nil {
+		p.hooks.Fire(ctx, ev)
+	}
+}
+
+// group assigns a stored event to an incident, and reports whether it
+// opened one. Events of the same kind arriving within the cooldown window
+// of each other share an incident.
+func (p *pipeline) group(ev *event.Event) bool {
+	inc, opened, err := p.db.GroupEvent(ev, p.cooldown.For(ev).Window)
+	if err != nil {
+		slog.Error("failed to group event into incident", "error", err)
+		return false
+	}
+	if opened {
+		slog.Debug("incident opened", "incident", inc.ID, "title", inc.Title)
+	}
+	p.publishIncidents([]string{inc.ID})
+	return opened
+}
+
+// publishIncidents sends the current state of changed incidents to the
+// dashboard's incident streams, and those that closed to the
+// OpenTelemetry collector as spans.
+func (p *pipeline) publishIncidents(ids []string) {
+	if p.web == nil && p.otel == nil {
+		return
+	}
+	for _, id := range ids {
+		inc, err := p.db.GetIncident(id)
+		if err != nil || inc == nil {
+			slog.Error("failed to load incident", "incident", id, "error", err)
+			continue
+		}
+		if p.web != nil {
+			p.web.PublishIncident(inc)
+		}
+		if p.otel != nil {
+			p.otel.ReportIncident(inc)
+		}
+	}
+}
+
+// suppressed reports whether an alert-stage suppression rule matches the
+// event, recording the rule name in its raw fields.
+func (p *pipeline) suppressed(ev *event.Event) bool {
+	name := p.sup.MatchEvent(ev)
+	if name == "" {
+		return false
+	}
+	ev.RawFields["_suppressed"] = name
+	slog.Debug("notification suppressed by rule", "rule", name, "summary", ev.Summary)
+	return true
+}
+
+// knownCrash reports whether ev is a repeat crash of a known_crashy process,
+// marking it suppressed. The first crash with a new signature is let through
+// with a hint to report it upstream.
+func (p *pipeline) knownCrash(ev *event.Event) bool {
+	if ev.Tier != event.TierProcessCrash || !p.cfg.Crashes.IsKnownCrashy(ev.Process) {
+		return false
+	}
+	earlier, seen, err := p.db.CrashHistory(ev)
+	if err != nil {
+		slog.Error("crash history check failed", "error", err)
+		return false
+	}
+
+	sig := ev.RawFields["_crash_signature"]
+	if seen || sig == "" {
+		ev.RawFields["_suppressed"] = "known_crashy"
+		slog.Debug("known crashy process, notification suppressed", "process", ev.Process, "crashes", earlier+1)
+		return true
+	}
+
+	ev.RawFields["_new_crash_signature"] = "true"
+	ev.Summary += " (new crash signature)"
+	ev.Detail = strings.TrimRight(ev.Detail, "\n") + fmt.Sprintf("\n\n"+
+		"%s is marked known-crashy (%d earlier crashes recorded), but crash signature %s "+
+		"is new. Consider filing an upstream bug with the backtrace above; "+
+		"`coredumpctl info %d` prints the full report.",
+		ev.Process, earlier, sig, ev.PID)
+	return false
+}
+
+// recovered records that a unit or process has recovered, ending its
+// current cooldown and incident.
+func (p *pipeline) recovered(ctx context.Context, r store.Recovery) {
+	r.InstanceID = p.cfg.Instance.ID
+	closed, err := p.db.RecordRecovery(r)
+	if err != nil {
+		slog.Error("failed to record recovery", "error", err)
+		return
+	}
+	slog.Info("recovered, cooldown reset", "tier", r.Tier, "unit", r.Unit, "process", r.Process, "dedup_key", r.DedupKey)
+	p.publishIncidents(closed)
+	p.notifyResolved(ctx, closed, r.At)
+}
+
+// notifyResolved sends a resolution notice for each of the closed
+// incidents that was alerted, if notify.resolved is set. Incidents that
+// never alerted close quietly.
+func (p *pipeline) notifyResolved(ctx context.Context, ids []string, at time.Time) {
+	if !p.cfg.Notify.Resolved || p.quiet {
+		return
+	}
+	for _, id := range ids {
+		inc, err := p.db.GetIncident(id)
+		if err != nil || inc == nil {
+			slog.Error("failed to load resolved incident", "incident", id, "error", err)
+			continue
+		}
+		alert, err := p.db.FirstNotified(id)
+		if err != nil {
+			slog.Error("failed to load resolved incident's alert", "incident", id, "error", err)
+			continue
+		}
+		if alert == nil {
+			continue
+		}
+
+		ev := reporter.NewResolution(inc, alert, at)
+		t := reporter.Transition{Kind: reporter.TransitionResolved, Count: inc.EventCount}
+		if _, held := p.hold(t, ev); held {
+			continue
+		}
+		// The notice is not stored, so a failed delivery is not retried.
+		if err := p.rep.ReportTransition(ctx, t, ev); err != nil {
+			slog.Error("failed to send resolution notification", "error", err)
+			selfstat.ReporterFailure()
+		}
+	}
+}
+
+// notify applies cooldown and sends the event to the notification sinks.
+func (p *pipeline) notify(ctx context.Context, ev *event.Event) {
+	if s, err := p.db.SnoozedBy(ev, time.Now()); err != nil {
+		slog.Error("snooze check failed", "error", err)
+	} else if s != nil {
+		slog.Debug("notification snoozed", "tier", ev.Tier, "unit", ev.Unit, "until", s.Until)
+		p.decide(ev, &store.Decision{
+			Outcome: store.DecisionSnoozed,
+			Reason:  fmt.Sprintf("snoozed until %s (snooze %d)", s.Until.Local().Format("2006-01-02 15:04"), s.ID),
+		})
+		return
+	}
+	if a, err := p.db.AckedBy(ev, time.Now()); err != nil {
+		slog.Error("ack check failed", "error", err)
+	} else if a != nil {
+		slog.Debug("notification quieted by ack", "tier", ev.Tier, "key", a.Key, "until", a.Until)
+		p.decide(ev, &store.Decision{
+			Outcome: store.DecisionAcked,
+			Reason: fmt.Sprintf("acknowledged by %s at %s; repeats are quiet until %s",
+				a.AckedBy, a.AckedAt.Local().Format("2006-01-02 15:04"), a.Until.Local().Format("2006-01-02 15:04")),
+			Key: a.Key,
+		})
+		return
 	}
 
 	// Check cooldown before notifying.
-	dedup, err := db.CheckCooldown(ev, cfg.Cooldown.Window.Duration, cfg.Cooldown.AggregateThreshold)
+	cd := p.cooldown.For(ev)
+	dedup, err := p.db.CheckCooldown(ev, cd.Window, cd.Threshold)
+	dec := cooldownDecision(dedup, cd)
 	if err != nil {
 		slog.Error("cooldown check failed", "error", err)
+		dec.Error = "cooldown check failed: " + err.Error()
 	}
-
-	if dedup.ShouldAlert {
-		if dedup.Aggregated {
-			ev.Summary = fmt.Sprintf("[x%d] %s", dedup.RecentCount, ev.Summary)
+	// Each new crash signature of a known-crashy process is alerted once,
+	// even among its suppressed repeats.
+	if ev.RawFields["_new_crash_signature"] != "" && !dedup.ShouldAlert {
+		dedup.ShouldAlert = true
+		dec.Outcome = store.DecisionAlerted
+		dec.Reason = "first crash with a new signature of a known-crashy process"
+	}
+	// A problem that keeps firing past its aggregate alert escalates.
+	var escalated int
+	if !dedup.ShouldAlert {
+		escalated = p.escalate(ev, dec)
+	}
+	defer p.decide(ev, dec)
+
+	if dedup.ShouldAlert || escalated > 0 {
+		// Count includes this event along with its predecessors in the window.
+		t := reporter.Transition{Kind: reporter.TransitionCreated, Count: dedup.RecentCount + 1}
+		switch {
+		case escalated > 0:
+			t = reporter.Transition{Kind: reporter.TransitionEscalated, Count: escalated}
+		case dedup.Aggregated:
+			t.Kind = reporter.TransitionAggregated
+			ev.Summary = fmt.Sprintf("[x%d] %s", t.Count, ev.Summary)
+		case dedup.Escalated:
+			t.Kind = reporter.TransitionEscalated
+		}
+		p.noteHistory(ev)
+		if until, held := p.hold(t, ev); held {
+			dec.Reason += fmt.Sprintf("; held for quiet hours until %s", until.Local().Format("2006-01-02 15:04"))
+			dec.Outcome = store.DecisionHeld
+			return
 		}
-		if err := rep.Report(ctx, ev); err != nil {
+		if err := p.rep.ReportTransition(ctx, t, ev); err != nil {
 			slog.Error("failed to send notification", "error", err)
+			selfstat.ReporterFailure()
+			p.queueRetry(reporter.FailedSinks(err), t, ev, err)
+			dec.Error = err.Error()
 		} else {
-			_ = db.MarkNotified(ev.ID)
+			_ = p.db.MarkNotified(ev.ID)
+		}
+		if escalated > 0 {
+			p.reportEscalation(ctx, ev)
 		}
 	} else {
 		slog.Debug("notification suppressed by cooldown",
@@ -340,15 +1921,89 @@ func handleEvent(ctx context.Context, ev *event.Event, enr *enricher.Enricher, d
 	}
 }
 
+// noteHistory appends to an alert's detail how often its problem was seen
+// in the last week and month, e.g. "3rd OOM kill of firefox this week; last
+// one 2d 4h ago". A problem is the tier and process when the event names a
+// process, otherwise the fingerprint.
+func (p *pipeline) noteHistory(ev *event.Event) {
+	history := p.db.FingerprintHistory
+	if ev.Process != "" {
+		history = p.db.ProcessHistory
+	}
+	week, err := history(ev, ev.Timestamp.Add(-7*24*time.Hour))
+	if err != nil {
+		slog.Error("event history check failed", "error", err)
+		return
+	}
+	month, err := history(ev, ev.Timestamp.Add(-30*24*time.Hour))
+	if err != nil {
+		slog.Error("event history check failed", "error", err)
+		return
+	}
+	line := reporter.FormatHistory(ev, week, month)
+	if line == "" {
+		return
+	}
+	if ev.Detail != "" {
+		ev.Detail = strings.TrimRight(ev.Detail, "\n") + "\n\n"
+	}
+	ev.Detail += line + "."
+}
+
+// newReporter builds the set of notification sinks enabled in the config.
+// ntfy is always included; it skips delivery when no URL is configured.
+// Each batching-capable sink gets its own window when notify.batch_window is
+// set; the lifecycle webhook is never batched so transitions stay distinct.
+func newReporter(cfg *config.Config) *reporter.Multi {
+	sinks := []reporter.BatchReporter{reporter.NewNtfy(cfg)}
+	if cfg.Slack.WebhookURL != "" {
+		sinks = append(sinks, reporter.NewSlack(cfg))
+		slog.Info("slack reporter enabled")
+	}
+	if cfg.Email.Host != "" && len(cfg.Email.To) > 0 {
+		sinks = append(sinks, reporter.NewSMTP(cfg))
+		slog.Info("email reporter enabled", "host", cfg.Email.Host)
+	}
+
+	window := cfg.Notify.BatchWindow.Duration
+	reporters := make([]reporter.Reporter, 0, len(sinks)+1)
+	for _, s := range sinks {
+		if window > 0 {
+			reporters = append(reporters, reporter.NewBatcher(s, window))
+		} else {
+			reporters = append(reporters, s)
+		}
+	}
+
+	if cfg.Webhook.URL != "" {
+		reporters = append(reporters, reporter.NewWebhook(cfg))
+		slog.Info("webhook reporter enabled")
+	}
+	return reporter.NewMulti(reporters...)
+}
+
 // --- digest subcommand ---
 
 func runDigest(args []string) {
 	fs := flag.NewFlagSet("digest", flag.ExitOnError)
 	configPath := fs.String("config", "", "path to config file")
-	send := fs.Bool("send", false, "send digest via ntfy (otherwise print to stdout)")
+	send := fs.Bool("send", false, "send digest (otherwise print to stdout)")
+	via := fs.String("via", "ntfy", "delivery channel for --send: ntfy or email")
 	last := fs.String("last", "7d", "time window for digest")
+	formatFlag := fs.String("format", "text", "output format when printing: text, json, or csv")
+	allInstances := fs.Bool("all-instances", false, "cover every instance in the store, with a section per host (for a hub)")
 	fs.Parse(args)
 
+	if *via != "ntfy" && *via != "email" {
+		fmt.Fprintf(os.Stderr, "invalid --via value %q: must be ntfy or email\n", *via)
+		os.Exit(1)
+	}
+	out := parseFormatFlag(*formatFlag)
+	if *send && out != format.OutputText {
+		fmt.Fprintln(os.Stderr, "error: --format cannot be combined with --send")
+		os.Exit(1)
+	}
+
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
@@ -357,7 +2012,7 @@ func runDigest(args []string) {
 
 	setupLogging("error")
 
-	db, err := store.Open(cfg.DBPath())
+	db, err := openDB(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
 		os.Exit(1)
@@ -373,36 +2028,40 @@ func runDigest(args []string) {
 	until := time.Now()
 	since := until.Add(-duration)
 
-	events, err := db.Query(store.QueryFilter{Since: since, Until: until})
+	warn := func(err error) { fmt.Fprintf(os.Stderr, "warning: %v\n", err) }
+	digest, err := collectDigest(cfg, db, since, until, *allInstances, warn)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	digest := reporter.BuildDigest(cfg.Instance.ID, events, since, until)
-	body := reporter.FormatDigest(digest)
-
-	if !*send {
-		fmt.Print(body)
+	switch out {
+	case format.OutputJSON:
+		exitOnWriteError(format.WriteJSON(os.Stdout, digest))
+		return
+	case format.OutputCSV:
+		exitOnWriteError(format.WriteCSV(os.Stdout, reporter.DigestCSVHeader, reporter.DigestRecords(digest)))
 		return
 	}
 
-	// Send via ntfy.
-	topic := cfg.DigestTopic()
-	if topic == "" {
-		fmt.Fprintln(os.Stderr, "error: no ntfy URL configured for digest")
-		os.Exit(1)
+	if !*send {
+		fmt.Print(reporter.FormatDigest(digest))
+		return
 	}
 
-	title := reporter.FormatDigestTitle(since, until)
-	if err := sendDigestNtfy(topic, title, body); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := sendDigest(ctx, cfg, *via, digest); err != nil {
 		fmt.Fprintf(os.Stderr, "error sending digest: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Println("Digest sent successfully.")
+	if err := reporter.NewHeartbeat(cfg).PingDigest(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
 }
 
-func sendDigestNtfy(url, title, body string) error {
+func sendDigestNtfy(cfg config.NtfyConfig, url, title, body string) error {
 	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
 	if err != nil {
 		return err
@@ -410,6 +2069,7 @@ func sendDigestNtfy(url, title, body string) error {
 	req.Header.Set("Title", title)
 	req.Header.Set("Priority", "low")
 	req.Header.Set("Tags", "chart")
+	reporter.SetNtfyOptions(req, cfg)
 
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
@@ -429,8 +2089,16 @@ func sendDigestNtfy(url, title, body string) error {
 func runStatus(args []string) {
 	fs := flag.NewFlagSet("status", flag.ExitOnError)
 	configPath := fs.String("config", "", "path to config file")
+	short := fs.Bool("short", false, "print a one-line summary and exit 0 (ok), 1 (degraded), 2 (critical), 3 (unknown)")
+	window := fs.String("window", "1h", "with --short, how far back to count events")
+	formatFlag := fs.String("format", "text", "output format: text, json, or csv")
 	fs.Parse(args)
 
+	if *short {
+		os.Exit(runStatusShort(*configPath, *window))
+	}
+	out := parseFormatFlag(*formatFlag)
+
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
@@ -439,81 +2107,214 @@ func runStatus(args []string) {
 
 	setupLogging("error")
 
-	fmt.Printf("Instance:     %s\n", cfg.Instance.ID)
-	fmt.Printf("Role:         %s\n", cfg.Instance.Role)
-
-	db, err := store.Open(cfg.DBPath())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+	// Prefer the running daemon, which also knows its own state, over
+	// opening the database.
+	st, err := daemonStatus(cfg)
+	if errors.Is(err, control.ErrNotRunning) {
+		db, err := openDB(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		st = buildStatus(cfg, db)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Last event.
-	lastEvents, err := db.Query(store.QueryFilter{Limit: 1})
-	if err == nil && len(lastEvents) > 0 {
-		ev := lastEvents[0]
-		ago := time.Since(ev.Timestamp).Truncate(time.Second)
-		fmt.Printf("Last event:   [%s] %s — %s ago\n", ev.Tier, ev.Summary, formatDuration(ago))
-	} else {
-		fmt.Println("Last event:   none")
+	switch out {
+	case format.OutputJSON:
+		exitOnWriteError(format.WriteJSON(os.Stdout, st))
+	case format.OutputCSV:
+		exitOnWriteError(format.WriteCSV(os.Stdout, []string{"key", "value"}, st.records()))
+	default:
+		st.print()
 	}
+}
 
-	// Event counts for last 24h.
-	since24h := time.Now().Add(-24 * time.Hour)
-	events24h, _ := db.Query(store.QueryFilter{Since: since24h})
+// daemonStatus asks the running daemon for its status over the control
+// socket. It returns control.ErrNotRunning when no daemon answers or the
+// socket is disabled.
+func daemonStatus(cfg *config.Config) (*statusReport, error) {
+	if !cfg.Control.Enabled {
+		return nil, control.ErrNotRunning
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var st statusReport
+	if err := control.Get(ctx, cfg.ControlSocket(), "status", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
 
-	var oom, crash, svcFail, kernHW, memPres int
-	for _, ev := range events24h {
-		switch ev.Tier {
-		case event.TierOOMKill:
-			oom++
-		case event.TierProcessCrash:
-			crash++
-		case event.TierServiceFailure:
-			svcFail++
-		case event.TierKernelHW:
-			kernHW++
-		case event.TierMemPressure:
-			memPres++
-		}
+// statusReport is a snapshot of the daemon's recent activity and the host's
+// state, as the status subcommand reports it. Its JSON form is the output
+// of "status --format=json".
+type statusReport struct {
+	Instance  string             `json:"instance"`
+	Role      string             `json:"role,omitempty"`
+	LastEvent *event.Event       `json:"last_event,omitempty"`
+	Events24h map[event.Tier]int `json:"events_24h"` // tier -> count
+	PSIMemory *statusPSI         `json:"psi_memory,omitempty"`
+	GPUs      []statusGPU        `json:"gpus,omitempty"`
+	DBEvents  int64              `json:"db_events"`
+	DBPath    string             `json:"db_path"`
+
+	QueuedNotifications int `json:"queued_notifications"` // awaiting retry
+
+	Daemon *statusDaemon `json:"daemon,omitempty"` // set when read from the running daemon
+}
+
+type statusPSI struct {
+	SomeAvg10 float64 `json:"some_avg10"`
+	FullAvg10 float64 `json:"full_avg10"`
+	Warning   bool    `json:"warning"`
+}
+
+type statusGPU struct {
+	Card        string `json:"card"`
+	Vendor      string `json:"vendor"`
+	Temperature int    `json:"temperature,omitempty"` // degrees C
+	VRAMUsedPct int64  `json:"vram_used_pct,omitempty"`
+}
+
+func buildStatus(cfg *config.Config, db *store.DB) *statusReport {
+	st := &statusReport{
+		Instance:  cfg.Instance.ID,
+		Role:      cfg.Instance.Role,
+		Events24h: make(map[event.Tier]int),
+		DBPath:    db.Location(),
 	}
-	fmt.Printf("Events (24h): %d OOM, %d crash, %d service, %d hw, %d pressure\n",
-		oom, crash, svcFail, kernHW, memPres)
 
-	// PSI snapshot.
-	stats, err := monitor.ReadPSI("/proc/pressure/memory")
-	if err == nil {
-		status := "healthy"
-		if stats.SomeAvg10 > cfg.PSI.WarnSomeAvg10 || stats.FullAvg10 > cfg.PSI.WarnFullAvg10 {
-			status = "WARNING"
+	if lastEvents, err := db.Query(store.QueryFilter{Limit: 1}); err == nil && len(lastEvents) > 0 {
+		st.LastEvent = lastEvents[0]
+	}
+
+	if counts, err := db.CountByTier(store.QueryFilter{Since: time.Now().Add(-24 * time.Hour)}); err == nil {
+		st.Events24h = counts
+	}
+
+	if stats, err := monitor.ReadPSI("/proc/pressure/memory"); err == nil {
+		st.PSIMemory = &statusPSI{
+			SomeAvg10: stats.SomeAvg10,
+			FullAvg10: stats.FullAvg10,
+			Warning:   stats.SomeAvg10 > cfg.PSI.WarnSomeAvg10 || stats.FullAvg10 > cfg.PSI.WarnFullAvg10,
 		}
-		fmt.Printf("PSI memory:   some=%.1f%% full=%.1f%% (%s)\n",
-			stats.SomeAvg10, stats.FullAvg10, status)
 	}
 
-	// GPU snapshot.
 	gpus := monitor.DetectGPUs()
 	for i := range gpus {
 		gpu := &gpus[i]
 		monitor.ReadGPUTemp(gpu)
 		monitor.ReadGPUVRAM(gpu)
 
-		info := fmt.Sprintf("%s (%s)", filepath.Base(gpu.CardPath), gpu.Vendor)
+		g := statusGPU{Card: filepath.Base(gpu.CardPath), Vendor: string(gpu.Vendor), Temperature: gpu.Temperature}
+		if gpu.VRAMTotal > 0 {
+			g.VRAMUsedPct = gpu.VRAMUsed * 100 / gpu.VRAMTotal
+		}
+		st.GPUs = append(st.GPUs, g)
+	}
+
+	st.DBEvents, _ = db.Count()
+	st.QueuedNotifications, _ = db.QueuedNotificationCount()
+	return st
+}
+
+func (st *statusReport) print() {
+	fmt.Printf("Instance:     %s\n", st.Instance)
+	fmt.Printf("Role:         %s\n", st.Role)
+
+	if ev := st.LastEvent; ev != nil {
+		ago := time.Since(ev.Timestamp).Truncate(time.Second)
+		fmt.Printf("Last event:   [%s] %s — %s ago\n", ev.Tier, ev.Summary, format.Duration(ago))
+	} else {
+		fmt.Println("Last event:   none")
+	}
+
+	fmt.Printf("Events (24h): %s\n", formatTierCountMap(st.Events24h))
+
+	if psi := st.PSIMemory; psi != nil {
+		status := "healthy"
+		if psi.Warning {
+			status = "WARNING"
+		}
+		fmt.Printf("PSI memory:   some=%.1f%% full=%.1f%% (%s)\n", psi.SomeAvg10, psi.FullAvg10, status)
+	}
+
+	for _, gpu := range st.GPUs {
+		info := fmt.Sprintf("%s (%s)", gpu.Card, gpu.Vendor)
 		if gpu.Temperature > 0 {
 			info += fmt.Sprintf(" %d°C", gpu.Temperature)
 		}
-		if gpu.VRAMTotal > 0 {
-			pct := gpu.VRAMUsed * 100 / gpu.VRAMTotal
-			info += fmt.Sprintf(", VRAM %d%%", pct)
+		if gpu.VRAMUsedPct > 0 {
+			info += fmt.Sprintf(", VRAM %d%%", gpu.VRAMUsedPct)
 		}
 		fmt.Printf("GPU:          %s\n", info)
 	}
 
-	// DB info.
-	eventCount, _ := db.Count()
-	fmt.Printf("DB events:    %d total\n", eventCount)
-	fmt.Printf("DB path:      %s\n", cfg.DBPath())
+	fmt.Printf("DB events:    %d total\n", st.DBEvents)
+	fmt.Printf("DB path:      %s\n", st.DBPath)
+	if st.QueuedNotifications > 0 {
+		fmt.Printf("Retry queue:  %d notifications (logtriage retry-notifications --list)\n", st.QueuedNotifications)
+	}
+
+	d := st.Daemon
+	if d == nil {
+		fmt.Println("Daemon:       not running (read from the database)")
+		return
+	}
+	up := time.Since(d.Started).Truncate(time.Second)
+	fmt.Printf("Daemon:       pid %d, version %s, up %s\n", d.PID, d.Version, format.Duration(up))
+	fmt.Printf("Handled:      %d events since start\n", d.Events)
+	if d.HeldNotifications > 0 {
+		fmt.Printf("Held:         %d notifications (logtriage ctl suppressions)\n", d.HeldNotifications)
+	}
+	if s := d.Storm; s != nil {
+		fmt.Printf("Storm:        %d events collapsed since %s\n", s.Collapsed, s.Since.Local().Format("15:04:05"))
+	}
+}
+
+// records flattens the report into key, value rows for --format=csv.
+func (st *statusReport) records() [][]string {
+	rows := [][]string{
+		{"instance", st.Instance},
+		{"role", st.Role},
+	}
+	if ev := st.LastEvent; ev != nil {
+		rows = append(rows,
+			[]string{"last_event_time", ev.Timestamp.UTC().Format(time.RFC3339Nano)},
+			[]string{"last_event_tier", string(ev.Tier)},
+			[]string{"last_event_summary", ev.Summary})
+	}
+	for _, tier := range slices.Sorted(maps.Keys(st.Events24h)) {
+		rows = append(rows, []string{"events_24h_" + string(tier), strconv.Itoa(st.Events24h[tier])})
+	}
+	if psi := st.PSIMemory; psi != nil {
+		rows = append(rows,
+			[]string{"psi_memory_some_avg10", strconv.FormatFloat(psi.SomeAvg10, 'f', 2, 64)},
+			[]string{"psi_memory_full_avg10", strconv.FormatFloat(psi.FullAvg10, 'f', 2, 64)},
+			[]string{"psi_memory_warning", strconv.FormatBool(psi.Warning)})
+	}
+	for _, gpu := range st.GPUs {
+		rows = append(rows,
+			[]string{"gpu_" + gpu.Card + "_vendor", gpu.Vendor},
+			[]string{"gpu_" + gpu.Card + "_temperature", strconv.Itoa(gpu.Temperature)},
+			[]string{"gpu_" + gpu.Card + "_vram_used_pct", strconv.FormatInt(gpu.VRAMUsedPct, 10)})
+	}
+	rows = append(rows,
+		[]string{"db_events", strconv.FormatInt(st.DBEvents, 10)},
+		[]string{"db_path", st.DBPath},
+		[]string{"queued_notifications", strconv.Itoa(st.QueuedNotifications)})
+	if d := st.Daemon; d != nil {
+		rows = append(rows,
+			[]string{"daemon_pid", strconv.Itoa(d.PID)},
+			[]string{"daemon_started", d.Started.UTC().Format(time.RFC3339)},
+			[]string{"daemon_events", strconv.Itoa(d.Events)},
+			[]string{"daemon_held_notifications", strconv.Itoa(d.HeldNotifications)})
+	}
+	return rows
 }
 
 // --- query subcommand ---
@@ -522,10 +2323,55 @@ func runQuery(args []string) {
 	fs := flag.NewFlagSet("query", flag.ExitOnError)
 	configPath := fs.String("config", "", "path to config file")
 	last := fs.String("last", "24h", "time window (e.g. 24h, 7d, 30d)")
-	tier := fs.String("tier", "", "filter by tier (T1, T2, T3, T4, T5)")
+	tier := fs.String("tier", "", "filter by tier (T0-T9)")
 	instance := fs.String("instance", "", "filter by instance ID")
 	limit := fs.Int("limit", 50, "max events to show")
+	page := fs.Int("page", 1, "show this page of --limit events, newest first")
+	cursor := fs.String("cursor", "", "show the events after the last page, from the cursor it printed")
+	incidents := fs.Bool("incidents", false, "group events into incident timelines")
+	incident := fs.String("incident", "", "show only events of this incident ID")
+	notifications := fs.Bool("notifications", false, "list logged notification attempts instead of events")
+	sink := fs.String("sink", "", "with --notifications, show only attempts on this sink (e.g. ntfy)")
+	failed := fs.Bool("failed", false, "with --notifications, show only failed attempts")
+	search := fs.String("search", "", "show only events whose summary or detail contain all these words")
+	process := fs.String("process", "", "filter by process name")
+	unit := fs.String("unit", "", "filter by systemd unit")
+	severity := fs.String("severity", "", "filter by severity (critical, high, medium, warning)")
+	formatFlag := fs.String("format", "text", "output format: text, json, or csv")
 	fs.Parse(args)
+	out := parseFormatFlag(*formatFlag)
+
+	sev := event.Severity(strings.ToLower(*severity))
+	switch sev {
+	case "", event.SevCritical, event.SevHigh, event.SevMedium, event.SevWarning:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --severity %q: want critical, high, medium, or warning\n", *severity)
+		os.Exit(1)
+	}
+	if *incidents && (*search != "" || *process != "" || *unit != "" || sev != "") {
+		fmt.Fprintln(os.Stderr, "--search, --process, --unit, and --severity filter events and cannot be used with --incidents")
+		os.Exit(1)
+	}
+	if *notifications && (*incidents || *incident != "" || *tier != "" || *search != "" || *process != "" || *unit != "" || sev != "") {
+		fmt.Fprintln(os.Stderr, "--notifications cannot be combined with event filters other than --instance")
+		os.Exit(1)
+	}
+	if !*notifications && (*sink != "" || *failed) {
+		fmt.Fprintln(os.Stderr, "--sink and --failed require --notifications")
+		os.Exit(1)
+	}
+	if *page < 1 {
+		fmt.Fprintf(os.Stderr, "invalid --page %d: pages start at 1\n", *page)
+		os.Exit(1)
+	}
+	if (*page > 1 || *cursor != "") && (*incidents || *notifications || *limit <= 0) {
+		fmt.Fprintln(os.Stderr, "--page and --cursor page through events, --limit at a time, and cannot be used with --incidents or --notifications")
+		os.Exit(1)
+	}
+	if *page > 1 && *cursor != "" {
+		fmt.Fprintln(os.Stderr, "--page and --cursor cannot be used together")
+		os.Exit(1)
+	}
 
 	cfg, err := config.Load(*configPath)
 	if err != nil {
@@ -535,7 +2381,7 @@ func runQuery(args []string) {
 
 	setupLogging("error") // quiet for CLI output
 
-	db, err := store.Open(cfg.DBPath())
+	db, err := openDB(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
 		os.Exit(1)
@@ -548,18 +2394,86 @@ func runQuery(args []string) {
 		os.Exit(1)
 	}
 
+	if *notifications {
+		printNotificationAttempts(db, store.NotificationFilter{
+			Since:      time.Now().Add(-since),
+			InstanceID: *instance,
+			Sink:       *sink,
+			FailedOnly: *failed,
+			Limit:      *limit,
+		}, out)
+		return
+	}
+
+	if *incidents {
+		printIncidents(db, store.IncidentFilter{
+			Since:      time.Now().Add(-since),
+			Tier:       strings.ToUpper(*tier),
+			InstanceID: *instance,
+			Limit:      *limit,
+		}, out)
+		return
+	}
+
 	filter := store.QueryFilter{
 		Since:      time.Now().Add(-since),
 		Tier:       strings.ToUpper(*tier),
 		InstanceID: *instance,
+		IncidentID: *incident,
+		Unit:       *unit,
+		Process:    *process,
+		Severity:   string(sev),
+		Search:     *search,
 		Limit:      *limit,
+		Offset:     (*page - 1) * *limit,
+		Cursor:     *cursor,
+	}
+	if *incident != "" {
+		// An incident's timeline is shown whole, however old.
+		filter.Since = time.Time{}
 	}
 
-	events, err := db.Query(filter)
+	var events []*event.Event
+	var next string
+	if *limit > 0 {
+		events, next, err = db.QueryPage(filter)
+	} else {
+		events, err = db.Query(filter)
+	}
+	if errors.Is(err, store.ErrBadCursor) {
+		fmt.Fprintf(os.Stderr, "invalid --cursor %q: use the one printed after the previous page\n", *cursor)
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "query error: %v\n", err)
 		os.Exit(1)
 	}
+	// Structured output keeps the cursor out of the data.
+	if next != "" {
+		next = "Next page: --cursor " + next
+	}
+
+	switch out {
+	case format.OutputJSON:
+		if events == nil {
+			events = []*event.Event{} // an empty result is [], not null
+		}
+		exitOnWriteError(format.WriteJSON(os.Stdout, events))
+		if next != "" {
+			fmt.Fprintln(os.Stderr, next)
+		}
+		return
+	case format.OutputCSV:
+		records := make([][]string, len(events))
+		for i, ev := range events {
+			records[i] = ev.CSVRecord()
+		}
+		exitOnWriteError(format.WriteCSV(os.Stdout, event.CSVHeader, records))
+		if next != "" {
+			fmt.Fprintln(os.Stderr, next)
+		}
+		return
+	}
 
 	if len(events) == 0 {
 		fmt.Println("No events found.")
@@ -567,6 +2481,9 @@ func runQuery(args []string) {
 	}
 
 	printEvents(events)
+	if next != "" {
+		fmt.Println(next)
+	}
 }
 
 func printEvents(events []*event.Event) {
@@ -577,6 +2494,18 @@ func printEvents(events []*event.Event) {
 		if ev.Unit != "" {
 			fmt.Printf("             Unit: %s\n", ev.Unit)
 		}
+		if ev.ContainerID != "" {
+			name := ev.ContainerName
+			if name == "" {
+				name = "(unknown)"
+			}
+			fmt.Printf("             Container: %s %s\n", name, classifier.ShortContainerID(ev.ContainerID))
+		} else if ev.CGroup != "" {
+			fmt.Printf("             CGroup: %s\n", ev.CGroup)
+		}
+		if !ev.AckedAt.IsZero() {
+			fmt.Printf("             Acked: by %s at %s\n", ev.AckedBy, ev.AckedAt.Local().Format("2006-01-02 15:04:05"))
+		}
 		if ev.Detail != "" {
 			// Print first line of detail as a brief.
 			lines := strings.SplitN(ev.Detail, "\n", 2)
@@ -587,6 +2516,105 @@ func printEvents(events []*event.Event) {
 	fmt.Printf("Total: %d event(s)\n", len(events))
 }
 
+// incidentCSVHeader names the columns of incident rows in query
+// --incidents --format=csv.
+var incidentCSVHeader = []string{"id", "instance_id", "tier", "severity", "title", "opened_at", "last_seen", "closed_at", "event_count"}
+
+// incidentOutput is an incident with its timeline, oldest first, as query
+// --incidents --format=json emits it.
+type incidentOutput struct {
+	*store.Incident
+	Events []*event.Event `json:"events"`
+}
+
+// printIncidents prints each matching incident followed by the timeline of
+// its events, oldest first.
+func printIncidents(db *store.DB, f store.IncidentFilter, out format.Output) {
+	incidents, err := db.ListIncidents(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch out {
+	case format.OutputJSON:
+		list := make([]incidentOutput, len(incidents))
+		for i, inc := range incidents {
+			events, err := db.Query(store.QueryFilter{IncidentID: inc.ID})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+				os.Exit(1)
+			}
+			slices.Reverse(events)
+			if events == nil {
+				events = []*event.Event{}
+			}
+			list[i] = incidentOutput{Incident: inc, Events: events}
+		}
+		exitOnWriteError(format.WriteJSON(os.Stdout, list))
+		return
+	case format.OutputCSV:
+		records := make([][]string, len(incidents))
+		for i, inc := range incidents {
+			closed := ""
+			if !inc.IsOpen() {
+				closed = inc.ClosedAt.UTC().Format(time.RFC3339Nano)
+			}
+			records[i] = []string{inc.ID, inc.InstanceID, string(inc.Tier), string(inc.Severity), inc.Title,
+				inc.OpenedAt.UTC().Format(time.RFC3339Nano), inc.LastSeen.UTC().Format(time.RFC3339Nano),
+				closed, strconv.Itoa(inc.EventCount)}
+		}
+		exitOnWriteError(format.WriteCSV(os.Stdout, incidentCSVHeader, records))
+		return
+	}
+
+	if len(incidents) == 0 {
+		fmt.Println("No incidents found.")
+		return
+	}
+
+	for _, inc := range incidents {
+		state := "open"
+		if !inc.IsOpen() {
+			state = "closed after " + format.Duration(inc.ClosedAt.Sub(inc.OpenedAt))
+		}
+		fmt.Printf("%s  [%s] %-18s %s\n",
+			inc.OpenedAt.Local().Format("2006-01-02 15:04:05"), inc.Tier, inc.Tier.Label(), inc.Title)
+		fmt.Printf("             %s, %d event(s), %s  (%s)\n", inc.Severity, inc.EventCount, state, inc.ID)
+
+		events, err := db.Query(store.QueryFilter{IncidentID: inc.ID})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+			os.Exit(1)
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			ev := events[i]
+			fmt.Printf("    %s  %-8s %s\n", ev.Timestamp.Local().Format("15:04:05"), ev.Severity, ev.Summary)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Total: %d incident(s)\n", len(incidents))
+}
+
+// parseFormatFlag validates a --format flag, exiting on a bad value.
+func parseFormatFlag(s string) format.Output {
+	out, err := format.ParseOutput(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --format: %v\n", err)
+		os.Exit(1)
+	}
+	return out
+}
+
+// exitOnWriteError exits if writing structured output failed, e.g. because
+// stdout was a closed pipe.
+func exitOnWriteError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // parseDuration extends time.ParseDuration with support for "d" (days) suffix.
 func parseDuration(s string) (time.Duration, error) {
 	if strings.HasSuffix(s, "d") {
@@ -600,22 +2628,124 @@ func parseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
-// formatDuration formats a duration in human-readable form.
-func formatDuration(d time.Duration) string {
-	if d < time.Minute {
-		return fmt.Sprintf("%ds", int(d.Seconds()))
+// classifiedCount sums per-tier event counts, leaving out unclassified
+// events, which are coverage gaps rather than problems.
+func classifiedCount(counts map[event.Tier]int) int {
+	n := 0
+	for tier, c := range counts {
+		if tier != event.TierUnclassified {
+			n += c
+		}
+	}
+	return n
+}
+
+// formatTierCountMap summarizes per-tier event counts.
+func formatTierCountMap(counts map[event.Tier]int) string {
+	return fmt.Sprintf("%d OOM, %d crash, %d service, %d hw, %d pressure, %d limit, %d reboot, %d security",
+		counts[event.TierOOMKill], counts[event.TierProcessCrash], counts[event.TierServiceFailure],
+		counts[event.TierKernelHW], counts[event.TierMemPressure], counts[event.TierResource], counts[event.TierReboot],
+		counts[event.TierSecurity])
+}
+
+// Exit codes for status --short, matching the Nagios plugin convention.
+const (
+	statusOK       = 0
+	statusDegraded = 1
+	statusCritical = 2
+	statusUnknown  = 3
+)
+
+// runStatusShort prints a single status line suitable for a monitoring
+// check, status bar widget, or MOTD, and returns the exit code.
+func runStatusShort(configPath, window string) int {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Printf("UNKNOWN: error loading config: %v\n", err)
+		return statusUnknown
+	}
+
+	setupLogging("error")
+
+	d, err := parseDuration(window)
+	if err != nil {
+		fmt.Printf("UNKNOWN: invalid --window %q: %v\n", window, err)
+		return statusUnknown
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Printf("UNKNOWN: error opening database: %v\n", err)
+		return statusUnknown
+	}
+	defer db.Close()
+
+	open, err := db.ListIncidents(store.IncidentFilter{OpenOnly: true})
+	if err != nil {
+		fmt.Printf("UNKNOWN: query error: %v\n", err)
+		return statusUnknown
+	}
+	counts, err := db.CountByTier(store.QueryFilter{Since: time.Now().Add(-d)})
+	if err != nil {
+		fmt.Printf("UNKNOWN: query error: %v\n", err)
+		return statusUnknown
+	}
+	events := classifiedCount(counts)
+
+	psiHigh := false
+	if stats, err := monitor.ReadPSI("/proc/pressure/memory"); err == nil {
+		psiHigh = stats.SomeAvg10 > cfg.PSI.WarnSomeAvg10 || stats.FullAvg10 > cfg.PSI.WarnFullAvg10
+	}
+
+	code, line := assessHealth(open, events, psiHigh, window)
+	fmt.Println(line)
+	return code
+}
+
+// assessHealth rates the host by its open incidents (most recently active
+// first): an open critical incident is critical, and an open high-severity
+// one or memory pressure is degraded. Incidents close once their events
+// stop or are acked, so a problem that was dealt with stops counting. events
+// is how many were classified in the last window, for context.
+func assessHealth(open []*store.Incident, events int, psiHigh bool, window string) (int, string) {
+	counts := make(map[event.Severity]int)
+	var worst *store.Incident
+	for _, inc := range open {
+		if inc.Tier == event.TierUnclassified {
+			continue // coverage gaps, not problems
+		}
+		counts[inc.Severity]++
+		if worst == nil || inc.Severity.Rank() > worst.Severity.Rank() {
+			worst = inc
+		}
+	}
+
+	var parts []string
+	for _, sev := range []event.Severity{event.SevCritical, event.SevHigh, event.SevMedium, event.SevWarning} {
+		if n := counts[sev]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, sev))
+		}
+	}
+
+	code, label := statusOK, "OK"
+	switch {
+	case counts[event.SevCritical] > 0:
+		code, label = statusCritical, "CRITICAL"
+	case counts[event.SevHigh] > 0 || psiHigh:
+		code, label = statusDegraded, "DEGRADED"
 	}
-	if d < time.Hour {
-		return fmt.Sprintf("%dm", int(d.Minutes()))
+
+	line := label + ": no open incidents"
+	if len(parts) > 0 {
+		line = fmt.Sprintf("%s: %s open", label, strings.Join(parts, ", "))
+		if code != statusOK {
+			line += fmt.Sprintf(" (%s)", worst.Title)
+		}
 	}
-	if d < 24*time.Hour {
-		h := int(d.Hours())
-		m := int(d.Minutes()) % 60
-		return fmt.Sprintf("%dh %dm", h, m)
+	if psiHigh {
+		line += ", memory pressure"
 	}
-	days := int(d.Hours()) / 24
-	h := int(d.Hours()) % 24
-	return fmt.Sprintf("%dd %dh", days, h)
+	return code, line + fmt.Sprintf("; %d events in last %s", events, window)
 }
 
 // --- test-ntfy subcommand ---
@@ -636,15 +2766,14 @@ func runTestNtfyCmd(args []string) {
 }
 
 func doTestNtfy(cfg *config.Config) {
-	if cfg.Ntfy.URL == "" {
-		fmt.Fprintln(os.Stderr, "error: ntfy.url not configured")
-		os.Exit(1)
-	}
-
 	rep := reporter.NewNtfy(cfg)
 	ev := &reporter.TestEvent{
 		InstanceID: cfg.Instance.ID,
 	}
+	if tier := string(ev.ToEvent().Tier); cfg.NtfyTopic(tier) == "" {
+		fmt.Fprintf(os.Stderr, "error: neither ntfy.url nor ntfy.tier_topics.%s configured\n", tier)
+		os.Exit(1)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -678,6 +2807,199 @@ func sdNotify(state string) {
 	}
 }
 
+// monitorStaleAfter is how long a monitor may go without finishing a poll
+// before it counts as wedged: a few missed intervals plus slack for slow
+// external tools.
+func monitorStaleAfter(interval time.Duration) time.Duration {
+	return 3*interval + 2*time.Minute
+}
+
+// kernelMatches selects the kernel messages followed by the kernel warning
+// journal stream.
+var kernelMatches = []string{"_TRANSPORT=kernel"}
+
+// auditMatches selects the audit records followed by the audit journal
+// stream: USER_AUTH, USER_AVC, SECCOMP, and AVC. Record types are matched
+// by number, which every journald version sets.
+var auditMatches = []string{
+	"_TRANSPORT=audit",
+	"_AUDIT_TYPE=1100",
+	"_AUDIT_TYPE=1107",
+	"_AUDIT_TYPE=1326",
+	"_AUDIT_TYPE=1400",
+}
+
+// authMatches selects the entries followed by the login journal stream.
+// OpenSSH 9.8 and later log logins from a separate sshd-session process.
+var authMatches = []string{
+	"SYSLOG_IDENTIFIER=sshd",
+	"SYSLOG_IDENTIFIER=sshd-session",
+	"SYSLOG_IDENTIFIER=login",
+}
+
+// containerMatches selects the container runtime entries followed by the
+// container journal stream.
+var containerMatches = []string{
+	"SYSLOG_IDENTIFIER=dockerd",
+	"SYSLOG_IDENTIFIER=containerd",
+	"SYSLOG_IDENTIFIER=podman",
+	"SYSLOG_IDENTIFIER=conmon",
+}
+
+// inventoryBaseline loads the hardware inventory saved by the previous
+// run, or nil if there is none or it cannot be read.
+func inventoryBaseline(db *store.DB, instanceID string) (*monitor.Inventory, time.Time) {
+	data, at, err := db.LoadInventory(instanceID)
+	if err != nil {
+		slog.Warn("loading hardware inventory failed", "error", err)
+		return nil, time.Time{}
+	}
+	if data == nil {
+		return nil, time.Time{}
+	}
+	var inv monitor.Inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		slog.Warn("saved hardware inventory is corrupt, starting over", "error", err)
+		return nil, time.Time{}
+	}
+	return &inv, at
+}
+
+// saveInventory stores a hardware inventory snapshot as the next run's
+// baseline.
+func saveInventory(db *store.DB, instanceID string, inv monitor.Inventory, at time.Time) {
+	data, err := json.Marshal(inv)
+	if err == nil {
+		err = db.SaveInventory(instanceID, at, data)
+	}
+	if err != nil {
+		slog.Warn("saving hardware inventory failed", "error", err)
+	}
+}
+
+// smartTempLimits converts the [smart] temperature settings for the monitor.
+func diskMounts(c config.DiskConfig) []monitor.DiskMount {
+	var mounts []monitor.DiskMount
+	for _, m := range c.ResolvedMounts() {
+		mounts = append(mounts, monitor.DiskMount{
+			Path: m.Path,
+			DiskThresholds: monitor.DiskThresholds{
+				WarnPct:      m.WarnPct,
+				CritPct:      m.CritPct,
+				InodeWarnPct: m.InodeWarnPct,
+				InodeCritPct: m.InodeCritPct,
+			},
+		})
+	}
+	return mounts
+}
+
+// enricherOptions converts the [crashes] debugger settings, the [audit]
+// source, and the [services] unit details for the enricher.
+func enricherOptions(c *config.Config) enricher.Options {
+	opts := enricher.Options{
+		Debugger:        c.Crashes.Debugger,
+		DebuggerTimeout: c.Crashes.DebuggerTimeout.Duration,
+		UnitInfo:        c.Services.UnitInfo,
+		UnitStatus:      c.Services.Status,
+	}
+	if c.Audit.Enabled {
+		opts.Audit = c.Audit.Source
+	}
+	return opts
+}
+
+// arraySummary titles an array event, e.g. "RAID degraded: md0 (failed:
+// sda1)" or "ZFS pool errors: tank (2 new, 17 total)".
+func arraySummary(ev monitor.ArrayEvent) string {
+	s := ev.Status
+	label := s.Kind
+	switch s.Kind {
+	case monitor.ArrayMD:
+		label = "RAID"
+	case monitor.ArrayZFS:
+		label = "ZFS pool"
+	}
+	if !s.Degraded {
+		return fmt.Sprintf("%s errors: %s (%d new, %d total)", label, s.Name, ev.NewErrors, s.Errors)
+	}
+	summary := fmt.Sprintf("%s degraded: %s", label, s.Name)
+	if len(s.Failed) > 0 {
+		summary += " (failed: " + strings.Join(s.Failed, ", ") + ")"
+	}
+	return summary
+}
+
+func smartTempLimits(c config.SMARTConfig) monitor.SMARTTempLimits {
+	limits := monitor.SMARTTempLimits{
+		HDD:     c.TempWarnHDD,
+		SSD:     c.TempWarnSSD,
+		Sustain: c.TempSustain.Duration,
+	}
+	for _, d := range c.Devices {
+		limits.Devices = append(limits.Devices, monitor.SMARTDeviceLimit{Pattern: d.Device, Limit: d.TempWarn})
+	}
+	return limits
+}
+
+// gpuThresholds converts the [gpu] config to the monitor's thresholds.
+func gpuThresholds(c config.GPUConfig) monitor.GPUThresholds {
+	t := monitor.GPUThresholds{TempWarn: c.TempWarn, VRAMWarnPct: c.VRAMWarnPct}
+	for _, card := range c.Cards {
+		t.Cards = append(t.Cards, monitor.GPUCardThresholds{Card: card.Card, TempWarn: card.TempWarn, VRAMWarnPct: card.VRAMWarnPct})
+	}
+	return t
+}
+
+// thrashThresholds converts the thrash config to monitor thresholds.
+func thrashThresholds(c config.ThrashConfig) monitor.ThrashThresholds {
+	return monitor.ThrashThresholds{
+		MajFaultRate:    c.MajFaultRate,
+		SwapRate:        c.SwapRate,
+		MemAvailablePct: c.MemAvailableWarnPct,
+		Sustain:         c.Sustain.Duration,
+	}
+}
+
+// capabilityWarnings lists features that are enabled or always on but
+// cannot work on this host, for the digest's self-health section.
+func capabilityWarnings(cfg *config.Config) []string {
+	var warnings []string
+	if !sysdep.Have("journalctl") {
+		warnings = append(warnings, "journalctl not found: journal watching disabled")
+	} else if !sysdep.Have("coredumpctl") {
+		warnings = append(warnings, "coredumpctl not found: crash backtraces unavailable")
+	}
+	if cfg.Crashes.Debugger && !sysdep.Have("gdb") {
+		warnings = append(warnings, "gdb not found: crashes.debugger backtraces unavailable")
+	}
+	if cfg.EBPF.Enabled && !sysdep.Have("bpftrace") {
+		warnings = append(warnings, "bpftrace not found: eBPF tracer disabled")
+	}
+	if cfg.SMART.Enabled && !sysdep.Have("smartctl") {
+		warnings = append(warnings, "smartctl not found: SMART monitor disabled")
+	}
+	if cfg.Quota.Enabled && !sysdep.Have("repquota") && !sysdep.Have("xfs_quota") {
+		warnings = append(warnings, "repquota and xfs_quota not found: quota monitor disabled")
+	}
+	if cfg.UnitLimits.Enabled && !sysdep.Have("systemctl") {
+		warnings = append(warnings, "systemctl not found: unit limit monitor disabled")
+	}
+	return warnings
+}
+
+// saveRun copies the loss counters into the daemon's run record.
+func saveRun(db *store.DB, r *store.Run) {
+	if r == nil {
+		return
+	}
+	c := selfstat.Snapshot()
+	r.Dropped, r.ReporterFailures = c.Dropped, c.ReporterFailures
+	if err := db.SaveRun(r); err != nil {
+		slog.Warn("failed to save run record", "error", err)
+	}
+}
+
 // watchdogInterval reads WATCHDOG_USEC from the environment and returns the
 // watchdog interval as a time.Duration. Returns 0 if not set.
 func watchdogInterval() time.Duration {
@@ -695,6 +3017,11 @@ func watchdogInterval() time.Duration {
 // --- utilities ---
 
 func setupLogging(level string) {
+	slog.SetDefault(slog.New(logHandler(level)))
+}
+
+// logHandler returns the handler writing the log to stderr at level.
+func logHandler(level string) slog.Handler {
 	var logLevel slog.Level
 	switch level {
 	case "debug":
@@ -707,10 +3034,9 @@ func setupLogging(level string) {
 		logLevel = slog.LevelInfo
 	}
 
-	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	return slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: logLevel,
 	})
-	slog.SetDefault(slog.New(handler))
 }
 
 func dataDirectory() (string, error) {
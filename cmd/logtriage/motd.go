@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/monitor"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// --- motd subcommand ---
+
+// motdMaxAlerts caps how many recent alerts the banner lists.
+const motdMaxAlerts = 5
+
+// runMotd prints a compact triage summary for /etc/update-motd.d or a shell
+// login hook. It never fails loudly: sections that cannot be read are left
+// out so a login is never cluttered with errors.
+func runMotd(args []string) {
+	fs := flag.NewFlagSet("motd", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	last := fs.String("last", "24h", "time window to summarize")
+	noHW := fs.Bool("no-hardware", false, "skip disk, SMART, and GPU checks")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return
+	}
+
+	setupLogging("error")
+
+	window, err := parseDuration(*last)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --last value: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Counted in SQL, with only the newest alerts loaded: this runs on
+	// every login.
+	var counts map[event.Tier]int
+	var alerts []*event.Event
+	var open []*store.Incident
+	moreAlerts := 0
+	if db, err := openDB(cfg); err == nil {
+		since := time.Now().Add(-window)
+		counts, _ = db.CountByTier(store.QueryFilter{Since: since})
+		alerts, moreAlerts = motdAlerts(db, since)
+		open, _ = db.ListIncidents(store.IncidentFilter{OpenOnly: true})
+		db.Close()
+	}
+	classified := classifiedCount(counts)
+
+	psiHigh := false
+	if stats, err := monitor.ReadPSI("/proc/pressure/memory"); err == nil {
+		psiHigh = stats.SomeAvg10 > cfg.PSI.WarnSomeAvg10 || stats.FullAvg10 > cfg.PSI.WarnFullAvg10
+	}
+
+	_, headline := assessHealth(open, classified, psiHigh, *last)
+	fmt.Printf("logtriage [%s] %s\n", cfg.Instance.ID, headline)
+
+	for _, ev := range alerts {
+		fmt.Printf("  %s  [%s] %s\n", ev.Timestamp.Local().Format("Jan 02 15:04"), ev.Tier, ev.Summary)
+	}
+	if moreAlerts > 0 {
+		fmt.Printf("  ... and %d more (logtriage query --last %s)\n", moreAlerts, *last)
+	}
+
+	fmt.Printf("  Events:  %s\n", formatTierCountMap(counts))
+
+	if *noHW {
+		return
+	}
+
+	if line := motdDiskLine("/"); line != "" {
+		fmt.Printf("  Disk:    %s\n", line)
+	}
+
+	if cfg.SMART.Enabled {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		disks := monitor.ReadSMARTAll(ctx)
+		cancel()
+		if len(disks) > 0 {
+			fmt.Printf("  SMART:   %s\n", motdSMARTLine(disks))
+		}
+	}
+
+	gpus := monitor.DetectGPUs()
+	var gpuParts []string
+	for i := range gpus {
+		gpu := &gpus[i]
+		monitor.ReadGPUTemp(gpu)
+		monitor.ReadGPUVRAM(gpu)
+
+		info := fmt.Sprintf("%s (%s)", filepath.Base(gpu.CardPath), gpu.Vendor)
+		if gpu.Temperature > 0 {
+			info += fmt.Sprintf(" %d°C", gpu.Temperature)
+		}
+		if gpu.VRAMTotal > 0 {
+			info += fmt.Sprintf(" VRAM %d%%", gpu.VRAMUsed*100/gpu.VRAMTotal)
+		}
+		gpuParts = append(gpuParts, info)
+	}
+	if len(gpuParts) > 0 {
+		fmt.Printf("  GPU:     %s\n", strings.Join(gpuParts, ", "))
+	}
+}
+
+// motdAlerts returns the newest alert-worthy (high or critical) events
+// since the given time, at most motdMaxAlerts of them newest first, and
+// how many more there are.
+func motdAlerts(db *store.DB, since time.Time) ([]*event.Event, int) {
+	var alerts []*event.Event
+	total := 0
+	for _, sev := range []event.Severity{event.SevCritical, event.SevHigh} {
+		f := store.QueryFilter{Since: since, Severity: string(sev)}
+		counts, err := db.CountByTier(f)
+		if err != nil {
+			continue
+		}
+		f.Limit = motdMaxAlerts
+		evs, err := db.Query(f)
+		if err != nil {
+			continue
+		}
+		alerts = append(alerts, evs...)
+		for _, n := range counts {
+			total += n
+		}
+	}
+	slices.SortStableFunc(alerts, func(a, b *event.Event) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	if len(alerts) > motdMaxAlerts {
+		alerts = alerts[:motdMaxAlerts]
+	}
+	return alerts, max(total-len(alerts), 0)
+}
+
+// motdDiskLine describes space usage of the filesystem containing path.
+func motdDiskLine(path string) string {
+	u, err := monitor.ReadDiskUsage(path)
+	if err != nil || u.Total == 0 {
+		return ""
+	}
+	used := u.Total - u.Free
+	return fmt.Sprintf("%s %d%% used (%s free)", path, used*100/u.Total, format.Bytes(int64(u.Avail)))
+}
+
+// motdSMARTLine summarizes SMART health across disks.
+func motdSMARTLine(disks []monitor.SMARTStatus) string {
+	parts := make([]string, 0, len(disks))
+	for _, d := range disks {
+		state := "OK"
+		switch {
+		case !d.Healthy:
+			state = "FAILING"
+		case d.ReallocCount > 0 || d.PendCount > 0:
+			state = "WARN"
+		}
+		info := fmt.Sprintf("%s %s", filepath.Base(d.Device), state)
+		if d.Temperature > 0 {
+			info += fmt.Sprintf(" %d°C", d.Temperature)
+		}
+		parts = append(parts, info)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+func TestMotdAlerts(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	sevs := []event.Severity{event.SevCritical, event.SevHigh, event.SevMedium}
+	for i := range 9 {
+		ev := event.New("h", now.Add(-time.Duration(i)*time.Minute), event.TierOOMKill, sevs[i%3], fmt.Sprintf("ev%d", i))
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts, more := motdAlerts(db, now.Add(-time.Hour))
+	var got []string
+	for _, ev := range alerts {
+		got = append(got, ev.Summary)
+	}
+	// Six are high or critical: the newest five are listed.
+	if fmt.Sprint(got) != "[ev0 ev1 ev3 ev4 ev6]" || more != 1 {
+		t.Errorf("motdAlerts = %v, %d more; want [ev0 ev1 ev3 ev4 ev6], 1 more", got, more)
+	}
+}
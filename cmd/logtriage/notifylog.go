@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// logAttempts returns the callback that records every delivery a sink
+// attempts in db's notification log, one row per event of the message,
+// for `logtriage query --notifications`. Like batchFailed's callback it may
+// run on a batcher's goroutine.
+func logAttempts(db *store.DB) func(reporter.Attempt) {
+	return func(a reporter.Attempt) {
+		errText := ""
+		if a.Err != nil {
+			errText = a.Err.Error()
+		}
+		for _, ev := range a.Events {
+			if err := db.RecordNotificationAttempt(&store.NotificationAttempt{
+				EventID:     ev.ID,
+				Sink:        a.Sink,
+				AttemptedAt: a.At,
+				Status:      a.Status,
+				Latency:     a.Latency,
+				Batched:     len(a.Events),
+				Error:       errText,
+			}); err != nil {
+				slog.Error("failed to log notification attempt", "sink", a.Sink, "error", err)
+			}
+		}
+	}
+}
+
+// notificationCSVHeader names the columns of query --notifications
+// --format=csv.
+var notificationCSVHeader = []string{"attempted_at", "sink", "event_id", "instance_id", "tier", "status", "latency_ms", "batched", "error", "summary"}
+
+// printNotificationAttempts prints the logged delivery attempts f matches,
+// most recent first.
+func printNotificationAttempts(db *store.DB, f store.NotificationFilter, out format.Output) {
+	attempts, err := db.NotificationAttempts(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "query error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch out {
+	case format.OutputJSON:
+		if attempts == nil {
+			attempts = []*store.NotificationAttempt{}
+		}
+		exitOnWriteError(format.WriteJSON(os.Stdout, attempts))
+		return
+	case format.OutputCSV:
+		records := make([][]string, len(attempts))
+		for i, a := range attempts {
+			records[i] = []string{a.AttemptedAt.UTC().Format(time.RFC3339Nano), a.Sink, a.EventID, a.InstanceID,
+				string(a.Tier), strconv.Itoa(a.Status), strconv.FormatInt(a.Latency.Milliseconds(), 10),
+				strconv.Itoa(a.Batched), a.Error, a.Summary}
+		}
+		exitOnWriteError(format.WriteCSV(os.Stdout, notificationCSVHeader, records))
+		return
+	}
+
+	if len(attempts) == 0 {
+		fmt.Println("No notification attempts found.")
+		return
+	}
+
+	failed := 0
+	for _, a := range attempts {
+		result := "ok"
+		if a.Error != "" {
+			result = "FAILED"
+			failed++
+		}
+		status := "-"
+		if a.Status != 0 {
+			status = strconv.Itoa(a.Status)
+		}
+		summary := a.Summary
+		if summary == "" {
+			summary = a.EventID + " (event purged)"
+		}
+		if a.Batched > 1 {
+			summary += fmt.Sprintf("  (1 of %d merged)", a.Batched)
+		}
+		fmt.Printf("%s  %-8s %-6s %3s %6dms  %s\n",
+			a.AttemptedAt.Local().Format("2006-01-02 15:04:05"), a.Sink, result, status,
+			a.Latency.Milliseconds(), summary)
+		if a.Error != "" {
+			fmt.Printf("             %s\n", a.Error)
+		}
+	}
+	fmt.Printf("Total: %d attempt(s), %d failed\n", len(attempts), failed)
+}
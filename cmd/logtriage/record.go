@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/corpus"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// --- record subcommand ---
+
+// runRecord captures classified (and optionally near-miss) journal entries
+// as anonymized fixtures, or with --check replays a corpus against the
+// current classifier.
+func runRecord(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	since := fs.String("since", "24h", "how far back to read the journal (e.g. 24h, 7d)")
+	out := fs.String("out", "", "directory to write fixtures to")
+	nearMiss := fs.Bool("near-miss", false, "also record error-priority entries that were not classified")
+	check := fs.String("check", "", "replay the fixtures in this directory and report regressions")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	cls := classifier.New(cfg.Instance.ID)
+	if err := cls.SetRules(cfg.Rules); err != nil {
+		fmt.Fprintf(os.Stderr, "error loading classification rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *check != "" {
+		os.Exit(checkCorpus(cls, *check))
+	}
+
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "error: --out or --check is required")
+		os.Exit(1)
+	}
+	window, err := parseDuration(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid --since: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "error creating %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+
+	entries, err := readJournalSince(time.Now().Add(-window))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading journal: %v\n", err)
+		os.Exit(1)
+	}
+
+	hosts := []string{cfg.Instance.ID}
+	if h, err := os.Hostname(); err == nil && h != cfg.Instance.ID {
+		hosts = append(hosts, h)
+	}
+	anon := corpus.NewAnonymizer(hosts, localUsers("/etc/passwd"))
+
+	var added, updated, misses int
+	for _, entry := range entries {
+		f, err := corpus.Record(cls, anon, entry, *nearMiss)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping entry: %v\n", err)
+			continue
+		}
+		if f == nil {
+			continue
+		}
+		isNew, err := f.Write(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		switch {
+		case f.Expect == nil && isNew:
+			misses++
+		case isNew:
+			added++
+		default:
+			updated++
+		}
+	}
+
+	fmt.Printf("Read %d entries: %d new fixtures, %d near-misses, %d already recorded.\n",
+		len(entries), added, misses, updated)
+	fmt.Println("Review the fixtures before committing them; anonymization is best-effort.")
+}
+
+// checkCorpus replays the fixtures in dir and prints any whose
+// classification changed. It returns the process exit code.
+func checkCorpus(cls *classifier.Classifier, dir string) int {
+	fixtures, err := corpus.Load(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading corpus: %v\n", err)
+		return 1
+	}
+	mismatches, err := corpus.Replay(cls, fixtures)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error replaying corpus: %v\n", err)
+		return 1
+	}
+	for _, m := range mismatches {
+		fmt.Println(m)
+	}
+	fmt.Printf("%d fixtures, %d regressions.\n", len(fixtures), len(mismatches))
+	if len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readJournalSince returns the error-priority journal entries since t, the
+// same priority range the daemon follows.
+func readJournalSince(t time.Time) ([]watcher.JournalEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "journalctl",
+		"--since", "@"+strconv.FormatInt(t.Unix(), 10),
+		"-p", "0..3", "-o", "json", "--no-pager")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("journalctl: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var entries []watcher.JournalEntry
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry, err := watcher.ParseJournalJSON(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// localUsers returns the names of regular (uid >= 1000) accounts in a
+// passwd file, so they can be anonymized wherever they appear.
+func localUsers(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var users []string
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		uid, err := strconv.Atoi(parts[2])
+		if err != nil || uid < 1000 || uid == 65534 {
+			continue
+		}
+		users = append(users, parts[0])
+	}
+	return users
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/cooldown"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/suppress"
+)
+
+// --- config reload ---
+
+// reload swaps a reloaded config into the pipeline: classification and
+// suppression rules, cooldown, notification sinks and alert tiers, quiet
+// hours, escalation, remediation actions, hooks, and the storm guard.
+// Incidents, held and queued notifications, a storm in progress, and
+// remediation and hook run counts are kept. If a rule set does not compile,
+// reload returns an error and nothing changes.
+func (p *pipeline) reload(ctx context.Context, cfg *config.Config) error {
+	sup, err := suppress.New(cfg.Suppress.Rules)
+	if err != nil {
+		return fmt.Errorf("loading suppression rules: %w", err)
+	}
+	cd, err := cooldown.New(cfg.Cooldown)
+	if err != nil {
+		return fmt.Errorf("loading cooldown overrides: %w", err)
+	}
+	remedy, err := newRemediator(cfg)
+	if err != nil {
+		return fmt.Errorf("loading remediation actions: %w", err)
+	}
+	hooks, err := newHooks(cfg)
+	if err != nil {
+		return fmt.Errorf("loading hooks: %w", err)
+	}
+	if err := p.cls.SetRules(cfg.Rules); err != nil {
+		return fmt.Errorf("loading classification rules: %w", err)
+	}
+	p.sup = sup
+	p.cooldown = cd
+	if remedy != nil {
+		remedy.Inherit(p.remedy)
+	}
+	p.remedy = remedy
+	if hooks != nil {
+		hooks.Inherit(p.hooks)
+	}
+	p.hooks = hooks
+	p.db.SetDedupKeys(cfg.Cooldown.Keys)
+
+	// Deliver what the old sinks hold in a batching window before they
+	// are replaced.
+	flushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	if err := p.rep.Flush(flushCtx); err != nil {
+		slog.Error("flushing notifications", "error", err)
+	}
+	cancel()
+	p.rep = newReporter(cfg)
+	p.rep.OnBatchFailure(p.batchFailed(cfg))
+	p.rep.OnAttempt(logAttempts(p.db))
+	p.escalation = newEscalationReporter(cfg)
+
+	switch {
+	case !cfg.Storm.Enabled:
+		p.storm = nil
+	case p.storm == nil:
+		p.storm = p.cls.NewStormGuard(cfg.Storm.Rate)
+	default:
+		p.storm.SetRate(cfg.Storm.Rate)
+	}
+
+	slog.SetDefault(slog.New(p.selfmon.Handler(logHandler(cfg.Log.Level))))
+	p.cfg = cfg
+	return nil
+}
+
+// configRejectedEvent is the T0 event for a reload that failed, with each
+// problem found in the config in its detail.
+func configRejectedEvent(instanceID string, err error) *event.Event {
+	problems := []string{err.Error()}
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		problems = problems[:0]
+		for _, p := range invalid.Problems {
+			problems = append(problems, p.String())
+		}
+	}
+
+	ev := event.New(instanceID, time.Now(), event.TierInternal, event.SevMedium, "Config change rejected: "+problems[0])
+	ev.Process = "logtriage"
+	ev.DedupKey = "config=rejected"
+	ev.Detail = "A config reload failed, so the daemon keeps running on the " +
+		"previous config.\n\n" + strings.Join(problems, "\n")
+	return ev
+}
+
+// restartRequired returns the config sections changed between old and cfg
+// that are only read at startup, such as listen addresses and which
+// monitors run, so the change waits for a restart. Monitor thresholds are
+// applied to the running monitors.
+func restartRequired(old, cfg *config.Config) []string {
+	sections := []struct {
+		key      string
+		old, new any
+	}{
+		{"instance", old.Instance, cfg.Instance},
+		{"db", old.DB, cfg.DB},
+		{"web", old.Web, cfg.Web},
+		{"ack", old.Ack, cfg.Ack},
+		{"hub", old.Hub, cfg.Hub},
+		{"health", old.Health, cfg.Health},
+		{"control", old.Control, cfg.Control},
+		{"reload", old.Reload, cfg.Reload},
+		{"agent", old.Agent, cfg.Agent},
+		{"syslog", old.Syslog, cfg.Syslog},
+		{"otel", old.OTel, cfg.OTel},
+		{"loki", old.Loki, cfg.Loki},
+		{"heartbeat", old.Heartbeat, cfg.Heartbeat},
+		{"self_monitor", old.SelfMonitor, cfg.SelfMonitor},
+		{"capture", old.Capture, cfg.Capture},
+		{"bundle", old.Bundle, cfg.Bundle},
+		{"containers", old.Containers, cfg.Containers},
+		{"user_journal", old.UserJournal, cfg.UserJournal},
+		{"kubernetes", old.Kubernetes, cfg.Kubernetes},
+		{"eventlog", old.EventLog, cfg.EventLog},
+		{"boot", old.Boot, cfg.Boot},
+		{"units", old.Units, cfg.Units},
+		{"inventory", old.Inventory, cfg.Inventory},
+		{"psi", []any{old.PSI.Enabled, old.PSI.PollInterval, old.PSI.CPU.Enabled, old.PSI.IO.Enabled}, []any{cfg.PSI.Enabled, cfg.PSI.PollInterval, cfg.PSI.CPU.Enabled, cfg.PSI.IO.Enabled}},
+		{"thrash", []any{old.Thrash.Enabled, old.Thrash.PollInterval}, []any{cfg.Thrash.Enabled, cfg.Thrash.PollInterval}},
+		{"smart", []any{old.SMART.Enabled, old.SMART.PollInterval}, []any{cfg.SMART.Enabled, cfg.SMART.PollInterval}},
+		{"arrays", old.Arrays, cfg.Arrays},
+		{"ebpf", old.EBPF, cfg.EBPF},
+		{"audit", old.Audit, cfg.Audit},
+		{"ssh", old.SSH, cfg.SSH},
+		{"security", []any{old.Security.Enabled, old.Security.AuthorizedKeys, old.Security.PollInterval}, []any{cfg.Security.Enabled, cfg.Security.AuthorizedKeys, cfg.Security.PollInterval}},
+		{"gpu", []any{old.GPU.Enabled, old.GPU.PollInterval}, []any{cfg.GPU.Enabled, cfg.GPU.PollInterval}},
+		{"quota", []any{old.Quota.Enabled, old.Quota.PollInterval, old.Quota.Subjects}, []any{cfg.Quota.Enabled, cfg.Quota.PollInterval, cfg.Quota.Subjects}},
+		{"unit_limits", []any{old.UnitLimits.Enabled, old.UnitLimits.PollInterval, old.UnitLimits.Units}, []any{cfg.UnitLimits.Enabled, cfg.UnitLimits.PollInterval, cfg.UnitLimits.Units}},
+		{"diskspace", []any{old.Disk.Enabled, old.Disk.PollInterval, old.Disk.TopDirs}, []any{cfg.Disk.Enabled, cfg.Disk.PollInterval, cfg.Disk.TopDirs}},
+	}
+	var changed []string
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.new) {
+			changed = append(changed, s.key)
+		}
+	}
+	return changed
+}
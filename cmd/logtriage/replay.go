@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/cooldown"
+	"github.com/setevik/logtriage/internal/enricher"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/suppress"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// --- replay subcommand ---
+
+// replayStats counts what a replay did with the entries it read.
+type replayStats struct {
+	entries      int // entries read within the period
+	malformed    int
+	stored       map[event.Tier]int
+	unclassified int // stored by the catch-all
+	seen         int // already in the database
+}
+
+// runReplay runs historical journal entries through the same classifier,
+// enricher, and store as the daemon, to back-fill the database, e.g. when
+// a machine is first set up. Nothing is notified unless --notify is given.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	sinceFlag := fs.String("since", "", "start of the period: a date (2024-05-01), a date and time (2024-05-01 14:00), or a duration ago (e.g. 7d)")
+	untilFlag := fs.String("until", "", "end of the period, in the same forms as --since (default: now)")
+	file := fs.String("file", "", "read a journalctl -o json export instead of the journal (- for stdin)")
+	notify := fs.Bool("notify", false, "notify as the daemon would; otherwise events are only stored")
+	fs.Parse(args)
+
+	now := time.Now()
+	var since, until time.Time
+	var err error
+	if *sinceFlag != "" {
+		if since, err = parseReplayTime(*sinceFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *untilFlag != "" {
+		if until, err = parseReplayTime(*untilFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if since.IsZero() && *file == "" {
+		fmt.Fprintln(os.Stderr, "error: --since or --file is required")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	cls := classifier.New(cfg.Instance.ID)
+	if err := cls.SetRules(cfg.Rules); err != nil {
+		fmt.Fprintf(os.Stderr, "error loading classification rules: %v\n", err)
+		os.Exit(1)
+	}
+	sup, err := suppress.New(cfg.Suppress.Rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading suppression rules: %v\n", err)
+		os.Exit(1)
+	}
+	cd, err := cooldown.New(cfg.Cooldown)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading cooldown overrides: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	db.SetDedupKeys(cfg.Cooldown.Keys)
+	if cfg.DB.WriteQueue > 0 {
+		db.StartWriter(cfg.DB.WriteQueue)
+	}
+
+	// The processes of past events are long gone; their PIDs may be
+	// another process's now.
+	opts := enricherOptions(cfg)
+	opts.Replay = true
+
+	// No storm guard: a replay reads a backlog as fast as it can, which
+	// is not a storm, and every entry should be back-filled.
+	p := &pipeline{
+		cfg:      cfg,
+		cls:      cls,
+		enr:      enricher.New(opts),
+		db:       db,
+		sup:      sup,
+		cooldown: cd,
+		quiet:    !*notify,
+		stats:    newPipelineStats(),
+	}
+	if *notify {
+		p.rep = newReporter(cfg)
+		p.rep.OnBatchFailure(p.batchFailed(cfg))
+		p.rep.OnAttempt(logAttempts(db))
+		p.escalation = newEscalationReporter(cfg)
+	}
+
+	ctx := context.Background()
+	var r io.Reader
+	switch *file {
+	case "":
+		cmd := journalSince(ctx, since, until)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading journal: %v\n", err)
+			os.Exit(1)
+		}
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading journal: %v\n", err)
+			os.Exit(1)
+		}
+		defer cmd.Wait()
+		r = stdout
+	case "-":
+		r = os.Stdin
+	default:
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	st, err := p.replay(ctx, r, since, until)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading entries: %v\n", err)
+		os.Exit(1)
+	}
+	if *notify {
+		if err := p.rep.Flush(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "error flushing notifications: %v\n", err)
+		}
+	}
+	st.print(os.Stdout, *notify)
+}
+
+// replay classifies and stores the journal entries in r, a journalctl -o
+// json stream, that fall within [since, until); a zero bound is open.
+// Events already in the database are skipped, so a period can be replayed
+// again, or overlap what the daemon recorded, without duplicates.
+func (p *pipeline) replay(ctx context.Context, r io.Reader, since, until time.Time) (*replayStats, error) {
+	st := &replayStats{stored: make(map[event.Tier]int)}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		entry, err := watcher.ParseJournalJSON(sc.Bytes())
+		if err != nil {
+			st.malformed++
+			continue
+		}
+		t := entry.Time()
+		if !since.IsZero() && t.Before(since) || !until.IsZero() && !t.Before(until) {
+			continue
+		}
+		st.entries++
+
+		// Skip our own lines, such as events exported to syslog.
+		if entry.SyslogIdentifier == "logtriage" || p.sup.MatchEntry(entry) != "" {
+			continue
+		}
+
+		ev := p.cls.Classify(entry)
+		if ev == nil && p.cfg.Catchall.Enabled {
+			ev = p.cls.ClassifyUnclassified(entry, p.cfg.Catchall.MaxPriority)
+		}
+		if ev == nil {
+			continue
+		}
+
+		if stored, err := p.db.Stored(ev); err != nil {
+			return st, err
+		} else if stored {
+			st.seen++
+			continue
+		}
+		if ev.Tier == event.TierUnclassified {
+			p.keep(ctx, ev)
+			st.unclassified++
+			continue
+		}
+		p.handle(ctx, ev)
+		st.stored[ev.Tier]++
+	}
+	return st, sc.Err()
+}
+
+// print summarizes the replay.
+func (st *replayStats) print(w io.Writer, notified bool) {
+	var total int
+	var tiers []string
+	for _, tier := range slices.Sorted(maps.Keys(st.stored)) {
+		total += st.stored[tier]
+		tiers = append(tiers, fmt.Sprintf("%s %d", tier, st.stored[tier]))
+	}
+	fmt.Fprintf(w, "Replayed %d entries: %d events stored", st.entries, total)
+	if len(tiers) > 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(tiers, ", "))
+	}
+	fmt.Fprintln(w, ".")
+	if st.unclassified > 0 {
+		fmt.Fprintf(w, "%d unclassified entries stored by the catch-all.\n", st.unclassified)
+	}
+	if st.seen > 0 {
+		fmt.Fprintf(w, "%d events were already stored and skipped.\n", st.seen)
+	}
+	if st.malformed > 0 {
+		fmt.Fprintf(w, "%d malformed lines skipped.\n", st.malformed)
+	}
+	if !notified && total > 0 {
+		fmt.Fprintln(w, "Nothing was notified; pass --notify to alert as the daemon would.")
+	}
+}
+
+// journalSince returns a journalctl command printing the error-priority
+// entries, the range the daemon follows, logged within [since, until).
+func journalSince(ctx context.Context, since, until time.Time) *exec.Cmd {
+	args := []string{"-p", "0..3", "-o", "json", "--no-pager"}
+	if !since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(since.Unix(), 10))
+	}
+	if !until.IsZero() {
+		args = append(args, "--until", "@"+strconv.FormatInt(until.Unix(), 10))
+	}
+	return exec.CommandContext(ctx, "journalctl", args...)
+}
+
+// parseReplayTime parses a --since or --until value: a local date, a local
+// date and time, an RFC 3339 timestamp, or a duration before now.
+func parseReplayTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date, time, or duration", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// Failed notifications are retried after retryBase, doubling with each
+// further failure up to retryMax, until notify.retry_max_age passes.
+const (
+	retryBase = 30 * time.Second
+	retryMax  = time.Hour
+)
+
+// retryBatch caps how many queued notifications the daemon retries per
+// pass, so a long outage does not stall the event loop once it ends.
+const retryBatch = 50
+
+// retryPassTimeout bounds a retry pass on the event loop. Under the
+// systemd watchdog it is at most a quarter of the interval, like a
+// remediation action.
+const retryPassTimeout = 30 * time.Second
+
+// retryDelay returns how long to wait after a notification's attempts-th
+// failed delivery.
+func retryDelay(attempts int) time.Duration {
+	d := retryBase
+	for i := 1; i < attempts && d < retryMax; i++ {
+		d *= 2
+	}
+	return min(d, retryMax)
+}
+
+// queueRetry stores a notification that failed on the given sinks so it is
+// retried later. Nothing is queued when the retry queue is disabled.
+func (p *pipeline) queueRetry(sinks []string, t reporter.Transition, ev *event.Event, err error) {
+	queueForRetry(p.db, p.cfg.Notify.RetryMaxAge.Duration, sinks, t, ev, err)
+}
+
+// queueForRetry implements queueRetry for a retry queue keeping
+// notifications for maxAge.
+func queueForRetry(db *store.DB, maxAge time.Duration, sinks []string, t reporter.Transition, ev *event.Event, err error) {
+	if maxAge <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, sink := range sinks {
+		q := &store.QueuedNotification{
+			EventID:     ev.ID,
+			Sink:        sink,
+			Transition:  string(t.Kind),
+			Count:       t.Count,
+			Summary:     ev.Summary,
+			Attempts:    1,
+			QueuedAt:    now,
+			NextAttempt: now.Add(retryDelay(1)),
+			LastError:   err.Error(),
+		}
+		if err := db.QueueNotification(q); err != nil {
+			slog.Error("failed to queue notification for retry", "sink", sink, "error", err)
+			continue
+		}
+		slog.Info("notification queued for retry", "sink", sink, "event_id", ev.ID, "retry_in", retryDelay(1))
+	}
+}
+
+// batchFailed returns the callback for a reporter built from cfg that
+// queues the events of a merged message a batching sink failed to deliver.
+// Each is retried as a notification of its own. The callback runs on the
+// batcher's goroutine, so it keeps to cfg rather than p.cfg, which a
+// config reload replaces.
+func (p *pipeline) batchFailed(cfg *config.Config) func(sink string, evs []*event.Event, err error) {
+	maxAge := cfg.Notify.RetryMaxAge.Duration
+	return func(sink string, evs []*event.Event, err error) {
+		for _, ev := range evs {
+			queueForRetry(p.db, maxAge, []string{sink}, reporter.Transition{Kind: reporter.TransitionCreated, Count: 1}, ev, err)
+		}
+	}
+}
+
+// retryNotifications resends the queued notifications that are due.
+func (p *pipeline) retryNotifications(ctx context.Context) {
+	if p.cfg.Notify.RetryMaxAge.Duration <= 0 {
+		return
+	}
+	timeout := retryPassTimeout
+	if wd := watchdogInterval(); wd > 0 {
+		timeout = min(timeout, wd/4)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	sent, failed := retryQueued(ctx, p.db, p.rep, p.cfg.Notify.RetryMaxAge.Duration, time.Now(), retryBatch)
+	if sent > 0 || failed > 0 {
+		slog.Info("retried queued notifications", "sent", sent, "failed", failed)
+	}
+}
+
+// retryQueued gives up on notifications queued longer than maxAge, then
+// resends up to limit of the rest through rep: those due by now, or all of
+// them when now is zero. It returns how many were delivered and how many
+// were not; failures are rescheduled with backoff. Once a sink fails, its
+// other notifications are left for the next pass rather than each waiting
+// out the sink's timeout, and the pass stops when ctx is done.
+func retryQueued(ctx context.Context, db *store.DB, rep *reporter.Multi, maxAge time.Duration, now time.Time, limit int) (sent, failed int) {
+	clock := now
+	if clock.IsZero() {
+		clock = time.Now()
+	}
+	if n, err := db.ExpireNotifications(clock.Add(-maxAge)); err != nil {
+		slog.Error("failed to expire queued notifications", "error", err)
+	} else if n > 0 {
+		slog.Warn("gave up on queued notifications", "count", n, "max_age", maxAge)
+	}
+
+	queued, err := db.DueNotifications(now, limit)
+	if err != nil {
+		slog.Error("failed to load queued notifications", "error", err)
+		return 0, 0
+	}
+	down := make(map[string]bool)
+	for _, q := range queued {
+		if down[q.Sink] || ctx.Err() != nil {
+			failed++
+			continue
+		}
+		ev, err := db.GetEvent(q.EventID)
+		if err != nil {
+			slog.Error("failed to load event for queued notification", "event_id", q.EventID, "error", err)
+			continue
+		}
+		if ev == nil {
+			// Purged by retention; there is nothing left to send.
+			_ = db.DeleteNotification(q.ID)
+			continue
+		}
+
+		ev.Summary = q.Summary
+		t := reporter.Transition{Kind: reporter.TransitionKind(q.Transition), Count: q.Count}
+		if err := rep.Retry(ctx, q.Sink, t, ev); err != nil {
+			failed++
+			down[q.Sink] = true
+			next := clock.Add(retryDelay(q.Attempts + 1))
+			slog.Warn("notification retry failed", "sink", q.Sink, "event_id", q.EventID,
+				"attempts", q.Attempts+1, "next", next, "error", err)
+			selfstat.ReporterFailure()
+			if err := db.RescheduleNotification(q.ID, next, err.Error()); err != nil {
+				slog.Error("failed to reschedule queued notification", "error", err)
+			}
+			continue
+		}
+		sent++
+		slog.Info("queued notification sent", "sink", q.Sink, "event_id", q.EventID, "attempts", q.Attempts+1)
+		if err := db.DeleteNotification(q.ID); err != nil {
+			slog.Error("failed to dequeue notification", "error", err)
+		}
+		_ = db.MarkNotified(ev.ID)
+	}
+	return sent, failed
+}
+
+// --- retry-notifications subcommand ---
+
+// runRetryNotifications resends every queued notification now, ignoring
+// backoff, or lists the queue with --list.
+func runRetryNotifications(args []string) {
+	fs := flag.NewFlagSet("retry-notifications", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	list := fs.Bool("list", false, "list queued notifications without sending them")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if *list {
+		queued, err := db.DueNotifications(time.Time{}, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error listing queued notifications: %v\n", err)
+			os.Exit(1)
+		}
+		if len(queued) == 0 {
+			fmt.Println("No queued notifications.")
+			return
+		}
+		for _, q := range queued {
+			fmt.Printf("%s  %-8s %-10s attempts=%d next=%s  %s\n    last error: %s\n",
+				q.QueuedAt.Local().Format("2006-01-02 15:04:05"),
+				q.Sink, q.Transition, q.Attempts,
+				q.NextAttempt.Local().Format("15:04:05"),
+				q.Summary, q.LastError,
+			)
+		}
+		return
+	}
+
+	maxAge := cfg.Notify.RetryMaxAge.Duration
+	if maxAge <= 0 {
+		maxAge = 100 * 365 * 24 * time.Hour // queue disabled: keep what is there
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	rep := newReporter(cfg)
+	rep.OnAttempt(logAttempts(db))
+	sent, failed := retryQueued(ctx, db, rep, maxAge, time.Time{}, 0)
+	fmt.Printf("Sent %d queued notifications, %d were not sent and remain queued.\n", sent, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
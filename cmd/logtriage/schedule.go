@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/reporter"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// heldNotification is a notification held back until quiet hours end.
+type heldNotification struct {
+	reporter.Notification
+	until time.Time
+}
+
+// hold holds back the notification of ev if it falls in quiet hours (see
+// config.ScheduleConfig), reporting whether it did and until when.
+func (p *pipeline) hold(t reporter.Transition, ev *event.Event) (time.Time, bool) {
+	until, quiet := p.cfg.Schedule.QuietUntil(string(ev.Tier), string(ev.Severity), time.Now())
+	if !quiet {
+		return time.Time{}, false
+	}
+	p.held = append(p.held, heldNotification{
+		Notification: reporter.Notification{Transition: t, Event: ev},
+		until:        until,
+	})
+	slog.Info("notification held for quiet hours", "tier", ev.Tier, "summary", ev.Summary, "until", until)
+	return until, true
+}
+
+// releaseHeld delivers the held notifications whose quiet hours have ended
+// by now, as one summary per sink. Sinks that fail have them queued for
+// retry.
+func (p *pipeline) releaseHeld(ctx context.Context, now time.Time) {
+	var due []reporter.Notification
+	kept := p.held[:0]
+	for _, h := range p.held {
+		if now.Before(h.until) {
+			kept = append(kept, h)
+		} else {
+			due = append(due, h.Notification)
+		}
+	}
+	p.held = kept
+	if len(due) == 0 {
+		return
+	}
+
+	slog.Info("quiet hours ended, sending held notifications", "count", len(due))
+	err := p.rep.ReportBatch(ctx, due)
+	if err != nil {
+		slog.Error("failed to send held notifications", "error", err)
+		selfstat.ReporterFailure()
+	}
+	failed := reporter.FailedSinks(err)
+	for _, n := range due {
+		if len(failed) > 0 {
+			p.queueRetry(failed, n.Transition, n.Event, err)
+		} else {
+			_ = p.db.MarkNotified(n.Event.ID)
+		}
+	}
+}
+
+// saveHeld keeps the held notifications across a restart by moving them to
+// the retry queue, due when their quiet hours end. Without a retry queue
+// they are sent now instead.
+func (p *pipeline) saveHeld(ctx context.Context) {
+	if len(p.held) == 0 {
+		return
+	}
+	if p.cfg.Notify.RetryMaxAge.Duration <= 0 {
+		for i := range p.held {
+			p.held[i].until = time.Time{}
+		}
+		p.releaseHeld(ctx, time.Now())
+		return
+	}
+
+	now := time.Now()
+	for _, h := range p.held {
+		for _, sink := range p.rep.Sinks() {
+			err := p.db.QueueNotification(&store.QueuedNotification{
+				EventID:     h.Event.ID,
+				Sink:        sink,
+				Transition:  string(h.Transition.Kind),
+				Count:       h.Transition.Count,
+				Summary:     h.Event.Summary,
+				QueuedAt:    now,
+				NextAttempt: h.until,
+				LastError:   "held for quiet hours",
+			})
+			if err != nil {
+				slog.Error("failed to queue held notification", "sink", sink, "error", err)
+			}
+		}
+	}
+	slog.Info("queued held notifications until quiet hours end", "count", len(p.held))
+	p.held = nil
+}
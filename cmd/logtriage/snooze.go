@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// --- snooze subcommand ---
+
+// runSnooze holds back notifications for a while, e.g. during planned
+// maintenance, optionally only for one tier or unit. The running daemon
+// honors snoozes from the next event on; events are still stored.
+func runSnooze(args []string) {
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	forFlag := fs.String("for", "", "how long to snooze notifications (e.g. 30m, 2h, 1d)")
+	tier := fs.String("tier", "", "only snooze this tier (T0-T9)")
+	unit := fs.String("unit", "", "only snooze this systemd unit")
+	instance := fs.String("instance", "", "only snooze this instance ID (on a hub)")
+	list := fs.Bool("list", false, "list active snoozes")
+	clearFlag := fs.Bool("clear", false, "end snoozes early: all, or those for --tier and --unit")
+	fs.Parse(args)
+
+	if *list && *clearFlag || (*forFlag != "") == (*list || *clearFlag) {
+		fmt.Fprintln(os.Stderr, "usage: logtriage snooze --for <duration> [--tier T3] [--unit nginx.service] | --list | --clear")
+		os.Exit(1)
+	}
+	t := event.Tier(strings.ToUpper(*tier))
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	switch {
+	case *list:
+		snoozes, err := db.ActiveSnoozes(now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error listing snoozes: %v\n", err)
+			os.Exit(1)
+		}
+		if len(snoozes) == 0 {
+			fmt.Println("No active snoozes.")
+			return
+		}
+		for _, s := range snoozes {
+			fmt.Printf("%3d  until %s (%s left)  %s\n", s.ID, s.Until.Local().Format("2006-01-02 15:04"),
+				format.Duration(s.Until.Sub(now)), snoozeScope(s))
+		}
+
+	case *clearFlag:
+		n, err := db.ClearSnoozes(t, *unit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error clearing snoozes: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Cleared %d snooze(s).\n", n)
+
+	default:
+		d, err := parseDuration(*forFlag)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid --for value %q: want a positive duration such as 2h\n", *forFlag)
+			os.Exit(1)
+		}
+		s := &store.Snooze{InstanceID: *instance, Tier: t, Unit: *unit, CreatedAt: now, Until: now.Add(d)}
+		if s.ID, err = db.AddSnooze(s); err != nil {
+			fmt.Fprintf(os.Stderr, "error adding snooze: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Snoozed %s until %s (snooze %d).\n", snoozeScope(s), s.Until.Local().Format("2006-01-02 15:04"), s.ID)
+	}
+}
+
+// snoozeScope describes which notifications a snooze holds back.
+func snoozeScope(s *store.Snooze) string {
+	var parts []string
+	if s.Tier != "" {
+		parts = append(parts, fmt.Sprintf("%s (%s)", s.Tier, s.Tier.Label()))
+	}
+	if s.Unit != "" {
+		parts = append(parts, s.Unit)
+	}
+	if s.InstanceID != "" {
+		parts = append(parts, "on "+s.InstanceID)
+	}
+	if len(parts) == 0 {
+		return "all notifications"
+	}
+	return strings.Join(parts, " ")
+}
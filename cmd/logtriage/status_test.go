@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/cooldown"
+	"github.com/setevik/logtriage/internal/enricher"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+	"github.com/setevik/logtriage/internal/suppress"
+)
+
+func TestMutedEventLeavesStatusOK(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[db]
+path = "`+filepath.Join(dir, "events.db")+`"
+
+[[suppress.rules]]
+name = "benign"
+message = "known benign"
+`), 0o644)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup, err := suppress.New(cfg.Suppress.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd, err := cooldown.New(cfg.Cooldown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	opts := enricherOptions(cfg)
+	opts.Replay = true
+	p := &pipeline{
+		cfg:      cfg,
+		enr:      enricher.New(opts),
+		db:       db,
+		sup:      sup,
+		cooldown: cd,
+		quiet:    true,
+		stats:    newPipelineStats(),
+	}
+
+	status := func() int {
+		t.Helper()
+		open, err := db.ListIncidents(store.IncidentFilter{OpenOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := assessHealth(open, 0, false, "1h")
+		return code
+	}
+
+	muted := event.New(cfg.Instance.ID, time.Now(), event.TierKernelHW, event.SevCritical, "Kernel: known benign fault")
+	p.handle(context.Background(), muted)
+	if muted.RawFields["_suppressed"] != "benign" {
+		t.Fatalf("event not suppressed: %v", muted.RawFields)
+	}
+	if code := status(); code != statusOK {
+		t.Errorf("status after a muted critical event = %d, want OK", code)
+	}
+
+	ev := event.New(cfg.Instance.ID, time.Now(), event.TierKernelHW, event.SevCritical, "Kernel: machine check")
+	p.handle(context.Background(), ev)
+	if code := status(); code != statusCritical {
+		t.Errorf("status after a critical event = %d, want CRITICAL", code)
+	}
+}
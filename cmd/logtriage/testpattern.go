@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/suppress"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// --- test-pattern subcommand ---
+
+// shortLineRe matches a line of journalctl's short or short-iso output (or
+// a classic syslog file): timestamp, host, identifier[pid]: message.
+var shortLineRe = regexp.MustCompile(`^(?:[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\S+) \S+ ([^\s\[:]+)(?:\[(\d+)\])?: (.*)$`)
+
+// runTestPattern prints how each input line would be classified, with the
+// user rules and suppression rules from the config, so rules can be
+// developed against sample lines instead of waiting for real failures.
+func runTestPattern(args []string) {
+	fs := flag.NewFlagSet("test-pattern", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	stdin := fs.Bool("stdin", false, "read lines from standard input")
+	file := fs.String("file", "", "read lines from this file")
+	identifier := fs.String("identifier", "kernel", "syslog identifier for plain message lines")
+	unit := fs.String("unit", "", "systemd unit for plain message lines")
+	matches := fs.Bool("matches", false, "print only lines that were classified or suppressed")
+	fs.Parse(args)
+
+	if *stdin == (*file != "") {
+		fmt.Fprintln(os.Stderr, "usage: logtriage test-pattern --stdin | --file <path>")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	cls := classifier.New(cfg.Instance.ID)
+	if err := cls.SetRules(cfg.Rules); err != nil {
+		fmt.Fprintf(os.Stderr, "error loading classification rules: %v\n", err)
+		os.Exit(1)
+	}
+	sup, err := suppress.New(cfg.Suppress.Rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading suppression rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	r := io.Reader(os.Stdin)
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var lines, suppressed, unmatched int
+	tiers := make(map[event.Tier]int)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines++
+
+		entry, err := testEntry(line, *identifier, *unit)
+		if err != nil {
+			fmt.Printf("%d: %s\n   invalid journal JSON: %v\n", lines, line, err)
+			continue
+		}
+		journalFields := maps.Clone(entry.Fields)
+
+		if name := sup.MatchEntry(entry); name != "" {
+			suppressed++
+			fmt.Printf("%d: %s\n   dropped before classification by suppression rule %q\n", lines, entry.Message, name)
+			continue
+		}
+
+		ev := cls.Classify(entry)
+		if ev == nil && cfg.Catchall.Enabled {
+			ev = cls.ClassifyUnclassified(entry, cfg.Catchall.MaxPriority)
+		}
+		if ev == nil {
+			unmatched++
+			if !*matches {
+				fmt.Printf("%d: %s\n   no match\n", lines, entry.Message)
+			}
+			continue
+		}
+		tiers[ev.Tier]++
+
+		fmt.Printf("%d: %s\n", lines, entry.Message)
+		fmt.Printf("   %s %s  %s\n", ev.Tier, ev.Severity, ev.Summary)
+		if rule := ev.RawFields["_rule"]; rule != "" {
+			fmt.Printf("   rule: %s\n", rule)
+		}
+		if ev.Tier == event.TierUnclassified {
+			fmt.Println("   stored by the catch-all, never alerted")
+		}
+		if name := sup.MatchEvent(ev); name != "" {
+			fmt.Printf("   muted by suppression rule %q (stored, not notified)\n", name)
+		}
+		var fields []string
+		if ev.Process != "" {
+			fields = append(fields, "process="+ev.Process)
+		}
+		if ev.PID != 0 {
+			fields = append(fields, fmt.Sprintf("pid=%d", ev.PID))
+		}
+		if ev.Unit != "" {
+			fields = append(fields, "unit="+ev.Unit)
+		}
+		if ev.ContainerName != "" {
+			fields = append(fields, "container="+ev.ContainerName)
+		}
+		for _, k := range slices.Sorted(maps.Keys(ev.RawFields)) {
+			if _, ok := journalFields[k]; !ok && k != "_rule" {
+				fields = append(fields, k+"="+ev.RawFields[k])
+			}
+		}
+		if len(fields) > 0 {
+			fmt.Printf("   %s\n", strings.Join(fields, " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "error reading input: %v\n", err)
+		os.Exit(1)
+	}
+
+	var classified int
+	var counts []string
+	for _, tier := range slices.Sorted(maps.Keys(tiers)) {
+		classified += tiers[tier]
+		counts = append(counts, fmt.Sprintf("%s %d", tier, tiers[tier]))
+	}
+	fmt.Printf("\n%d lines: %d classified", lines, classified)
+	if len(counts) > 0 {
+		fmt.Printf(" (%s)", strings.Join(counts, ", "))
+	}
+	fmt.Printf(", %d suppressed, %d unmatched.\n", suppressed, unmatched)
+}
+
+// testEntry turns an input line into a journal entry: a line of journalctl
+// -o json output as is, a short-format line by its identifier and PID, and
+// anything else as a message from the given identifier and unit, at error
+// priority.
+func testEntry(line, identifier, unit string) (watcher.JournalEntry, error) {
+	if strings.HasPrefix(line, "{") {
+		return watcher.ParseJournalJSON([]byte(line))
+	}
+	entry := watcher.JournalEntry{
+		Message:          line,
+		Priority:         3,
+		SyslogIdentifier: identifier,
+		SystemdUnit:      unit,
+	}
+	if m := shortLineRe.FindStringSubmatch(line); m != nil {
+		entry.SyslogIdentifier, entry.PID, entry.Message = m[1], m[2], m[3]
+	}
+	if entry.SyslogIdentifier == "kernel" {
+		entry.Transport = "kernel"
+	}
+	entry.Fields = map[string]string{
+		"MESSAGE":           entry.Message,
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": entry.SyslogIdentifier,
+		"_PID":              entry.PID,
+		"_SYSTEMD_UNIT":     entry.SystemdUnit,
+		"_TRANSPORT":        entry.Transport,
+	}
+	return entry, nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// importBatch is how many events import writes per transaction.
+const importBatch = 500
+
+// --- export and import subcommands ---
+
+// runExport writes stored events, with their IDs, incidents, and notified
+// flags, as JSON lines or CSV that logtriage import reads back on another
+// instance, e.g. to seed a hub from its agents or move to a new database.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	sinceFlag := fs.String("since", "", "only events since: a date (2024-05-01), a date and time (2024-05-01 14:00), or a duration ago (e.g. 30d)")
+	untilFlag := fs.String("until", "", "only events until, in the same forms as --since")
+	instance := fs.String("instance", "", "only events of this instance ID")
+	formatFlag := fs.String("format", "jsonl", "output format: jsonl or csv")
+	out := fs.String("out", "-", "file to write (- for stdout)")
+	fs.Parse(args)
+
+	if *formatFlag != "jsonl" && *formatFlag != "csv" {
+		fmt.Fprintf(os.Stderr, "invalid --format %q: must be jsonl or csv\n", *formatFlag)
+		os.Exit(1)
+	}
+	filter := store.QueryFilter{InstanceID: *instance}
+	now := time.Now()
+	var err error
+	if *sinceFlag != "" {
+		if filter.Since, err = parseReplayTime(*sinceFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *untilFlag != "" {
+		if filter.Until, err = parseReplayTime(*untilFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	records, err := db.Export(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		if w, err = os.Create(*out); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	err = writeRecords(w, records, *formatFlag)
+	if err == nil && w != os.Stdout {
+		err = w.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing export: %v\n", err)
+		os.Exit(1)
+	}
+	if w != os.Stdout {
+		fmt.Fprintf(os.Stderr, "Exported %d event(s) to %s.\n", len(records), *out)
+	}
+}
+
+// writeRecords writes records as JSON lines or CSV.
+func writeRecords(w io.Writer, records []*store.Record, format string) error {
+	bw := bufio.NewWriter(w)
+	if format == "csv" {
+		cw := csv.NewWriter(bw)
+		cw.Write(store.RecordCSVHeader)
+		for _, r := range records {
+			cw.Write(r.CSVRecord())
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	} else {
+		enc := json.NewEncoder(bw)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// runImport stores the events of a logtriage export file. Events already
+// in the database, by ID, are skipped, so an import can be repeated.
+// Imported events are not notified.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	formatFlag := fs.String("format", "", "input format: jsonl or csv (default: from the file name, or its first byte)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: logtriage import [--config path] [--format jsonl|csv] file")
+		os.Exit(1)
+	}
+	path := fs.Arg(0)
+	switch *formatFlag {
+	case "", "jsonl", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --format %q: must be jsonl or csv\n", *formatFlag)
+		os.Exit(1)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	br := bufio.NewReader(in)
+	format := *formatFlag
+	if format == "" {
+		format = sniffFormat(path, br)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	read, stored := 0, 0
+	var batch []*store.Record
+	flush := func() error {
+		n, err := db.Import(batch)
+		stored += n
+		batch = batch[:0]
+		return err
+	}
+	err = readRecords(br, format, func(r *store.Record) error {
+		read++
+		if batch = append(batch, r); len(batch) == importBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import error after %d event(s): %v\n", stored, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d event(s); %d already stored.\n", stored, read-stored)
+}
+
+// sniffFormat picks the format of an import file: csv for a .csv file or
+// content that does not start with a JSON object, else jsonl.
+func sniffFormat(path string, br *bufio.Reader) string {
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return "csv"
+	}
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return "jsonl"
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case '{':
+			return "jsonl"
+		default:
+			return "csv"
+		}
+	}
+}
+
+// readRecords calls fn with each record of an export, in order.
+func readRecords(r io.Reader, format string, fn func(*store.Record) error) error {
+	if format == "csv" {
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return fmt.Errorf("reading CSV header: %w", err)
+		}
+		for n := 1; ; n++ {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			rec, err := store.ParseCSVRecord(header, row)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", n, err)
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var rec store.Record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if rec.Event == nil || rec.ID == "" {
+			return fmt.Errorf("record %d: no event id", n)
+		}
+		if rec.RawFields == nil {
+			rec.RawFields = make(map[string]string)
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
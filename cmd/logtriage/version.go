@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/monitor"
+	"github.com/setevik/logtriage/internal/store"
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// Set at build time via -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// --- version subcommand ---
+
+// buildInfo describes how this binary was built and what it can do on the
+// current host.
+type buildInfo struct {
+	Version string          `json:"version"`
+	Commit  string          `json:"commit,omitempty"`
+	Date    string          `json:"date,omitempty"`
+	Go      string          `json:"go"`
+	OS      string          `json:"os"`
+	Arch    string          `json:"arch"`
+	CGO     bool            `json:"cgo"`
+	Tags    []string        `json:"tags"`
+	SQLite  string          `json:"sqlite"` // the driver, or "none" without cgo
+	Stores  []string        `json:"stores"` // the db.driver values this build can open
+	NVML    bool            `json:"nvml"`   // NVIDIA GPUs are read through NVML rather than nvidia-smi
+	Tools   map[string]bool `json:"tools"`
+}
+
+// readBuildInfo collects build metadata. Commit and date fall back to the
+// VCS stamp Go embeds when they were not injected by the Makefile.
+func readBuildInfo() buildInfo {
+	info := buildInfo{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+		Go:      runtime.Version(),
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Tags:    []string{},
+		SQLite:  store.Driver,
+		Stores:  []string{"sqlite", "postgres"},
+		NVML:    monitor.NVML,
+	}
+	if info.SQLite == "" {
+		info.SQLite = "none"
+		info.Stores = []string{"postgres"}
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "CGO_ENABLED":
+				info.CGO = s.Value == "1"
+			case "-tags":
+				if s.Value != "" {
+					info.Tags = strings.Split(s.Value, ",")
+				}
+			case "GOARM":
+				info.Arch += "/v" + s.Value
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print build metadata as JSON")
+	fs.Parse(args)
+
+	info := readBuildInfo()
+
+	if *asJSON {
+		info.Tools = sysdep.Available()
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding version: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("logtriage", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("  commit: %s %s\n", info.Commit, info.Date)
+	}
+	fmt.Printf("  built:  %s %s/%s, sqlite: %s\n", info.Go, info.OS, info.Arch, info.SQLite)
+	if store.Driver == "" {
+		fmt.Println("  built without cgo: events can only be stored in PostgreSQL")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/cooldown"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// decide records the notification decision for ev, for `logtriage why`.
+func (p *pipeline) decide(ev *event.Event, dec *store.Decision) {
+	dec.EventID = ev.ID
+	dec.DecidedAt = time.Now()
+	p.stats.decision(dec.Outcome)
+	if err := p.db.RecordDecision(dec); err != nil {
+		slog.Error("failed to record notification decision", "error", err)
+	}
+}
+
+// mutedDecision explains an event silenced by a suppression rule or as a
+// repeat crash of a known-crashy process.
+func mutedDecision(ev *event.Event) *store.Decision {
+	dec := &store.Decision{Outcome: store.DecisionMuted}
+	if rule := ev.RawFields["_suppressed"]; rule != "known_crashy" {
+		dec.Reason = fmt.Sprintf("matched suppression rule %q", rule)
+	} else {
+		dec.Reason = fmt.Sprintf("%s is listed in crashes.known_crashy and this crash signature was seen before", ev.Process)
+	}
+	return dec
+}
+
+// cooldownDecision explains the outcome of a cooldown check made with cd.
+func cooldownDecision(dedup store.DedupResult, cd cooldown.Setting) *store.Decision {
+	threshold := cd.Threshold
+	dec := &store.Decision{
+		RecentCount: dedup.RecentCount,
+		Threshold:   threshold,
+		Window:      cd.Window,
+		WindowStart: dedup.WindowStart,
+		Key:         dedup.Key,
+	}
+	since := dedup.WindowStart.Local().Format("15:04:05")
+	switch {
+	case dedup.Aggregated:
+		dec.Outcome = store.DecisionAggregated
+		dec.Reason = fmt.Sprintf("%d similar events since %s reached the aggregate threshold of %d",
+			dedup.RecentCount, since, threshold)
+	case dedup.Escalated:
+		dec.Outcome = store.DecisionEscalated
+		dec.Reason = fmt.Sprintf("more severe than the %d similar event(s) since %s", dedup.RecentCount, since)
+	case dedup.ShouldAlert:
+		dec.Outcome = store.DecisionAlerted
+		dec.Reason = fmt.Sprintf("no similar event since %s", since)
+	case dedup.RecentCount < threshold:
+		dec.Outcome = store.DecisionSuppressed
+		dec.Reason = fmt.Sprintf("%d similar event(s) since %s were already alerted; an aggregate alert goes out when they reach %d",
+			dedup.RecentCount, since, threshold)
+	default:
+		dec.Outcome = store.DecisionSuppressed
+		dec.Reason = fmt.Sprintf("%d similar events since %s; the aggregate alert was already sent at %d",
+			dedup.RecentCount, since, threshold)
+	}
+	return dec
+}
+
+// --- why subcommand ---
+
+// runWhy explains why an event was or was not notified.
+func runWhy(args []string) {
+	fs := flag.NewFlagSet("why", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: logtriage why <event-id>")
+		os.Exit(1)
+	}
+	id := fs.Arg(0)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging("error") // quiet for CLI output
+
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ev, err := db.GetEvent(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading event: %v\n", err)
+		os.Exit(1)
+	}
+	if ev == nil {
+		fmt.Fprintf(os.Stderr, "no event %s\n", id)
+		os.Exit(1)
+	}
+	dec, err := db.GetDecision(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading decision: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Event:     %s\n", ev.ID)
+	fmt.Printf("           %s  [%s] %s\n", ev.Timestamp.Local().Format("2006-01-02 15:04:05"), ev.Tier, ev.Summary)
+	fmt.Printf("Instance:  %s\n", ev.InstanceID)
+	if dec == nil {
+		if ev.Tier == event.TierUnclassified {
+			fmt.Println("Outcome:   not notified: unclassified events only feed the digest")
+		} else {
+			fmt.Println("Outcome:   unknown: no decision was recorded for this event")
+		}
+		return
+	}
+
+	fmt.Printf("Outcome:   %s\n", dec.Outcome)
+	fmt.Printf("Reason:    %s\n", dec.Reason)
+	if dec.Window > 0 {
+		key := dec.Key
+		if key == "" {
+			key = "tier only"
+		}
+		fmt.Printf("Dedup key: %s, %s\n", ev.Tier, key)
+		fmt.Printf("Cooldown:  %s window, aggregate threshold %d\n", format.Duration(dec.Window), dec.Threshold)
+		start := dec.WindowStart.Local().Format("2006-01-02 15:04:05")
+		if dec.WindowStart.After(ev.Timestamp.Add(-dec.Window)) {
+			start += " (after a recovery)"
+		}
+		fmt.Printf("Counted:   %d similar event(s) since %s\n", dec.RecentCount, start)
+	}
+	if dec.Error != "" {
+		fmt.Printf("Error:     %s\n", dec.Error)
+	}
+	fmt.Printf("Decided:   %s\n", dec.DecidedAt.Local().Format("2006-01-02 15:04:05"))
+
+	queued, err := db.DueNotifications(time.Time{}, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading queued notifications: %v\n", err)
+		os.Exit(1)
+	}
+	for _, q := range queued {
+		if q.EventID == ev.ID {
+			fmt.Printf("Queued:    %s, attempts=%d, next %s: %s\n", q.Sink, q.Attempts,
+				q.NextAttempt.Local().Format("2006-01-02 15:04:05"), q.LastError)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package boot detects kernel panics and unexpected reboots by inspecting
+// the tail of the previous boot's journal for a clean-shutdown marker.
+package boot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+const (
+	// tailEntries is how many journal entries of the previous boot are read.
+	tailEntries = 300
+	// maxTailLines caps the last messages included in a report.
+	maxTailLines = 20
+)
+
+// cleanShutdownRe matches messages systemd, logind, and journald log during
+// an orderly shutdown or reboot.
+var cleanShutdownRe = regexp.MustCompile(`(?i)Reached target (System )?(Power[- ]?Off|Reboot|Halt|Shutdown|kexec)|^Shutting down\.?$|Journal stopped|System is (powering down|rebooting|halting)`)
+
+// panicRe matches kernel messages that explain a crash.
+var panicRe = regexp.MustCompile(`Kernel panic - not syncing|\bBUG: |\bOops\b|general protection fault|hard LOCKUP|soft lockup|Machine check events logged|\[Hardware Error\]`)
+
+// Report describes how the previous boot ended.
+type Report struct {
+	BootID    string
+	LastEntry time.Time // time of the previous boot's last journal entry
+	Clean     bool      // a clean-shutdown marker was found
+	Panic     bool      // a kernel panic or oops was found
+	Reason    string
+	Tail      []string // last kernel messages (or last messages) before the end
+	Pstore    []string // crash dumps left in /sys/fs/pstore
+}
+
+// Summary returns a one-line description suitable for an event summary.
+func (r *Report) Summary() string {
+	if r.Panic {
+		return "Kernel panic before reboot"
+	}
+	return "Unexpected reboot"
+}
+
+// Detail returns a multi-line description with the last messages.
+func (r *Report) Detail() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Reason)
+	if !r.LastEntry.IsZero() {
+		fmt.Fprintf(&b, "Previous boot's last entry: %s\n", r.LastEntry.Local().Format("2006-01-02 15:04:05"))
+	}
+	if len(r.Pstore) > 0 {
+		fmt.Fprintf(&b, "pstore crash records: %s\n", strings.Join(r.Pstore, ", "))
+	}
+	if len(r.Tail) > 0 {
+		b.WriteString("\nLast messages:\n")
+		for _, line := range r.Tail {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// CheckOnce analyzes the previous boot the first time it is called during
+// the current boot. The current boot ID is recorded in stateFile so daemon
+// restarts do not report the same reboot again. It returns nil when the
+// previous boot was already checked, ended cleanly, or is not in the
+// journal (e.g. the journal is not persistent).
+func CheckOnce(ctx context.Context, stateFile string) (*Report, error) {
+	current, err := readBootID("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return nil, fmt.Errorf("reading boot id: %w", err)
+	}
+	if prev, err := os.ReadFile(stateFile); err == nil && strings.TrimSpace(string(prev)) == current {
+		return nil, nil
+	}
+
+	report, err := Analyze(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(stateFile, []byte(current+"\n"), 0o640); err != nil {
+		slog.Warn("failed to record checked boot", "error", err)
+	}
+	if report == nil || report.Clean {
+		return nil, nil
+	}
+	return report, nil
+}
+
+// Analyze reads the tail of the previous boot's journal and pstore and
+// reports how that boot ended. It returns nil if the previous boot is not
+// available.
+func Analyze(ctx context.Context) (*Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "journalctl", "-b", "-1", "-n", strconv.Itoa(tailEntries), "-o", "json", "--no-pager")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Volatile journals and first boots have no previous boot.
+		slog.Debug("previous boot not available", "error", err, "stderr", strings.TrimSpace(stderr.String()))
+		return nil, nil
+	}
+
+	var entries []watcher.JournalEntry
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry, err := watcher.ParseJournalJSON(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return analyze(entries, readPstore("/sys/fs/pstore")), nil
+}
+
+// analyze decides how a boot ended from its last journal entries, oldest
+// first, and any pstore records.
+func analyze(entries []watcher.JournalEntry, pstore map[string]string) *Report {
+	r := &Report{
+		BootID: entries[len(entries)-1].Fields["_BOOT_ID"],
+	}
+	r.LastEntry = entries[len(entries)-1].Time()
+
+	var kernel, all []string
+	for _, e := range entries {
+		line := formatEntry(e)
+		all = append(all, line)
+		if e.Transport == "kernel" {
+			kernel = append(kernel, line)
+		}
+		switch {
+		case cleanShutdownRe.MatchString(e.Message):
+			// Only a panic after the shutdown began counts; earlier oopses
+			// were reported live and the system went down cleanly.
+			r.Clean = true
+			r.Panic = false
+			r.Reason = ""
+		case !r.Panic && panicRe.MatchString(e.Message):
+			r.Clean = false
+			r.Panic = true
+			r.Reason = "Kernel reported: " + e.Message
+		}
+	}
+
+	// pstore records survive a panic that never reached the journal. After
+	// a clean shutdown they are stale and ignored.
+	if !r.Clean {
+		for name := range pstore {
+			r.Pstore = append(r.Pstore, name)
+		}
+		sort.Strings(r.Pstore)
+		for _, name := range r.Pstore {
+			if m := panicRe.FindString(pstore[name]); m != "" && !r.Panic {
+				r.Panic = true
+				r.Reason = fmt.Sprintf("Kernel reported (pstore %s): %s", name, lineContaining(pstore[name], m))
+			}
+		}
+	}
+
+	if !r.Clean && r.Reason == "" {
+		r.Reason = "No clean shutdown recorded (power loss, hard hang, or watchdog reset)"
+	}
+
+	r.Tail = kernel
+	if len(r.Tail) == 0 {
+		r.Tail = all
+	}
+	if len(r.Tail) > maxTailLines {
+		r.Tail = r.Tail[len(r.Tail)-maxTailLines:]
+	}
+	return r
+}
+
+// readPstore returns the contents of crash records in dir, keyed by name.
+func readPstore(dir string) map[string]string {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	out := make(map[string]string)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		out[f.Name()] = string(data)
+	}
+	return out
+}
+
+func readBootID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func formatEntry(e watcher.JournalEntry) string {
+	ts := ""
+	if t := e.Time(); !t.IsZero() {
+		ts = t.Local().Format("15:04:05") + " "
+	}
+	ident := e.SyslogIdentifier
+	if ident == "" {
+		ident = e.Transport
+	}
+	return fmt.Sprintf("%s%s: %s", ts, ident, e.Message)
+}
+
+func lineContaining(content, substr string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, substr) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return substr
+}
@@ -0,0 +1,110 @@
+package boot
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+var base = time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC)
+
+func entry(offset time.Duration, transport, ident, msg string) watcher.JournalEntry {
+	return watcher.JournalEntry{
+		Message:           msg,
+		SyslogIdentifier:  ident,
+		Transport:         transport,
+		RealtimeTimestamp: strconv.FormatInt(base.Add(offset).UnixMicro(), 10),
+		Fields:            map[string]string{"_BOOT_ID": "prevboot"},
+	}
+}
+
+func TestAnalyzeCleanShutdown(t *testing.T) {
+	r := analyze([]watcher.JournalEntry{
+		entry(0, "journal", "sshd", "Accepted publickey for admin"),
+		entry(time.Second, "journal", "systemd-logind", "System is rebooting."),
+		entry(2*time.Second, "journal", "systemd", "Reached target System Reboot."),
+		entry(3*time.Second, "driver", "systemd-journald", "Journal stopped"),
+	}, map[string]string{"dmesg-ramoops-0": "Kernel panic - not syncing: stale"})
+
+	if !r.Clean || r.Panic {
+		t.Errorf("clean=%v panic=%v, want clean", r.Clean, r.Panic)
+	}
+	if len(r.Pstore) != 0 {
+		t.Errorf("stale pstore records should be ignored after a clean shutdown: %v", r.Pstore)
+	}
+}
+
+func TestAnalyzePowerLoss(t *testing.T) {
+	r := analyze([]watcher.JournalEntry{
+		entry(0, "kernel", "kernel", "ata1: link is slow to respond"),
+		entry(time.Minute, "journal", "smbd", "connection from 10.0.0.5"),
+	}, nil)
+
+	if r.Clean || r.Panic {
+		t.Fatalf("clean=%v panic=%v, want unclean without panic", r.Clean, r.Panic)
+	}
+	if r.BootID != "prevboot" || !r.LastEntry.Equal(base.Add(time.Minute)) {
+		t.Errorf("boot=%q last=%v", r.BootID, r.LastEntry)
+	}
+	if !strings.Contains(r.Reason, "No clean shutdown") {
+		t.Errorf("reason = %q", r.Reason)
+	}
+	// Kernel messages are preferred for the tail.
+	if len(r.Tail) != 1 || !strings.Contains(r.Tail[0], "ata1") {
+		t.Errorf("tail = %v", r.Tail)
+	}
+	if r.Summary() != "Unexpected reboot" {
+		t.Errorf("summary = %q", r.Summary())
+	}
+}
+
+func TestAnalyzePanic(t *testing.T) {
+	entries := []watcher.JournalEntry{
+		// An earlier oops followed by a clean shutdown would not count, but
+		// here the system later panics for good.
+		entry(0, "kernel", "kernel", "BUG: unable to handle page fault"),
+		entry(time.Second, "journal", "systemd", "Reached target System Power Off."),
+		entry(2*time.Second, "kernel", "kernel", "Kernel panic - not syncing: Fatal exception"),
+	}
+	for i := 0; i < 30; i++ {
+		entries = append(entries, entry(3*time.Second, "kernel", "kernel", "---[ end trace ]---"))
+	}
+
+	r := analyze(entries, nil)
+	if r.Clean || !r.Panic {
+		t.Fatalf("clean=%v panic=%v, want panic", r.Clean, r.Panic)
+	}
+	if !strings.Contains(r.Reason, "Fatal exception") {
+		t.Errorf("reason = %q", r.Reason)
+	}
+	if len(r.Tail) != maxTailLines {
+		t.Errorf("tail has %d lines, want %d", len(r.Tail), maxTailLines)
+	}
+	if !strings.Contains(r.Detail(), "Last messages:") {
+		t.Errorf("detail = %q", r.Detail())
+	}
+}
+
+func TestAnalyzeOopsThenCleanShutdown(t *testing.T) {
+	r := analyze([]watcher.JournalEntry{
+		entry(0, "kernel", "kernel", "BUG: soft lockup - CPU#2 stuck for 22s"),
+		entry(time.Hour, "journal", "systemd", "Reached target System Power Off."),
+	}, nil)
+	if !r.Clean || r.Panic {
+		t.Errorf("clean=%v panic=%v, want clean", r.Clean, r.Panic)
+	}
+}
+
+func TestAnalyzePstore(t *testing.T) {
+	r := analyze([]watcher.JournalEntry{
+		entry(0, "journal", "smbd", "connection from 10.0.0.5"),
+	}, map[string]string{
+		"dmesg-ramoops-0": "<0>[ 123.4] Kernel panic - not syncing: hung_task: blocked tasks\n<0>[ 123.5] CPU: 1 PID: 40",
+	})
+	if !r.Panic || !strings.Contains(r.Reason, "pstore dmesg-ramoops-0") || !strings.Contains(r.Reason, "hung_task") {
+		t.Errorf("panic=%v reason=%q", r.Panic, r.Reason)
+	}
+}
@@ -0,0 +1,304 @@
+// Package bundle writes a compressed diagnostic snapshot when a critical OOM
+// kill or hardware event is seen: the journal leading up to it, the kernel
+// ring buffer, memory stats, and GPU state. Unlike a debug capture, which
+// follows the journal for a while after an incident opens, a bundle records
+// what had already happened, so it is useful after the machine recovers or
+// reboots.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/monitor"
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// commandTimeout bounds each command run for a bundle.
+const commandTimeout = 30 * time.Second
+
+// suffix is the file name suffix of finished bundles.
+const suffix = ".tar.gz"
+
+// Writer writes bundles for critical events into a directory, one at a
+// time, and prunes old ones by count and age.
+type Writer struct {
+	cfg config.BundleConfig
+	dir string
+
+	mu     sync.Mutex
+	active bool
+	wg     sync.WaitGroup
+
+	// Overridable for testing.
+	now      func() time.Time
+	run      func(ctx context.Context, name string, args ...string) ([]byte, error)
+	procRoot string
+	gpuState func(ctx context.Context) string
+}
+
+// New creates a Writer that stores bundles in dir.
+func New(cfg config.BundleConfig, dir string) *Writer {
+	w := &Writer{
+		cfg:      cfg,
+		dir:      dir,
+		now:      time.Now,
+		run:      runCommand,
+		procRoot: "/proc",
+	}
+	w.gpuState = w.readGPUState
+	return w
+}
+
+// Wants reports whether ev gets a bundle: a critical OOM kill (T1) or
+// kernel/hardware event (T4).
+func Wants(ev *event.Event) bool {
+	if ev.Severity != event.SevCritical {
+		return false
+	}
+	return ev.Tier == event.TierOOMKill || ev.Tier == event.TierKernelHW
+}
+
+// Start begins writing a bundle for ev in the background and returns the
+// path it will have, so the path can go out with the notification. It
+// returns false if ev does not get a bundle or one is already being
+// written. Cancelling ctx stops the commands early; what was collected is
+// still saved.
+func (w *Writer) Start(ctx context.Context, ev *event.Event) (string, bool) {
+	if !Wants(ev) {
+		return "", false
+	}
+
+	w.mu.Lock()
+	if w.active {
+		w.mu.Unlock()
+		slog.Debug("diagnostic bundle skipped, another is being written", "summary", ev.Summary)
+		return "", false
+	}
+	w.active = true
+	w.mu.Unlock()
+
+	path := filepath.Join(w.dir, fmt.Sprintf("%s-%s-%s%s",
+		ev.Timestamp.UTC().Format("20060102-150405"), ev.Tier, ev.ID, suffix))
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if err := w.write(ctx, ev, path); err != nil {
+			slog.Error("failed to write diagnostic bundle", "path", path, "error", err)
+		} else {
+			slog.Info("diagnostic bundle written", "event_id", ev.ID, "path", path)
+		}
+		w.prune()
+		w.mu.Lock()
+		w.active = false
+		w.mu.Unlock()
+	}()
+	return path, true
+}
+
+// Wait blocks until any bundle being written has been saved.
+func (w *Writer) Wait() {
+	w.wg.Wait()
+}
+
+// write collects the bundle's files and writes them to path. The archive is
+// written under a temporary name and renamed, so a bundle that exists is
+// complete.
+func (w *Writer) write(ctx context.Context, ev *event.Event, path string) error {
+	if err := os.MkdirAll(w.dir, 0o700); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"event.txt", []byte(describe(ev))},
+		{"journal.txt", w.journal(ctx, ev)},
+		{"dmesg.txt", w.dmesg(ctx)},
+		{"meminfo.txt", w.readProc("meminfo")},
+		{"gpu.txt", []byte(w.gpuState(ctx))},
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op after the rename
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	mtime := w.now()
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0o600,
+			Size:    int64(len(file.data)),
+			ModTime: mtime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// journal returns every journal line from the configured window before ev
+// up to now.
+func (w *Writer) journal(ctx context.Context, ev *event.Event) []byte {
+	since := ev.Timestamp.Add(-w.cfg.Window.Duration)
+	return w.output(ctx, "journalctl", "--no-pager", "-o", "short-iso",
+		"--since", fmt.Sprintf("@%d", since.Unix()))
+}
+
+// dmesg returns the kernel ring buffer. dmesg is refused to unprivileged
+// users on many systems; the kernel messages of the current boot in the
+// journal are the fallback.
+func (w *Writer) dmesg(ctx context.Context) []byte {
+	cctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	out, err := w.run(cctx, "dmesg", "--ctime")
+	cancel()
+	if err == nil {
+		return out
+	}
+	slog.Debug("dmesg failed, reading kernel messages from the journal", "error", err)
+	return w.output(ctx, "journalctl", "--no-pager", "-o", "short-iso", "-k", "-b")
+}
+
+// output runs a command and returns its output, with any error appended.
+func (w *Writer) output(ctx context.Context, name string, args ...string) []byte {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	out, err := w.run(ctx, name, args...)
+	if err != nil {
+		out = append(out, fmt.Sprintf("\n[%s failed: %v]\n", name, err)...)
+	}
+	return out
+}
+
+func (w *Writer) readProc(name string) []byte {
+	data, err := os.ReadFile(filepath.Join(w.procRoot, name))
+	if err != nil {
+		return []byte(fmt.Sprintf("[%v]\n", err))
+	}
+	return data
+}
+
+// readGPUState describes every GPU from sysfs, plus the full nvidia-smi
+// report when it is installed.
+func (w *Writer) readGPUState(ctx context.Context) string {
+	gpus := monitor.DetectGPUs()
+	if len(gpus) == 0 {
+		return "No GPUs found.\n"
+	}
+	var b strings.Builder
+	for i := range gpus {
+		monitor.ReadGPUTemp(&gpus[i])
+		monitor.ReadGPUVRAM(&gpus[i])
+		monitor.ReadGPUFreq(&gpus[i])
+		b.WriteString(monitor.FormatGPUStatus(gpus[i]))
+		b.WriteString("\n\n")
+	}
+	if sysdep.Have("nvidia-smi") {
+		b.Write(w.output(ctx, "nvidia-smi", "-q"))
+	}
+	return b.String()
+}
+
+// prune deletes bundles older than the retention period, then the oldest
+// ones beyond the maximum count.
+func (w *Writer) prune() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		slog.Warn("listing diagnostic bundles failed", "error", err)
+		return
+	}
+
+	// Names start with the UTC event time, so they sort oldest first.
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), suffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	cutoff := w.now().Add(-w.cfg.Retention.Duration)
+	for i, name := range names {
+		path := filepath.Join(w.dir, name)
+		expired := false
+		if w.cfg.Retention.Duration > 0 {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		excess := w.cfg.MaxBundles > 0 && len(names)-i > w.cfg.MaxBundles
+		if !expired && !excess {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			slog.Warn("deleting diagnostic bundle failed", "path", path, "error", err)
+			continue
+		}
+		slog.Debug("deleted diagnostic bundle", "path", path)
+	}
+}
+
+// describe returns the event the bundle was written for as text.
+func describe(ev *event.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Event:    %s\n", ev.ID)
+	fmt.Fprintf(&b, "Instance: %s\n", ev.InstanceID)
+	fmt.Fprintf(&b, "Time:     %s\n", ev.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Tier:     %s (%s)\n", ev.Tier, ev.Tier.Label())
+	fmt.Fprintf(&b, "Severity: %s\n", ev.Severity)
+	fmt.Fprintf(&b, "Summary:  %s\n", ev.Summary)
+	if ev.Process != "" {
+		fmt.Fprintf(&b, "Process:  %s (pid %d)\n", ev.Process, ev.PID)
+	}
+	if ev.Unit != "" {
+		fmt.Fprintf(&b, "Unit:     %s\n", ev.Unit)
+	}
+	if ev.Detail != "" {
+		fmt.Fprintf(&b, "\n%s\n", ev.Detail)
+	}
+	return b.String()
+}
+
+// runCommand runs an external tool and returns its standard output.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if !sysdep.Have(name) {
+		return nil, fmt.Errorf("%s not installed", name)
+	}
+	return exec.CommandContext(ctx, name, args...).Output()
+}
@@ -0,0 +1,160 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func testWriter(t *testing.T) *Writer {
+	t.Helper()
+	proc := t.TempDir()
+	if err := os.WriteFile(filepath.Join(proc, "meminfo"), []byte("MemAvailable:    2097152 kB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(config.BundleConfig{
+		Enabled:    true,
+		Window:     config.Duration{Duration: 10 * time.Minute},
+		MaxBundles: 2,
+		Retention:  config.Duration{Duration: 24 * time.Hour},
+	}, filepath.Join(t.TempDir(), "bundles"))
+	w.procRoot = proc
+	w.gpuState = func(context.Context) string { return "card0 72°C\n" }
+	return w
+}
+
+// readBundle returns the files in a bundle by name.
+func readBundle(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+}
+
+func TestStartWritesBundle(t *testing.T) {
+	w := testWriter(t)
+	var calls []string
+	w.run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		switch name {
+		case "dmesg":
+			return nil, errors.New("operation not permitted")
+		case "journalctl":
+			return []byte("kernel: Out of memory: Killed process 4521 (java)\n"), nil
+		}
+		return nil, errors.New("unexpected command")
+	}
+
+	ev := event.New("h", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: java")
+	ev.Process, ev.PID = "java", 4521
+	path, ok := w.Start(context.Background(), ev)
+	if !ok {
+		t.Fatal("bundle not started")
+	}
+	w.Wait()
+
+	if !strings.HasPrefix(path, w.dir) || !strings.HasSuffix(path, "-T1-"+ev.ID+".tar.gz") {
+		t.Errorf("path = %q", path)
+	}
+	files := readBundle(t, path)
+	checks := map[string]string{
+		"event.txt":   "OOM Kill: java",
+		"journal.txt": "Killed process 4521",
+		"dmesg.txt":   "Killed process 4521", // fell back to journalctl -k
+		"meminfo.txt": "MemAvailable",
+		"gpu.txt":     "card0 72°C",
+	}
+	for name, want := range checks {
+		if !strings.Contains(files[name], want) {
+			t.Errorf("%s = %q, want it to contain %q", name, files[name], want)
+		}
+	}
+
+	joined := strings.Join(calls, "\n")
+	since := ev.Timestamp.Add(-10 * time.Minute).Unix()
+	for _, want := range []string{"dmesg --ctime", "-k -b", "--since @" + strconv.FormatInt(since, 10)} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("commands %q missing %q", joined, want)
+		}
+	}
+}
+
+func TestStartSkipsOtherEvents(t *testing.T) {
+	w := testWriter(t)
+	for _, ev := range []*event.Event{
+		event.New("h", time.Now(), event.TierOOMKill, event.SevHigh, "OOM Kill: cc1plus"),
+		event.New("h", time.Now(), event.TierServiceFailure, event.SevCritical, "Service failed: nginx.service"),
+	} {
+		if _, ok := w.Start(context.Background(), ev); ok {
+			t.Errorf("bundle started for %s %s", ev.Tier, ev.Severity)
+		}
+	}
+	w.Wait()
+}
+
+func TestPrune(t *testing.T) {
+	w := testWriter(t)
+	now := time.Now()
+	w.now = func() time.Time { return now }
+	if err := os.MkdirAll(w.dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{
+		"20260101-000000-T1-a.tar.gz", // expired
+		"20260102-000000-T4-b.tar.gz", // beyond max_bundles
+		"20260103-000000-T1-c.tar.gz",
+		"20260104-000000-T1-d.tar.gz",
+		"notes.txt", // not a bundle
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(w.dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := now.Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(w.dir, names[0]), old, old)
+
+	w.prune()
+
+	entries, _ := os.ReadDir(w.dir)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{names[2], names[3], "notes.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("after prune = %v, want %v", got, want)
+	}
+}
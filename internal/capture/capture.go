@@ -0,0 +1,282 @@
+// Package capture records a short, bounded debug bundle when a critical
+// incident opens: journal lines from the involved unit at a raised priority
+// and periodic system stat samples.
+package capture
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/monitor"
+	"github.com/setevik/logtriage/internal/store"
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// lookback is how far before the triggering event the journal capture
+// starts, so the lines leading up to the incident are included.
+const lookback = 30 * time.Second
+
+// Saver persists finished capture bundles.
+type Saver interface {
+	SaveCapture(c *store.Capture) error
+}
+
+// Manager starts captures for critical events. At most one capture runs at
+// a time, and a new one is not started until MinInterval has passed since
+// the previous one began.
+type Manager struct {
+	cfg   config.CaptureConfig
+	saver Saver
+
+	mu     sync.Mutex
+	active bool
+	last   time.Time
+	wg     sync.WaitGroup
+
+	// Overridable for testing.
+	now      func() time.Time
+	follow   func(ctx context.Context, args []string, maxLines int) (lines []string, dropped int, err error)
+	procRoot string
+}
+
+// New creates a capture Manager.
+func New(cfg config.CaptureConfig, saver Saver) *Manager {
+	return &Manager{
+		cfg:      cfg,
+		saver:    saver,
+		now:      time.Now,
+		follow:   followJournal,
+		procRoot: "/proc",
+	}
+}
+
+// Trigger starts a capture for ev, the event opening an incident, if it is
+// critical and no capture is throttling it. The capture is stored against
+// the event's incident. It returns true if a capture was started. The capture
+// runs in the background for the configured duration and stops early when
+// ctx is cancelled, saving whatever was collected.
+func (m *Manager) Trigger(ctx context.Context, ev *event.Event) bool {
+	if ev.Severity != event.SevCritical {
+		return false
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	if m.active || (!m.last.IsZero() && now.Sub(m.last) < m.cfg.MinInterval.Duration) {
+		m.mu.Unlock()
+		slog.Debug("debug capture throttled", "summary", ev.Summary)
+		return false
+	}
+	m.active = true
+	m.last = now
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx, ev)
+		m.mu.Lock()
+		m.active = false
+		m.mu.Unlock()
+	}()
+	return true
+}
+
+// Wait blocks until any running capture has been saved.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Manager) run(ctx context.Context, ev *event.Event) {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Duration.Duration)
+	defer cancel()
+
+	scope := Scope(ev)
+	args := journalArgs(ev, m.cfg.Priority)
+	c := &store.Capture{
+		EventID:    ev.ID,
+		IncidentID: ev.IncidentID,
+		Started:    m.now(),
+		Scope:      strings.Join(scope, " "),
+	}
+	slog.Info("debug capture started",
+		"summary", ev.Summary,
+		"scope", c.Scope,
+		"duration", m.cfg.Duration.Duration,
+	)
+
+	type journalResult struct {
+		lines   []string
+		dropped int
+		err     error
+	}
+	done := make(chan journalResult, 1)
+	go func() {
+		lines, dropped, err := m.follow(ctx, args, m.cfg.MaxLines)
+		done <- journalResult{lines, dropped, err}
+	}()
+
+	var samples []string
+	samples = append(samples, m.sample())
+	ticker := time.NewTicker(m.cfg.SampleInterval.Duration)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			samples = append(samples, m.sample())
+		}
+	}
+
+	res := <-done
+	if res.err != nil {
+		slog.Warn("debug capture journal failed", "error", res.err)
+		res.lines = append(res.lines, fmt.Sprintf("[journal capture failed: %v]", res.err))
+	}
+	if res.dropped > 0 {
+		res.lines = append(res.lines, fmt.Sprintf("[%d more line(s) dropped]", res.dropped))
+	}
+
+	c.Ended = m.now()
+	c.Journal = strings.Join(res.lines, "\n")
+	c.Samples = strings.Join(samples, "\n")
+	if err := m.saver.SaveCapture(c); err != nil {
+		slog.Error("failed to save debug capture", "error", err)
+		return
+	}
+	slog.Info("debug capture saved",
+		"incident_id", ev.IncidentID,
+		"event_id", ev.ID,
+		"journal_lines", len(res.lines),
+		"samples", len(samples),
+	)
+}
+
+// Scope returns the journalctl match that narrows a capture to the unit or
+// process involved in ev. It is empty when the event names neither, in
+// which case the capture covers the whole journal.
+func Scope(ev *event.Event) []string {
+	switch {
+	case ev.Unit != "":
+		return []string{"-u", ev.Unit}
+	case ev.Process != "":
+		return []string{"-t", ev.Process}
+	}
+	return nil
+}
+
+// journalArgs builds the journalctl arguments for a capture of ev.
+func journalArgs(ev *event.Event, priority int) []string {
+	args := []string{
+		"--follow",
+		"--no-pager",
+		"-o", "short-iso",
+		"-p", fmt.Sprintf("0..%d", priority),
+		"--since", fmt.Sprintf("@%d", ev.Timestamp.Add(-lookback).Unix()),
+	}
+	return append(args, Scope(ev)...)
+}
+
+// followJournal runs journalctl until ctx is done and returns up to
+// maxLines of its output, along with how many further lines were dropped.
+func followJournal(ctx context.Context, args []string, maxLines int) ([]string, int, error) {
+	if !sysdep.Have("journalctl") {
+		return nil, 0, fmt.Errorf("journalctl not installed")
+	}
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, 0, fmt.Errorf("stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, 0, fmt.Errorf("starting journalctl: %w", err)
+	}
+
+	var lines []string
+	var dropped int
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if maxLines > 0 && len(lines) >= maxLines {
+			dropped++
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+
+	// journalctl is killed when ctx ends; that is the normal way out.
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return lines, dropped, fmt.Errorf("journalctl: %w", err)
+	}
+	return lines, dropped, nil
+}
+
+// sample returns one line of system stats: load average, available memory,
+// free swap, and memory pressure.
+func (m *Manager) sample() string {
+	var b strings.Builder
+	b.WriteString(m.now().Local().Format("15:04:05"))
+
+	if data, err := os.ReadFile(filepath.Join(m.procRoot, "loadavg")); err == nil {
+		if f := strings.Fields(string(data)); len(f) >= 3 {
+			fmt.Fprintf(&b, " load=%s,%s,%s", f[0], f[1], f[2])
+		}
+	}
+
+	if mem, err := readMeminfo(filepath.Join(m.procRoot, "meminfo")); err == nil {
+		if v, ok := mem["MemAvailable"]; ok {
+			fmt.Fprintf(&b, " mem_avail=%s", strings.ReplaceAll(format.Bytes(v), " ", ""))
+		}
+		if v, ok := mem["SwapFree"]; ok {
+			fmt.Fprintf(&b, " swap_free=%s", strings.ReplaceAll(format.Bytes(v), " ", ""))
+		}
+	}
+
+	if psi, err := monitor.ReadPSI(filepath.Join(m.procRoot, "pressure", "memory")); err == nil {
+		fmt.Fprintf(&b, " psi_some=%.2f psi_full=%.2f", psi.SomeAvg10, psi.FullAvg10)
+	}
+
+	return b.String()
+}
+
+// readMeminfo parses /proc/meminfo into byte counts keyed by field name.
+func readMeminfo(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int64)
+	for _, line := range strings.Split(string(data), "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			continue
+		}
+		v, err := strconv.ParseInt(f[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(f) > 1 && f[1] == "kB" {
+			v *= 1024
+		}
+		out[name] = v
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+type memSaver struct {
+	mu       sync.Mutex
+	captures []*store.Capture
+}
+
+func (s *memSaver) SaveCapture(c *store.Capture) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.captures = append(s.captures, c)
+	return nil
+}
+
+func testConfig() config.CaptureConfig {
+	return config.CaptureConfig{
+		Enabled:        true,
+		Duration:       config.Duration{Duration: 50 * time.Millisecond},
+		SampleInterval: config.Duration{Duration: 10 * time.Millisecond},
+		Priority:       6,
+		MaxLines:       2,
+		MinInterval:    config.Duration{Duration: time.Hour},
+	}
+}
+
+func fakeProc(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"loadavg":         "1.50 0.75 0.25 2/300 1234\n",
+		"meminfo":         "MemTotal:       16384000 kB\nMemAvailable:    2097152 kB\nSwapFree:         1048576 kB\n",
+		"pressure/memory": "some avg10=12.50 avg60=3.00 avg300=1.00 total=1\nfull avg10=2.00 avg60=0.50 avg300=0.10 total=1\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTriggerCapturesBundle(t *testing.T) {
+	saver := &memSaver{}
+	m := New(testConfig(), saver)
+	m.procRoot = fakeProc(t)
+
+	var gotArgs []string
+	m.follow = func(ctx context.Context, args []string, maxLines int) ([]string, int, error) {
+		gotArgs = args
+		<-ctx.Done()
+		return []string{"line 1", "line 2"}, 3, nil
+	}
+
+	ev := event.New("h", time.Now(), event.TierServiceFailure, event.SevCritical, "Service failed: nginx.service")
+	ev.Unit = "nginx.service"
+	ev.IncidentID = "inc-1"
+	if !m.Trigger(context.Background(), ev) {
+		t.Fatal("capture not started")
+	}
+	m.Wait()
+
+	joined := strings.Join(gotArgs, " ")
+	for _, want := range []string{"-p 0..6", "-u nginx.service", "--follow"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("journalctl args %q missing %q", joined, want)
+		}
+	}
+
+	if len(saver.captures) != 1 {
+		t.Fatalf("saved %d captures, want 1", len(saver.captures))
+	}
+	c := saver.captures[0]
+	if c.EventID != ev.ID || c.IncidentID != "inc-1" || c.Scope != "-u nginx.service" {
+		t.Errorf("capture = %+v", c)
+	}
+	if !strings.Contains(c.Journal, "line 2") || !strings.Contains(c.Journal, "3 more line(s) dropped") {
+		t.Errorf("journal = %q", c.Journal)
+	}
+	samples := strings.Split(c.Samples, "\n")
+	if len(samples) < 2 {
+		t.Errorf("got %d samples, want several", len(samples))
+	}
+	for _, want := range []string{"load=1.50,0.75,0.25", "mem_avail=2.0GB", "swap_free=1.0GB", "psi_some=12.50"} {
+		if !strings.Contains(samples[0], want) {
+			t.Errorf("sample %q missing %q", samples[0], want)
+		}
+	}
+}
+
+func TestTriggerThrottled(t *testing.T) {
+	saver := &memSaver{}
+	m := New(testConfig(), saver)
+	m.procRoot = t.TempDir()
+	release := make(chan struct{})
+	m.follow = func(ctx context.Context, args []string, maxLines int) ([]string, int, error) {
+		<-release
+		return nil, 0, nil
+	}
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	ev := event.New("h", now, event.TierOOMKill, event.SevCritical, "OOM Kill: java")
+
+	if !m.Trigger(context.Background(), ev) {
+		t.Fatal("first capture not started")
+	}
+	if m.Trigger(context.Background(), ev) {
+		t.Error("second capture started while first is running")
+	}
+	close(release)
+	m.Wait()
+
+	// Still within min_interval of the first start.
+	if m.Trigger(context.Background(), ev) {
+		t.Error("capture started within min_interval")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if !m.Trigger(context.Background(), ev) {
+		t.Error("capture not started after min_interval")
+	}
+	m.Wait()
+
+	// Non-critical events never trigger.
+	now = now.Add(2 * time.Hour)
+	low := event.New("h", now, event.TierProcessCrash, event.SevHigh, "Crash: vlc")
+	if m.Trigger(context.Background(), low) {
+		t.Error("non-critical event triggered a capture")
+	}
+}
+
+func TestScope(t *testing.T) {
+	ev := &event.Event{Process: "firefox"}
+	if got := strings.Join(Scope(ev), " "); got != "-t firefox" {
+		t.Errorf("Scope = %q", got)
+	}
+	if got := Scope(&event.Event{}); got != nil {
+		t.Errorf("Scope of bare event = %q, want nil", got)
+	}
+}
@@ -0,0 +1,274 @@
+package classifier
+
+import (
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// auditTypeNames names the audit record types classified, by number, for
+// journald versions that do not set _AUDIT_TYPE_NAME.
+var auditTypeNames = map[string]string{
+	"1100": "USER_AUTH",
+	"1107": "USER_AVC",
+	"1326": "SECCOMP",
+	"1400": "AVC",
+}
+
+// auditFieldRe matches the key=value fields of an audit record. Values are
+// bare, double-quoted, or, for the message a userspace program logs with
+// its record, single-quoted.
+var auditFieldRe = regexp.MustCompile(`(\w+)=("[^"]*"|'[^']*'|\S*)`)
+
+// auditHexFields are the fields the kernel writes hex-encoded, unquoted,
+// when their value contains spaces or quotes.
+var auditHexFields = map[string]bool{
+	"acct": true,
+	"cmd":  true,
+	"comm": true,
+	"exe":  true,
+	"name": true,
+	"path": true,
+}
+
+// auditArches names the AUDIT_ARCH values of seccomp records, as ausyscall
+// takes them.
+var auditArches = map[string]string{
+	"c000003e": "x86_64",
+	"40000003": "i386",
+	"c00000b7": "aarch64",
+	"40000028": "arm",
+	"c00000f3": "riscv64",
+	"c0000015": "ppc64le",
+}
+
+// avcDeniedRe extracts the permissions an SELinux denial refused.
+// Example: "avc:  denied  { read write } for  pid=1234 comm="nginx" name="index.html" ... tclass=file permissive=0"
+var avcDeniedRe = regexp.MustCompile(`avc:\s+denied\s+\{ ([^}]*) \}`)
+
+// auditRecord returns the type and the fields part of an audit record
+// entry. journald, and watcher.AuditSource after it, prefix the record
+// with its type name.
+func auditRecord(entry watcher.JournalEntry) (typ, data string, ok bool) {
+	if entry.Transport != watcher.AuditTransport {
+		return "", "", false
+	}
+	typ = entry.Fields["_AUDIT_TYPE_NAME"]
+	if typ == "" {
+		typ = auditTypeNames[entry.Fields["_AUDIT_TYPE"]]
+	}
+	_, data, _ = strings.Cut(entry.Message, " ")
+	return typ, data, true
+}
+
+// auditFields parses the fields of an audit record. The fields of a
+// userspace message (msg='...') are merged in; where a key appears twice,
+// the first value is kept.
+func auditFields(data string) map[string]string {
+	f := make(map[string]string)
+	for _, m := range auditFieldRe.FindAllStringSubmatch(data, -1) {
+		key, value := m[1], m[2]
+		switch {
+		case strings.HasPrefix(value, "'"):
+			for k, v := range auditFields(strings.Trim(value, "'")) {
+				if _, ok := f[k]; !ok {
+					f[k] = v
+				}
+			}
+			continue
+		case strings.HasPrefix(value, `"`):
+			value = strings.Trim(value, `"`)
+		case auditHexFields[key]:
+			if b, err := hex.DecodeString(value); err == nil && value != "" {
+				value = string(b)
+			}
+		}
+		if _, ok := f[key]; !ok {
+			f[key] = value
+		}
+	}
+	return f
+}
+
+// auditValue returns a field of an audit record, or "" where the record
+// has "?" or "(null)" for unknown.
+func auditValue(f map[string]string, key string) string {
+	switch v := f[key]; v {
+	case "?", "(null)", "(none)":
+		return ""
+	default:
+		return v
+	}
+}
+
+// auditProcess returns the command of the process an audit record is
+// about, or the base name of its executable.
+func auditProcess(f map[string]string) string {
+	if comm := auditValue(f, "comm"); comm != "" {
+		return comm
+	}
+	if exe := auditValue(f, "exe"); exe != "" {
+		return path.Base(exe)
+	}
+	return ""
+}
+
+// classifyAudit classifies seccomp kills and access denials in audit
+// records. Failed authentications are not classified here: they only
+// matter repeated, and are counted by an AuthGuard.
+func (c *Classifier) classifyAudit(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	typ, data, ok := auditRecord(entry)
+	if !ok {
+		return nil
+	}
+	f := auditFields(data)
+
+	var ev *event.Event
+	switch typ {
+	case "SECCOMP":
+		ev = c.classifySeccomp(f, ts)
+	case "AVC", "USER_AVC":
+		d, ok := accessDenial(data, f)
+		if !ok {
+			return nil
+		}
+		ev = event.New(c.instanceID, ts, event.TierSecurity, event.SevMedium, d.summary)
+		ev.Process = d.process
+		ev.PID = d.pid
+		ev.DedupKey = d.dedupKey
+		ev.Detail = d.detail
+		ev.RawFields["_denied_by"] = d.lsm
+		ev.RawFields["_security"] = "access_denial"
+	}
+	if ev == nil {
+		return nil
+	}
+	maps.Copy(ev.RawFields, entry.Fields)
+	ev.RawFields["_audit_type"] = typ
+	return ev
+}
+
+// classifySeccomp classifies a seccomp record of a process its filter
+// killed. Records of filters that only log, or fail the syscall with an
+// error, carry no signal and are ignored.
+func (c *Classifier) classifySeccomp(f map[string]string, ts time.Time) *event.Event {
+	sig := auditValue(f, "sig")
+	if sig == "" || sig == "0" {
+		return nil
+	}
+	process := auditProcess(f)
+	pid, _ := strconv.Atoi(f["pid"])
+	syscall := auditValue(f, "syscall")
+
+	ev := event.New(c.instanceID, ts, event.TierSecurity, event.SevHigh,
+		fmt.Sprintf("Seccomp kill: %s (pid %d) on syscall %s", process, pid, syscall))
+	ev.Process = process
+	ev.PID = pid
+	ev.RawFields["_syscall"] = syscall
+	ev.RawFields["_security"] = "seccomp"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s was killed by its seccomp filter for making syscall %s.\n", process, syscall)
+	if exe := auditValue(f, "exe"); exe != "" {
+		fmt.Fprintf(&b, "Executable: %s\n", exe)
+	}
+	if arch, ok := auditArches[f["arch"]]; ok {
+		fmt.Fprintf(&b, "Name the syscall with: ausyscall %s %s\n", arch, syscall)
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev
+}
+
+// denial is an access denial by a Linux security module.
+type denial struct {
+	lsm      string // "selinux" or "apparmor"
+	summary  string
+	detail   string
+	dedupKey string
+	process  string
+	pid      int
+}
+
+// accessDenial parses an enforced SELinux or AppArmor denial. Denials of
+// permissive domains and AppArmor profiles in complain mode are only
+// logged, so they are not reported.
+func accessDenial(data string, f map[string]string) (denial, bool) {
+	d := denial{process: auditProcess(f)}
+	d.pid, _ = strconv.Atoi(f["pid"])
+
+	var b strings.Builder
+	if m := avcDeniedRe.FindStringSubmatch(data); m != nil {
+		if f["permissive"] == "1" {
+			return denial{}, false
+		}
+		perms, class := m[1], auditValue(f, "tclass")
+		target := class
+		if name := cmp.Or(auditValue(f, "path"), auditValue(f, "name")); name != "" {
+			target += " " + name
+		}
+		stype := selinuxType(f["scontext"])
+		d.lsm = "selinux"
+		d.summary = fmt.Sprintf("SELinux denied %s %s on %s", d.process, perms, target)
+		d.dedupKey = "selinux=" + stype + ", tclass=" + class + ", perm=" + perms
+		fmt.Fprintf(&b, "SELinux denied %s (pid %d) %s on %s.\n", d.process, d.pid, perms, target)
+		fmt.Fprintf(&b, "Source context: %s\n", f["scontext"])
+		fmt.Fprintf(&b, "Target context: %s\n", f["tcontext"])
+		fmt.Fprintf(&b, "To see a policy allowing it: ausearch -m AVC,USER_AVC -c %s | audit2allow", d.process)
+	} else if f["apparmor"] == "DENIED" {
+		op, profile := auditValue(f, "operation"), auditValue(f, "profile")
+		target := cmp.Or(auditValue(f, "name"), auditValue(f, "capname"), op)
+		d.lsm = "apparmor"
+		d.summary = fmt.Sprintf("AppArmor denied %s %s on %s", d.process, op, target)
+		d.dedupKey = "apparmor=" + profile + ", operation=" + op
+		fmt.Fprintf(&b, "AppArmor profile %s denied %s (pid %d) %s on %s.\n", profile, d.process, d.pid, op, target)
+		if mask := auditValue(f, "denied_mask"); mask != "" {
+			fmt.Fprintf(&b, "Denied: %s\n", mask)
+		}
+		b.WriteString("To see a policy allowing it: aa-logprof")
+	} else {
+		return denial{}, false
+	}
+	if len(d.summary) > 100 {
+		d.summary = d.summary[:97] + "..."
+	}
+	d.detail = b.String()
+	return d, true
+}
+
+// Denial is an enforced SELinux or AppArmor denial, as AccessDenial
+// parses it.
+type Denial struct {
+	Summary string // e.g. "SELinux denied nginx read on file index.html"
+	Process string
+	PID     int
+}
+
+// AccessDenial reports whether an audit record entry is an enforced
+// SELinux or AppArmor denial, and of which process.
+func AccessDenial(entry watcher.JournalEntry) (Denial, bool) {
+	typ, data, ok := auditRecord(entry)
+	if !ok || (typ != "AVC" && typ != "USER_AVC") {
+		return Denial{}, false
+	}
+	d, ok := accessDenial(data, auditFields(data))
+	return Denial{Summary: d.summary, Process: d.process, PID: d.pid}, ok
+}
+
+// selinuxType returns the type of an SELinux context, e.g. "httpd_t" of
+// "system_u:system_r:httpd_t:s0".
+func selinuxType(context string) string {
+	parts := strings.Split(context, ":")
+	if len(parts) < 3 {
+		return context
+	}
+	return parts[2]
+}
@@ -0,0 +1,180 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// auditEntry builds an audit record entry as journald collects it.
+func auditEntry(typ, data string) watcher.JournalEntry {
+	return watcher.JournalEntry{
+		Message:           typ + " " + data,
+		Transport:         watcher.AuditTransport,
+		RealtimeTimestamp: "1708300000000000",
+		Fields:            map[string]string{"_AUDIT_TYPE_NAME": typ},
+	}
+}
+
+func TestClassifyAudit(t *testing.T) {
+	c := New("testhost")
+
+	tests := []struct {
+		name     string
+		entry    watcher.JournalEntry
+		summary  string // empty means no event
+		severity event.Severity
+		process  string
+	}{
+		{
+			name: "seccomp kill",
+			entry: auditEntry("SECCOMP", `auid=4294967295 uid=33 gid=33 ses=4294967295 subj=unconfined pid=4242 comm="php-fpm" `+
+				`exe="/usr/sbin/php-fpm8.2" sig=31 arch=c000003e syscall=59 compat=0 ip=0x7f0e1c2d3e4f code=0x80000000`),
+			summary:  "Seccomp kill: php-fpm (pid 4242) on syscall 59",
+			severity: event.SevHigh,
+			process:  "php-fpm",
+		},
+		{
+			name: "seccomp log only",
+			entry: auditEntry("SECCOMP", `auid=1000 uid=1000 gid=1000 ses=2 pid=4242 comm="chrome" `+
+				`exe="/opt/google/chrome/chrome" sig=0 arch=c000003e syscall=330 compat=0 ip=0x7f0e1c2d3e4f code=0x7ffc0000`),
+		},
+		{
+			name: "selinux denial",
+			entry: auditEntry("AVC", `avc:  denied  { read } for  pid=1234 comm="nginx" name="index.html" dev="sda1" ino=5678 `+
+				`scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=0`),
+			summary:  "SELinux denied nginx read on file index.html",
+			severity: event.SevMedium,
+			process:  "nginx",
+		},
+		{
+			name: "selinux permissive",
+			entry: auditEntry("AVC", `avc:  denied  { read } for  pid=1234 comm="nginx" name="index.html" `+
+				`scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=1`),
+		},
+		{
+			name: "apparmor denial",
+			entry: auditEntry("AVC", `apparmor="DENIED" operation="open" class="file" profile="/usr/sbin/cupsd" `+
+				`name="/etc/shadow" pid=981 comm="cupsd" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`),
+			summary:  "AppArmor denied cupsd open on /etc/shadow",
+			severity: event.SevMedium,
+			process:  "cupsd",
+		},
+		{
+			name: "apparmor complain mode",
+			entry: auditEntry("AVC", `apparmor="ALLOWED" operation="open" class="file" profile="/usr/sbin/cupsd" `+
+				`name="/etc/shadow" pid=981 comm="cupsd" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`),
+		},
+		{
+			name: "hex-encoded comm",
+			entry: auditEntry("SECCOMP", `auid=1000 uid=1000 gid=1000 ses=2 pid=77 comm=6D7920617070 `+
+				`exe="/usr/bin/my app" sig=31 arch=c000003e syscall=101 compat=0 ip=0x7f0e1c2d3e4f code=0x80000000`),
+			summary:  "Seccomp kill: my app (pid 77) on syscall 101",
+			severity: event.SevHigh,
+			process:  "my app",
+		},
+		{
+			name: "not audit transport",
+			entry: watcher.JournalEntry{
+				Message:   `AVC avc:  denied  { read } for  pid=1234 comm="nginx" tclass=file permissive=0`,
+				Transport: "syslog",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := c.classifyAudit(tt.entry, time.Unix(1708300000, 0))
+			if tt.summary == "" {
+				if ev != nil {
+					t.Fatalf("expected no event, got %q", ev.Summary)
+				}
+				return
+			}
+			if ev == nil {
+				t.Fatal("expected an event, got nil")
+			}
+			if ev.Tier != event.TierSecurity || ev.Severity != tt.severity {
+				t.Errorf("tier/severity = %s/%s", ev.Tier, ev.Severity)
+			}
+			if ev.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", ev.Summary, tt.summary)
+			}
+			if ev.Process != tt.process {
+				t.Errorf("process = %q, want %q", ev.Process, tt.process)
+			}
+		})
+	}
+}
+
+func TestClassifyAuditByTypeNumber(t *testing.T) {
+	c := New("testhost")
+	// Older journald versions set only the type number.
+	entry := auditEntry("SECCOMP", `auid=0 uid=0 gid=0 ses=1 pid=900 comm="worker" exe="/usr/bin/worker" sig=9 arch=c00000b7 syscall=220 compat=0 ip=0x0 code=0x0`)
+	entry.Fields = map[string]string{"_AUDIT_TYPE": "1326"}
+
+	ev := c.Classify(entry)
+	if ev == nil || ev.Tier != event.TierSecurity {
+		t.Fatalf("expected T9 event, got %v", ev)
+	}
+	if want := "Name the syscall with: ausyscall aarch64 220"; !strings.Contains(ev.Detail, want) {
+		t.Errorf("detail = %q, want it to contain %q", ev.Detail, want)
+	}
+}
+
+func TestAuthGuard(t *testing.T) {
+	c := New("testhost")
+	g := c.NewAuthGuard(3, 10*time.Minute)
+
+	start := time.Unix(1708300000, 0)
+	fail := func(after time.Duration, addr string) *event.Event {
+		return g.Observe(AuthFailure{Time: start.Add(after), Account: "root", Addr: addr, Service: "sshd"})
+	}
+
+	if ev := fail(0, "203.0.113.7"); ev != nil {
+		t.Fatalf("first failure reported: %q", ev.Summary)
+	}
+	if ev := fail(time.Minute, "203.0.113.7"); ev != nil {
+		t.Fatalf("second failure reported: %q", ev.Summary)
+	}
+	ev := fail(2*time.Minute, "198.51.100.2")
+	if ev == nil {
+		t.Fatal("third failure within the window not reported")
+	}
+	if ev.Tier != event.TierSecurity || ev.Summary != "Repeated authentication failures: root via sshd (3 in 10m)" {
+		t.Errorf("event = %s %q", ev.Tier, ev.Summary)
+	}
+	if !strings.Contains(ev.Detail, "203.0.113.7") || !strings.Contains(ev.Detail, "198.51.100.2") {
+		t.Errorf("detail does not list the sources: %q", ev.Detail)
+	}
+
+	if ev := fail(3*time.Minute, "203.0.113.7"); ev != nil {
+		t.Errorf("account reported twice within the window: %q", ev.Summary)
+	}
+	// Failures spread wider than the window do not add up.
+	if ev := fail(30*time.Minute, ""); ev != nil {
+		t.Errorf("failure after a quiet window reported: %q", ev.Summary)
+	}
+}
+
+func TestParseAuthFailure(t *testing.T) {
+	entry := auditEntry("USER_AUTH", `pid=2211 uid=0 auid=4294967295 ses=4294967295 subj=unconfined `+
+		`msg='op=PAM:authentication grantors=? acct="admin" exe="/usr/sbin/sshd" hostname=203.0.113.7 addr=203.0.113.7 terminal=ssh res=failed'`)
+	af, ok := ParseAuthFailure(entry)
+	if !ok {
+		t.Fatal("failed authentication not parsed")
+	}
+	if af.Account != "admin" || af.Addr != "203.0.113.7" || af.Service != "sshd" {
+		t.Errorf("failure = %+v", af)
+	}
+	if !af.Time.Equal(time.Unix(1708300000, 0)) {
+		t.Errorf("time = %v", af.Time)
+	}
+
+	entry = auditEntry("USER_AUTH", `pid=2211 uid=0 auid=1000 ses=3 msg='op=PAM:authentication grantors=pam_unix acct="admin" exe="/usr/bin/sudo" hostname=? addr=? terminal=/dev/pts/0 res=success'`)
+	if _, ok := ParseAuthFailure(entry); ok {
+		t.Error("successful authentication parsed as a failure")
+	}
+}
@@ -0,0 +1,150 @@
+package classifier
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// maxAuthSources caps the sources listed in a repeated authentication
+// failure event's detail.
+const maxAuthSources = 10
+
+// AuthFailure is a failed attempt to authenticate.
+type AuthFailure struct {
+	Time    time.Time
+	Account string // the account tried; "" if unknown
+	Addr    string // the remote address; "" for a local attempt
+	Service string // what was authenticating, e.g. "sshd" or "sudo"
+}
+
+// ParseAuthFailure reports whether entry is a failed authentication: an
+// audit USER_AUTH record with res=failed, which PAM logs for every failed
+// password of sshd, sudo, su, and login.
+func ParseAuthFailure(entry watcher.JournalEntry) (AuthFailure, bool) {
+	typ, data, ok := auditRecord(entry)
+	if !ok || typ != "USER_AUTH" {
+		return AuthFailure{}, false
+	}
+	f := auditFields(data)
+	if f["res"] != "failed" {
+		return AuthFailure{}, false
+	}
+	af := AuthFailure{
+		Time:    parseTimestamp(entry),
+		Account: auditValue(f, "acct"),
+		Addr:    cmp.Or(auditValue(f, "addr"), auditValue(f, "hostname")),
+	}
+	if exe := auditValue(f, "exe"); exe != "" {
+		af.Service = path.Base(exe)
+	}
+	return af, true
+}
+
+// AuthGuard counts failed authentications per account and reports an
+// account failing threshold times within window, once per window. Single
+// failures are typos; a run of them is someone guessing. It is not safe for
+// concurrent use.
+type AuthGuard struct {
+	c         *Classifier
+	threshold int
+	window    time.Duration
+	recent    map[string][]AuthFailure // by account, oldest first
+	reported  map[string]time.Time     // when each account was last reported
+	swept     time.Time
+}
+
+// NewAuthGuard returns an AuthGuard reporting threshold failures of an
+// account within window.
+func (c *Classifier) NewAuthGuard(threshold int, window time.Duration) *AuthGuard {
+	return &AuthGuard{
+		c:         c,
+		threshold: threshold,
+		window:    window,
+		recent:    make(map[string][]AuthFailure),
+		reported:  make(map[string]time.Time),
+	}
+}
+
+// Observe records a failed authentication and returns a T9 event when it
+// is the threshold-th failure of its account within the window, unless
+// the account was already reported within the window.
+func (g *AuthGuard) Observe(f AuthFailure) *event.Event {
+	g.sweep(f.Time)
+
+	account := cmp.Or(f.Account, "unknown")
+	cutoff := f.Time.Add(-g.window)
+	failures := slices.DeleteFunc(g.recent[account], func(old AuthFailure) bool {
+		return !old.Time.After(cutoff)
+	})
+	failures = append(failures, f)
+	g.recent[account] = failures
+
+	if len(failures) < g.threshold {
+		return nil
+	}
+	if last, ok := g.reported[account]; ok && last.After(cutoff) {
+		return nil
+	}
+	g.reported[account] = f.Time
+	return g.event(account, failures)
+}
+
+// sweep forgets accounts with no failure within the window, at most once
+// per window, so guessed account names do not pile up.
+func (g *AuthGuard) sweep(now time.Time) {
+	if now.Sub(g.swept) < g.window {
+		return
+	}
+	g.swept = now
+	cutoff := now.Add(-g.window)
+	maps.DeleteFunc(g.recent, func(_ string, failures []AuthFailure) bool {
+		return !failures[len(failures)-1].Time.After(cutoff)
+	})
+	maps.DeleteFunc(g.reported, func(_ string, at time.Time) bool {
+		return !at.After(cutoff)
+	})
+}
+
+// event reports the failures of an account, with where they came from.
+func (g *AuthGuard) event(account string, failures []AuthFailure) *event.Event {
+	last := failures[len(failures)-1]
+	service := cmp.Or(last.Service, "unknown")
+	summary := fmt.Sprintf("Repeated authentication failures: %s via %s (%d in %s)",
+		account, service, len(failures), formatWindow(g.window))
+
+	ev := event.New(g.c.instanceID, last.Time, event.TierSecurity, event.SevHigh, summary)
+	ev.Process = service
+	ev.DedupKey = "account=" + account
+	ev.RawFields["_account"] = account
+	ev.RawFields["_security"] = "auth_failures"
+	ev.RawFields["_auth_failures"] = strconv.Itoa(len(failures))
+
+	bySource := make(map[string]int)
+	for _, f := range failures {
+		bySource[cmp.Or(f.Addr, "local")]++
+	}
+	sources := slices.SortedFunc(maps.Keys(bySource), func(a, b string) int {
+		return cmp.Or(bySource[b]-bySource[a], strings.Compare(a, b))
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d failed authentications for %s in %s.\n\nBy source:\n", len(failures), account, formatWindow(g.window))
+	for i, src := range sources {
+		if i == maxAuthSources {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(sources)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %-40s %d\n", src, bySource[src])
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev
+}
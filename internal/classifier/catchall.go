@@ -0,0 +1,57 @@
+package classifier
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// priorityNames are the syslog priority keywords, indexed by level.
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// unclassifiedSummaryLen bounds the message excerpt in an unclassified
+// event's summary; the full message is kept in the detail.
+const unclassifiedSummaryLen = 100
+
+// ClassifyUnclassified creates a T8 event for an entry that matched no
+// pattern but was logged at maxPriority or more severe, or returns nil.
+// Entries without a PRIORITY field are skipped rather than read as emerg.
+func (c *Classifier) ClassifyUnclassified(entry watcher.JournalEntry, maxPriority int) *event.Event {
+	if entry.Fields["PRIORITY"] == "" || entry.Priority > maxPriority {
+		return nil
+	}
+
+	source := entry.SyslogIdentifier
+	if source == "" {
+		source = entry.SystemdUnit
+	}
+	if source == "" {
+		source = "unknown"
+	}
+
+	level := strconv.Itoa(entry.Priority)
+	if entry.Priority >= 0 && entry.Priority < len(priorityNames) {
+		level = priorityNames[entry.Priority]
+	}
+
+	msg := entry.Message
+	if len(msg) > unclassifiedSummaryLen {
+		msg = msg[:unclassifiedSummaryLen-3] + "..."
+	}
+	summary := fmt.Sprintf("Unclassified %s from %s: %s", level, source, msg)
+	ev := event.New(c.instanceID, parseTimestamp(entry), event.TierUnclassified, event.SevWarning, summary)
+	ev.Process = entry.SyslogIdentifier
+	ev.Unit = entry.SystemdUnit
+	if pid, err := strconv.Atoi(entry.PID); err == nil {
+		ev.PID = pid
+	}
+	ev.Detail = entry.Message
+	ev.RawFields = entry.Fields
+	if ev.RawFields == nil {
+		ev.RawFields = make(map[string]string)
+	}
+	ev.RawFields["_unclassified"] = "true"
+	return ev
+}
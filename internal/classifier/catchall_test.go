@@ -0,0 +1,61 @@
+package classifier
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestClassifyUnclassified(t *testing.T) {
+	c := New("testhost")
+
+	// priority is the PRIORITY field as journalctl emits it; "" omits it.
+	entry := func(priority, msg string) watcher.JournalEntry {
+		fields := map[string]string{"MESSAGE": msg}
+		if priority != "" {
+			fields["PRIORITY"] = priority
+		}
+		p, _ := strconv.Atoi(priority)
+		return watcher.JournalEntry{
+			Message:          msg,
+			Priority:         p,
+			SyslogIdentifier: "mysqld",
+			SystemdUnit:      "mariadb.service",
+			PID:              "812",
+			Fields:           fields,
+		}
+	}
+
+	ev := c.ClassifyUnclassified(entry("2", "InnoDB: Database page corruption on disk"), 2)
+	if ev == nil {
+		t.Fatal("crit entry: expected event")
+	}
+	if ev.Tier != event.TierUnclassified || ev.Severity != event.SevWarning {
+		t.Errorf("tier=%q severity=%q", ev.Tier, ev.Severity)
+	}
+	if ev.Summary != "Unclassified crit from mysqld: InnoDB: Database page corruption on disk" {
+		t.Errorf("summary = %q", ev.Summary)
+	}
+	if ev.Process != "mysqld" || ev.Unit != "mariadb.service" || ev.PID != 812 {
+		t.Errorf("process=%q unit=%q pid=%d", ev.Process, ev.Unit, ev.PID)
+	}
+	if ev.RawFields["_unclassified"] != "true" {
+		t.Errorf("raw fields = %v", ev.RawFields)
+	}
+
+	if ev := c.ClassifyUnclassified(entry("3", "connection reset"), 2); ev != nil {
+		t.Errorf("err entry above max priority: got %q", ev.Summary)
+	}
+	if ev := c.ClassifyUnclassified(entry("", "no priority field"), 2); ev != nil {
+		t.Errorf("entry without PRIORITY: got %q", ev.Summary)
+	}
+
+	long := strings.Repeat("x", 300)
+	ev = c.ClassifyUnclassified(entry("0", long), 2)
+	if ev == nil || len(ev.Summary) > 140 || ev.Detail != long {
+		t.Errorf("long message: summary %d bytes, detail %d bytes", len(ev.Summary), len(ev.Detail))
+	}
+}
@@ -0,0 +1,94 @@
+package classifier
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// oomMemcgRe extracts the task_memcg cgroup path from an oom-kill line.
+var oomMemcgRe = regexp.MustCompile(`task_memcg=([^,]+)`)
+
+// oomLimitMemcgRe extracts the oom_memcg cgroup path from an oom-kill line:
+// the cgroup whose memory limit was hit, which may be a parent of the
+// killed task's own cgroup ("/" for a system-wide OOM).
+var oomLimitMemcgRe = regexp.MustCompile(`oom_memcg=([^,]+)`)
+
+// userSliceRe extracts the uid from a user slice such as "user-1000.slice".
+var userSliceRe = regexp.MustCompile(`^user-(\d+)\.slice$`)
+
+// CGroupOwner returns the systemd unit a cgroup path belongs to and, for
+// paths under a user slice, the owning uid. The unit is the innermost
+// service or scope, so a process in a user's app scope is attributed to
+// that scope rather than to user@1000.service.
+// Examples:
+//
+//	/system.slice/backup.service                                -> backup.service
+//	/system.slice/system-getty.slice/getty@tty1.service         -> getty@tty1.service
+//	/user.slice/user-1000.slice/session-3.scope                 -> session-3.scope, 1000
+//	/user.slice/user-1000.slice/user@1000.service/app.slice/app-firefox-42.scope
+//	                                                            -> app-firefox-42.scope, 1000
+func CGroupOwner(path string) (unit, uid string) {
+	for _, part := range strings.Split(path, "/") {
+		if m := userSliceRe.FindStringSubmatch(part); m != nil {
+			uid = m[1]
+		}
+		if strings.HasSuffix(part, ".service") || strings.HasSuffix(part, ".scope") {
+			unit = part
+		}
+	}
+	return unit, uid
+}
+
+// tagCGroup records the memory cgroup of an OOM-killed task and attributes
+// the kill to the container, unit, or user that owns it, based on the
+// task_memcg and oom_memcg paths in the kernel message. The unit is only set
+// when the event has none, so journal metadata takes precedence.
+func tagCGroup(ev *event.Event, msg string) {
+	m := oomMemcgRe.FindStringSubmatch(msg)
+	if m == nil {
+		return
+	}
+	ev.CGroup = m[1]
+	if ev.RawFields == nil {
+		ev.RawFields = make(map[string]string)
+	}
+	if lm := oomLimitMemcgRe.FindStringSubmatch(msg); lm != nil && lm[1] != ev.CGroup {
+		ev.RawFields["_oom_memcg"] = lm[1]
+	}
+	attributeCGroup(ev)
+}
+
+// attributeCGroup attributes an event to the container, unit, or user that
+// owns its cgroup, ev.CGroup, and says so in its summary. The unit is only
+// set when the event has none.
+func attributeCGroup(ev *event.Event) {
+	if runtime, id := ContainerFromCgroup(ev.CGroup); id != "" {
+		ev.ContainerID = id
+		ev.RawFields["_container_runtime"] = runtime
+		ev.Summary += " in container " + ShortContainerID(id)
+		if uid := podFromCgroup(ev.CGroup); uid != "" {
+			// The runtime reports the same kill; see classifyPodContainer.
+			ev.RawFields["_pod_uid"] = uid
+			ev.DedupKey = "pod=" + uid
+		}
+		return
+	}
+
+	unit, uid := CGroupOwner(ev.CGroup)
+	if uid != "" {
+		ev.RawFields["_cgroup_uid"] = uid
+	}
+	switch {
+	case unit != "" && uid != "":
+		ev.Summary += " in " + unit + " (uid " + uid + ")"
+	case unit != "":
+		ev.Summary += " in " + unit
+	case uid != "":
+		ev.Summary += " (uid " + uid + ")"
+	}
+	if unit != "" && ev.Unit == "" {
+		ev.Unit = unit
+	}
+}
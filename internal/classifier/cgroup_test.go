@@ -0,0 +1,89 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestCGroupOwner(t *testing.T) {
+	tests := []struct {
+		path, unit, uid string
+	}{
+		{"/system.slice/backup.service", "backup.service", ""},
+		{"/system.slice/system-getty.slice/getty@tty1.service", "getty@tty1.service", ""},
+		{"/user.slice/user-1000.slice/session-3.scope", "session-3.scope", "1000"},
+		{"/user.slice/user-1000.slice/user@1000.service/app.slice/app-firefox-42.scope", "app-firefox-42.scope", "1000"},
+		{"/user.slice/user-1000.slice/user@1000.service", "user@1000.service", "1000"},
+		{"/user.slice/user-1000.slice", "", "1000"},
+		{"/", "", ""},
+	}
+	for _, tt := range tests {
+		unit, uid := CGroupOwner(tt.path)
+		if unit != tt.unit || uid != tt.uid {
+			t.Errorf("CGroupOwner(%q) = %q, %q; want %q, %q", tt.path, unit, uid, tt.unit, tt.uid)
+		}
+	}
+}
+
+func TestClassifyOOMCGroup(t *testing.T) {
+	c := New("testhost")
+	oom := func(oomMemcg, taskMemcg string) watcher.JournalEntry {
+		msg := "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=" +
+			oomMemcg + ",task_memcg=" + taskMemcg + ",task=python3,pid=4242,uid=0"
+		return watcher.JournalEntry{Message: msg, SyslogIdentifier: "kernel", Transport: "kernel", Fields: map[string]string{}}
+	}
+
+	tests := []struct {
+		name     string
+		entry    watcher.JournalEntry
+		summary  string
+		unit     string
+		uid      string
+		oomMemcg string
+	}{
+		{
+			name:    "system service",
+			entry:   oom("/system.slice/backup.service", "/system.slice/backup.service"),
+			summary: "OOM Kill: python3 (pid 4242) in backup.service",
+			unit:    "backup.service",
+		},
+		{
+			name:     "user app scope under a user slice limit",
+			entry:    oom("/user.slice/user-1000.slice", "/user.slice/user-1000.slice/user@1000.service/app.slice/app-jupyter-7.scope"),
+			summary:  "OOM Kill: python3 (pid 4242) in app-jupyter-7.scope (uid 1000)",
+			unit:     "app-jupyter-7.scope",
+			uid:      "1000",
+			oomMemcg: "/user.slice/user-1000.slice",
+		},
+		{
+			name:    "root cgroup",
+			entry:   oom("/", "/"),
+			summary: "OOM Kill: python3 (pid 4242)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := c.Classify(tt.entry)
+			if ev == nil || ev.Tier != event.TierOOMKill {
+				t.Fatalf("expected T1 event, got %v", ev)
+			}
+			if ev.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", ev.Summary, tt.summary)
+			}
+			if ev.Unit != tt.unit {
+				t.Errorf("unit = %q, want %q", ev.Unit, tt.unit)
+			}
+			if ev.RawFields["_cgroup_uid"] != tt.uid {
+				t.Errorf("uid = %q, want %q", ev.RawFields["_cgroup_uid"], tt.uid)
+			}
+			if ev.RawFields["_oom_memcg"] != tt.oomMemcg {
+				t.Errorf("oom_memcg = %q, want %q", ev.RawFields["_oom_memcg"], tt.oomMemcg)
+			}
+			if ev.CGroup == "" {
+				t.Error("cgroup not recorded")
+			}
+		})
+	}
+}
@@ -4,15 +4,18 @@ package classifier
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
 	"github.com/setevik/logtriage/internal/watcher"
 )
 
 // Classifier matches journal entries to event types.
 type Classifier struct {
 	instanceID string
+	rules      []rule // user-defined, evaluated before built-in patterns
 }
 
 // New creates a Classifier for the given instance.
@@ -25,6 +28,31 @@ func New(instanceID string) *Classifier {
 func (c *Classifier) Classify(entry watcher.JournalEntry) *event.Event {
 	ts := parseTimestamp(entry)
 
+	// User-defined rules take precedence over the built-in tiers.
+	if ev := c.classifyRules(entry, ts); ev != nil {
+		return ev
+	}
+
+	// T2/T3/T4/T5/T7 — Windows Event Log events
+	if ev := c.classifyWindowsEvent(entry, ts); ev != nil {
+		return ev
+	}
+
+	// T9 — Seccomp kills and access denials in audit records
+	if ev := c.classifyAudit(entry, ts); ev != nil {
+		return ev
+	}
+
+	// T9 — Root logins and sudo failures
+	if ev := c.classifyLogin(entry, ts); ev != nil {
+		return ev
+	}
+
+	// T1/T2 — Kubernetes pod container OOM killed or exited with an error
+	if ev := c.classifyPodContainer(entry, ts); ev != nil {
+		return ev
+	}
+
 	// T1 — OOM Kill
 	if ev := c.classifyOOM(entry, ts); ev != nil {
 		return ev
@@ -35,6 +63,11 @@ func (c *Classifier) Classify(entry watcher.JournalEntry) *event.Event {
 		return ev
 	}
 
+	// T2 — Container exited with an error
+	if ev := c.classifyContainer(entry, ts); ev != nil {
+		return ev
+	}
+
 	// T3 — Service Failure
 	if ev := c.classifyServiceFailure(entry, ts); ev != nil {
 		return ev
@@ -49,24 +82,22 @@ func (c *Classifier) Classify(entry watcher.JournalEntry) *event.Event {
 }
 
 func (c *Classifier) classifyOOM(entry watcher.JournalEntry, ts time.Time) *event.Event {
-	for _, re := range oomPatterns {
-		if !re.MatchString(entry.Message) {
-			continue
-		}
-
-		process, pid := extractOOMProcess(entry.Message)
-		summary := "OOM Kill"
-		if process != "" {
-			summary = fmt.Sprintf("OOM Kill: %s (pid %d)", process, pid)
-		}
+	if !oomMatcher.MatchString(entry.Message) {
+		return nil
+	}
 
-		ev := event.New(c.instanceID, ts, event.TierOOMKill, event.SevCritical, summary)
-		ev.Process = process
-		ev.PID = pid
-		ev.RawFields = entry.Fields
-		return ev
+	process, pid := extractOOMProcess(entry.Message)
+	summary := "OOM Kill"
+	if process != "" {
+		summary = fmt.Sprintf("OOM Kill: %s (pid %d)", process, pid)
 	}
-	return nil
+
+	ev := event.New(c.instanceID, ts, event.TierOOMKill, event.SevCritical, summary)
+	ev.Process = process
+	ev.PID = pid
+	ev.RawFields = entry.Fields
+	tagCGroup(ev, entry.Message)
+	return ev
 }
 
 func (c *Classifier) classifyCrash(entry watcher.JournalEntry, ts time.Time) *event.Event {
@@ -86,25 +117,21 @@ func (c *Classifier) classifyCrash(entry watcher.JournalEntry, ts time.Time) *ev
 	}
 
 	// Check message-based crash patterns.
-	for _, re := range crashPatterns {
-		if !re.MatchString(entry.Message) {
-			continue
-		}
-
-		process, pid := extractCrashProcess(entry)
-		summary := "Process Crash"
-		if process != "" {
-			summary = fmt.Sprintf("Crash: %s (pid %d) segfault", process, pid)
-		}
+	if !crashMatcher.MatchString(entry.Message) {
+		return nil
+	}
 
-		ev := event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh, summary)
-		ev.Process = process
-		ev.PID = pid
-		ev.RawFields = entry.Fields
-		return ev
+	process, pid := extractCrashProcess(entry)
+	summary := "Process Crash"
+	if process != "" {
+		summary = fmt.Sprintf("Crash: %s (pid %d) segfault", process, pid)
 	}
 
-	return nil
+	ev := event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh, summary)
+	ev.Process = process
+	ev.PID = pid
+	ev.RawFields = entry.Fields
+	return ev
 }
 
 func (c *Classifier) classifyServiceFailure(entry watcher.JournalEntry, ts time.Time) *event.Event {
@@ -113,32 +140,39 @@ func (c *Classifier) classifyServiceFailure(entry watcher.JournalEntry, ts time.
 		return nil
 	}
 
-	for _, re := range serviceFailPatterns {
-		if !re.MatchString(entry.Message) {
-			continue
-		}
+	if !serviceFailMatcher.MatchString(entry.Message) {
+		return nil
+	}
 
-		unit := extractServiceUnit(entry)
-		if unit == "" {
-			// If we can't identify the unit, use the systemd unit field.
-			unit = entry.SystemdUnit
-		}
-		if unit == "" {
-			continue
-		}
+	unit := extractServiceUnit(entry)
+	if unit == "" {
+		// If we can't identify the unit, use the systemd unit field.
+		unit = entry.SystemdUnit
+	}
+	if unit == "" {
+		return nil
+	}
 
-		exitCode := extractExitCode(entry.Message)
-		summary := fmt.Sprintf("Service failed: %s", unit)
-		if exitCode != "" {
-			summary = fmt.Sprintf("Service failed: %s (exit %s)", unit, exitCode)
-		}
+	// The per-user service manager logs from a user unit of its own.
+	user := entry.Fields["_SYSTEMD_USER_UNIT"] != ""
+	kind := "Service"
+	if user {
+		kind = "User service"
+	}
 
-		ev := event.New(c.instanceID, ts, event.TierServiceFailure, event.SevMedium, summary)
-		ev.Unit = unit
-		ev.RawFields = entry.Fields
-		return ev
+	exitCode := extractExitCode(entry.Message)
+	summary := fmt.Sprintf("%s failed: %s", kind, unit)
+	if exitCode != "" {
+		summary = fmt.Sprintf("%s failed: %s (exit %s)", kind, unit, exitCode)
 	}
-	return nil
+
+	ev := event.New(c.instanceID, ts, event.TierServiceFailure, event.SevMedium, summary)
+	ev.Unit = unit
+	ev.RawFields = entry.Fields
+	if user {
+		ev.RawFields["_user_unit"] = "true"
+	}
+	return ev
 }
 
 // extractServiceUnit pulls the unit name from a systemd failure message.
@@ -150,9 +184,44 @@ func extractServiceUnit(entry watcher.JournalEntry) string {
 	if unit, ok := entry.Fields["UNIT"]; ok {
 		return unit
 	}
+	if unit, ok := entry.Fields["USER_UNIT"]; ok {
+		return unit
+	}
 	return ""
 }
 
+// extractDevice pulls the block device a kernel I/O error names.
+func extractDevice(msg string) string {
+	if m := kernelDeviceRe.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	if m := kernelFSDevRe.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// kernelHWDedupKey returns the dedup key of a kernel hardware error: the
+// block device it names, or for a GPU fault the card and what failed.
+// Without one, kernel errors of the tier all count as repeats.
+func kernelHWDedupKey(msg string) string {
+	if dev := extractDevice(msg); dev != "" {
+		return "device=" + dev
+	}
+	var pairs []string
+	if m := gpuPCIRe.FindStringSubmatch(msg); m != nil {
+		pairs = append(pairs, "gpu="+m[1])
+	}
+	if m := nvidiaXidRe.FindStringSubmatch(msg); m != nil {
+		pairs = append(pairs, "xid="+m[1])
+	} else if m := amdGPURingRe.FindStringSubmatch(msg); m != nil {
+		pairs = append(pairs, "ring="+m[1])
+	} else if m := i915ResetRe.FindStringSubmatch(msg); m != nil {
+		pairs = append(pairs, "engine="+m[1])
+	}
+	return strings.Join(pairs, ", ")
+}
+
 // extractExitCode pulls the exit status from a failure message.
 func extractExitCode(msg string) string {
 	if m := serviceExitCodeRe.FindStringSubmatch(msg); len(m) == 2 {
@@ -167,29 +236,108 @@ func (c *Classifier) classifyKernelHW(entry watcher.JournalEntry, ts time.Time)
 		return nil
 	}
 
+	if ev := c.classifyKernelOops(entry, ts); ev != nil {
+		return ev
+	}
+
 	// Check disk/CPU/generic HW patterns.
-	for _, re := range kernelHWPatterns {
-		if !re.MatchString(entry.Message) {
-			continue
-		}
+	if kernelHWMatcher.MatchString(entry.Message) {
 		summary := extractKernelHWSummary(entry.Message)
 		ev := event.New(c.instanceID, ts, event.TierKernelHW, event.SevHigh, summary)
 		ev.RawFields = entry.Fields
+		ev.DedupKey = kernelHWDedupKey(entry.Message)
+		if dev := extractDevice(entry.Message); dev != "" {
+			if ev.RawFields == nil {
+				ev.RawFields = make(map[string]string)
+			}
+			ev.RawFields["_device"] = dev
+		}
+		return ev
+	}
+
+	// Check Wi-Fi/Bluetooth firmware patterns.
+	if ev := c.classifyWireless(entry, ts); ev != nil {
 		return ev
 	}
 
 	// Check GPU-specific patterns.
-	for _, re := range gpuPatterns {
-		if !re.MatchString(entry.Message) {
+	if !gpuMatcher.MatchString(entry.Message) {
+		return nil
+	}
+	summary := extractGPUSummary(entry.Message)
+	ev := event.New(c.instanceID, ts, event.TierKernelHW, event.SevHigh, summary)
+	ev.RawFields = entry.Fields
+	ev.RawFields["_gpu_event"] = "true"
+	ev.DedupKey = kernelHWDedupKey(entry.Message)
+	return ev
+}
+
+// classifyKernelOops matches the first line of a kernel BUG, oops,
+// warning, or general protection fault report. The event is tagged so the
+// enricher gathers the rest of the report into its detail.
+func (c *Classifier) classifyKernelOops(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	msg := strings.TrimSpace(entry.Message)
+	for _, op := range kernelOopsPatterns {
+		m := op.re.FindStringSubmatch(msg)
+		if m == nil {
 			continue
 		}
-		summary := extractGPUSummary(entry.Message)
-		ev := event.New(c.instanceID, ts, event.TierKernelHW, event.SevHigh, summary)
+		summary := op.summary
+		if len(m) > 1 {
+			summary = fmt.Sprintf(op.summary, m[1])
+		}
+		if len(summary) > 100 {
+			summary = summary[:97] + "..."
+		}
+		ev := event.New(c.instanceID, ts, event.TierKernelHW, op.sev, summary)
 		ev.RawFields = entry.Fields
-		ev.RawFields["_gpu_event"] = "true"
+		if ev.RawFields == nil {
+			ev.RawFields = make(map[string]string)
+		}
+		ev.RawFields["_kernel_oops"] = "true"
+		ev.Detail = msg
 		return ev
 	}
+	return nil
+}
+
+// classifyWireless matches Wi-Fi and Bluetooth firmware crashes and resets.
+// The adapter is recorded as the event's process so repeated crashes of the
+// same adapter are aggregated by the cooldown logic, while different adapters
+// are tracked separately.
+func (c *Classifier) classifyWireless(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	if !wirelessMatcher.mayMatch(entry.Message) {
+		return nil
+	}
+	for _, wp := range wirelessPatterns {
+		m := wp.re.FindStringSubmatch(entry.Message)
+		if m == nil {
+			continue
+		}
+
+		driver := "bluetooth"
+		var adapter string
+		for i, name := range wp.re.SubexpNames() {
+			switch name {
+			case "driver":
+				driver = m[i]
+			case "adapter":
+				adapter = m[i]
+			}
+		}
+
+		summary := fmt.Sprintf("%s: %s", wp.label, adapter)
+		if driver != "bluetooth" {
+			summary = fmt.Sprintf("%s: %s (%s)", wp.label, adapter, driver)
+		}
 
+		ev := event.New(c.instanceID, ts, event.TierKernelHW, event.SevMedium, summary)
+		ev.Process = driver + " " + adapter
+		ev.RawFields = entry.Fields
+		ev.RawFields["_wireless_driver"] = driver
+		ev.RawFields["_wireless_adapter"] = adapter
+		return ev
+	}
 	return nil
 }
 
@@ -261,9 +409,66 @@ func CompositorLabel(process string) string {
 // ClassifyPSIEvent creates a T5 memory pressure event from PSI monitor data.
 // This is called directly from the main pipeline, not via journal entry classification.
 func (c *Classifier) ClassifyPSIEvent(someAvg10, fullAvg10 float64, detail string) *event.Event {
-	summary := fmt.Sprintf("Memory pressure: some=%.1f%% full=%.1f%%", someAvg10, fullAvg10)
+	return c.ClassifyPressureEvent("memory", event.TierMemPressure, someAvg10, fullAvg10, detail)
+}
+
+// ClassifyPressureEvent creates an event in tier for the start of a
+// pressure episode from PSI monitor data; resource is "memory", "cpu", or
+// "io". The pressure file is recorded as the event's process, so each
+// resource has its own cooldown and incident, which the episode's end
+// closes.
+func (c *Classifier) ClassifyPressureEvent(resource string, tier event.Tier, someAvg10, fullAvg10 float64, detail string) *event.Event {
+	summary := fmt.Sprintf("%s pressure: some=%.1f%% full=%.1f%%", psiName(resource), someAvg10, fullAvg10)
+	ev := event.New(c.instanceID, time.Now(), tier, event.SevWarning, summary)
+	ev.Process = "/proc/pressure/" + resource
+	ev.Detail = detail
+	ev.RawFields["_psi"] = resource
+	ev.RawFields["_psi_phase"] = "started"
+	return ev
+}
+
+// ClassifyPressureResolved creates an event in tier for the end of a
+// pressure episode that lasted duration, with its peak values.
+func (c *Classifier) ClassifyPressureResolved(resource string, tier event.Tier, duration time.Duration, peakSome, peakFull float64, detail string) *event.Event {
+	summary := fmt.Sprintf("%s pressure resolved after %s: peak some=%.1f%% full=%.1f%%",
+		psiName(resource), format.Duration(duration), peakSome, peakFull)
+	ev := event.New(c.instanceID, time.Now(), tier, event.SevWarning, summary)
+	ev.Process = "/proc/pressure/" + resource
+	ev.Detail = detail
+	ev.RawFields["_psi"] = resource
+	ev.RawFields["_psi_phase"] = "resolved"
+	ev.RawFields["_psi_duration"] = strconv.FormatFloat(duration.Seconds(), 'f', 0, 64)
+	return ev
+}
+
+// psiName returns a PSI resource's name for summaries.
+func psiName(resource string) string {
+	if resource == "memory" {
+		return "Memory"
+	}
+	return strings.ToUpper(resource)
+}
+
+// ClassifyLowMemoryEvent creates a T5 memory pressure event for available
+// memory staying below its threshold. The episode is one incident however
+// the largest processes change.
+func (c *Classifier) ClassifyLowMemoryEvent(availablePct float64, summary, detail string) *event.Event {
 	ev := event.New(c.instanceID, time.Now(), event.TierMemPressure, event.SevWarning, summary)
 	ev.Detail = detail
+	ev.DedupKey = "low_memory"
+	ev.RawFields["_low_memory"] = "true"
+	ev.RawFields["_mem_available_pct"] = strconv.FormatFloat(availablePct, 'f', 1, 64)
+	return ev
+}
+
+// ClassifyThrashEvent creates a T5 memory pressure event for sustained swap
+// thrashing. process is the process faulting the most, if known.
+func (c *Classifier) ClassifyThrashEvent(majFaultRate float64, process, summary, detail string) *event.Event {
+	ev := event.New(c.instanceID, time.Now(), event.TierMemPressure, event.SevHigh, summary)
+	ev.Process = process
+	ev.Detail = detail
+	ev.RawFields["_thrash"] = "true"
+	ev.RawFields["_majfault_rate"] = strconv.FormatFloat(majFaultRate, 'f', 0, 64)
 	return ev
 }
 
@@ -271,16 +476,200 @@ func (c *Classifier) ClassifyPSIEvent(someAvg10, fullAvg10 float64, detail strin
 func (c *Classifier) ClassifySMARTEvent(device, summary, detail string) *event.Event {
 	ev := event.New(c.instanceID, time.Now(), event.TierKernelHW, event.SevHigh, summary)
 	ev.Detail = detail
+	ev.DedupKey = SMARTDedupKey(device)
+	ev.RawFields["_device"] = device
+	return ev
+}
+
+// SMARTDedupKey returns the DedupKey of a device's SMART health events, for
+// recording its recovery.
+func SMARTDedupKey(device string) string {
+	return "device=" + device
+}
+
+// ClassifySMARTTempEvent creates a T4 warning for a drive that has stayed
+// above its temperature limit. The device is recorded as the event's process
+// so each drive has its own cooldown, apart from its health events.
+func (c *Classifier) ClassifySMARTTempEvent(device, summary, detail string) *event.Event {
+	ev := event.New(c.instanceID, time.Now(), event.TierKernelHW, event.SevWarning, summary)
+	ev.Process = device
+	ev.Detail = detail
+	ev.RawFields["_smart_temp"] = "true"
+	ev.RawFields["_device"] = device
+	return ev
+}
+
+// ClassifyArrayEvent creates a T4 kernel/HW event for a storage array that
+// is degraded or reporting errors. The array name is recorded as the event's
+// process so each array has its own cooldown and recovers on its own; kind
+// is "mdraid", "zfs", or "btrfs", and failed lists its failed members.
+func (c *Classifier) ClassifyArrayEvent(kind, name string, degraded bool, failed []string, summary, detail string) *event.Event {
+	sev := event.SevWarning
+	if degraded {
+		sev = event.SevCritical
+	}
+	ev := event.New(c.instanceID, time.Now(), event.TierKernelHW, sev, summary)
+	ev.Process = name
+	ev.Detail = detail
+	ev.RawFields["_array"] = kind
+	ev.RawFields["_array_name"] = name
+	if len(failed) > 0 {
+		ev.RawFields["_failed_devices"] = strings.Join(failed, ",")
+	}
+	return ev
+}
+
+// ClassifyTracedOOM creates a T1 event for an OOM kill seen by the eBPF
+// tracer, attributed to the owner of the victim's cgroup like one from the
+// kernel log. Its process is the victim's command, so the kernel's own
+// report of the kill, if it gets through, falls in the same cooldown.
+func (c *Classifier) ClassifyTracedOOM(ts time.Time, pid int, comm, cgroup, detail string) *event.Event {
+	ev := event.New(c.instanceID, ts, event.TierOOMKill, event.SevCritical,
+		fmt.Sprintf("OOM Kill: %s (pid %d)", comm, pid))
+	ev.Process = comm
+	ev.PID = pid
+	ev.Detail = detail
+	ev.RawFields["_traced"] = "ebpf"
+	if cgroup != "" {
+		ev.CGroup = cgroup
+		attributeCGroup(ev)
+	}
+	return ev
+}
+
+// ClassifyTracedCrash creates a T2 event for a process the eBPF tracer saw
+// killed by a crash signal, e.g. "SIGSEGV". Like ClassifyTracedOOM, it
+// shares its cooldown with the journal's report of the crash.
+func (c *Classifier) ClassifyTracedCrash(ts time.Time, pid int, comm, signal, cgroup, detail string) *event.Event {
+	ev := event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh,
+		fmt.Sprintf("Crash: %s (pid %d) killed by %s", comm, pid, signal))
+	ev.Process = comm
+	ev.PID = pid
+	ev.CGroup = cgroup
+	ev.Detail = detail
+	ev.RawFields["_traced"] = "ebpf"
+	ev.RawFields["_signal"] = signal
 	return ev
 }
 
 // ClassifyGPUEvent creates a T4 kernel/HW event from a GPU monitor threshold.
-func (c *Classifier) ClassifyGPUEvent(card, vendor, summary, detail string) *event.Event {
+// id is the card's PCI address, or its name if that is unknown; it is
+// recorded as the event's process, and with the reason as its dedup key, so
+// each card and reason has its own cooldown even if card numbers change
+// across reboots. card is the card's name, e.g. "card0", and reason is the
+// monitor's reason, e.g. "vram_high".
+func (c *Classifier) ClassifyGPUEvent(id, card, vendor, reason, summary, detail string) *event.Event {
 	ev := event.New(c.instanceID, time.Now(), event.TierKernelHW, event.SevHigh, summary)
+	ev.Process = id
 	ev.Detail = detail
+	ev.DedupKey = GPUDedupKey(id, reason)
 	ev.RawFields["_gpu_event"] = "true"
 	ev.RawFields["_gpu_vendor"] = vendor
 	ev.RawFields["_gpu_card"] = card
+	if id != card {
+		ev.RawFields["_gpu_pci"] = id
+	}
+	ev.RawFields["_gpu_reason"] = reason
+	return ev
+}
+
+// GPUDedupKey returns the DedupKey of a card's GPU events for reason, for
+// recording its recovery.
+func GPUDedupKey(id, reason string) string {
+	return "gpu=" + id + ", reason=" + reason
+}
+
+// ClassifyInventoryEvent creates a T4 kernel/HW event for hardware missing
+// from the inventory: a disk, GPU, or NIC that disappeared, or memory that
+// shrank. The device is recorded as the event's process so each device has
+// its own cooldown; kind is "disk", "gpu", "nic", or "memory".
+func (c *Classifier) ClassifyInventoryEvent(kind, device, summary, detail string) *event.Event {
+	ev := event.New(c.instanceID, time.Now(), event.TierKernelHW, event.SevHigh, summary)
+	ev.Process = device
+	ev.Detail = detail
+	ev.RawFields["_inventory"] = kind
+	ev.RawFields["_device"] = device
+	return ev
+}
+
+// ClassifyQuotaEvent creates a T6 resource event from a quota monitor reading.
+// The subject is recorded as the event's process so each subject has its own
+// cooldown.
+func (c *Classifier) ClassifyQuotaEvent(subject string, exceeded bool, summary, detail string) *event.Event {
+	sev := event.SevWarning
+	if exceeded {
+		sev = event.SevHigh
+	}
+	ev := event.New(c.instanceID, time.Now(), event.TierResource, sev, summary)
+	ev.Process = subject
+	ev.Detail = detail
+	ev.RawFields["_quota_subject"] = subject
+	return ev
+}
+
+// ClassifyDiskSpaceEvent creates a T6 resource event from a disk space
+// reading. The mountpoint is recorded as the event's process so each
+// filesystem has its own cooldown.
+func (c *Classifier) ClassifyDiskSpaceEvent(mount string, critical bool, summary, detail string) *event.Event {
+	sev := event.SevWarning
+	if critical {
+		sev = event.SevHigh
+	}
+	ev := event.New(c.instanceID, time.Now(), event.TierResource, sev, summary)
+	ev.Process = mount
+	ev.Detail = detail
+	ev.RawFields["_mountpoint"] = mount
+	return ev
+}
+
+// ClassifyUnitLimitEvent creates a T6 resource event for a unit nearing its
+// file descriptor or task limit.
+func (c *Classifier) ClassifyUnitLimitEvent(unit, resource string, critical bool, summary, detail string) *event.Event {
+	sev := event.SevWarning
+	if critical {
+		sev = event.SevHigh
+	}
+	ev := event.New(c.instanceID, time.Now(), event.TierResource, sev, summary)
+	ev.Unit = unit
+	ev.Detail = detail
+	ev.RawFields["UNIT"] = unit
+	ev.RawFields["_limit_resource"] = resource
+	return ev
+}
+
+// ClassifyUnitEvent creates a T3 event for a unit that systemd reported as
+// failed over D-Bus. result is the service result (e.g. "exit-code") and
+// restarts the number of automatic restarts; both are kept as raw fields.
+func (c *Classifier) ClassifyUnitEvent(unit, result string, restarts uint32, summary, detail string) *event.Event {
+	ev := event.New(c.instanceID, time.Now(), event.TierServiceFailure, event.SevMedium, summary)
+	ev.Unit = unit
+	ev.Detail = detail
+	ev.RawFields["UNIT"] = unit
+	ev.RawFields["_source"] = "dbus"
+	if result != "" {
+		ev.RawFields["_unit_result"] = result
+	}
+	ev.RawFields["_n_restarts"] = strconv.FormatUint(uint64(restarts), 10)
+	return ev
+}
+
+// ClassifyRebootEvent creates a T7 event for a previous boot that ended
+// without a clean shutdown. ts is when that boot's journal ended. A kernel
+// panic is critical; any other unexpected reboot is high.
+func (c *Classifier) ClassifyRebootEvent(ts time.Time, panicked bool, bootID, summary, detail string) *event.Event {
+	sev := event.SevHigh
+	if panicked {
+		sev = event.SevCritical
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	ev := event.New(c.instanceID, ts, event.TierReboot, sev, summary)
+	ev.Process = "kernel"
+	ev.Detail = detail
+	if bootID != "" {
+		ev.RawFields["_boot_id"] = bootID
+	}
 	return ev
 }
 
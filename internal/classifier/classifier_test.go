@@ -1,7 +1,9 @@
 package classifier
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/setevik/logtriage/internal/event"
 	"github.com/setevik/logtriage/internal/watcher"
@@ -194,6 +196,7 @@ func TestClassifyServiceFailure(t *testing.T) {
 		wantNil bool
 		tier    event.Tier
 		unit    string
+		user    bool
 	}{
 		{
 			name: "service entered failed state",
@@ -231,6 +234,19 @@ func TestClassifyServiceFailure(t *testing.T) {
 			tier: event.TierServiceFailure,
 			unit: "myapp.service",
 		},
+		{
+			name: "user service failed with result",
+			entry: watcher.JournalEntry{
+				Message:           "pipewire.service: Failed with result 'core-dump'.",
+				Priority:          4,
+				SyslogIdentifier:  "systemd",
+				RealtimeTimestamp: "1708300000000000",
+				Fields:            map[string]string{"_SYSTEMD_USER_UNIT": "init.scope", "USER_UNIT": "pipewire.service"},
+			},
+			tier: event.TierServiceFailure,
+			unit: "pipewire.service",
+			user: true,
+		},
 		{
 			name: "non-systemd identifier should not match",
 			entry: watcher.JournalEntry{
@@ -278,6 +294,12 @@ func TestClassifyServiceFailure(t *testing.T) {
 			if ev.Severity != event.SevMedium {
 				t.Errorf("severity = %q, expected medium", ev.Severity)
 			}
+			if got := ev.RawFields["_user_unit"] != ""; got != tt.user {
+				t.Errorf("user unit = %v, want %v", got, tt.user)
+			}
+			if tt.user && !strings.HasPrefix(ev.Summary, "User service failed: ") {
+				t.Errorf("summary = %q", ev.Summary)
+			}
 		})
 	}
 }
@@ -291,6 +313,7 @@ func TestClassifyKernelHW(t *testing.T) {
 		wantNil bool
 		tier    event.Tier
 		summary string
+		device  string
 	}{
 		{
 			name: "I/O error on disk",
@@ -304,6 +327,7 @@ func TestClassifyKernelHW(t *testing.T) {
 			},
 			tier:    event.TierKernelHW,
 			summary: "I/O error on /dev/sda",
+			device:  "sda",
 		},
 		{
 			name: "EXT4 filesystem error",
@@ -317,6 +341,7 @@ func TestClassifyKernelHW(t *testing.T) {
 			},
 			tier:    event.TierKernelHW,
 			summary: "EXT4 error on /dev/sda1",
+			device:  "sda1",
 		},
 		{
 			name: "GPU hang",
@@ -406,19 +431,157 @@ func TestClassifyKernelHW(t *testing.T) {
 			if ev.Severity != event.SevHigh {
 				t.Errorf("severity = %q, expected high", ev.Severity)
 			}
+			if tt.device != "" && ev.DedupKey != "device="+tt.device {
+				t.Errorf("DedupKey = %q, want device=%s", ev.DedupKey, tt.device)
+			}
+			if ev.RawFields["_device"] != tt.device {
+				t.Errorf("_device = %q, want %q", ev.RawFields["_device"], tt.device)
+			}
 		})
 	}
 }
 
-func TestClassifyGPUPatterns(t *testing.T) {
+func TestClassifyWireless(t *testing.T) {
 	c := New("testhost")
 
 	tests := []struct {
 		name    string
-		entry   watcher.JournalEntry
-		tier    event.Tier
+		message string
+		summary string
+		process string
+	}{
+		{
+			name:    "iwlwifi microcode error",
+			message: "iwlwifi 0000:00:14.3: Microcode SW error detected. Restarting 0x0.",
+			summary: "Wi-Fi firmware crash: 0000:00:14.3 (iwlwifi)",
+			process: "iwlwifi 0000:00:14.3",
+		},
+		{
+			name:    "ath11k firmware crashed",
+			message: "ath11k_pci 0000:03:00.0: firmware crashed: MHI_CB_EE_RDDM",
+			summary: "Wi-Fi firmware crash: 0000:03:00.0 (ath11k_pci)",
+			process: "ath11k_pci 0000:03:00.0",
+		},
+		{
+			name:    "mt7921e message timeout",
+			message: "mt7921e 0000:01:00.0: Message 00020007 (seq 11) timeout",
+			summary: "Wi-Fi firmware timeout: 0000:01:00.0 (mt7921e)",
+			process: "mt7921e 0000:01:00.0",
+		},
+		{
+			name:    "bluetooth firmware load failure",
+			message: "Bluetooth: hci0: Failed to load Intel firmware file intel/ibt-19-0-4.sfi (-2)",
+			summary: "Bluetooth firmware failure: hci0",
+			process: "bluetooth hci0",
+		},
+		{
+			name:    "bluetooth command timeout",
+			message: "Bluetooth: hci1: command 0xfc05 tx timeout",
+			summary: "Bluetooth command timeout: hci1",
+			process: "bluetooth hci1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := c.Classify(watcher.JournalEntry{
+				Message:           tt.message,
+				Priority:          3,
+				SyslogIdentifier:  "kernel",
+				Transport:         "kernel",
+				RealtimeTimestamp: "1708300000000000",
+				Fields:            map[string]string{},
+			})
+			if ev == nil {
+				t.Fatal("expected event, got nil")
+			}
+			if ev.Tier != event.TierKernelHW {
+				t.Errorf("tier = %q, want T4", ev.Tier)
+			}
+			if ev.Severity != event.SevMedium {
+				t.Errorf("severity = %q, want medium", ev.Severity)
+			}
+			if ev.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", ev.Summary, tt.summary)
+			}
+			if ev.Process != tt.process {
+				t.Errorf("process = %q, want %q", ev.Process, tt.process)
+			}
+		})
+	}
+
+	// Follow-up lines of an iwlwifi error dump must not produce extra events.
+	ev := c.Classify(watcher.JournalEntry{
+		Message:          "iwlwifi 0000:00:14.3: Start IWL Error Log Dump:",
+		SyslogIdentifier: "kernel",
+		Transport:        "kernel",
+		Fields:           map[string]string{},
+	})
+	if ev != nil {
+		t.Errorf("error log dump continuation should not match, got %q", ev.Summary)
+	}
+}
+
+func TestClassifyKernelOops(t *testing.T) {
+	c := New("testhost")
+
+	tests := []struct {
+		msg     string
 		summary string
-		gpuFlag bool
+		sev     event.Severity
+	}{
+		{"BUG: kernel NULL pointer dereference, address: 0000000000000008", "Kernel BUG: kernel NULL pointer dereference", event.SevHigh},
+		{"BUG: unable to handle page fault for address: ffffa0c4c0000000", "Kernel BUG: unable to handle page fault", event.SevHigh},
+		{"BUG: soft lockup - CPU#2 stuck for 22s! [kworker/2:1:123]", "Kernel BUG: soft lockup - CPU#2 stuck for 22s! [kworker/2:1:123]", event.SevHigh},
+		{"kernel BUG at mm/slub.c:4431!", "Kernel BUG at mm/slub.c:4431", event.SevHigh},
+		{"general protection fault, probably for non-canonical address 0xdead000000000100: 0000 [#1] PREEMPT SMP NOPTI", "Kernel general protection fault", event.SevHigh},
+		{"Unable to handle kernel NULL pointer dereference at virtual address 0000000000000010", "Kernel oops: unable to handle NULL pointer dereference", event.SevHigh},
+		{"WARNING: CPU: 3 PID: 1234 at drivers/gpu/drm/drm_atomic.c:123 drm_atomic_check+0x45/0x90 [drm]", "Kernel warning at drivers/gpu/drm/drm_atomic.c:123", event.SevMedium},
+	}
+	for _, tt := range tests {
+		ev := c.Classify(watcher.JournalEntry{
+			Message:           tt.msg,
+			Priority:          4,
+			SyslogIdentifier:  "kernel",
+			Transport:         "kernel",
+			RealtimeTimestamp: "1708300000000000",
+			Fields:            map[string]string{"MESSAGE": tt.msg},
+		})
+		if ev == nil {
+			t.Errorf("%q: no event", tt.msg)
+			continue
+		}
+		if ev.Tier != event.TierKernelHW || ev.Summary != tt.summary || ev.Severity != tt.sev {
+			t.Errorf("%q: got %s %q (%s), want T4 %q (%s)", tt.msg, ev.Tier, ev.Summary, ev.Severity, tt.summary, tt.sev)
+		}
+		if ev.RawFields["_kernel_oops"] != "true" {
+			t.Errorf("%q: not tagged as a kernel report", tt.msg)
+		}
+	}
+
+	// A user-space general protection fault is a process crash, and the
+	// lines inside a report are not reports of their own.
+	for _, msg := range []string{
+		"traps: app[4242] general protection fault ip:7f3a12 sp:7ffc error:0 in libc.so.6",
+		"Call Trace:",
+		"Oops: 0000 [#1] PREEMPT SMP NOPTI",
+	} {
+		if IsKernelReportStart(msg) {
+			t.Errorf("%q starts a kernel report", msg)
+		}
+	}
+}
+
+func TestClassifyGPUPatterns(t *testing.T) {
+	c := New("testhost")
+
+	tests := []struct {
+		name     string
+		entry    watcher.JournalEntry
+		tier     event.Tier
+		summary  string
+		gpuFlag  bool
+		dedupKey string
 	}{
 		{
 			name: "NVIDIA Xid 31 memory page fault",
@@ -430,9 +593,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "NVIDIA Xid 31: GPU memory page fault",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "NVIDIA Xid 31: GPU memory page fault",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:04:00, xid=31",
 		},
 		{
 			name: "NVIDIA GPU fallen off bus",
@@ -444,9 +608,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "NVIDIA GPU fallen off bus (fatal)",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "NVIDIA GPU fallen off bus (fatal)",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:01:00",
 		},
 		{
 			name: "NVIDIA VRAM out of memory",
@@ -472,9 +637,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "AMD GPU reset",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "AMD GPU reset",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:03:00",
 		},
 		{
 			name: "AMD GPU ring timeout",
@@ -486,9 +652,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "AMD GPU ring gfx_0.0.0 timeout",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "AMD GPU ring gfx_0.0.0 timeout",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:03:00, ring=gfx_0.0.0",
 		},
 		{
 			name: "AMD VRAM protection fault",
@@ -528,9 +695,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "AMD GPU thermal fault",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "AMD GPU thermal fault",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:03:00",
 		},
 		{
 			name: "Intel i915 engine reset",
@@ -542,9 +710,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "Intel GPU resetting rcs0: hang on rcs0",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "Intel GPU resetting rcs0: hang on rcs0",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:00:02, engine=rcs0",
 		},
 		{
 			name: "Intel i915 chip reset",
@@ -556,9 +725,10 @@ func TestClassifyGPUPatterns(t *testing.T) {
 				RealtimeTimestamp: "1708300000000000",
 				Fields:            map[string]string{},
 			},
-			tier:    event.TierKernelHW,
-			summary: "Intel GPU resetting chip: GuC failed to respond",
-			gpuFlag: true,
+			tier:     event.TierKernelHW,
+			summary:  "Intel GPU resetting chip: GuC failed to respond",
+			gpuFlag:  true,
+			dedupKey: "gpu=0000:00:02, engine=chip",
 		},
 		{
 			name: "DRM flip timeout",
@@ -591,13 +761,16 @@ func TestClassifyGPUPatterns(t *testing.T) {
 			if tt.gpuFlag && ev.RawFields["_gpu_event"] != "true" {
 				t.Error("expected _gpu_event=true in RawFields")
 			}
+			if ev.DedupKey != tt.dedupKey {
+				t.Errorf("DedupKey = %q, want %q", ev.DedupKey, tt.dedupKey)
+			}
 		})
 	}
 }
 
 func TestClassifyGPUEvent(t *testing.T) {
 	c := New("testhost")
-	ev := c.ClassifyGPUEvent("card0", "amd", "GPU thermal warning: card0 92°C", "Temperature: 92°C")
+	ev := c.ClassifyGPUEvent("0000:03:00.0", "card0", "amd", "thermal_warning", "GPU thermal warning: card0 (0000:03:00.0) 92°C", "Temperature: 92°C")
 	if ev == nil {
 		t.Fatal("expected event")
 	}
@@ -610,6 +783,154 @@ func TestClassifyGPUEvent(t *testing.T) {
 	if ev.RawFields["_gpu_vendor"] != "amd" {
 		t.Errorf("_gpu_vendor = %q, want amd", ev.RawFields["_gpu_vendor"])
 	}
+	if ev.RawFields["_gpu_reason"] != "thermal_warning" {
+		t.Errorf("_gpu_reason = %q, want thermal_warning", ev.RawFields["_gpu_reason"])
+	}
+	if ev.RawFields["_gpu_card"] != "card0" || ev.RawFields["_gpu_pci"] != "0000:03:00.0" {
+		t.Errorf("_gpu_card = %q, _gpu_pci = %q", ev.RawFields["_gpu_card"], ev.RawFields["_gpu_pci"])
+	}
+	if ev.Process != "0000:03:00.0" || ev.DedupKey != "gpu=0000:03:00.0, reason=thermal_warning" {
+		t.Errorf("Process = %q, DedupKey = %q", ev.Process, ev.DedupKey)
+	}
+
+	// A card of unknown address is keyed on its name.
+	ev = c.ClassifyGPUEvent("card0", "card0", "intel", "vram_high", "GPU VRAM high: card0 95%", "")
+	if ev.DedupKey != "gpu=card0, reason=vram_high" {
+		t.Errorf("DedupKey = %q", ev.DedupKey)
+	}
+	if _, ok := ev.RawFields["_gpu_pci"]; ok {
+		t.Error("_gpu_pci set for a card of unknown address")
+	}
+}
+
+func TestClassifyRebootEvent(t *testing.T) {
+	c := New("testhost")
+	ts := time.Date(2024, 3, 1, 4, 12, 0, 0, time.UTC)
+
+	ev := c.ClassifyRebootEvent(ts, true, "abc123", "Kernel panic before reboot", "Kernel reported: ...")
+	if ev.Tier != event.TierReboot || ev.Severity != event.SevCritical {
+		t.Errorf("panic: tier=%q severity=%q", ev.Tier, ev.Severity)
+	}
+	if !ev.Timestamp.Equal(ts) || ev.RawFields["_boot_id"] != "abc123" {
+		t.Errorf("timestamp=%v boot_id=%q", ev.Timestamp, ev.RawFields["_boot_id"])
+	}
+
+	ev = c.ClassifyRebootEvent(time.Time{}, false, "", "Unexpected reboot", "")
+	if ev.Severity != event.SevHigh || ev.Timestamp.IsZero() {
+		t.Errorf("unclean: severity=%q timestamp=%v", ev.Severity, ev.Timestamp)
+	}
+}
+
+func TestClassifyUnitEvent(t *testing.T) {
+	c := New("testhost")
+	ev := c.ClassifyUnitEvent("nginx.service", "exit-code", 3, "Service failed: nginx.service (exit-code)", "Unit: nginx.service")
+	if ev.Tier != event.TierServiceFailure || ev.Severity != event.SevMedium || ev.Unit != "nginx.service" {
+		t.Errorf("tier=%q severity=%q unit=%q", ev.Tier, ev.Severity, ev.Unit)
+	}
+	if ev.RawFields["_unit_result"] != "exit-code" || ev.RawFields["_n_restarts"] != "3" {
+		t.Errorf("raw fields = %v", ev.RawFields)
+	}
+}
+
+func TestClassifyRestartLoop(t *testing.T) {
+	c := New("testhost")
+	base := time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	// Each failure is logged twice by systemd; the pair counts once.
+	var recent []*event.Event
+	for i := 3; i >= 0; i-- {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		failed := event.New("testhost", ts.Add(10*time.Millisecond), event.TierServiceFailure, event.SevMedium, "Service failed: app.service")
+		failed.RawFields["MESSAGE"] = "app.service: Failed with result 'exit-code'."
+		exited := event.New("testhost", ts, event.TierServiceFailure, event.SevMedium, "Service failed: app.service (exit 2)")
+		exited.RawFields["MESSAGE"] = "app.service: Main process exited, code=exited, status=2/INVALIDARGUMENT"
+		recent = append(recent, failed, exited)
+	}
+
+	if loop, looping := c.ClassifyRestartLoop("app.service", recent, 5, 10*time.Minute); loop != nil || looping {
+		t.Fatalf("4 failures reported as a loop: %v", loop)
+	}
+
+	loop, looping := c.ClassifyRestartLoop("app.service", recent, 4, 10*time.Minute)
+	if loop == nil || !looping {
+		t.Fatal("expected a restart loop event")
+	}
+	if loop.Severity != event.SevHigh || loop.Unit != "app.service" || loop.RawFields["_restart_loop"] != "4" {
+		t.Errorf("severity=%q unit=%q raw=%v", loop.Severity, loop.Unit, loop.RawFields)
+	}
+	if loop.Summary != "Restart loop: app.service (4 failures in 10m)" {
+		t.Errorf("summary = %q", loop.Summary)
+	}
+	if strings.Count(loop.Detail, "exit 2") != 4 {
+		t.Errorf("detail should list each exit status once:\n%s", loop.Detail)
+	}
+
+	// Once reported, the loop is not reported again within the window.
+	recent = append([]*event.Event{loop}, recent...)
+	if again, looping := c.ClassifyRestartLoop("app.service", recent, 4, 10*time.Minute); again != nil || !looping {
+		t.Errorf("loop reported twice: %v, %v", again, looping)
+	}
+}
+
+func TestStormGuard(t *testing.T) {
+	c := New("testhost")
+	g := c.NewStormGuard(10)
+	base := time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)
+	ioErr := func() *event.Event {
+		return event.New("testhost", base, event.TierKernelHW, event.SevHigh, "I/O error on sda")
+	}
+
+	// Ten events a minute are within the rate.
+	for i := range 10 {
+		if storm, collapsed := g.Observe(ioErr(), base.Add(time.Duration(i)*time.Second)); storm != nil || collapsed {
+			t.Fatalf("event %d: storm=%v collapsed=%v", i, storm, collapsed)
+		}
+	}
+
+	// The eleventh starts a storm and is collapsed into it.
+	now := base.Add(10 * time.Second)
+	storm, collapsed := g.Observe(ioErr(), now)
+	if storm == nil || !collapsed {
+		t.Fatalf("storm=%v collapsed=%v", storm, collapsed)
+	}
+	if storm.Tier != event.TierKernelHW || storm.Severity != event.SevHigh || storm.RawFields["_storm"] != "start" {
+		t.Errorf("tier=%q severity=%q raw=%v", storm.Tier, storm.Severity, storm.RawFields)
+	}
+	if storm.Summary != `Event storm: 11 events in 1m, mostly "I/O error on sda"` {
+		t.Errorf("summary = %q", storm.Summary)
+	}
+
+	// Storm events pass through, as do critical events.
+	if _, collapsed := g.Observe(storm, now); collapsed {
+		t.Error("storm event collapsed")
+	}
+	oom := event.New("testhost", now, event.TierOOMKill, event.SevCritical, "OOM kill: firefox")
+	if again, collapsed := g.Observe(oom, now); again != nil || collapsed {
+		t.Errorf("critical event: storm=%v collapsed=%v", again, collapsed)
+	}
+	for range 3 {
+		if again, collapsed := g.Observe(ioErr(), now); again != nil || !collapsed {
+			t.Fatalf("storm=%v collapsed=%v", again, collapsed)
+		}
+	}
+
+	// The storm lasts while the rate stays above half.
+	if end := g.Check(now.Add(30 * time.Second)); end != nil {
+		t.Fatalf("storm ended early: %v", end)
+	}
+	end := g.Check(now.Add(2 * time.Minute))
+	if end == nil {
+		t.Fatal("expected the storm to end")
+	}
+	if end.Tier != event.TierKernelHW || end.Severity != event.SevWarning || end.RawFields["_storm_events"] != "4" {
+		t.Errorf("tier=%q severity=%q raw=%v", end.Tier, end.Severity, end.RawFields)
+	}
+	if !strings.Contains(end.Detail, "4  I/O error on sda") {
+		t.Errorf("detail should count the collapsed events:\n%s", end.Detail)
+	}
+	if storm, collapsed := g.Observe(ioErr(), now.Add(2*time.Minute)); storm != nil || collapsed {
+		t.Errorf("after the storm: storm=%v collapsed=%v", storm, collapsed)
+	}
 }
 
 func TestIsCompositorProcess(t *testing.T) {
@@ -642,6 +963,50 @@ func TestClassifyPSIEvent(t *testing.T) {
 	}
 }
 
+func TestClassifyPressureEvent(t *testing.T) {
+	c := New("testhost")
+
+	ev := c.ClassifyPressureEvent("io", event.TierResource, 72.5, 31.0, "PSI io ...")
+	if ev.Tier != event.TierResource || ev.Summary != "IO pressure: some=72.5% full=31.0%" {
+		t.Errorf("event = %s %q", ev.Tier, ev.Summary)
+	}
+	if ev.Process != "/proc/pressure/io" || ev.RawFields["_psi"] != "io" || ev.RawFields["_psi_phase"] != "started" {
+		t.Errorf("Process = %q, fields = %v", ev.Process, ev.RawFields)
+	}
+}
+
+func TestClassifyPressureResolved(t *testing.T) {
+	c := New("testhost")
+
+	ev := c.ClassifyPressureResolved("memory", event.TierMemPressure, 3*time.Minute+20*time.Second, 81.4, 22.0, "Duration: 3m")
+	if ev.Tier != event.TierMemPressure {
+		t.Errorf("tier = %q, want T5", ev.Tier)
+	}
+	if want := "Memory pressure resolved after 3m: peak some=81.4% full=22.0%"; ev.Summary != want {
+		t.Errorf("summary = %q, want %q", ev.Summary, want)
+	}
+	// Grouped with the start of the episode, so they are one incident.
+	start := c.ClassifyPSIEvent(81.4, 22.0, "")
+	if ev.Process != start.Process || ev.DedupKey != start.DedupKey {
+		t.Errorf("Process = %q, start's = %q", ev.Process, start.Process)
+	}
+	if ev.RawFields["_psi_phase"] != "resolved" || ev.RawFields["_psi_duration"] != "200" {
+		t.Errorf("fields = %v", ev.RawFields)
+	}
+}
+
+func TestClassifyLowMemoryEvent(t *testing.T) {
+	c := New("testhost")
+
+	ev := c.ClassifyLowMemoryEvent(3.25, "Low memory: 250.0 MB available (3.2%)", "Available memory: ...")
+	if ev.Tier != event.TierMemPressure || ev.Severity != event.SevWarning {
+		t.Errorf("event = %s %s", ev.Tier, ev.Severity)
+	}
+	if ev.DedupKey != "low_memory" || ev.RawFields["_mem_available_pct"] != "3.2" {
+		t.Errorf("DedupKey = %q, fields = %v", ev.DedupKey, ev.RawFields)
+	}
+}
+
 func TestClassifySMARTEvent(t *testing.T) {
 	c := New("testhost")
 
@@ -655,6 +1020,29 @@ func TestClassifySMARTEvent(t *testing.T) {
 	if ev.Severity != event.SevHigh {
 		t.Errorf("severity = %q, want high", ev.Severity)
 	}
+	if ev.RawFields["_device"] != "/dev/sda" {
+		t.Errorf("_device = %q, want /dev/sda", ev.RawFields["_device"])
+	}
+}
+
+func TestClassifyArrayEvent(t *testing.T) {
+	c := New("testhost")
+
+	ev := c.ClassifyArrayEvent("mdraid", "md0", true, []string{"sda1"}, "RAID degraded: md0", "Array: md0 (mdraid)")
+	if ev.Tier != event.TierKernelHW || ev.Severity != event.SevCritical {
+		t.Errorf("tier, severity = %q, %q; want T4, critical", ev.Tier, ev.Severity)
+	}
+	if ev.Process != "md0" || ev.RawFields["_array"] != "mdraid" || ev.RawFields["_failed_devices"] != "sda1" {
+		t.Errorf("process = %q, raw fields = %v", ev.Process, ev.RawFields)
+	}
+
+	ev = c.ClassifyArrayEvent("btrfs", "/srv", false, nil, "btrfs errors: /srv", "")
+	if ev.Severity != event.SevWarning {
+		t.Errorf("errors only: severity = %q, want warning", ev.Severity)
+	}
+	if _, ok := ev.RawFields["_failed_devices"]; ok {
+		t.Error("_failed_devices set with no failed devices")
+	}
 }
 
 func TestClassifyTimestampParsing(t *testing.T) {
@@ -677,3 +1065,29 @@ func TestClassifyTimestampParsing(t *testing.T) {
 		t.Errorf("timestamp year = %d, expected >= 2024", ev.Timestamp.Year())
 	}
 }
+
+func TestClassifyTraced(t *testing.T) {
+	c := New("testhost")
+	ts := time.Unix(1708300000, 0)
+
+	ev := c.ClassifyTracedOOM(ts, 4242, "java", "/system.slice/app.service", "detail")
+	if ev.Tier != event.TierOOMKill || ev.Severity != event.SevCritical {
+		t.Errorf("tier/severity = %s/%s", ev.Tier, ev.Severity)
+	}
+	if ev.Summary != "OOM Kill: java (pid 4242) in app.service" || ev.Unit != "app.service" {
+		t.Errorf("summary = %q, unit = %q", ev.Summary, ev.Unit)
+	}
+	if ev.RawFields["_traced"] != "ebpf" {
+		t.Errorf("_traced = %q", ev.RawFields["_traced"])
+	}
+
+	ev = c.ClassifyTracedOOM(ts, 1, "app", "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1b2c3d4e_5f60_7182_93a4_b5c6d7e8f901.slice/cri-containerd-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.scope", "")
+	if ev.DedupKey != "pod=1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901" {
+		t.Errorf("DedupKey = %q, want the pod", ev.DedupKey)
+	}
+
+	ev = c.ClassifyTracedCrash(ts, 77, "foo", "SIGSEGV", "", "")
+	if ev.Tier != event.TierProcessCrash || ev.Summary != "Crash: foo (pid 77) killed by SIGSEGV" || ev.Process != "foo" {
+		t.Errorf("crash = %s %q %q", ev.Tier, ev.Summary, ev.Process)
+	}
+}
@@ -0,0 +1,223 @@
+package classifier
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// Container runtimes, as recorded in the _container_runtime raw field.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+	RuntimeCRI    = "cri" // a Kubernetes pod container, reached through crictl
+)
+
+// podCrashLoopRestarts is the restart count from which an exiting pod
+// container is reported as crash looping rather than as one exit.
+const podCrashLoopRestarts = 3
+
+// containerIdentifiers are syslog identifiers of container runtimes whose
+// logs report containers exiting.
+var containerIdentifiers = map[string]string{
+	"dockerd":    RuntimeDocker,
+	"containerd": RuntimeDocker,
+	"podman":     RuntimePodman,
+	"conmon":     RuntimePodman,
+}
+
+// containerDiedRe matches a runtime reporting that a container exited.
+// Examples:
+//
+//	podman: "container died 3c5b1f0e9a2d... (image=docker.io/library/nginx:latest, name=web)"
+//	dockerd: `level=info msg="container 3c5b1f0e9a2d died" exitCode=137`
+var containerDiedRe = regexp.MustCompile(`\bcontainer (?:died|[0-9a-f]{12,64} died)\b`)
+
+// containerIDRe extracts a container ID from a runtime log line.
+var containerIDRe = regexp.MustCompile(`\b(?:container(?: died)?[= ]|id=)([0-9a-f]{12,64})\b`)
+
+// containerNameRe extracts the name=... attribute podman appends to events.
+var containerNameRe = regexp.MustCompile(`\bname=([^,)\s]+)`)
+
+// containerExitRe extracts an exit code from a runtime log line.
+var containerExitRe = regexp.MustCompile(`\b(?:exitCode|exit_code|exitStatus|exit_status|exit code)[=: ]"?(\d+)`)
+
+// cgroupContainerRe extracts the runtime and ID from a container's cgroup
+// path, as the kernel prints it in task_memcg.
+// Examples:
+//
+//	/system.slice/docker-3c5b...e9a2d.scope
+//	/docker/3c5b...e9a2d
+//	/machine.slice/libpod-3c5b...e9a2d.scope/container
+//	/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-3c5b...e9a2d.scope
+var cgroupContainerRe = regexp.MustCompile(`(?:/docker[-/]|/libpod-)([0-9a-f]{64})`)
+
+// cgroupPodContainerRe extracts the ID of a Kubernetes pod container from
+// its cgroup path, with the systemd or the cgroupfs cgroup driver.
+// Examples:
+//
+//	/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1b2c3d4e_....slice/cri-containerd-3c5b...e9a2d.scope
+//	/kubepods/burstable/pod1b2c3d4e-.../3c5b...e9a2d
+var cgroupPodContainerRe = regexp.MustCompile(`/kubepods\b.*/(?:cri-containerd-|crio-|docker-)?([0-9a-f]{64})`)
+
+// cgroupPodRe extracts the pod UID from a Kubernetes pod container's cgroup
+// path. The systemd cgroup driver writes it with underscores.
+var cgroupPodRe = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// ContainerFromCgroup returns the runtime and full ID of the container a
+// cgroup path belongs to, or empty strings if it is not a container cgroup.
+func ContainerFromCgroup(path string) (runtime, id string) {
+	if m := cgroupPodContainerRe.FindStringSubmatch(path); m != nil {
+		return RuntimeCRI, m[1]
+	}
+	m := cgroupContainerRe.FindStringSubmatch(path)
+	if m == nil {
+		return "", ""
+	}
+	if strings.Contains(m[0], "libpod") {
+		return RuntimePodman, m[1]
+	}
+	return RuntimeDocker, m[1]
+}
+
+// podFromCgroup returns the UID of the Kubernetes pod a cgroup path belongs
+// to, or "" if it is not a pod cgroup.
+func podFromCgroup(path string) string {
+	m := cgroupPodRe.FindStringSubmatch(path)
+	if m == nil {
+		return ""
+	}
+	return strings.ReplaceAll(m[1], "_", "-")
+}
+
+// ShortContainerID returns the 12-character form of a container ID that
+// docker ps and podman ps show.
+func ShortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// classifyContainer matches container runtime logs reporting a container
+// that exited with a non-zero status. Clean exits are not events.
+func (c *Classifier) classifyContainer(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	runtime, ok := containerIdentifiers[entry.SyslogIdentifier]
+	if !ok {
+		return nil
+	}
+
+	// podman's journald events logger tags its entries with structured fields.
+	id, name, code := entry.Fields["PODMAN_ID"], entry.Fields["PODMAN_NAME"], entry.Fields["PODMAN_EXIT_CODE"]
+	if kind := entry.Fields["PODMAN_EVENT"]; kind != "" {
+		if kind != "died" {
+			return nil
+		}
+	} else if !containerDiedRe.MatchString(entry.Message) {
+		return nil
+	}
+
+	if id == "" {
+		if m := containerIDRe.FindStringSubmatch(entry.Message); m != nil {
+			id = m[1]
+		}
+	}
+	if name == "" {
+		if m := containerNameRe.FindStringSubmatch(entry.Message); m != nil {
+			name = m[1]
+		}
+	}
+	if code == "" {
+		if m := containerExitRe.FindStringSubmatch(entry.Message); m != nil {
+			code = m[1]
+		}
+	}
+	if code == "0" {
+		return nil
+	}
+
+	label := name
+	if label == "" {
+		label = ShortContainerID(id)
+	}
+	summary := fmt.Sprintf("Container died: %s", label)
+	if code != "" {
+		summary = fmt.Sprintf("Container died: %s (exit %s)", label, code)
+	}
+
+	ev := event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh, summary)
+	ev.Process = label
+	ev.ContainerID = id
+	ev.ContainerName = name
+	maps.Copy(ev.RawFields, entry.Fields)
+	ev.RawFields["_container_runtime"] = runtime
+	if code != "" {
+		ev.RawFields["_exit_code"] = code
+	}
+	return ev
+}
+
+// classifyPodContainer classifies the entries watcher.CRISource emits for
+// Kubernetes pod containers that exited with a non-zero code: an OOM kill
+// as T1, and other exits as T2 crashes, or crash loops once the kubelet has
+// restarted the container podCrashLoopRestarts times.
+func (c *Classifier) classifyPodContainer(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	if entry.SyslogIdentifier != watcher.CRIIdentifier {
+		return nil
+	}
+	f := entry.Fields
+	name := f["CRI_CONTAINER_NAME"]
+	pod := f["CRI_POD_NAMESPACE"] + "/" + f["CRI_POD_NAME"]
+	label := pod + "/" + name
+	code := f["CRI_EXIT_CODE"]
+	restarts, _ := strconv.Atoi(f["CRI_RESTART_COUNT"])
+
+	var ev *event.Event
+	switch {
+	case f["CRI_REASON"] == "OOMKilled":
+		ev = event.New(c.instanceID, ts, event.TierOOMKill, event.SevCritical,
+			fmt.Sprintf("OOM Kill: container %s in pod %s", name, pod))
+	case restarts >= podCrashLoopRestarts:
+		ev = event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh,
+			fmt.Sprintf("Container crash loop: %s (exit %s, %d restarts)", label, code, restarts))
+	default:
+		ev = event.New(c.instanceID, ts, event.TierProcessCrash, event.SevHigh,
+			fmt.Sprintf("Container died: %s (exit %s)", label, code))
+	}
+	ev.Process = label
+	ev.ContainerID = f["CRI_CONTAINER_ID"]
+	ev.ContainerName = name
+	maps.Copy(ev.RawFields, f)
+	ev.RawFields["_container_runtime"] = RuntimeCRI
+	ev.RawFields["_exit_code"] = code
+	if uid := f["CRI_POD_UID"]; uid != "" {
+		ev.RawFields["_pod_uid"] = uid
+		if ev.Tier == event.TierOOMKill {
+			// Matches the kernel's report of the same kill; see tagCGroup.
+			ev.DedupKey = "pod=" + uid
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pod: %s\n", pod)
+	fmt.Fprintf(&b, "Container: %s (%s)\n", name, ShortContainerID(ev.ContainerID))
+	if image := f["CRI_IMAGE"]; image != "" {
+		fmt.Fprintf(&b, "Image: %s\n", image)
+	}
+	fmt.Fprintf(&b, "Exit code: %s", code)
+	if reason := f["CRI_REASON"]; reason != "" {
+		fmt.Fprintf(&b, " (%s)", reason)
+	}
+	fmt.Fprintf(&b, ", %d restarts", restarts)
+	if msg := f["CRI_MESSAGE"]; msg != "" {
+		fmt.Fprintf(&b, "\nMessage: %s", msg)
+	}
+	ev.Detail = b.String()
+	return ev
+}
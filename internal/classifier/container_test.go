@@ -0,0 +1,183 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+const testContainerID = "3c5b1f0e9a2d7c4b8e6f1a0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b"
+
+func TestContainerFromCgroup(t *testing.T) {
+	tests := []struct {
+		path    string
+		runtime string
+	}{
+		{"/system.slice/docker-" + testContainerID + ".scope", RuntimeDocker},
+		{"/docker/" + testContainerID, RuntimeDocker},
+		{"/machine.slice/libpod-" + testContainerID + ".scope/container", RuntimePodman},
+		{"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testContainerID + ".scope", RuntimePodman},
+		{"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1b2c3d4e_5f60_7182_93a4_b5c6d7e8f901.slice/cri-containerd-" + testContainerID + ".scope", RuntimeCRI},
+		{"/kubepods/besteffort/pod1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901/" + testContainerID, RuntimeCRI},
+		{"/user.slice/user-1000.slice/session-2.scope", ""},
+	}
+	for _, tt := range tests {
+		runtime, id := ContainerFromCgroup(tt.path)
+		if runtime != tt.runtime {
+			t.Errorf("ContainerFromCgroup(%q) runtime = %q, want %q", tt.path, runtime, tt.runtime)
+		}
+		if tt.runtime != "" && id != testContainerID {
+			t.Errorf("ContainerFromCgroup(%q) id = %q", tt.path, id)
+		}
+	}
+}
+
+func TestClassifyContainerOOM(t *testing.T) {
+	c := New("testhost")
+	msg := "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/system.slice/docker-" +
+		testContainerID + ".scope,task_memcg=/system.slice/docker-" + testContainerID + ".scope,task=java,pid=31337,uid=0"
+	ev := c.Classify(watcher.JournalEntry{Message: msg, SyslogIdentifier: "kernel", Transport: "kernel"})
+	if ev == nil || ev.Tier != event.TierOOMKill {
+		t.Fatalf("expected T1 event, got %v", ev)
+	}
+	if ev.ContainerID != testContainerID || ev.RawFields["_container_runtime"] != RuntimeDocker {
+		t.Errorf("container = %q, raw = %v", ev.ContainerID, ev.RawFields)
+	}
+	if ev.Summary != "OOM Kill: java (pid 31337) in container 3c5b1f0e9a2d" {
+		t.Errorf("summary = %q", ev.Summary)
+	}
+}
+
+func TestClassifyContainerDied(t *testing.T) {
+	c := New("testhost")
+
+	tests := []struct {
+		name    string
+		entry   watcher.JournalEntry
+		summary string // empty means no event
+		cname   string
+	}{
+		{
+			name: "podman journald event",
+			entry: watcher.JournalEntry{
+				Message:          "container died " + testContainerID + " (image=docker.io/library/nginx:latest, name=web)",
+				SyslogIdentifier: "podman",
+				Fields: map[string]string{
+					"PODMAN_EVENT":     "died",
+					"PODMAN_ID":        testContainerID,
+					"PODMAN_NAME":      "web",
+					"PODMAN_EXIT_CODE": "137",
+				},
+			},
+			summary: "Container died: web (exit 137)",
+			cname:   "web",
+		},
+		{
+			name: "podman clean exit",
+			entry: watcher.JournalEntry{
+				Message:          "container died " + testContainerID + " (image=docker.io/library/nginx:latest, name=web)",
+				SyslogIdentifier: "podman",
+				Fields:           map[string]string{"PODMAN_EVENT": "died", "PODMAN_EXIT_CODE": "0"},
+			},
+		},
+		{
+			name: "podman other event",
+			entry: watcher.JournalEntry{
+				Message:          "container start " + testContainerID,
+				SyslogIdentifier: "podman",
+				Fields:           map[string]string{"PODMAN_EVENT": "start"},
+			},
+		},
+		{
+			name: "dockerd message",
+			entry: watcher.JournalEntry{
+				Message:          `level=info msg="container 3c5b1f0e9a2d died" exitCode=2`,
+				SyslogIdentifier: "dockerd",
+			},
+			summary: "Container died: 3c5b1f0e9a2d (exit 2)",
+		},
+		{
+			name: "unrelated dockerd message",
+			entry: watcher.JournalEntry{
+				Message:          `level=info msg="Loading containers: done."`,
+				SyslogIdentifier: "dockerd",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := c.Classify(tt.entry)
+			if tt.summary == "" {
+				if ev != nil {
+					t.Errorf("expected no event, got %q", ev.Summary)
+				}
+				return
+			}
+			if ev == nil {
+				t.Fatal("expected an event")
+			}
+			if ev.Tier != event.TierProcessCrash || ev.Summary != tt.summary {
+				t.Errorf("tier = %q, summary = %q", ev.Tier, ev.Summary)
+			}
+			if ev.ContainerID == "" || ev.ContainerName != tt.cname {
+				t.Errorf("container id = %q, name = %q", ev.ContainerID, ev.ContainerName)
+			}
+		})
+	}
+}
+
+func TestClassifyPodContainer(t *testing.T) {
+	c := New("testhost")
+	const podUID = "1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901"
+	entry := func(code, reason, restarts string) watcher.JournalEntry {
+		return watcher.JournalEntry{
+			Message:          "container app in pod default/web exited",
+			SyslogIdentifier: watcher.CRIIdentifier,
+			Fields: map[string]string{
+				"CRI_CONTAINER_ID":   testContainerID,
+				"CRI_CONTAINER_NAME": "app",
+				"CRI_POD_NAME":       "web",
+				"CRI_POD_NAMESPACE":  "default",
+				"CRI_POD_UID":        podUID,
+				"CRI_EXIT_CODE":      code,
+				"CRI_REASON":         reason,
+				"CRI_RESTART_COUNT":  restarts,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		entry   watcher.JournalEntry
+		tier    event.Tier
+		summary string
+	}{
+		{"oom killed", entry("137", "OOMKilled", "0"), event.TierOOMKill, "OOM Kill: container app in pod default/web"},
+		{"error", entry("1", "Error", "1"), event.TierProcessCrash, "Container died: default/web/app (exit 1)"},
+		{"crash loop", entry("1", "Error", "5"), event.TierProcessCrash, "Container crash loop: default/web/app (exit 1, 5 restarts)"},
+	}
+	for _, tt := range tests {
+		ev := c.Classify(tt.entry)
+		if ev == nil {
+			t.Fatalf("%s: expected an event", tt.name)
+		}
+		if ev.Tier != tt.tier || ev.Summary != tt.summary {
+			t.Errorf("%s: tier = %q, summary = %q", tt.name, ev.Tier, ev.Summary)
+		}
+		if ev.ContainerID != testContainerID || ev.RawFields["_container_runtime"] != RuntimeCRI {
+			t.Errorf("%s: container = %q, runtime = %q", tt.name, ev.ContainerID, ev.RawFields["_container_runtime"])
+		}
+	}
+
+	// The kernel's report of the same OOM kill groups with the runtime's.
+	msg := "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0," +
+		"task_memcg=/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1b2c3d4e_5f60_7182_93a4_b5c6d7e8f901.slice/cri-containerd-" +
+		testContainerID + ".scope,task=java,pid=31337,uid=0"
+	kernel := c.Classify(watcher.JournalEntry{Message: msg, SyslogIdentifier: "kernel", Transport: "kernel"})
+	runtime := c.Classify(entry("137", "OOMKilled", "0"))
+	if kernel == nil || kernel.DedupKey != "pod="+podUID || runtime.DedupKey != kernel.DedupKey {
+		t.Errorf("dedup keys: kernel %v, runtime %q", kernel, runtime.DedupKey)
+	}
+}
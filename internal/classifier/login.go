@@ -0,0 +1,133 @@
+package classifier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// sshRootLoginRe matches sshd accepting a login as root.
+// Example: "Accepted publickey for root from 192.168.1.20 port 50022 ssh2: ED25519 SHA256:abc..."
+var sshRootLoginRe = regexp.MustCompile(`^Accepted (\S+) for root from (\S+) port \d+`)
+
+// consoleRootLoginRe matches login(1) logging a root login on a terminal.
+// Example: "ROOT LOGIN  ON tty1" or "ROOT LOGIN ON pts/3 FROM 192.168.1.20"
+var consoleRootLoginRe = regexp.MustCompile(`^ROOT LOGIN\s+ON (\S+)(?:\s+FROM (\S+))?`)
+
+// sudoFailureRe matches sudo's line for a user who failed to authenticate
+// or may not use sudo.
+// Example: "alice : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/apt upgrade"
+// Example: "mallory : user NOT in sudoers ; TTY=pts/1 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh"
+var sudoFailureRe = regexp.MustCompile(`^(\S+) : (?:(\d+) incorrect password attempts?|(user NOT in sudoers)) ; (.*)$`)
+
+// classifyLogin classifies successful root logins and sudo failures.
+func (c *Classifier) classifyLogin(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	msg := strings.TrimSpace(entry.Message)
+
+	switch entry.SyslogIdentifier {
+	case "sshd", "sshd-session":
+		m := sshRootLoginRe.FindStringSubmatch(msg)
+		if m == nil {
+			return nil
+		}
+		method, addr := m[1], m[2]
+		ev := c.rootLogin(ts, "ssh from "+addr, addr)
+		ev.Process = entry.SyslogIdentifier
+		ev.Detail = fmt.Sprintf("root logged in over SSH from %s with %s.", addr, method)
+		ev.RawFields["_login_method"] = method
+		return ev
+
+	case "login":
+		m := consoleRootLoginRe.FindStringSubmatch(msg)
+		if m == nil {
+			return nil
+		}
+		tty, from := m[1], m[2]
+		where := "on " + tty
+		if from != "" {
+			where += " from " + from
+		}
+		ev := c.rootLogin(ts, where, from)
+		ev.Process = "login"
+		ev.Detail = fmt.Sprintf("root logged in %s.", where)
+		return ev
+
+	case "sudo":
+		m := sudoFailureRe.FindStringSubmatch(msg)
+		if m == nil {
+			return nil
+		}
+		return c.sudoFailure(ts, m[1], m[2], m[4])
+	}
+	return nil
+}
+
+// rootLogin creates a T9 event for a root login; where says how it came
+// in, e.g. "ssh from 192.168.1.20" or "on tty1".
+func (c *Classifier) rootLogin(ts time.Time, where, addr string) *event.Event {
+	ev := event.New(c.instanceID, ts, event.TierSecurity, event.SevHigh, "Root login: "+where)
+	ev.DedupKey = "root_login=" + where
+	ev.RawFields["_security"] = "root_login"
+	if addr != "" {
+		ev.RawFields["_source_addr"] = addr
+	}
+	return ev
+}
+
+// sudoFailure creates a T9 event for a user who failed to authenticate to
+// sudo (attempts > 0) or is not allowed to use it (attempts ""). context is
+// the rest of sudo's line, with the target user and command.
+func (c *Classifier) sudoFailure(ts time.Time, user, attempts, context string) *event.Event {
+	var summary string
+	switch attempts {
+	case "":
+		summary = fmt.Sprintf("sudo failure: %s is not in sudoers", user)
+	case "1":
+		summary = fmt.Sprintf("sudo failure: %s (1 incorrect password)", user)
+	default:
+		summary = fmt.Sprintf("sudo failure: %s (%s incorrect passwords)", user, attempts)
+	}
+
+	ev := event.New(c.instanceID, ts, event.TierSecurity, event.SevMedium, summary)
+	ev.Process = "sudo"
+	ev.DedupKey = "sudo_user=" + user
+	ev.RawFields["_security"] = "sudo_failure"
+	ev.RawFields["_sudo_user"] = user
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", summary)
+	for _, field := range strings.Split(context, " ; ") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "USER":
+			fmt.Fprintf(&b, "As user: %s\n", value)
+		case "COMMAND":
+			fmt.Fprintf(&b, "Command: %s\n", value)
+		case "TTY":
+			fmt.Fprintf(&b, "Terminal: %s\n", value)
+		}
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev
+}
+
+// ClassifySSHKeyEvent creates a T9 event for a key added to an
+// authorized_keys file. The file is recorded as the event's process so
+// each file has its own cooldown.
+func (c *Classifier) ClassifySSHKeyEvent(path, account, fingerprint, summary, detail string) *event.Event {
+	ev := event.New(c.instanceID, time.Now(), event.TierSecurity, event.SevHigh, summary)
+	ev.Process = path
+	ev.DedupKey = "ssh_key=" + fingerprint
+	ev.Detail = detail
+	ev.RawFields["_security"] = "ssh_key"
+	ev.RawFields["_account"] = account
+	ev.RawFields["_key_fingerprint"] = fingerprint
+	return ev
+}
@@ -0,0 +1,71 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestClassifyLogin(t *testing.T) {
+	c := New("testhost")
+
+	tests := []struct {
+		ident    string
+		msg      string
+		summary  string // empty means no event
+		severity event.Severity
+		kind     string
+	}{
+		{"sshd", "Accepted publickey for root from 192.168.1.20 port 50022 ssh2: ED25519 SHA256:Qm9vYmFy",
+			"Root login: ssh from 192.168.1.20", event.SevHigh, "root_login"},
+		{"sshd-session", "Accepted password for root from 2001:db8::20 port 50022 ssh2",
+			"Root login: ssh from 2001:db8::20", event.SevHigh, "root_login"},
+		{"sshd", "Accepted publickey for alice from 192.168.1.20 port 50022 ssh2", "", "", ""},
+		{"login", "ROOT LOGIN  ON tty1", "Root login: on tty1", event.SevHigh, "root_login"},
+		{"login", "ROOT LOGIN ON pts/3 FROM 192.168.1.20", "Root login: on pts/3 from 192.168.1.20", event.SevHigh, "root_login"},
+		{"sudo", "alice : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/apt upgrade",
+			"sudo failure: alice (3 incorrect passwords)", event.SevMedium, "sudo_failure"},
+		{"sudo", "bob : 1 incorrect password attempt ; TTY=pts/2 ; PWD=/home/bob ; USER=root ; COMMAND=/bin/ls",
+			"sudo failure: bob (1 incorrect password)", event.SevMedium, "sudo_failure"},
+		{"sudo", "mallory : user NOT in sudoers ; TTY=pts/1 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh",
+			"sudo failure: mallory is not in sudoers", event.SevMedium, "sudo_failure"},
+		{"sudo", "alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/apt upgrade", "", "", ""},
+	}
+	for _, tt := range tests {
+		ev := c.Classify(watcher.JournalEntry{SyslogIdentifier: tt.ident, Message: tt.msg, RealtimeTimestamp: "1708300000000000"})
+		if tt.summary == "" {
+			if ev != nil {
+				t.Errorf("%s: %q: expected no event, got %q", tt.ident, tt.msg, ev.Summary)
+			}
+			continue
+		}
+		if ev == nil {
+			t.Errorf("%s: %q: expected an event, got nil", tt.ident, tt.msg)
+			continue
+		}
+		if ev.Tier != event.TierSecurity || ev.Severity != tt.severity || ev.RawFields["_security"] != tt.kind {
+			t.Errorf("%q: tier/severity/kind = %s/%s/%s", tt.msg, ev.Tier, ev.Severity, ev.RawFields["_security"])
+		}
+		if ev.Summary != tt.summary {
+			t.Errorf("summary = %q, want %q", ev.Summary, tt.summary)
+		}
+	}
+}
+
+func TestSudoFailureDetail(t *testing.T) {
+	c := New("testhost")
+	ev := c.Classify(watcher.JournalEntry{
+		SyslogIdentifier: "sudo",
+		Message:          "alice : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/apt upgrade",
+	})
+	if ev == nil {
+		t.Fatal("expected an event")
+	}
+	for _, want := range []string{"As user: root", "Command: /usr/bin/apt upgrade", "Terminal: pts/0"} {
+		if !strings.Contains(ev.Detail, want) {
+			t.Errorf("detail %q does not contain %q", ev.Detail, want)
+		}
+	}
+}
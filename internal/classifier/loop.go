@@ -0,0 +1,113 @@
+package classifier
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// sameFailureGap groups T3 events logged this close together into one
+// failure: systemd reports a single failure with several journal lines
+// ("Main process exited", "Failed with result").
+const sameFailureGap = time.Second
+
+// maxLoopFailures caps the failures listed in a restart-loop event's detail.
+const maxLoopFailures = 10
+
+// ClassifyRestartLoop checks a unit's recent T3 events, newest first as the
+// store returns them, and reports whether the unit is in a restart loop:
+// at least threshold distinct failures, or a restart-loop event already
+// among recent. A new high-severity T3 restart-loop event is returned only
+// when the loop has not been reported yet, so it is reported once per
+// window.
+func (c *Classifier) ClassifyRestartLoop(unit string, recent []*event.Event, threshold int, window time.Duration) (loop *event.Event, looping bool) {
+	var failures []loopFailure
+	for _, ev := range recent {
+		if ev.RawFields["_restart_loop"] != "" {
+			return nil, true
+		}
+		code, result := extractExitCode(ev.RawFields["MESSAGE"]), ev.RawFields["_unit_result"]
+		if n := len(failures); n > 0 && failures[n-1].ts.Sub(ev.Timestamp) < sameFailureGap {
+			failures[n-1].merge(code, result)
+			continue
+		}
+		failures = append(failures, loopFailure{ts: ev.Timestamp, code: code, result: result})
+	}
+	if threshold <= 0 || len(failures) < threshold {
+		return nil, false
+	}
+
+	summary := fmt.Sprintf("Restart loop: %s (%d failures in %s)", unit, len(failures), formatWindow(window))
+	ev := event.New(c.instanceID, failures[0].ts, event.TierServiceFailure, event.SevHigh, summary)
+	ev.Unit = unit
+	ev.RawFields["UNIT"] = unit
+	ev.RawFields["_restart_loop"] = strconv.Itoa(len(failures))
+	if recent[0].RawFields["_user_unit"] != "" {
+		ev.RawFields["_user_unit"] = "true"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed %d times in %s.\n", unit, len(failures), formatWindow(window))
+	for _, f := range recent {
+		if n := f.RawFields["_n_restarts"]; n != "" {
+			ev.RawFields["_n_restarts"] = n
+			fmt.Fprintf(&b, "Restart count: %s\n", n)
+			break
+		}
+	}
+	b.WriteString("\nRecent failures:\n")
+	for i, f := range failures {
+		if i == maxLoopFailures {
+			fmt.Fprintf(&b, "  ... and %d earlier\n", len(failures)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s  %s\n", f.ts.Local().Format("15:04:05"), f.reason())
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev, true
+}
+
+// loopFailure is one failure of a looping unit, merged from the events
+// systemd logged for it.
+type loopFailure struct {
+	ts     time.Time
+	code   string // exit status, if logged
+	result string // service result from D-Bus, if known
+}
+
+func (f *loopFailure) merge(code, result string) {
+	if f.code == "" {
+		f.code = code
+	}
+	if f.result == "" {
+		f.result = result
+	}
+}
+
+// reason describes how the failure ended: the exit status when systemd
+// logged one, otherwise the service result.
+func (f loopFailure) reason() string {
+	switch {
+	case f.code != "":
+		return "exit " + f.code
+	case f.result != "":
+		return f.result
+	default:
+		return "failed"
+	}
+}
+
+// formatWindow formats a detection window compactly, e.g. "10m" or "1h".
+func formatWindow(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,92 @@
+package classifier
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// matcher tests a message against a list of patterns in a single pass.
+// The patterns are combined into one alternation, and a message must first
+// contain one of the literals the patterns require, so most journal lines
+// are ruled out by a few substring searches without running a regexp.
+type matcher struct {
+	re       *regexp.Regexp
+	literals []string // nil if some pattern requires no literal
+}
+
+// newMatcher combines patterns into a matcher. It panics if the
+// combination does not compile, which cannot happen for valid patterns.
+func newMatcher(patterns []*regexp.Regexp) *matcher {
+	parts := make([]string, len(patterns))
+	literals := make([]string, 0, len(patterns))
+	for i, re := range patterns {
+		// A group keeps each pattern's flags to itself.
+		parts[i] = "(?:" + re.String() + ")"
+		if literals != nil {
+			if lit := requiredLiteral(re); lit != "" {
+				literals = append(literals, lit)
+			} else {
+				literals = nil
+			}
+		}
+	}
+	return &matcher{
+		re:       regexp.MustCompile(strings.Join(parts, "|")),
+		literals: literals,
+	}
+}
+
+// MatchString reports whether any of the patterns matches s.
+func (m *matcher) MatchString(s string) bool {
+	return m.mayMatch(s) && m.re.MatchString(s)
+}
+
+// mayMatch reports whether s contains a literal some pattern requires, so
+// that a pattern could match.
+func (m *matcher) mayMatch(s string) bool {
+	if m.literals == nil {
+		return true
+	}
+	for _, lit := range m.literals {
+		if strings.Contains(s, lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiteral returns the longest case-sensitive literal every match of
+// re contains, or "" if there is none.
+func requiredLiteral(re *regexp.Regexp) string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	return longestLiteral(parsed.Simplify())
+}
+
+func longestLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return ""
+		}
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return longestLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return longestLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best string
+		for _, sub := range re.Sub {
+			if lit := longestLiteral(sub); len(lit) > len(best) {
+				best = lit
+			}
+		}
+		return best
+	}
+	return ""
+}
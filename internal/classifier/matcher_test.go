@@ -0,0 +1,138 @@
+package classifier
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// loadJournal reads testdata/journal.json, a journalctl -o json dump of
+// mostly routine lines with a few of every built-in tier mixed in.
+func loadJournal(tb testing.TB) []watcher.JournalEntry {
+	tb.Helper()
+	f, err := os.Open("testdata/journal.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []watcher.JournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		entry, err := watcher.ParseJournalJSON(sc.Bytes())
+		if err != nil {
+			tb.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := sc.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return entries
+}
+
+func TestRequiredLiteral(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{`I/O error`, "I/O error"},
+		{`traps:.*trap`, "traps:"},
+		{`Process \d+ \(.+\) of user \d+ dumped core`, " dumped core"},
+		{`(?:GCVM_L2|VM_L2)_PROTECTION_FAULT_STATUS`, "_PROTECTION_FAULT_STATUS"},
+		{`(?P<driver>iwlwifi) (?P<adapter>\S+): FW error in SYNC CMD`, ": FW error in SYNC CMD"},
+		{`(ab)+c`, "ab"},
+		{`oom|OOM`, ""},
+		{`(?i)segfault`, ""},
+		{`x?`, ""},
+	}
+	for _, tt := range tests {
+		if got := requiredLiteral(regexp.MustCompile(tt.pattern)); got != tt.want {
+			t.Errorf("requiredLiteral(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// TestMatchersAgree checks that each combined matcher matches exactly the
+// messages one of its patterns matches.
+func TestMatchersAgree(t *testing.T) {
+	messages := []string{
+		"oom-kill:constraint=CONSTRAINT_NONE,task=firefox,pid=4521,uid=1000",
+		"EXT4-fs error (device sda1): ext4_find_entry:1455: inode #2",
+		"XFS (sdb1): metadata I/O error in xfs_trans_read_buf",
+		"mce: [Hardware Error]: CPU 0: Machine Check: 0 Bank 5",
+		"amdgpu 0000:03:00.0: amdgpu: ring gfx_0.0.0 timeout, signaled seq=1, emitted seq=3",
+		"amdgpu: GCVM_L2_PROTECTION_FAULT_STATUS:0x00301031",
+		"[drm:drm_atomic_helper_wait_for_flip_done] *ERROR* [CRTC:82:crtc-0] flip_done timed out",
+		"app[1234]: traps: app[1234] trap invalid opcode ip:55d1",
+		"foo.service entered failed state.",
+	}
+	for _, entry := range loadJournal(t) {
+		messages = append(messages, entry.Message)
+	}
+
+	for name, tc := range map[string]struct {
+		m        *matcher
+		patterns []*regexp.Regexp
+	}{
+		"oom":     {oomMatcher, oomPatterns},
+		"crash":   {crashMatcher, crashPatterns},
+		"service": {serviceFailMatcher, serviceFailPatterns},
+		"kernel":  {kernelHWMatcher, kernelHWPatterns},
+		"gpu":     {gpuMatcher, gpuPatterns},
+	} {
+		if tc.m.literals == nil {
+			t.Errorf("%s: no literal gate", name)
+		}
+		for _, msg := range messages {
+			var want bool
+			for _, re := range tc.patterns {
+				want = want || re.MatchString(msg)
+			}
+			if got := tc.m.MatchString(msg); got != want {
+				t.Errorf("%s matcher on %q = %v, want %v", name, msg, got, want)
+			}
+		}
+	}
+}
+
+// BenchmarkClassifyJournal measures classification throughput over a
+// replayed journal dump.
+func BenchmarkClassifyJournal(b *testing.B) {
+	entries := loadJournal(b)
+	c := New("bench")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		for _, entry := range entries {
+			c.Classify(entry)
+		}
+	}
+	b.ReportMetric(float64(b.N*len(entries))/b.Elapsed().Seconds(), "entries/s")
+}
+
+// BenchmarkPatternLoopJournal is the baseline BenchmarkClassifyJournal
+// improves on: every built-in pattern tried in turn against every entry.
+func BenchmarkPatternLoopJournal(b *testing.B) {
+	entries := loadJournal(b)
+	var patterns []*regexp.Regexp
+	for _, list := range [][]*regexp.Regexp{oomPatterns, crashPatterns, serviceFailPatterns, kernelHWPatterns, gpuPatterns} {
+		patterns = append(patterns, list...)
+	}
+	for _, wp := range wirelessPatterns {
+		patterns = append(patterns, wp.re)
+	}
+	b.ResetTimer()
+	for b.Loop() {
+		for _, entry := range entries {
+			for _, re := range patterns {
+				if re.MatchString(entry.Message) {
+					break
+				}
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*len(entries))/b.Elapsed().Seconds(), "entries/s")
+}
@@ -1,6 +1,10 @@
 package classifier
 
-import "regexp"
+import (
+	"regexp"
+
+	"github.com/setevik/logtriage/internal/event"
+)
 
 // T1 — OOM Kill patterns
 var oomPatterns = []*regexp.Regexp{
@@ -9,6 +13,9 @@ var oomPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`invoked oom-killer`),
 }
 
+// oomMatcher matches any of oomPatterns in one pass.
+var oomMatcher = newMatcher(oomPatterns)
+
 // T2 — Process Crash patterns
 var crashPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`segfault at`),
@@ -16,6 +23,9 @@ var crashPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`Process \d+ \(.+\) of user \d+ dumped core`),
 }
 
+// crashMatcher matches any of crashPatterns in one pass.
+var crashMatcher = newMatcher(crashPatterns)
+
 // crashIdentifiers are syslog identifiers that signal crash events.
 var crashIdentifiers = map[string]bool{
 	"systemd-coredump": true,
@@ -44,6 +54,9 @@ var serviceFailPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`Main process exited, code=exited, status=\d+/`),
 }
 
+// serviceFailMatcher matches any of serviceFailPatterns in one pass.
+var serviceFailMatcher = newMatcher(serviceFailPatterns)
+
 // serviceIdentifiers are syslog identifiers that emit service failure messages.
 var serviceIdentifiers = map[string]bool{
 	"systemd": true,
@@ -76,6 +89,9 @@ var kernelHWPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`Hardware Error`),
 }
 
+// kernelHWMatcher matches any of kernelHWPatterns in one pass.
+var kernelHWMatcher = newMatcher(kernelHWPatterns)
+
 // T4 — GPU-specific kernel error patterns
 var gpuPatterns = []*regexp.Regexp{
 	// NVIDIA (proprietary driver)
@@ -108,6 +124,82 @@ var gpuPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`\*ERROR\*.*commit wait timed out`),
 }
 
+// gpuMatcher matches any of gpuPatterns in one pass.
+var gpuMatcher = newMatcher(gpuPatterns)
+
+// T4 — Kernel BUG, oops, warning, and general protection fault patterns.
+// Each matches the first line of a report that continues with registers,
+// modules, and a call trace, which the enricher collects into the detail.
+// Capture group 1, when present, fills in the summary. An "Oops:" line
+// follows a BUG line in the same report, so it is not matched on its own.
+var kernelOopsPatterns = []struct {
+	re      *regexp.Regexp
+	summary string
+	sev     event.Severity
+}{
+	// Example: "BUG: kernel NULL pointer dereference, address: 0000000000000008"
+	{regexp.MustCompile(`^BUG: (.+?)(?:,? (?:for )?address:? .*)?$`), "Kernel BUG: %s", event.SevHigh},
+	// Example: "kernel BUG at mm/slub.c:4431!"
+	{regexp.MustCompile(`^kernel BUG at (\S+?)!?$`), "Kernel BUG at %s", event.SevHigh},
+	// Example: "general protection fault, probably for non-canonical address 0xdead000000000100: 0000 [#1] SMP"
+	{regexp.MustCompile(`^general protection fault`), "Kernel general protection fault", event.SevHigh},
+	// Example: "Unable to handle kernel NULL pointer dereference at virtual address 0000000000000010"
+	{regexp.MustCompile(`^Unable to handle kernel (.+?)(?: at virtual address .*)?$`), "Kernel oops: unable to handle %s", event.SevHigh},
+	// Example: "WARNING: CPU: 3 PID: 1234 at drivers/gpu/drm/drm_atomic.c:123 drm_atomic_check+0x45/0x90 [drm]"
+	{regexp.MustCompile(`^WARNING: CPU: \d+ PID: \d+ at (\S+)`), "Kernel warning at %s", event.SevMedium},
+}
+
+// IsKernelReportStart reports whether a kernel log line begins a BUG,
+// oops, warning, or general protection fault report.
+func IsKernelReportStart(line string) bool {
+	for _, op := range kernelOopsPatterns {
+		if op.re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// T4 — Wi-Fi and Bluetooth firmware failure patterns.
+// Named groups: "driver" (kernel module) and "adapter" (PCI address or hciN).
+// Only the first line of a firmware crash dump is matched so a single crash
+// yields a single event.
+var wirelessPatterns = []struct {
+	re    *regexp.Regexp
+	label string
+}{
+	// Intel (iwlwifi / iwlmvm)
+	// Example: "iwlwifi 0000:00:14.3: Microcode SW error detected. Restarting 0x0."
+	{regexp.MustCompile(`(?P<driver>iwlwifi) (?P<adapter>\S+): Microcode SW error detected`), "Wi-Fi firmware crash"},
+	{regexp.MustCompile(`(?P<driver>iwlwifi) (?P<adapter>\S+): FW error in SYNC CMD`), "Wi-Fi firmware crash"},
+	{regexp.MustCompile(`(?P<driver>iwlwifi) (?P<adapter>\S+): (?:Failed to load firmware|no suitable firmware found)`), "Wi-Fi firmware load failed"},
+
+	// Qualcomm Atheros (ath10k / ath11k / ath12k)
+	// Example: "ath11k_pci 0000:03:00.0: firmware crashed: MHI_CB_EE_RDDM"
+	{regexp.MustCompile(`(?P<driver>ath1[012]k\w*) (?P<adapter>\S+): firmware crashed`), "Wi-Fi firmware crash"},
+
+	// MediaTek (mt76: mt7921e, mt7922, mt7612u, ...)
+	// Example: "mt7921e 0000:01:00.0: Message 00020007 (seq 11) timeout"
+	{regexp.MustCompile(`(?P<driver>mt7\w+) (?P<adapter>\S+): Message \w+ \(seq \d+\) timeout`), "Wi-Fi firmware timeout"},
+	{regexp.MustCompile(`(?P<driver>mt7\w+) (?P<adapter>\S+): (?:chip reset|Timeout for driver own)`), "Wi-Fi chip reset"},
+
+	// Bluetooth (btusb / btintel / btrtl / btmtk, reported via the HCI core)
+	// Example: "Bluetooth: hci0: Failed to load Intel firmware file intel/ibt-19-0-4.sfi (-2)"
+	{regexp.MustCompile(`Bluetooth: (?P<adapter>hci\d+): (?:Failed to .*firmware|.*[Ff]irmware.*(?:failed|error|timeout))`), "Bluetooth firmware failure"},
+	{regexp.MustCompile(`Bluetooth: (?P<adapter>hci\d+): command 0x[0-9a-f]+ tx timeout`), "Bluetooth command timeout"},
+	{regexp.MustCompile(`Bluetooth: (?P<adapter>hci\d+): Hardware error 0x[0-9a-f]+`), "Bluetooth controller hardware error"},
+}
+
+// wirelessMatcher rules out messages no wireless pattern matches, before
+// they are tried one by one for the label.
+var wirelessMatcher = func() *matcher {
+	res := make([]*regexp.Regexp, len(wirelessPatterns))
+	for i, wp := range wirelessPatterns {
+		res[i] = wp.re
+	}
+	return newMatcher(res)
+}()
+
 // compositorProcesses maps compositor process names to friendly labels.
 // Used to detect compositor crashes that may be GPU-driver-initiated.
 var compositorProcesses = map[string]string{
@@ -138,6 +230,11 @@ var kernelFSDevRe = regexp.MustCompile(`\(device\s+(\w+)\)`)
 // Example: "NVRM: Xid (PCI:0000:01:00): 79, pid=1234, GPU has fallen off the bus"
 var nvidiaXidRe = regexp.MustCompile(`NVRM: Xid \(PCI:[0-9a-f:\.]+\): (\d+),`)
 
+// gpuPCIRe extracts the PCI address of the card a GPU driver message is
+// about, without the function number, which NVIDIA's Xid messages leave out.
+// Examples: "NVRM: Xid (PCI:0000:01:00): 79", "amdgpu 0000:03:00.0: ..."
+var gpuPCIRe = regexp.MustCompile(`(?:PCI:|\b(?:GPU|amdgpu|i915|nouveau|radeon|xe) )((?:[0-9a-f]{4}:)?[0-9a-f]{2}:[0-9a-f]{2})`)
+
 // nvidiaXidDescriptions maps critical Xid codes to descriptions.
 var nvidiaXidDescriptions = map[string]string{
 	"13":  "Graphics exception",
@@ -0,0 +1,139 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// userTierRe matches the tier identifiers a rule may assign.
+var userTierRe = regexp.MustCompile(`^T\d+$`)
+
+// rule is a compiled user-defined classification rule.
+type rule struct {
+	name       string
+	re         *regexp.Regexp
+	literal    string // a substring every match contains, checked first
+	identifier string
+	unit       string
+	tier       event.Tier
+	severity   event.Severity
+	summary    string
+	process    string
+}
+
+// SetRules compiles user-defined rules and installs them ahead of the
+// built-in patterns. On error no rules are changed; every invalid rule is
+// reported.
+func (c *Classifier) SetRules(specs []config.RuleConfig) error {
+	rules := make([]rule, 0, len(specs))
+	var errs []error
+
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		r, err := compileRule(name, spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", name, err))
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	c.rules = rules
+	return nil
+}
+
+func compileRule(name string, spec config.RuleConfig) (rule, error) {
+	if spec.Pattern == "" {
+		return rule{}, errors.New("pattern is required")
+	}
+	re, err := regexp.Compile(spec.Pattern)
+	if err != nil {
+		return rule{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	if !userTierRe.MatchString(spec.Tier) {
+		return rule{}, fmt.Errorf("tier %q must look like T1..Tn", spec.Tier)
+	}
+
+	sev := event.Severity(spec.Severity)
+	if sev == "" {
+		sev = event.SevMedium
+	}
+	if sev.Rank() == 0 {
+		return rule{}, fmt.Errorf("unknown severity %q", spec.Severity)
+	}
+
+	summary := spec.Summary
+	if summary == "" {
+		summary = name + ": $0"
+	}
+
+	return rule{
+		name:       name,
+		re:         re,
+		literal:    requiredLiteral(re),
+		identifier: spec.Identifier,
+		unit:       spec.Unit,
+		tier:       event.Tier(spec.Tier),
+		severity:   sev,
+		summary:    summary,
+		process:    spec.Process,
+	}, nil
+}
+
+// classifyRules returns an event for the first user rule that matches.
+func (c *Classifier) classifyRules(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	for _, r := range c.rules {
+		if r.identifier != "" && r.identifier != entry.SyslogIdentifier {
+			continue
+		}
+		if r.unit != "" && r.unit != entry.SystemdUnit {
+			continue
+		}
+		if !strings.Contains(entry.Message, r.literal) {
+			continue
+		}
+		m := r.re.FindStringSubmatchIndex(entry.Message)
+		if m == nil {
+			continue
+		}
+
+		summary := string(r.re.ExpandString(nil, r.summary, entry.Message, m))
+		ev := event.New(c.instanceID, ts, r.tier, r.severity, summary)
+		ev.Unit = entry.SystemdUnit
+		ev.Process = entry.SyslogIdentifier
+		if r.process != "" {
+			ev.Process = string(r.re.ExpandString(nil, r.process, entry.Message, m))
+		}
+		if pid, err := strconv.Atoi(entry.PID); err == nil {
+			ev.PID = pid
+		}
+		ev.RawFields = entry.Fields
+		if ev.RawFields == nil {
+			ev.RawFields = make(map[string]string)
+		}
+		ev.RawFields["_rule"] = r.name
+		for i, name := range r.re.SubexpNames() {
+			if name != "" && m[2*i] >= 0 {
+				ev.RawFields["_match_"+name] = entry.Message[m[2*i]:m[2*i+1]]
+			}
+		}
+		return ev
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestUserRules(t *testing.T) {
+	c := New("testhost")
+	err := c.SetRules([]config.RuleConfig{
+		{
+			Name:       "zfs-degraded",
+			Pattern:    `pool '(?P<pool>\w+)' state is DEGRADED`,
+			Identifier: "zed",
+			Tier:       "T4",
+			Severity:   "high",
+			Summary:    "ZFS pool ${pool} degraded",
+			Process:    "zpool-$1",
+		},
+		{
+			Name:     "backup",
+			Pattern:  `backup failed: (.+)`,
+			Unit:     "restic.service",
+			Tier:     "T3",
+			Severity: "medium",
+		},
+		{
+			Name:     "shadowed",
+			Pattern:  `backup failed`,
+			Tier:     "T2",
+			Severity: "high",
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetRules: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		entry    watcher.JournalEntry
+		tier     event.Tier
+		severity event.Severity
+		summary  string
+		process  string
+	}{
+		{
+			name: "named capture in summary",
+			entry: watcher.JournalEntry{
+				Message:          "pool 'tank' state is DEGRADED",
+				SyslogIdentifier: "zed",
+				PID:              "812",
+			},
+			tier:     event.TierKernelHW,
+			severity: event.SevHigh,
+			summary:  "ZFS pool tank degraded",
+			process:  "zpool-tank",
+		},
+		{
+			name: "default summary and first match wins",
+			entry: watcher.JournalEntry{
+				Message:          "backup failed: repository locked",
+				SyslogIdentifier: "restic",
+				SystemdUnit:      "restic.service",
+			},
+			tier:     event.TierServiceFailure,
+			severity: event.SevMedium,
+			summary:  "backup: backup failed: repository locked",
+			process:  "restic",
+		},
+		{
+			name: "unit filter falls through to later rule",
+			entry: watcher.JournalEntry{
+				Message:          "backup failed: disk full",
+				SyslogIdentifier: "borg",
+				SystemdUnit:      "borg.service",
+			},
+			tier:     event.TierProcessCrash,
+			severity: event.SevHigh,
+			summary:  "shadowed: backup failed",
+			process:  "borg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := c.Classify(tt.entry)
+			if ev == nil {
+				t.Fatal("expected event, got nil")
+			}
+			if ev.Tier != tt.tier {
+				t.Errorf("tier = %q, want %q", ev.Tier, tt.tier)
+			}
+			if ev.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", ev.Severity, tt.severity)
+			}
+			if ev.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", ev.Summary, tt.summary)
+			}
+			if ev.Process != tt.process {
+				t.Errorf("process = %q, want %q", ev.Process, tt.process)
+			}
+			if ev.RawFields["_rule"] == "" {
+				t.Error("_rule raw field not set")
+			}
+		})
+	}
+
+	ev := c.Classify(tests[0].entry)
+	if got := ev.RawFields["_match_pool"]; got != "tank" {
+		t.Errorf("_match_pool = %q, want tank", got)
+	}
+}
+
+func TestUserRulesIdentifierFilter(t *testing.T) {
+	c := New("testhost")
+	if err := c.SetRules([]config.RuleConfig{
+		{Pattern: `state is DEGRADED`, Identifier: "zed", Tier: "T4", Severity: "high"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := c.Classify(watcher.JournalEntry{
+		Message:          "pool 'tank' state is DEGRADED",
+		SyslogIdentifier: "someone-else",
+		Fields:           map[string]string{},
+	})
+	if ev != nil {
+		t.Errorf("rule should not match other identifiers, got %+v", ev)
+	}
+}
+
+func TestUserRulesPrecedeBuiltins(t *testing.T) {
+	c := New("testhost")
+	if err := c.SetRules([]config.RuleConfig{
+		{Name: "quiet-oom", Pattern: `Killed process \d+ \((\w+)\)`, Tier: "T5", Severity: "warning", Summary: "expected OOM: $1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := c.Classify(watcher.JournalEntry{
+		Message:          "Out of memory: Killed process 4521 (stress)",
+		SyslogIdentifier: "kernel",
+		Transport:        "kernel",
+		Fields:           map[string]string{},
+	})
+	if ev == nil || ev.Tier != event.TierMemPressure || ev.Summary != "expected OOM: stress" {
+		t.Errorf("user rule should override built-in OOM classification, got %+v", ev)
+	}
+}
+
+func TestSetRulesErrors(t *testing.T) {
+	c := New("testhost")
+	if err := c.SetRules([]config.RuleConfig{{Name: "ok", Pattern: "x", Tier: "T4"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.SetRules([]config.RuleConfig{
+		{Name: "bad-regex", Pattern: "(", Tier: "T4"},
+		{Name: "bad-tier", Pattern: "x", Tier: "critical"},
+		{Name: "bad-sev", Pattern: "x", Tier: "T4", Severity: "urgent"},
+		{Tier: "T4"},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"bad-regex", "bad-tier", "bad-sev", "#4"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+
+	// The previous rule set stays installed.
+	if len(c.rules) != 1 || c.rules[0].name != "ok" {
+		t.Errorf("rules changed after failed SetRules: %+v", c.rules)
+	}
+}
@@ -0,0 +1,174 @@
+package classifier
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// maxSSHSources caps the sources listed in an SSH brute-force event's
+// detail, and maxSSHAccounts the accounts.
+const (
+	maxSSHSources  = 10
+	maxSSHAccounts = 10
+)
+
+// sshFailedRe matches sshd's line for a failed password. Failed public
+// keys are not counted: clients offer each of their keys in turn.
+// Example: "Failed password for root from 203.0.113.7 port 52214 ssh2"
+// Example: "Failed keyboard-interactive/pam for admin from 203.0.113.7 port 52214 ssh2"
+var sshFailedRe = regexp.MustCompile(`^Failed (?:password|keyboard-interactive/pam) for (invalid user )?(\S*) from (\S+) port \d+`)
+
+// sshInvalidUserRe matches sshd's line for a connection trying an account
+// that does not exist.
+// Example: "Invalid user oracle from 203.0.113.7 port 40112"
+var sshInvalidUserRe = regexp.MustCompile(`^Invalid user (\S*) from (\S+)(?: port \d+)?`)
+
+// SSHFailure is a failed SSH login.
+type SSHFailure struct {
+	Time    time.Time
+	Account string // the account tried
+	Addr    string // where the attempt came from
+}
+
+// ParseSSHFailure reports whether entry is sshd logging a failed login: a
+// wrong password, or an account that does not exist. sshd logs a wrong
+// password for an account that does not exist after its "Invalid user"
+// line, so that second line is not counted again.
+func ParseSSHFailure(entry watcher.JournalEntry) (SSHFailure, bool) {
+	if entry.SyslogIdentifier != "sshd" && entry.SyslogIdentifier != "sshd-session" {
+		return SSHFailure{}, false
+	}
+	msg := strings.TrimSpace(entry.Message)
+	if m := sshInvalidUserRe.FindStringSubmatch(msg); m != nil {
+		return SSHFailure{Time: parseTimestamp(entry), Account: m[1], Addr: m[2]}, true
+	}
+	if m := sshFailedRe.FindStringSubmatch(msg); m != nil && m[1] == "" {
+		return SSHFailure{Time: parseTimestamp(entry), Account: m[2], Addr: m[3]}, true
+	}
+	return SSHFailure{}, false
+}
+
+// SSHGuard counts failed SSH logins per source address and reports an
+// address failing threshold times within window, once per window, listing
+// the other addresses failing most in the same window. It is not safe for
+// concurrent use.
+type SSHGuard struct {
+	c         *Classifier
+	threshold int
+	window    time.Duration
+	recent    map[string][]SSHFailure // by address, oldest first
+	reported  map[string]time.Time    // when each address was last reported
+	swept     time.Time
+}
+
+// NewSSHGuard returns an SSHGuard reporting threshold failed logins from
+// an address within window.
+func (c *Classifier) NewSSHGuard(threshold int, window time.Duration) *SSHGuard {
+	return &SSHGuard{
+		c:         c,
+		threshold: threshold,
+		window:    window,
+		recent:    make(map[string][]SSHFailure),
+		reported:  make(map[string]time.Time),
+	}
+}
+
+// Observe records a failed login and returns a T9 event when it is the
+// threshold-th failure from its address within the window, unless the
+// address was already reported within the window.
+func (g *SSHGuard) Observe(f SSHFailure) *event.Event {
+	g.sweep(f.Time)
+
+	cutoff := f.Time.Add(-g.window)
+	failures := slices.DeleteFunc(g.recent[f.Addr], func(old SSHFailure) bool {
+		return !old.Time.After(cutoff)
+	})
+	failures = append(failures, f)
+	g.recent[f.Addr] = failures
+
+	if len(failures) < g.threshold {
+		return nil
+	}
+	if last, ok := g.reported[f.Addr]; ok && last.After(cutoff) {
+		return nil
+	}
+	g.reported[f.Addr] = f.Time
+	return g.event(f.Addr, failures)
+}
+
+// sweep forgets addresses with no failure within the window, at most once
+// per window, so a scan from many addresses does not pile up.
+func (g *SSHGuard) sweep(now time.Time) {
+	if now.Sub(g.swept) < g.window {
+		return
+	}
+	g.swept = now
+	cutoff := now.Add(-g.window)
+	maps.DeleteFunc(g.recent, func(_ string, failures []SSHFailure) bool {
+		return !failures[len(failures)-1].Time.After(cutoff)
+	})
+	maps.DeleteFunc(g.reported, func(_ string, at time.Time) bool {
+		return !at.After(cutoff)
+	})
+}
+
+// event reports the failures from an address, with the accounts tried and
+// the addresses failing most within the window.
+func (g *SSHGuard) event(addr string, failures []SSHFailure) *event.Event {
+	last := failures[len(failures)-1]
+	summary := fmt.Sprintf("SSH brute force: %s (%d failed logins in %s)", addr, len(failures), formatWindow(g.window))
+
+	ev := event.New(g.c.instanceID, last.Time, event.TierSecurity, event.SevHigh, summary)
+	ev.Process = "sshd"
+	ev.DedupKey = "ssh_source=" + addr
+	ev.RawFields["_source_addr"] = addr
+	ev.RawFields["_security"] = "ssh_bruteforce"
+	ev.RawFields["_ssh_failures"] = strconv.Itoa(len(failures))
+
+	var accounts []string
+	for _, f := range failures {
+		if a := cmp.Or(f.Account, `""`); !slices.Contains(accounts, a) {
+			accounts = append(accounts, a)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d failed SSH logins from %s in %s.\n", len(failures), addr, formatWindow(g.window))
+	if len(accounts) > maxSSHAccounts {
+		fmt.Fprintf(&b, "Accounts tried: %s, and %d more\n", strings.Join(accounts[:maxSSHAccounts], ", "), len(accounts)-maxSSHAccounts)
+	} else {
+		fmt.Fprintf(&b, "Accounts tried: %s\n", strings.Join(accounts, ", "))
+	}
+
+	cutoff := last.Time.Add(-g.window)
+	bySource := make(map[string]int)
+	for src, fs := range g.recent {
+		for _, f := range fs {
+			if f.Time.After(cutoff) {
+				bySource[src]++
+			}
+		}
+	}
+	sources := slices.SortedFunc(maps.Keys(bySource), func(a, b string) int {
+		return cmp.Or(bySource[b]-bySource[a], strings.Compare(a, b))
+	})
+	fmt.Fprintf(&b, "\nTop sources in the last %s:\n", formatWindow(g.window))
+	for i, src := range sources {
+		if i == maxSSHSources {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(sources)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %-40s %d\n", src, bySource[src])
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev
+}
@@ -0,0 +1,85 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestParseSSHFailure(t *testing.T) {
+	tests := []struct {
+		ident   string
+		msg     string
+		ok      bool
+		account string
+		addr    string
+	}{
+		{"sshd", "Failed password for root from 203.0.113.7 port 52214 ssh2", true, "root", "203.0.113.7"},
+		{"sshd-session", "Failed keyboard-interactive/pam for admin from 2001:db8::7 port 40112 ssh2", true, "admin", "2001:db8::7"},
+		{"sshd", "Invalid user oracle from 198.51.100.2 port 40112", true, "oracle", "198.51.100.2"},
+		{"sshd", "Invalid user  from 198.51.100.2 port 40112", true, "", "198.51.100.2"},
+		// Counted at its "Invalid user" line.
+		{"sshd", "Failed password for invalid user oracle from 198.51.100.2 port 40112 ssh2", false, "", ""},
+		{"sshd", "Failed publickey for git from 203.0.113.7 port 52214 ssh2", false, "", ""},
+		{"sshd", "Accepted password for alice from 192.168.1.20 port 50022 ssh2", false, "", ""},
+		{"sudo", "Failed password for root from 203.0.113.7 port 52214 ssh2", false, "", ""},
+	}
+	for _, tt := range tests {
+		entry := watcher.JournalEntry{SyslogIdentifier: tt.ident, Message: tt.msg, RealtimeTimestamp: "1708300000000000"}
+		f, ok := ParseSSHFailure(entry)
+		if ok != tt.ok {
+			t.Errorf("ParseSSHFailure(%s: %q) ok = %v, want %v", tt.ident, tt.msg, ok, tt.ok)
+			continue
+		}
+		if ok && (f.Account != tt.account || f.Addr != tt.addr) {
+			t.Errorf("ParseSSHFailure(%q) = %+v, want account %q addr %q", tt.msg, f, tt.account, tt.addr)
+		}
+	}
+}
+
+func TestSSHGuard(t *testing.T) {
+	c := New("testhost")
+	g := c.NewSSHGuard(4, 10*time.Minute)
+
+	start := time.Unix(1708300000, 0)
+	fail := func(after time.Duration, addr, account string) *event.Event {
+		return g.Observe(SSHFailure{Time: start.Add(after), Account: account, Addr: addr})
+	}
+
+	fail(0, "198.51.100.2", "pi")
+	for i, account := range []string{"root", "admin", "root"} {
+		if ev := fail(time.Duration(i)*time.Minute, "203.0.113.7", account); ev != nil {
+			t.Fatalf("failure %d reported: %q", i+1, ev.Summary)
+		}
+	}
+	ev := fail(4*time.Minute, "203.0.113.7", "oracle")
+	if ev == nil {
+		t.Fatal("threshold-th failure within the window not reported")
+	}
+	if ev.Tier != event.TierSecurity || ev.Summary != "SSH brute force: 203.0.113.7 (4 failed logins in 10m)" {
+		t.Errorf("event = %s %q", ev.Tier, ev.Summary)
+	}
+	if ev.DedupKey != "ssh_source=203.0.113.7" {
+		t.Errorf("dedup key = %q", ev.DedupKey)
+	}
+	if !strings.Contains(ev.Detail, "Accounts tried: root, admin, oracle") {
+		t.Errorf("detail does not list the accounts: %q", ev.Detail)
+	}
+	top := ev.Detail[strings.Index(ev.Detail, "Top sources"):]
+	if i, j := strings.Index(top, "203.0.113.7"), strings.Index(top, "198.51.100.2"); i < 0 || j < i {
+		t.Errorf("detail does not list the top sources in order: %q", top)
+	}
+
+	if ev := fail(5*time.Minute, "203.0.113.7", "root"); ev != nil {
+		t.Errorf("address reported twice within the window: %q", ev.Summary)
+	}
+	// Failures spread wider than the window do not add up.
+	for i := range 4 {
+		if ev := fail(time.Duration(20+10*i)*time.Minute, "192.0.2.9", "root"); ev != nil {
+			t.Errorf("slow failures reported: %q", ev.Summary)
+		}
+	}
+}
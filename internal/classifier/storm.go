@@ -0,0 +1,191 @@
+package classifier
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// stormWindow is the span over which the storm guard measures the rate.
+const stormWindow = time.Minute
+
+// maxStormSummaries caps the summaries listed in a storm event's detail.
+const maxStormSummaries = 5
+
+// StormGuard detects event storms: more than a set number of classified
+// events within a minute. During a storm, events are only counted, and the
+// storm is reported by one event when it starts and one when it ends. It is
+// not safe for concurrent use.
+type StormGuard struct {
+	c      *Classifier
+	rate   int
+	recent []stormArrival // arrivals within the last stormWindow, oldest first
+	storm  *storm         // nil unless in a storm
+}
+
+// stormArrival is one classified event seen by the storm guard.
+type stormArrival struct {
+	at      time.Time
+	tier    event.Tier
+	summary string
+}
+
+// storm tallies the events collapsed during a storm.
+type storm struct {
+	start     time.Time
+	tier      event.Tier // the tier most events had when the storm started
+	total     int
+	summaries map[string]int
+}
+
+// NewStormGuard returns a StormGuard that starts a storm once more than
+// rate events arrive within a minute. A rate of zero or less never does.
+func (c *Classifier) NewStormGuard(rate int) *StormGuard {
+	return &StormGuard{c: c, rate: rate}
+}
+
+// Active reports whether a storm is in progress, with when it started and
+// how many events it has collapsed so far.
+func (g *StormGuard) Active() (since time.Time, collapsed int, ok bool) {
+	if g.storm == nil {
+		return time.Time{}, 0, false
+	}
+	return g.storm.start, g.storm.total, true
+}
+
+// SetRate changes the rate, e.g. on a config reload. A storm in progress
+// ends once the rate falls to half the new one.
+func (g *StormGuard) SetRate(rate int) {
+	g.rate = rate
+}
+
+// Observe records ev arriving at now and reports whether it is collapsed
+// into a storm, in which case it should not be handled further. It returns
+// a storm event when ev starts or ends a storm. Critical events and storm
+// events are never collapsed.
+func (g *StormGuard) Observe(ev *event.Event, now time.Time) (storm *event.Event, collapsed bool) {
+	if g.rate <= 0 || ev.RawFields["_storm"] != "" {
+		return nil, false
+	}
+	g.prune(now)
+	g.recent = append(g.recent, stormArrival{at: now, tier: ev.Tier, summary: ev.Summary})
+
+	if g.storm == nil {
+		if len(g.recent) <= g.rate {
+			return nil, false
+		}
+		storm = g.start(now)
+	} else if len(g.recent) <= g.rate/2 {
+		return g.end(now), false
+	}
+
+	if ev.Severity == event.SevCritical {
+		return storm, false
+	}
+	g.storm.total++
+	g.storm.summaries[ev.Summary]++
+	return storm, true
+}
+
+// Check ends the storm if the rate has fallen while no events arrived,
+// returning the event reporting its end, or nil.
+func (g *StormGuard) Check(now time.Time) *event.Event {
+	if g.storm == nil {
+		return nil
+	}
+	g.prune(now)
+	if len(g.recent) > g.rate/2 {
+		return nil
+	}
+	return g.end(now)
+}
+
+// prune forgets arrivals older than stormWindow.
+func (g *StormGuard) prune(now time.Time) {
+	cutoff := now.Add(-stormWindow)
+	i := 0
+	for i < len(g.recent) && !g.recent[i].at.After(cutoff) {
+		i++
+	}
+	g.recent = g.recent[i:]
+}
+
+// start begins a storm and returns a high-severity event reporting it,
+// filed under the tier most of the last minute's events had.
+func (g *StormGuard) start(now time.Time) *event.Event {
+	tiers := make(map[event.Tier]int)
+	summaries := make(map[string]int)
+	for _, a := range g.recent {
+		tiers[a.tier]++
+		summaries[a.summary]++
+	}
+	top := topCounts(summaries)
+	g.storm = &storm{
+		start:     now,
+		tier:      topCounts(tiers)[0],
+		summaries: make(map[string]int),
+	}
+
+	summary := fmt.Sprintf("Event storm: %d events in %s, mostly %q", len(g.recent), formatWindow(stormWindow), top[0])
+	ev := event.New(g.c.instanceID, now, g.storm.tier, event.SevHigh, summary)
+	ev.RawFields["_storm"] = "start"
+	ev.RawFields["_storm_events"] = strconv.Itoa(len(g.recent))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d classified events arrived in the last %s (storm threshold: %d).\n",
+		len(g.recent), formatWindow(stormWindow), g.rate)
+	writeStormSummaries(&b, top, summaries)
+	fmt.Fprintf(&b, "\nUntil fewer than %d events arrive in a minute, further events are counted but not enriched, stored, or notified. Critical events are still handled.",
+		g.rate/2+1)
+	ev.Detail = b.String()
+	return ev
+}
+
+// end finishes the storm and returns an event reporting what was collapsed.
+func (g *StormGuard) end(now time.Time) *event.Event {
+	s := g.storm
+	g.storm = nil
+
+	lasted := now.Sub(s.start).Round(time.Second)
+	summary := fmt.Sprintf("Event storm over: %d events collapsed in %s", s.total, lasted)
+	ev := event.New(g.c.instanceID, now, s.tier, event.SevWarning, summary)
+	ev.RawFields["_storm"] = "end"
+	ev.RawFields["_storm_events"] = strconv.Itoa(s.total)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The event rate fell below %d per minute after %s; events are handled one by one again.\n",
+		g.rate/2+1, lasted)
+	if s.total > 0 {
+		writeStormSummaries(&b, topCounts(s.summaries), s.summaries)
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+	return ev
+}
+
+// writeStormSummaries lists the most frequent summaries with their counts.
+func writeStormSummaries(b *strings.Builder, top []string, counts map[string]int) {
+	b.WriteString("\nMost frequent:\n")
+	for i, s := range top {
+		if i == maxStormSummaries {
+			fmt.Fprintf(b, "  ... and %d more\n", len(top)-i)
+			break
+		}
+		fmt.Fprintf(b, "  %6d  %s\n", counts[s], s)
+	}
+}
+
+// topCounts returns the keys of counts, most frequent first.
+func topCounts[K cmp.Ordered](counts map[K]int) []K {
+	return slices.SortedFunc(maps.Keys(counts), func(a, b K) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+}
@@ -0,0 +1,139 @@
+package classifier
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// windowsEvent identifies an Event Log event by provider and event ID.
+type windowsEvent struct {
+	provider string
+	id       string
+}
+
+// windowsEventTiers lists the Event Log events that report what the journal
+// patterns catch on Linux, with their tier and severity.
+var windowsEventTiers = map[windowsEvent]struct {
+	tier event.Tier
+	sev  event.Severity
+}{
+	// An application crashed.
+	{"Application Error", "1000"}: {event.TierProcessCrash, event.SevHigh},
+
+	// A service terminated unexpectedly (7031 with a recovery action, 7034
+	// without), terminated with an error, or failed to start.
+	{"Service Control Manager", "7031"}: {event.TierServiceFailure, event.SevMedium},
+	{"Service Control Manager", "7034"}: {event.TierServiceFailure, event.SevMedium},
+	{"Service Control Manager", "7023"}: {event.TierServiceFailure, event.SevMedium},
+	{"Service Control Manager", "7000"}: {event.TierServiceFailure, event.SevMedium},
+
+	// Windows diagnosed a low virtual memory condition: its memory pressure
+	// report, naming the largest consumers.
+	{"Microsoft-Windows-Resource-Exhaustion-Detector", "2004"}: {event.TierMemPressure, event.SevHigh},
+
+	// Disk bad blocks, controller and paging errors, retried I/O, NTFS
+	// corruption, and hardware errors reported by WHEA.
+	{"disk", "7"}:                           {event.TierKernelHW, event.SevHigh},
+	{"disk", "11"}:                          {event.TierKernelHW, event.SevHigh},
+	{"disk", "51"}:                          {event.TierKernelHW, event.SevHigh},
+	{"disk", "153"}:                         {event.TierKernelHW, event.SevMedium},
+	{"Ntfs", "55"}:                          {event.TierKernelHW, event.SevHigh},
+	{"Microsoft-Windows-Ntfs", "55"}:        {event.TierKernelHW, event.SevHigh},
+	{"Microsoft-Windows-WHEA-Logger", "1"}:  {event.TierKernelHW, event.SevHigh},
+	{"Microsoft-Windows-WHEA-Logger", "17"}: {event.TierKernelHW, event.SevMedium},
+	{"Microsoft-Windows-WHEA-Logger", "18"}: {event.TierKernelHW, event.SevCritical},
+	{"Microsoft-Windows-WHEA-Logger", "19"}: {event.TierKernelHW, event.SevHigh},
+	{"Microsoft-Windows-WHEA-Logger", "47"}: {event.TierKernelHW, event.SevMedium},
+
+	// The system rebooted without shutting down cleanly, or from a bugcheck.
+	{"Microsoft-Windows-Kernel-Power", "41"}:               {event.TierReboot, event.SevHigh},
+	{"Microsoft-Windows-WER-SystemErrorReporting", "1001"}: {event.TierReboot, event.SevCritical},
+}
+
+// lowMemoryTopRe extracts the largest consumer from a Resource-Exhaustion-
+// Detector report.
+// Example: "...consumed the most virtual memory: chrome.exe (8000) consumed 4294967296 bytes, ..."
+var lowMemoryTopRe = regexp.MustCompile(`virtual memory: (\S+) \((\d+)\) consumed (\d+) bytes`)
+
+// classifyWindowsEvent classifies an entry of watcher.EventLogSource.
+func (c *Classifier) classifyWindowsEvent(entry watcher.JournalEntry, ts time.Time) *event.Event {
+	if entry.Transport != watcher.EventLogTransport {
+		return nil
+	}
+	kind, ok := windowsEventTiers[windowsEvent{entry.SyslogIdentifier, entry.Fields["WINDOWS_EVENT_ID"]}]
+	if !ok {
+		return nil
+	}
+
+	var summary, process, unit string
+	var pid int
+	switch kind.tier {
+	case event.TierProcessCrash:
+		process = windowsData(entry, "AppName", 1)
+		pid = parseWindowsPID(windowsData(entry, "ProcessId", 9))
+		summary = fmt.Sprintf("Crash: %s (pid %d)", process, pid)
+		if module := windowsData(entry, "ModuleName", 4); module != "" {
+			summary += " faulted in " + module
+		}
+		if code := windowsData(entry, "ExceptionCode", 7); code != "" {
+			summary += " (" + code + ")"
+		}
+	case event.TierServiceFailure:
+		unit = windowsData(entry, "param1", 1)
+		summary = "Service failed: " + unit
+	case event.TierMemPressure:
+		summary = "Low virtual memory"
+		if m := lowMemoryTopRe.FindStringSubmatch(entry.Message); m != nil {
+			process, pid = m[1], parseWindowsPID(m[2])
+			summary += ": " + process + " used the most"
+		}
+	case event.TierKernelHW:
+		summary = "Hardware error: " + firstSentence(entry.Message)
+	case event.TierReboot:
+		summary = "Unexpected reboot: " + firstSentence(entry.Message)
+	}
+	if len(summary) > 100 {
+		summary = summary[:97] + "..."
+	}
+
+	ev := event.New(c.instanceID, ts, kind.tier, kind.sev, summary)
+	ev.Process = process
+	ev.PID = pid
+	ev.Unit = unit
+	ev.Detail = entry.Message
+	maps.Copy(ev.RawFields, entry.Fields)
+	return ev
+}
+
+// windowsData returns an event data item by name, or by position for
+// providers that do not name them.
+func windowsData(entry watcher.JournalEntry, name string, pos int) string {
+	if v := entry.Fields["WINDOWS_DATA_"+name]; v != "" {
+		return v
+	}
+	return entry.Fields["WINDOWS_DATA_"+strconv.Itoa(pos)]
+}
+
+// parseWindowsPID parses a process ID in decimal or, as Application Error
+// events write it, in hex with a 0x prefix.
+func parseWindowsPID(s string) int {
+	pid, _ := strconv.ParseInt(s, 0, 64)
+	return int(pid)
+}
+
+// firstSentence returns the first sentence of an Event Log message, which
+// states what happened; the rest explains it.
+func firstSentence(msg string) string {
+	msg, _, _ = strings.Cut(strings.TrimSpace(msg), "\n")
+	if i := strings.Index(msg, ". "); i >= 0 {
+		msg = msg[:i]
+	}
+	return strings.TrimSuffix(msg, ".")
+}
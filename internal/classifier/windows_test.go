@@ -0,0 +1,88 @@
+package classifier
+
+import (
+	"testing"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestClassifyWindowsEvent(t *testing.T) {
+	c := New("testhost")
+	entry := func(provider, id, msg string, data map[string]string) watcher.JournalEntry {
+		fields := map[string]string{"WINDOWS_EVENT_ID": id}
+		for k, v := range data {
+			fields["WINDOWS_DATA_"+k] = v
+		}
+		return watcher.JournalEntry{
+			Message:          msg,
+			SyslogIdentifier: provider,
+			Transport:        watcher.EventLogTransport,
+			Fields:           fields,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		entry   watcher.JournalEntry
+		tier    event.Tier
+		summary string
+		process string
+		unit    string
+	}{
+		{
+			name: "application crash",
+			entry: entry("Application Error", "1000", "Faulting application name: app.exe",
+				map[string]string{"1": "app.exe", "4": "ntdll.dll", "7": "c0000005", "9": "0x1f40"}),
+			tier:    event.TierProcessCrash,
+			summary: "Crash: app.exe (pid 8000) faulted in ntdll.dll (c0000005)",
+			process: "app.exe",
+		},
+		{
+			name:    "service terminated",
+			entry:   entry("Service Control Manager", "7034", "The Print Spooler service terminated unexpectedly.", map[string]string{"param1": "Print Spooler"}),
+			tier:    event.TierServiceFailure,
+			summary: "Service failed: Print Spooler",
+			unit:    "Print Spooler",
+		},
+		{
+			name: "low memory",
+			entry: entry("Microsoft-Windows-Resource-Exhaustion-Detector", "2004",
+				"Windows successfully diagnosed a low virtual memory condition. The following programs consumed the most virtual memory: chrome.exe (8000) consumed 4294967296 bytes, sqlservr.exe (912) consumed 1073741824 bytes.", nil),
+			tier:    event.TierMemPressure,
+			summary: "Low virtual memory: chrome.exe used the most",
+			process: "chrome.exe",
+		},
+		{
+			name:    "bad block",
+			entry:   entry("disk", "7", "The device, \\Device\\Harddisk0\\DR0, has a bad block.", nil),
+			tier:    event.TierKernelHW,
+			summary: "Hardware error: The device, \\Device\\Harddisk0\\DR0, has a bad block",
+		},
+		{
+			name:    "unclean reboot",
+			entry:   entry("Microsoft-Windows-Kernel-Power", "41", "The system has rebooted without cleanly shutting down first. This error could be caused by...", nil),
+			tier:    event.TierReboot,
+			summary: "Unexpected reboot: The system has rebooted without cleanly shutting down first",
+		},
+	}
+	for _, tt := range tests {
+		ev := c.Classify(tt.entry)
+		if ev == nil {
+			t.Fatalf("%s: expected an event", tt.name)
+		}
+		if ev.Tier != tt.tier || ev.Summary != tt.summary || ev.Process != tt.process || ev.Unit != tt.unit {
+			t.Errorf("%s: tier = %q, summary = %q, process = %q, unit = %q", tt.name, ev.Tier, ev.Summary, ev.Process, ev.Unit)
+		}
+	}
+
+	// Other events, and journal entries that look alike, are not matched here.
+	if ev := c.Classify(entry("Service Control Manager", "7036", "The Print Spooler service entered the running state.", nil)); ev != nil {
+		t.Errorf("unlisted event classified: %q", ev.Summary)
+	}
+	journal := entry("disk", "7", "bad block", nil)
+	journal.Transport = "journal"
+	if ev := c.Classify(journal); ev != nil {
+		t.Errorf("journal entry classified as an Event Log event: %q", ev.Summary)
+	}
+}
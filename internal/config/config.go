@@ -2,10 +2,17 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -13,15 +20,70 @@ import (
 
 // Config is the top-level configuration for logtriage.
 type Config struct {
-	Instance InstanceConfig `toml:"instance"`
-	Ntfy     NtfyConfig     `toml:"ntfy"`
-	Digest   DigestConfig   `toml:"digest"`
-	Cooldown CooldownConfig `toml:"cooldown"`
-	PSI      PSIConfig      `toml:"psi"`
-	SMART    SMARTConfig    `toml:"smart"`
-	GPU      GPUConfig      `toml:"gpu"`
-	DB       DBConfig       `toml:"db"`
-	Log      LogConfig      `toml:"log"`
+	// Include lists extra config files or glob patterns, relative to the
+	// main config file's directory, merged after it. Only read from the
+	// main file; being a top-level key, it must come before any table.
+	Include []string `toml:"include"`
+
+	Instance    InstanceConfig    `toml:"instance"`
+	Ntfy        NtfyConfig        `toml:"ntfy"`
+	Slack       SlackConfig       `toml:"slack"`
+	Email       EmailConfig       `toml:"email"`
+	Webhook     WebhookConfig     `toml:"webhook"`
+	Syslog      SyslogConfig      `toml:"syslog"`
+	OTel        OTelConfig        `toml:"otel"`
+	Loki        LokiConfig        `toml:"loki"`
+	Heartbeat   HeartbeatConfig   `toml:"heartbeat"`
+	Notify      NotifyConfig      `toml:"notify"`
+	Digest      DigestConfig      `toml:"digest"`
+	Cooldown    CooldownConfig    `toml:"cooldown"`
+	Escalation  EscalationConfig  `toml:"escalation"`
+	Schedule    ScheduleConfig    `toml:"schedule"`
+	PSI         PSIConfig         `toml:"psi"`
+	Thrash      ThrashConfig      `toml:"thrash"`
+	EBPF        EBPFConfig        `toml:"ebpf"`
+	SMART       SMARTConfig       `toml:"smart"`
+	Arrays      ArraysConfig      `toml:"arrays"`
+	GPU         GPUConfig         `toml:"gpu"`
+	Quota       QuotaConfig       `toml:"quota"`
+	Disk        DiskConfig        `toml:"diskspace"`
+	Inventory   InventoryConfig   `toml:"inventory"`
+	Units       UnitsConfig       `toml:"units"`
+	UnitLimits  UnitLimitsConfig  `toml:"unit_limits"`
+	Loop        LoopConfig        `toml:"restart_loop"`
+	Storm       StormConfig       `toml:"storm"`
+	SelfMonitor SelfMonitorConfig `toml:"self_monitor"`
+	Containers  ContainersConfig  `toml:"containers"`
+	UserJournal UserJournalConfig `toml:"user_journal"`
+	Kubernetes  KubernetesConfig  `toml:"kubernetes"`
+	EventLog    EventLogConfig    `toml:"eventlog"`
+	Audit       AuditConfig       `toml:"audit"`
+	SSH         SSHConfig         `toml:"ssh"`
+	Security    SecurityConfig    `toml:"security"`
+	Crashes     CrashesConfig     `toml:"crashes"`
+	Services    ServicesConfig    `toml:"services"`
+	Catchall    CatchallConfig    `toml:"catchall"`
+	Capture     CaptureConfig     `toml:"capture"`
+	Bundle      BundleConfig      `toml:"bundle"`
+	Boot        BootConfig        `toml:"boot"`
+	Health      HealthConfig      `toml:"health"`
+	Control     ControlConfig     `toml:"control"`
+	Reload      ReloadConfig      `toml:"reload"`
+	Web         WebConfig         `toml:"web"`
+	Ack         AckConfig         `toml:"ack"`
+	Hub         HubConfig         `toml:"hub"`
+	Agent       AgentConfig       `toml:"agent"`
+	DB          DBConfig          `toml:"db"`
+	Log         LogConfig         `toml:"log"`
+	Rules       []RuleConfig      `toml:"rules"`
+	Suppress    SuppressConfig    `toml:"suppress"`
+	Remediation RemediationConfig `toml:"remediation"`
+	Hooks       []HookConfig      `toml:"hooks"`
+
+	// Files lists the config files that were loaded, in merge order.
+	Files []string `toml:"-"`
+
+	sources map[string]keySource // where each key was last set, for Validate
 }
 
 // InstanceConfig identifies this machine.
@@ -35,46 +97,675 @@ type NtfyConfig struct {
 	URL         string            `toml:"url"`
 	PriorityMap map[string]string `toml:"priority_map"`
 	AlertTiers  []string          `toml:"alert_tiers"`
+
+	// TierTopics sends the listed tiers to their own topic URL instead of
+	// url, e.g. T1 to an "urgent" topic. Values may be templates like url.
+	TierTopics map[string]string `toml:"tier_topics"`
+
+	// Token is an access token for servers with access control. Username
+	// and Password are basic auth instead; set one or the other.
+	Token    string `toml:"token"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+
+	Click string `toml:"click"` // URL opened when the notification is tapped
+	Icon  string `toml:"icon"`  // notification icon URL
+	Email string `toml:"email"` // also forward each notification to this address
+}
+
+// SlackConfig controls the Slack/Mattermost incoming webhook target.
+type SlackConfig struct {
+	WebhookURL string   `toml:"webhook_url"`
+	Channel    string   `toml:"channel"`     // optional channel override
+	Username   string   `toml:"username"`    // optional display name override
+	IconEmoji  string   `toml:"icon_emoji"`  // optional, e.g. ":rotating_light:"
+	AlertTiers []string `toml:"alert_tiers"` // defaults to ntfy.alert_tiers if empty
+}
+
+// EmailConfig controls the SMTP notification target.
+type EmailConfig struct {
+	Host       string   `toml:"host"`
+	Port       int      `toml:"port"`
+	Username   string   `toml:"username"`
+	Password   string   `toml:"password"`
+	From       string   `toml:"from"`
+	To         []string `toml:"to"`
+	TLS        string   `toml:"tls"`         // "starttls", "tls" (implicit), or "none"
+	AlertTiers []string `toml:"alert_tiers"` // defaults to ntfy.alert_tiers if empty
+}
+
+// WebhookConfig controls the generic JSON webhook target, which receives
+// lifecycle transitions (created, aggregated, escalated, acked, resolved).
+type WebhookConfig struct {
+	URL         string   `toml:"url"`
+	Secret      string   `toml:"secret"`      // signs payloads with HMAC-SHA256 when set
+	AlertTiers  []string `toml:"alert_tiers"` // defaults to ntfy.alert_tiers if empty
+	Transitions []string `toml:"transitions"` // empty means all
+}
+
+// SyslogConfig re-emits classified events as RFC 5424 syslog messages, so
+// an existing syslog collector or SIEM receives the classifications.
+type SyslogConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Network  string   `toml:"network"`  // "unix", "udp", or "tcp"
+	Address  string   `toml:"address"`  // socket path or host:port
+	Facility string   `toml:"facility"` // e.g. "local0", "daemon"
+	Tiers    []string `toml:"tiers"`    // tiers to send; empty means all
+}
+
+// OTelConfig exports classified events as OpenTelemetry log records, and
+// closed incidents as spans, to an OTLP/HTTP collector, so observability
+// stacks such as Grafana with Loki and Tempo receive them.
+type OTelConfig struct {
+	Enabled       bool              `toml:"enabled"`
+	Endpoint      string            `toml:"endpoint"`       // collector base URL; /v1/logs and /v1/traces are appended
+	Headers       map[string]string `toml:"headers"`        // sent with every request, e.g. for authentication
+	Tiers         []string          `toml:"tiers"`          // tiers to export as logs; empty means all
+	Spans         bool              `toml:"spans"`          // export incidents as spans when they close
+	BatchInterval Duration          `toml:"batch_interval"` // how often queued records are sent
+}
+
+// LokiConfig pushes classified events to Grafana Loki, labelled by
+// instance, tier, and severity, so they appear beside the raw logs.
+type LokiConfig struct {
+	Enabled       bool     `toml:"enabled"`
+	URL           string   `toml:"url"`            // push API URL, e.g. http://loki:3100/loki/api/v1/push
+	Tenant        string   `toml:"tenant"`         // X-Scope-OrgID for multi-tenant Loki; empty for none
+	Tiers         []string `toml:"tiers"`          // tiers to push; empty means all
+	BatchInterval Duration `toml:"batch_interval"` // how often queued events are pushed
+
+	// Username and Password are basic auth, e.g. a Grafana Cloud user ID
+	// and access token.
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// HeartbeatConfig pings a dead man's switch, such as a healthchecks.io
+// check, while the daemon is healthy, so a dead daemon or host is noticed
+// by the pings stopping.
+type HeartbeatConfig struct {
+	URL      string   `toml:"url"`      // pinged every interval; empty disables
+	Interval Duration `toml:"interval"` // how often url is pinged
+
+	// DigestURL is pinged after each digest is delivered, so a separate
+	// check can catch digests that stop arriving. It defaults to URL.
+	DigestURL string `toml:"digest_url"`
+}
+
+// NotifyConfig controls behavior shared by all notification sinks.
+type NotifyConfig struct {
+	// BatchWindow merges notifications to the same sink that arrive within
+	// this window of the first one into a single message. Off (0) by
+	// default, since it holds back a second critical alert too.
+	BatchWindow Duration `toml:"batch_window"`
+
+	// RetryMaxAge is how long a notification a sink failed to deliver is
+	// kept and retried with backoff before it is given up. 0 disables the
+	// retry queue.
+	RetryMaxAge Duration `toml:"retry_max_age"`
+
+	// Resolved sends a low-priority notification when the problem of an
+	// alerted incident clears, such as a failed unit running again, and
+	// the incident closes.
+	Resolved bool `toml:"resolved"`
+}
+
+// ScheduleConfig holds back alerts during quiet hours. Held alerts are
+// delivered together as one summary per sink when the quiet hours end.
+// Critical events always break through.
+type ScheduleConfig struct {
+	// QuietHours is a daily span of local time, e.g. "23:00-07:00".
+	// Empty disables quiet hours.
+	QuietHours string `toml:"quiet_hours"`
+
+	// TierQuietHours overrides QuietHours for some tiers; an empty span
+	// means the tier is never held.
+	TierQuietHours map[string]string `toml:"tier_quiet_hours"`
+
+	// BreakThrough lists tiers delivered at once even during quiet hours.
+	BreakThrough []string `toml:"break_through"`
 }
 
 // DigestConfig controls weekly digest generation.
 type DigestConfig struct {
 	Enabled bool   `toml:"enabled"`
 	Topic   string `toml:"topic"` // defaults to ntfy.url if empty
+
+	// Schedule has the daemon send the digest itself, e.g. "Sun 09:00",
+	// "Mon,Thu 08:30", "Mon-Fri 18:00", or "09:00" for every day. Each
+	// digest covers the time since the previous scheduled one. Empty
+	// leaves digests to the logtriage-digest.timer unit.
+	Schedule     string `toml:"schedule"`
+	Via          string `toml:"via"`           // ntfy or email, for scheduled digests
+	AllInstances bool   `toml:"all_instances"` // scheduled digests cover the fleet (on a hub)
 }
 
 // CooldownConfig controls dedup/cooldown behavior.
 type CooldownConfig struct {
 	Window             Duration `toml:"window"`
 	AggregateThreshold int      `toml:"aggregate_threshold"`
+
+	// Keys lists, per tier, the fields whose values must all match for an
+	// event to count as a repeat, e.g. T4 = ["device"]. Tiers not listed
+	// match on the unit, or the process when there is no unit.
+	Keys map[string][]string `toml:"keys"`
+
+	// Overrides give matching events their own window and threshold,
+	// written as [[cooldown.override]] tables. The first match applies.
+	Overrides []CooldownOverride `toml:"override"`
+}
+
+// CooldownOverride sets the cooldown for the events it matches. All set
+// matchers must match; regexes are unanchored. An unset window or
+// threshold is the [cooldown] one.
+type CooldownOverride struct {
+	Name               string   `toml:"name"`
+	Tier               string   `toml:"tier"`    // exact tier
+	Unit               string   `toml:"unit"`    // regex against the systemd unit
+	Process            string   `toml:"process"` // regex against the event process
+	Window             Duration `toml:"window"`
+	AggregateThreshold int      `toml:"aggregate_threshold"`
+}
+
+// EscalationConfig re-alerts on a problem that keeps repeating after the
+// cooldown's aggregate alert, which would otherwise be its last.
+type EscalationConfig struct {
+	Enabled   bool     `toml:"enabled"`
+	Threshold int      `toml:"threshold"` // repeats within window that escalate
+	Window    Duration `toml:"window"`
+	Severity  string   `toml:"severity"` // severity of the escalated alert; empty raises it one level
+	Tiers     []string `toml:"tiers"`    // tiers that escalate; empty means all
+	NtfyURL   string   `toml:"ntfy_url"` // secondary topic that also gets escalated alerts
 }
 
-// PSIConfig controls the /proc/pressure memory monitor.
+// PSIConfig controls the /proc/pressure monitors. The top-level thresholds
+// are memory's; CPU and IO pressure have their own.
 type PSIConfig struct {
-	Enabled      bool    `toml:"enabled"`
-	PollInterval Duration `toml:"poll_interval"`
+	Enabled       bool              `toml:"enabled"`
+	PollInterval  Duration          `toml:"poll_interval"`
+	WarnSomeAvg10 float64           `toml:"warn_some_avg10"`
+	WarnFullAvg10 float64           `toml:"warn_full_avg10"`
+	CPU           PSIResourceConfig `toml:"cpu"`
+	IO            PSIResourceConfig `toml:"io"`
+}
+
+// PSIResourceConfig controls the monitor of /proc/pressure/cpu or
+// /proc/pressure/io. A zero threshold never fires.
+type PSIResourceConfig struct {
+	Enabled       bool    `toml:"enabled"`
 	WarnSomeAvg10 float64 `toml:"warn_some_avg10"`
 	WarnFullAvg10 float64 `toml:"warn_full_avg10"`
+	Tier          string  `toml:"tier"` // tier of its events, e.g. "T6"
+}
+
+// ThrashConfig controls swap thrash detection from major page fault and
+// swap rates, and low available memory detection.
+type ThrashConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	PollInterval Duration `toml:"poll_interval"`
+	MajFaultRate float64  `toml:"majfault_rate"` // major faults per second
+	SwapRate     float64  `toml:"swap_rate"`     // pages swapped in plus out per second; 0 disables
+	Sustain      Duration `toml:"sustain"`       // how long a rate must stay high, or memory low
+
+	// MemAvailableWarnPct is the MemAvailable share of MemTotal, in
+	// percent, below which memory is low; 0 disables.
+	MemAvailableWarnPct float64 `toml:"mem_available_warn_pct"`
 }
 
 // SMARTConfig controls smartctl disk health polling.
 type SMARTConfig struct {
+	Enabled      bool                `toml:"enabled"`
+	PollInterval Duration            `toml:"poll_interval"`
+	TempWarnHDD  int                 `toml:"temp_warn_hdd"` // degrees C for spinning disks; 0 disables
+	TempWarnSSD  int                 `toml:"temp_warn_ssd"` // degrees C for SSDs and NVMe; 0 disables
+	TempSustain  Duration            `toml:"temp_sustain"`  // how long a drive must stay above its limit
+	Devices      []SMARTDeviceConfig `toml:"devices"`
+}
+
+// SMARTDeviceConfig overrides the temperature limit for matching devices.
+type SMARTDeviceConfig struct {
+	Device   string `toml:"device"`    // device path glob, e.g. "/dev/sda" or "/dev/nvme*"
+	TempWarn int    `toml:"temp_warn"` // degrees C; 0 disables temperature alerts
+}
+
+// EBPFConfig controls the eBPF tracer, which catches OOM kills and crashes
+// in the kernel with bpftrace, independently of the journal.
+type EBPFConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// ArraysConfig controls storage array health polling: md RAID via
+// /proc/mdstat, ZFS pools via zpool, and btrfs filesystems via btrfs.
+type ArraysConfig struct {
 	Enabled      bool     `toml:"enabled"`
 	PollInterval Duration `toml:"poll_interval"`
 }
 
 // GPUConfig controls GPU monitoring via sysfs and vendor tools.
 type GPUConfig struct {
+	Enabled      bool            `toml:"enabled"`
+	PollInterval Duration        `toml:"poll_interval"`
+	TempWarn     int             `toml:"temp_warn"`     // degrees C, emit warning above this
+	VRAMWarnPct  int             `toml:"vram_warn_pct"` // emit warning when VRAM usage exceeds this %
+	Cards        []GPUCardConfig `toml:"cards"`
+}
+
+// GPUCardConfig overrides the thresholds for one card. A zero threshold
+// keeps the [gpu] value.
+type GPUCardConfig struct {
+	Card        string `toml:"card"`          // card index ("1" or "card1") or PCI address ("0000:03:00.0")
+	TempWarn    int    `toml:"temp_warn"`     // degrees C
+	VRAMWarnPct int    `toml:"vram_warn_pct"` // percent
+}
+
+// QuotaConfig controls filesystem quota polling via repquota/xfs_quota.
+type QuotaConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	PollInterval Duration `toml:"poll_interval"`
+	WarnPct      float64  `toml:"warn_pct"` // warn when usage reaches this % of the soft (or hard) limit
+	Subjects     []string `toml:"subjects"` // e.g. ["user:alice", "group:*"]; empty means all
+}
+
+// DiskConfig controls free space and inode polling of mounted filesystems.
+type DiskConfig struct {
+	Enabled      bool              `toml:"enabled"`
+	PollInterval Duration          `toml:"poll_interval"`
+	WarnPct      float64           `toml:"warn_pct"` // space used, as df reports it
+	CritPct      float64           `toml:"crit_pct"`
+	InodeWarnPct float64           `toml:"inode_warn_pct"`
+	InodeCritPct float64           `toml:"inode_crit_pct"`
+	TopDirs      int               `toml:"top_dirs"` // largest directories listed in the detail; 0 disables the scan
+	Mounts       []DiskMountConfig `toml:"mounts"`
+}
+
+// DiskMountConfig is a mountpoint to watch. Zero thresholds inherit the
+// [diskspace] defaults.
+type DiskMountConfig struct {
+	Path         string  `toml:"path"`
+	WarnPct      float64 `toml:"warn_pct"`
+	CritPct      float64 `toml:"crit_pct"`
+	InodeWarnPct float64 `toml:"inode_warn_pct"`
+	InodeCritPct float64 `toml:"inode_crit_pct"`
+}
+
+// InventoryConfig controls the hardware inventory snapshot, which alerts
+// when a disk, GPU, or NIC disappears or installed memory shrinks.
+type InventoryConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	PollInterval Duration `toml:"poll_interval"`
+	Ignore       []string `toml:"ignore"` // device name globs to leave out, e.g. "usb*"
+}
+
+// UnitsConfig controls the systemd D-Bus unit state monitor.
+type UnitsConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Match   []string `toml:"match"`  // unit name globs to watch; empty means all
+	Ignore  []string `toml:"ignore"` // unit name globs to skip
+}
+
+// UnitLimitsConfig controls polling of systemd units' open file descriptors
+// and task counts against LimitNOFILE and TasksMax.
+type UnitLimitsConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	PollInterval Duration `toml:"poll_interval"`
+	Units        []string `toml:"units"`    // unit names or globs of active units
+	WarnPct      float64  `toml:"warn_pct"` // of the limit, for both resources
+	CritPct      float64  `toml:"crit_pct"`
+}
+
+// LoopConfig controls restart-loop detection: a unit that fails Failures
+// times within Window gets a single high-severity event.
+type LoopConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Failures int      `toml:"failures"`
+	Window   Duration `toml:"window"`
+}
+
+// StormConfig controls the storm guard: once more than Rate classified
+// events arrive within a minute, further events are counted into a single
+// storm event instead of being enriched, stored, and notified one by one,
+// until the rate falls to half of Rate.
+type StormConfig struct {
+	Enabled bool `toml:"enabled"`
+	Rate    int  `toml:"rate"` // events per minute
+}
+
+// SelfMonitorConfig controls the self-monitor: a warning or error the
+// daemon logs Repeats times within Window raises a single T0 internal
+// event, so its own chronic failures reach the digest.
+type SelfMonitorConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Repeats int      `toml:"repeats"`
+	Window  Duration `toml:"window"`
+}
+
+// ContainersConfig controls classification of Docker and Podman container
+// exits, which the runtimes log at info level and so need their own journal
+// stream.
+type ContainersConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// KubernetesConfig controls the pod container source, which polls the
+// node's container runtime with crictl for containers that exited with an
+// error or were OOM killed.
+type KubernetesConfig struct {
 	Enabled      bool     `toml:"enabled"`
 	PollInterval Duration `toml:"poll_interval"`
-	TempWarn     int      `toml:"temp_warn"`     // degrees C, emit warning above this
-	VRAMWarnPct  int      `toml:"vram_warn_pct"` // emit warning when VRAM usage exceeds this %
 }
 
-// DBConfig controls SQLite event storage.
+// EventLogConfig controls the Windows Event Log source, which stands in
+// for the journal on Windows. It is ignored on other platforms.
+type EventLogConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	Channels     []string `toml:"channels"` // e.g. "System", "Application"
+	PollInterval Duration `toml:"poll_interval"`
+}
+
+// UserJournalConfig controls following the per-user journal, so failures
+// of user services (pipewire, gnome-session components) are classified.
+type UserJournalConfig struct {
+	Enabled bool     `toml:"enabled"`
+	Units   []string `toml:"units"` // user units to follow; empty means all
+}
+
+// AuditConfig controls classification of Linux audit records: seccomp
+// kills, SELinux and AppArmor denials, and repeated authentication
+// failures, all T9 security events.
+type AuditConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	Source       string   `toml:"source"`        // "journal" or "ausearch"
+	PollInterval Duration `toml:"poll_interval"` // of ausearch
+	AuthFailures int      `toml:"auth_failures"` // failures of an account within auth_window to report; 0 disables
+	AuthWindow   Duration `toml:"auth_window"`
+}
+
+// SSHConfig controls SSH brute-force detection: a source address failing
+// to log in Failures times within Window is reported as a T9 event. sshd
+// logs failed logins at info level, so they need their own journal stream.
+type SSHConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Failures int      `toml:"failures"`
+	Window   Duration `toml:"window"`
+}
+
+// SecurityKinds are the kinds of T9 security events, the keys of
+// security.severity and security.roles.
+var SecurityKinds = []string{
+	"root_login", "sudo_failure", "ssh_key",
+	"ssh_bruteforce", "auth_failures", "seccomp", "access_denial",
+}
+
+// SecurityConfig controls login and privilege events: successful root
+// logins, sudo authentication failures, and keys added to authorized_keys
+// files. They are T9 events like those of [ssh] and [audit]. Topic sends
+// every T9 event to its own ntfy topic. Severity overrides the severity of
+// a kind of T9 event, and Roles overrides it again for machines of one
+// instance.role, e.g. to make root logins critical on servers only.
+type SecurityConfig struct {
+	Enabled        bool                         `toml:"enabled"`
+	AuthorizedKeys []string                     `toml:"authorized_keys"` // globs of the files watched for new keys
+	PollInterval   Duration                     `toml:"poll_interval"`   // of the authorized_keys files
+	Topic          string                       `toml:"topic"`           // ntfy URL for T9 events; may be a template like ntfy.url
+	Severity       map[string]string            `toml:"severity"`        // kind -> severity
+	Roles          map[string]map[string]string `toml:"roles"`           // role -> kind -> severity
+}
+
+// SeverityFor returns the severity configured for a kind of security event
+// on a machine of the given role, or "" to keep the classifier's.
+func (s SecurityConfig) SeverityFor(kind, role string) string {
+	if sev := s.Roles[role][kind]; sev != "" {
+		return sev
+	}
+	return s.Severity[kind]
+}
+
+// CatchallConfig controls the catch-all for severe journal lines that match
+// no pattern. They are stored as T8 unclassified events and summarized in
+// the digest, never notified, so gaps in pattern coverage become visible.
+type CatchallConfig struct {
+	Enabled     bool `toml:"enabled"`
+	MaxPriority int  `toml:"max_priority"` // syslog priority, 0 (emerg) to 7 (debug)
+}
+
+// CrashesConfig controls handling of processes known to crash often.
+type CrashesConfig struct {
+	// KnownCrashy lists process name globs whose crashes are stored but
+	// not pushed, except the first time a new crash signature appears.
+	KnownCrashy []string `toml:"known_crashy"`
+
+	// Debugger runs gdb on each crash's coredump, via coredumpctl debug,
+	// and shows its symbolized backtrace in the alert. gdb may take long
+	// to load debug info; it is stopped after DebuggerTimeout.
+	Debugger        bool     `toml:"debugger"`
+	DebuggerTimeout Duration `toml:"debugger_timeout"`
+}
+
+// IsKnownCrashy reports whether process matches a known_crashy glob.
+func (c CrashesConfig) IsKnownCrashy(process string) bool {
+	if process == "" {
+		return false
+	}
+	for _, pattern := range c.KnownCrashy {
+		if ok, _ := filepath.Match(pattern, process); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ServicesConfig controls what service failure alerts say about the failed
+// unit beyond its last log lines, so they can be acted on without a shell.
+type ServicesConfig struct {
+	UnitInfo bool `toml:"unit_info"` // the unit's ExecStart command and Restart= policy
+	Status   bool `toml:"status"`    // the header of systemctl status: state, result, processes
+}
+
+// HealthConfig controls the pipeline health checks that gate systemd
+// watchdog pings.
+type HealthConfig struct {
+	Listen       string   `toml:"listen"`        // serve GET /healthz on this address; empty disables
+	JournalGrace Duration `toml:"journal_grace"` // how long a journal entry may go unreceived
+}
+
+// ControlConfig controls the control socket the status and ctl subcommands
+// query the running daemon over.
+type ControlConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Socket  string `toml:"socket"` // defaults to $XDG_RUNTIME_DIR/logtriage.sock
+}
+
+// ReloadConfig controls how the daemon picks up config edits besides
+// SIGHUP and the control socket.
+type ReloadConfig struct {
+	Watch bool `toml:"watch"` // reload when the config files change
+}
+
+// WebConfig controls the local web dashboard.
+type WebConfig struct {
+	Listen string     `toml:"listen"` // e.g. "127.0.0.1:9247"; a bare ":9247" binds to localhost; empty disables the dashboard
+	Tokens []WebToken `toml:"tokens"` // with none, only local clients are served, with full access
+}
+
+// WebToken is a bearer token for the dashboard and its API.
+type WebToken struct {
+	Name  string `toml:"name"` // identifies the token in logs
+	Token string `toml:"token"`
+	Role  string `toml:"role"` // "read", "ack", or "admin"
+}
+
+// AckConfig controls the Ack button on ntfy notifications. The button posts
+// a signed link to the dashboard, so it needs web.listen on an address the
+// phones subscribed to the topic can reach.
+type AckConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	URL      string   `toml:"url"`      // base URL the dashboard is reached at, e.g. "https://host.example.net:9247"
+	Secret   string   `toml:"secret"`   // signs ack links
+	Duration Duration `toml:"duration"` // how long an acknowledged problem stays quiet
+}
+
+// BootConfig controls unexpected reboot detection at startup.
+type BootConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// CaptureConfig controls the debug capture that runs when a critical
+// incident opens.
+type CaptureConfig struct {
+	Enabled        bool     `toml:"enabled"`
+	Duration       Duration `toml:"duration"`        // how long each capture runs
+	SampleInterval Duration `toml:"sample_interval"` // system stat sampling period
+	Priority       int      `toml:"priority"`        // capture journal priorities 0..priority
+	MaxLines       int      `toml:"max_lines"`       // journal lines kept per capture
+	MinInterval    Duration `toml:"min_interval"`    // minimum gap between capture starts
+}
+
+// BundleConfig controls the diagnostic bundle written when a critical OOM
+// kill or hardware event is seen.
+type BundleConfig struct {
+	Enabled    bool     `toml:"enabled"`
+	Dir        string   `toml:"dir"`         // defaults to bundles/ under the data directory
+	Window     Duration `toml:"window"`      // how much journal before the event to include
+	MaxBundles int      `toml:"max_bundles"` // oldest bundles beyond this are deleted
+	Retention  Duration `toml:"retention"`   // bundles older than this are deleted
+}
+
+// HubConfig enables the HTTP ingest API that agents forward events to.
+type HubConfig struct {
+	Listen string `toml:"listen"` // e.g. ":9245"; empty disables hub mode
+	Token  string `toml:"token"`  // bearer token agents must present
+}
+
+// AgentConfig forwards classified events to a hub.
+type AgentConfig struct {
+	HubURL        string   `toml:"hub_url"` // e.g. "http://hub.lan:9245"; empty disables forwarding
+	Token         string   `toml:"token"`
+	NotifyLocal   bool     `toml:"notify_local"`   // also notify via this host's own sinks
+	SpoolDir      string   `toml:"spool_dir"`      // queue for events the hub has not accepted yet
+	SpoolMaxMB    int      `toml:"spool_max_mb"`   // oldest events are dropped beyond this size
+	RetryInterval Duration `toml:"retry_interval"` // how often to retry an unreachable hub
+}
+
+// RuleConfig is a user-defined classification rule, written as a [[rules]]
+// table. Rules are evaluated in order before the built-in patterns.
+type RuleConfig struct {
+	Name       string `toml:"name"`
+	Pattern    string `toml:"pattern"`    // regex matched against MESSAGE
+	Identifier string `toml:"identifier"` // optional exact SYSLOG_IDENTIFIER filter
+	Unit       string `toml:"unit"`       // optional exact _SYSTEMD_UNIT filter
+	Tier       string `toml:"tier"`
+	Severity   string `toml:"severity"`
+	Summary    string `toml:"summary"` // template; $1 or ${name} expand capture groups
+	Process    string `toml:"process"` // optional template for the dedup process name
+}
+
+// SuppressConfig holds ignore rules for known-benign noise.
+type SuppressConfig struct {
+	Rules []SuppressRule `toml:"rules"`
+}
+
+// SuppressRule silences matching entries or events, written as a
+// [[suppress.rules]] table. All set matchers must match. Regexes are
+// unanchored.
+type SuppressRule struct {
+	Name    string `toml:"name"`
+	Message string `toml:"message"` // regex against the journal MESSAGE
+	Unit    string `toml:"unit"`    // regex against the systemd unit
+	Process string `toml:"process"` // regex against the identifier / event process
+	Tier    string `toml:"tier"`    // exact tier; only valid at the alert stage
+	// Stage is "alert" (default: store the event but never notify) or
+	// "classify" (drop the journal entry before classification).
+	Stage string `toml:"stage"`
+}
+
+// RemediationConfig controls automatic remediation: actions, such as
+// restarting a failed unit, run when an event matches them. Nothing runs
+// unless it is enabled and the event's unit is allow-listed.
+type RemediationConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// AllowedUnits lists the units actions may run for, as names or globs
+	// such as "nginx.service" or "myapp-*.service".
+	AllowedUnits []string `toml:"allowed_units"`
+
+	// Timeout bounds each action's command. The event waits for it, so
+	// its notification can say how it went; as the pipeline waits with
+	// it, it is at most maxRemediationTimeout.
+	Timeout Duration `toml:"timeout"`
+
+	Actions []RemediationAction `toml:"actions"`
+}
+
+// maxRemediationTimeout keeps an action from holding up the pipeline, and
+// its systemd watchdog pings, for long.
+const maxRemediationTimeout = 15 * time.Second
+
+// RemediationAction is an action run for matching events, written as a
+// [[remediation.actions]] table. The first matching action runs.
+type RemediationAction struct {
+	Name string `toml:"name"`
+	Tier string `toml:"tier"` // exact tier
+	Unit string `toml:"unit"` // regex against the event's unit
+
+	// Command is the program and its arguments, run without a shell.
+	// "{unit}" in an argument is replaced with the event's unit, and
+	// "{uid}" with the uid of a user unit's owner; only a command with
+	// "{uid}" runs for user units. Defaults to systemctl restart {unit},
+	// with --user --machine={uid}@ for user units.
+	Command []string `toml:"command"`
+
+	// MaxRuns is how many times the action may run for one unit within
+	// Per; further matches are only noted in the notification.
+	MaxRuns int      `toml:"max_runs"`
+	Per     Duration `toml:"per"`
+}
+
+// HookConfig is a user script run for matching events, written as a
+// [[hooks]] table. The script gets the event as JSON on stdin, so it can
+// page another system or attempt its own remediation.
+type HookConfig struct {
+	Name string `toml:"name"`
+
+	// Command is the program and its arguments, run without a shell.
+	Command []string `toml:"command"`
+
+	// Filters; an unset one matches every event.
+	Tiers       []string `toml:"tiers"`
+	MinSeverity string   `toml:"min_severity"` // warning, medium, high, or critical
+	Unit        string   `toml:"unit"`         // regex against the event's unit
+
+	// Timeout bounds each run (default 30s). Concurrency is how many runs
+	// may be in flight at once (default 1); an event arriving when all are
+	// busy is skipped.
+	Timeout     Duration `toml:"timeout"`
+	Concurrency int      `toml:"concurrency"`
+
+	// MaxRuns caps the runs within Per (default 1h); 0 is unlimited.
+	MaxRuns int      `toml:"max_runs"`
+	Per     Duration `toml:"per"`
+}
+
+// DBConfig controls event storage.
 type DBConfig struct {
+	// Driver selects the database: "sqlite" (the default), a file at Path,
+	// or "postgres", the database DSN names, for a hub taking the events
+	// of many agents.
+	Driver    string   `toml:"driver"`
+	DSN       string   `toml:"dsn"`
 	Path      string   `toml:"path"`
 	Retention Duration `toml:"retention"`
+
+	// WriteQueue is how many event writes the daemon buffers for its
+	// background writer, which commits them in batches, before the event
+	// loop waits for it. 0 writes each event as it arrives.
+	WriteQueue int `toml:"write_queue"`
+
+	// BackupInterval, if set, has the daemon back up the database this
+	// often with SQLite's online backup into BackupDir, keeping the newest
+	// BackupKeep backups.
+	BackupInterval Duration `toml:"backup_interval"`
+	BackupDir      string   `toml:"backup_dir"`
+	BackupKeep     int      `toml:"backup_keep"`
 }
 
 // LogConfig controls logging.
@@ -114,7 +805,7 @@ func Default() *Config {
 	if hostname == "" {
 		hostname = "unknown"
 	}
-	return &Config{
+	c := &Config{
 		Instance: InstanceConfig{
 			ID:   hostname,
 			Role: "desktop",
@@ -127,22 +818,62 @@ func Default() *Config {
 			},
 			AlertTiers: []string{"T1", "T2"},
 		},
+		Email: EmailConfig{
+			Port: 587,
+			TLS:  "starttls",
+		},
+		Notify: NotifyConfig{
+			RetryMaxAge: Duration{24 * time.Hour},
+			Resolved:    true,
+		},
 		Digest: DigestConfig{
 			Enabled: true,
+			Via:     "ntfy",
 		},
 		Cooldown: CooldownConfig{
 			Window:             Duration{5 * time.Minute},
 			AggregateThreshold: 3,
 		},
+		Escalation: EscalationConfig{
+			Enabled:   false,
+			Threshold: 10,
+			Window:    Duration{time.Hour},
+		},
 		PSI: PSIConfig{
 			Enabled:       true,
 			PollInterval:  Duration{5 * time.Second},
 			WarnSomeAvg10: 50.0,
 			WarnFullAvg10: 10.0,
+			CPU: PSIResourceConfig{
+				Enabled:       false,
+				WarnSomeAvg10: 80.0,
+				Tier:          "T6",
+			},
+			IO: PSIResourceConfig{
+				Enabled:       true,
+				WarnSomeAvg10: 50.0,
+				WarnFullAvg10: 25.0,
+				Tier:          "T6",
+			},
+		},
+		Thrash: ThrashConfig{
+			Enabled:             true,
+			PollInterval:        Duration{5 * time.Second},
+			MajFaultRate:        250,
+			SwapRate:            2500,
+			Sustain:             Duration{30 * time.Second},
+			MemAvailableWarnPct: 5,
 		},
 		SMART: SMARTConfig{
 			Enabled:      false,
 			PollInterval: Duration{1 * time.Hour},
+			TempWarnHDD:  50,
+			TempWarnSSD:  70,
+			TempSustain:  Duration{30 * time.Minute},
+		},
+		Arrays: ArraysConfig{
+			Enabled:      true,
+			PollInterval: Duration{5 * time.Minute},
 		},
 		GPU: GPUConfig{
 			Enabled:      true,
@@ -150,14 +881,157 @@ func Default() *Config {
 			TempWarn:     85,
 			VRAMWarnPct:  90,
 		},
+		Quota: QuotaConfig{
+			Enabled:      false,
+			PollInterval: Duration{15 * time.Minute},
+			WarnPct:      90,
+		},
+		Disk: DiskConfig{
+			Enabled:      true,
+			PollInterval: Duration{5 * time.Minute},
+			WarnPct:      90,
+			CritPct:      97,
+			InodeWarnPct: 90,
+			InodeCritPct: 97,
+			TopDirs:      5,
+		},
+		Inventory: InventoryConfig{
+			Enabled:      true,
+			PollInterval: Duration{24 * time.Hour},
+		},
+		Units: UnitsConfig{
+			Enabled: false,
+			Match:   []string{"*.service"},
+		},
+		UnitLimits: UnitLimitsConfig{
+			Enabled:      false,
+			PollInterval: Duration{1 * time.Minute},
+			WarnPct:      80,
+			CritPct:      95,
+		},
+		Loop: LoopConfig{
+			Enabled:  true,
+			Failures: 5,
+			Window:   Duration{10 * time.Minute},
+		},
+		Storm: StormConfig{
+			Enabled: true,
+			Rate:    100,
+		},
+		SelfMonitor: SelfMonitorConfig{
+			Enabled: true,
+			Repeats: 5,
+			Window:  Duration{time.Hour},
+		},
+		Containers: ContainersConfig{
+			Enabled: true,
+		},
+		Kubernetes: KubernetesConfig{
+			Enabled:      true,
+			PollInterval: Duration{30 * time.Second},
+		},
+		EventLog: EventLogConfig{
+			Enabled:      true,
+			Channels:     []string{"System", "Application"},
+			PollInterval: Duration{10 * time.Second},
+		},
+		Audit: AuditConfig{
+			Enabled:      false,
+			Source:       "journal",
+			PollInterval: Duration{30 * time.Second},
+			AuthFailures: 5,
+			AuthWindow:   Duration{10 * time.Minute},
+		},
+		SSH: SSHConfig{
+			Enabled:  true,
+			Failures: 10,
+			Window:   Duration{10 * time.Minute},
+		},
+		Security: SecurityConfig{
+			Enabled:        true,
+			AuthorizedKeys: []string{"/root/.ssh/authorized_keys", "/home/*/.ssh/authorized_keys"},
+			PollInterval:   Duration{time.Minute},
+		},
+		Crashes: CrashesConfig{
+			DebuggerTimeout: Duration{30 * time.Second},
+		},
+		Services: ServicesConfig{
+			UnitInfo: true,
+		},
+		Catchall: CatchallConfig{
+			Enabled:     false,
+			MaxPriority: 2, // crit and above
+		},
+		Boot: BootConfig{
+			Enabled: true,
+		},
+		Ack: AckConfig{
+			Duration: Duration{4 * time.Hour},
+		},
+		Control: ControlConfig{
+			Enabled: true,
+		},
+		Reload: ReloadConfig{
+			Watch: true,
+		},
+		Health: HealthConfig{
+			JournalGrace: Duration{2 * time.Minute},
+		},
+		Syslog: SyslogConfig{
+			Enabled:  false,
+			Network:  "unix",
+			Address:  "/dev/log",
+			Facility: "local0",
+		},
+		OTel: OTelConfig{
+			Enabled:       false,
+			Endpoint:      "http://localhost:4318",
+			Spans:         true,
+			BatchInterval: Duration{5 * time.Second},
+		},
+		Loki: LokiConfig{
+			Enabled:       false,
+			URL:           "http://localhost:3100/loki/api/v1/push",
+			BatchInterval: Duration{5 * time.Second},
+		},
+		Heartbeat: HeartbeatConfig{
+			Interval: Duration{5 * time.Minute},
+		},
+		Capture: CaptureConfig{
+			Enabled:        false,
+			Duration:       Duration{2 * time.Minute},
+			SampleInterval: Duration{5 * time.Second},
+			Priority:       6,
+			MaxLines:       5000,
+			MinInterval:    Duration{15 * time.Minute},
+		},
+		Bundle: BundleConfig{
+			Enabled:    false,
+			Window:     Duration{10 * time.Minute},
+			MaxBundles: 20,
+			Retention:  Duration{30 * 24 * time.Hour},
+		},
+		Remediation: RemediationConfig{
+			Enabled: false,
+			Timeout: Duration{10 * time.Second},
+		},
+		Agent: AgentConfig{
+			SpoolMaxMB:    64,
+			RetryInterval: Duration{30 * time.Second},
+		},
 		DB: DBConfig{
-			Path:      "", // defaults to ~/.local/share/logtriage/events.db at runtime
-			Retention: Duration{90 * 24 * time.Hour},
+			Driver:     "sqlite",
+			Path:       "", // defaults to ~/.local/share/logtriage/events.db at runtime
+			Retention:  Duration{90 * 24 * time.Hour},
+			WriteQueue: 1024,
+			BackupKeep: 7,
 		},
 		Log: LogConfig{
 			Level: "info",
 		},
 	}
+	platformDefaults(c)
+	return c
 }
 
 // DefaultPath returns the default config file path.
@@ -171,31 +1045,171 @@ func DefaultPath() string {
 
 // Load reads configuration from the given path, falling back to defaults
 // for any unset fields. If the file does not exist, returns defaults.
+//
+// After the main file, files named by its include key are merged, then
+// every *.toml file in the drop-in directory <path>.d/, each in lexical
+// order. Later files override earlier values, except [[rules]] and
+// [[suppress.rules]], which accumulate across files.
 func Load(path string) (*Config, error) {
+	cfg, err := read(path, func(_ string, err error) error { return err })
+	if err != nil {
+		return nil, err
+	}
+	var invalid []Problem
+	for _, p := range cfg.Validate() {
+		if !p.Warning {
+			invalid = append(invalid, p)
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &ValidationError{Problems: invalid}
+	}
+	return cfg, nil
+}
+
+// read merges the config files Load reads. Errors parsing a file go to
+// fail, which may return nil to carry on with the next file.
+func read(path string, fail func(file string, err error) error) (*Config, error) {
 	cfg := Default()
 
 	if path == "" {
 		path = DefaultPath()
 	}
+	merge := func(file string) error {
+		if err := cfg.mergeFile(file); err != nil {
+			return fail(file, err)
+		}
+		return nil
+	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
+	if err := cfg.mergeFile(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if err := fail(path, err); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("reading config: %w", err)
 	}
+	includes := cfg.Include
 
-	if err := toml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	for _, pattern := range includes {
+		pattern = expandHome(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("include %q: file not found", pattern)
+		}
+		for _, m := range matches {
+			if err := merge(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dropIns, _ := filepath.Glob(filepath.Join(path+".d", "*.toml"))
+	for _, m := range dropIns {
+		if err := merge(m); err != nil {
+			return nil, err
+		}
 	}
 
+	cfg.Include = includes
 	return cfg, nil
 }
 
+// mergeFile decodes one config file over c. Rule lists, cooldown
+// overrides, and hooks are appended to rather than replaced, so each file can
+// contribute its own rules. Keys that match no setting are an error (see
+// UnknownKeysError).
+func (c *Config) mergeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	rules, suppress, overrides, hooks, include := c.Rules, c.Suppress.Rules, c.Cooldown.Overrides, c.Hooks, c.Include
+	c.Rules, c.Suppress.Rules, c.Cooldown.Overrides, c.Hooks = nil, nil, nil, nil
+
+	md, err := toml.Decode(string(data), c)
+	c.Rules = append(rules, c.Rules...)
+	c.Suppress.Rules = append(suppress, c.Suppress.Rules...)
+	c.Cooldown.Overrides = append(overrides, c.Cooldown.Overrides...)
+	c.Hooks = append(hooks, c.Hooks...)
+	if len(c.Files) > 0 {
+		// Includes are only honored in the main file.
+		c.Include = include
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	c.Files = append(c.Files, path)
+	c.recordKeys(path, data, map[string]int{
+		"rules":             len(rules),
+		"suppress.rules":    len(suppress),
+		"cooldown.override": len(overrides),
+		"hooks":             len(hooks),
+	})
+	return checkUndecoded(path, md)
+}
+
 // ShouldAlert returns true if the given tier is in the configured alert tiers.
 func (c *Config) ShouldAlert(tier string) bool {
-	for _, t := range c.Ntfy.AlertTiers {
+	return containsTier(c.Ntfy.AlertTiers, tier)
+}
+
+// SlackShouldAlert returns true if the given tier should be posted to Slack.
+// Falls back to the ntfy alert tiers when slack.alert_tiers is not set.
+func (c *Config) SlackShouldAlert(tier string) bool {
+	if len(c.Slack.AlertTiers) == 0 {
+		return c.ShouldAlert(tier)
+	}
+	return containsTier(c.Slack.AlertTiers, tier)
+}
+
+// EmailShouldAlert returns true if the given tier should be sent by email.
+// Falls back to the ntfy alert tiers when email.alert_tiers is not set.
+func (c *Config) EmailShouldAlert(tier string) bool {
+	if len(c.Email.AlertTiers) == 0 {
+		return c.ShouldAlert(tier)
+	}
+	return containsTier(c.Email.AlertTiers, tier)
+}
+
+// WebhookShouldAlert returns true if the given tier should be posted to the
+// webhook. Falls back to the ntfy alert tiers when webhook.alert_tiers is not set.
+func (c *Config) WebhookShouldAlert(tier string) bool {
+	if len(c.Webhook.AlertTiers) == 0 {
+		return c.ShouldAlert(tier)
+	}
+	return containsTier(c.Webhook.AlertTiers, tier)
+}
+
+// SyslogShouldSend returns true if events of the given tier are sent to
+// syslog.
+func (c *Config) SyslogShouldSend(tier string) bool {
+	return len(c.Syslog.Tiers) == 0 || containsTier(c.Syslog.Tiers, tier)
+}
+
+// OTelShouldSend returns true if events of the given tier are exported as
+// OpenTelemetry logs.
+func (c *Config) OTelShouldSend(tier string) bool {
+	return len(c.OTel.Tiers) == 0 || containsTier(c.OTel.Tiers, tier)
+}
+
+// LokiShouldSend returns true if events of the given tier are pushed to
+// Loki.
+func (c *Config) LokiShouldSend(tier string) bool {
+	return len(c.Loki.Tiers) == 0 || containsTier(c.Loki.Tiers, tier)
+}
+
+// containsTier reports whether tier is in tiers, case-insensitively.
+func containsTier(tiers []string, tier string) bool {
+	for _, t := range tiers {
 		if strings.EqualFold(t, tier) {
 			return true
 		}
@@ -203,29 +1217,106 @@ func (c *Config) ShouldAlert(tier string) bool {
 	return false
 }
 
+// ResolvedMounts returns the mounts to watch with zero thresholds filled in
+// from the [diskspace] defaults. With no mounts configured it watches "/".
+func (d DiskConfig) ResolvedMounts() []DiskMountConfig {
+	mounts := d.Mounts
+	if len(mounts) == 0 {
+		mounts = []DiskMountConfig{{Path: "/"}}
+	}
+	out := make([]DiskMountConfig, len(mounts))
+	for i, m := range mounts {
+		if m.WarnPct == 0 {
+			m.WarnPct = d.WarnPct
+		}
+		if m.CritPct == 0 {
+			m.CritPct = d.CritPct
+		}
+		if m.InodeWarnPct == 0 {
+			m.InodeWarnPct = d.InodeWarnPct
+		}
+		if m.InodeCritPct == 0 {
+			m.InodeCritPct = d.InodeCritPct
+		}
+		out[i] = m
+	}
+	return out
+}
+
 // DBPath returns the resolved database path. If not explicitly configured,
 // it returns the default path under the XDG data directory.
 func (c *Config) DBPath() string {
 	if c.DB.Path != "" {
-		// Expand ~ prefix.
-		if strings.HasPrefix(c.DB.Path, "~/") {
-			home, err := os.UserHomeDir()
-			if err == nil {
-				return filepath.Join(home, c.DB.Path[2:])
-			}
+		return expandHome(c.DB.Path)
+	}
+	return defaultDataPath("events.db")
+}
+
+// BackupDir returns the resolved directory for scheduled database backups.
+// If not explicitly configured, it is "backups" in the XDG data directory.
+func (c *Config) BackupDir() string {
+	if c.DB.BackupDir != "" {
+		return expandHome(c.DB.BackupDir)
+	}
+	return defaultDataPath("backups")
+}
+
+// ControlSocket returns the resolved control socket path. If not
+// explicitly configured, it is logtriage.sock in $XDG_RUNTIME_DIR, or in
+// the XDG data directory when that is unset.
+func (c *Config) ControlSocket() string {
+	if c.Control.Socket != "" {
+		return expandHome(c.Control.Socket)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "logtriage.sock")
+	}
+	return defaultDataPath("control.sock")
+}
+
+// SpoolPath returns the resolved agent spool directory. If not explicitly
+// configured, it returns the default path under the XDG data directory.
+func (c *Config) SpoolPath() string {
+	if c.Agent.SpoolDir != "" {
+		return expandHome(c.Agent.SpoolDir)
+	}
+	return defaultDataPath("spool")
+}
+
+// BundleDir returns the resolved diagnostic bundle directory. If not
+// explicitly configured, it returns the default path under the XDG data
+// directory.
+func (c *Config) BundleDir() string {
+	if c.Bundle.Dir != "" {
+		return expandHome(c.Bundle.Dir)
+	}
+	return defaultDataPath("bundles")
+}
+
+// expandHome expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, path[2:])
 		}
-		return c.DB.Path
 	}
+	return path
+}
+
+// defaultDataPath returns name under the logtriage XDG data directory.
+func defaultDataPath(name string) string {
 	dataHome := os.Getenv("XDG_DATA_HOME")
 	if dataHome == "" {
 		home, _ := os.UserHomeDir()
 		dataHome = filepath.Join(home, ".local", "share")
 	}
-	return filepath.Join(dataHome, "logtriage", "events.db")
+	return filepath.Join(dataHome, "logtriage", name)
 }
 
 // DigestTopic returns the ntfy URL to use for digest notifications.
-// Falls back to the main ntfy URL if not explicitly set.
+// Falls back to the main ntfy URL if not explicitly set. The result may be
+// a template; see ExpandTopic.
 func (c *Config) DigestTopic() string {
 	if c.Digest.Topic != "" {
 		return c.Digest.Topic
@@ -233,6 +1324,346 @@ func (c *Config) DigestTopic() string {
 	return c.Ntfy.URL
 }
 
+// TopicData is the data available to templates in ntfy.url and
+// digest.topic, e.g. "https://ntfy.sh/logs-{{.Instance}}-{{.Tier | lower}}".
+// Characters a topic cannot hold are rendered as "-".
+type TopicData struct {
+	Instance string // instance that produced the event (the hub's own for digests)
+	Role     string // this machine's instance.role
+	Tier     string // event tier such as "T1"; "digest" for digests
+	Severity string // event severity; empty for digests
+}
+
+var topicFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// topicRe matches the topic names ntfy accepts.
+var topicRe = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+// topicSafe replaces the characters ntfy does not allow in a topic, such as
+// the dots of a host name, with "-". A value from an agent can then neither
+// break the topic nor reach the rest of the URL.
+func topicSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// ExpandTopic renders a topic URL template, with its values made safe for
+// a topic (see topicSafe), and checks that the rendered topic is one ntfy
+// accepts. URLs without template actions are returned unchanged.
+func (c *Config) ExpandTopic(url string, data TopicData) (string, error) {
+	if !strings.Contains(url, "{{") {
+		return url, nil
+	}
+	tmpl, err := template.New("topic").Funcs(topicFuncs).Parse(url)
+	if err != nil {
+		return "", fmt.Errorf("topic template %q: %w", url, err)
+	}
+	if data.Role == "" {
+		data.Role = c.Instance.Role
+	}
+	data = TopicData{
+		Instance: topicSafe(data.Instance),
+		Role:     topicSafe(data.Role),
+		Tier:     topicSafe(data.Tier),
+		Severity: topicSafe(data.Severity),
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("topic template %q: %w", url, err)
+	}
+
+	out := b.String()
+	topic, _, _ := strings.Cut(out[strings.LastIndex(out, "/")+1:], "?")
+	if !topicRe.MatchString(topic) {
+		return "", fmt.Errorf("topic template %q: %q is not a valid ntfy topic (up to 64 letters, digits, \"-\" and \"_\")", url, topic)
+	}
+	return out, nil
+}
+
+// NtfyTopic returns the ntfy URL for events of a tier: its entry in
+// ntfy.tier_topics, security.topic for T9, or ntfy.url. The result may be
+// a template; see ExpandTopic.
+func (c *Config) NtfyTopic(tier string) string {
+	for t, url := range c.Ntfy.TierTopics {
+		if strings.EqualFold(t, tier) {
+			return url
+		}
+	}
+	if c.Security.Topic != "" && strings.EqualFold(tier, "T9") {
+		return c.Security.Topic
+	}
+	return c.Ntfy.URL
+}
+
+// QuietUntil reports whether an alert of the given tier and severity at t
+// is held for quiet hours, and if so, when they end. Critical alerts and
+// tiers in break_through are never held.
+func (s *ScheduleConfig) QuietUntil(tier, severity string, t time.Time) (time.Time, bool) {
+	if severity == "critical" {
+		return time.Time{}, false
+	}
+	for _, b := range s.BreakThrough {
+		if strings.EqualFold(b, tier) {
+			return time.Time{}, false
+		}
+	}
+	span := s.QuietHours
+	for k, q := range s.TierQuietHours {
+		if strings.EqualFold(k, tier) {
+			span = q
+		}
+	}
+	if span == "" {
+		return time.Time{}, false
+	}
+	start, end, err := parseClockSpan(span)
+	if err != nil {
+		return time.Time{}, false // rejected by Load
+	}
+
+	t = t.Local()
+	day := func(offset, minute int) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day()+offset, minute/60, minute%60, 0, 0, t.Location())
+	}
+	now := t.Hour()*60 + t.Minute()
+	switch {
+	case start < end && now >= start && now < end:
+		return day(0, end), true
+	case start > end && now >= start:
+		return day(1, end), true // past midnight
+	case start > end && now < end:
+		return day(0, end), true
+	}
+	return time.Time{}, false
+}
+
+// validate checks the quiet hours spans.
+func (s *ScheduleConfig) validate() error {
+	spans := map[string]string{"schedule.quiet_hours": s.QuietHours}
+	for tier, span := range s.TierQuietHours {
+		spans["schedule.tier_quiet_hours."+tier] = span
+	}
+	for key, span := range spans {
+		if span == "" {
+			continue
+		}
+		if _, _, err := parseClockSpan(span); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Scheduled reports whether the daemon sends digests itself.
+func (d *DigestConfig) Scheduled() bool {
+	return d.Enabled && d.Schedule != ""
+}
+
+// Next returns the first scheduled digest time after t, or the zero time
+// when digests are not scheduled.
+func (d *DigestConfig) Next(t time.Time) time.Time {
+	return d.step(t, 1)
+}
+
+// Prev returns the last scheduled digest time before t, or the zero time
+// when digests are not scheduled.
+func (d *DigestConfig) Prev(t time.Time) time.Time {
+	return d.step(t, -1)
+}
+
+// step finds the scheduled time nearest t in direction dir (1 or -1).
+func (d *DigestConfig) step(t time.Time, dir int) time.Time {
+	if !d.Scheduled() {
+		return time.Time{}
+	}
+	days, minute, err := parseDigestSchedule(d.Schedule)
+	if err != nil {
+		return time.Time{} // rejected by Load
+	}
+	t = t.Local()
+	for offset := 0; offset <= 7; offset++ {
+		at := time.Date(t.Year(), t.Month(), t.Day()+dir*offset, minute/60, minute%60, 0, 0, t.Location())
+		if !days[at.Weekday()] {
+			continue
+		}
+		if (dir > 0 && at.After(t)) || (dir < 0 && at.Before(t)) {
+			return at
+		}
+	}
+	return time.Time{} // unreachable: some day is always set
+}
+
+// validate checks the digest schedule and delivery channel.
+func (d *DigestConfig) validate() error {
+	if d.Via != "ntfy" && d.Via != "email" {
+		return fmt.Errorf("digest.via: %q is not ntfy or email", d.Via)
+	}
+	if d.Schedule == "" {
+		return nil
+	}
+	if _, _, err := parseDigestSchedule(d.Schedule); err != nil {
+		return fmt.Errorf("digest.schedule: %w", err)
+	}
+	return nil
+}
+
+// parseDigestSchedule parses a schedule such as "Sun 09:00", "Mon,Thu
+// 08:30", or "Mon-Fri 18:00" into the weekdays it runs on and its time of
+// day in minutes. Without days it runs every day.
+func parseDigestSchedule(s string) (days [7]bool, minute int, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return days, 0, fmt.Errorf("invalid schedule %q: want [days] HH:MM", s)
+	}
+	c, err := time.Parse("15:04", fields[len(fields)-1])
+	if err != nil {
+		return days, 0, fmt.Errorf("invalid schedule %q: want [days] HH:MM", s)
+	}
+	minute = c.Hour()*60 + c.Minute()
+
+	if len(fields) == 1 {
+		for i := range days {
+			days[i] = true
+		}
+		return days, minute, nil
+	}
+	for _, part := range strings.Split(fields[0], ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := parseWeekday(from)
+		last := first
+		if isRange {
+			var ok2 bool
+			last, ok2 = parseWeekday(to)
+			ok = ok && ok2
+		}
+		if !ok {
+			return days, 0, fmt.Errorf("invalid schedule %q: unknown day %q", s, part)
+		}
+		for wd := first; ; wd = (wd + 1) % 7 {
+			days[wd] = true
+			if wd == last {
+				break
+			}
+		}
+	}
+	return days, minute, nil
+}
+
+// parseWeekday parses a three-letter day name such as "Sun", in any case.
+func parseWeekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if strings.EqualFold(s, wd.String()[:3]) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+// Applies reports whether events of the given tier escalate.
+func (e *EscalationConfig) Applies(tier string) bool {
+	return e.Enabled && (len(e.Tiers) == 0 || containsTier(e.Tiers, tier))
+}
+
+// validate checks the escalation threshold, window, and severity.
+func (e *EscalationConfig) validate() error {
+	if !e.Enabled {
+		return nil
+	}
+	if e.Threshold < 1 {
+		return fmt.Errorf("escalation.threshold: must be at least 1, got %d", e.Threshold)
+	}
+	if e.Window.Duration <= 0 {
+		return errors.New("escalation.window: must be positive")
+	}
+	switch e.Severity {
+	case "", "critical", "high", "medium", "warning":
+	default:
+		return fmt.Errorf("escalation.severity: %q is not critical, high, medium, or warning", e.Severity)
+	}
+	return nil
+}
+
+// validateAck checks that the Ack button has a dashboard to post to.
+func (c *Config) validateAck() error {
+	if !c.Ack.Enabled {
+		return nil
+	}
+	switch {
+	case c.Ack.URL == "":
+		return errors.New("ack.url: required when ack is enabled")
+	case c.Ack.Secret == "":
+		return errors.New("ack.secret: required when ack is enabled")
+	case c.Web.Listen == "":
+		return errors.New("ack: the Ack button posts to the dashboard; set web.listen")
+	case c.Ack.Duration.Duration <= 0:
+		return errors.New("ack.duration: must be positive")
+	}
+	return nil
+}
+
+// Link returns the URL the Ack button of an event's notification posts to.
+func (a *AckConfig) Link(eventID string) string {
+	return strings.TrimRight(a.URL, "/") + "/api/v1/events/" + eventID + "/ack?sig=" + a.sign(eventID)
+}
+
+// Verify reports whether sig is the signature of an ack link for eventID.
+func (a *AckConfig) Verify(eventID, sig string) bool {
+	return a.Secret != "" && hmac.Equal([]byte(sig), []byte(a.sign(eventID)))
+}
+
+func (a *AckConfig) sign(eventID string) string {
+	mac := hmac.New(sha256.New, []byte(a.Secret))
+	mac.Write([]byte(eventID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseClockSpan parses "HH:MM-HH:MM" into minutes after midnight. The end
+// may be earlier than the start, for a span that runs past midnight.
+func parseClockSpan(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid span %q: want HH:MM-HH:MM", s)
+	}
+	var minutes [2]int
+	for i, clock := range []string{from, to} {
+		c, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid span %q: want HH:MM-HH:MM", s)
+		}
+		minutes[i] = c.Hour()*60 + c.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return 0, 0, fmt.Errorf("invalid span %q: start and end are the same", s)
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// validateTopics checks that the ntfy and digest topic templates render and
+// that ntfy has at most one kind of credentials.
+func (c *Config) validateTopics() error {
+	sample := TopicData{Instance: c.Instance.ID, Tier: "T1", Severity: "critical"}
+	urls := map[string]string{"ntfy.url": c.Ntfy.URL, "digest.topic": c.Digest.Topic, "escalation.ntfy_url": c.Escalation.NtfyURL, "security.topic": c.Security.Topic}
+	for tier, url := range c.Ntfy.TierTopics {
+		urls["ntfy.tier_topics."+tier] = url
+	}
+	for key, url := range urls {
+		if _, err := c.ExpandTopic(url, sample); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if c.Ntfy.Token != "" && (c.Ntfy.Username != "" || c.Ntfy.Password != "") {
+		return errors.New("ntfy: set either token or username and password, not both")
+	}
+	return nil
+}
+
 // NtfyPriority maps a severity string to an ntfy priority string.
 func (c *Config) NtfyPriority(severity string) string {
 	if p, ok := c.Ntfy.PriorityMap[severity]; ok {
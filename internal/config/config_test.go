@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,6 +31,9 @@ func TestDefaultConfig(t *testing.T) {
 	if len(cfg.Ntfy.AlertTiers) != 2 {
 		t.Errorf("default alert tiers count = %d, want 2", len(cfg.Ntfy.AlertTiers))
 	}
+	if cfg.Notify.BatchWindow.Duration != 0 {
+		t.Errorf("default batch window = %v, want 0 (off)", cfg.Notify.BatchWindow.Duration)
+	}
 }
 
 func TestLoadNonExistentFile(t *testing.T) {
@@ -159,3 +165,656 @@ func TestNtfyPriority(t *testing.T) {
 		t.Errorf("unknown priority = %q, want %q", p, "default")
 	}
 }
+
+func TestLoadDropIns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	dropDir := path + ".d"
+	if err := os.Mkdir(dropDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		path: `
+include = ["sinks/*.toml"]
+
+[instance]
+id = "main"
+role = "nas"
+
+[[rules]]
+name = "main-rule"
+pattern = "a"
+tier = "T4"
+`,
+		filepath.Join(dir, "sinks", "slack.toml"): `
+[slack]
+webhook_url = "https://hooks.example/x"
+`,
+		filepath.Join(dropDir, "10-gpu.toml"): `
+[gpu]
+temp_warn = 70
+
+[[rules]]
+name = "gpu-rule"
+pattern = "b"
+tier = "T4"
+`,
+		filepath.Join(dropDir, "20-override.toml"): `
+[instance]
+id = "override"
+
+[[suppress.rules]]
+name = "dock"
+message = "sdc"
+`,
+		filepath.Join(dropDir, "README"): `not toml`,
+	}
+	for p, content := range files {
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Instance.ID != "override" {
+		t.Errorf("instance.id = %q, want later drop-in to win", cfg.Instance.ID)
+	}
+	if cfg.Instance.Role != "nas" {
+		t.Errorf("instance.role = %q, keys not set in drop-ins should be kept", cfg.Instance.Role)
+	}
+	if cfg.Slack.WebhookURL == "" {
+		t.Error("included slack config not merged")
+	}
+	if cfg.GPU.TempWarn != 70 || cfg.GPU.VRAMWarnPct != 90 {
+		t.Errorf("gpu = %+v, want temp_warn from drop-in and default vram_warn_pct", cfg.GPU)
+	}
+	if len(cfg.Rules) != 2 || cfg.Rules[0].Name != "main-rule" || cfg.Rules[1].Name != "gpu-rule" {
+		t.Errorf("rules = %+v, want both files' rules in order", cfg.Rules)
+	}
+	if len(cfg.Suppress.Rules) != 1 {
+		t.Errorf("suppress rules = %+v", cfg.Suppress.Rules)
+	}
+	if len(cfg.Files) != 4 {
+		t.Errorf("Files = %v, want main, include, and two drop-ins", cfg.Files)
+	}
+}
+
+func TestLoadDropInsWithoutMainFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.Mkdir(path+".d", 0o755)
+	os.WriteFile(filepath.Join(path+".d", "a.toml"), []byte("[instance]\nid = \"dropin\"\n"), 0o644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Instance.ID != "dropin" {
+		t.Errorf("instance.id = %q", cfg.Instance.ID)
+	}
+}
+
+func TestLoadMissingInclude(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`include = ["missing.toml"]`), 0o644)
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for missing literal include")
+	}
+}
+
+func TestDiskResolvedMounts(t *testing.T) {
+	d := Default().Disk
+	if got := d.ResolvedMounts(); len(got) != 1 || got[0].Path != "/" || got[0].WarnPct != 90 {
+		t.Errorf("default mounts = %+v, want / with default thresholds", got)
+	}
+
+	d.Mounts = []DiskMountConfig{
+		{Path: "/"},
+		{Path: "/var", WarnPct: 80, InodeCritPct: 99},
+	}
+	got := d.ResolvedMounts()
+	if got[1].WarnPct != 80 || got[1].CritPct != 97 || got[1].InodeCritPct != 99 {
+		t.Errorf("/var thresholds = %+v, want overrides merged with defaults", got[1])
+	}
+	if d.Mounts[1].CritPct != 0 {
+		t.Error("ResolvedMounts modified the configured mounts")
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	content := `
+[smart]
+enable = true
+
+[gpu]
+temp_warn = 80
+
+[[rules]]
+name = "x"
+patern = "foo"
+
+[bogus]
+x = 1
+`
+	os.WriteFile(path, []byte(content), 0o644)
+
+	_, err := Load(path)
+	var uerr *UnknownKeysError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("Load error = %v, want UnknownKeysError", err)
+	}
+
+	got := make(map[string]string)
+	for _, k := range uerr.Keys {
+		got[k.Key] = k.Suggestion
+	}
+	want := map[string]string{
+		"smart.enable": "smart.enabled",
+		"rules.patern": "rules.pattern",
+		"bogus":        "",
+		"bogus.x":      "",
+	}
+	for key, sugg := range want {
+		if s, ok := got[key]; !ok || s != sugg {
+			t.Errorf("key %q: suggestion = %q (reported %v), want %q", key, s, ok, sugg)
+		}
+	}
+	if _, ok := got["gpu.temp_warn"]; ok {
+		t.Error("valid key reported as unknown")
+	}
+	if !strings.Contains(err.Error(), `"smart.enable" (did you mean "smart.enabled"?)`) {
+		t.Errorf("error message = %q", err)
+	}
+}
+
+func TestExpandTopic(t *testing.T) {
+	cfg := Default()
+	cfg.Instance.Role = "nas"
+
+	got, err := cfg.ExpandTopic("https://ntfy.sh/{{.Role}}-{{.Instance}}-{{.Tier | lower}}",
+		TopicData{Instance: "box1", Tier: "T2"})
+	if err != nil || got != "https://ntfy.sh/nas-box1-t2" {
+		t.Errorf("ExpandTopic = %q, %v", got, err)
+	}
+
+	plain := "https://ntfy.sh/plain"
+	if got, _ := cfg.ExpandTopic(plain, TopicData{}); got != plain {
+		t.Errorf("plain URL changed to %q", got)
+	}
+
+	// Host names and IDs from agents are made topic-safe.
+	for instance, want := range map[string]string{
+		"web01.example.com": "https://ntfy.sh/logs-web01-example-com-t2",
+		"../admin?x=1":      "https://ntfy.sh/logs----admin-x-1-t2",
+	} {
+		got, err := cfg.ExpandTopic("https://ntfy.sh/logs-{{.Instance}}-{{.Tier | lower}}", TopicData{Instance: instance, Tier: "T2"})
+		if err != nil || got != want {
+			t.Errorf("ExpandTopic(%q) = %q, %v, want %q", instance, got, err, want)
+		}
+	}
+	if got, err := cfg.ExpandTopic("https://ntfy.sh/{{.Instance}}", TopicData{Instance: strings.Repeat("x", 65)}); err == nil {
+		t.Errorf("ExpandTopic with a 65-character topic = %q, want error", got)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[ntfy]
+url = "https://ntfy.sh/{{.Host}}"
+`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for unknown template field")
+	}
+
+	os.WriteFile(path, []byte(`[instance]
+id = "`+strings.Repeat("x", 60)+`"
+
+[ntfy]
+url = "https://ntfy.sh/logs-{{.Instance}}"
+`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a rendered topic ntfy rejects")
+	}
+}
+
+func TestNtfyTopic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[ntfy]
+url = "https://ntfy.example.com/logs"
+token = "tk_secret"
+tier_topics = { T1 = "https://ntfy.example.com/urgent", t3 = "https://ntfy.example.com/noise-{{.Instance}}" }
+`), 0o644)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tier, want := range map[string]string{
+		"T1": "https://ntfy.example.com/urgent",
+		"T2": "https://ntfy.example.com/logs",
+		"T3": "https://ntfy.example.com/noise-{{.Instance}}",
+	} {
+		if got := cfg.NtfyTopic(tier); got != want {
+			t.Errorf("NtfyTopic(%s) = %q, want %q", tier, got, want)
+		}
+	}
+
+	os.WriteFile(path, []byte(`[ntfy]
+url = "https://ntfy.example.com/logs"
+token = "tk_secret"
+username = "alerts"
+`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for both token and username")
+	}
+}
+
+func TestSecurityConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[ntfy]
+url = "https://ntfy.example.com/logs"
+alert_tiers = ["T1", "T2", "T9"]
+
+[instance]
+role = "server"
+
+[security]
+topic = "https://ntfy.example.com/security"
+
+[security.severity]
+sudo_failure = "warning"
+
+[security.roles.server]
+root_login = "critical"
+`), 0o644)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.NtfyTopic("T9"); got != "https://ntfy.example.com/security" {
+		t.Errorf("NtfyTopic(T9) = %q", got)
+	}
+	if got := cfg.NtfyTopic("T1"); got != "https://ntfy.example.com/logs" {
+		t.Errorf("NtfyTopic(T1) = %q", got)
+	}
+	for _, tt := range []struct{ kind, role, want string }{
+		{"root_login", "server", "critical"},
+		{"root_login", "desktop", ""},
+		{"sudo_failure", "server", "warning"},
+		{"ssh_key", "server", ""},
+	} {
+		if got := cfg.Security.SeverityFor(tt.kind, tt.role); got != tt.want {
+			t.Errorf("SeverityFor(%s, %s) = %q, want %q", tt.kind, tt.role, got, tt.want)
+		}
+	}
+
+	os.WriteFile(path, []byte(`[security.roles.server]
+root_logins = "urgent"
+`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for an unknown kind and severity")
+	}
+}
+
+func TestCooldownKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[cooldown.keys]
+T4 = ["device", "gpu_card"]
+`), 0o644)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Cooldown.Keys["T4"]; len(got) != 2 || got[0] != "device" {
+		t.Errorf("cooldown.keys.T4 = %v", got)
+	}
+
+	os.WriteFile(path, []byte(`[cooldown.keys]
+T4 = []
+`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for empty key list")
+	}
+}
+
+func TestCooldownOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	dropDir := path + ".d"
+	os.Mkdir(dropDir, 0o755)
+	os.WriteFile(path, []byte(`[cooldown]
+window = "5m"
+
+[[cooldown.override]]
+name = "flaps"
+tier = "T3"
+window = "2h"
+`), 0o644)
+	os.WriteFile(filepath.Join(dropDir, "oom.toml"), []byte(`[[cooldown.override]]
+name = "oom"
+tier = "T1"
+window = "30s"
+aggregate_threshold = 10
+`), 0o644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Cooldown.Overrides; len(got) != 2 || got[0].Name != "flaps" || got[1].Window.Duration != 30*time.Second {
+		t.Errorf("overrides = %+v, want both files' in order", got)
+	}
+
+	os.WriteFile(filepath.Join(dropDir, "oom.toml"), []byte(`[[cooldown.override]]
+window = "-1m"
+`), 0o644)
+	_, err = Load(path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want ValidationError", err)
+	}
+	var keys []string
+	for _, p := range verr.Problems {
+		if !p.Warning {
+			keys = append(keys, p.Key)
+		}
+	}
+	if want := []string{"cooldown.override[1]", "cooldown.override[1].window"}; !slices.Equal(keys, want) {
+		t.Errorf("problems = %v, want %v", keys, want)
+	}
+	if p := verr.Problems[0]; p.File != filepath.Join(dropDir, "oom.toml") || p.Line != 1 {
+		t.Errorf("problem located at %s:%d, want the drop-in's line 1", p.File, p.Line)
+	}
+}
+
+func TestEscalationConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[escalation]
+enabled = true
+threshold = 20
+tiers = ["t3"]
+`), 0o644)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Escalation.Threshold != 20 || cfg.Escalation.Window.Duration != time.Hour {
+		t.Errorf("escalation = %+v", cfg.Escalation)
+	}
+	if !cfg.Escalation.Applies("T3") || cfg.Escalation.Applies("T4") {
+		t.Error("escalation should apply to T3 only")
+	}
+
+	for _, bad := range []string{"threshold = 0", `severity = "urgent"`, `ntfy_url = "https://ntfy.sh/{{.Bogus}}"`} {
+		os.WriteFile(path, []byte("[escalation]\nenabled = true\n"+bad+"\n"), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestAckConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[web]
+listen = ":9247"
+
+[ack]
+enabled = true
+url = "https://host.example.net:9247/"
+secret = "s3cret"
+`), 0o644)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Ack.Duration.Duration != 4*time.Hour {
+		t.Errorf("ack.duration = %v, want the 4h default", cfg.Ack.Duration)
+	}
+	link := cfg.Ack.Link("e1")
+	prefix := "https://host.example.net:9247/api/v1/events/e1/ack?sig="
+	if !strings.HasPrefix(link, prefix) {
+		t.Fatalf("link = %q", link)
+	}
+	sig := strings.TrimPrefix(link, prefix)
+	if !cfg.Ack.Verify("e1", sig) || cfg.Ack.Verify("e2", sig) || cfg.Ack.Verify("e1", "") {
+		t.Error("signature should verify for e1 only")
+	}
+
+	os.WriteFile(path, []byte("[ack]\nenabled = true\nurl = \"https://h\"\nsecret = \"x\"\n"), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for ack without web.listen")
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	s := ScheduleConfig{
+		QuietHours:     "23:00-07:00",
+		TierQuietHours: map[string]string{"t6": "09:00-17:00", "T3": ""},
+		BreakThrough:   []string{"T1"},
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		tier, severity string
+		t              time.Time
+		until          time.Time // zero if not held
+	}{
+		{"T2", "high", at(10, 23, 30), at(11, 7, 0)},
+		{"T2", "high", at(11, 6, 59), at(11, 7, 0)},
+		{"T2", "high", at(11, 7, 0), time.Time{}},
+		{"T2", "high", at(10, 22, 59), time.Time{}},
+		{"T2", "critical", at(10, 23, 30), time.Time{}},
+		{"T1", "high", at(10, 23, 30), time.Time{}},
+		{"T3", "medium", at(10, 23, 30), time.Time{}},
+		{"T6", "warning", at(10, 23, 30), time.Time{}},
+		{"T6", "warning", at(10, 12, 0), at(10, 17, 0)},
+	}
+	for _, tt := range tests {
+		until, held := s.QuietUntil(tt.tier, tt.severity, tt.t)
+		if held != !tt.until.IsZero() || !until.Equal(tt.until) {
+			t.Errorf("QuietUntil(%s, %s, %s) = %v, %v; want %v", tt.tier, tt.severity,
+				tt.t.Format("15:04"), until, held, tt.until)
+		}
+	}
+
+	for _, span := range []string{"23:00", "25:00-07:00", "07:00-07:00"} {
+		if _, _, err := parseClockSpan(span); err == nil {
+			t.Errorf("parseClockSpan(%q) succeeded", span)
+		}
+	}
+}
+
+func TestIsKnownCrashy(t *testing.T) {
+	c := CrashesConfig{KnownCrashy: []string{"firefox-beta", "chrome-*"}}
+	for _, p := range []string{"firefox-beta", "chrome-unstable"} {
+		if !c.IsKnownCrashy(p) {
+			t.Errorf("IsKnownCrashy(%q) = false", p)
+		}
+	}
+	for _, p := range []string{"firefox", "", "chromium"} {
+		if c.IsKnownCrashy(p) {
+			t.Errorf("IsKnownCrashy(%q) = true", p)
+		}
+	}
+}
+
+func TestDigestSchedule(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local) // Mar 1 2026 is a Sunday
+	}
+	tests := []struct {
+		schedule   string
+		t          time.Time
+		next, prev time.Time
+	}{
+		{"Sun 09:00", at(4, 12, 0), at(8, 9, 0), at(1, 9, 0)},
+		{"Sun 09:00", at(8, 9, 0), at(15, 9, 0), at(1, 9, 0)},
+		{"sun 09:00", at(8, 8, 59), at(8, 9, 0), at(1, 9, 0)},
+		{"Mon,Thu 08:30", at(3, 8, 0), at(5, 8, 30), at(2, 8, 30)},
+		{"Mon-Fri 18:00", at(6, 19, 0), at(9, 18, 0), at(6, 18, 0)},
+		{"Fri-Mon 18:00", at(3, 12, 0), at(6, 18, 0), at(2, 18, 0)},
+		{"07:00", at(4, 12, 0), at(5, 7, 0), at(4, 7, 0)},
+	}
+	for _, tt := range tests {
+		d := DigestConfig{Enabled: true, Schedule: tt.schedule, Via: "ntfy"}
+		if err := d.validate(); err != nil {
+			t.Errorf("validate(%q): %v", tt.schedule, err)
+			continue
+		}
+		if next := d.Next(tt.t); !next.Equal(tt.next) {
+			t.Errorf("%q: Next(%s) = %s, want %s", tt.schedule, tt.t, next, tt.next)
+		}
+		if prev := d.Prev(tt.t); !prev.Equal(tt.prev) {
+			t.Errorf("%q: Prev(%s) = %s, want %s", tt.schedule, tt.t, prev, tt.prev)
+		}
+	}
+
+	if d := (DigestConfig{Schedule: "Sun 09:00", Via: "ntfy"}); !d.Next(at(4, 12, 0)).IsZero() {
+		t.Error("a disabled digest should not be scheduled")
+	}
+	for _, bad := range []DigestConfig{
+		{Schedule: "Sunday 09:00", Via: "ntfy"},
+		{Schedule: "Sun 9am", Via: "ntfy"},
+		{Schedule: "Sun Mon 09:00", Via: "ntfy"},
+		{Schedule: "Sun 09:00", Via: "slack"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[ntfy]
+url = "ntfy.sh/alerts"
+
+[diskspace]
+warn_pct = 95
+crit_pct = 90
+
+[[rules]]
+name = "ok"
+pattern = "fine"
+tier = "T2"
+
+[[rules]]
+name = "broken"
+pattern = "unclosed ("
+
+[[gpu.cards]]
+card = ""
+vram_warn_pct = 120
+
+[psi.io]
+tier = "io"
+
+[[remediation.actions]]
+unit = "unclosed ("
+
+[[hooks]]
+name = "pager"
+min_severity = "urgent"
+
+[db]
+driver = "postgres"
+
+[otel]
+enabled = true
+endpoint = "localhost:4318"
+
+[loki]
+enabled = true
+password = "hunter2"
+
+[remediation]
+timeout = "1m"
+
+[email]
+tls = "none"
+username = "alerts"
+`), 0o644)
+
+	_, err := Load(path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load error = %v, want ValidationError", err)
+	}
+
+	got := make(map[string]Problem)
+	for _, p := range verr.Problems {
+		got[p.Key] = p
+	}
+	for key, line := range map[string]int{"ntfy.url": 2, "diskspace.warn_pct": 5, "rules[1].pattern": 15, "gpu.cards[0].card": 18, "gpu.cards[0].vram_warn_pct": 19, "psi.io.tier": 22, "remediation.actions[0].unit": 25, "hooks[0].command": 27, "hooks[0].min_severity": 29, "db.dsn": 31, "otel.endpoint": 36, "loki.password": 40, "remediation.timeout": 43, "email.tls": 46} {
+		p, ok := got[key]
+		if !ok {
+			t.Errorf("no problem reported for %s; got %v", key, verr.Problems)
+			continue
+		}
+		if p.File != path || p.Line != line {
+			t.Errorf("%s located at %s:%d, want line %d", key, p.File, p.Line, line)
+		}
+	}
+	if _, ok := got["rules[0].pattern"]; ok {
+		t.Error("valid rule reported")
+	}
+
+	if problems := Default().Validate(); len(problems) != 1 || !problems[0].Warning {
+		t.Errorf("default config problems = %v, want one warning (no sink)", problems)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`[smart]
+enable = true
+
+[diskspace]
+warn_pct = 120
+`), 0o644)
+
+	cfg, problems, err := Check(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		t.Fatal("Check returned no config")
+	}
+	var unknown, pct bool
+	for _, p := range problems {
+		switch p.Key {
+		case "smart.enable":
+			unknown = p.Line == 2 && strings.Contains(p.Message, `"smart.enabled"`)
+		case "diskspace.warn_pct":
+			pct = p.Line == 5 && !p.Warning
+		}
+	}
+	if !unknown || !pct {
+		t.Errorf("problems = %v, want unknown smart.enable on line 2 and diskspace.warn_pct on line 5", problems)
+	}
+
+	os.WriteFile(path, []byte("[ntfy]\nurl = \"https://ntfy.sh\n"), 0o644)
+	_, problems, err = Check(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) == 0 || problems[0].Line != 2 {
+		t.Errorf("syntax error problems = %v, want one on line 2", problems)
+	}
+}
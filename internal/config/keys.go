@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// UnknownKeysError reports config keys that do not correspond to any
+// setting. They are almost always typos, which would otherwise leave the
+// intended setting at its default without any sign of it.
+type UnknownKeysError struct {
+	Path string
+	Keys []UnknownKey
+}
+
+// UnknownKey is an unrecognized key and the closest valid key, if any is
+// close enough to be a likely typo.
+type UnknownKey struct {
+	Key        string
+	Suggestion string
+}
+
+func (e *UnknownKeysError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: unknown config key", e.Path)
+	if len(e.Keys) > 1 {
+		b.WriteString("s")
+	}
+	for i, k := range e.Keys {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", k.Key)
+		if k.Suggestion != "" {
+			fmt.Fprintf(&b, " (did you mean %q?)", k.Suggestion)
+		}
+	}
+	return b.String()
+}
+
+// checkUndecoded returns an UnknownKeysError for keys in md that were not
+// decoded into the config, or nil if every key was used.
+func checkUndecoded(path string, md toml.MetaData) error {
+	undecoded := md.Undecoded()
+	if len(undecoded) == 0 {
+		return nil
+	}
+
+	valid := validKeys()
+	err := &UnknownKeysError{Path: path}
+	for _, key := range undecoded {
+		name := key.String()
+		err.Keys = append(err.Keys, UnknownKey{Key: name, Suggestion: closestKey(name, valid)})
+	}
+	return err
+}
+
+// validKeys lists every dotted key the Config struct accepts. Arrays of
+// tables contribute their element keys under the array's name, matching
+// how toml.Key paths are reported.
+func validKeys() []string {
+	var keys []string
+	collectKeys(reflect.TypeOf(Config{}), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key := prefix + name
+		*keys = append(*keys, key)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !reflect.PointerTo(ft).Implements(textUnmarshalerType) {
+			collectKeys(ft, key+".", keys)
+		}
+	}
+}
+
+// closestKey returns the valid key nearest to key by edit distance, or ""
+// if none is near enough to be a plausible typo. A key placed in the wrong
+// table (same last component) is matched too.
+func closestKey(key string, valid []string) string {
+	best, bestDist := "", -1
+	for _, v := range valid {
+		d := editDistance(key, v)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = v, d
+		}
+	}
+	if bestDist >= 0 && bestDist <= max(2, len(key)/4) {
+		return best
+	}
+
+	// A correctly spelled key in the wrong table, e.g. "temp_warn" under
+	// [smart], is only suggested when one table has it.
+	last := key[strings.LastIndex(key, ".")+1:]
+	var match string
+	for _, v := range valid {
+		if v == last || strings.HasSuffix(v, "."+last) {
+			if match != "" {
+				return ""
+			}
+			match = v
+		}
+	}
+	return match
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package config
+
+// platformDefaults adjusts the defaults to the platform. Every section is
+// available on Linux.
+func platformDefaults(*Config) {}
+
+// checkPlatform reports sections unavailable on the platform.
+func (v *validator) checkPlatform() {}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package config
+
+import (
+	"maps"
+	"slices"
+)
+
+// linuxOnly returns the enabled flags of the sections that read Linux
+// interfaces: /proc, sysfs, D-Bus, the journal, and Linux tools.
+func linuxOnly(c *Config) map[string]*bool {
+	return map[string]*bool{
+		"psi":          &c.PSI.Enabled,
+		"thrash":       &c.Thrash.Enabled,
+		"ebpf":         &c.EBPF.Enabled,
+		"smart":        &c.SMART.Enabled,
+		"arrays":       &c.Arrays.Enabled,
+		"gpu":          &c.GPU.Enabled,
+		"quota":        &c.Quota.Enabled,
+		"inventory":    &c.Inventory.Enabled,
+		"units":        &c.Units.Enabled,
+		"unit_limits":  &c.UnitLimits.Enabled,
+		"containers":   &c.Containers.Enabled,
+		"user_journal": &c.UserJournal.Enabled,
+		"kubernetes":   &c.Kubernetes.Enabled,
+		"audit":        &c.Audit.Enabled,
+		"ssh":          &c.SSH.Enabled,
+		"security":     &c.Security.Enabled,
+		"capture":      &c.Capture.Enabled,
+		"bundle":       &c.Bundle.Enabled,
+		"boot":         &c.Boot.Enabled,
+		"remediation":  &c.Remediation.Enabled,
+	}
+}
+
+// platformDefaults turns the Linux-only sections off, so the daemon runs in
+// a reduced mode on Windows: the Event Log source, disk space monitoring,
+// and everything built on events.
+func platformDefaults(c *Config) {
+	for _, enabled := range linuxOnly(c) {
+		*enabled = false
+	}
+}
+
+// checkPlatform warns about Linux-only sections enabled on Windows.
+func (v *validator) checkPlatform() {
+	sections := linuxOnly(v.c)
+	for _, key := range slices.Sorted(maps.Keys(sections)) {
+		if *sections[key] {
+			v.warnf(key+".enabled", "%s is not available on Windows and does nothing", key)
+		}
+	}
+}
@@ -0,0 +1,863 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tierRe matches tier identifiers, built-in or assigned by user rules, as
+// tier lists compare them: in any case.
+var tierRe = regexp.MustCompile(`^[Tt]\d+$`)
+
+// ruleTierRe matches the tier identifiers a rule may assign.
+var ruleTierRe = regexp.MustCompile(`^T\d+$`)
+
+// tomlKeyRe matches the key part of a key = value line.
+var tomlKeyRe = regexp.MustCompile(`^\s*[\w\-."' ]+$`)
+
+// summaryGroupRe matches ${name} references in a rule's summary template.
+var summaryGroupRe = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Problem is a mistake in the config found by Validate or Check. A warning
+// is a setting that works but is unlikely to be what was meant.
+type Problem struct {
+	Key     string // dotted key, e.g. "diskspace.warn_pct" or "rules[1].pattern"
+	Message string
+	Warning bool
+	File    string // file the key is set in; empty for a default value
+	Line    int    // line of the key in File; 0 if unknown
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(p.File)
+		if p.Line > 0 {
+			fmt.Fprintf(&b, ":%d", p.Line)
+		}
+		b.WriteString(": ")
+	}
+	if p.Warning {
+		b.WriteString("warning: ")
+	}
+	if p.Key != "" {
+		b.WriteString(p.Key + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// ValidationError reports the problems that make a config invalid.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// keySource is where a key was set.
+type keySource struct {
+	file string
+	line int
+}
+
+// Validate checks the settings decoding alone cannot: threshold sanity,
+// duration ranges, URL and address syntax, tier names, and rule regexes.
+// It returns every problem found, located in the file that set the key.
+// Load rejects a config with any problem that is not a warning.
+func (c *Config) Validate() []Problem {
+	v := &validator{c: c}
+	v.checkLegacy()
+	v.checkSinks()
+	v.checkTiers()
+	v.checkThresholds()
+	v.checkDurations()
+	v.checkRules()
+	v.checkSuppress()
+	v.checkSecurity()
+	v.checkCooldown()
+	v.checkRemediation()
+	v.checkHooks()
+	v.checkPlatform()
+	for i := range v.problems {
+		c.locate(&v.problems[i])
+	}
+	return v.problems
+}
+
+// validator collects the problems Validate finds.
+type validator struct {
+	c        *Config
+	problems []Problem
+}
+
+func (v *validator) errorf(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// add records an error reported as "key: message" by one of the section
+// validators.
+func (v *validator) add(err error) {
+	if err == nil {
+		return
+	}
+	key, msg, ok := strings.Cut(err.Error(), ": ")
+	if !ok || strings.ContainsAny(key, " \"") {
+		key, msg = "", err.Error()
+	}
+	v.problems = append(v.problems, Problem{Key: key, Message: msg})
+}
+
+// checkLegacy runs the section validators Load has always applied.
+func (v *validator) checkLegacy() {
+	c := v.c
+	v.add(c.validateTopics())
+	v.add(c.Schedule.validate())
+	v.add(c.Digest.validate())
+	v.add(c.Escalation.validate())
+	v.add(c.validateAck())
+	for _, tier := range sortedKeys(c.Cooldown.Keys) {
+		if fields := c.Cooldown.Keys[tier]; len(fields) == 0 || slices.Contains(fields, "") {
+			v.errorf("cooldown.keys."+tier, "list one or more field names")
+		}
+	}
+}
+
+// checkSinks checks sink URLs, listen addresses, and enumerated settings.
+func (v *validator) checkSinks() {
+	c := v.c
+	sample := TopicData{Instance: c.Instance.ID, Tier: "T1", Severity: "critical"}
+	urls := map[string]string{
+		"ntfy.url":             c.Ntfy.URL,
+		"ntfy.icon":            c.Ntfy.Icon,
+		"digest.topic":         c.Digest.Topic,
+		"escalation.ntfy_url":  c.Escalation.NtfyURL,
+		"slack.webhook_url":    c.Slack.WebhookURL,
+		"webhook.url":          c.Webhook.URL,
+		"agent.hub_url":        c.Agent.HubURL,
+		"ack.url":              c.Ack.URL,
+		"security.topic":       c.Security.Topic,
+		"heartbeat.url":        c.Heartbeat.URL,
+		"heartbeat.digest_url": c.Heartbeat.DigestURL,
+	}
+	if c.OTel.Enabled {
+		urls["otel.endpoint"] = c.OTel.Endpoint
+	}
+	if c.Loki.Enabled {
+		urls["loki.url"] = c.Loki.URL
+		if c.Loki.URL == "" {
+			v.errorf("loki.url", "required when loki is enabled")
+		}
+	}
+	for tier, u := range c.Ntfy.TierTopics {
+		urls["ntfy.tier_topics."+tier] = u
+	}
+	for _, key := range sortedKeys(urls) {
+		raw := urls[key]
+		if raw == "" {
+			continue
+		}
+		// Templates were checked by validateTopics; check what they render.
+		expanded, err := c.ExpandTopic(raw, sample)
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(expanded)
+		switch {
+		case err != nil:
+			v.errorf(key, "invalid URL %q", raw)
+		case u.Scheme != "http" && u.Scheme != "https":
+			v.errorf(key, "%q must be an http or https URL", raw)
+		case u.Host == "":
+			v.errorf(key, "%q has no host", raw)
+		}
+	}
+
+	addrs := map[string]string{"web.listen": c.Web.Listen, "hub.listen": c.Hub.Listen, "health.listen": c.Health.Listen}
+	for _, key := range sortedKeys(addrs) {
+		addr := addrs[key]
+		if addr == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+			v.errorf(key, "%q is not a host:port address, e.g. \"127.0.0.1:9247\" or \":9247\"", addr)
+		}
+	}
+
+	oneOf := func(key, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			v.errorf(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+	oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	oneOf("db.driver", c.DB.Driver, "sqlite", "postgres")
+	oneOf("email.tls", c.Email.TLS, "starttls", "tls", "none")
+	if c.Syslog.Enabled {
+		oneOf("syslog.network", c.Syslog.Network, "unix", "udp", "tcp")
+	}
+	if c.Audit.Enabled {
+		oneOf("audit.source", c.Audit.Source, "journal", "ausearch")
+	}
+	for i, k := range c.Webhook.Transitions {
+		oneOf(fmt.Sprintf("webhook.transitions[%d]", i), k, "created", "aggregated", "escalated", "acked", "resolved")
+	}
+	for i, t := range c.Web.Tokens {
+		key := fmt.Sprintf("web.tokens[%d]", i)
+		oneOf(key+".role", t.Role, "read", "ack", "admin")
+		if t.Token == "" {
+			v.errorf(key+".token", "required")
+		}
+	}
+
+	if c.Loki.Password != "" && c.Loki.Username == "" {
+		v.errorf("loki.password", "set loki.username too")
+	}
+	// Go's SMTP client refuses to send credentials over a plain connection,
+	// so this would otherwise only fail once an alert is sent.
+	if c.Email.TLS == "none" && c.Email.Username != "" {
+		v.errorf("email.tls", "\"none\" sends email.username and email.password in the clear; use \"starttls\" or \"tls\"")
+	}
+
+	if c.Email.Host != "" && len(c.Email.To) == 0 {
+		v.warnf("email.to", "no recipients, so email is not sent")
+	}
+	if c.Security.Topic != "" && !c.ShouldAlert("T9") {
+		v.warnf("security.topic", "T9 is not in ntfy.alert_tiers, so nothing is sent to it")
+	}
+	if c.Ntfy.URL == "" && len(c.Ntfy.TierTopics) == 0 && c.Security.Topic == "" && c.Slack.WebhookURL == "" &&
+		(c.Email.Host == "" || len(c.Email.To) == 0) && c.Webhook.URL == "" && c.Agent.HubURL == "" {
+		v.warnf("ntfy.url", "no notification sink is configured; events are only stored")
+	}
+}
+
+// checkTiers checks that tier lists and tier-keyed tables name tiers.
+func (v *validator) checkTiers() {
+	c := v.c
+	lists := map[string][]string{
+		"ntfy.alert_tiers":          c.Ntfy.AlertTiers,
+		"slack.alert_tiers":         c.Slack.AlertTiers,
+		"email.alert_tiers":         c.Email.AlertTiers,
+		"webhook.alert_tiers":       c.Webhook.AlertTiers,
+		"syslog.tiers":              c.Syslog.Tiers,
+		"otel.tiers":                c.OTel.Tiers,
+		"loki.tiers":                c.Loki.Tiers,
+		"escalation.tiers":          c.Escalation.Tiers,
+		"schedule.break_through":    c.Schedule.BreakThrough,
+		"ntfy.tier_topics":          sortedKeys(c.Ntfy.TierTopics),
+		"schedule.tier_quiet_hours": sortedKeys(c.Schedule.TierQuietHours),
+		"cooldown.keys":             sortedKeys(c.Cooldown.Keys),
+	}
+	for _, key := range sortedKeys(lists) {
+		for _, tier := range lists[key] {
+			if !tierRe.MatchString(tier) {
+				v.errorf(key, "%q is not a tier such as T1", tier)
+			}
+		}
+	}
+}
+
+// checkThresholds checks percentages, temperatures, and counts.
+func (v *validator) checkThresholds() {
+	c := v.c
+	percent := func(key string, pct float64, zeroOK bool) {
+		if pct < 0 || pct > 100 || (pct == 0 && !zeroOK) {
+			v.errorf(key, "%g is not a percentage between 0 and 100", pct)
+		}
+	}
+	below := func(warnKey, critKey string, warn, crit float64) {
+		if warn > crit {
+			v.errorf(warnKey, "%g is above %s (%g), so the warning would never fire first", warn, critKey, crit)
+		}
+	}
+
+	d := c.Disk
+	percent("diskspace.warn_pct", d.WarnPct, false)
+	percent("diskspace.crit_pct", d.CritPct, false)
+	percent("diskspace.inode_warn_pct", d.InodeWarnPct, false)
+	percent("diskspace.inode_crit_pct", d.InodeCritPct, false)
+	for i, m := range d.Mounts {
+		key := fmt.Sprintf("diskspace.mounts[%d]", i)
+		if m.Path == "" {
+			v.errorf(key+".path", "required")
+		}
+		percent(key+".warn_pct", m.WarnPct, true)
+		percent(key+".crit_pct", m.CritPct, true)
+		percent(key+".inode_warn_pct", m.InodeWarnPct, true)
+		percent(key+".inode_crit_pct", m.InodeCritPct, true)
+	}
+	for i, m := range d.ResolvedMounts() {
+		prefix := "diskspace."
+		if len(d.Mounts) > 0 {
+			prefix = fmt.Sprintf("diskspace.mounts[%d].", i)
+		}
+		below(prefix+"warn_pct", "crit_pct", m.WarnPct, m.CritPct)
+		below(prefix+"inode_warn_pct", "inode_crit_pct", m.InodeWarnPct, m.InodeCritPct)
+	}
+
+	percent("unit_limits.warn_pct", c.UnitLimits.WarnPct, false)
+	percent("unit_limits.crit_pct", c.UnitLimits.CritPct, false)
+	below("unit_limits.warn_pct", "crit_pct", c.UnitLimits.WarnPct, c.UnitLimits.CritPct)
+	percent("quota.warn_pct", c.Quota.WarnPct, false)
+	percent("gpu.vram_warn_pct", float64(c.GPU.VRAMWarnPct), true)
+	percent("thrash.mem_available_warn_pct", c.Thrash.MemAvailableWarnPct, true)
+	if c.Thrash.MemAvailableWarnPct > 50 {
+		v.warnf("thrash.mem_available_warn_pct", "%g%% is more than half of memory, so it will alert constantly", c.Thrash.MemAvailableWarnPct)
+	}
+	percent("psi.warn_some_avg10", c.PSI.WarnSomeAvg10, false)
+	percent("psi.warn_full_avg10", c.PSI.WarnFullAvg10, false)
+	for _, res := range []struct {
+		key string
+		cfg PSIResourceConfig
+	}{{"psi.cpu", c.PSI.CPU}, {"psi.io", c.PSI.IO}} {
+		key, r := res.key, res.cfg
+		percent(key+".warn_some_avg10", r.WarnSomeAvg10, true)
+		percent(key+".warn_full_avg10", r.WarnFullAvg10, true)
+		if !ruleTierRe.MatchString(r.Tier) {
+			v.errorf(key+".tier", "%q must look like T1..Tn", r.Tier)
+		}
+		if r.Enabled && r.WarnSomeAvg10 == 0 && r.WarnFullAvg10 == 0 {
+			v.warnf(key+".enabled", "both thresholds are 0, so it will never alert")
+		}
+	}
+
+	temp := func(key string, celsius int) {
+		switch {
+		case celsius < 0 || celsius > 150:
+			v.errorf(key, "%d°C is not a plausible temperature limit", celsius)
+		case celsius > 0 && celsius < 30:
+			v.warnf(key, "%d°C is below normal operating temperature, so it will alert constantly", celsius)
+		}
+	}
+	temp("smart.temp_warn_hdd", c.SMART.TempWarnHDD)
+	temp("smart.temp_warn_ssd", c.SMART.TempWarnSSD)
+	for i, dev := range c.SMART.Devices {
+		temp(fmt.Sprintf("smart.devices[%d].temp_warn", i), dev.TempWarn)
+	}
+	temp("gpu.temp_warn", c.GPU.TempWarn)
+	for i, card := range c.GPU.Cards {
+		key := fmt.Sprintf("gpu.cards[%d]", i)
+		if strings.TrimSpace(card.Card) == "" {
+			v.errorf(key+".card", "is empty; set a card index such as \"1\" or a PCI address such as \"0000:03:00.0\"")
+		}
+		temp(key+".temp_warn", card.TempWarn)
+		percent(key+".vram_warn_pct", float64(card.VRAMWarnPct), true)
+	}
+
+	priority := func(key string, p int) {
+		if p < 0 || p > 7 {
+			v.errorf(key, "%d is not a syslog priority from 0 (emerg) to 7 (debug)", p)
+		}
+	}
+	priority("catchall.max_priority", c.Catchall.MaxPriority)
+	priority("capture.priority", c.Capture.Priority)
+
+	counts := map[string]int{
+		"cooldown.aggregate_threshold": c.Cooldown.AggregateThreshold,
+		"diskspace.top_dirs":           c.Disk.TopDirs,
+		"capture.max_lines":            c.Capture.MaxLines,
+		"bundle.max_bundles":           c.Bundle.MaxBundles,
+		"agent.spool_max_mb":           c.Agent.SpoolMaxMB,
+		"db.write_queue":               c.DB.WriteQueue,
+		"db.backup_keep":               c.DB.BackupKeep,
+	}
+	for _, key := range sortedKeys(counts) {
+		if n := counts[key]; n < 0 {
+			v.errorf(key, "must not be negative, got %d", n)
+		}
+	}
+	if c.Loop.Enabled && c.Loop.Failures < 2 {
+		v.errorf("restart_loop.failures", "must be at least 2, got %d", c.Loop.Failures)
+	}
+	if c.SelfMonitor.Enabled && c.SelfMonitor.Repeats < 1 {
+		v.errorf("self_monitor.repeats", "must be at least 1, got %d", c.SelfMonitor.Repeats)
+	}
+	if c.Storm.Enabled && c.Storm.Rate < 1 {
+		v.errorf("storm.rate", "must be at least 1 event per minute, got %d", c.Storm.Rate)
+	}
+	if c.SSH.Enabled && c.SSH.Failures < 1 {
+		v.errorf("ssh.failures", "must be at least 1, got %d", c.SSH.Failures)
+	}
+	if c.Audit.Enabled && c.Audit.AuthFailures < 0 {
+		v.errorf("audit.auth_failures", "must not be negative, got %d", c.Audit.AuthFailures)
+	}
+	if c.Thrash.Enabled && c.Thrash.MajFaultRate <= 0 {
+		v.errorf("thrash.majfault_rate", "must be positive, got %g", c.Thrash.MajFaultRate)
+	}
+	if c.Thrash.Enabled && c.Thrash.SwapRate < 0 {
+		v.errorf("thrash.swap_rate", "must not be negative, got %g", c.Thrash.SwapRate)
+	}
+}
+
+// checkDurations checks that intervals are positive where they must be and
+// warns about ones too short or long to be intended.
+func (v *validator) checkDurations() {
+	c := v.c
+	polls := []struct {
+		key     string
+		enabled bool
+		d       time.Duration
+		min     time.Duration
+	}{
+		{"psi.poll_interval", c.PSI.Enabled, c.PSI.PollInterval.Duration, time.Second},
+		{"thrash.poll_interval", c.Thrash.Enabled, c.Thrash.PollInterval.Duration, time.Second},
+		{"smart.poll_interval", c.SMART.Enabled, c.SMART.PollInterval.Duration, 5 * time.Minute},
+		{"arrays.poll_interval", c.Arrays.Enabled, c.Arrays.PollInterval.Duration, time.Minute},
+		{"kubernetes.poll_interval", c.Kubernetes.Enabled, c.Kubernetes.PollInterval.Duration, 5 * time.Second},
+		{"eventlog.poll_interval", c.EventLog.Enabled, c.EventLog.PollInterval.Duration, time.Second},
+		{"security.poll_interval", c.Security.Enabled && len(c.Security.AuthorizedKeys) > 0, c.Security.PollInterval.Duration, 5 * time.Second},
+		{"audit.poll_interval", c.Audit.Enabled && c.Audit.Source == "ausearch", c.Audit.PollInterval.Duration, 5 * time.Second},
+		{"gpu.poll_interval", c.GPU.Enabled, c.GPU.PollInterval.Duration, time.Second},
+		{"quota.poll_interval", c.Quota.Enabled, c.Quota.PollInterval.Duration, time.Minute},
+		{"diskspace.poll_interval", c.Disk.Enabled, c.Disk.PollInterval.Duration, 10 * time.Second},
+		{"inventory.poll_interval", c.Inventory.Enabled, c.Inventory.PollInterval.Duration, time.Minute},
+		{"unit_limits.poll_interval", c.UnitLimits.Enabled, c.UnitLimits.PollInterval.Duration, time.Second},
+		{"capture.sample_interval", c.Capture.Enabled, c.Capture.SampleInterval.Duration, time.Second},
+	}
+	for _, p := range polls {
+		switch {
+		case !p.enabled:
+		case p.d <= 0:
+			v.errorf(p.key, "must be positive")
+		case p.d < p.min:
+			v.warnf(p.key, "%s polls more often than every %s, which costs more than it is likely to catch", p.d, p.min)
+		}
+	}
+
+	positive := map[string]time.Duration{"cooldown.window": c.Cooldown.Window.Duration}
+	if c.Loop.Enabled {
+		positive["restart_loop.window"] = c.Loop.Window.Duration
+	}
+	if c.SelfMonitor.Enabled {
+		positive["self_monitor.window"] = c.SelfMonitor.Window.Duration
+	}
+	if c.Capture.Enabled {
+		positive["capture.duration"] = c.Capture.Duration.Duration
+	}
+	if c.Crashes.Debugger {
+		positive["crashes.debugger_timeout"] = c.Crashes.DebuggerTimeout.Duration
+	}
+	if c.Bundle.Enabled {
+		positive["bundle.window"] = c.Bundle.Window.Duration
+	}
+	if c.SSH.Enabled {
+		positive["ssh.window"] = c.SSH.Window.Duration
+	}
+	if c.Audit.Enabled && c.Audit.AuthFailures > 0 {
+		positive["audit.auth_window"] = c.Audit.AuthWindow.Duration
+	}
+	if c.Agent.HubURL != "" {
+		positive["agent.retry_interval"] = c.Agent.RetryInterval.Duration
+	}
+	if c.OTel.Enabled {
+		positive["otel.batch_interval"] = c.OTel.BatchInterval.Duration
+	}
+	if c.Heartbeat.URL != "" {
+		positive["heartbeat.interval"] = c.Heartbeat.Interval.Duration
+	}
+	if c.Loki.Enabled {
+		positive["loki.batch_interval"] = c.Loki.BatchInterval.Duration
+	}
+	for _, key := range sortedKeys(positive) {
+		if positive[key] <= 0 {
+			v.errorf(key, "must be positive")
+		}
+	}
+
+	nonNegative := map[string]time.Duration{
+		"notify.batch_window":  c.Notify.BatchWindow.Duration,
+		"notify.retry_max_age": c.Notify.RetryMaxAge.Duration,
+		"db.retention":         c.DB.Retention.Duration,
+		"db.backup_interval":   c.DB.BackupInterval.Duration,
+		"health.journal_grace": c.Health.JournalGrace.Duration,
+		"smart.temp_sustain":   c.SMART.TempSustain.Duration,
+		"thrash.sustain":       c.Thrash.Sustain.Duration,
+		"capture.min_interval": c.Capture.MinInterval.Duration,
+		"bundle.retention":     c.Bundle.Retention.Duration,
+	}
+	for _, key := range sortedKeys(nonNegative) {
+		if nonNegative[key] < 0 {
+			v.errorf(key, "must not be negative")
+		}
+	}
+
+	// ausearch is asked for the last ten minutes of the audit log.
+	if c.Audit.Enabled && c.Audit.Source == "ausearch" && c.Audit.PollInterval.Duration >= 10*time.Minute {
+		v.errorf("audit.poll_interval", "must be under 10m, the span each ausearch poll covers")
+	}
+
+	if w := c.Cooldown.Window.Duration; w > 24*time.Hour {
+		v.warnf("cooldown.window", "%s silences repeats of a problem for more than a day", w)
+	}
+	if w := c.Notify.BatchWindow.Duration; w > 10*time.Minute {
+		v.warnf("notify.batch_window", "%s delays every alert by up to that long", w)
+	}
+	if r := c.DB.Retention.Duration; r > 0 && r < 7*24*time.Hour {
+		v.warnf("db.retention", "%s keeps less history than a weekly digest covers", r)
+	}
+	if c.DB.BackupInterval.Duration > 0 && c.DB.BackupKeep == 0 {
+		v.errorf("db.backup_keep", "must be at least 1 with backup_interval set")
+	}
+	if i := c.DB.BackupInterval.Duration; i > 0 && i < time.Hour {
+		v.warnf("db.backup_interval", "%s copies the whole database that often", i)
+	}
+	switch c.DB.Driver {
+	case "sqlite":
+		if c.DB.DSN != "" {
+			v.warnf("db.dsn", "is ignored with the sqlite driver")
+		}
+	case "postgres":
+		if c.DB.DSN == "" {
+			v.errorf("db.dsn", "required with the postgres driver")
+		}
+		if c.DB.BackupInterval.Duration > 0 {
+			v.errorf("db.backup_interval", "is for SQLite; back up a PostgreSQL database with pg_dump")
+		}
+	}
+}
+
+// checkRules checks the user classification rules.
+func (v *validator) checkRules() {
+	seen := make(map[string]bool)
+	for i, r := range v.c.Rules {
+		key := fmt.Sprintf("rules[%d]", i)
+		if r.Name != "" {
+			if seen[r.Name] {
+				v.warnf(key+".name", "%q is used by an earlier rule too", r.Name)
+			}
+			seen[r.Name] = true
+		}
+
+		if r.Pattern == "" {
+			v.errorf(key+".pattern", "required")
+		} else if re, err := regexp.Compile(r.Pattern); err != nil {
+			v.errorf(key+".pattern", "invalid regex: %v", err)
+		} else {
+			for _, m := range summaryGroupRe.FindAllStringSubmatch(r.Summary+r.Process, -1) {
+				if _, err := strconv.Atoi(m[1]); err != nil && re.SubexpIndex(m[1]) < 0 {
+					v.warnf(key+".summary", "%s refers to a group the pattern does not have", m[0])
+				}
+			}
+		}
+
+		if !ruleTierRe.MatchString(r.Tier) {
+			v.errorf(key+".tier", "%q must look like T1..Tn", r.Tier)
+		}
+		switch r.Severity {
+		case "", "critical", "high", "medium", "warning":
+		default:
+			v.errorf(key+".severity", "%q is not critical, high, medium, or warning", r.Severity)
+		}
+	}
+}
+
+// checkSecurity checks that the security severity tables name kinds of
+// security events and severities.
+func (v *validator) checkSecurity() {
+	c := v.c
+	tables := map[string]map[string]string{"security.severity": c.Security.Severity}
+	for role, t := range c.Security.Roles {
+		tables["security.roles."+role] = t
+	}
+	for _, key := range sortedKeys(tables) {
+		for _, kind := range sortedKeys(tables[key]) {
+			if !slices.Contains(SecurityKinds, kind) {
+				v.errorf(key+"."+kind, "unknown kind of security event; known kinds are %s", strings.Join(SecurityKinds, ", "))
+			}
+			switch sev := tables[key][kind]; sev {
+			case "critical", "high", "medium", "warning":
+			default:
+				v.errorf(key+"."+kind, "%q is not critical, high, medium, or warning", sev)
+			}
+		}
+	}
+}
+
+// checkSuppress checks the suppression rules.
+func (v *validator) checkSuppress() {
+	for i, r := range v.c.Suppress.Rules {
+		key := fmt.Sprintf("suppress.rules[%d]", i)
+		patterns := map[string]string{"message": r.Message, "unit": r.Unit, "process": r.Process}
+		for _, field := range sortedKeys(patterns) {
+			pattern := patterns[field]
+			if pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				v.errorf(key+"."+field, "invalid regex: %v", err)
+			}
+		}
+		if r.Message == "" && r.Unit == "" && r.Process == "" && r.Tier == "" {
+			v.errorf(key, "set at least one of message, unit, process, or tier")
+		}
+		if r.Tier != "" && !tierRe.MatchString(r.Tier) {
+			v.errorf(key+".tier", "%q is not a tier such as T1", r.Tier)
+		}
+		switch strings.ToLower(r.Stage) {
+		case "", "alert":
+		case "classify":
+			if r.Tier != "" {
+				v.errorf(key+".tier", "cannot be used at the classify stage, before events have a tier")
+			}
+		default:
+			v.errorf(key+".stage", "%q is not classify or alert", r.Stage)
+		}
+	}
+}
+
+// checkCooldown checks the cooldown overrides.
+func (v *validator) checkCooldown() {
+	for i, o := range v.c.Cooldown.Overrides {
+		key := fmt.Sprintf("cooldown.override[%d]", i)
+		patterns := map[string]string{"unit": o.Unit, "process": o.Process}
+		for _, field := range sortedKeys(patterns) {
+			if pattern := patterns[field]; pattern != "" {
+				if _, err := regexp.Compile(pattern); err != nil {
+					v.errorf(key+"."+field, "invalid regex: %v", err)
+				}
+			}
+		}
+		if o.Tier == "" && o.Unit == "" && o.Process == "" {
+			v.errorf(key, "set at least one of tier, unit, or process")
+		}
+		if o.Tier != "" && !tierRe.MatchString(o.Tier) {
+			v.errorf(key+".tier", "%q is not a tier such as T1", o.Tier)
+		}
+		switch w := o.Window.Duration; {
+		case w < 0:
+			v.errorf(key+".window", "must not be negative")
+		case w > 24*time.Hour:
+			v.warnf(key+".window", "%s silences repeats of a problem for more than a day", w)
+		}
+		if n := o.AggregateThreshold; n < 0 {
+			v.errorf(key+".aggregate_threshold", "must not be negative, got %d", n)
+		}
+		if o.Window.Duration == 0 && o.AggregateThreshold == 0 {
+			v.warnf(key, "sets neither window nor aggregate_threshold, so it changes nothing")
+		}
+	}
+}
+
+// checkRemediation checks the remediation actions and allow-list.
+func (v *validator) checkRemediation() {
+	r := v.c.Remediation
+	for i, pattern := range r.AllowedUnits {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			v.errorf(fmt.Sprintf("remediation.allowed_units[%d]", i), "%q is not a unit name or glob", pattern)
+		}
+	}
+	switch {
+	case r.Timeout.Duration <= 0:
+		v.errorf("remediation.timeout", "must be positive")
+	case r.Timeout.Duration > maxRemediationTimeout:
+		v.errorf("remediation.timeout", "must be at most %s, as events wait for the action; got %s",
+			maxRemediationTimeout, r.Timeout.Duration)
+	}
+	if r.Enabled && len(r.Actions) > 0 && len(r.AllowedUnits) == 0 {
+		v.warnf("remediation.allowed_units", "is empty, so no action will ever run")
+	}
+	for i, a := range r.Actions {
+		key := fmt.Sprintf("remediation.actions[%d]", i)
+		if a.Tier == "" && a.Unit == "" {
+			v.errorf(key, "set at least one of tier or unit")
+		}
+		if a.Tier != "" && !tierRe.MatchString(a.Tier) {
+			v.errorf(key+".tier", "%q is not a tier such as T1", a.Tier)
+		}
+		if a.Unit != "" {
+			if _, err := regexp.Compile(a.Unit); err != nil {
+				v.errorf(key+".unit", "invalid regex: %v", err)
+			}
+		}
+		if len(a.Command) > 0 && a.Command[0] == "" {
+			v.errorf(key+".command", "the program is empty")
+		}
+		if a.MaxRuns < 0 {
+			v.errorf(key+".max_runs", "must not be negative, got %d", a.MaxRuns)
+		}
+		if a.Per.Duration < 0 {
+			v.errorf(key+".per", "must not be negative")
+		}
+	}
+}
+
+// checkHooks checks the [[hooks]] scripts and their filters.
+func (v *validator) checkHooks() {
+	for i, h := range v.c.Hooks {
+		key := fmt.Sprintf("hooks[%d]", i)
+		if len(h.Command) == 0 || h.Command[0] == "" {
+			v.errorf(key+".command", "required: the program to run and its arguments")
+		}
+		for _, tier := range h.Tiers {
+			if !tierRe.MatchString(tier) {
+				v.errorf(key+".tiers", "%q is not a tier such as T1", tier)
+			}
+		}
+		switch h.MinSeverity {
+		case "", "critical", "high", "medium", "warning":
+		default:
+			v.errorf(key+".min_severity", "%q is not critical, high, medium, or warning", h.MinSeverity)
+		}
+		if h.Unit != "" {
+			if _, err := regexp.Compile(h.Unit); err != nil {
+				v.errorf(key+".unit", "invalid regex: %v", err)
+			}
+		}
+		if h.Timeout.Duration < 0 {
+			v.errorf(key+".timeout", "must not be negative")
+		}
+		if h.Concurrency < 0 {
+			v.errorf(key+".concurrency", "must not be negative, got %d", h.Concurrency)
+		}
+		if h.MaxRuns < 0 {
+			v.errorf(key+".max_runs", "must not be negative, got %d", h.MaxRuns)
+		}
+		if h.Per.Duration < 0 {
+			v.errorf(key+".per", "must not be negative")
+		}
+	}
+}
+
+// Check loads the config at path like Load, but rather than stopping at
+// the first mistake it returns every problem: TOML syntax errors, unknown
+// keys, and what Validate finds. The error is only for files that cannot
+// be read.
+func Check(path string) (*Config, []Problem, error) {
+	var problems []Problem
+	cfg, err := read(path, func(file string, err error) error {
+		var perr toml.ParseError
+		var uerr *UnknownKeysError
+		switch {
+		case errors.As(err, &perr):
+			problems = append(problems, Problem{Message: perr.Message, File: file, Line: perr.Position.Line})
+		case errors.As(err, &uerr):
+			for _, k := range uerr.Keys {
+				p := Problem{Key: k.Key, Message: "unknown key", File: file}
+				if k.Suggestion != "" {
+					p.Message += fmt.Sprintf(" (did you mean %q?)", k.Suggestion)
+				}
+				problems = append(problems, p)
+			}
+		default:
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range problems {
+		if problems[i].Line == 0 {
+			problems[i].Line = cfg.keyLine(problems[i].Key, problems[i].File)
+		}
+	}
+	return cfg, append(problems, cfg.Validate()...), nil
+}
+
+// locate fills in where the key of p was set.
+func (c *Config) locate(p *Problem) {
+	for key := p.Key; key != ""; {
+		if src, ok := c.sources[key]; ok {
+			p.File, p.Line = src.file, src.line
+			return
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+}
+
+// keyLine returns the line key was set on in file, or 0.
+func (c *Config) keyLine(key, file string) int {
+	p := Problem{Key: key}
+	c.locate(&p)
+	if p.File != file {
+		return 0
+	}
+	return p.Line
+}
+
+// recordKeys notes the line each key and table in a file is set on, for
+// locating problems. offsets gives the number of elements arrays of tables
+// that accumulate across files already had.
+func (c *Config) recordKeys(file string, data []byte, offsets map[string]int) {
+	if c.sources == nil {
+		c.sources = make(map[string]keySource)
+	}
+	counts := make(map[string]int)
+	table, plain := "", ""
+	set := func(key string, line int) {
+		c.sources[key] = keySource{file: file, line: line}
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#':
+		case strings.HasPrefix(line, "[["):
+			name, _, _ := strings.Cut(line[2:], "]]")
+			plain = unquoteKey(name)
+			table = fmt.Sprintf("%s[%d]", plain, offsets[plain]+counts[plain])
+			counts[plain]++
+			set(plain, i+1)
+			set(table, i+1)
+		case line[0] == '[':
+			name, _, _ := strings.Cut(line[1:], "]")
+			table = unquoteKey(name)
+			plain = table
+			set(table, i+1)
+		default:
+			name, _, ok := strings.Cut(line, "=")
+			if !ok || !tomlKeyRe.MatchString(name) {
+				continue
+			}
+			key := unquoteKey(name)
+			if table == "" {
+				set(key, i+1)
+				continue
+			}
+			set(table+"."+key, i+1)
+			if plain != table {
+				set(plain+"."+key, i+1)
+			}
+		}
+	}
+}
+
+// unquoteKey normalizes a possibly dotted and quoted TOML key.
+func unquoteKey(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
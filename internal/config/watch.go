@@ -0,0 +1,176 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long the files must be left alone after a change
+// before it is reported, so an editor's save, often a write to a temporary
+// file and a rename, or a tool copying in several drop-ins, is one change.
+var watchDelay = 500 * time.Millisecond
+
+// Watcher reports edits to the files Load reads for a config path: the
+// main file, files matching its include patterns, and drop-ins in <path>.d/.
+// It watches their directories rather than the files, as editors and config
+// management replace files by renaming over them.
+type Watcher struct {
+	path    string
+	fsw     *fsnotify.Watcher
+	changes chan struct{}
+
+	mu       sync.Mutex
+	patterns []string        // file patterns whose changes are reported
+	dirs     map[string]bool // directories being watched
+}
+
+// Watch starts watching the files cfg was loaded from, with path as given
+// to Load. Include patterns whose directory is itself a pattern are not
+// watched.
+func Watch(path string, cfg *Config) (*Watcher, error) {
+	if path == "" {
+		path = DefaultPath()
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching config: %w", err)
+	}
+	w := &Watcher{
+		path:    filepath.Clean(path),
+		fsw:     fsw,
+		changes: make(chan struct{}, 1),
+		dirs:    make(map[string]bool),
+	}
+	w.Update(cfg)
+	if len(w.dirs) == 0 {
+		fsw.Close()
+		return nil, fmt.Errorf("watching config: no directory of %s can be watched", path)
+	}
+	return w, nil
+}
+
+// Changes returns the channel a change is signalled on. Changes that come
+// while one is pending are merged with it.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Update watches the files of a reloaded config, whose includes may
+// differ, and any drop-in directory created since.
+func (w *Watcher) Update(cfg *Config) {
+	dropIns := w.path + ".d"
+	patterns := []string{w.path, dropIns, filepath.Join(dropIns, "*.toml")}
+	dirs := []string{filepath.Dir(w.path), dropIns}
+	for _, pattern := range cfg.Include {
+		pattern = expandHome(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(w.path), pattern)
+		}
+		pattern = filepath.Clean(pattern)
+		patterns = append(patterns, pattern)
+		if dir := filepath.Dir(pattern); !strings.ContainsAny(dir, "*?[") {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.patterns = patterns
+	for _, dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.fsw.Add(dir); err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("cannot watch config directory", "dir", dir, "error", err)
+			}
+			continue
+		}
+		w.dirs[dir] = true
+	}
+}
+
+// Run reports changes until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod || !w.relevant(ev.Name) {
+				continue
+			}
+			if ev.Name == w.path+".d" && ev.Has(fsnotify.Create) {
+				w.watchDir(ev.Name)
+			}
+			if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+				w.forgetDir(ev.Name)
+			}
+			timer.Reset(watchDelay)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("config watch error", "error", err)
+		case <-timer.C:
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// relevant reports whether a change to name may change the loaded config.
+func (w *Watcher) relevant(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, pattern := range w.patterns {
+		if name == pattern {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDir starts watching a directory created after Watch.
+func (w *Watcher) watchDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dirs[dir] {
+		return
+	}
+	if err := w.fsw.Add(dir); err == nil {
+		w.dirs[dir] = true
+	}
+}
+
+// forgetDir notes that a watched directory is gone, so it is watched again
+// if it comes back.
+func (w *Watcher) forgetDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirs, dir)
+}
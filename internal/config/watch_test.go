@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { watchDelay = d }(watchDelay)
+	watchDelay = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(path, "include = [\"rules/*.toml\"]\n")
+	if err := os.Mkdir(filepath.Join(dir, "rules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Watch(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	expect := func(what string, changed bool) {
+		t.Helper()
+		select {
+		case <-w.Changes():
+			if !changed {
+				t.Errorf("%s: reported a change", what)
+			}
+		case <-time.After(10 * watchDelay):
+			if changed {
+				t.Errorf("%s: no change reported", what)
+			}
+		}
+	}
+
+	write(filepath.Join(dir, "notes.txt"), "unrelated")
+	expect("unrelated file", false)
+
+	// Several writes in quick succession are one change.
+	write(path, "include = [\"rules/*.toml\"]\n[log]\nlevel = \"debug\"\n")
+	write(path, "include = [\"rules/*.toml\"]\n[log]\nlevel = \"warn\"\n")
+	expect("main file", true)
+	expect("main file, again", false)
+
+	write(filepath.Join(dir, "rules", "nas.toml"), "[[rules]]\n")
+	expect("included file", true)
+
+	// A drop-in directory created after Watch is watched too.
+	if err := os.Mkdir(path+".d", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	expect("drop-in directory", true)
+	write(filepath.Join(path+".d", "10-ntfy.toml"), "[ntfy]\n")
+	expect("drop-in", true)
+	write(filepath.Join(path+".d", "README"), "not a drop-in")
+	expect("non-toml drop-in", false)
+}
@@ -0,0 +1,224 @@
+// Package control serves the daemon's control socket: a Unix socket, like
+// systemd's, over which the CLI asks the running daemon for its state and
+// has it act, e.g. "logtriage status" or "logtriage ctl purge". Requests
+// are HTTP with JSON responses, so the socket can also be queried with
+// curl --unix-socket.
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrNotRunning is returned by the client when no daemon is listening on
+// the socket.
+var ErrNotRunning = errors.New("daemon not running")
+
+// Handler answers a command with a value encoded as the JSON response.
+// args holds the query parameters or form values of the request.
+type Handler func(ctx context.Context, args url.Values) (any, error)
+
+// ArgError is an error in a command's arguments, answered with 400 rather
+// than 500.
+type ArgError struct {
+	Err error
+}
+
+func (e *ArgError) Error() string { return e.Err.Error() }
+
+func (e *ArgError) Unwrap() error { return e.Err }
+
+// Server is the control socket server.
+type Server struct {
+	path  string
+	mux   *http.ServeMux
+	calls chan func()
+}
+
+// New creates a server that will listen on the Unix socket at path.
+func New(path string) *Server {
+	return &Server{
+		path:  path,
+		mux:   http.NewServeMux(),
+		calls: make(chan func()),
+	}
+}
+
+// Path returns the socket path.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Handle registers h for a command, as a method and name, e.g. "GET
+// /stats" or "POST /purge". Handlers run on the server's goroutines; state
+// owned by the daemon's event loop must be read through Do.
+func (s *Server) Handle(command string, h Handler) {
+	s.mux.HandleFunc(command, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		v, err := h(r.Context(), r.Form)
+		var aerr *ArgError
+		switch {
+		case errors.As(err, &aerr):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, v)
+		}
+	})
+}
+
+// Calls returns the channel of functions handlers pass to Do. The daemon's
+// event loop receives from it and runs each.
+func (s *Server) Calls() <-chan func() {
+	return s.calls
+}
+
+// Do runs fn on the goroutine receiving from Calls and waits for it to
+// return, or for ctx to be done.
+func (s *Server) Do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	select {
+	case s.calls <- func() { fn(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Start binds the socket and serves in the background until ctx is
+// cancelled, then removes the socket. A stale socket left by a daemon that
+// died is replaced; one a running daemon answers on is an error. The socket
+// is only accessible to the daemon's user.
+func (s *Server) Start(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("creating socket directory: %w", err)
+	}
+	if conn, err := net.DialTimeout("unix", s.path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another daemon is listening on %s", s.path)
+	}
+	os.Remove(s.path)
+
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.path, err)
+	}
+	if err := os.Chmod(s.path, 0o600); err != nil {
+		ln.Close()
+		return fmt.Errorf("restricting %s: %w", s.path, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("control socket failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Get runs a read-only command on the daemon listening at path and
+// decodes its response into out.
+func Get(ctx context.Context, path, command string, args url.Values, out any) error {
+	return call(ctx, path, http.MethodGet, command, args, out)
+}
+
+// Post runs a command that acts, such as purge or reload, on the daemon
+// listening at path and decodes its response into out.
+func Post(ctx context.Context, path, command string, args url.Values, out any) error {
+	return call(ctx, path, http.MethodPost, command, args, out)
+}
+
+func call(ctx context.Context, path, method, command string, args url.Values, out any) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	target := "http://logtriage/" + strings.TrimPrefix(command, "/")
+	var body io.Reader
+	if method == http.MethodGet {
+		if len(args) > 0 {
+			target += "?" + args.Encode()
+		}
+	} else {
+		body = strings.NewReader(args.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return ErrNotRunning
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return fmt.Errorf("%s: %s", command, e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,82 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerCommands(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "control.sock")
+	s := New(path)
+
+	// State owned by the event loop, changed only on it.
+	purged := 0
+	go func() {
+		for {
+			select {
+			case fn := <-s.Calls():
+				fn()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.Handle("GET /echo", func(ctx context.Context, args url.Values) (any, error) {
+		if args.Get("n") == "" {
+			return nil, &ArgError{Err: errors.New("n is required")}
+		}
+		return map[string]string{"n": args.Get("n")}, nil
+	})
+	s.Handle("POST /purge", func(ctx context.Context, args url.Values) (any, error) {
+		var n int
+		err := s.Do(ctx, func() {
+			purged += 3
+			n = purged
+		})
+		return map[string]int{"purged": n}, err
+	})
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var echo map[string]string
+	if err := Get(ctx, path, "echo", url.Values{"n": {"5"}}, &echo); err != nil {
+		t.Fatal(err)
+	}
+	if echo["n"] != "5" {
+		t.Errorf("echo = %v", echo)
+	}
+	if err := Get(ctx, path, "echo", nil, nil); err == nil || !strings.Contains(err.Error(), "n is required") {
+		t.Errorf("missing argument: err = %v", err)
+	}
+
+	var res map[string]int
+	if err := Post(ctx, path, "purge", nil, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["purged"] != 3 {
+		t.Errorf("purge = %v", res)
+	}
+	if err := Get(ctx, path, "purge", nil, nil); err == nil {
+		t.Error("GET of a POST command succeeded")
+	}
+
+	if err := New(path).Start(ctx); err == nil {
+		t.Error("second server started on a live socket")
+	}
+}
+
+func TestClientNotRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	if err := Get(context.Background(), path, "stats", nil, nil); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("err = %v, want ErrNotRunning", err)
+	}
+}
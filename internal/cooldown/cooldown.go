@@ -0,0 +1,121 @@
+// Package cooldown picks the cooldown window and aggregate threshold that
+// apply to an event: the [cooldown] ones, or those of the first
+// [[cooldown.override]] matching its tier, unit, and process. A flapping
+// service wants a long window, an OOM kill a short one.
+package cooldown
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Setting is the cooldown applied to an event.
+type Setting struct {
+	Window    time.Duration
+	Threshold int    // repeats within Window that send the aggregate alert
+	Override  string // name of the override that applied; empty for [cooldown]
+}
+
+// override is a compiled [[cooldown.override]].
+type override struct {
+	name    string
+	tier    event.Tier
+	unit    *regexp.Regexp
+	process *regexp.Regexp
+	setting Setting
+}
+
+// Policy chooses the cooldown for each event.
+type Policy struct {
+	def       Setting
+	overrides []override
+}
+
+// New compiles the cooldown config. Every invalid override is reported.
+func New(cfg config.CooldownConfig) (*Policy, error) {
+	p := &Policy{def: Setting{Window: cfg.Window.Duration, Threshold: cfg.AggregateThreshold}}
+	var errs []error
+
+	for i, spec := range cfg.Overrides {
+		name := spec.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		o := override{name: name, tier: event.Tier(strings.ToUpper(spec.Tier)), setting: p.def}
+		o.setting.Override = name
+		if spec.Window.Duration > 0 {
+			o.setting.Window = spec.Window.Duration
+		}
+		if spec.AggregateThreshold > 0 {
+			o.setting.Threshold = spec.AggregateThreshold
+		}
+
+		var err error
+		if o.unit, err = compileOptional("unit", spec.Unit); err == nil {
+			o.process, err = compileOptional("process", spec.Process)
+		}
+		if err == nil && o.tier == "" && o.unit == nil && o.process == nil {
+			err = errors.New("at least one of tier, unit, or process is required")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cooldown override %s: %w", name, err))
+			continue
+		}
+		p.overrides = append(p.overrides, o)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compileOptional(field, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", field, err)
+	}
+	return re, nil
+}
+
+// For returns the cooldown that applies to ev.
+func (p *Policy) For(ev *event.Event) Setting {
+	for _, o := range p.overrides {
+		if o.matches(ev) {
+			return o.setting
+		}
+	}
+	return p.def
+}
+
+// MaxWindow returns the longest window of any setting, which is how long
+// an incident must be idle before it can be closed.
+func (p *Policy) MaxWindow() time.Duration {
+	w := p.def.Window
+	for _, o := range p.overrides {
+		w = max(w, o.setting.Window)
+	}
+	return w
+}
+
+func (o override) matches(ev *event.Event) bool {
+	if o.tier != "" && o.tier != ev.Tier {
+		return false
+	}
+	if o.unit != nil && !o.unit.MatchString(ev.Unit) {
+		return false
+	}
+	if o.process != nil && !o.process.MatchString(ev.Process) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package cooldown
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestPolicyFor(t *testing.T) {
+	p, err := New(config.CooldownConfig{
+		Window:             config.Duration{Duration: 5 * time.Minute},
+		AggregateThreshold: 3,
+		Overrides: []config.CooldownOverride{
+			{Name: "flaps", Tier: "t3", Unit: `^backup-`, Window: config.Duration{Duration: 2 * time.Hour}},
+			{Name: "oom", Tier: "T1", Window: config.Duration{Duration: 30 * time.Second}, AggregateThreshold: 10},
+			{Process: `^chrome$`, AggregateThreshold: 20},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := func(tier event.Tier, unit, process string) *event.Event {
+		ev := event.New("h", time.Now(), tier, event.SevHigh, "x")
+		ev.Unit, ev.Process = unit, process
+		return ev
+	}
+	tests := []struct {
+		name string
+		ev   *event.Event
+		want Setting
+	}{
+		{"flapping unit", ev(event.TierServiceFailure, "backup-home.service", ""), Setting{2 * time.Hour, 3, "flaps"}},
+		{"other unit", ev(event.TierServiceFailure, "nginx.service", ""), Setting{5 * time.Minute, 3, ""}},
+		{"oom", ev(event.TierOOMKill, "", "java"), Setting{30 * time.Second, 10, "oom"}},
+		{"unnamed", ev(event.TierProcessCrash, "", "chrome"), Setting{5 * time.Minute, 20, "#3"}},
+	}
+	for _, tt := range tests {
+		if got := p.For(tt.ev); got != tt.want {
+			t.Errorf("%s: For = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if got := p.MaxWindow(); got != 2*time.Hour {
+		t.Errorf("MaxWindow = %s, want 2h", got)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(config.CooldownConfig{
+		Overrides: []config.CooldownOverride{
+			{Name: "bad", Unit: `(`},
+			{Window: config.Duration{Duration: time.Hour}},
+		},
+	})
+	if err == nil {
+		t.Fatal("invalid overrides accepted")
+	}
+	for _, want := range []string{"override bad: invalid unit pattern", "override #2: at least one"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package corpus
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	homeRe = regexp.MustCompile(`/home/([^/\s'"]+)`)
+	ipv4Re = regexp.MustCompile(`\b(\d{1,3})\.(\d{1,3})\.(\d{1,3})\.(\d{1,3})\b`)
+	macRe  = regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}\b`)
+	mailRe = regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`)
+)
+
+// Anonymizer replaces host names, user names, home directories, IPv4 and
+// MAC addresses, and email addresses with stable placeholders. The same
+// input always maps to the same placeholder within one Anonymizer, so
+// related fixtures stay consistent.
+type Anonymizer struct {
+	names   []*regexp.Regexp // known host and user names
+	replace []string         // placeholder for each of names
+	users   map[string]string
+	ips     map[string]string
+	macs    map[string]string
+}
+
+// NewAnonymizer creates an Anonymizer that also replaces the given host and
+// user names wherever they appear as whole words. Names shorter than three
+// characters are ignored to avoid mangling ordinary words.
+func NewAnonymizer(hosts, users []string) *Anonymizer {
+	a := &Anonymizer{
+		users: make(map[string]string),
+		ips:   make(map[string]string),
+		macs:  make(map[string]string),
+	}
+	add := func(name, placeholder string) {
+		if len(name) < 3 {
+			return
+		}
+		a.names = append(a.names, regexp.MustCompile(`\b`+regexp.QuoteMeta(name)+`\b`))
+		a.replace = append(a.replace, placeholder)
+	}
+	for i, h := range hosts {
+		add(h, fmt.Sprintf("host%d", i+1))
+	}
+	sorted := append([]string(nil), users...)
+	sort.Strings(sorted)
+	for _, u := range sorted {
+		add(u, a.user(u))
+	}
+	return a
+}
+
+// Anonymize returns s with identifying values replaced.
+func (a *Anonymizer) Anonymize(s string) string {
+	s = homeRe.ReplaceAllStringFunc(s, func(m string) string {
+		return "/home/" + a.user(strings.TrimPrefix(m, "/home/"))
+	})
+	for i, re := range a.names {
+		s = re.ReplaceAllString(s, a.replace[i])
+	}
+	s = mailRe.ReplaceAllString(s, "user@example.com")
+	s = macRe.ReplaceAllStringFunc(s, func(m string) string {
+		return placeholder(a.macs, strings.ToLower(m), func(n int) string {
+			return fmt.Sprintf("02:00:00:00:%02x:%02x", n>>8&0xff, n&0xff)
+		})
+	})
+	s = ipv4Re.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "127.") || m == "0.0.0.0" {
+			return m
+		}
+		// TEST-NET-3 (RFC 5737) addresses are reserved for documentation.
+		return placeholder(a.ips, m, func(n int) string {
+			return fmt.Sprintf("203.0.113.%d", n%254+1)
+		})
+	})
+	return s
+}
+
+// user returns the placeholder for a user name.
+func (a *Anonymizer) user(name string) string {
+	return placeholder(a.users, name, func(n int) string {
+		return fmt.Sprintf("user%d", n+1)
+	})
+}
+
+// placeholder returns the existing replacement for key in m, or assigns the
+// next one from gen.
+func placeholder(m map[string]string, key string, gen func(n int) string) string {
+	if p, ok := m[key]; ok {
+		return p
+	}
+	p := gen(len(m))
+	m[key] = p
+	return p
+}
@@ -0,0 +1,204 @@
+// Package corpus records journal entries as anonymized JSON fixtures and
+// replays them through the classifier, so pattern changes can be checked
+// against real logs for classification regressions.
+package corpus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// keptFields are the journal fields stored in a fixture: everything the
+// classifier reads, and nothing that identifies the machine.
+var keptFields = []string{
+	"MESSAGE",
+	"PRIORITY",
+	"SYSLOG_IDENTIFIER",
+	"_SYSTEMD_UNIT",
+	"UNIT",
+	"_PID",
+	"_COMM",
+	"_TRANSPORT",
+	"__REALTIME_TIMESTAMP",
+	"PODMAN_EVENT",
+	"PODMAN_ID",
+	"PODMAN_NAME",
+	"PODMAN_EXIT_CODE",
+}
+
+// Fixture is one recorded journal entry and how it was classified.
+type Fixture struct {
+	Fields map[string]string `json:"fields"`
+	// Expect is the expected classification, or nil for a near-miss: an
+	// entry that should stay unclassified.
+	Expect *Expect `json:"expect"`
+
+	Path string `json:"-"` // file the fixture was loaded from or written to
+}
+
+// Expect is the part of a classified event that a fixture pins down.
+type Expect struct {
+	Tier     event.Tier     `json:"tier"`
+	Severity event.Severity `json:"severity"`
+	Summary  string         `json:"summary"`
+	Process  string         `json:"process,omitempty"`
+	Unit     string         `json:"unit,omitempty"`
+}
+
+// ExpectFor returns the expectation for an event, or nil if ev is nil.
+func ExpectFor(ev *event.Event) *Expect {
+	if ev == nil {
+		return nil
+	}
+	return &Expect{
+		Tier:     ev.Tier,
+		Severity: ev.Severity,
+		Summary:  ev.Summary,
+		Process:  ev.Process,
+		Unit:     ev.Unit,
+	}
+}
+
+// Entry rebuilds the journal entry the fixture was recorded from.
+func (f *Fixture) Entry() (watcher.JournalEntry, error) {
+	data, err := json.Marshal(f.Fields)
+	if err != nil {
+		return watcher.JournalEntry{}, err
+	}
+	return watcher.ParseJournalJSON(data)
+}
+
+// Record anonymizes entry, classifies it, and returns the fixture. It
+// returns nil for unclassified entries unless nearMiss is set.
+func Record(cls *classifier.Classifier, anon *Anonymizer, entry watcher.JournalEntry, nearMiss bool) (*Fixture, error) {
+	fields := make(map[string]string)
+	for _, k := range keptFields {
+		if v, ok := entry.Fields[k]; ok {
+			fields[k] = anon.Anonymize(v)
+		}
+	}
+
+	f := &Fixture{Fields: fields}
+	clean, err := f.Entry()
+	if err != nil {
+		return nil, err
+	}
+	f.Expect = ExpectFor(cls.Classify(clean))
+	if f.Expect == nil && !nearMiss {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Name returns the fixture's file name: the expected tier (or "miss") and a
+// hash of the identifying fields, so re-recording the same message
+// overwrites rather than duplicates it.
+func (f *Fixture) Name() string {
+	h := sha256.New()
+	for _, k := range []string{"SYSLOG_IDENTIFIER", "_SYSTEMD_UNIT", "MESSAGE"} {
+		fmt.Fprintf(h, "%s=%s\n", k, f.Fields[k])
+	}
+	prefix := "miss"
+	if f.Expect != nil {
+		prefix = strings.ToLower(string(f.Expect.Tier))
+	}
+	return prefix + "-" + hex.EncodeToString(h.Sum(nil))[:12] + ".json"
+}
+
+// Write stores the fixture in dir and reports whether it was new.
+func (f *Fixture) Write(dir string) (bool, error) {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	f.Path = filepath.Join(dir, f.Name())
+	_, statErr := os.Stat(f.Path)
+	if err := os.WriteFile(f.Path, append(data, '\n'), 0o644); err != nil {
+		return false, fmt.Errorf("writing fixture: %w", err)
+	}
+	return os.IsNotExist(statErr), nil
+}
+
+// Load reads every *.json fixture in dir, sorted by name.
+func Load(dir string) ([]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var fixtures []*Fixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		f.Path = path
+		fixtures = append(fixtures, &f)
+	}
+	return fixtures, nil
+}
+
+// Mismatch is a fixture whose classification changed.
+type Mismatch struct {
+	Fixture *Fixture
+	Got     *Expect
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: %q\n  want %s\n  got  %s",
+		filepath.Base(m.Fixture.Path), m.Fixture.Fields["MESSAGE"],
+		describe(m.Fixture.Expect), describe(m.Got))
+}
+
+func describe(e *Expect) string {
+	if e == nil {
+		return "unclassified"
+	}
+	s := fmt.Sprintf("%s/%s %q", e.Tier, e.Severity, e.Summary)
+	if e.Process != "" {
+		s += " process=" + e.Process
+	}
+	if e.Unit != "" {
+		s += " unit=" + e.Unit
+	}
+	return s
+}
+
+// Replay classifies every fixture and returns those whose result no longer
+// matches what was recorded.
+func Replay(cls *classifier.Classifier, fixtures []*Fixture) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	for _, f := range fixtures {
+		entry, err := f.Entry()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		got := ExpectFor(cls.Classify(entry))
+		if !sameExpect(f.Expect, got) {
+			mismatches = append(mismatches, Mismatch{Fixture: f, Got: got})
+		}
+	}
+	return mismatches, nil
+}
+
+func sameExpect(a, b *Expect) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
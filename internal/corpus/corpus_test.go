@@ -0,0 +1,131 @@
+package corpus
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// TestGoldenCorpus replays the fixtures in testdata, plus any corpus
+// directories listed in LOGTRIAGE_CORPUS (colon-separated), and fails on
+// any change in classification.
+func TestGoldenCorpus(t *testing.T) {
+	dirs := []string{"testdata"}
+	if extra := os.Getenv("LOGTRIAGE_CORPUS"); extra != "" {
+		dirs = append(dirs, filepath.SplitList(extra)...)
+	}
+
+	cls := classifier.New("test")
+	for _, dir := range dirs {
+		fixtures, err := Load(dir)
+		if err != nil {
+			t.Fatalf("loading %s: %v", dir, err)
+		}
+		if len(fixtures) == 0 {
+			t.Errorf("%s: no fixtures", dir)
+		}
+		mismatches, err := Replay(cls, fixtures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range mismatches {
+			t.Error(m)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	cls := classifier.New("test")
+	anon := NewAnonymizer([]string{"nas01"}, nil)
+
+	oom := watcher.JournalEntry{
+		Message:          "Out of memory: Killed process 4521 (firefox) total-vm:12345kB, anon-rss:3200000kB",
+		SyslogIdentifier: "kernel",
+		Transport:        "kernel",
+		Fields: map[string]string{
+			"MESSAGE":           "Out of memory: Killed process 4521 (firefox) total-vm:12345kB, anon-rss:3200000kB",
+			"SYSLOG_IDENTIFIER": "kernel",
+			"_TRANSPORT":        "kernel",
+			"_HOSTNAME":         "nas01",
+			"_MACHINE_ID":       "0123456789abcdef",
+		},
+	}
+	f, err := Record(cls, anon, oom, false)
+	if err != nil || f == nil {
+		t.Fatalf("Record = %v, %v", f, err)
+	}
+	if _, ok := f.Fields["_HOSTNAME"]; ok {
+		t.Error("identifying fields should not be recorded")
+	}
+	if f.Expect.Tier != event.TierOOMKill || f.Expect.Process != "firefox" {
+		t.Errorf("expect = %+v", f.Expect)
+	}
+
+	isNew, err := f.Write(dir)
+	if err != nil || !isNew {
+		t.Fatalf("Write = %v, %v", isNew, err)
+	}
+	if isNew, _ := f.Write(dir); isNew {
+		t.Error("rewriting the same entry should not create a new fixture")
+	}
+
+	miss := watcher.JournalEntry{Message: "nothing to see", Fields: map[string]string{"MESSAGE": "nothing to see"}}
+	if f, _ := Record(cls, anon, miss, false); f != nil {
+		t.Error("unclassified entry recorded without nearMiss")
+	}
+	f, _ = Record(cls, anon, miss, true)
+	if f == nil || f.Expect != nil {
+		t.Fatalf("near-miss fixture = %+v", f)
+	}
+	f.Write(dir)
+
+	fixtures, err := Load(dir)
+	if err != nil || len(fixtures) != 2 {
+		t.Fatalf("Load = %d fixtures, %v", len(fixtures), err)
+	}
+	if m, _ := Replay(cls, fixtures); len(m) != 0 {
+		t.Errorf("fresh corpus has mismatches: %v", m)
+	}
+
+	// A changed expectation is reported.
+	for _, f := range fixtures {
+		if f.Expect != nil {
+			f.Expect.Summary = "something else"
+		}
+	}
+	m, _ := Replay(cls, fixtures)
+	if len(m) != 1 || !strings.Contains(m[0].String(), "OOM Kill: firefox") {
+		t.Errorf("mismatches = %v", m)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	a := NewAnonymizer([]string{"nas01"}, []string{"alice", "bo"})
+
+	got := a.Anonymize("nas01 sshd: alice from 192.168.1.20 (aa:BB:cc:dd:ee:ff) mailed bob@corp.example.org, see /home/carol/x and /home/alice/y; bo stays")
+	for _, leak := range []string{"nas01", "alice", "192.168.1.20", "aa:BB", "bob@", "carol"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("output leaks %q: %s", leak, got)
+		}
+	}
+	if !strings.Contains(got, "bo stays") {
+		t.Errorf("short names should be left alone: %s", got)
+	}
+
+	// Placeholders are stable across calls.
+	if a.Anonymize("192.168.1.20") != a.Anonymize("192.168.1.20") {
+		t.Error("same address mapped to different placeholders")
+	}
+	if a.Anonymize("/home/alice") != "/home/"+a.Anonymize("alice") {
+		t.Error("home directory and user name map to different placeholders")
+	}
+	if got := a.Anonymize("listening on 127.0.0.1"); got != "listening on 127.0.0.1" {
+		t.Errorf("loopback changed: %s", got)
+	}
+}
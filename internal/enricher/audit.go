@@ -0,0 +1,141 @@
+package enricher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// denialLookback is how long before a service failure access denials of
+// the service's process are looked for.
+const denialLookback = 5 * time.Minute
+
+// maxDenials caps the access denials listed in a service failure's detail.
+const maxDenials = 5
+
+// enrichDenials appends to a service failure the SELinux and AppArmor
+// denials of its main process logged shortly before it failed, which are
+// often why it did. source is where audit records are read: "journal" or
+// "ausearch".
+func enrichDenials(ctx context.Context, ev *event.Event, source string) {
+	if ev.Unit == "" {
+		return
+	}
+	user := ev.RawFields["_user_unit"] != ""
+
+	denials, err := recentDenials(ctx, source, ev.Timestamp)
+	if err != nil {
+		slog.Debug("denial enrichment: failed to read audit records", "source", source, "error", err)
+		return
+	}
+	if len(denials) == 0 {
+		return
+	}
+
+	// The process a denial names is matched on the unit's last main PID,
+	// or failing that the unit's name, which is usually its command's.
+	mainPID, _ := getMainPID(ctx, ev.Unit, user)
+	comm := strings.TrimSuffix(ev.Unit, ".service")
+	comm = comm[:min(len(comm), 15)] // the kernel truncates comm to 15 bytes
+
+	var matched []classifier.Denial
+	for _, d := range denials {
+		if (mainPID > 0 && d.PID == mainPID) || d.Process == comm {
+			matched = append(matched, d)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	ev.RawFields["_access_denials"] = strconv.Itoa(len(matched))
+	var detail strings.Builder
+	if ev.Detail != "" {
+		detail.WriteString(strings.TrimRight(ev.Detail, "\n") + "\n\n")
+	}
+	detail.WriteString("Access denials before the failure:\n")
+	for i, d := range matched {
+		if i == maxDenials {
+			fmt.Fprintf(&detail, "  ... and %d more\n", len(matched)-i)
+			break
+		}
+		fmt.Fprintf(&detail, "  %s\n", d.Summary)
+	}
+	ev.Detail = detail.String()
+}
+
+// recentDenials returns the enforced access denials logged in the
+// denialLookback before t, oldest first.
+func recentDenials(ctx context.Context, source string, t time.Time) ([]classifier.Denial, error) {
+	var entries []watcher.JournalEntry
+	switch source {
+	case "journal":
+		out, err := runCommand(ctx, "journalctl", "_TRANSPORT=audit",
+			"--since", fmt.Sprintf("@%d", t.Add(-denialLookback).Unix()),
+			"--until", fmt.Sprintf("@%d", t.Unix()+1),
+			"-o", "json", "--no-pager",
+		)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if entry, err := watcher.ParseJournalJSON(scanner.Bytes()); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+	case "ausearch":
+		// "recent" is the last ten minutes, which covers the lookback of
+		// an event enriched as it happens.
+		out, err := runCommand(ctx, "ausearch", "--input-logs", "-r", "-m", "AVC,USER_AVC", "-ts", "recent")
+		if err != nil {
+			if strings.Contains(err.Error(), "no matches") {
+				return nil, nil
+			}
+			return nil, err
+		}
+		entries = watcher.ParseAuditLog(out)
+	default:
+		return nil, fmt.Errorf("unknown audit source %q", source)
+	}
+	return denialsBetween(entries, t.Add(-denialLookback), t.Add(time.Second)), nil
+}
+
+// denialsBetween returns the enforced access denials among entries logged
+// from since up to until.
+func denialsBetween(entries []watcher.JournalEntry, since, until time.Time) []classifier.Denial {
+	var denials []classifier.Denial
+	for _, entry := range entries {
+		if ts := entry.Time(); ts.Before(since) || ts.After(until) {
+			continue
+		}
+		if d, ok := classifier.AccessDenial(entry); ok {
+			denials = append(denials, d)
+		}
+	}
+	return denials
+}
+
+// getMainPID returns the PID the main process of a unit last ran as, or 0
+// if it never started.
+func getMainPID(ctx context.Context, unit string, user bool) (int, error) {
+	args := []string{"show", "-p", "ExecMainPID", "--value", unit}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := runCommand(ctx, "systemctl", args...)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
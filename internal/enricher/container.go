@@ -0,0 +1,170 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// containerInspectFormat prints the fields enrichContainer reads, one per
+// line. docker prefixes names with "/"; podman does not.
+const containerInspectFormat = "{{.Name}}\n{{.Config.Image}}\n{{.State.Status}}\n{{.State.ExitCode}}\n{{.State.OOMKilled}}\n{{.RestartCount}}"
+
+// enrichContainer resolves the name and image of the container an event
+// happened in with docker or podman inspect, and appends them to the
+// detail. The container may already be gone; then the event keeps its ID.
+func enrichContainer(ctx context.Context, ev *event.Event) {
+	runtime := ev.RawFields["_container_runtime"]
+	if runtime == classifier.RuntimeCRI {
+		enrichPodContainer(ctx, ev)
+		return
+	}
+	if runtime != classifier.RuntimeDocker && runtime != classifier.RuntimePodman {
+		return
+	}
+
+	info, err := inspectContainer(ctx, runtime, ev.ContainerID)
+	if err != nil {
+		slog.Debug("container enrichment: inspect failed", "runtime", runtime, "id", ev.ContainerID, "error", err)
+		return
+	}
+	if ev.ContainerName == "" {
+		ev.ContainerName = info.name
+	}
+
+	var b strings.Builder
+	if ev.Detail != "" {
+		b.WriteString(ev.Detail)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "Container: %s (%s, %s)\n", info.name, classifier.ShortContainerID(ev.ContainerID), runtime)
+	fmt.Fprintf(&b, "Image: %s\n", info.image)
+	fmt.Fprintf(&b, "State: %s, exit code %s", info.status, info.exitCode)
+	if info.oomKilled {
+		b.WriteString(", OOM killed")
+	}
+	if info.restarts != "" && info.restarts != "0" {
+		fmt.Fprintf(&b, ", %s restarts", info.restarts)
+	}
+	ev.Detail = b.String()
+}
+
+type containerInfo struct {
+	name      string
+	image     string
+	status    string
+	exitCode  string
+	oomKilled bool
+	restarts  string
+}
+
+func inspectContainer(ctx context.Context, runtime, id string) (containerInfo, error) {
+	out, err := runCommand(ctx, runtime, "inspect", "--format", containerInspectFormat, id)
+	if err != nil {
+		return containerInfo{}, err
+	}
+	return parseContainerInspect(string(out))
+}
+
+// parseContainerInspect parses the output of containerInspectFormat.
+func parseContainerInspect(out string) (containerInfo, error) {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) < 6 {
+		return containerInfo{}, fmt.Errorf("unexpected inspect output: %q", out)
+	}
+	return containerInfo{
+		name:      strings.TrimPrefix(lines[0], "/"),
+		image:     lines[1],
+		status:    lines[2],
+		exitCode:  lines[3],
+		oomKilled: lines[4] == "true",
+		restarts:  lines[5],
+	}, nil
+}
+
+// podLogLines is how many of a pod container's last log lines are added to
+// the detail.
+const podLogLines = 10
+
+// enrichPodContainer adds the pod and last log lines of a Kubernetes pod
+// container to the detail, using crictl. Events from the CRI source already
+// name the pod; a kernel OOM kill only has the container ID.
+func enrichPodContainer(ctx context.Context, ev *event.Event) {
+	var b strings.Builder
+	b.WriteString(ev.Detail)
+
+	if ev.RawFields["CRI_POD_NAME"] == "" {
+		info, err := inspectPodContainer(ctx, ev.ContainerID)
+		if err != nil {
+			slog.Debug("pod container enrichment: inspect failed", "id", ev.ContainerID, "error", err)
+			return
+		}
+		if ev.ContainerName == "" {
+			ev.ContainerName = info.name
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "Pod: %s/%s\n", info.namespace, info.pod)
+		fmt.Fprintf(&b, "Container: %s (%s)\n", info.name, classifier.ShortContainerID(ev.ContainerID))
+		fmt.Fprintf(&b, "Image: %s", info.image)
+	}
+
+	// crictl relays the container's stdout and stderr on its own.
+	out, err := runCommandCombined(ctx, "crictl", "logs", "--tail", fmt.Sprint(podLogLines), ev.ContainerID)
+	if err != nil {
+		slog.Debug("pod container enrichment: logs failed", "id", ev.ContainerID, "error", err)
+	} else if logs := strings.TrimRight(string(out), "\n"); logs != "" {
+		b.WriteString("\n\nLast log lines:\n")
+		for line := range strings.Lines(logs) {
+			b.WriteString("  " + line)
+		}
+	}
+	ev.Detail = strings.TrimRight(b.String(), "\n")
+}
+
+type podContainerInfo struct {
+	namespace string
+	pod       string
+	name      string
+	image     string
+}
+
+func inspectPodContainer(ctx context.Context, id string) (podContainerInfo, error) {
+	out, err := runCommand(ctx, "crictl", "inspect", "-o", "json", id)
+	if err != nil {
+		return podContainerInfo{}, err
+	}
+	return parsePodInspect(out)
+}
+
+// parsePodInspect parses the pod labels and image out of crictl inspect -o
+// json output.
+func parsePodInspect(out []byte) (podContainerInfo, error) {
+	var doc struct {
+		Status struct {
+			Labels map[string]string `json:"labels"`
+			Image  struct {
+				Image string `json:"image"`
+			} `json:"image"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return podContainerInfo{}, fmt.Errorf("parsing crictl inspect output: %w", err)
+	}
+	labels := doc.Status.Labels
+	if labels["io.kubernetes.pod.name"] == "" {
+		return podContainerInfo{}, fmt.Errorf("not a pod container")
+	}
+	return podContainerInfo{
+		namespace: labels["io.kubernetes.pod.namespace"],
+		pod:       labels["io.kubernetes.pod.name"],
+		name:      labels["io.kubernetes.container.name"],
+		image:     doc.Status.Image.Image,
+	}, nil
+}
@@ -2,17 +2,24 @@ package enricher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/setevik/logtriage/internal/event"
 	"github.com/setevik/logtriage/internal/format"
 )
 
 // enrichCrash adds coredump context to a crash event by querying coredumpctl.
-func enrichCrash(ctx context.Context, ev *event.Event) {
+// With opts.Debugger, the backtrace shown comes from gdb instead, which
+// resolves symbols and source lines that the journal's stack trace lacks.
+func enrichCrash(ctx context.Context, ev *event.Event, opts Options) {
 	if ev.PID == 0 {
 		return
 	}
@@ -36,26 +43,69 @@ func enrichCrash(ctx context.Context, ev *event.Event) {
 	if info.CoredumpSize > 0 {
 		fmt.Fprintf(&detail, "Coredump saved (%s).\n", format.Bytes(info.CoredumpSize))
 	}
+	if info.Package != "" {
+		fmt.Fprintf(&detail, "Package: %s\n", info.Package)
+	}
+	if info.FaultingModule != "" {
+		fmt.Fprintf(&detail, "Faulting module: %s", info.FaultingModule)
+		if pkg := info.ModulePackages[info.FaultingModule]; pkg != "" {
+			fmt.Fprintf(&detail, " (%s)", pkg)
+		}
+		detail.WriteString("\n")
+	}
 
-	if len(info.Backtrace) > 0 {
-		detail.WriteString("\nTop backtrace frames:\n")
-		limit := 5
-		if len(info.Backtrace) < limit {
-			limit = len(info.Backtrace)
+	var gdbFrames []string
+	if opts.Debugger {
+		frames, err := debuggerBacktrace(ctx, ev.PID, opts.DebuggerTimeout)
+		if err != nil {
+			slog.Debug("crash enrichment: coredumpctl debug failed", "pid", ev.PID, "error", err)
+		}
+		gdbFrames = frames
+	}
+
+	if len(gdbFrames) > 0 {
+		detail.WriteString("\nBacktrace (gdb):\n")
+		for i, frame := range gdbFrames[:min(backtraceFrames, len(gdbFrames))] {
+			fmt.Fprintf(&detail, "  #%d %s\n", i, frame)
 		}
-		for i, frame := range info.Backtrace[:limit] {
+	} else if len(info.Backtrace) > 0 {
+		detail.WriteString("\nTop backtrace frames:\n")
+		for i, frame := range info.Backtrace[:min(backtraceFrames, len(info.Backtrace))] {
 			fmt.Fprintf(&detail, "  #%d %s\n", i, frame)
 		}
 	}
 
 	ev.Detail = detail.String()
+	ev.RawFields["_crash_signature"] = CrashSignature(info.Executable, info.Signal, info.Backtrace)
+
+	// Events from the kernel's segfault line lack the process metadata
+	// systemd-coredump's own entry carries; see enrichProcess.
+	for k, v := range info.Metadata {
+		if ev.RawFields[k] == "" {
+			ev.RawFields[k] = v
+		}
+	}
 }
 
+// backtraceFrames is how many frames of a backtrace the detail shows.
+const backtraceFrames = 5
+
 type coredumpInfo struct {
 	Signal       string
 	Executable   string
 	CoredumpSize int64
 	Backtrace    []string
+
+	// From the text report: the executable's package, e.g.
+	// "coreutils/9.1-7.fc37", and the module the crash happened in with
+	// the packages of the modules systemd could attribute.
+	Package        string
+	FaultingModule string
+	ModulePackages map[string]string
+
+	// Metadata holds the crashed process's command line, uid, and unit,
+	// as the COREDUMP_* journal fields naming them.
+	Metadata map[string]string
 }
 
 // getCoredumpInfo queries coredumpctl for crash details about a given PID.
@@ -97,6 +147,185 @@ func getCoredumpInfo(ctx context.Context, pid int) (*coredumpInfo, error) {
 		info.CoredumpSize = int64(size)
 	}
 
+	info.Metadata = make(map[string]string)
+	for _, key := range []string{"COREDUMP_CMDLINE", "COREDUMP_EXE", "COREDUMP_UID", "COREDUMP_UNIT", "COREDUMP_USER_UNIT"} {
+		if v, ok := entry[key].(string); ok && v != "" {
+			info.Metadata[key] = v
+		}
+	}
+
+	// The JSON output has no stack or package metadata; the text report
+	// does.
+	if text, err := runCommand(ctx, "coredumpctl", "info", fmt.Sprintf("%d", pid), "--no-pager"); err == nil {
+		frames := parseStackFrames(string(text))
+		for _, f := range frames {
+			info.Backtrace = append(info.Backtrace, f.String())
+		}
+		info.FaultingModule = faultingModule(frames)
+		info.Package, info.ModulePackages = parsePackages(string(text))
+	}
+
 	return info, nil
 }
 
+// stackFrameRe matches one frame of a coredumpctl stack trace.
+// Example: "#0  0x00007f3a1c2a89fc raise (libc.so.6 + 0x3e9fc)"
+var stackFrameRe = regexp.MustCompile(`^#\d+\s+0x[0-9a-f]+\s+(\S+)\s+\((\S+)(?:\s+\+\s+(0x[0-9a-f]+))?\)`)
+
+// stackFrame is one frame of a coredumpctl stack trace.
+type stackFrame struct {
+	fn     string // function, or "n/a" when unsymbolized
+	module string // executable or library, e.g. "libc.so.6"
+	offset string // offset within the module, e.g. "0x3e9fc"
+}
+
+// String returns the frame without its address, e.g. "raise (libc.so.6)",
+// or with the module offset when unsymbolized, e.g.
+// "n/a (/usr/bin/app + 0x1234)", since that is what identifies the code.
+func (f stackFrame) String() string {
+	if f.fn == "n/a" && f.offset != "" {
+		return fmt.Sprintf("n/a (%s + %s)", f.module, f.offset)
+	}
+	return fmt.Sprintf("%s (%s)", f.fn, f.module)
+}
+
+// parseStackTrace returns the frames of the first (crashing) thread's
+// stack in a coredumpctl info report, without addresses, e.g.
+// "raise (libc.so.6)" or "n/a (/usr/bin/app + 0x1234)".
+func parseStackTrace(report string) []string {
+	var frames []string
+	for _, f := range parseStackFrames(report) {
+		frames = append(frames, f.String())
+	}
+	return frames
+}
+
+// parseStackFrames returns the frames of the first (crashing) thread's
+// stack in a coredumpctl info report.
+func parseStackFrames(report string) []stackFrame {
+	var frames []stackFrame
+	inStack := false
+	for _, line := range strings.Split(report, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Stack trace of thread") {
+			if inStack {
+				break
+			}
+			inStack = true
+			continue
+		}
+		if !inStack {
+			continue
+		}
+		m := stackFrameRe.FindStringSubmatch(line)
+		if m == nil {
+			if len(frames) > 0 {
+				break
+			}
+			continue
+		}
+		frames = append(frames, stackFrame{fn: m[1], module: m[2], offset: m[3]})
+	}
+	return frames
+}
+
+// abortFrames are the functions a crashing thread passes through after the
+// fault, raising or reporting the signal, rather than where it happened.
+var abortFrames = map[string]bool{
+	"raise": true, "__GI_raise": true, "gsignal": true,
+	"abort": true, "__GI_abort": true,
+	"pthread_kill": true, "__pthread_kill": true,
+	"__pthread_kill_implementation": true, "__pthread_kill_internal": true,
+	"__assert_fail": true, "__assert_fail_base": true,
+	"__libc_message": true, "__libc_message_impl": true, "malloc_printerr": true,
+	"__fortify_fail": true, "__chk_fail": true, "__stack_chk_fail": true,
+}
+
+// faultingModule returns the module of the topmost frame that is not
+// signal or abort machinery, e.g. the library that called abort() or
+// dereferenced a bad pointer.
+func faultingModule(frames []stackFrame) string {
+	for _, f := range frames {
+		if !abortFrames[f.fn] {
+			return f.module
+		}
+	}
+	return ""
+}
+
+// packageRe matches the executable's package in a coredumpctl info report.
+// Example: "Package: coreutils/9.1-7.fc37"
+var packageRe = regexp.MustCompile(`^Package: (\S+)$`)
+
+// modulePackageRe matches a module's package in a coredumpctl info report,
+// taken from the package metadata (COREDUMP_PACKAGE_JSON) of its ELF notes.
+// Example: "Module libc.so.6 from rpm glibc-2.36-9.fc37.x86_64"
+var modulePackageRe = regexp.MustCompile(`^Module (\S+) from (\S+ \S+)$`)
+
+// parsePackages returns the executable's package and the packages of the
+// modules listed in a coredumpctl info report, keyed by module as stack
+// frames name it.
+func parsePackages(report string) (string, map[string]string) {
+	var pkg string
+	modules := make(map[string]string)
+	for _, line := range strings.Split(report, "\n") {
+		line = strings.TrimSpace(line)
+		if m := packageRe.FindStringSubmatch(line); m != nil {
+			pkg = m[1]
+		} else if m := modulePackageRe.FindStringSubmatch(line); m != nil {
+			modules[m[1]] = m[2]
+		}
+	}
+	return pkg, modules
+}
+
+// debuggerBacktrace runs gdb on the coredump of pid in batch mode and
+// returns the crashing thread's backtrace, e.g. "main (app.c:12)". gdb can
+// take long loading debug info, so it gets its own timeout.
+func debuggerBacktrace(ctx context.Context, pid int, timeout time.Duration) ([]string, error) {
+	out, err := runCommandTimeout(ctx, timeout, "coredumpctl", "debug", fmt.Sprintf("%d", pid),
+		"--no-pager", "--debugger=gdb", "--debugger-arguments=-batch -ex bt")
+	if err != nil {
+		return nil, err
+	}
+	return parseGDBBacktrace(string(out)), nil
+}
+
+// gdbFrameRe matches one frame of a gdb backtrace: the function, then the
+// source location or the library it is from, when known.
+// Example: "#3  0x000055d1a0a1b2c3 in main (argc=1, argv=0x7ffc) at app.c:12"
+var gdbFrameRe = regexp.MustCompile(`^#\d+\s+(?:0x[0-9a-f]+ in )?(\S+) \(.*\)(?: (?:at|from) (\S+))?$`)
+
+// parseGDBBacktrace returns the frames of a gdb backtrace without
+// addresses or arguments, e.g. "main (app.c:12)" or "raise (libc.so.6)".
+func parseGDBBacktrace(out string) []string {
+	var frames []string
+	for _, line := range strings.Split(out, "\n") {
+		m := gdbFrameRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if m[2] == "" {
+			frames = append(frames, m[1])
+		} else {
+			frames = append(frames, fmt.Sprintf("%s (%s)", m[1], filepath.Base(m[2])))
+		}
+	}
+	return frames
+}
+
+// signatureFrames is how many top frames identify a crash.
+const signatureFrames = 5
+
+// CrashSignature identifies a crash by the executable, the signal, and the
+// top frames of the crashing thread, so repeats of the same bug share it
+// across process IDs and restarts.
+func CrashSignature(exe, signal string, frames []string) string {
+	if len(frames) > signatureFrames {
+		frames = frames[:signatureFrames]
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s", exe, signal, strings.Join(frames, "\n"))
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
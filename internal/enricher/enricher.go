@@ -3,34 +3,89 @@ package enricher
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/setevik/logtriage/internal/event"
 )
 
+// Options enable enrichment that is slower or needs extra tools.
+type Options struct {
+	// Debugger runs gdb on a crash's coredump, via coredumpctl debug, for
+	// a symbolized backtrace, giving up after DebuggerTimeout.
+	Debugger        bool
+	DebuggerTimeout time.Duration
+
+	// Audit names where audit records are read, "journal" or "ausearch",
+	// to add the access denials before a service failure; "" adds none.
+	Audit string
+
+	// Replay marks events from the past, e.g. of logtriage replay, for
+	// which what runs now under a PID says nothing.
+	Replay bool
+
+	// UnitInfo adds a failed unit's ExecStart command and Restart= policy,
+	// and UnitStatus its systemctl status, to service failures.
+	UnitInfo   bool
+	UnitStatus bool
+}
+
 // Enricher adds context to classified events via subprocess queries.
-type Enricher struct{}
+type Enricher struct {
+	mu   sync.Mutex // guards opts, which SetOptions changes
+	opts Options
+}
 
 // New creates a new Enricher.
-func New() *Enricher {
-	return &Enricher{}
+func New(opts Options) *Enricher {
+	return &Enricher{opts: opts}
+}
+
+// SetOptions replaces the options of an enricher in use, e.g. on a config
+// reload.
+func (e *Enricher) SetOptions(opts Options) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.opts = opts
 }
 
 // Enrich adds detailed context to an event based on its tier.
 // This may spawn short-lived subprocesses (journalctl, coredumpctl) to
 // gather additional information.
 func (e *Enricher) Enrich(ctx context.Context, ev *event.Event) {
+	e.mu.Lock()
+	opts := e.opts
+	e.mu.Unlock()
+
 	switch ev.Tier {
 	case event.TierOOMKill:
+		// /proc is read first, in case the process is still there.
+		var live processInfo
+		if !opts.Replay {
+			live = readProcess(ev)
+		}
 		enrichOOM(ctx, ev)
+		enrichProcess(ctx, ev, live)
 	case event.TierProcessCrash:
-		enrichCrash(ctx, ev)
+		var live processInfo
+		if !opts.Replay {
+			live = readProcess(ev)
+		}
+		enrichCrash(ctx, ev, opts)
 		// Also check if this is a compositor crash (possibly GPU-related).
 		enrichCompositorCrash(ctx, ev)
+		enrichProcess(ctx, ev, live)
 	case event.TierServiceFailure:
-		enrichService(ctx, ev)
+		enrichService(ctx, ev, opts)
+		if opts.Audit != "" {
+			enrichDenials(ctx, ev, opts.Audit)
+		}
 	case event.TierKernelHW:
-		// Use GPU-specific enrichment for GPU events, disk enrichment otherwise.
-		if ev.RawFields["_gpu_event"] == "true" {
+		// Kernel reports get their full text, GPU events GPU-specific
+		// enrichment, and the rest disk enrichment.
+		if ev.RawFields["_kernel_oops"] == "true" {
+			enrichKernelOops(ctx, ev)
+		} else if ev.RawFields["_gpu_event"] == "true" {
 			enrichGPU(ctx, ev)
 		} else {
 			enrichKernelHW(ctx, ev)
@@ -38,4 +93,8 @@ func (e *Enricher) Enrich(ctx context.Context, ev *event.Event) {
 	default:
 		slog.Debug("no enrichment available for tier", "tier", ev.Tier)
 	}
+
+	if ev.ContainerID != "" {
+		enrichContainer(ctx, ev)
+	}
 }
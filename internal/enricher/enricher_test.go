@@ -1,7 +1,14 @@
 package enricher
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
 )
 
 func TestParseOOMTable(t *testing.T) {
@@ -75,3 +82,279 @@ func TestParseOOMTableLine(t *testing.T) {
 		}
 	}
 }
+
+func TestParseContainerInspect(t *testing.T) {
+	info, err := parseContainerInspect("/web\nnginx:latest\nexited\n137\ntrue\n3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.name != "web" || info.image != "nginx:latest" || info.exitCode != "137" || !info.oomKilled || info.restarts != "3" {
+		t.Errorf("info = %+v", info)
+	}
+	if _, err := parseContainerInspect("Error: no such container\n"); err == nil {
+		t.Error("expected an error for short output")
+	}
+}
+
+func TestParsePodInspect(t *testing.T) {
+	out := `{"status": {"labels": {"io.kubernetes.pod.name": "web", "io.kubernetes.pod.namespace": "default",
+  "io.kubernetes.container.name": "app"}, "image": {"image": "docker.io/library/app:1.2"}}, "info": {}}`
+	info, err := parsePodInspect([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.namespace != "default" || info.pod != "web" || info.name != "app" || info.image != "docker.io/library/app:1.2" {
+		t.Errorf("info = %+v", info)
+	}
+	if _, err := parsePodInspect([]byte(`{"status": {"labels": {}}}`)); err == nil {
+		t.Error("container without pod labels accepted")
+	}
+}
+
+func TestParseStackTrace(t *testing.T) {
+	report := `           PID: 4242 (app)
+        Signal: 11 (SEGV)
+                Stack trace of thread 4242:
+                #0  0x00007f3a1c2a89fc raise (libc.so.6 + 0x3e9fc)
+                #1  0x000055d1c0a01234 n/a (/usr/bin/app + 0x1234)
+                #2  0x000055d1c0a05678 main (/usr/bin/app + 0x5678)
+                ELF object binary architecture: AMD x86-64
+
+                Stack trace of thread 4243:
+                #0  0x00007f3a1c2f0000 poll (libc.so.6 + 0x100000)
+`
+	frames := parseStackTrace(report)
+	want := []string{"raise (libc.so.6)", "n/a (/usr/bin/app + 0x1234)", "main (/usr/bin/app)"}
+	if len(frames) != len(want) {
+		t.Fatalf("frames = %q, want %q", frames, want)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Errorf("frame %d = %q, want %q", i, frames[i], want[i])
+		}
+	}
+
+	sig := CrashSignature("/usr/bin/app", "SIGSEGV", frames)
+	if sig != CrashSignature("/usr/bin/app", "SIGSEGV", frames) || len(sig) != 12 {
+		t.Errorf("signature %q is not stable", sig)
+	}
+	if sig == CrashSignature("/usr/bin/app", "SIGABRT", frames) {
+		t.Error("different signals should give different signatures")
+	}
+}
+
+func TestParseCrashModules(t *testing.T) {
+	report := `           PID: 4242 (app)
+        Signal: 6 (ABRT)
+       Package: app/1.4-2.fc39
+                Module /usr/bin/app from rpm app-1.4-2.fc39.x86_64
+                Module libc.so.6 from rpm glibc-2.38-16.fc39.x86_64
+                Module libfoo.so.1 from rpm foo-libs-0.9-1.fc39.x86_64
+                Stack trace of thread 4242:
+                #0  0x00007f3a1c2a89fc __pthread_kill_implementation (libc.so.6 + 0x8d9fc)
+                #1  0x00007f3a1c25a476 raise (libc.so.6 + 0x3c476)
+                #2  0x00007f3a1c2407f3 abort (libc.so.6 + 0x227f3)
+                #3  0x00007f3a1d001234 foo_check (libfoo.so.1 + 0x1234)
+                #4  0x000055d1c0a05678 main (/usr/bin/app + 0x5678)
+`
+	frames := parseStackFrames(report)
+	if got := faultingModule(frames); got != "libfoo.so.1" {
+		t.Errorf("faultingModule = %q, want libfoo.so.1", got)
+	}
+	pkg, modules := parsePackages(report)
+	if pkg != "app/1.4-2.fc39" {
+		t.Errorf("package = %q", pkg)
+	}
+	if modules["libfoo.so.1"] != "rpm foo-libs-0.9-1.fc39.x86_64" || len(modules) != 3 {
+		t.Errorf("module packages = %v", modules)
+	}
+}
+
+func TestParseGDBBacktrace(t *testing.T) {
+	out := `[New LWP 4242]
+Core was generated by '/usr/bin/app'.
+Program terminated with signal SIGSEGV, Segmentation fault.
+#0  0x000055d1c0a01234 in parse_header (buf=0x0, len=12) at src/parse.c:88
+#1  0x00007f3a1d001234 in foo_read () from /lib64/libfoo.so.1
+#2  0x000055d1c0a05678 in main (argc=1, argv=0x7ffc12345678) at src/main.c:12
+#3  0x00007f3a1c2a89fc in ?? ()
+`
+	frames := parseGDBBacktrace(out)
+	want := []string{"parse_header (parse.c:88)", "foo_read (libfoo.so.1)", "main (main.c:12)", "??"}
+	if len(frames) != len(want) {
+		t.Fatalf("frames = %q, want %q", frames, want)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Errorf("frame %d = %q, want %q", i, frames[i], want[i])
+		}
+	}
+}
+
+func TestReconstructOops(t *testing.T) {
+	first := "BUG: kernel NULL pointer dereference, address: 0000000000000008"
+	lines := []string{
+		"usb 1-1: new high-speed USB device number 5", // before the report, without a cursor
+		first,
+		"#PF: supervisor read access in kernel mode",
+		"Oops: 0000 [#1] PREEMPT SMP NOPTI",
+		"CPU: 2 PID: 1234 Comm: kworker/2:1 Tainted: G        W          6.6.8 #1",
+		"RIP: 0010:foo_work+0x12/0x40 [foo]",
+		"Call Trace:",
+		" <TASK>",
+		" process_one_work+0x171/0x340",
+		" </TASK>",
+		"---[ end trace 0000000000000000 ]---",
+		"usb 1-1: USB disconnect, device number 5",
+	}
+	report, complete := reconstructOops(first, lines)
+	if !complete || len(report) != 10 || report[0] != first || report[9] != "---[ end trace 0000000000000000 ]---" {
+		t.Errorf("report = %q, complete = %v", report, complete)
+	}
+
+	// Cut short: the rest has not been logged yet.
+	if _, complete := reconstructOops(first, lines[2:5]); complete {
+		t.Error("report without its end marker is complete")
+	}
+
+	// Another report starts before the end marker.
+	warn := "WARNING: CPU: 3 PID: 1234 at drivers/foo.c:12 foo+0x1/0x2"
+	report, complete = reconstructOops(first, []string{lines[2], warn, lines[3]})
+	if !complete || len(report) != 2 {
+		t.Errorf("report = %q, complete = %v", report, complete)
+	}
+}
+
+func TestDenialsBetween(t *testing.T) {
+	entries := watcher.ParseAuditLog([]byte(
+		`type=AVC msg=audit(1708299000.000:100): avc:  denied  { write } for  pid=1234 comm="nginx" name="old" scontext=system_u:system_r:httpd_t:s0 tcontext=system_u:object_r:var_t:s0 tclass=file permissive=0
+type=AVC msg=audit(1708300000.000:200): avc:  denied  { read } for  pid=1234 comm="nginx" name="index.html" scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=0
+type=AVC msg=audit(1708300001.000:201): avc:  denied  { read } for  pid=1234 comm="nginx" name="index.html" scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=1
+type=SECCOMP msg=audit(1708300002.000:202): pid=4242 comm="php-fpm" sig=31 arch=c000003e syscall=59
+`))
+	failed := time.Unix(1708300010, 0)
+
+	denials := denialsBetween(entries, failed.Add(-denialLookback), failed)
+	if len(denials) != 1 {
+		t.Fatalf("got %d denials, want 1: %+v", len(denials), denials)
+	}
+	d := denials[0]
+	if d.Process != "nginx" || d.PID != 1234 || d.Summary != "SELinux denied nginx read on file index.html" {
+		t.Errorf("denial = %+v", d)
+	}
+}
+
+func TestParseUnitInfo(t *testing.T) {
+	out := "Restart=on-failure\n" +
+		"ExecStart={ path=/usr/bin/nginx ; argv[]=/usr/bin/nginx -g daemon off; ; ignore_errors=no ; start_time=[n/a] ; stop_time=[n/a] ; pid=0 ; code=(null) ; status=0/0 }\n"
+	info := parseUnitInfo(out)
+	if info.restart != "on-failure" {
+		t.Errorf("restart = %q, want on-failure", info.restart)
+	}
+	if len(info.commands) != 1 || info.commands[0] != "/usr/bin/nginx -g daemon off;" {
+		t.Errorf("commands = %q", info.commands)
+	}
+
+	if info := parseUnitInfo("Restart=no\nExecStart=\n"); info.restart != "no" || len(info.commands) != 0 {
+		t.Errorf("unit without ExecStart: %+v", info)
+	}
+}
+
+func TestReadProcess(t *testing.T) {
+	defer func(dir string) { procDir = dir }(procDir)
+	procDir = t.TempDir()
+	dir := filepath.Join(procDir, "4521")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Booted at 1700000000, started 120.5s later.
+	files := map[string]string{
+		"comm":    "python3\n",
+		"stat":    "4521 (python3) S 1 4521 4521 0 -1 4194560 500 0 0 0 10 5 0 0 20 0 1 0 12050 10000000 300 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0\n",
+		"cmdline": "python3\x00/opt/jobs/etl.py\x00--full\x00",
+		"status":  "Name:\tpython3\nUid:\t1000\t1000\t1000\t1000\nGid:\t1000\t1000\t1000\t1000\n",
+		"cgroup":  "0::/system.slice/etl.service\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(procDir, "stat"), []byte("cpu  1 2 3 4\nbtime 1700000000\nprocesses 900\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/bin/python3.12 (deleted)", filepath.Join(dir, "exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Unix(1700000120, 500_000_000)
+	ev := &event.Event{PID: 4521, Process: "python3", Timestamp: started.Add(time.Minute)}
+	got := readProcess(ev)
+	want := processInfo{cmdline: "python3 /opt/jobs/etl.py --full", exe: "/usr/bin/python3.12", uid: "1000", unit: "etl.service"}
+	if got != want {
+		t.Errorf("readProcess = %+v, want %+v", got, want)
+	}
+
+	for name, ev := range map[string]*event.Event{
+		"exited":          {PID: 4522, Process: "python3", Timestamp: ev.Timestamp},
+		"other command":   {PID: 4521, Process: "node", Timestamp: ev.Timestamp},
+		"started later":   {PID: 4521, Process: "python3", Timestamp: started.Add(-time.Second)},
+		"no process name": {PID: 4521, Timestamp: ev.Timestamp},
+	} {
+		if got := readProcess(ev); got != (processInfo{}) {
+			t.Errorf("%s: readProcess = %+v, want nothing", name, got)
+		}
+	}
+}
+
+func TestSameComm(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"python3", "python3", true},
+		{"python3", "python", false},
+		{"gnome-shell-cal", "gnome-shell-calendar-server", true},
+		{"gnome-shell-calendar-server", "gnome-shell-cal", true},
+		{"gnome-shell-cal", "gnome-terminal-server", false},
+		{"chrome", "chromedriver", false},
+	} {
+		if got := sameComm(tc.a, tc.b); got != tc.want {
+			t.Errorf("sameComm(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestParseJournalProcess(t *testing.T) {
+	out := []byte(`{"_COMM":"python3","_CMDLINE":"python3 /opt/jobs/etl.py","_EXE":"/usr/bin/python3.12","_UID":"1000","_SYSTEMD_UNIT":"user@1000.service","_SYSTEMD_USER_UNIT":"etl.service"}`)
+	got := parseJournalProcess(out, "python3")
+	want := processInfo{cmdline: "python3 /opt/jobs/etl.py", exe: "/usr/bin/python3.12", uid: "1000", unit: "etl.service"}
+	if got != want {
+		t.Errorf("parseJournalProcess = %+v, want %+v", got, want)
+	}
+	if got := parseJournalProcess(out, "rsync"); got != (processInfo{}) {
+		t.Errorf("entry of another process = %+v, want nothing", got)
+	}
+}
+
+func TestEnrichProcess(t *testing.T) {
+	ev := event.New("nas", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: python3 (pid 4521)")
+	ev.PID = 4521
+	ev.Detail = "python3 was killed by OOM killer.\n\nTop memory consumers at time of kill:\n  1. python3 80000 pages (killed)\n"
+	ev.RawFields["COREDUMP_UID"] = "4242"
+
+	enrichProcess(context.Background(), ev, processInfo{cmdline: "python3 /opt/jobs/etl.py", exe: "/usr/bin/python3.12", unit: "etl.service"})
+
+	want := "python3 was killed by OOM killer.\n" +
+		"Command: python3 /opt/jobs/etl.py\n" +
+		"Executable: /usr/bin/python3.12\n" +
+		"User: uid 4242\n" +
+		"Unit: etl.service\n" +
+		"\nTop memory consumers at time of kill:\n  1. python3 80000 pages (killed)\n"
+	if ev.Detail != want {
+		t.Errorf("detail = %q, want %q", ev.Detail, want)
+	}
+	if ev.Unit != "etl.service" || ev.RawFields["_proc_cmdline"] != "python3 /opt/jobs/etl.py" {
+		t.Errorf("unit %q, raw fields %v", ev.Unit, ev.RawFields)
+	}
+}
@@ -23,8 +23,9 @@ func enrichGPU(ctx context.Context, ev *event.Event) {
 		gpu := &gpus[i]
 		monitor.ReadGPUTemp(gpu)
 		monitor.ReadGPUVRAM(gpu)
+		monitor.ReadGPUFreq(gpu)
 
-		if gpu.Temperature > 0 || gpu.VRAMTotal > 0 {
+		if gpu.Temperature > 0 || gpu.VRAMTotal > 0 || gpu.FreqMHz > 0 {
 			detail.WriteString(monitor.FormatGPUStatus(*gpu))
 		}
 	}
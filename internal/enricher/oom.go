@@ -28,6 +28,13 @@ func enrichOOM(ctx context.Context, ev *event.Event) {
 	if ev.Process != "" {
 		fmt.Fprintf(&detail, "%s was killed by OOM killer.\n", ev.Process)
 	}
+	if ev.CGroup != "" {
+		fmt.Fprintf(&detail, "Memory cgroup: %s\n", ev.CGroup)
+		// A limit on a parent slice can kill a task in a child cgroup.
+		if limit := ev.RawFields["_oom_memcg"]; limit != "" {
+			fmt.Fprintf(&detail, "Limit reached in: %s\n", limit)
+		}
+	}
 
 	// Parse the OOM killer's process table for top memory consumers.
 	consumers := parseOOMTable(lines)
@@ -0,0 +1,133 @@
+package enricher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// oopsMaxLines bounds the kernel report kept in an event's detail.
+const oopsMaxLines = 60
+
+// oopsSettle is how long to wait for the rest of a kernel report to reach
+// the journal when the first query finds it incomplete. The kernel prints
+// a report in one burst, but the event may be handled before journald has
+// stored all of it.
+const oopsSettle = time.Second
+
+// oopsEndRe matches the last line of a kernel report.
+// Example: "---[ end trace 0000000000000000 ]---"
+var oopsEndRe = regexp.MustCompile(`^---\[ end (?:trace|Kernel panic)`)
+
+// oopsCommRe extracts the task that was running from a kernel report.
+// Example: "CPU: 2 PID: 1234 Comm: kworker/2:1 Tainted: G        W  6.6.8"
+var oopsCommRe = regexp.MustCompile(`^CPU: \d+ (?:UID: \d+ )?PID: \d+ Comm: (\S+)`)
+
+// enrichKernelOops replaces the detail of a kernel BUG, oops, warning, or
+// general protection fault with the whole report the kernel printed: the
+// registers, modules, and call trace lines that follow the one that was
+// classified.
+func enrichKernelOops(ctx context.Context, ev *event.Event) {
+	first := strings.TrimSpace(ev.RawFields["MESSAGE"])
+	if first == "" {
+		return
+	}
+
+	var report []string
+	for attempt := 0; ; attempt++ {
+		lines, err := kernelLinesAfter(ctx, ev)
+		if err != nil {
+			slog.Debug("kernel oops enrichment: journalctl query failed", "error", err)
+			return
+		}
+		var complete bool
+		report, complete = reconstructOops(first, lines)
+		if complete || attempt > 0 {
+			break
+		}
+		select {
+		case <-time.After(oopsSettle):
+		case <-ctx.Done():
+			return
+		}
+	}
+	if len(report) <= 1 {
+		return
+	}
+
+	for _, line := range report {
+		if m := oopsCommRe.FindStringSubmatch(line); m != nil {
+			ev.RawFields["_oops_comm"] = m[1]
+			break
+		}
+	}
+
+	var detail strings.Builder
+	if comm := ev.RawFields["_oops_comm"]; comm != "" {
+		fmt.Fprintf(&detail, "Running task: %s\n\n", comm)
+	}
+	detail.WriteString("Kernel report:\n")
+	for _, line := range report {
+		detail.WriteString("  " + line + "\n")
+	}
+	ev.Detail = strings.TrimRight(detail.String(), "\n")
+}
+
+// kernelLinesAfter returns the kernel messages logged after ev's journal
+// entry, up to a few seconds after it, or from its second on when its
+// cursor is unknown.
+func kernelLinesAfter(ctx context.Context, ev *event.Event) ([]string, error) {
+	args := []string{"_TRANSPORT=kernel", "-o", "cat", "--no-pager",
+		"--until", fmt.Sprintf("@%d", ev.Timestamp.Add(10*time.Second).Unix())}
+	if cursor := ev.RawFields["__CURSOR"]; cursor != "" {
+		args = append(args, "--after-cursor", cursor)
+	} else {
+		args = append(args, "--since", fmt.Sprintf("@%d", ev.Timestamp.Unix()))
+	}
+	out, err := runCommand(ctx, "journalctl", args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(out), "\n"), "\n"), nil
+}
+
+// reconstructOops returns a kernel report: its first line followed by the
+// lines after it in the log, up to and including its "end trace" marker.
+// complete is false when the marker was not found, e.g. because the rest of
+// the report had not been logged yet. Lines before first in lines, as
+// returned when the entry's cursor is unknown, are skipped. The report is
+// cut at oopsMaxLines, and ends at the start of another report.
+func reconstructOops(first string, lines []string) (report []string, complete bool) {
+	for i, line := range lines {
+		if strings.TrimSpace(line) == first {
+			lines = lines[i+1:]
+			break
+		}
+	}
+
+	report = []string{first}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			continue
+		}
+		if classifier.IsKernelReportStart(line) {
+			return report, true
+		}
+		report = append(report, line)
+		if oopsEndRe.MatchString(line) {
+			return report, true
+		}
+		if len(report) == oopsMaxLines {
+			report = append(report, "...")
+			return report, true
+		}
+	}
+	return report, false
+}
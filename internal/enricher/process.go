@@ -0,0 +1,278 @@
+package enricher
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/classifier"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// procDir is where live processes are read from; tests point it elsewhere.
+var procDir = "/proc"
+
+// processInfo identifies the process an event is about beyond its name:
+// what it ran, as whom, and under which unit, so an OOM kill of "python3"
+// says which script it was.
+type processInfo struct {
+	cmdline string
+	exe     string
+	uid     string
+	unit    string
+}
+
+// complete reports whether every field is known.
+func (p processInfo) complete() bool {
+	return p.cmdline != "" && p.exe != "" && p.uid != "" && p.unit != ""
+}
+
+// merge fills p's unknown fields from o.
+func (p *processInfo) merge(o processInfo) {
+	p.cmdline = cmp.Or(p.cmdline, o.cmdline)
+	p.exe = cmp.Or(p.exe, o.exe)
+	p.uid = cmp.Or(p.uid, o.uid)
+	p.unit = cmp.Or(p.unit, o.unit)
+}
+
+// enrichProcess adds the command line, executable, owner, and unit of an
+// OOM-killed or crashed process to its event. live is what /proc showed
+// when enrichment began (see readProcess), if anything, as the process is
+// usually gone by now; what it
+// lacks comes from systemd-coredump's metadata, then from the last journal
+// entry the process logged. The lines go after the first paragraph of the
+// detail, and the unit fills in the event's if it has none.
+func enrichProcess(ctx context.Context, ev *event.Event, live processInfo) {
+	if ev.PID == 0 {
+		return
+	}
+	if ev.RawFields == nil {
+		ev.RawFields = make(map[string]string)
+	}
+	proc := live
+	proc.merge(coredumpProcess(ev.RawFields))
+	if !proc.complete() {
+		if logged, err := journalProcess(ctx, ev); err != nil {
+			slog.Debug("process enrichment: journal query failed", "pid", ev.PID, "error", err)
+		} else {
+			proc.merge(logged)
+		}
+	}
+
+	var lines strings.Builder
+	if proc.cmdline != "" {
+		fmt.Fprintf(&lines, "Command: %s\n", proc.cmdline)
+		ev.RawFields["_proc_cmdline"] = proc.cmdline
+	}
+	if proc.exe != "" {
+		fmt.Fprintf(&lines, "Executable: %s\n", proc.exe)
+		ev.RawFields["_proc_exe"] = proc.exe
+	}
+	if proc.uid != "" {
+		fmt.Fprintf(&lines, "User: %s\n", userName(proc.uid))
+		ev.RawFields["_proc_uid"] = proc.uid
+	}
+	if proc.unit != "" {
+		fmt.Fprintf(&lines, "Unit: %s\n", proc.unit)
+		ev.RawFields["_proc_unit"] = proc.unit
+		if ev.Unit == "" {
+			ev.Unit = proc.unit
+		}
+	}
+	if lines.Len() == 0 {
+		return
+	}
+
+	if ev.Detail == "" {
+		ev.Detail = lines.String()
+		return
+	}
+	head, rest, found := strings.Cut(ev.Detail, "\n\n")
+	head = strings.TrimRight(head, "\n") + "\n" + lines.String()
+	if found {
+		head += "\n" + rest
+	}
+	ev.Detail = head
+}
+
+// userName returns "name (uid N)" for a uid, or "uid N" if it has no name.
+func userName(uid string) string {
+	if u, err := user.LookupId(uid); err == nil && u.Username != "" {
+		return fmt.Sprintf("%s (uid %s)", u.Username, uid)
+	}
+	return "uid " + uid
+}
+
+// userHZ is the unit of the start time in /proc/<pid>/stat, fixed at 100
+// ticks a second for userspace on every architecture.
+const userHZ = 100
+
+// readProcess reads the information of the event's process from /proc, if
+// it still exists. As the PID may have been reused since, the process is
+// only taken for the event's if its command name matches and it started
+// before the event. Fields it cannot read, such as another user's
+// executable without root, are left empty.
+func readProcess(ev *event.Event) processInfo {
+	if ev.PID <= 0 || ev.Process == "" {
+		return processInfo{}
+	}
+	dir := filepath.Join(procDir, strconv.Itoa(ev.PID))
+
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil || !sameComm(strings.TrimSpace(string(comm)), ev.Process) {
+		return processInfo{}
+	}
+	started, err := processStart(dir)
+	if err != nil || started.After(ev.Timestamp) {
+		return processInfo{}
+	}
+
+	var p processInfo
+	if data, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		p.cmdline = strings.Join(strings.Fields(string(bytes.ReplaceAll(data, []byte{0}, []byte{' '}))), " ")
+	}
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		p.exe = strings.TrimSuffix(exe, " (deleted)")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if f := strings.Fields(rest); len(f) > 0 {
+					p.uid = f[0]
+				}
+				break
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		// The unified hierarchy's line is "0::<path>".
+		for _, line := range strings.Split(string(data), "\n") {
+			if path, ok := strings.CutPrefix(line, "0::"); ok {
+				p.unit, _ = classifier.CGroupOwner(path)
+				break
+			}
+		}
+	}
+	return p
+}
+
+// processStart returns when the process in dir, a /proc/<pid> directory,
+// started: the boot time from /proc/stat plus the start time in its stat,
+// field 22, counted in ticks since boot.
+func processStart(dir string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The command name in parentheses may hold spaces; fields are counted
+	// from the state after it, field 3.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("malformed %s/stat", dir)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("malformed %s/stat", dir)
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed %s/stat: %w", dir, err)
+	}
+
+	stat, err := os.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			boot, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			if err != nil {
+				break
+			}
+			return time.Unix(boot, 0).Add(time.Duration(ticks) * time.Second / userHZ), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no boot time in %s/stat", procDir)
+}
+
+// sameComm reports whether two command names are of the same process,
+// allowing for either being cut to the kernel's 15 bytes.
+func sameComm(a, b string) bool {
+	const commLen = 15
+	switch {
+	case a == b:
+		return true
+	case len(a) == commLen:
+		return strings.HasPrefix(b, a)
+	case len(b) == commLen:
+		return strings.HasPrefix(a, b)
+	}
+	return false
+}
+
+// coredumpProcess returns the process information systemd-coredump records
+// with a crash, from the fields of its journal entry or coredumpctl's
+// metadata. Other events have none.
+func coredumpProcess(fields map[string]string) processInfo {
+	return processInfo{
+		cmdline: fields["COREDUMP_CMDLINE"],
+		exe:     fields["COREDUMP_EXE"],
+		uid:     fields["COREDUMP_UID"],
+		unit:    cmp.Or(fields["COREDUMP_USER_UNIT"], fields["COREDUMP_UNIT"]),
+	}
+}
+
+// journalProcess returns the process information the journal attached to
+// the last entry the event's PID logged before the event, in the boot the
+// event is from: its _BOOT_ID, or the current boot for events from other
+// sources, such as eBPF tracing, which are live.
+func journalProcess(ctx context.Context, ev *event.Event) (processInfo, error) {
+	boot := "-b"
+	if id := ev.RawFields["_BOOT_ID"]; id != "" {
+		boot = "_BOOT_ID=" + id
+	}
+	out, err := runCommand(ctx, "journalctl",
+		"_PID="+strconv.Itoa(ev.PID),
+		boot,
+		"--until", ev.Timestamp.Add(time.Second).Local().Format("2006-01-02 15:04:05"),
+		"-n", "1",
+		"--no-pager",
+		"-o", "json",
+	)
+	if err != nil {
+		return processInfo{}, err
+	}
+	return parseJournalProcess(out, ev.Process), nil
+}
+
+// parseJournalProcess reads the trusted process fields of a journal entry
+// in JSON. An entry whose command name is not comm is from an earlier
+// process with the same PID and is ignored.
+func parseJournalProcess(out []byte, comm string) processInfo {
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(out), &entry); err != nil {
+		return processInfo{}
+	}
+	field := func(key string) string {
+		s, _ := entry[key].(string)
+		return s
+	}
+	if got := field("_COMM"); comm != "" && got != "" && !sameComm(got, comm) {
+		return processInfo{}
+	}
+	return processInfo{
+		cmdline: field("_CMDLINE"),
+		exe:     field("_EXE"),
+		uid:     field("_UID"),
+		unit:    cmp.Or(field("_SYSTEMD_USER_UNIT"), field("_SYSTEMD_UNIT")),
+	}
+}
@@ -7,13 +7,25 @@ import (
 	"fmt"
 	"os/exec"
 	"time"
+
+	"github.com/setevik/logtriage/internal/sysdep"
 )
 
 const queryTimeout = 10 * time.Second
 
 // runCommand executes a command with a timeout and returns its stdout.
 func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	return runCommandTimeout(ctx, queryTimeout, name, args...)
+}
+
+// runCommandTimeout is runCommand with a timeout other than queryTimeout,
+// for slower queries such as running a debugger.
+func runCommandTimeout(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	if !sysdep.Have(name) {
+		return nil, fmt.Errorf("%s not installed", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, name, args...)
@@ -26,3 +38,38 @@ func runCommand(ctx context.Context, name string, args ...string) ([]byte, error
 	}
 	return stdout.Bytes(), nil
 }
+
+// runCommandCombined is runCommand returning stdout and stderr together, for
+// commands that pass on another program's output, such as crictl logs.
+func runCommandCombined(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if !sysdep.Have(name) {
+		return nil, fmt.Errorf("%s not installed", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s %v: %w", name, args, err)
+	}
+	return out, nil
+}
+
+// runCommandOutput is runCommand returning stdout even when the command
+// fails, for commands whose exit status reports a state rather than an
+// error, such as systemctl status.
+func runCommandOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if !sysdep.Have(name) {
+		return nil, fmt.Errorf("%s not installed", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return out, fmt.Errorf("%s %v: %w", name, args, err)
+	}
+	return out, nil
+}
@@ -13,35 +13,88 @@ import (
 )
 
 // enrichService adds context to a service failure event by querying the
-// last journal entries for the failed unit.
-func enrichService(ctx context.Context, ev *event.Event) {
+// last journal entries for the failed unit and, as opts select, its start
+// command and restart policy and its systemctl status. Detail already set
+// by the classifier is kept and the rest is appended to it.
+func enrichService(ctx context.Context, ev *event.Event, opts Options) {
 	if ev.Unit == "" {
 		return
 	}
+	user := ev.RawFields["_user_unit"] != ""
 
-	lines, err := getUnitLogs(ctx, ev.Unit, 10)
-	if err != nil {
-		slog.Debug("service enrichment: failed to get unit logs", "unit", ev.Unit, "error", err)
-		return
+	if ev.RawFields["_restart_loop"] != "" && ev.RawFields["_n_restarts"] == "" {
+		if n, err := getRestartCount(ctx, ev.Unit, user); err != nil {
+			slog.Debug("service enrichment: failed to get restart count", "unit", ev.Unit, "error", err)
+		} else {
+			ev.RawFields["_n_restarts"] = n
+			ev.Detail = strings.Replace(ev.Detail, "\n", fmt.Sprintf("\nRestart count: %s\n", n), 1)
+		}
 	}
 
-	if len(lines) == 0 {
-		return
+	var detail strings.Builder
+	if ev.Detail != "" {
+		detail.WriteString(ev.Detail)
+	} else {
+		fmt.Fprintf(&detail, "%s failed.", ev.Unit)
 	}
+	enriched := false
 
-	var detail strings.Builder
-	fmt.Fprintf(&detail, "%s failed.\n\nLast log lines:\n", ev.Unit)
-	for _, line := range lines {
-		fmt.Fprintf(&detail, "  %s\n", line)
+	if opts.UnitInfo {
+		if info, err := getUnitInfo(ctx, ev.Unit, user); err != nil {
+			slog.Debug("service enrichment: failed to get unit info", "unit", ev.Unit, "error", err)
+		} else if len(info.commands) > 0 || info.restart != "" {
+			detail.WriteString("\n")
+			for _, cmd := range info.commands {
+				fmt.Fprintf(&detail, "\nCommand: %s", cmd)
+			}
+			if info.restart != "" {
+				fmt.Fprintf(&detail, "\nRestart policy: %s", info.restart)
+				ev.RawFields["_restart_policy"] = info.restart
+			}
+			if len(info.commands) > 0 {
+				ev.RawFields["_exec_start"] = strings.Join(info.commands, "\n")
+			}
+			enriched = true
+		}
+	}
+
+	if opts.UnitStatus {
+		if status, err := getUnitStatus(ctx, ev.Unit, user); err != nil {
+			slog.Debug("service enrichment: failed to get unit status", "unit", ev.Unit, "error", err)
+		} else if len(status) > 0 {
+			detail.WriteString("\n\nStatus:\n")
+			for _, line := range status {
+				fmt.Fprintf(&detail, "  %s\n", line)
+			}
+			enriched = true
+		}
 	}
 
-	ev.Detail = detail.String()
+	lines, err := getUnitLogs(ctx, ev.Unit, user, 10)
+	if err != nil {
+		slog.Debug("service enrichment: failed to get unit logs", "unit", ev.Unit, "error", err)
+	} else if len(lines) > 0 {
+		fmt.Fprintf(&detail, "\n\nLast log lines:\n")
+		for _, line := range lines {
+			fmt.Fprintf(&detail, "  %s\n", line)
+		}
+		enriched = true
+	}
+
+	if enriched {
+		ev.Detail = strings.TrimRight(detail.String(), "\n") + "\n"
+	}
 }
 
 // getUnitLogs fetches the last N log lines from a systemd unit via journalctl.
-func getUnitLogs(ctx context.Context, unit string, n int) ([]string, error) {
+// user selects a unit of the per-user service manager.
+func getUnitLogs(ctx context.Context, unit string, user bool, n int) ([]string, error) {
+	match := "-u"
+	if user {
+		match = "--user-unit"
+	}
 	out, err := runCommand(ctx, "journalctl",
-		"-u", unit,
+		match, unit,
 		"-n", fmt.Sprintf("%d", n),
 		"--no-pager",
 		"-o", "json",
@@ -63,3 +116,84 @@ func getUnitLogs(ctx context.Context, unit string, n int) ([]string, error) {
 	}
 	return lines, nil
 }
+
+// getRestartCount returns how many times systemd has automatically
+// restarted a unit since it was last started manually.
+func getRestartCount(ctx context.Context, unit string, user bool) (string, error) {
+	args := []string{"show", "-p", "NRestarts", "--value", unit}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := runCommand(ctx, "systemctl", args...)
+	if err != nil {
+		return "", err
+	}
+	n := strings.TrimSpace(string(out))
+	if n == "" {
+		return "", fmt.Errorf("no restart count reported for %s", unit)
+	}
+	return n, nil
+}
+
+// unitInfo is what a unit runs and what systemd does when it stops.
+type unitInfo struct {
+	commands []string // ExecStart command lines
+	restart  string   // Restart= policy, e.g. "on-failure"
+}
+
+// getUnitInfo reads a unit's ExecStart commands and Restart= policy.
+func getUnitInfo(ctx context.Context, unit string, user bool) (unitInfo, error) {
+	args := []string{"show", "-p", "ExecStart", "-p", "Restart", unit}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := runCommand(ctx, "systemctl", args...)
+	if err != nil {
+		return unitInfo{}, err
+	}
+	return parseUnitInfo(string(out)), nil
+}
+
+// parseUnitInfo parses systemctl show output for ExecStart and Restart.
+// Each ExecStart is shown as "{ path=... ; argv[]=<command line> ; ... }",
+// of which the command line is kept.
+func parseUnitInfo(out string) unitInfo {
+	var info unitInfo
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Restart":
+			info.restart = value
+		case "ExecStart":
+			for _, field := range strings.Split(value, " ; ") {
+				field = strings.Trim(field, "{} ")
+				if argv, ok := strings.CutPrefix(field, "argv[]="); ok && argv != "" {
+					info.commands = append(info.commands, argv)
+				}
+			}
+		}
+	}
+	return info
+}
+
+// getUnitStatus returns the header of systemctl status for a unit: its
+// state, result, and processes, without the log lines enrichService adds
+// itself. systemctl status exits non-zero for a unit that is not running,
+// which is the usual case here, so only a missing output is an error.
+func getUnitStatus(ctx context.Context, unit string, user bool) ([]string, error) {
+	args := []string{"status", "--no-pager", "--lines=0", "--full", unit}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := runCommandOutput(ctx, "systemctl", args...)
+	if len(bytes.TrimSpace(out)) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no status reported for %s", unit)
+		}
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(out), "\n"), "\n"), nil
+}
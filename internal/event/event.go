@@ -2,6 +2,7 @@
 package event
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,20 @@ const (
 	TierServiceFailure Tier = "T3"
 	TierKernelHW       Tier = "T4"
 	TierMemPressure    Tier = "T5"
+	TierResource       Tier = "T6"
+	TierReboot         Tier = "T7"
+
+	// TierSecurity holds security events: seccomp kills, access denials by
+	// SELinux or AppArmor, and repeated authentication failures.
+	TierSecurity Tier = "T9"
+
+	// TierInternal holds logtriage's own recurring failures, such as a
+	// sink that rejects every notification, raised by its self-monitor.
+	TierInternal Tier = "T0"
+
+	// TierUnclassified holds severe journal lines no pattern matched. They
+	// are stored to show gaps in pattern coverage but never alerted on.
+	TierUnclassified Tier = "T8"
 )
 
 // Severity indicates the urgency of an event.
@@ -29,18 +44,72 @@ const (
 )
 
 // Event represents a classified system event with enriched context.
+//
+// Its JSON form is what the hub protocol, webhooks, and the CLI's
+// --format=json emit: snake_case keys, RFC 3339 timestamps, and empty
+// optional fields omitted. Fields may be added but are never renamed.
 type Event struct {
-	ID         string
-	InstanceID string
-	Timestamp  time.Time
-	Tier       Tier
-	Severity   Severity
-	Summary    string
-	Process    string
-	PID        int
-	Unit       string
-	Detail     string
-	RawFields  map[string]string
+	ID         string            `json:"id"`
+	InstanceID string            `json:"instance_id"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Tier       Tier              `json:"tier"`
+	Severity   Severity          `json:"severity"`
+	Summary    string            `json:"summary"`
+	Process    string            `json:"process,omitempty"`
+	PID        int               `json:"pid,omitempty"`
+	Unit       string            `json:"unit,omitempty"`
+	Detail     string            `json:"detail,omitempty"`
+	RawFields  map[string]string `json:"raw_fields,omitempty"`
+	IncidentID string            `json:"incident_id,omitempty"` // set once grouped into an incident
+
+	// Set when the event happened inside a Docker or Podman container.
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+
+	// CGroup is the cgroup of the affected process, e.g. the task_memcg of
+	// an OOM kill.
+	CGroup string `json:"cgroup,omitempty"`
+
+	// DedupKey names what the event is about when its unit and process do
+	// not, as "field=value" pairs, e.g. "device=sda" for a disk error or
+	// "gpu=0000:01:00, xid=79" for a GPU fault. Repeats of a problem share
+	// it; it is set by the classifier.
+	DedupKey string `json:"dedup_key,omitempty"`
+
+	// Fingerprint identifies the problem across occurrences, whatever the
+	// PIDs, addresses, or counts in its summary; see ComputeFingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Set once the event's alert is acknowledged, e.g. with the Ack button
+	// of its ntfy notification.
+	AckedBy string    `json:"acked_by,omitempty"`
+	AckedAt time.Time `json:"acked_at,omitzero"`
+}
+
+// CSVHeader names the columns of CSVRecord. Raw fields are left out; use
+// JSON for those. New columns are only ever appended.
+var CSVHeader = []string{
+	"id", "instance_id", "timestamp", "tier", "severity", "summary",
+	"process", "pid", "unit", "container_id", "container_name", "cgroup",
+	"incident_id", "detail", "acked_by", "acked_at",
+}
+
+// CSVRecord returns the event as a CSV row in CSVHeader order. The
+// timestamps are RFC 3339 in UTC; a zero PID or ack time is empty.
+func (e *Event) CSVRecord() []string {
+	pid := ""
+	if e.PID != 0 {
+		pid = strconv.Itoa(e.PID)
+	}
+	acked := ""
+	if !e.AckedAt.IsZero() {
+		acked = e.AckedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return []string{
+		e.ID, e.InstanceID, e.Timestamp.UTC().Format(time.RFC3339Nano), string(e.Tier), string(e.Severity), e.Summary,
+		e.Process, pid, e.Unit, e.ContainerID, e.ContainerName, e.CGroup,
+		e.IncidentID, e.Detail, e.AckedBy, acked,
+	}
 }
 
 // New creates a new Event with a generated UUID and the given timestamp.
@@ -69,6 +138,16 @@ func (t Tier) Label() string {
 		return "Kernel/HW Error"
 	case TierMemPressure:
 		return "Memory Pressure"
+	case TierResource:
+		return "Resource Limit"
+	case TierReboot:
+		return "Unexpected Reboot"
+	case TierSecurity:
+		return "Security"
+	case TierInternal:
+		return "Internal Error"
+	case TierUnclassified:
+		return "Unclassified"
 	default:
 		return string(t)
 	}
@@ -78,3 +157,35 @@ func (t Tier) Label() string {
 func (s Severity) Label() string {
 	return string(s)
 }
+
+// Rank orders severities from least (1, warning) to most (4, critical)
+// urgent. Unknown severities rank 0.
+func (s Severity) Rank() int {
+	switch s {
+	case SevCritical:
+		return 4
+	case SevHigh:
+		return 3
+	case SevMedium:
+		return 2
+	case SevWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Raise returns the next more urgent severity. Critical, and unknown
+// severities, are returned unchanged.
+func (s Severity) Raise() Severity {
+	switch s {
+	case SevWarning:
+		return SevMedium
+	case SevMedium:
+		return SevHigh
+	case SevHigh:
+		return SevCritical
+	default:
+		return s
+	}
+}
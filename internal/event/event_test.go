@@ -1,6 +1,7 @@
 package event
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 )
@@ -51,6 +52,8 @@ func TestTierLabel(t *testing.T) {
 		{TierServiceFailure, "Service Failure"},
 		{TierKernelHW, "Kernel/HW Error"},
 		{TierMemPressure, "Memory Pressure"},
+		{TierResource, "Resource Limit"},
+		{TierReboot, "Unexpected Reboot"},
 		{Tier("T99"), "T99"},
 	}
 
@@ -80,3 +83,99 @@ func TestSeverityLabel(t *testing.T) {
 		}
 	}
 }
+
+func TestSeverityRank(t *testing.T) {
+	order := []Severity{Severity("bogus"), SevWarning, SevMedium, SevHigh, SevCritical}
+	for i := 1; i < len(order); i++ {
+		if order[i].Rank() <= order[i-1].Rank() {
+			t.Errorf("%q.Rank() = %d, should exceed %q.Rank() = %d",
+				order[i], order[i].Rank(), order[i-1], order[i-1].Rank())
+		}
+	}
+}
+
+func TestSeverityRaise(t *testing.T) {
+	tests := map[Severity]Severity{
+		SevWarning:        SevMedium,
+		SevMedium:         SevHigh,
+		SevHigh:           SevCritical,
+		SevCritical:       SevCritical,
+		Severity("bogus"): Severity("bogus"),
+	}
+	for in, want := range tests {
+		if got := in.Raise(); got != want {
+			t.Errorf("%q.Raise() = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestComputeFingerprint(t *testing.T) {
+	crash := func(summary, process string) *Event {
+		ev := New("h", time.Now(), TierProcessCrash, SevHigh, summary)
+		ev.Process = process
+		return ev
+	}
+	a := crash("Segfault: worker[1234] at 7f3a12bc00 ip 0x55d1a0 error 4", "worker")
+	b := crash("Segfault: worker[98] at 7f9b04aa10 ip 0x5612ff error 6", "worker")
+	if ComputeFingerprint(a) != ComputeFingerprint(b) {
+		t.Error("occurrences differing only in PIDs and addresses have different fingerprints")
+	}
+	if got := ComputeFingerprint(a); len(got) != 16 {
+		t.Errorf("fingerprint = %q, want 16 hex digits", got)
+	}
+
+	for name, other := range map[string]*Event{
+		"process": crash(a.Summary, "other"),
+		"summary": crash("Abort: worker[1234]", "worker"),
+		"tier":    New("h", time.Now(), TierOOMKill, SevHigh, a.Summary),
+	} {
+		if name == "tier" {
+			other.Process = "worker"
+		}
+		if ComputeFingerprint(other) == ComputeFingerprint(a) {
+			t.Errorf("different %s, same fingerprint", name)
+		}
+	}
+
+	sda := New("h", time.Now(), TierKernelHW, SevHigh, "I/O error")
+	sda.DedupKey = "device=sda"
+	sdb := New("h", time.Now(), TierKernelHW, SevHigh, "I/O error")
+	sdb.DedupKey = "device=sdb"
+	if ComputeFingerprint(sda) == ComputeFingerprint(sdb) {
+		t.Error("different devices, same fingerprint")
+	}
+}
+
+func TestEventSerialization(t *testing.T) {
+	ts := time.Date(2024, 2, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+	ev := New("host1", ts, TierOOMKill, SevCritical, "OOM Kill: python3 (pid 4242) in backup.service")
+	ev.ID = "e1"
+	ev.Process = "python3"
+	ev.PID = 4242
+	ev.Unit = "backup.service"
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["tier"] != "T1" || m["unit"] != "backup.service" || m["pid"] != float64(4242) {
+		t.Errorf("json = %s", data)
+	}
+	for _, k := range []string{"container_id", "cgroup", "incident_id", "detail", "acked_by", "acked_at"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("empty %s should be omitted: %s", k, data)
+		}
+	}
+
+	rec := ev.CSVRecord()
+	if len(rec) != len(CSVHeader) {
+		t.Fatalf("record has %d columns, header %d", len(rec), len(CSVHeader))
+	}
+	if rec[2] != "2024-02-15T09:30:00Z" || rec[7] != "4242" || rec[8] != "backup.service" {
+		t.Errorf("record = %q", rec)
+	}
+}
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Patterns for the parts of a summary, process, or unit that differ between
+// occurrences of the same problem, applied in order to the lowercased text.
+var (
+	uuidRe   = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	hexRe    = regexp.MustCompile(`0x[0-9a-f]+`)
+	hexRunRe = regexp.MustCompile(`\b[0-9a-f]*\d[0-9a-f]*\b`) // addresses and offsets without 0x
+	digitsRe = regexp.MustCompile(`\d+`)
+)
+
+// ComputeFingerprint returns a short hash identifying the problem ev
+// reports: its tier, process, unit, dedup key, and summary, with PIDs,
+// addresses, hex offsets, and other numbers masked, so "segfault at
+// 7f3a12 ip 0x55d1" and "segfault at 7f9b04 ip 0x5612" share it.
+func ComputeFingerprint(ev *Event) string {
+	h := sha256.New()
+	for _, s := range []string{string(ev.Tier), normalize(ev.Process), normalize(ev.Unit), ev.DedupKey, normalize(ev.Summary)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// normalize masks the variable parts of s.
+func normalize(s string) string {
+	s = strings.ToLower(s)
+	s = uuidRe.ReplaceAllString(s, "#")
+	s = hexRe.ReplaceAllString(s, "0x#")
+	s = hexRunRe.ReplaceAllString(s, "#")
+	s = digitsRe.ReplaceAllString(s, "#")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,25 @@
+package format
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration formats a duration coarsely for people, e.g. "45s", "12m",
+// "3h 5m", or "2d 4h".
+func Duration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	if d < 24*time.Hour {
+		h := int(d.Hours())
+		m := int(d.Minutes()) % 60
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	days := int(d.Hours()) / 24
+	h := int(d.Hours()) % 24
+	return fmt.Sprintf("%dd %dh", days, h)
+}
@@ -0,0 +1,24 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		input time.Duration
+		want  string
+	}{
+		{0, "0s"},
+		{45 * time.Second, "45s"},
+		{12 * time.Minute, "12m"},
+		{3*time.Hour + 5*time.Minute, "3h 5m"},
+		{52 * time.Hour, "2d 4h"},
+	}
+	for _, tt := range tests {
+		if got := Duration(tt.input); got != tt.want {
+			t.Errorf("Duration(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
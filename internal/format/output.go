@@ -0,0 +1,46 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output is a CLI output format, as selected with --format.
+type Output string
+
+const (
+	OutputText Output = "text"
+	OutputJSON Output = "json"
+	OutputCSV  Output = "csv"
+)
+
+// ParseOutput validates a --format value.
+func ParseOutput(s string) (Output, error) {
+	switch o := Output(s); o {
+	case OutputText, OutputJSON, OutputCSV:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown format %q: must be text, json, or csv", s)
+	}
+}
+
+// WriteJSON writes v as indented JSON followed by a newline.
+func WriteJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteCSV writes a header row followed by records.
+func WriteCSV(w io.Writer, header []string, records [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,29 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	for _, s := range []string{"text", "json", "csv"} {
+		if o, err := ParseOutput(s); err != nil || string(o) != s {
+			t.Errorf("ParseOutput(%q) = %q, %v", s, o, err)
+		}
+	}
+	if _, err := ParseOutput("yaml"); err == nil {
+		t.Error("ParseOutput(yaml) should fail")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteCSV(&b, []string{"a", "b"}, [][]string{{"1", "x,y"}, {"2", "line\nbreak"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a,b\n1,\"x,y\"\n2,\"line\nbreak\"\n"
+	if b.String() != want {
+		t.Errorf("WriteCSV = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,232 @@
+// Package health tracks whether the daemon's pipeline is actually working,
+// so the systemd watchdog is only petted while it is, and serves the result
+// over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each check so a hung dependency reads as unhealthy
+// instead of hanging the caller.
+const checkTimeout = 5 * time.Second
+
+// CheckFunc returns nil when its component is healthy.
+type CheckFunc func(ctx context.Context) error
+
+// Component is the result of one check.
+type Component struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status is the result of running every check.
+type Status struct {
+	Healthy    bool        `json:"healthy"`
+	Checked    time.Time   `json:"checked"`
+	Components []Component `json:"components"`
+}
+
+// Failing returns the names and errors of unhealthy components, for logs.
+func (s Status) Failing() []string {
+	var out []string
+	for _, c := range s.Components {
+		if !c.OK {
+			out = append(out, c.Name+": "+c.Error)
+		}
+	}
+	return out
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs a set of named health checks.
+type Checker struct {
+	run    sync.Mutex // serializes Run
+	mu     sync.Mutex // guards checks and last
+	checks []check
+	last   Status
+}
+
+// NewChecker creates an empty Checker.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers a check. Checks run in the order they were added.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Run executes every check and returns the combined status. Calls are
+// serialized; the result is also kept for Last.
+func (c *Checker) Run(ctx context.Context) Status {
+	c.run.Lock()
+	defer c.run.Unlock()
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	st := Status{Healthy: true, Checked: time.Now()}
+	for _, ch := range checks {
+		cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := ch.fn(cctx)
+		cancel()
+
+		comp := Component{Name: ch.name, OK: err == nil}
+		if err != nil {
+			comp.Error = err.Error()
+			st.Healthy = false
+		}
+		st.Components = append(st.Components, comp)
+	}
+	c.mu.Lock()
+	c.last = st
+	c.mu.Unlock()
+	return st
+}
+
+// Last returns the status from the most recent Run. It does not wait for
+// a Run in progress.
+func (c *Checker) Last() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// LastWithin returns the status from the most recent Run, or an unhealthy
+// one if no Run has finished within maxAge, so a check that hangs past its
+// timeout still reads as unhealthy.
+func (c *Checker) LastWithin(maxAge time.Duration) Status {
+	st := c.Last()
+	if age := time.Since(st.Checked); st.Checked.IsZero() || age > maxAge {
+		since := "never"
+		if !st.Checked.IsZero() {
+			since = age.Round(time.Second).String() + " ago"
+		}
+		return Status{
+			Healthy:    false,
+			Checked:    st.Checked,
+			Components: []Component{{Name: "checker", Error: "checks last completed " + since}},
+		}
+	}
+	return st
+}
+
+// Loop runs the checks every interval until ctx is cancelled, starting at
+// once, so callers can read Last without running slow checks themselves.
+func (c *Checker) Loop(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		c.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Fresh returns a check that fails when last() is older than maxAge. A zero
+// time counts from start, so a component gets maxAge to report for the
+// first time.
+func Fresh(last func() time.Time, maxAge time.Duration) CheckFunc {
+	start := time.Now()
+	return func(ctx context.Context) error {
+		t := last()
+		if t.IsZero() {
+			t = start
+		}
+		if age := time.Since(t); age > maxAge {
+			return fmt.Errorf("no progress for %s (limit %s)", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
+
+// Handler serves the checker's status as JSON: 200 when healthy, 503 when
+// not. Each request runs the checks.
+func Handler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := c.Run(r.Context())
+		code := http.StatusOK
+		if !st.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(st)
+	})
+}
+
+// Serve listens on addr and serves GET /healthz until ctx is cancelled.
+// Bind errors are returned immediately.
+func Serve(ctx context.Context, addr string, c *Checker) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", Handler(c))
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("health server failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Journal returns a check that fails when the journal holds an entry newer
+// than the last one the pipeline received and that entry is older than
+// grace: entries are not flowing even though there are some to read. A
+// quiet journal, with nothing newer than what was received, is healthy.
+func Journal(lastReceived func() time.Time, latest func(ctx context.Context) (time.Time, error), grace time.Duration) CheckFunc {
+	return func(ctx context.Context) error {
+		newest, err := latest(ctx)
+		if err != nil {
+			return fmt.Errorf("verifying journal: %w", err)
+		}
+		seen := lastReceived()
+		if newest.After(seen) && time.Since(newest) > grace {
+			return fmt.Errorf("journal has entries up to %s but the last received is from %s",
+				newest.Format(time.RFC3339), formatSeen(seen))
+		}
+		return nil
+	}
+}
+
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
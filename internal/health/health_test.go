@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	c := NewChecker()
+	c.Add("ok", func(ctx context.Context) error { return nil })
+	c.Add("db", func(ctx context.Context) error { return errors.New("read-only filesystem") })
+
+	st := c.Run(context.Background())
+	if st.Healthy {
+		t.Fatal("status healthy with a failing check")
+	}
+	if len(st.Components) != 2 || !st.Components[0].OK || st.Components[1].Error != "read-only filesystem" {
+		t.Errorf("components = %+v", st.Components)
+	}
+	if f := st.Failing(); len(f) != 1 || f[0] != "db: read-only filesystem" {
+		t.Errorf("Failing() = %v", f)
+	}
+	if !c.Last().Checked.Equal(st.Checked) {
+		t.Error("Last() does not return the latest run")
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := NewChecker()
+	c.Add("hung", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if st := c.Run(ctx); st.Healthy {
+		t.Error("hung check reported healthy")
+	}
+}
+
+func TestLastWithin(t *testing.T) {
+	c := NewChecker()
+	release := make(chan struct{})
+	c.Add("slow", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	if st := c.LastWithin(time.Minute); st.Healthy {
+		t.Error("status healthy before any run")
+	}
+	go c.Run(context.Background())
+	// Last must not wait for the run in progress.
+	done := make(chan Status)
+	go func() { done <- c.Last() }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Last blocked on a running check")
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for !c.LastWithin(time.Minute).Healthy {
+		if time.Now().After(deadline) {
+			t.Fatal("status not healthy after the run finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if st := c.LastWithin(time.Nanosecond); st.Healthy || len(st.Failing()) != 1 {
+		t.Errorf("stale status = %+v, want unhealthy", st)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	var last time.Time
+	check := Fresh(func() time.Time { return last }, time.Minute)
+
+	// Before the first report the component has maxAge from creation.
+	if err := check(context.Background()); err != nil {
+		t.Errorf("new component: %v", err)
+	}
+	last = time.Now().Add(-30 * time.Second)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("recent poll: %v", err)
+	}
+	last = time.Now().Add(-5 * time.Minute)
+	if err := check(context.Background()); err == nil {
+		t.Error("stale poll reported healthy")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	now := time.Now()
+	var received, newest time.Time
+	check := Journal(
+		func() time.Time { return received },
+		func(ctx context.Context) (time.Time, error) { return newest, nil },
+		2*time.Minute,
+	)
+
+	tests := []struct {
+		name     string
+		received time.Time
+		newest   time.Time
+		healthy  bool
+	}{
+		{"empty journal", time.Time{}, time.Time{}, true},
+		{"caught up", now.Add(-time.Hour), now.Add(-time.Hour), true},
+		{"new entry within grace", now.Add(-time.Hour), now.Add(-time.Minute), true},
+		{"entry not received", now.Add(-time.Hour), now.Add(-10 * time.Minute), false},
+		{"never received", time.Time{}, now.Add(-10 * time.Minute), false},
+	}
+	for _, tt := range tests {
+		received, newest = tt.received, tt.newest
+		err := check(context.Background())
+		if (err == nil) != tt.healthy {
+			t.Errorf("%s: err = %v, want healthy=%v", tt.name, err, tt.healthy)
+		}
+	}
+
+	failing := Journal(func() time.Time { return now },
+		func(ctx context.Context) (time.Time, error) { return time.Time{}, errors.New("no journalctl") },
+		time.Minute)
+	if failing(context.Background()) == nil {
+		t.Error("journal read error reported healthy")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	healthy := true
+	c := NewChecker()
+	c.Add("toggle", func(ctx context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("wedged")
+	})
+	h := Handler(c)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthy status = %d", rec.Code)
+	}
+
+	healthy = false
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("unhealthy status = %d", rec.Code)
+	}
+	var st Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil || st.Healthy || st.Components[0].Error != "wedged" {
+		t.Errorf("body = %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,253 @@
+// Package hook runs the user scripts of [[hooks]] for matching events. Each
+// script gets the event as JSON on stdin and runs in the background, bounded
+// by its timeout, concurrency, and rate limit, so a slow or failing script
+// never holds up the pipeline.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Defaults for a hook that leaves them unset.
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultConcurrency = 1
+	defaultPer         = time.Hour
+)
+
+// hook is a compiled [[hooks]] entry.
+type hook struct {
+	name    string
+	command []string
+	tiers   []event.Tier
+	minRank int
+	unit    *regexp.Regexp
+	timeout time.Duration
+	maxRuns int
+	per     time.Duration
+
+	slots chan struct{} // one token per run in flight
+
+	mu   sync.Mutex
+	runs []time.Time // start times within per, when rate limited
+}
+
+// Runner fires the hooks matching each event.
+type Runner struct {
+	hooks []*hook
+	wg    sync.WaitGroup
+
+	// Overridable for testing.
+	now  func() time.Time
+	exec func(ctx context.Context, argv, env []string, stdin []byte) ([]byte, error)
+}
+
+// New compiles the hooks. Every invalid hook is reported.
+func New(cfgs []config.HookConfig) (*Runner, error) {
+	r := &Runner{now: time.Now, exec: runCommand}
+	var errs []error
+
+	for i, spec := range cfgs {
+		h := &hook{
+			name:    spec.Name,
+			command: spec.Command,
+			minRank: event.Severity(spec.MinSeverity).Rank(),
+			timeout: spec.Timeout.Duration,
+			maxRuns: spec.MaxRuns,
+			per:     spec.Per.Duration,
+		}
+		if h.name == "" {
+			h.name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, t := range spec.Tiers {
+			h.tiers = append(h.tiers, event.Tier(strings.ToUpper(t)))
+		}
+		if h.timeout == 0 {
+			h.timeout = defaultTimeout
+		}
+		if h.per == 0 {
+			h.per = defaultPer
+		}
+		h.slots = make(chan struct{}, max(spec.Concurrency, defaultConcurrency))
+
+		var err error
+		if len(h.command) == 0 || h.command[0] == "" {
+			err = errors.New("command is required")
+		} else if spec.Unit != "" {
+			if h.unit, err = regexp.Compile(spec.Unit); err != nil {
+				err = fmt.Errorf("invalid unit pattern: %w", err)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hook %s: %w", h.name, err))
+			continue
+		}
+		r.hooks = append(r.hooks, h)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Len returns the number of hooks.
+func (r *Runner) Len() int {
+	return len(r.hooks)
+}
+
+// Inherit takes over the rate limit history of old, the runner a reloaded
+// config replaces, for the hooks of the same name.
+func (r *Runner) Inherit(old *Runner) {
+	if old == nil {
+		return
+	}
+	prev := make(map[string]*hook, len(old.hooks))
+	for _, h := range old.hooks {
+		prev[h.name] = h
+	}
+	for _, h := range r.hooks {
+		if o := prev[h.name]; o != nil {
+			o.mu.Lock()
+			h.runs = append([]time.Time(nil), o.runs...)
+			o.mu.Unlock()
+		}
+	}
+}
+
+// Fire starts every hook matching ev in the background and returns how
+// many it started. A hook whose runs are all in flight, or that has used up
+// its rate limit, skips the event.
+func (r *Runner) Fire(ctx context.Context, ev *event.Event) int {
+	var payload []byte
+	started := 0
+	for _, h := range r.hooks {
+		if !h.matches(ev) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(ev); err != nil {
+				slog.Error("encoding event for hooks", "error", err)
+				return 0
+			}
+		}
+		select {
+		case h.slots <- struct{}{}:
+		default:
+			slog.Warn("hook busy, event skipped", "hook", h.name, "event", ev.ID)
+			continue
+		}
+		if !h.take(r.now()) {
+			<-h.slots
+			slog.Warn("hook rate limited, event skipped",
+				"hook", h.name, "event", ev.ID, "max_runs", h.maxRuns, "per", h.per)
+			continue
+		}
+
+		env := []string{
+			"LOGTRIAGE_HOOK=" + h.name,
+			"LOGTRIAGE_EVENT_ID=" + ev.ID,
+			"LOGTRIAGE_TIER=" + string(ev.Tier),
+			"LOGTRIAGE_SEVERITY=" + string(ev.Severity),
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-h.slots }()
+			r.run(ctx, h, env, payload, ev.ID)
+		}()
+		started++
+	}
+	return started
+}
+
+// Wait blocks until the hooks in flight have finished.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) run(ctx context.Context, h *hook, env []string, payload []byte, id string) {
+	runCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	start := r.now()
+	out, err := r.exec(runCtx, h.command, env, payload)
+	if err != nil {
+		slog.Error("hook failed", "hook", h.name, "event", id, "error", err,
+			"output", firstLine(out))
+		return
+	}
+	slog.Debug("hook ran", "hook", h.name, "event", id, "took", r.now().Sub(start))
+}
+
+func (h *hook) matches(ev *event.Event) bool {
+	if len(h.tiers) > 0 && !slices.Contains(h.tiers, ev.Tier) {
+		return false
+	}
+	if h.minRank > 0 && ev.Severity.Rank() < h.minRank {
+		return false
+	}
+	if h.unit != nil && !h.unit.MatchString(ev.Unit) {
+		return false
+	}
+	return true
+}
+
+// take records a run starting at now, unless the hook has already run
+// maxRuns times within per.
+func (h *hook) take(now time.Time) bool {
+	if h.maxRuns == 0 {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recent := h.runs[:0]
+	for _, t := range h.runs {
+		if now.Sub(t) < h.per {
+			recent = append(recent, t)
+		}
+	}
+	h.runs = recent
+	if len(recent) >= h.maxRuns {
+		return false
+	}
+	h.runs = append(h.runs, now)
+	return true
+}
+
+// waitDelay is how long a hook's output is waited for once it has been
+// killed, in case something it started still holds it open.
+const waitDelay = 5 * time.Second
+
+// runCommand runs argv without a shell, with stdin on its standard input
+// and env added to the daemon's environment, and returns its combined
+// output. When ctx is done the hook is killed together with whatever it
+// started, where the platform allows (see killGroup).
+func runCommand(ctx context.Context, argv, env []string, stdin []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.WaitDelay = waitDelay
+	killGroup(cmd)
+	return cmd.CombinedOutput()
+}
+
+func firstLine(out []byte) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
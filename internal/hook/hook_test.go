@@ -0,0 +1,186 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// call is a recorded hook run.
+type call struct {
+	argv, env []string
+	ev        event.Event
+}
+
+// testRunner returns a Runner that records the runs of its hooks instead
+// of running them. Each run waits for release to be closed, if set.
+func testRunner(t *testing.T, cfgs []config.HookConfig, release chan struct{}) (*Runner, func() []call) {
+	t.Helper()
+	r, err := New(cfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var calls []call
+	r.exec = func(_ context.Context, argv, env []string, stdin []byte) ([]byte, error) {
+		var ev event.Event
+		if err := json.Unmarshal(stdin, &ev); err != nil {
+			t.Errorf("stdin is not an event: %v", err)
+		}
+		mu.Lock()
+		calls = append(calls, call{argv, env, ev})
+		mu.Unlock()
+		if release != nil {
+			<-release
+		}
+		return nil, nil
+	}
+	return r, func() []call {
+		r.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func newEvent(tier event.Tier, sev event.Severity, unit string) *event.Event {
+	ev := event.New("h", time.Now(), tier, sev, "something broke")
+	ev.Unit = unit
+	return ev
+}
+
+func TestFireFilters(t *testing.T) {
+	r, calls := testRunner(t, []config.HookConfig{
+		{Name: "page", Command: []string{"/usr/local/bin/page", "--now"}, Tiers: []string{"t1", "T4"}, MinSeverity: "high"},
+		{Name: "web", Command: []string{"fix-web"}, Unit: `^nginx`},
+	}, nil)
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		ev   *event.Event
+		want int
+	}{
+		{newEvent(event.TierOOMKill, event.SevCritical, ""), 1},
+		{newEvent(event.TierOOMKill, event.SevWarning, ""), 0},
+		{newEvent(event.TierServiceFailure, event.SevHigh, "sshd.service"), 0},
+		{newEvent(event.TierServiceFailure, event.SevHigh, "nginx.service"), 1},
+	} {
+		if got := r.Fire(ctx, tt.ev); got != tt.want {
+			t.Errorf("Fire(%s %s %q) = %d, want %d", tt.ev.Tier, tt.ev.Severity, tt.ev.Unit, got, tt.want)
+		}
+	}
+
+	got := calls()
+	if len(got) != 2 {
+		t.Fatalf("calls = %+v, want 2", got)
+	}
+	page := got[0]
+	if got[0].argv[0] != "/usr/local/bin/page" {
+		page = got[1]
+	}
+	if !slices.Equal(page.argv, []string{"/usr/local/bin/page", "--now"}) || page.ev.Tier != event.TierOOMKill {
+		t.Errorf("page call = %+v", page)
+	}
+	if !slices.Contains(page.env, "LOGTRIAGE_TIER=T1") || !slices.Contains(page.env, "LOGTRIAGE_HOOK=page") {
+		t.Errorf("env = %v", page.env)
+	}
+}
+
+func TestFireConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	r, calls := testRunner(t, []config.HookConfig{
+		{Name: "slow", Command: []string{"slow"}, Concurrency: 2},
+	}, release)
+
+	ctx := context.Background()
+	started := 0
+	for range 3 {
+		started += r.Fire(ctx, newEvent(event.TierServiceFailure, event.SevHigh, "a.service"))
+	}
+	if started != 2 {
+		t.Errorf("started %d runs with 2 slots, want 2", started)
+	}
+	close(release)
+	if got := len(calls()); got != 2 {
+		t.Errorf("ran %d times, want 2", got)
+	}
+	if r.Fire(ctx, newEvent(event.TierServiceFailure, event.SevHigh, "a.service")) != 1 {
+		t.Error("no run after the slots were freed")
+	}
+	r.Wait()
+}
+
+func TestFireRateLimit(t *testing.T) {
+	cfgs := []config.HookConfig{{Name: "limited", Command: []string{"x"}, MaxRuns: 2, Per: config.Duration{Duration: 10 * time.Minute}}}
+	r, _ := testRunner(t, cfgs, nil)
+	now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	ev := newEvent(event.TierProcessCrash, event.SevMedium, "")
+	for i, want := range []int{1, 1, 0} {
+		if got := r.Fire(ctx, ev); got != want {
+			t.Errorf("fire %d = %d, want %d", i, got, want)
+		}
+		r.Wait()
+	}
+
+	// A reload keeps the history; the window passing frees the limit.
+	next, _ := testRunner(t, cfgs, nil)
+	next.now = r.now
+	next.Inherit(r)
+	if next.Fire(ctx, ev) != 0 {
+		t.Error("reload reset the rate limit")
+	}
+	now = now.Add(10 * time.Minute)
+	if next.Fire(ctx, ev) != 1 {
+		t.Error("still limited after the window")
+	}
+	next.Wait()
+}
+
+func TestRunCommandStdin(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("no cat")
+	}
+	out, err := runCommand(context.Background(), []string{"cat"}, nil, []byte(`{"tier":"T1"}`))
+	if err != nil || string(out) != `{"tier":"T1"}` {
+		t.Errorf("runCommand = %q, %v", out, err)
+	}
+}
+
+func TestRunCommandTimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no process groups")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The backgrounded sleep holds the output open; without the group
+	// kill, runCommand would wait for it.
+	start := time.Now()
+	_, err := runCommand(ctx, []string{"sh", "-c", "sleep 30 & sleep 30"}, nil, nil)
+	if err == nil {
+		t.Fatal("runCommand succeeded past its timeout")
+	}
+	if d := time.Since(start); d > waitDelay {
+		t.Errorf("runCommand returned after %s, want the timeout", d)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New([]config.HookConfig{{Name: "empty"}, {Command: []string{"x"}, Unit: "("}}); err == nil {
+		t.Error("no error for a hook without command and a bad unit pattern")
+	}
+}
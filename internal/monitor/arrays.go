@@ -0,0 +1,454 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// Storage array kinds.
+const (
+	ArrayMD    = "mdraid"
+	ArrayZFS   = "zfs"
+	ArrayBtrfs = "btrfs"
+)
+
+// ArrayStatus is the health of one storage array: an md RAID device, a ZFS
+// pool, or a mounted btrfs filesystem.
+type ArrayStatus struct {
+	Kind     string   // ArrayMD, ArrayZFS, or ArrayBtrfs
+	Name     string   // "md0", the pool name, or the btrfs mountpoint
+	State    string   // as the tool reports it, e.g. "active raid1" or "DEGRADED"
+	Degraded bool     // the array is running without all its members, or not at all
+	Failed   []string // failed or missing members
+	Errors   int64    // read, write, checksum, and scrub errors, summed
+	Notes    []string // further detail, e.g. rebuild progress or per-device error counts
+}
+
+// Healthy reports whether the array is complete and has no errors.
+func (s ArrayStatus) Healthy() bool {
+	return !s.Degraded && s.Errors == 0
+}
+
+// ArrayEvent is emitted when an array degrades, a member fails, or its
+// error counts rise, and with a healthy status once it has recovered.
+type ArrayEvent struct {
+	Timestamp time.Time
+	Status    ArrayStatus
+	NewErrors int64 // errors since the previous poll
+}
+
+// ArrayMonitor polls /proc/mdstat, zpool status, and btrfs device stats and
+// emits events for arrays that degrade or report errors.
+type ArrayMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	last         map[string]ArrayStatus // kind:name -> status at the previous poll
+}
+
+// NewArrayMonitor creates a storage array monitor with the given poll
+// interval.
+func NewArrayMonitor(pollInterval time.Duration) *ArrayMonitor {
+	return &ArrayMonitor{
+		pollInterval: pollInterval,
+		last:         make(map[string]ArrayStatus),
+	}
+}
+
+// Events starts the array polling loop and returns a channel of array events.
+func (m *ArrayMonitor) Events(ctx context.Context) <-chan ArrayEvent {
+	ch := make(chan ArrayEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *ArrayMonitor) poll(ctx context.Context, ch chan<- ArrayEvent) {
+	defer close(ch)
+
+	// Initial poll.
+	m.checkAll(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx, ch)
+		}
+	}
+}
+
+func (m *ArrayMonitor) checkAll(ctx context.Context, ch chan<- ArrayEvent) {
+	defer m.markPoll()
+
+	statuses := ReadArrays(ctx)
+	seen := make(map[string]bool, len(statuses))
+	for _, s := range statuses {
+		key := s.Kind + ":" + s.Name
+		seen[key] = true
+		prev, known := m.last[key]
+		m.last[key] = s
+
+		ev, ok := arrayChange(prev, known, s)
+		if !ok {
+			continue
+		}
+		ev.Timestamp = time.Now()
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return
+		default:
+			selfstat.Drop(1)
+		}
+	}
+	// Arrays stopped or exported since are forgotten.
+	for key := range m.last {
+		if !seen[key] {
+			delete(m.last, key)
+		}
+	}
+}
+
+// arrayChange decides whether an array's status is worth an event given its
+// status at the previous poll, if known. An unhealthy array is reported when
+// first seen, when it degrades or recovers a member, or when its error
+// counts rise; a healthy one only when it was unhealthy before. Error
+// counters that went down were reset, which is not new errors.
+func arrayChange(prev ArrayStatus, known bool, cur ArrayStatus) (ArrayEvent, bool) {
+	ev := ArrayEvent{Status: cur, NewErrors: max(cur.Errors-prev.Errors, 0)}
+	if cur.Healthy() {
+		return ev, known && !prev.Healthy()
+	}
+	report := !known || ev.NewErrors > 0 ||
+		cur.Degraded != prev.Degraded || !slices.Equal(cur.Failed, prev.Failed)
+	return ev, report
+}
+
+// ReadArrays returns the status of every md array, ZFS pool, and mounted
+// btrfs filesystem. Sources that are absent, such as zpool on a host
+// without ZFS, are skipped.
+func ReadArrays(ctx context.Context) []ArrayStatus {
+	var all []ArrayStatus
+
+	if data, err := os.ReadFile("/proc/mdstat"); err == nil {
+		all = append(all, parseMdstat(data)...)
+	}
+
+	if sysdep.Have("zpool") {
+		cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		out, err := exec.CommandContext(cctx, "zpool", "status", "-j").Output()
+		cancel()
+		if err != nil {
+			slog.Debug("zpool status failed", "error", err)
+		} else if pools, err := parseZpoolStatus(out); err != nil {
+			slog.Debug("parsing zpool status failed", "error", err)
+		} else {
+			all = append(all, pools...)
+		}
+	}
+
+	if sysdep.Have("btrfs") {
+		for _, mount := range btrfsMounts() {
+			cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			// btrfs exits non-zero with -c when errors are found, which
+			// are what we want to read.
+			out, _ := exec.CommandContext(cctx, "btrfs", "device", "stats", mount).Output()
+			cancel()
+			if len(out) == 0 {
+				slog.Debug("btrfs device stats returned no output", "mount", mount)
+				continue
+			}
+			all = append(all, parseBtrfsDeviceStats(mount, out))
+		}
+	}
+
+	return all
+}
+
+// mdstatDevRe matches an md device's header line.
+// Example: "md0 : active raid1 sdb1[1] sda1[0](F)"
+var mdstatDevRe = regexp.MustCompile(`^(md\S+) : (.*)$`)
+
+// mdstatMemberRe matches one member of an md device: its name, slot, and
+// flags such as (F) for failed or (S) for spare.
+var mdstatMemberRe = regexp.MustCompile(`^(\S+)\[\d+\]((?:\([A-Z]\))*)$`)
+
+// mdstatCountRe matches the member count and map of an md device.
+// Example: "1953382464 blocks super 1.2 [2/1] [U_]"
+var mdstatCountRe = regexp.MustCompile(`\[(\d+)/(\d+)\] \[([U_]+)\]`)
+
+// mdstatProgressRe matches a rebuild, resync, or check in progress.
+// Example: "[==>....]  recovery = 12.6% (123456/976630272) finish=100.0min speed=100000K/sec"
+var mdstatProgressRe = regexp.MustCompile(`(recovery|resync|reshape|check|repair)\s*=\s*([\d.]+%)(?:.*finish=(\S+))?`)
+
+// parseMdstat parses /proc/mdstat:
+//
+//	Personalities : [raid1] [raid6] [raid5] [raid4]
+//	md0 : active raid1 sdb1[1] sda1[0](F)
+//	      1953382464 blocks super 1.2 [2/1] [_U]
+//	      bitmap: 2/15 pages [8KB], 65536KB chunk
+//
+//	unused devices: <none>
+//
+// An array is degraded when fewer members are active than it has slots, or
+// when it is inactive.
+func parseMdstat(data []byte) []ArrayStatus {
+	var arrays []ArrayStatus
+	var cur *ArrayStatus
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mdstatDevRe.FindStringSubmatch(line); m != nil {
+			arrays = append(arrays, ArrayStatus{Kind: ArrayMD, Name: m[1]})
+			cur = &arrays[len(arrays)-1]
+
+			var state []string
+			for _, f := range strings.Fields(m[2]) {
+				mm := mdstatMemberRe.FindStringSubmatch(f)
+				if mm == nil {
+					state = append(state, f)
+					continue
+				}
+				if strings.Contains(mm[2], "(F)") {
+					cur.Failed = append(cur.Failed, mm[1])
+				}
+			}
+			cur.State = strings.Join(state, " ")
+			if len(state) > 0 && state[0] == "inactive" {
+				cur.Degraded = true
+			}
+			if len(cur.Failed) > 0 {
+				cur.Degraded = true
+			}
+			continue
+		}
+		if cur == nil || !strings.HasPrefix(line, " ") {
+			cur = nil
+			continue
+		}
+
+		if m := mdstatCountRe.FindStringSubmatch(line); m != nil {
+			slots, _ := strconv.Atoi(m[1])
+			active, _ := strconv.Atoi(m[2])
+			if active < slots {
+				cur.Degraded = true
+				cur.Notes = append(cur.Notes, fmt.Sprintf("%d of %d members active [%s]", active, slots, m[3]))
+			}
+		}
+		if m := mdstatProgressRe.FindStringSubmatch(line); m != nil {
+			note := m[1] + " " + m[2]
+			if m[3] != "" {
+				note += ", finish in " + m[3]
+			}
+			cur.Notes = append(cur.Notes, note)
+		}
+	}
+	return arrays
+}
+
+// zpoolJSON is the subset of "zpool status -j" output we care about.
+// Counters are strings unless --json-int is given, which older releases
+// lack; json.Number accepts both.
+type zpoolJSON struct {
+	Pools map[string]struct {
+		Name      string `json:"name"`
+		State     string `json:"state"`
+		Status    string `json:"status"`
+		ScanStats *struct {
+			Function string      `json:"function"`
+			State    string      `json:"state"`
+			Errors   json.Number `json:"errors"`
+		} `json:"scan_stats"`
+		ErrorCount json.Number            `json:"error_count"`
+		Vdevs      map[string]zfsVdevJSON `json:"vdevs"`
+	} `json:"pools"`
+}
+
+type zfsVdevJSON struct {
+	Name           string                 `json:"name"`
+	VdevType       string                 `json:"vdev_type"`
+	State          string                 `json:"state"`
+	ReadErrors     json.Number            `json:"read_errors"`
+	WriteErrors    json.Number            `json:"write_errors"`
+	ChecksumErrors json.Number            `json:"checksum_errors"`
+	Vdevs          map[string]zfsVdevJSON `json:"vdevs"`
+}
+
+// parseZpoolStatus parses the output of "zpool status -j". A pool is
+// degraded when it is not ONLINE; its failed members are the disks that are
+// not ONLINE. Errors sum every vdev's read, write, and checksum errors, the
+// last scrub's errors, and the pool's permanent data errors.
+func parseZpoolStatus(data []byte) ([]ArrayStatus, error) {
+	var j zpoolJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parsing zpool JSON: %w", err)
+	}
+
+	var pools []ArrayStatus
+	for name, p := range j.Pools {
+		s := ArrayStatus{Kind: ArrayZFS, Name: name, State: p.State, Degraded: p.State != "ONLINE"}
+		for _, k := range slices.Sorted(maps.Keys(p.Vdevs)) {
+			walkZFSVdev(p.Vdevs[k], &s)
+		}
+		if p.ScanStats != nil {
+			scanErrs := jsonInt(p.ScanStats.Errors)
+			s.Errors += scanErrs
+			if scanErrs > 0 {
+				s.Notes = append(s.Notes, fmt.Sprintf("last %s %s with %d errors",
+					strings.ToLower(p.ScanStats.Function), strings.ToLower(p.ScanStats.State), scanErrs))
+			}
+		}
+		if n := jsonInt(p.ErrorCount); n > 0 {
+			s.Errors += n
+			s.Notes = append(s.Notes, fmt.Sprintf("%d permanent data errors", n))
+		}
+		if p.Status != "" && !s.Healthy() {
+			s.Notes = append(s.Notes, p.Status)
+		}
+		pools = append(pools, s)
+	}
+	slices.SortFunc(pools, func(a, b ArrayStatus) int { return strings.Compare(a.Name, b.Name) })
+	return pools, nil
+}
+
+func walkZFSVdev(v zfsVdevJSON, s *ArrayStatus) {
+	r, w, c := jsonInt(v.ReadErrors), jsonInt(v.WriteErrors), jsonInt(v.ChecksumErrors)
+	s.Errors += r + w + c
+	if r+w+c > 0 {
+		s.Notes = append(s.Notes, fmt.Sprintf("%s: %s, read %d, write %d, checksum %d", v.Name, v.State, r, w, c))
+	}
+	if len(v.Vdevs) == 0 && v.VdevType != "root" && v.State != "ONLINE" {
+		s.Failed = append(s.Failed, v.Name)
+	}
+	for _, k := range slices.Sorted(maps.Keys(v.Vdevs)) {
+		walkZFSVdev(v.Vdevs[k], s)
+	}
+}
+
+func jsonInt(n json.Number) int64 {
+	v, _ := n.Int64()
+	return v
+}
+
+// btrfsStatRe matches one counter of "btrfs device stats".
+// Example: "[/dev/sda1].write_io_errs    0"
+var btrfsStatRe = regexp.MustCompile(`^\[(.+)\]\.(\w+)\s+(\d+)$`)
+
+// parseBtrfsDeviceStats parses the output of "btrfs device stats" for the
+// filesystem mounted at mount. Missing devices are listed as "devid:N" and
+// make the filesystem degraded.
+func parseBtrfsDeviceStats(mount string, data []byte) ArrayStatus {
+	s := ArrayStatus{Kind: ArrayBtrfs, Name: mount}
+
+	type devStats struct {
+		name     string
+		counters []string
+	}
+	var devs []*devStats
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m := btrfsStatRe.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		if len(devs) == 0 || devs[len(devs)-1].name != m[1] {
+			devs = append(devs, &devStats{name: m[1]})
+			if strings.HasPrefix(m[1], "devid:") {
+				s.Failed = append(s.Failed, m[1])
+				s.Degraded = true
+			}
+		}
+		n, _ := strconv.ParseInt(m[3], 10, 64)
+		if n > 0 {
+			d := devs[len(devs)-1]
+			d.counters = append(d.counters, fmt.Sprintf("%s %d", m[2], n))
+			s.Errors += n
+		}
+	}
+	for _, d := range devs {
+		if len(d.counters) > 0 {
+			s.Notes = append(s.Notes, d.name+": "+strings.Join(d.counters, ", "))
+		}
+	}
+	return s
+}
+
+// btrfsMounts returns one mountpoint per mounted btrfs filesystem. Subvolumes
+// of a filesystem mounted in several places share its device stats, so only
+// the first mount of each device is kept.
+func btrfsMounts() []string {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	var mounts []string
+	seen := make(map[string]bool)
+	for line := range strings.Lines(string(data)) {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[2] != "btrfs" || seen[f[0]] {
+			continue
+		}
+		seen[f[0]] = true
+		mounts = append(mounts, unescapeMount(f[1]))
+	}
+	return mounts
+}
+
+// unescapeMount undoes the octal escaping of spaces and other special
+// characters in /proc/self/mounts, e.g. "\040" for a space.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FormatArray returns a human-readable description of an array's status.
+func FormatArray(s ArrayStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Array: %s (%s)\n", s.Name, s.Kind)
+	if s.State != "" {
+		fmt.Fprintf(&b, "State: %s\n", s.State)
+	}
+	if len(s.Failed) > 0 {
+		fmt.Fprintf(&b, "Failed devices: %s\n", strings.Join(s.Failed, ", "))
+	}
+	if s.Errors > 0 {
+		fmt.Fprintf(&b, "Errors: %d\n", s.Errors)
+	}
+	for _, n := range s.Notes {
+		fmt.Fprintf(&b, "  %s\n", n)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
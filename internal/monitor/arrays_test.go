@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sampleMdstat = `Personalities : [raid1] [raid6] [raid5] [raid4]
+md0 : active raid1 sdb1[1] sda1[0](F)
+      1953382464 blocks super 1.2 [2/1] [_U]
+      bitmap: 2/15 pages [8KB], 65536KB chunk
+
+md1 : active raid5 sdc1[0] sdd1[1] sde1[3]
+      1953260544 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [UU_]
+      [==>..................]  recovery = 12.6% (123456/976630272) finish=100.0min speed=100000K/sec
+
+md2 : active raid1 sdf1[0] sdg1[1]
+      976630464 blocks super 1.2 [2/2] [UU]
+
+md127 : inactive sdh1[0](S)
+      976630464 blocks super 1.2
+
+unused devices: <none>
+`
+
+func TestParseMdstat(t *testing.T) {
+	arrays := parseMdstat([]byte(sampleMdstat))
+	if len(arrays) != 4 {
+		t.Fatalf("got %d arrays, want 4: %+v", len(arrays), arrays)
+	}
+
+	md0 := arrays[0]
+	if md0.Name != "md0" || md0.State != "active raid1" || !md0.Degraded || !slices.Equal(md0.Failed, []string{"sda1"}) {
+		t.Errorf("md0 = %+v", md0)
+	}
+
+	md1 := arrays[1]
+	if !md1.Degraded || len(md1.Failed) != 0 {
+		t.Errorf("md1 = %+v, want degraded with no failed member listed", md1)
+	}
+	if want := []string{"2 of 3 members active [UU_]", "recovery 12.6%, finish in 100.0min"}; !slices.Equal(md1.Notes, want) {
+		t.Errorf("md1 notes = %q, want %q", md1.Notes, want)
+	}
+
+	if md2 := arrays[2]; !md2.Healthy() {
+		t.Errorf("md2 = %+v, want healthy", md2)
+	}
+	if md127 := arrays[3]; !md127.Degraded || md127.State != "inactive" {
+		t.Errorf("md127 = %+v, want inactive and degraded", md127)
+	}
+}
+
+const sampleZpoolStatus = `{
+  "output_version": {"command": "zpool status", "vers_major": 0, "vers_minor": 1},
+  "pools": {
+    "tank": {
+      "name": "tank",
+      "state": "DEGRADED",
+      "status": "One or more devices are faulted in response to persistent errors.",
+      "scan_stats": {"function": "SCRUB", "state": "FINISHED", "errors": "2"},
+      "vdevs": {
+        "tank": {
+          "name": "tank", "vdev_type": "root", "state": "DEGRADED",
+          "read_errors": "0", "write_errors": "0", "checksum_errors": "0",
+          "vdevs": {
+            "mirror-0": {
+              "name": "mirror-0", "vdev_type": "mirror", "state": "DEGRADED",
+              "read_errors": "0", "write_errors": "0", "checksum_errors": "0",
+              "vdevs": {
+                "sda": {"name": "sda", "vdev_type": "disk", "state": "ONLINE",
+                  "read_errors": "0", "write_errors": "0", "checksum_errors": "0"},
+                "sdb": {"name": "sdb", "vdev_type": "disk", "state": "FAULTED",
+                  "read_errors": "3", "write_errors": "0", "checksum_errors": "12"}
+              }
+            }
+          }
+        }
+      },
+      "error_count": "0"
+    },
+    "backup": {
+      "name": "backup",
+      "state": "ONLINE",
+      "scan_stats": {"function": "SCRUB", "state": "FINISHED", "errors": 0},
+      "vdevs": {
+        "backup": {"name": "backup", "vdev_type": "root", "state": "ONLINE",
+          "read_errors": 0, "write_errors": 0, "checksum_errors": 0,
+          "vdevs": {
+            "sdc": {"name": "sdc", "vdev_type": "disk", "state": "ONLINE",
+              "read_errors": 0, "write_errors": 0, "checksum_errors": 0}
+          }
+        }
+      },
+      "error_count": 0
+    }
+  }
+}`
+
+func TestParseZpoolStatus(t *testing.T) {
+	pools, err := parseZpoolStatus([]byte(sampleZpoolStatus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 {
+		t.Fatalf("got %d pools, want 2: %+v", len(pools), pools)
+	}
+
+	// Sorted by name; --json-int counters parse like string ones.
+	if backup := pools[0]; backup.Name != "backup" || !backup.Healthy() {
+		t.Errorf("backup = %+v, want healthy", backup)
+	}
+
+	tank := pools[1]
+	if tank.State != "DEGRADED" || !tank.Degraded || !slices.Equal(tank.Failed, []string{"sdb"}) {
+		t.Errorf("tank = %+v", tank)
+	}
+	if tank.Errors != 17 {
+		t.Errorf("tank errors = %d, want 17 (3 read + 12 checksum + 2 scrub)", tank.Errors)
+	}
+	detail := FormatArray(tank)
+	for _, want := range []string{"Array: tank (zfs)", "Failed devices: sdb", "sdb: FAULTED, read 3, write 0, checksum 12", "last scrub finished with 2 errors"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("detail missing %q:\n%s", want, detail)
+		}
+	}
+
+	if _, err := parseZpoolStatus([]byte("no pools available")); err == nil {
+		t.Error("non-JSON output accepted")
+	}
+}
+
+func TestParseBtrfsDeviceStats(t *testing.T) {
+	input := `[/dev/sda1].write_io_errs    0
+[/dev/sda1].read_io_errs     0
+[/dev/sda1].flush_io_errs    0
+[/dev/sda1].corruption_errs  0
+[/dev/sda1].generation_errs  0
+[/dev/sdb1].write_io_errs    4
+[/dev/sdb1].read_io_errs     0
+[/dev/sdb1].flush_io_errs    0
+[/dev/sdb1].corruption_errs  7
+[/dev/sdb1].generation_errs  0
+[devid:3].write_io_errs      0
+[devid:3].read_io_errs       0
+[devid:3].flush_io_errs      0
+[devid:3].corruption_errs    0
+[devid:3].generation_errs    0
+`
+	s := parseBtrfsDeviceStats("/srv", []byte(input))
+	if s.Name != "/srv" || s.Errors != 11 || !s.Degraded || !slices.Equal(s.Failed, []string{"devid:3"}) {
+		t.Errorf("status = %+v", s)
+	}
+	if want := []string{"/dev/sdb1: write_io_errs 4, corruption_errs 7"}; !slices.Equal(s.Notes, want) {
+		t.Errorf("notes = %q, want %q", s.Notes, want)
+	}
+}
+
+func TestArrayChange(t *testing.T) {
+	healthy := ArrayStatus{Kind: ArrayMD, Name: "md0"}
+	degraded := ArrayStatus{Kind: ArrayMD, Name: "md0", Degraded: true, Failed: []string{"sda1"}}
+	errs := func(n int64) ArrayStatus { return ArrayStatus{Kind: ArrayBtrfs, Name: "/srv", Errors: n} }
+
+	tests := []struct {
+		name       string
+		prev       ArrayStatus
+		known      bool
+		cur        ArrayStatus
+		wantReport bool
+		wantNew    int64
+	}{
+		{"healthy when first seen", ArrayStatus{}, false, healthy, false, 0},
+		{"degraded when first seen", ArrayStatus{}, false, degraded, true, 0},
+		{"degrades", healthy, true, degraded, true, 0},
+		{"still degraded", degraded, true, degraded, false, 0},
+		{"recovers", degraded, true, healthy, true, 0},
+		{"errors rise", errs(3), true, errs(5), true, 2},
+		{"errors unchanged", errs(5), true, errs(5), false, 0},
+		{"counters reset", errs(5), true, errs(0), true, 0},
+	}
+	for _, tt := range tests {
+		ev, report := arrayChange(tt.prev, tt.known, tt.cur)
+		if report != tt.wantReport || ev.NewErrors != tt.wantNew {
+			t.Errorf("%s: report = %v, new errors = %d; want %v, %d", tt.name, report, ev.NewErrors, tt.wantReport, tt.wantNew)
+		}
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	if got := unescapeMount(`/mnt/my\040disk`); got != "/mnt/my disk" {
+		t.Errorf("unescapeMount = %q", got)
+	}
+}
@@ -0,0 +1,283 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AuthorizedKey is one key line of an authorized_keys file.
+type AuthorizedKey struct {
+	Type        string // e.g. "ssh-ed25519"
+	Fingerprint string // SHA256 fingerprint, as ssh-keygen -l prints it
+	Comment     string
+	Options     string // e.g. `from="10.0.0.0/8",no-pty`; empty if none
+}
+
+// AuthorizedKeyEvent is emitted for a key added to an authorized_keys file.
+type AuthorizedKeyEvent struct {
+	Path    string
+	Account string // the account the file lets the key log in as
+	Key     AuthorizedKey
+}
+
+// AuthorizedKeysMonitor polls authorized_keys files and emits an event for
+// each key added to one, including keys in a file that did not exist. The
+// keys seen are saved to a state file, so keys added while the daemon was
+// not running are reported when it starts. On the first run there is no
+// state file, and the keys found are only recorded.
+type AuthorizedKeysMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	patterns     []string // globs of the files to watch
+	stateFile    string
+	known        map[string][]string // path -> fingerprints; nil before the first poll
+}
+
+// NewAuthorizedKeysMonitor creates a monitor of the files matching patterns,
+// e.g. "/home/*/.ssh/authorized_keys", saving the keys seen to stateFile.
+func NewAuthorizedKeysMonitor(pollInterval time.Duration, patterns []string, stateFile string) *AuthorizedKeysMonitor {
+	return &AuthorizedKeysMonitor{
+		pollInterval: pollInterval,
+		patterns:     patterns,
+		stateFile:    stateFile,
+	}
+}
+
+// Events starts the polling loop and returns a channel of added keys.
+func (m *AuthorizedKeysMonitor) Events(ctx context.Context) <-chan AuthorizedKeyEvent {
+	ch := make(chan AuthorizedKeyEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *AuthorizedKeysMonitor) poll(ctx context.Context, ch chan<- AuthorizedKeyEvent) {
+	defer close(ch)
+
+	m.known = m.loadState()
+	m.check(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx, ch)
+		}
+	}
+}
+
+func (m *AuthorizedKeysMonitor) check(ctx context.Context, ch chan<- AuthorizedKeyEvent) {
+	defer m.markPoll()
+
+	current := readAuthorizedKeys(m.patterns)
+	added := addedKeys(m.known, current)
+	known := fingerprints(current)
+	if m.known != nil && maps.EqualFunc(m.known, known, slices.Equal) {
+		return
+	}
+	first := m.known == nil
+	m.known = known
+	m.saveState()
+	if first {
+		slog.Debug("authorized_keys baseline recorded", "files", len(current))
+		return
+	}
+
+	for _, ev := range added {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readAuthorizedKeys reads the keys of the files matching patterns, by
+// path. Files that cannot be read are left out.
+func readAuthorizedKeys(patterns []string) map[string][]AuthorizedKey {
+	files := make(map[string][]AuthorizedKey)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					slog.Debug("reading authorized_keys failed", "path", path, "error", err)
+				}
+				continue
+			}
+			files[path] = ParseAuthorizedKeys(data)
+		}
+	}
+	return files
+}
+
+// addedKeys returns the keys in current that known does not have. A nil
+// known means nothing is known yet, and nothing is added.
+func addedKeys(known map[string][]string, current map[string][]AuthorizedKey) []AuthorizedKeyEvent {
+	if known == nil {
+		return nil
+	}
+	var added []AuthorizedKeyEvent
+	for _, path := range slices.Sorted(maps.Keys(current)) {
+		for _, key := range current[path] {
+			if !slices.Contains(known[path], key.Fingerprint) {
+				added = append(added, AuthorizedKeyEvent{Path: path, Account: keyAccount(path), Key: key})
+			}
+		}
+	}
+	return added
+}
+
+// fingerprints returns the fingerprints of the keys of each file.
+func fingerprints(files map[string][]AuthorizedKey) map[string][]string {
+	known := make(map[string][]string, len(files))
+	for path, keys := range files {
+		fps := make([]string, 0, len(keys))
+		for _, k := range keys {
+			fps = append(fps, k.Fingerprint)
+		}
+		known[path] = fps
+	}
+	return known
+}
+
+// loadState returns the fingerprints saved by the previous run, or nil if
+// there are none.
+func (m *AuthorizedKeysMonitor) loadState() map[string][]string {
+	data, err := os.ReadFile(m.stateFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("reading authorized_keys state failed", "path", m.stateFile, "error", err)
+		}
+		return nil
+	}
+	var known map[string][]string
+	if err := json.Unmarshal(data, &known); err != nil {
+		slog.Warn("authorized_keys state is corrupt, starting over", "path", m.stateFile, "error", err)
+		return nil
+	}
+	if known == nil {
+		known = make(map[string][]string)
+	}
+	return known
+}
+
+func (m *AuthorizedKeysMonitor) saveState() {
+	data, err := json.Marshal(m.known)
+	if err == nil {
+		err = os.WriteFile(m.stateFile, data, 0o600)
+	}
+	if err != nil {
+		slog.Warn("saving authorized_keys state failed", "path", m.stateFile, "error", err)
+	}
+}
+
+// keyAccount returns the account an authorized_keys file belongs to, from
+// its path: "root" for /root/.ssh/authorized_keys and "alice" for
+// /home/alice/.ssh/authorized_keys. Other paths are returned as they are.
+func keyAccount(path string) string {
+	dir := filepath.Dir(filepath.Dir(path))
+	if filepath.Base(filepath.Dir(path)) != ".ssh" {
+		return path
+	}
+	if dir == "/root" {
+		return "root"
+	}
+	return filepath.Base(dir)
+}
+
+// ParseAuthorizedKeys parses the key lines of an authorized_keys file.
+// Blank lines, comments, and lines without a key are skipped.
+func ParseAuthorizedKeys(data []byte) []AuthorizedKey {
+	var keys []AuthorizedKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, ok := parseAuthorizedKey(line); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// parseAuthorizedKey parses one key line: optional options, the key type,
+// the base64 key, and an optional comment.
+func parseAuthorizedKey(line string) (AuthorizedKey, bool) {
+	var options string
+	if !isKeyType(firstField(line)) {
+		options, line = splitOptions(line)
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !isKeyType(fields[0]) {
+		return AuthorizedKey{}, false
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return AuthorizedKey{}, false
+	}
+	sum := sha256.Sum256(blob)
+	return AuthorizedKey{
+		Type:        fields[0],
+		Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+		Comment:     strings.Join(fields[2:], " "),
+		Options:     options,
+	}, true
+}
+
+// splitOptions splits the options at the start of a key line from the
+// rest. Options end at the first space outside double quotes, in which a
+// backslash escapes a quote.
+func splitOptions(line string) (options, rest string) {
+	quoted, escaped := false, false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			return line[:i], strings.TrimSpace(line[i:])
+		}
+	}
+	return line, ""
+}
+
+func firstField(line string) string {
+	if f := strings.Fields(line); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// isKeyType reports whether s names an SSH public key type.
+func isKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-sha2-") ||
+		strings.HasPrefix(s, "sk-ssh-") || strings.HasPrefix(s, "sk-ecdsa-")
+}
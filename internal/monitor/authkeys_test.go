@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKey = "AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	data := []byte(`# deploy keys
+ssh-ed25519 ` + testKey + ` alice@laptop
+
+from="10.0.0.0/8",command="echo \"hi there\"",no-pty ssh-ed25519 ` + testKey + ` backup key
+not a key line
+ssh-rsa !!!notbase64 broken
+`)
+	keys := ParseAuthorizedKeys(data)
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want 2: %+v", len(keys), keys)
+	}
+	if k := keys[0]; k.Type != "ssh-ed25519" || k.Comment != "alice@laptop" || k.Options != "" {
+		t.Errorf("key 0 = %+v", k)
+	}
+	if k := keys[1]; k.Options != `from="10.0.0.0/8",command="echo \"hi there\"",no-pty` || k.Comment != "backup key" {
+		t.Errorf("key 1 = %+v", k)
+	}
+	if keys[0].Fingerprint != keys[1].Fingerprint || len(keys[0].Fingerprint) != len("SHA256:")+43 {
+		t.Errorf("fingerprints = %q, %q", keys[0].Fingerprint, keys[1].Fingerprint)
+	}
+}
+
+func TestKeyAccount(t *testing.T) {
+	for path, want := range map[string]string{
+		"/root/.ssh/authorized_keys":       "root",
+		"/home/alice/.ssh/authorized_keys": "alice",
+		"/etc/ssh/keys/bob":                "/etc/ssh/keys/bob",
+	} {
+		if got := keyAccount(path); got != want {
+			t.Errorf("keyAccount(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAuthorizedKeysMonitorCheck(t *testing.T) {
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "alice", ".ssh", "authorized_keys")
+	os.MkdirAll(filepath.Dir(keys), 0o700)
+	os.WriteFile(keys, []byte("ssh-ed25519 "+testKey+" alice@laptop\n"), 0o600)
+
+	state := filepath.Join(dir, "state.json")
+	m := NewAuthorizedKeysMonitor(0, []string{filepath.Join(dir, "*", ".ssh", "authorized_keys")}, state)
+	ch := make(chan AuthorizedKeyEvent, 8)
+
+	// The first run only records a baseline.
+	m.known = m.loadState()
+	m.check(context.Background(), ch)
+	if len(ch) != 0 {
+		t.Fatalf("baseline reported %d keys", len(ch))
+	}
+
+	// A new key is reported by a later run, from the saved state.
+	os.WriteFile(keys, []byte("ssh-ed25519 "+testKey+" alice@laptop\nssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ== intruder\n"), 0o600)
+	m = NewAuthorizedKeysMonitor(0, m.patterns, state)
+	m.known = m.loadState()
+	m.check(context.Background(), ch)
+	if len(ch) != 1 {
+		t.Fatalf("got %d events, want 1", len(ch))
+	}
+	ev := <-ch
+	if ev.Path != keys || ev.Account != "alice" || ev.Key.Comment != "intruder" {
+		t.Errorf("event = %+v", ev)
+	}
+
+	m.check(context.Background(), ch)
+	if len(ch) != 0 {
+		t.Errorf("key reported again")
+	}
+}
@@ -0,0 +1,328 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// dirScanTimeout bounds the walk that finds the largest directories, so a
+// huge filesystem cannot stall the poll loop.
+const dirScanTimeout = 30 * time.Second
+
+// DiskUsage is a statfs snapshot of one mounted filesystem.
+type DiskUsage struct {
+	Mount      string
+	Total      uint64 // bytes
+	Free       uint64 // bytes free, including root-reserved blocks
+	Avail      uint64 // bytes available to unprivileged users
+	Inodes     uint64 // 0 when the filesystem has no fixed inode table (e.g. btrfs)
+	InodesFree uint64
+}
+
+// UsedPct returns block usage the way df reports it: used space as a
+// percentage of the space usable by unprivileged users.
+func (u DiskUsage) UsedPct() float64 {
+	used := u.Total - u.Free
+	if used+u.Avail == 0 {
+		return 0
+	}
+	return float64(used) / float64(used+u.Avail) * 100
+}
+
+// InodePct returns inode usage as a percentage, or 0 when the filesystem
+// does not report inodes.
+func (u DiskUsage) InodePct() float64 {
+	if u.Inodes == 0 {
+		return 0
+	}
+	return float64(u.Inodes-u.InodesFree) / float64(u.Inodes) * 100
+}
+
+// DiskThresholds are the usage percentages at which a filesystem is reported.
+// A zero threshold is disabled.
+type DiskThresholds struct {
+	WarnPct      float64
+	CritPct      float64
+	InodeWarnPct float64
+	InodeCritPct float64
+}
+
+// DiskMount is a mountpoint to watch and its thresholds.
+type DiskMount struct {
+	Path string
+	DiskThresholds
+}
+
+// DiskLevel describes how full a filesystem is.
+type DiskLevel int
+
+const (
+	DiskOK       DiskLevel = iota
+	DiskWarning            // usage at or above the warning threshold
+	DiskCritical           // usage at or above the critical threshold
+)
+
+// String returns "ok", "warning", or "critical".
+func (l DiskLevel) String() string {
+	switch l {
+	case DiskWarning:
+		return "warning"
+	case DiskCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// DirSize is a directory and the bytes stored beneath it.
+type DirSize struct {
+	Path  string
+	Bytes int64
+}
+
+// DiskSpaceEvent is emitted when a filesystem's level rises, and with level
+// DiskOK when it has recovered.
+type DiskSpaceEvent struct {
+	Timestamp time.Time
+	Usage     DiskUsage
+	Level     DiskLevel
+	Resource  string    // "space" or "inodes", whichever is worse
+	Percent   float64   // usage of that resource
+	TopDirs   []DirSize // largest directories on the filesystem, biggest first
+	Partial   bool      // the directory scan hit its time limit
+}
+
+// DiskSpaceMonitor polls statfs on configured mountpoints and emits events
+// when space or inode usage crosses warning or critical thresholds.
+type DiskSpaceMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	mu           sync.Mutex // guards mounts, which SetMounts changes
+	mounts       []DiskMount
+	topDirs      int
+	lastLevel    map[string]DiskLevel
+}
+
+// NewDiskSpaceMonitor creates a disk space monitor. topDirs is how many of
+// the largest directories are listed in each event; 0 skips the scan.
+func NewDiskSpaceMonitor(pollInterval time.Duration, mounts []DiskMount, topDirs int) *DiskSpaceMonitor {
+	return &DiskSpaceMonitor{
+		pollInterval: pollInterval,
+		mounts:       mounts,
+		topDirs:      topDirs,
+		lastLevel:    make(map[string]DiskLevel),
+	}
+}
+
+// SetMounts replaces the mountpoints and thresholds of a running monitor,
+// e.g. on a config reload. Mountpoints kept keep their current level, so
+// a reload alone does not re-report them.
+func (m *DiskSpaceMonitor) SetMounts(mounts []DiskMount) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mounts = mounts
+}
+
+// Events starts the disk space polling loop and returns a channel of events.
+func (m *DiskSpaceMonitor) Events(ctx context.Context) <-chan DiskSpaceEvent {
+	ch := make(chan DiskSpaceEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *DiskSpaceMonitor) poll(ctx context.Context, ch chan<- DiskSpaceEvent) {
+	defer close(ch)
+
+	// Initial poll.
+	m.checkAll(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx, ch)
+		}
+	}
+}
+
+func (m *DiskSpaceMonitor) checkAll(ctx context.Context, ch chan<- DiskSpaceEvent) {
+	defer m.markPoll()
+	m.mu.Lock()
+	mounts := m.mounts
+	m.mu.Unlock()
+
+	for path := range m.lastLevel {
+		if !slices.ContainsFunc(mounts, func(mount DiskMount) bool { return mount.Path == path }) {
+			delete(m.lastLevel, path)
+		}
+	}
+	for _, mount := range mounts {
+		u, err := ReadDiskUsage(mount.Path)
+		if err != nil {
+			slog.Debug("statfs failed", "mount", mount.Path, "error", err)
+			continue
+		}
+
+		level, resource, pct := EvaluateDiskSpace(u, mount.DiskThresholds)
+		prev := m.lastLevel[mount.Path]
+		m.lastLevel[mount.Path] = level
+
+		// Emit when the level rises, and once when it is back to ok.
+		recovered := level == DiskOK && prev > DiskOK
+		if level <= prev && !recovered {
+			continue
+		}
+
+		ev := DiskSpaceEvent{
+			Timestamp: time.Now(),
+			Usage:     u,
+			Level:     level,
+			Resource:  resource,
+			Percent:   pct,
+		}
+		if m.topDirs > 0 && resource == "space" && level > DiskOK {
+			scanCtx, cancel := context.WithTimeout(ctx, dirScanTimeout)
+			ev.TopDirs, ev.Partial = LargestDirs(scanCtx, mount.Path, m.topDirs)
+			cancel()
+		}
+
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return
+		default:
+			selfstat.Drop(1)
+		}
+	}
+}
+
+// EvaluateDiskSpace returns the level for a filesystem along with the
+// resource ("space" or "inodes") and percentage that determined it.
+func EvaluateDiskSpace(u DiskUsage, th DiskThresholds) (DiskLevel, string, float64) {
+	spacePct := u.UsedPct()
+	inodePct := u.InodePct()
+
+	spaceLevel := diskLevel(spacePct, th.WarnPct, th.CritPct)
+	inodeLevel := diskLevel(inodePct, th.InodeWarnPct, th.InodeCritPct)
+
+	if inodeLevel > spaceLevel {
+		return inodeLevel, "inodes", inodePct
+	}
+	return spaceLevel, "space", spacePct
+}
+
+func diskLevel(pct, warn, crit float64) DiskLevel {
+	switch {
+	case crit > 0 && pct >= crit:
+		return DiskCritical
+	case warn > 0 && pct >= warn:
+		return DiskWarning
+	default:
+		return DiskOK
+	}
+}
+
+// LargestDirs walks the filesystem mounted at root without crossing into
+// other filesystems and returns the n largest top-level directories by
+// allocated size. If ctx expires mid-walk, the sizes found so far are
+// returned and partial is true.
+func LargestDirs(ctx context.Context, root string, n int) (dirs []DirSize, partial bool) {
+	var rootDev uint64
+	if info, err := os.Lstat(root); err == nil {
+		rootDev, _, _ = fileAllocation(info)
+	}
+
+	sizes := make(map[string]int64)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		if ctx.Err() != nil {
+			partial = true
+			return filepath.SkipAll
+		}
+		if path == root {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		dev, allocated, ok := fileAllocation(info)
+		if ok && dev != rootDev {
+			// Another filesystem is mounted here; it has its own statfs.
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		top, _, _ := strings.Cut(rel, string(filepath.Separator))
+		if top == rel && !d.IsDir() {
+			return nil // files directly under root are not attributed
+		}
+		if ok {
+			sizes[filepath.Join(root, top)] += allocated
+		} else {
+			sizes[filepath.Join(root, top)] += info.Size()
+		}
+		return nil
+	})
+
+	for path, size := range sizes {
+		dirs = append(dirs, DirSize{Path: path, Bytes: size})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Bytes != dirs[j].Bytes {
+			return dirs[i].Bytes > dirs[j].Bytes
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs, partial
+}
+
+// FormatDiskSpace returns a human-readable description of a disk space event.
+func FormatDiskSpace(ev DiskSpaceEvent) string {
+	u := ev.Usage
+	var b strings.Builder
+	fmt.Fprintf(&b, "Mount: %s\n", u.Mount)
+	fmt.Fprintf(&b, "  Space:  %s used of %s, %s available (%.1f%%)\n",
+		format.Bytes(int64(u.Total-u.Free)), format.Bytes(int64(u.Total)),
+		format.Bytes(int64(u.Avail)), u.UsedPct())
+	if u.Inodes > 0 {
+		fmt.Fprintf(&b, "  Inodes: %d used of %d (%.1f%%)\n",
+			u.Inodes-u.InodesFree, u.Inodes, u.InodePct())
+	}
+	if len(ev.TopDirs) > 0 {
+		b.WriteString("\nLargest directories:\n")
+		for _, d := range ev.TopDirs {
+			fmt.Fprintf(&b, "  %10s  %s\n", format.Bytes(d.Bytes), d.Path)
+		}
+		if ev.Partial {
+			b.WriteString("  (scan stopped early; sizes are lower bounds)\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateDiskSpace(t *testing.T) {
+	th := DiskThresholds{WarnPct: 90, CritPct: 97, InodeWarnPct: 90, InodeCritPct: 97}
+	const gb = 1 << 30
+
+	tests := []struct {
+		name     string
+		usage    DiskUsage
+		level    DiskLevel
+		resource string
+	}{
+		{"ok", DiskUsage{Total: 100 * gb, Free: 50 * gb, Avail: 45 * gb, Inodes: 1000, InodesFree: 900}, DiskOK, "space"},
+		// 86 GB used of 86+8 usable: the root reserve counts against users.
+		{"warning counts reserve", DiskUsage{Total: 100 * gb, Free: 14 * gb, Avail: 8 * gb, Inodes: 1000, InodesFree: 900}, DiskWarning, "space"},
+		{"critical", DiskUsage{Total: 100 * gb, Free: 5 * gb, Avail: 1 * gb, Inodes: 1000, InodesFree: 900}, DiskCritical, "space"},
+		{"inodes worse", DiskUsage{Total: 100 * gb, Free: 50 * gb, Avail: 45 * gb, Inodes: 1000, InodesFree: 20}, DiskCritical, "inodes"},
+		{"no inode table", DiskUsage{Total: 100 * gb, Free: 50 * gb, Avail: 45 * gb}, DiskOK, "space"},
+	}
+	for _, tt := range tests {
+		level, resource, _ := EvaluateDiskSpace(tt.usage, th)
+		if level != tt.level || resource != tt.resource {
+			t.Errorf("%s: got %s/%s, want %s/%s", tt.name, level, resource, tt.level, tt.resource)
+		}
+	}
+
+	// Zero thresholds are disabled.
+	full := DiskUsage{Total: 100, Free: 0, Avail: 0, Inodes: 10, InodesFree: 0}
+	if level, _, _ := EvaluateDiskSpace(full, DiskThresholds{}); level != DiskOK {
+		t.Errorf("disabled thresholds: level = %s, want ok", level)
+	}
+}
+
+func TestLargestDirs(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, size int) {
+		t.Helper()
+		path := filepath.Join(root, rel)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("big/a/one.bin", 256*1024)
+	write("big/two.bin", 256*1024)
+	write("medium/three.bin", 128*1024)
+	write("small/four.bin", 4*1024)
+	write("toplevel.bin", 1024*1024)
+
+	dirs, partial := LargestDirs(context.Background(), root, 2)
+	if partial {
+		t.Error("scan reported partial without a deadline")
+	}
+	if len(dirs) != 2 {
+		t.Fatalf("got %d dirs, want 2: %+v", len(dirs), dirs)
+	}
+	if dirs[0].Path != filepath.Join(root, "big") || dirs[1].Path != filepath.Join(root, "medium") {
+		t.Errorf("dirs = %+v, want big then medium", dirs)
+	}
+	if dirs[0].Bytes < 512*1024 {
+		t.Errorf("big = %d bytes, want nested files counted", dirs[0].Bytes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, partial := LargestDirs(ctx, root, 2); !partial {
+		t.Error("cancelled scan should report partial")
+	}
+}
+
+func TestFormatDiskSpace(t *testing.T) {
+	ev := DiskSpaceEvent{
+		Usage:   DiskUsage{Mount: "/var", Total: 100 << 30, Free: 2 << 30, Avail: 1 << 30, Inodes: 1000, InodesFree: 400},
+		Level:   DiskCritical,
+		TopDirs: []DirSize{{Path: "/var/log", Bytes: 40 << 30}},
+		Partial: true,
+	}
+	out := FormatDiskSpace(ev)
+	for _, want := range []string{"Mount: /var", "Inodes: 600 used of 1000", "40.0 GB  /var/log", "lower bounds"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package monitor
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// ReadDiskUsage returns a statfs snapshot of the filesystem mounted at path.
+func ReadDiskUsage(path string) (DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return DiskUsage{
+		Mount:      path,
+		Total:      uint64(st.Blocks) * bsize,
+		Free:       uint64(st.Bfree) * bsize,
+		Avail:      uint64(st.Bavail) * bsize,
+		Inodes:     uint64(st.Files),
+		InodesFree: uint64(st.Ffree),
+	}, nil
+}
+
+// fileAllocation returns the device a file is on and the bytes allocated to
+// it. ok is false when the platform does not report them.
+func fileAllocation(info fs.FileInfo) (dev uint64, allocated int64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), int64(st.Blocks) * 512, true
+}
@@ -0,0 +1,263 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// Kinds of TraceEvent.
+const (
+	TraceOOM   = "oom"
+	TraceCrash = "crash"
+)
+
+// TraceEvent is an OOM kill or a crash seen by the eBPF tracer.
+type TraceEvent struct {
+	Timestamp  time.Time
+	Kind       string // TraceOOM or TraceCrash
+	PID        int
+	Comm       string
+	CGroup     string // cgroup v2 path of the process
+	AnonRSS    int64  // bytes; OOM kills on kernels 6.8 and later
+	FileRSS    int64
+	ShmemRSS   int64
+	Signal     int // crash signal
+	CoreDumped bool
+}
+
+// crashSignals are the signals reported when they kill a process: those
+// whose default action dumps core and that mean the process crashed.
+var crashSignals = map[int]string{
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	11: "SIGSEGV",
+	31: "SIGSYS",
+}
+
+// traceExitProbe reports processes killed by a crash signal. exit_code is
+// set before the tracepoint fires: the signal in its low 7 bits, and 0x80
+// if a core was dumped. Non-zero exit codes are not traced, as every failing
+// shell command has one.
+const traceExitProbe = `
+tracepoint:sched:sched_process_exit
+/pid == tid && (curtask->exit_code & 0x7f) != 0/
+{
+	$sig = curtask->exit_code & 0x7f;
+	if ($sig == 4 || $sig == 5 || $sig == 6 || $sig == 7 || $sig == 8 || $sig == 11 || $sig == 31) {
+		printf("crash pid=%d sig=%d core=%d cgroup=%s comm=%s\n", pid, $sig,
+			(curtask->exit_code >> 7) & 1, cgroup_path(cgroup), comm);
+	}
+}
+`
+
+// traceScripts are the bpftrace programs tried in order, until one
+// attaches. The first reads the victim's memory from the oom:mark_victim
+// tracepoint, which has it from kernel 6.8; the second works on older
+// kernels with BTF. The victim's cgroup comes from oom_kill_process, as
+// both probes run in the context of the task that hit the OOM.
+var traceScripts = []string{
+	`
+kprobe:oom_kill_process
+{
+	$t = ((struct oom_control *)arg0)->chosen;
+	@oom_cgroup[$t->pid] = $t->cgroups->dfl_cgrp->kn->id;
+}
+
+tracepoint:oom:mark_victim
+{
+	printf("oom pid=%d anon_rss_kb=%lu file_rss_kb=%lu shmem_rss_kb=%lu cgroup=%s comm=%s\n",
+		args->pid, args->anon_rss, args->file_rss, args->shmem_rss,
+		cgroup_path(@oom_cgroup[args->pid]), args->comm);
+	delete(@oom_cgroup[args->pid]);
+}
+` + traceExitProbe,
+	`
+kprobe:oom_kill_process
+{
+	$t = ((struct oom_control *)arg0)->chosen;
+	printf("oom pid=%d cgroup=%s comm=%s\n", $t->pid,
+		cgroup_path($t->cgroups->dfl_cgrp->kn->id), $t->comm);
+}
+` + traceExitProbe,
+}
+
+// traceRestartWait is how long the tracer waits before restarting bpftrace
+// after it exits.
+const traceRestartWait = 5 * time.Second
+
+// TraceMonitor runs bpftrace to catch OOM kills and crashes in the kernel,
+// with their exact cgroup, command, and memory, even when the kernel's log
+// lines about them are rate-limited or the journal drops them. It needs
+// root or CAP_BPF and CAP_PERFMON.
+type TraceMonitor struct{}
+
+// NewTraceMonitor creates an eBPF tracer.
+func NewTraceMonitor() *TraceMonitor {
+	return &TraceMonitor{}
+}
+
+// Events starts bpftrace and returns a channel of the OOM kills and crashes
+// it traces. The channel is closed when ctx is done, or when no script can
+// be attached.
+func (m *TraceMonitor) Events(ctx context.Context) <-chan TraceEvent {
+	ch := make(chan TraceEvent, 16)
+	go m.run(ctx, ch)
+	return ch
+}
+
+func (m *TraceMonitor) run(ctx context.Context, ch chan<- TraceEvent) {
+	defer close(ch)
+
+	script := 0
+	for ctx.Err() == nil {
+		attached, err := m.trace(ctx, traceScripts[script], ch)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case !attached && script+1 < len(traceScripts):
+			slog.Debug("eBPF tracer script failed to attach, trying the next", "error", err)
+			script++
+			continue
+		case !attached:
+			slog.Warn("eBPF tracer disabled, bpftrace failed to attach", "error", err)
+			return
+		}
+		slog.Warn("eBPF tracer exited, restarting", "error", err)
+		select {
+		case <-time.After(traceRestartWait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// trace runs one bpftrace script until it exits, and reports whether it
+// attached its probes.
+func (m *TraceMonitor) trace(ctx context.Context, script string, ch chan<- TraceEvent) (attached bool, err error) {
+	cmd := exec.CommandContext(ctx, "bpftrace", "-f", "json", "-e", script)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var msg struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil {
+			continue
+		}
+		switch msg.Type {
+		case "attached_probes":
+			attached = true
+			slog.Info("eBPF tracer attached")
+		case "printf":
+			var line string
+			if json.Unmarshal(msg.Data, &line) != nil {
+				continue
+			}
+			ev, ok := parseTraceLine(line)
+			if !ok {
+				continue
+			}
+			ev.Timestamp = time.Now()
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+			default:
+				selfstat.Drop(1)
+			}
+		}
+	}
+
+	err = cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+	return attached, err
+}
+
+// parseTraceLine parses a line printed by traceScripts: a kind, then
+// key=value fields, with comm last since it may contain spaces.
+func parseTraceLine(line string) (TraceEvent, bool) {
+	line = strings.TrimRight(line, "\n")
+	kind, rest, _ := strings.Cut(line, " ")
+	if kind != TraceOOM && kind != TraceCrash {
+		return TraceEvent{}, false
+	}
+	ev := TraceEvent{Kind: kind}
+	rest, ev.Comm, _ = strings.Cut(rest, "comm=")
+	for _, field := range strings.Fields(rest) {
+		key, value, _ := strings.Cut(field, "=")
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch key {
+		case "pid":
+			ev.PID = int(n)
+		case "sig":
+			ev.Signal = int(n)
+		case "core":
+			ev.CoreDumped = n != 0
+		case "cgroup":
+			ev.CGroup = value
+		case "anon_rss_kb":
+			ev.AnonRSS = n * 1024
+		case "file_rss_kb":
+			ev.FileRSS = n * 1024
+		case "shmem_rss_kb":
+			ev.ShmemRSS = n * 1024
+		}
+	}
+	return ev, ev.PID > 0
+}
+
+// SignalName returns the name of a crash signal, e.g. "SIGSEGV".
+func SignalName(sig int) string {
+	if name, ok := crashSignals[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", sig)
+}
+
+// FormatTrace returns a human-readable description of a traced event.
+func FormatTrace(ev TraceEvent) string {
+	var b strings.Builder
+	b.WriteString("Traced in the kernel with eBPF.\n")
+	fmt.Fprintf(&b, "Process: %s (pid %d)\n", ev.Comm, ev.PID)
+	if ev.CGroup != "" {
+		fmt.Fprintf(&b, "Cgroup: %s\n", ev.CGroup)
+	}
+	switch ev.Kind {
+	case TraceOOM:
+		if rss := ev.AnonRSS + ev.FileRSS + ev.ShmemRSS; rss > 0 {
+			fmt.Fprintf(&b, "RSS: %s (anon %s, file %s, shmem %s)\n", format.Bytes(rss),
+				format.Bytes(ev.AnonRSS), format.Bytes(ev.FileRSS), format.Bytes(ev.ShmemRSS))
+		}
+	case TraceCrash:
+		fmt.Fprintf(&b, "Signal: %s", SignalName(ev.Signal))
+		if ev.CoreDumped {
+			b.WriteString(" (core dumped)")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTraceLine(t *testing.T) {
+	ev, ok := parseTraceLine("oom pid=4242 anon_rss_kb=1048576 file_rss_kb=2048 shmem_rss_kb=0 cgroup=/user.slice/user-1000.slice/app-web.scope comm=Web Content\n")
+	if !ok {
+		t.Fatal("OOM line not parsed")
+	}
+	if ev.Kind != TraceOOM || ev.PID != 4242 || ev.Comm != "Web Content" {
+		t.Errorf("ev = %+v", ev)
+	}
+	if ev.CGroup != "/user.slice/user-1000.slice/app-web.scope" {
+		t.Errorf("CGroup = %q", ev.CGroup)
+	}
+	if ev.AnonRSS != 1<<30 || ev.FileRSS != 2<<20 || ev.ShmemRSS != 0 {
+		t.Errorf("RSS = %d/%d/%d, want bytes", ev.AnonRSS, ev.FileRSS, ev.ShmemRSS)
+	}
+
+	ev, ok = parseTraceLine("crash pid=77 sig=11 core=1 cgroup=/system.slice/foo.service comm=foo")
+	if !ok || ev.Kind != TraceCrash || ev.Signal != 11 || !ev.CoreDumped || ev.Comm != "foo" {
+		t.Errorf("crash = %+v, %v", ev, ok)
+	}
+
+	for _, line := range []string{"", "exit pid=1 comm=init", "oom cgroup=/ comm=x"} {
+		if _, ok := parseTraceLine(line); ok {
+			t.Errorf("parseTraceLine(%q) accepted", line)
+		}
+	}
+}
+
+func TestFormatTrace(t *testing.T) {
+	got := FormatTrace(TraceEvent{Kind: TraceCrash, PID: 77, Comm: "foo", CGroup: "/system.slice/foo.service", Signal: 11, CoreDumped: true})
+	for _, want := range []string{"Process: foo (pid 77)", "Cgroup: /system.slice/foo.service", "Signal: SIGSEGV (core dumped)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("FormatTrace missing %q:\n%s", want, got)
+		}
+	}
+
+	got = FormatTrace(TraceEvent{Kind: TraceOOM, PID: 1, Comm: "java", AnonRSS: 1 << 30})
+	if !strings.Contains(got, "RSS: ") || strings.Contains(got, "Cgroup") {
+		t.Errorf("FormatTrace(oom) =\n%s", got)
+	}
+	if SignalName(9) != "signal 9" {
+		t.Errorf("SignalName(9) = %q", SignalName(9))
+	}
+}
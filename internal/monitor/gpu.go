@@ -1,18 +1,19 @@
 package monitor
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/sysdep"
 )
 
 // GPUVendor identifies the GPU driver/vendor.
@@ -27,36 +28,154 @@ const (
 // GPUStatus represents the current state of a GPU.
 type GPUStatus struct {
 	CardPath    string    // e.g., "/sys/class/drm/card0"
+	PCIAddress  string    // e.g., "0000:01:00.0"; empty if unknown
 	Vendor      GPUVendor // detected driver vendor
 	Temperature int       // degrees Celsius, 0 if unavailable
 	TempCrit    int       // critical threshold, 0 if unavailable
 	VRAMUsed    int64     // bytes, 0 if unavailable
 	VRAMTotal   int64     // bytes, 0 if unavailable
+
+	// Intel cards only.
+	SharedMemUsed   int64    // bytes of system memory in use, for integrated GPUs
+	FreqMHz         int      // actual clock, 0 if unavailable
+	MaxFreqMHz      int      // maximum clock, 0 if unavailable
+	ThrottleReasons []string // why the clock is limited, e.g. "thermal", "pl1"
+
+	// From nvidia-smi, for NVIDIA cards only.
+	PowerDraw         float64      // watts, 0 if unavailable
+	PowerLimit        float64      // watts, 0 if unavailable
+	ECCCorrected      int64        // corrected ECC errors since the driver loaded
+	ECCUncorrected    int64        // uncorrectable ECC errors since the driver loaded
+	RetiredPages      int64        // memory pages retired or rows remapped for ECC errors
+	RetirementPending bool         // a page retirement or row remap waits for a reset
+	PCIeReplays       int64        // PCIe link replays
+	Processes         []GPUProcess // by VRAM used, most first
+}
+
+// Card returns the card's name, e.g. "card0".
+func (s GPUStatus) Card() string {
+	return filepath.Base(s.CardPath)
+}
+
+// ID returns the card's PCI address, or its name if the address is
+// unknown. Card numbers can change across reboots, PCI addresses do not.
+func (s GPUStatus) ID() string {
+	if s.PCIAddress != "" {
+		return s.PCIAddress
+	}
+	return s.Card()
+}
+
+// Label returns the card's name with its PCI address, e.g.
+// "card1 (0000:03:00.0)", for summaries.
+func (s GPUStatus) Label() string {
+	if s.PCIAddress != "" {
+		return s.Card() + " (" + s.PCIAddress + ")"
+	}
+	return s.Card()
+}
+
+// GPUThresholds are the temperature and VRAM usage at which cards are
+// reported.
+type GPUThresholds struct {
+	TempWarn    int                 // degrees C
+	VRAMWarnPct int                 // percent of VRAM
+	Cards       []GPUCardThresholds // per-card overrides; the first match wins
+}
+
+// GPUCardThresholds override the thresholds for one card. A zero
+// threshold keeps the default.
+type GPUCardThresholds struct {
+	Card        string // index ("1" or "card1") or PCI address ("0000:03:00.0" or "03:00.0")
+	TempWarn    int
+	VRAMWarnPct int
+}
+
+// For returns the thresholds for a card.
+func (t GPUThresholds) For(s GPUStatus) (tempWarn, vramWarnPct int) {
+	tempWarn, vramWarnPct = t.TempWarn, t.VRAMWarnPct
+	for _, c := range t.Cards {
+		if !c.matches(s) {
+			continue
+		}
+		if c.TempWarn > 0 {
+			tempWarn = c.TempWarn
+		}
+		if c.VRAMWarnPct > 0 {
+			vramWarnPct = c.VRAMWarnPct
+		}
+		break
+	}
+	return tempWarn, vramWarnPct
+}
+
+func (c GPUCardThresholds) matches(s GPUStatus) bool {
+	card := strings.ToLower(c.Card)
+	if card == s.Card() || "card"+card == s.Card() {
+		return true
+	}
+	return s.PCIAddress != "" && normalizePCIAddress(card) == s.PCIAddress
+}
+
+// normalizePCIAddress returns a PCI address in sysfs form, lower case with
+// a four-digit domain: "0000:01:00.0". nvidia-smi prints an eight-digit
+// domain, and the domain is often left out.
+func normalizePCIAddress(addr string) string {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	switch strings.Count(addr, ":") {
+	case 1:
+		return "0000:" + addr
+	case 2:
+		domain, rest, _ := strings.Cut(addr, ":")
+		if len(domain) > 4 {
+			domain = domain[len(domain)-4:]
+		}
+		return domain + ":" + rest
+	}
+	return addr
 }
 
 // GPUEvent is emitted when GPU status crosses a threshold.
 type GPUEvent struct {
 	Timestamp time.Time
 	Status    GPUStatus
-	Reason    string // "thermal_warning", "vram_high"
+	Reason    string // "thermal_warning", "vram_high", "thermal_normal", "ecc_uncorrected", "retired_pages"
+	Growth    int64  // for "ecc_uncorrected" and "retired_pages", how much the count grew
+
+	TopConsumers []GPUProcess // for "vram_high", the processes using the most VRAM
 }
 
 // GPUMonitor polls GPU sysfs and optional vendor CLIs for health status.
 type GPUMonitor struct {
+	liveness
+
 	pollInterval time.Duration
-	tempWarn     int // temperature warning threshold (degrees C)
-	vramWarnPct  int // VRAM usage warning threshold (percent)
+	mu           sync.Mutex // guards thresholds, which SetThresholds changes
+	thresholds   GPUThresholds
+
+	hot    map[string]bool      // card IDs at or above their temperature limit at the last poll
+	nvidia *nvidiaSMI           // nil without NVIDIA cards or nvidia-smi
+	counts map[string]GPUStatus // card ID -> last reading, for ECC and retired page growth
 }
 
 // NewGPUMonitor creates a GPU monitor with the given settings.
-func NewGPUMonitor(pollInterval time.Duration, tempWarn, vramWarnPct int) *GPUMonitor {
+func NewGPUMonitor(pollInterval time.Duration, thresholds GPUThresholds) *GPUMonitor {
 	return &GPUMonitor{
 		pollInterval: pollInterval,
-		tempWarn:     tempWarn,
-		vramWarnPct:  vramWarnPct,
+		thresholds:   thresholds,
+		hot:          make(map[string]bool),
+		counts:       make(map[string]GPUStatus),
 	}
 }
 
+// SetThresholds replaces the thresholds of a running monitor, e.g. on a
+// config reload.
+func (m *GPUMonitor) SetThresholds(thresholds GPUThresholds) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thresholds = thresholds
+}
+
 // Events starts the GPU polling loop and returns a channel of GPU events.
 func (m *GPUMonitor) Events(ctx context.Context) <-chan GPUEvent {
 	ch := make(chan GPUEvent, 8)
@@ -67,6 +186,11 @@ func (m *GPUMonitor) Events(ctx context.Context) <-chan GPUEvent {
 func (m *GPUMonitor) poll(ctx context.Context, ch chan<- GPUEvent) {
 	defer close(ch)
 
+	if hasNvidiaGPU(DetectGPUs()) && sysdep.Have("nvidia-smi") {
+		m.nvidia = startNvidiaSMI(ctx, m.pollInterval)
+		m.nvidia.wait(ctx, 15*time.Second)
+	}
+
 	// Initial poll.
 	m.checkAll(ctx, ch)
 
@@ -84,23 +208,39 @@ func (m *GPUMonitor) poll(ctx context.Context, ch chan<- GPUEvent) {
 }
 
 func (m *GPUMonitor) checkAll(ctx context.Context, ch chan<- GPUEvent) {
+	defer m.markPoll()
+
 	gpus := DetectGPUs()
 	if len(gpus) == 0 {
 		return
 	}
+	m.mu.Lock()
+	thresholds := m.thresholds
+	m.mu.Unlock()
 
+	var smi map[string]nvidiaReading
+	if m.nvidia != nil {
+		smi = m.nvidia.latest()
+	}
 	for i := range gpus {
 		gpu := &gpus[i]
 		ReadGPUTemp(gpu)
 		ReadGPUVRAM(gpu)
-
-		// For NVIDIA, try nvidia-smi if sysfs data is missing.
-		if gpu.Vendor == GPUVendorNVIDIA && gpu.Temperature == 0 {
-			readNvidiaSMI(ctx, gpu)
+		ReadGPUFreq(gpu)
+		switch gpu.Vendor {
+		case GPUVendorNVIDIA:
+			applyNvidiaReading(gpu, smi)
+		case GPUVendorIntel:
+			if gpu.FreqMHz == 0 {
+				readIntelGPUTop(ctx, gpu)
+			}
 		}
 
 		// Emit events for thresholds.
-		if gpu.Temperature > 0 && gpu.Temperature >= m.tempWarn {
+		tempWarn, vramWarnPct := thresholds.For(*gpu)
+		id := gpu.ID()
+		if gpu.Temperature > 0 && gpu.Temperature >= tempWarn {
+			m.hot[id] = true
 			select {
 			case ch <- GPUEvent{
 				Timestamp: time.Now(),
@@ -110,25 +250,84 @@ func (m *GPUMonitor) checkAll(ctx context.Context, ch chan<- GPUEvent) {
 			case <-ctx.Done():
 				return
 			default:
+				selfstat.Drop(1)
+			}
+		} else if gpu.Temperature > 0 && m.hot[id] {
+			// Back under the limit: reported once so the cooldown resets.
+			delete(m.hot, id)
+			select {
+			case ch <- GPUEvent{
+				Timestamp: time.Now(),
+				Status:    *gpu,
+				Reason:    "thermal_normal",
+			}:
+			case <-ctx.Done():
+				return
+			default:
+				selfstat.Drop(1)
 			}
 		}
 
 		if gpu.VRAMTotal > 0 && gpu.VRAMUsed > 0 {
 			pct := int(gpu.VRAMUsed * 100 / gpu.VRAMTotal)
-			if pct >= m.vramWarnPct {
+			if pct >= vramWarnPct {
 				select {
 				case ch <- GPUEvent{
-					Timestamp: time.Now(),
-					Status:    *gpu,
-					Reason:    "vram_high",
+					Timestamp:    time.Now(),
+					Status:       *gpu,
+					Reason:       "vram_high",
+					TopConsumers: GPUVRAMConsumers(*gpu, 5),
 				}:
 				case <-ctx.Done():
 					return
 				default:
+					selfstat.Drop(1)
 				}
 			}
 		}
+
+		for _, ev := range m.countGrowth(*gpu) {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			default:
+				selfstat.Drop(1)
+			}
+		}
+	}
+}
+
+// countGrowth returns events for a card's uncorrectable ECC errors and
+// retired pages that grew since the last poll. The first reading of a
+// card is only recorded.
+func (m *GPUMonitor) countGrowth(gpu GPUStatus) []GPUEvent {
+	id := gpu.ID()
+	prev, seen := m.counts[id]
+	m.counts[id] = gpu
+	if !seen {
+		return nil
+	}
+
+	var events []GPUEvent
+	// Volatile ECC counts restart at zero when the driver reloads.
+	if grew := gpu.ECCUncorrected - prev.ECCUncorrected; grew > 0 {
+		events = append(events, GPUEvent{Timestamp: time.Now(), Status: gpu, Reason: "ecc_uncorrected", Growth: grew})
+	}
+	if grew := gpu.RetiredPages - prev.RetiredPages; grew > 0 {
+		events = append(events, GPUEvent{Timestamp: time.Now(), Status: gpu, Reason: "retired_pages", Growth: grew})
+	}
+	return events
+}
+
+// hasNvidiaGPU reports whether any card uses the NVIDIA driver.
+func hasNvidiaGPU(gpus []GPUStatus) bool {
+	for _, g := range gpus {
+		if g.Vendor == GPUVendorNVIDIA {
+			return true
+		}
 	}
+	return false
 }
 
 // DetectGPUs scans /sys/class/drm for GPU cards and identifies their vendor.
@@ -158,13 +357,31 @@ func DetectGPUs() []GPUStatus {
 		}
 
 		gpus = append(gpus, GPUStatus{
-			CardPath: cardPath,
-			Vendor:   vendor,
+			CardPath:   cardPath,
+			PCIAddress: gpuPCIAddress(cardPath),
+			Vendor:     vendor,
 		})
 	}
 	return gpus
 }
 
+// gpuPCIAddress returns the PCI address of a card from its device
+// symlink, e.g. "0000:01:00.0", or "" if the card is not a PCI device.
+func gpuPCIAddress(cardPath string) string {
+	target, err := os.Readlink(filepath.Join(cardPath, "device"))
+	if err != nil {
+		return ""
+	}
+	addr := filepath.Base(target)
+	if !pciAddressRe.MatchString(addr) {
+		return ""
+	}
+	return addr
+}
+
+// pciAddressRe matches a PCI address in sysfs form.
+var pciAddressRe = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
 // identifyGPUVendor reads the driver symlink to determine the GPU vendor.
 func identifyGPUVendor(cardPath string) GPUVendor {
 	driverLink := filepath.Join(cardPath, "device", "driver")
@@ -217,8 +434,13 @@ func ReadGPUTemp(gpu *GPUStatus) {
 	}
 }
 
-// ReadGPUVRAM reads VRAM usage from amdgpu sysfs.
+// ReadGPUVRAM reads VRAM usage from amdgpu sysfs, or for Intel cards from
+// their clients' DRM usage stats.
 func ReadGPUVRAM(gpu *GPUStatus) {
+	if gpu.Vendor == GPUVendorIntel {
+		readIntelVRAM(gpu, "/proc")
+		return
+	}
 	if gpu.Vendor != GPUVendorAMD {
 		return
 	}
@@ -228,41 +450,6 @@ func ReadGPUVRAM(gpu *GPUStatus) {
 	gpu.VRAMTotal = readSysfsInt64(filepath.Join(devicePath, "mem_info_vram_total"))
 }
 
-// readNvidiaSMI queries nvidia-smi for GPU temperature and VRAM usage.
-func readNvidiaSMI(ctx context.Context, gpu *GPUStatus) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "nvidia-smi",
-		"--query-gpu=temperature.gpu,memory.used,memory.total",
-		"--format=csv,noheader,nounits")
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-
-	if err := cmd.Run(); err != nil {
-		slog.Debug("nvidia-smi query failed", "error", err)
-		return
-	}
-
-	// Output: "72, 4096, 8192"
-	parts := strings.Split(strings.TrimSpace(stdout.String()), ",")
-	if len(parts) >= 1 {
-		if v, err := strconv.Atoi(strings.TrimSpace(parts[0])); err == nil {
-			gpu.Temperature = v
-		}
-	}
-	if len(parts) >= 2 {
-		if v, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err == nil {
-			gpu.VRAMUsed = v * 1024 * 1024 // MiB to bytes
-		}
-	}
-	if len(parts) >= 3 {
-		if v, err := strconv.ParseInt(strings.TrimSpace(parts[2]), 10, 64); err == nil {
-			gpu.VRAMTotal = v * 1024 * 1024 // MiB to bytes
-		}
-	}
-}
-
 // readSysfsInt reads an integer from a sysfs file.
 func readSysfsInt(path string) int {
 	data, err := os.ReadFile(path)
@@ -292,7 +479,10 @@ func readSysfsInt64(path string) int64 {
 // FormatGPUStatus returns a human-readable summary of GPU status.
 func FormatGPUStatus(gpu GPUStatus) string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "GPU: %s (%s)\n", filepath.Base(gpu.CardPath), gpu.Vendor)
+	fmt.Fprintf(&b, "GPU: %s (%s)\n", gpu.Card(), gpu.Vendor)
+	if gpu.PCIAddress != "" {
+		fmt.Fprintf(&b, "  PCI address: %s\n", gpu.PCIAddress)
+	}
 
 	if gpu.Temperature > 0 {
 		tempStr := fmt.Sprintf("%d°C", gpu.Temperature)
@@ -308,6 +498,49 @@ func FormatGPUStatus(gpu GPUStatus) string {
 			format.Bytes(gpu.VRAMUsed),
 			format.Bytes(gpu.VRAMTotal),
 			pct)
+	} else if gpu.VRAMUsed > 0 {
+		fmt.Fprintf(&b, "  VRAM: %s used\n", format.Bytes(gpu.VRAMUsed))
+	}
+	if gpu.SharedMemUsed > 0 {
+		fmt.Fprintf(&b, "  Shared memory: %s used\n", format.Bytes(gpu.SharedMemUsed))
+	}
+	if gpu.FreqMHz > 0 {
+		clock := fmt.Sprintf("%d MHz", gpu.FreqMHz)
+		if gpu.MaxFreqMHz > 0 {
+			clock += fmt.Sprintf(" (max: %d MHz)", gpu.MaxFreqMHz)
+		}
+		fmt.Fprintf(&b, "  Clock: %s\n", clock)
+	}
+	if len(gpu.ThrottleReasons) > 0 {
+		fmt.Fprintf(&b, "  Throttled: %s\n", strings.Join(gpu.ThrottleReasons, ", "))
+	}
+
+	if gpu.PowerDraw > 0 {
+		power := fmt.Sprintf("%.0f W", gpu.PowerDraw)
+		if gpu.PowerLimit > 0 {
+			power += fmt.Sprintf(" (limit: %.0f W)", gpu.PowerLimit)
+		}
+		fmt.Fprintf(&b, "  Power: %s\n", power)
+	}
+	if gpu.ECCCorrected > 0 || gpu.ECCUncorrected > 0 {
+		fmt.Fprintf(&b, "  ECC errors: %d corrected, %d uncorrectable\n", gpu.ECCCorrected, gpu.ECCUncorrected)
+	}
+	if gpu.RetiredPages > 0 || gpu.RetirementPending {
+		retired := fmt.Sprintf("%d", gpu.RetiredPages)
+		if gpu.RetirementPending {
+			retired += " (retirement pending, reset the GPU to apply)"
+		}
+		fmt.Fprintf(&b, "  Retired pages: %s\n", retired)
+	}
+	if gpu.PCIeReplays > 0 {
+		fmt.Fprintf(&b, "  PCIe replays: %d\n", gpu.PCIeReplays)
+	}
+	for i, p := range gpu.Processes {
+		if i == 5 {
+			fmt.Fprintf(&b, "  ... %d more processes\n", len(gpu.Processes)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  Process: %s (PID %d) %s VRAM\n", p.Name, p.PID, format.Bytes(p.VRAMUsed))
 	}
 
 	return b.String()
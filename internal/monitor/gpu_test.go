@@ -157,6 +157,7 @@ func TestReadSysfsInt(t *testing.T) {
 func TestFormatGPUStatus(t *testing.T) {
 	gpu := GPUStatus{
 		CardPath:    "/sys/class/drm/card0",
+		PCIAddress:  "0000:01:00.0",
 		Vendor:      GPUVendorAMD,
 		Temperature: 75,
 		TempCrit:    100,
@@ -170,7 +171,7 @@ func TestFormatGPUStatus(t *testing.T) {
 	}
 
 	// Check key content.
-	checks := []string{"card0", "amd", "75°C", "100°C", "VRAM", "50%"}
+	checks := []string{"card0", "0000:01:00.0", "amd", "75°C", "100°C", "VRAM", "50%"}
 	for _, check := range checks {
 		if !strings.Contains(out, check) {
 			t.Errorf("output missing %q\nfull output:\n%s", check, out)
@@ -179,3 +180,100 @@ func TestFormatGPUStatus(t *testing.T) {
 }
 
 
+
+func TestGPUPCIAddress(t *testing.T) {
+	tmpDir := t.TempDir()
+	dev := filepath.Join(tmpDir, "devices", "pci0000:00", "0000:00:01.0", "0000:03:00.0")
+	os.MkdirAll(dev, 0o755)
+	cardPath := filepath.Join(tmpDir, "card1")
+	os.MkdirAll(cardPath, 0o755)
+	os.Symlink(dev, filepath.Join(cardPath, "device"))
+
+	if addr := gpuPCIAddress(cardPath); addr != "0000:03:00.0" {
+		t.Errorf("gpuPCIAddress = %q, want 0000:03:00.0", addr)
+	}
+	if addr := gpuPCIAddress(filepath.Join(tmpDir, "card9")); addr != "" {
+		t.Errorf("gpuPCIAddress of a missing card = %q, want empty", addr)
+	}
+}
+
+func TestGPUThresholdsFor(t *testing.T) {
+	thresholds := GPUThresholds{
+		TempWarn:    85,
+		VRAMWarnPct: 90,
+		Cards: []GPUCardThresholds{
+			{Card: "03:00.0", TempWarn: 95},
+			{Card: "1", VRAMWarnPct: 99},
+			{Card: "card2", TempWarn: 70, VRAMWarnPct: 80},
+		},
+	}
+	tests := []struct {
+		card, pci  string
+		temp, vram int
+	}{
+		{"card0", "0000:01:00.0", 85, 90},
+		{"card1", "0000:03:00.0", 95, 90}, // the PCI address matches first
+		{"card1", "", 85, 99},
+		{"card2", "0000:04:00.0", 70, 80},
+		{"card3", "", 85, 90},
+	}
+	for _, tt := range tests {
+		s := GPUStatus{CardPath: "/sys/class/drm/" + tt.card, PCIAddress: tt.pci}
+		temp, vram := thresholds.For(s)
+		if temp != tt.temp || vram != tt.vram {
+			t.Errorf("For(%s %s) = %d, %d, want %d, %d", tt.card, tt.pci, temp, vram, tt.temp, tt.vram)
+		}
+	}
+}
+
+func TestDRMVRAMConsumers(t *testing.T) {
+	procRoot := t.TempDir()
+	devDir := t.TempDir()
+	proc := func(pid, comm string, fds map[string]string) {
+		dir := filepath.Join(procRoot, pid)
+		os.MkdirAll(filepath.Join(dir, "fd"), 0o755)
+		os.MkdirAll(filepath.Join(dir, "fdinfo"), 0o755)
+		os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644)
+		for fd, info := range fds {
+			target := "/dev/dri/renderD128"
+			if info == "" {
+				target = filepath.Join(devDir, "not-a-gpu")
+			}
+			os.Symlink(target, filepath.Join(dir, "fd", fd))
+			os.WriteFile(filepath.Join(dir, "fdinfo", fd), []byte(info), 0o644)
+		}
+	}
+	fdinfo := func(pdev, client, vram string) string {
+		return "pos:\t0\nflags:\t02100002\ndrm-driver:\tamdgpu\ndrm-client-id:\t" + client +
+			"\ndrm-pdev:\t" + pdev + "\ndrm-memory-vram:\t" + vram + "\ndrm-memory-gtt: \t2556 KiB\n"
+	}
+
+	proc("100", "Xorg", map[string]string{
+		"10": fdinfo("0000:03:00.0", "1", "204800 KiB"),
+		"11": fdinfo("0000:03:00.0", "1", "204800 KiB"), // the same client twice
+		"12": "",
+	})
+	proc("200", "blender", map[string]string{"5": fdinfo("0000:03:00.0", "7", "3 GiB")})
+	proc("300", "game", map[string]string{"5": fdinfo("0000:04:00.0", "9", "1 GiB")}) // another card
+	proc("400", "shell", nil)
+	// A shared client is counted for the first process only.
+	proc("500", "compositor", map[string]string{"3": fdinfo("0000:03:00.0", "7", "3 GiB")})
+
+	got := drmVRAMConsumers(procRoot, "0000:03:00.0")
+	if len(got) != 2 {
+		t.Fatalf("got %d consumers, want 2: %+v", len(got), got)
+	}
+	if got[0].PID != 200 || got[0].Name != "blender" || got[0].VRAMUsed != 3<<30 {
+		t.Errorf("top consumer = %+v", got[0])
+	}
+	if got[1].PID != 100 || got[1].VRAMUsed != 200<<20 {
+		t.Errorf("second consumer = %+v, want Xorg with 200 MiB counted once", got[1])
+	}
+}
+
+func TestGPUVRAMConsumersNvidia(t *testing.T) {
+	gpu := GPUStatus{Processes: []GPUProcess{{PID: 1, VRAMUsed: 3}, {PID: 2, VRAMUsed: 2}, {PID: 3, VRAMUsed: 1}}}
+	if got := GPUVRAMConsumers(gpu, 2); len(got) != 2 || got[0].PID != 1 {
+		t.Errorf("GPUVRAMConsumers = %+v, want nvidia-smi's first two", got)
+	}
+}
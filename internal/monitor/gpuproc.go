@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/format"
+)
+
+// GPUVRAMConsumers returns the top n processes by VRAM use on a card. NVIDIA
+// cards list them in nvidia-smi's report; for other cards they are read
+// from the DRM usage stats in /proc/*/fdinfo, which amdgpu and newer
+// drivers report for every open device file.
+func GPUVRAMConsumers(gpu GPUStatus, n int) []GPUProcess {
+	procs := gpu.Processes
+	if len(procs) == 0 && gpu.PCIAddress != "" {
+		procs = drmVRAMConsumers("/proc", gpu.PCIAddress)
+	}
+	if n > 0 && len(procs) > n {
+		procs = procs[:n]
+	}
+	return procs
+}
+
+// drmVRAMConsumers reads the VRAM use of the DRM clients of the device at
+// pciAddress, by process, most first.
+func drmVRAMConsumers(procRoot, pciAddress string) []GPUProcess {
+	var procs []GPUProcess
+	byPID := make(map[int]int) // PID -> index in procs
+	drmClients(procRoot, pciAddress, func(pid int, dir string, info drmFdinfo) {
+		if info.vram == 0 {
+			return
+		}
+		i, ok := byPID[pid]
+		if !ok {
+			i = len(procs)
+			byPID[pid] = i
+			procs = append(procs, GPUProcess{PID: pid, Name: readCommName(filepath.Join(dir, "comm"))})
+		}
+		procs[i].VRAMUsed += info.vram
+	})
+	slices.SortStableFunc(procs, func(a, b GPUProcess) int { return cmp.Compare(b.VRAMUsed, a.VRAMUsed) })
+	return procs
+}
+
+// drmMemoryUse returns the VRAM and system memory in use by all DRM
+// clients of the device at pciAddress.
+func drmMemoryUse(procRoot, pciAddress string) (vram, system int64) {
+	drmClients(procRoot, pciAddress, func(_ int, _ string, info drmFdinfo) {
+		vram += info.vram
+		system += info.system
+	})
+	return vram, system
+}
+
+// drmClients calls fn with the DRM usage stats of each client of the
+// device at pciAddress, and the PID and /proc directory of the process
+// that has it open. A client shared by several processes is passed once,
+// for the first.
+func drmClients(procRoot, pciAddress string, fn func(pid int, dir string, info drmFdinfo)) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool) // client IDs
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // not a PID directory
+		}
+		dir := filepath.Join(procRoot, entry.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue // process may have exited, or is not ours to read
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "/dev/dri/") {
+				continue
+			}
+			info := readDRMFdinfo(filepath.Join(dir, "fdinfo", fd.Name()))
+			if info.pdev != pciAddress || info.clientID == "" || seen[info.clientID] {
+				continue
+			}
+			seen[info.clientID] = true
+			fn(pid, dir, info)
+		}
+	}
+}
+
+// drmFdinfo is the part of a DRM device file's fdinfo that is used.
+type drmFdinfo struct {
+	pdev     string
+	clientID string
+	vram     int64 // bytes in device memory: amdgpu and xe "vram", i915 "local" regions
+	system   int64 // bytes in system memory regions, which integrated GPUs use
+}
+
+// readDRMFdinfo parses the DRM usage stats of an fdinfo file. Each memory
+// region's use is its drm-resident-<region> size, or amdgpu's older
+// drm-memory-<region>, or failing those drm-total-<region>.
+func readDRMFdinfo(path string) drmFdinfo {
+	f, err := os.Open(path)
+	if err != nil {
+		return drmFdinfo{}
+	}
+	defer f.Close()
+
+	var info drmFdinfo
+	resident := make(map[string]int64)
+	memory := make(map[string]int64)
+	total := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "drm-pdev":
+			info.pdev = value
+		case key == "drm-client-id":
+			info.clientID = value
+		case strings.HasPrefix(key, "drm-resident-"):
+			resident[strings.TrimPrefix(key, "drm-resident-")] = parseDRMSize(value)
+		case strings.HasPrefix(key, "drm-memory-"):
+			memory[strings.TrimPrefix(key, "drm-memory-")] = parseDRMSize(value)
+		case strings.HasPrefix(key, "drm-total-"):
+			total[strings.TrimPrefix(key, "drm-total-")] = parseDRMSize(value)
+		}
+	}
+
+	regions := make(map[string]bool)
+	for _, m := range []map[string]int64{resident, memory, total} {
+		for region := range m {
+			regions[region] = true
+		}
+	}
+	for region := range regions {
+		size := cmp.Or(resident[region], memory[region], total[region])
+		switch {
+		case strings.HasPrefix(region, "vram"), strings.HasPrefix(region, "local"):
+			info.vram += size
+		case strings.HasPrefix(region, "system"):
+			info.system += size
+		}
+	}
+	return info
+}
+
+// parseDRMSize parses a DRM usage stats size: bytes, or a number with a
+// KiB, MiB, or GiB unit.
+func parseDRMSize(s string) int64 {
+	num, unit, _ := strings.Cut(s, " ")
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.TrimSpace(unit) {
+	case "KiB":
+		v <<= 10
+	case "MiB":
+		v <<= 20
+	case "GiB":
+		v <<= 30
+	}
+	return v
+}
+
+// FormatGPUConsumers formats a list of GPUProcess as human-readable lines.
+func FormatGPUConsumers(procs []GPUProcess) string {
+	var b strings.Builder
+	for i, p := range procs {
+		fmt.Fprintf(&b, "  %d. %-20s %s (PID %d)\n", i+1, p.Name, format.Bytes(p.VRAMUsed), p.PID)
+	}
+	return b.String()
+}
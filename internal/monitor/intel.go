@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// readIntelVRAM reads an Intel card's memory use. Neither i915 nor xe
+// reports used memory in sysfs, so it is summed from the DRM usage stats
+// of the card's clients under procRoot. xe reports its VRAM size per tile;
+// i915 does not report it at all.
+func readIntelVRAM(gpu *GPUStatus, procRoot string) {
+	if gpu.PCIAddress == "" {
+		return
+	}
+	tiles, _ := filepath.Glob(filepath.Join(gpu.CardPath, "device", "tile[0-9]*", "physical_vram_size_bytes"))
+	for _, tile := range tiles {
+		gpu.VRAMTotal += readSysfsInt64(tile)
+	}
+	gpu.VRAMUsed, gpu.SharedMemUsed = drmMemoryUse(procRoot, gpu.PCIAddress)
+}
+
+// ReadGPUFreq reads an Intel card's actual and maximum clock, and why it
+// is throttled, from i915 or xe sysfs. Other vendors are left as they are.
+func ReadGPUFreq(gpu *GPUStatus) {
+	if gpu.Vendor != GPUVendorIntel {
+		return
+	}
+
+	// i915: the card directory has the frequencies, gt/gt0 the throttle
+	// reasons, one file each.
+	if cur := readSysfsInt(filepath.Join(gpu.CardPath, "gt_act_freq_mhz")); cur > 0 {
+		gpu.FreqMHz = cur
+		gpu.MaxFreqMHz = readSysfsInt(filepath.Join(gpu.CardPath, "gt_RP0_freq_mhz"))
+		gpu.ThrottleReasons = throttleReasons(filepath.Join(gpu.CardPath, "gt", "gt0"), "throttle_reason_")
+		return
+	}
+
+	// xe: each GT of each tile has a freq0 directory.
+	freq := filepath.Join(gpu.CardPath, "device", "tile0", "gt0", "freq0")
+	if cur := readSysfsInt(filepath.Join(freq, "act_freq")); cur > 0 {
+		gpu.FreqMHz = cur
+		gpu.MaxFreqMHz = readSysfsInt(filepath.Join(freq, "rp0_freq"))
+		gpu.ThrottleReasons = throttleReasons(filepath.Join(freq, "throttle"), "reason_")
+	}
+}
+
+// throttleReasons returns the names of the set throttle reason files in
+// dir, without prefix, e.g. "thermal" for throttle_reason_thermal. i915's
+// throttle_reason_status, set if any other is, is left out.
+func throttleReasons(dir, prefix string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	var reasons []string
+	for _, f := range files {
+		reason := strings.TrimPrefix(filepath.Base(f), prefix)
+		if reason != "status" && readSysfsInt(f) == 1 {
+			reasons = append(reasons, reason)
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// intelGPUTopSample is the part of one intel_gpu_top -J sample that is used.
+type intelGPUTopSample struct {
+	Frequency struct {
+		Actual float64 `json:"actual"`
+	} `json:"frequency"`
+	Power struct {
+		GPU float64 `json:"GPU"`
+	} `json:"power"`
+}
+
+// readIntelGPUTop fills in an Intel card's clock and power draw from the
+// first sample of intel_gpu_top, for when sysfs does not have them.
+// intel_gpu_top needs root or CAP_PERFMON.
+func readIntelGPUTop(ctx context.Context, gpu *GPUStatus) {
+	if !sysdep.Have("intel_gpu_top") {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "intel_gpu_top", "-J", "-s", "500", "-d", "drm:/dev/dri/"+gpu.Card())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		slog.Debug("intel_gpu_top failed", "error", err)
+		return
+	}
+	defer func() {
+		cancel()
+		cmd.Wait()
+	}()
+
+	sample, err := parseIntelGPUTop(bufio.NewReader(stdout))
+	if err != nil {
+		slog.Debug("reading intel_gpu_top output failed", "error", err)
+		return
+	}
+	if gpu.FreqMHz == 0 {
+		gpu.FreqMHz = int(sample.Frequency.Actual)
+	}
+	if gpu.PowerDraw == 0 {
+		gpu.PowerDraw = sample.Power.GPU
+	}
+}
+
+// parseIntelGPUTop reads the first sample of intel_gpu_top -J, which
+// prints a JSON array of samples, one each period, without ever closing it.
+// Older versions leave out the opening bracket too.
+func parseIntelGPUTop(r *bufio.Reader) (intelGPUTopSample, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return intelGPUTopSample{}, err
+		}
+		if b == '{' {
+			r.UnreadByte()
+			break
+		}
+	}
+	var sample intelGPUTopSample
+	err := json.NewDecoder(r).Decode(&sample)
+	return sample, err
+}
@@ -0,0 +1,101 @@
+package monitor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadGPUFreqI915(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "card0")
+	gt := filepath.Join(cardPath, "gt", "gt0")
+	os.MkdirAll(gt, 0o755)
+	os.WriteFile(filepath.Join(cardPath, "gt_act_freq_mhz"), []byte("650\n"), 0o644)
+	os.WriteFile(filepath.Join(cardPath, "gt_RP0_freq_mhz"), []byte("1300\n"), 0o644)
+	for name, v := range map[string]string{"status": "1", "thermal": "1", "pl1": "1", "pl2": "0", "prochot": "0"} {
+		os.WriteFile(filepath.Join(gt, "throttle_reason_"+name), []byte(v+"\n"), 0o644)
+	}
+
+	gpu := GPUStatus{CardPath: cardPath, Vendor: GPUVendorIntel}
+	ReadGPUFreq(&gpu)
+	if gpu.FreqMHz != 650 || gpu.MaxFreqMHz != 1300 {
+		t.Errorf("clock = %d / %d MHz, want 650 / 1300", gpu.FreqMHz, gpu.MaxFreqMHz)
+	}
+	if !slices.Equal(gpu.ThrottleReasons, []string{"pl1", "thermal"}) {
+		t.Errorf("throttle reasons = %v, want [pl1 thermal]", gpu.ThrottleReasons)
+	}
+
+	// Other vendors are not read.
+	gpu = GPUStatus{CardPath: cardPath, Vendor: GPUVendorAMD}
+	ReadGPUFreq(&gpu)
+	if gpu.FreqMHz != 0 {
+		t.Errorf("AMD card clock = %d, want 0", gpu.FreqMHz)
+	}
+}
+
+func TestReadGPUFreqXe(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "card1")
+	freq := filepath.Join(cardPath, "device", "tile0", "gt0", "freq0")
+	os.MkdirAll(filepath.Join(freq, "throttle"), 0o755)
+	os.WriteFile(filepath.Join(freq, "act_freq"), []byte("2050\n"), 0o644)
+	os.WriteFile(filepath.Join(freq, "rp0_freq"), []byte("2400\n"), 0o644)
+	os.WriteFile(filepath.Join(freq, "throttle", "status"), []byte("1\n"), 0o644)
+	os.WriteFile(filepath.Join(freq, "throttle", "reason_thermal"), []byte("1\n"), 0o644)
+	os.WriteFile(filepath.Join(freq, "throttle", "reason_pl1"), []byte("0\n"), 0o644)
+
+	gpu := GPUStatus{CardPath: cardPath, Vendor: GPUVendorIntel}
+	ReadGPUFreq(&gpu)
+	if gpu.FreqMHz != 2050 || gpu.MaxFreqMHz != 2400 || !slices.Equal(gpu.ThrottleReasons, []string{"thermal"}) {
+		t.Errorf("xe card = %d / %d MHz, throttled %v", gpu.FreqMHz, gpu.MaxFreqMHz, gpu.ThrottleReasons)
+	}
+
+	out := FormatGPUStatus(gpu)
+	for _, want := range []string{"Clock: 2050 MHz (max: 2400 MHz)", "Throttled: thermal"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("FormatGPUStatus missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestReadIntelVRAM(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "card1")
+	os.MkdirAll(filepath.Join(cardPath, "device", "tile0"), 0o755)
+	os.WriteFile(filepath.Join(cardPath, "device", "tile0", "physical_vram_size_bytes"), []byte("17179869184\n"), 0o644)
+
+	procRoot := t.TempDir()
+	dir := filepath.Join(procRoot, "42")
+	os.MkdirAll(filepath.Join(dir, "fd"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "fdinfo"), 0o755)
+	os.Symlink("/dev/dri/renderD129", filepath.Join(dir, "fd", "7"))
+	os.WriteFile(filepath.Join(dir, "fdinfo", "7"), []byte("drm-driver:\txe\ndrm-pdev:\t0000:03:00.0\ndrm-client-id:\t3\n"+
+		"drm-total-system:\t64 MiB\ndrm-resident-system:\t32 MiB\ndrm-total-vram0:\t2 GiB\ndrm-resident-vram0:\t1 GiB\n"), 0o644)
+
+	gpu := GPUStatus{CardPath: cardPath, PCIAddress: "0000:03:00.0", Vendor: GPUVendorIntel}
+	readIntelVRAM(&gpu, procRoot)
+	if gpu.VRAMTotal != 16<<30 || gpu.VRAMUsed != 1<<30 || gpu.SharedMemUsed != 32<<20 {
+		t.Errorf("VRAM = %d / %d, shared %d", gpu.VRAMUsed, gpu.VRAMTotal, gpu.SharedMemUsed)
+	}
+}
+
+func TestParseIntelGPUTop(t *testing.T) {
+	out := `[
+{
+	"period": {"duration": 500.1, "unit": "ms"},
+	"frequency": {"requested": 1100.0, "actual": 1050.5, "unit": "MHz"},
+	"power": {"GPU": 4.25, "Package": 12.0, "unit": "W"},
+	"engines": {"Render/3D": {"busy": 12.0, "unit": "%"}}
+},
+{
+	"frequency": {"actual": 300.0}
+`
+	sample, err := parseIntelGPUTop(bufio.NewReader(strings.NewReader(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Frequency.Actual != 1050.5 || sample.Power.GPU != 4.25 {
+		t.Errorf("sample = %+v", sample)
+	}
+}
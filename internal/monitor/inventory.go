@@ -0,0 +1,410 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+)
+
+// sysRoot is the root of the sysfs filesystem, a variable so tests can
+// point it at a fixture.
+var sysRoot = "/sys"
+
+// memDropPct is how far installed memory must shrink before it is
+// reported. MemTotal moves by a few megabytes between kernels and with
+// crashkernel reservations; a failed DIMM removes gigabytes.
+const memDropPct = 2.0
+
+// Inventory is a snapshot of the hardware the host should have.
+type Inventory struct {
+	Disks    []InventoryDevice `json:"disks"`
+	GPUs     []InventoryDevice `json:"gpus"`
+	NICs     []InventoryDevice `json:"nics"`
+	MemTotal int64             `json:"mem_total"` // bytes, from /proc/meminfo
+}
+
+// InventoryDevice is one disk, GPU, or NIC. ID identifies the device across
+// renames and reboots: a disk's serial number (or WWID, or its name when it
+// reports neither), a GPU's PCI address, a NIC's MAC address.
+type InventoryDevice struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`            // e.g. sda, card0, eth0
+	Model string `json:"model,omitempty"` // disk model, GPU vendor, NIC driver
+	Size  int64  `json:"size,omitempty"`  // disk capacity in bytes
+}
+
+// Inventory change kinds.
+const (
+	InventoryDisk   = "disk"
+	InventoryGPU    = "gpu"
+	InventoryNIC    = "nic"
+	InventoryMemory = "memory"
+)
+
+// InventoryChange is a difference between two inventories.
+type InventoryChange struct {
+	Kind    string // InventoryDisk, InventoryGPU, InventoryNIC, or InventoryMemory
+	Subject string // the device name, or "memory"
+	Lost    bool   // a device disappeared or memory shrank; otherwise something was added
+	Summary string
+	Detail  string
+}
+
+// InventoryEvent is emitted when hardware disappears between two snapshots.
+type InventoryEvent struct {
+	Timestamp time.Time
+	Change    InventoryChange
+	Previous  time.Time // when the snapshot the device was last seen in was taken
+}
+
+// ReadInventory takes a snapshot of the host's disks, GPUs, NICs, and
+// memory. Devices whose names match one of the ignore globs are left out.
+// Virtual devices (loop, dm, md, bridges, veth) and removable disks are
+// never included.
+func ReadInventory(ignore []string) Inventory {
+	inv := Inventory{
+		Disks: readInventoryDisks(),
+		GPUs:  readInventoryGPUs(),
+		NICs:  readInventoryNICs(),
+	}
+	if mem, err := os.ReadFile(filepath.Join(procRoot, "meminfo")); err == nil {
+		inv.MemTotal = parseMemTotal(string(mem))
+	}
+	if len(ignore) > 0 {
+		inv.Disks = dropIgnored(inv.Disks, ignore)
+		inv.GPUs = dropIgnored(inv.GPUs, ignore)
+		inv.NICs = dropIgnored(inv.NICs, ignore)
+	}
+	return inv
+}
+
+func readInventoryDisks() []InventoryDevice {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "block"))
+	if err != nil {
+		return nil
+	}
+	var disks []InventoryDevice
+	for _, e := range entries {
+		dir := filepath.Join(sysRoot, "block", e.Name())
+		// Only disks backed by hardware have a device link.
+		if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+			continue
+		}
+		if readSysString(filepath.Join(dir, "removable")) == "1" {
+			continue
+		}
+		d := InventoryDevice{
+			Name:  e.Name(),
+			Model: readSysString(filepath.Join(dir, "device", "model")),
+		}
+		if sectors, err := strconv.ParseInt(readSysString(filepath.Join(dir, "size")), 10, 64); err == nil {
+			d.Size = sectors * 512
+		}
+		for _, f := range []string{"serial", "wwid"} {
+			if d.ID = readSysString(filepath.Join(dir, "device", f)); d.ID != "" {
+				break
+			}
+		}
+		if d.ID == "" {
+			d.ID = d.Name
+		}
+		disks = append(disks, d)
+	}
+	return disks
+}
+
+func readInventoryGPUs() []InventoryDevice {
+	entries, err := filepath.Glob(filepath.Join(sysRoot, "class", "drm", "card[0-9]*"))
+	if err != nil {
+		return nil
+	}
+	var gpus []InventoryDevice
+	for _, cardPath := range entries {
+		name := filepath.Base(cardPath)
+		if strings.Contains(name, "-") {
+			continue // connectors like card0-DP-1
+		}
+		vendor := identifyGPUVendor(cardPath)
+		if vendor == "" {
+			continue
+		}
+		id := name
+		if target, err := os.Readlink(filepath.Join(cardPath, "device")); err == nil {
+			id = filepath.Base(target) // PCI address, e.g. 0000:03:00.0
+		}
+		gpus = append(gpus, InventoryDevice{ID: id, Name: name, Model: string(vendor)})
+	}
+	return gpus
+}
+
+func readInventoryNICs() []InventoryDevice {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "class", "net"))
+	if err != nil {
+		return nil
+	}
+	var nics []InventoryDevice
+	for _, e := range entries {
+		dir := filepath.Join(sysRoot, "class", "net", e.Name())
+		// Virtual interfaces (lo, bridges, veth, tun) have no device link.
+		if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+			continue
+		}
+		n := InventoryDevice{
+			ID:   readSysString(filepath.Join(dir, "address")),
+			Name: e.Name(),
+		}
+		if target, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+			n.Model = filepath.Base(target)
+		}
+		if n.ID == "" {
+			n.ID = n.Name
+		}
+		nics = append(nics, n)
+	}
+	return nics
+}
+
+// readSysString returns the trimmed contents of a sysfs attribute, or "".
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// parseMemTotal returns MemTotal from /proc/meminfo in bytes, or 0.
+func parseMemTotal(meminfo string) int64 {
+	for _, line := range strings.Split(meminfo, "\n") {
+		rest, ok := strings.CutPrefix(line, "MemTotal:")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			return 0
+		}
+		kb, err := strconv.ParseInt(f[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+func dropIgnored(devs []InventoryDevice, ignore []string) []InventoryDevice {
+	out := devs[:0]
+	for _, d := range devs {
+		if !matchAny(ignore, d.Name) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns how cur differs from inv: devices that disappeared,
+// devices that were added, and memory that shrank by more than memDropPct
+// or grew. Devices are matched by ID, so a disk that moved from sdb to sdc
+// is not a change.
+func (inv Inventory) Changes(cur Inventory) []InventoryChange {
+	var changes []InventoryChange
+	changes = append(changes, deviceChanges(InventoryDisk, inv.Disks, cur.Disks)...)
+	changes = append(changes, deviceChanges(InventoryGPU, inv.GPUs, cur.GPUs)...)
+	changes = append(changes, deviceChanges(InventoryNIC, inv.NICs, cur.NICs)...)
+
+	if inv.MemTotal > 0 && cur.MemTotal > 0 && cur.MemTotal != inv.MemTotal {
+		detail := fmt.Sprintf("Installed memory: %s (was %s)", format.Bytes(cur.MemTotal), format.Bytes(inv.MemTotal))
+		switch {
+		case float64(inv.MemTotal-cur.MemTotal) > float64(inv.MemTotal)*memDropPct/100:
+			changes = append(changes, InventoryChange{
+				Kind:    InventoryMemory,
+				Subject: "memory",
+				Lost:    true,
+				Summary: fmt.Sprintf("Memory size dropped: %s to %s", format.Bytes(inv.MemTotal), format.Bytes(cur.MemTotal)),
+				Detail:  detail,
+			})
+		case cur.MemTotal > inv.MemTotal:
+			changes = append(changes, InventoryChange{
+				Kind:    InventoryMemory,
+				Subject: "memory",
+				Summary: fmt.Sprintf("Memory size grew: %s to %s", format.Bytes(inv.MemTotal), format.Bytes(cur.MemTotal)),
+				Detail:  detail,
+			})
+		}
+	}
+	return changes
+}
+
+func deviceChanges(kind string, old, cur []InventoryDevice) []InventoryChange {
+	byID := func(devs []InventoryDevice) map[string]InventoryDevice {
+		m := make(map[string]InventoryDevice, len(devs))
+		for _, d := range devs {
+			m[d.ID] = d
+		}
+		return m
+	}
+	oldIDs, curIDs := byID(old), byID(cur)
+
+	var changes []InventoryChange
+	for _, d := range old {
+		if _, ok := curIDs[d.ID]; !ok {
+			changes = append(changes, InventoryChange{
+				Kind:    kind,
+				Subject: d.Name,
+				Lost:    true,
+				Summary: fmt.Sprintf("%s missing: %s", inventoryNoun(kind), d.Name),
+				Detail:  formatInventoryDevice(kind, d),
+			})
+		}
+	}
+	for _, d := range cur {
+		if _, ok := oldIDs[d.ID]; !ok {
+			changes = append(changes, InventoryChange{
+				Kind:    kind,
+				Subject: d.Name,
+				Summary: fmt.Sprintf("%s added: %s", inventoryNoun(kind), d.Name),
+				Detail:  formatInventoryDevice(kind, d),
+			})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Lost && !changes[j].Lost })
+	return changes
+}
+
+func inventoryNoun(kind string) string {
+	switch kind {
+	case InventoryDisk:
+		return "Disk"
+	case InventoryGPU:
+		return "GPU"
+	case InventoryNIC:
+		return "NIC"
+	}
+	return kind
+}
+
+// formatInventoryDevice renders a device for an event's detail.
+func formatInventoryDevice(kind string, d InventoryDevice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Device: %s\n", d.Name)
+	switch kind {
+	case InventoryDisk:
+		if d.Model != "" {
+			fmt.Fprintf(&b, "Model: %s\n", d.Model)
+		}
+		if d.ID != d.Name {
+			fmt.Fprintf(&b, "Serial: %s\n", d.ID)
+		}
+		if d.Size > 0 {
+			fmt.Fprintf(&b, "Size: %s\n", format.Bytes(d.Size))
+		}
+	case InventoryGPU:
+		fmt.Fprintf(&b, "Vendor: %s\nPCI address: %s\n", d.Model, d.ID)
+	case InventoryNIC:
+		fmt.Fprintf(&b, "MAC address: %s\n", d.ID)
+		if d.Model != "" {
+			fmt.Fprintf(&b, "Driver: %s\n", d.Model)
+		}
+	}
+	return b.String()
+}
+
+// InventoryMonitor snapshots the hardware inventory at startup and then
+// every poll interval, and emits an event for each device that has
+// disappeared since the previous snapshot. Added devices are only logged.
+type InventoryMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	ignore       []string
+	last         *Inventory
+	lastAt       time.Time
+	save         func(Inventory, time.Time)
+}
+
+// NewInventoryMonitor creates an inventory monitor. last is the snapshot
+// saved by a previous run, taken at lastAt, or nil on first start; the
+// first snapshot is compared against it, which catches hardware lost across
+// a reboot. save, if not nil, is called from the polling goroutine with
+// every new snapshot so the next run has a baseline.
+func NewInventoryMonitor(pollInterval time.Duration, ignore []string, last *Inventory, lastAt time.Time, save func(Inventory, time.Time)) *InventoryMonitor {
+	return &InventoryMonitor{
+		pollInterval: pollInterval,
+		ignore:       ignore,
+		last:         last,
+		lastAt:       lastAt,
+		save:         save,
+	}
+}
+
+// Events starts the snapshot loop and returns a channel of inventory events.
+func (m *InventoryMonitor) Events(ctx context.Context) <-chan InventoryEvent {
+	ch := make(chan InventoryEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *InventoryMonitor) poll(ctx context.Context, ch chan<- InventoryEvent) {
+	defer close(ch)
+
+	// Initial snapshot.
+	m.check(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx, ch)
+		}
+	}
+}
+
+func (m *InventoryMonitor) check(ctx context.Context, ch chan<- InventoryEvent) {
+	defer m.markPoll()
+
+	now := time.Now()
+	cur := ReadInventory(m.ignore)
+	if m.last == nil {
+		slog.Info("hardware inventory recorded",
+			"disks", len(cur.Disks), "gpus", len(cur.GPUs), "nics", len(cur.NICs), "memory", format.Bytes(cur.MemTotal))
+	} else {
+		for _, c := range m.last.Changes(cur) {
+			if !c.Lost {
+				slog.Info("hardware inventory changed", "kind", c.Kind, "change", c.Summary)
+				continue
+			}
+			select {
+			case ch <- InventoryEvent{Timestamp: now, Change: c, Previous: m.lastAt}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	m.last, m.lastAt = &cur, now
+	if m.save != nil {
+		m.save(cur, now)
+	}
+}
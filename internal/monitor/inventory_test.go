@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSysfs builds a sysfs and procfs fixture with two disks (one
+// removable), a loop device, an AMD GPU, and a physical and a virtual NIC.
+func fakeSysfs(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path, data string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, path string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("devices/nvme0/serial", "S4EWNX0R123456")
+	write("devices/nvme0/model", "Samsung SSD 980")
+	write("devices/usb1/model", "Flash Drive")
+	link("../../../drivers/amdgpu", "devices/pci/0000:03:00.0/driver")
+	link("../../../drivers/e1000e", "devices/pci/0000:00:1f.6/driver")
+
+	link("../../devices/nvme0", "sys/block/nvme0n1/device")
+	write("sys/block/nvme0n1/size", "1953525168")
+	write("sys/block/nvme0n1/removable", "0")
+	link("../../devices/usb1", "sys/block/sdb/device")
+	write("sys/block/sdb/removable", "1")
+	write("sys/block/loop0/size", "0")
+
+	link("../../../devices/pci/0000:03:00.0", "sys/class/drm/card0/device")
+	write("sys/class/drm/card0-DP-1/status", "connected")
+
+	link("../../../devices/pci/0000:00:1f.6", "sys/class/net/eno1/device")
+	write("sys/class/net/eno1/address", "3c:ec:ef:00:11:22")
+	write("sys/class/net/lo/address", "00:00:00:00:00:00")
+
+	write("proc/meminfo", "MemTotal:       65747292 kB\nMemFree:         1000000 kB")
+
+	// Device links point into sys/devices, as in the real sysfs.
+	link("../devices", "sys/devices")
+
+	sysRoot = filepath.Join(root, "sys")
+	procRoot = filepath.Join(root, "proc")
+	t.Cleanup(func() {
+		sysRoot = "/sys"
+		procRoot = "/proc"
+	})
+}
+
+func TestReadInventory(t *testing.T) {
+	fakeSysfs(t)
+
+	inv := ReadInventory(nil)
+	if len(inv.Disks) != 1 {
+		t.Fatalf("disks = %+v, want only nvme0n1", inv.Disks)
+	}
+	d := inv.Disks[0]
+	if d.ID != "S4EWNX0R123456" || d.Name != "nvme0n1" || d.Model != "Samsung SSD 980" || d.Size != 1953525168*512 {
+		t.Errorf("disk = %+v", d)
+	}
+	if len(inv.GPUs) != 1 || inv.GPUs[0].ID != "0000:03:00.0" || inv.GPUs[0].Model != "amd" {
+		t.Errorf("gpus = %+v", inv.GPUs)
+	}
+	if len(inv.NICs) != 1 || inv.NICs[0].ID != "3c:ec:ef:00:11:22" || inv.NICs[0].Model != "e1000e" {
+		t.Errorf("nics = %+v", inv.NICs)
+	}
+	if inv.MemTotal != 65747292*1024 {
+		t.Errorf("MemTotal = %d", inv.MemTotal)
+	}
+
+	if inv := ReadInventory([]string{"eno*"}); len(inv.NICs) != 0 {
+		t.Errorf("ignored NIC still listed: %+v", inv.NICs)
+	}
+}
+
+func TestInventoryChanges(t *testing.T) {
+	old := Inventory{
+		Disks:    []InventoryDevice{{ID: "SER1", Name: "sda"}, {ID: "SER2", Name: "sdb"}},
+		NICs:     []InventoryDevice{{ID: "aa:bb", Name: "eth0"}},
+		MemTotal: 64 << 30,
+	}
+
+	// A disk renamed by a reboot and a rounding change in memory are not
+	// changes.
+	same := Inventory{
+		Disks:    []InventoryDevice{{ID: "SER2", Name: "sda"}, {ID: "SER1", Name: "sdb"}},
+		NICs:     []InventoryDevice{{ID: "aa:bb", Name: "enp1s0"}},
+		MemTotal: 64<<30 - 64<<20,
+	}
+	if changes := old.Changes(same); len(changes) != 0 {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+
+	cur := Inventory{
+		Disks:    []InventoryDevice{{ID: "SER1", Name: "sda"}, {ID: "SER3", Name: "sdc"}},
+		MemTotal: 48 << 30,
+	}
+	changes := old.Changes(cur)
+	lost := make(map[string]bool)
+	var added []string
+	for _, c := range changes {
+		if c.Lost {
+			lost[c.Kind+" "+c.Subject] = true
+		} else {
+			added = append(added, c.Kind+" "+c.Subject)
+		}
+	}
+	if len(lost) != 3 || !lost["disk sdb"] || !lost["nic eth0"] || !lost["memory memory"] {
+		t.Errorf("lost = %v, want disk sdb, nic eth0, memory", lost)
+	}
+	if len(added) != 1 || added[0] != "disk sdc" {
+		t.Errorf("added = %v, want disk sdc", added)
+	}
+	if changes[0].Summary != "Disk missing: sdb" {
+		t.Errorf("first change = %q, want lost devices first", changes[0].Summary)
+	}
+}
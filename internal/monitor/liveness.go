@@ -0,0 +1,26 @@
+package monitor
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// liveness records when a monitor last finished a poll, so the daemon can
+// tell a wedged monitor from one that simply has nothing to report.
+type liveness struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+func (l *liveness) markPoll() {
+	l.last.Store(time.Now().UnixNano())
+}
+
+// LastPoll returns when the monitor last finished a poll, or the zero time
+// before the first poll completes.
+func (l *liveness) LastPoll() time.Time {
+	ns := l.last.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,271 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NVML reports whether NVIDIA GPUs are read through an NVML binding. No
+// build has one: they are read with nvidia-smi, if it is installed.
+const NVML = false
+
+// GPUProcess is a process using a GPU's memory.
+type GPUProcess struct {
+	PID      int
+	Name     string
+	VRAMUsed int64 // bytes
+}
+
+// nvidiaReading is what nvidia-smi reports for one GPU.
+type nvidiaReading struct {
+	Temperature       int
+	VRAMUsed          int64 // bytes
+	VRAMTotal         int64 // bytes
+	PowerDraw         float64
+	PowerLimit        float64
+	ECCCorrected      int64
+	ECCUncorrected    int64
+	RetiredPages      int64
+	RetirementPending bool
+	PCIeReplays       int64
+	Processes         []GPUProcess
+}
+
+// nvidiaRestartWait is how long nvidia-smi is given before it is restarted
+// after it exits.
+const nvidiaRestartWait = 10 * time.Second
+
+// nvidiaSMI runs nvidia-smi in its loop mode, which prints an XML report of
+// every GPU each interval, and keeps the latest report. One long-running
+// process is much cheaper than starting nvidia-smi, which initializes the
+// driver, on every poll.
+type nvidiaSMI struct {
+	interval  time.Duration
+	ready     chan struct{} // closed at the first report
+	readyOnce sync.Once     // of all runs: nvidia-smi is restarted when it exits
+
+	mu       sync.Mutex
+	readings map[string]nvidiaReading // by PCI address
+	at       time.Time
+}
+
+// startNvidiaSMI starts nvidia-smi, reporting every interval, until ctx is
+// done.
+func startNvidiaSMI(ctx context.Context, interval time.Duration) *nvidiaSMI {
+	n := &nvidiaSMI{interval: interval, ready: make(chan struct{})}
+	go n.run(ctx)
+	return n
+}
+
+func (n *nvidiaSMI) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		reported, err := n.stream(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case !reported:
+			slog.Warn("nvidia-smi failed, NVIDIA telemetry disabled", "error", err)
+			return
+		}
+		slog.Warn("nvidia-smi exited, restarting", "error", err)
+		select {
+		case <-time.After(nvidiaRestartWait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stream runs nvidia-smi until it exits, and reports whether it printed a
+// report.
+func (n *nvidiaSMI) stream(ctx context.Context) (reported bool, err error) {
+	secs := max(1, int(n.interval/time.Second))
+	cmd := exec.CommandContext(ctx, "nvidia-smi", "-q", "-x", "-l", strconv.Itoa(secs))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	dec := xml.NewDecoder(stdout)
+	for {
+		var log nvidiaSMILog
+		if err := dec.Decode(&log); err != nil {
+			break
+		}
+		n.mu.Lock()
+		n.readings, n.at = log.readings(), time.Now()
+		n.mu.Unlock()
+		if !reported {
+			reported = true
+			n.readyOnce.Do(func() { close(n.ready) })
+		}
+	}
+
+	err = cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+	return reported, err
+}
+
+// wait waits up to timeout for the first report.
+func (n *nvidiaSMI) wait(ctx context.Context, timeout time.Duration) {
+	select {
+	case <-n.ready:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+}
+
+// latest returns the latest report, or nil if there is none from the last
+// few intervals.
+func (n *nvidiaSMI) latest() map[string]nvidiaReading {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if time.Since(n.at) > 3*n.interval+nvidiaRestartWait {
+		return nil
+	}
+	return n.readings
+}
+
+// nvidiaSMILog is the part of nvidia-smi's XML report that is used. Field
+// names changed across driver versions, so some values have several
+// fields, of which one is set.
+type nvidiaSMILog struct {
+	GPUs []struct {
+		ID          string `xml:"id,attr"`
+		Temperature string `xml:"temperature>gpu_temp"`
+		VRAMTotal   string `xml:"fb_memory_usage>total"`
+		VRAMUsed    string `xml:"fb_memory_usage>used"`
+
+		PowerDraw        string    `xml:"power_readings>power_draw"`
+		PowerLimit       string    `xml:"power_readings>power_limit"`
+		GPUPowerDraw     string    `xml:"gpu_power_readings>power_draw"`
+		GPUInstantDraw   string    `xml:"gpu_power_readings>instant_power_draw"`
+		GPUCurrentLimit  string    `xml:"gpu_power_readings>current_power_limit"`
+		ECCVolatile      nvidiaECC `xml:"ecc_errors>volatile"`
+		RetiredSingleBit string    `xml:"retired_pages>multiple_single_bit_retirement>retired_count"`
+		RetiredDoubleBit string    `xml:"retired_pages>double_bit_retirement>retired_count"`
+		PendingBlacklist string    `xml:"retired_pages>pending_blacklist"`
+		PendingRetire    string    `xml:"retired_pages>pending_retirement"`
+		RemappedCorr     string    `xml:"remapped_rows>remapped_row_corr"`
+		RemappedUnc      string    `xml:"remapped_rows>remapped_row_unc"`
+		RemappedPending  string    `xml:"remapped_rows>remapped_row_pending"`
+		PCIeReplays      string    `xml:"pci>replay_counter"`
+
+		Processes []struct {
+			PID        string `xml:"pid"`
+			Name       string `xml:"process_name"`
+			UsedMemory string `xml:"used_memory"`
+		} `xml:"processes>process_info"`
+	} `xml:"gpu"`
+}
+
+// nvidiaECC is the volatile ECC error counts: single- and double-bit totals
+// from older drivers, SRAM and DRAM counts from newer ones.
+type nvidiaECC struct {
+	SingleBit         string `xml:"single_bit>total"`
+	DoubleBit         string `xml:"double_bit>total"`
+	SRAMCorrectable   string `xml:"sram_correctable"`
+	SRAMUncorrectable string `xml:"sram_uncorrectable"`
+	SRAMParity        string `xml:"sram_uncorrectable_parity"`
+	SRAMSECDED        string `xml:"sram_uncorrectable_secded"`
+	DRAMCorrectable   string `xml:"dram_correctable"`
+	DRAMUncorrectable string `xml:"dram_uncorrectable"`
+}
+
+// readings returns the report's GPUs by PCI address.
+func (l nvidiaSMILog) readings() map[string]nvidiaReading {
+	readings := make(map[string]nvidiaReading, len(l.GPUs))
+	for _, g := range l.GPUs {
+		r := nvidiaReading{
+			Temperature: int(nvidiaNumber(g.Temperature)),
+			VRAMTotal:   nvidiaNumber(g.VRAMTotal) * 1024 * 1024, // MiB to bytes
+			VRAMUsed:    nvidiaNumber(g.VRAMUsed) * 1024 * 1024,
+			PowerDraw:   nvidiaWatts(cmp.Or(g.GPUInstantDraw, g.GPUPowerDraw, g.PowerDraw)),
+			PowerLimit:  nvidiaWatts(cmp.Or(g.GPUCurrentLimit, g.PowerLimit)),
+			ECCCorrected: nvidiaNumber(g.ECCVolatile.SingleBit) +
+				nvidiaNumber(g.ECCVolatile.SRAMCorrectable) + nvidiaNumber(g.ECCVolatile.DRAMCorrectable),
+			ECCUncorrected: nvidiaNumber(g.ECCVolatile.DoubleBit) +
+				nvidiaNumber(g.ECCVolatile.SRAMUncorrectable) + nvidiaNumber(g.ECCVolatile.SRAMParity) +
+				nvidiaNumber(g.ECCVolatile.SRAMSECDED) + nvidiaNumber(g.ECCVolatile.DRAMUncorrectable),
+			RetiredPages: nvidiaNumber(g.RetiredSingleBit) + nvidiaNumber(g.RetiredDoubleBit) +
+				nvidiaNumber(g.RemappedCorr) + nvidiaNumber(g.RemappedUnc),
+			RetirementPending: g.PendingBlacklist == "Yes" || g.PendingRetire == "Yes" || g.RemappedPending == "Yes",
+			PCIeReplays:       nvidiaNumber(g.PCIeReplays),
+		}
+		for _, p := range g.Processes {
+			pid, err := strconv.Atoi(strings.TrimSpace(p.PID))
+			if err != nil {
+				continue
+			}
+			r.Processes = append(r.Processes, GPUProcess{
+				PID:      pid,
+				Name:     strings.TrimSpace(p.Name),
+				VRAMUsed: nvidiaNumber(p.UsedMemory) * 1024 * 1024,
+			})
+		}
+		slices.SortStableFunc(r.Processes, func(a, b GPUProcess) int { return cmp.Compare(b.VRAMUsed, a.VRAMUsed) })
+		readings[normalizePCIAddress(g.ID)] = r
+	}
+	return readings
+}
+
+// nvidiaNumber parses the integer at the start of an nvidia-smi value such
+// as "45 C" or "4096 MiB". It returns 0 for "N/A" and other non-numbers.
+func nvidiaNumber(s string) int64 {
+	v, err := strconv.ParseInt(firstField(s), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// nvidiaWatts parses a power value such as "31.45 W".
+func nvidiaWatts(s string) float64 {
+	v, err := strconv.ParseFloat(firstField(s), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// applyNvidiaReading fills in a card's telemetry from its nvidia-smi
+// report. sysfs temperature and VRAM readings are kept. A card of unknown
+// address gets the only GPU's report, if there is just one.
+func applyNvidiaReading(gpu *GPUStatus, readings map[string]nvidiaReading) {
+	r, ok := readings[gpu.PCIAddress]
+	if !ok && gpu.PCIAddress == "" && len(readings) == 1 {
+		for _, only := range readings {
+			r, ok = only, true
+		}
+	}
+	if !ok {
+		return
+	}
+	if gpu.Temperature == 0 {
+		gpu.Temperature = r.Temperature
+	}
+	if gpu.VRAMTotal == 0 && r.VRAMTotal > 0 {
+		gpu.VRAMUsed, gpu.VRAMTotal = r.VRAMUsed, r.VRAMTotal
+	}
+	gpu.PowerDraw, gpu.PowerLimit = r.PowerDraw, r.PowerLimit
+	gpu.ECCCorrected, gpu.ECCUncorrected = r.ECCCorrected, r.ECCUncorrected
+	gpu.RetiredPages, gpu.RetirementPending = r.RetiredPages, r.RetirementPending
+	gpu.PCIeReplays = r.PCIeReplays
+	gpu.Processes = r.Processes
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nvidiaSMISample is trimmed from "nvidia-smi -q -x" on a two-GPU host: a
+// data-center card with a newer driver's field names, and a consumer card
+// without ECC.
+const nvidiaSMISample = `<?xml version="1.0" ?>
+<!DOCTYPE nvidia_smi_log SYSTEM "nvsmi_device_v12.dtd">
+<nvidia_smi_log>
+	<driver_version>550.54.15</driver_version>
+	<attached_gpus>2</attached_gpus>
+	<gpu id="00000000:3B:00.0">
+		<product_name>NVIDIA A100-PCIE-40GB</product_name>
+		<fb_memory_usage>
+			<total>40960 MiB</total>
+			<used>30720 MiB</used>
+		</fb_memory_usage>
+		<ecc_errors>
+			<volatile>
+				<sram_correctable>4</sram_correctable>
+				<sram_uncorrectable>0</sram_uncorrectable>
+				<dram_correctable>12</dram_correctable>
+				<dram_uncorrectable>2</dram_uncorrectable>
+			</volatile>
+		</ecc_errors>
+		<retired_pages>
+			<multiple_single_bit_retirement><retired_count>N/A</retired_count></multiple_single_bit_retirement>
+			<double_bit_retirement><retired_count>N/A</retired_count></double_bit_retirement>
+			<pending_retirement>N/A</pending_retirement>
+		</retired_pages>
+		<remapped_rows>
+			<remapped_row_corr>1</remapped_row_corr>
+			<remapped_row_unc>2</remapped_row_unc>
+			<remapped_row_pending>Yes</remapped_row_pending>
+		</remapped_rows>
+		<pci>
+			<pci_bus_id>00000000:3B:00.0</pci_bus_id>
+			<replay_counter>7</replay_counter>
+		</pci>
+		<temperature><gpu_temp>61 C</gpu_temp></temperature>
+		<gpu_power_readings>
+			<instant_power_draw>212.40 W</instant_power_draw>
+			<current_power_limit>250.00 W</current_power_limit>
+		</gpu_power_readings>
+		<processes>
+			<process_info><pid>4100</pid><process_name>/usr/bin/python3</process_name><used_memory>2048 MiB</used_memory></process_info>
+			<process_info><pid>4200</pid><process_name>/opt/trainer</process_name><used_memory>28672 MiB</used_memory></process_info>
+		</processes>
+	</gpu>
+	<gpu id="00000000:AF:00.0">
+		<fb_memory_usage><total>8192 MiB</total><used>512 MiB</used></fb_memory_usage>
+		<ecc_errors><volatile><single_bit><total>N/A</total></single_bit><double_bit><total>N/A</total></double_bit></volatile></ecc_errors>
+		<retired_pages><pending_blacklist>N/A</pending_blacklist></retired_pages>
+		<pci><replay_counter>0</replay_counter></pci>
+		<temperature><gpu_temp>40 C</gpu_temp></temperature>
+		<power_readings><power_draw>15.02 W</power_draw><power_limit>120.00 W</power_limit></power_readings>
+		<processes></processes>
+	</gpu>
+</nvidia_smi_log>
+`
+
+func TestNvidiaSMIReadings(t *testing.T) {
+	var log nvidiaSMILog
+	if err := xml.Unmarshal([]byte(nvidiaSMISample), &log); err != nil {
+		t.Fatal(err)
+	}
+	readings := log.readings()
+	if len(readings) != 2 {
+		t.Fatalf("got %d readings, want 2: %v", len(readings), readings)
+	}
+
+	r := readings["0000:3b:00.0"]
+	if r.Temperature != 61 || r.VRAMUsed != 30720<<20 || r.VRAMTotal != 40960<<20 {
+		t.Errorf("temperature and VRAM = %+v", r)
+	}
+	if r.PowerDraw != 212.4 || r.PowerLimit != 250 {
+		t.Errorf("power = %g / %g W", r.PowerDraw, r.PowerLimit)
+	}
+	if r.ECCCorrected != 16 || r.ECCUncorrected != 2 || r.RetiredPages != 3 || !r.RetirementPending || r.PCIeReplays != 7 {
+		t.Errorf("error counts = %+v", r)
+	}
+	if len(r.Processes) != 2 || r.Processes[0].PID != 4200 || r.Processes[0].VRAMUsed != 28672<<20 {
+		t.Errorf("processes = %+v, want the trainer first", r.Processes)
+	}
+
+	r = readings["0000:af:00.0"]
+	if r.Temperature != 40 || r.PowerDraw != 15.02 || r.PowerLimit != 120 {
+		t.Errorf("older driver fields = %+v", r)
+	}
+	if r.ECCCorrected != 0 || r.ECCUncorrected != 0 || r.RetirementPending || len(r.Processes) != 0 {
+		t.Errorf("card without ECC = %+v", r)
+	}
+}
+
+func TestNvidiaSMIStream(t *testing.T) {
+	// Loop mode prints one document per interval.
+	dec := xml.NewDecoder(strings.NewReader(nvidiaSMISample + "\n" + nvidiaSMISample))
+	for i := range 2 {
+		var log nvidiaSMILog
+		if err := dec.Decode(&log); err != nil || len(log.GPUs) != 2 {
+			t.Fatalf("report %d: %d GPUs, error %v", i+1, len(log.GPUs), err)
+		}
+	}
+}
+
+func TestNvidiaSMIRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake nvidia-smi is a shell script")
+	}
+	// An nvidia-smi that prints one report and exits, as on a driver reset.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.xml"), []byte(nvidiaSMISample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncat \"$(dirname \"$0\")/report.xml\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "nvidia-smi"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	n := &nvidiaSMI{interval: time.Second, ready: make(chan struct{})}
+	for i := range 2 {
+		reported, err := n.stream(context.Background())
+		if !reported || err != nil {
+			t.Fatalf("run %d: reported %v, error %v", i+1, reported, err)
+		}
+	}
+	n.wait(context.Background(), time.Second)
+	if len(n.latest()) != 2 {
+		t.Errorf("latest = %v, want 2 GPUs", n.latest())
+	}
+}
+
+func TestApplyNvidiaReading(t *testing.T) {
+	readings := map[string]nvidiaReading{
+		"0000:01:00.0": {Temperature: 72, VRAMUsed: 4096 << 20, VRAMTotal: 8192 << 20, ECCUncorrected: 1},
+		"0000:02:00.0": {Temperature: 45},
+	}
+
+	gpu := GPUStatus{CardPath: "/sys/class/drm/card1", PCIAddress: "0000:02:00.0", Temperature: 50}
+	applyNvidiaReading(&gpu, readings)
+	if gpu.Temperature != 50 {
+		t.Errorf("sysfs temperature replaced: %d", gpu.Temperature)
+	}
+
+	// Without an address, only a lone GPU's report can be trusted.
+	gpu = GPUStatus{CardPath: "/sys/class/drm/card0"}
+	applyNvidiaReading(&gpu, readings)
+	if gpu.Temperature != 0 {
+		t.Errorf("card without an address got a reading: %+v", gpu)
+	}
+	delete(readings, "0000:02:00.0")
+	applyNvidiaReading(&gpu, readings)
+	if gpu.Temperature != 72 || gpu.VRAMTotal != 8192<<20 || gpu.ECCUncorrected != 1 {
+		t.Errorf("lone GPU = %+v", gpu)
+	}
+}
+
+func TestGPUCountGrowth(t *testing.T) {
+	m := NewGPUMonitor(0, GPUThresholds{})
+	gpu := GPUStatus{CardPath: "/sys/class/drm/card0", PCIAddress: "0000:01:00.0", ECCUncorrected: 3, RetiredPages: 1}
+
+	if evs := m.countGrowth(gpu); len(evs) != 0 {
+		t.Errorf("first reading reported: %+v", evs)
+	}
+	if evs := m.countGrowth(gpu); len(evs) != 0 {
+		t.Errorf("unchanged counts reported: %+v", evs)
+	}
+
+	gpu.ECCUncorrected, gpu.RetiredPages = 5, 2
+	evs := m.countGrowth(gpu)
+	if len(evs) != 2 || evs[0].Reason != "ecc_uncorrected" || evs[0].Growth != 2 ||
+		evs[1].Reason != "retired_pages" || evs[1].Growth != 1 {
+		t.Errorf("growth events = %+v", evs)
+	}
+
+	// A driver reload resets the volatile ECC count.
+	gpu.ECCUncorrected = 0
+	if evs := m.countGrowth(gpu); len(evs) != 0 {
+		t.Errorf("counter reset reported: %+v", evs)
+	}
+}
@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+)
+
+// userHZ is the unit of the CPU times in /proc/[pid]/stat. It is 100 on
+// every Linux architecture logtriage runs on.
+const userHZ = 100
+
+// ProcIO is a process's disk I/O rate, from /proc/[pid]/io.
+type ProcIO struct {
+	PID       int
+	Name      string
+	ReadRate  float64 // bytes per second
+	WriteRate float64 // bytes per second
+}
+
+// ProcCPU is a process's CPU use, from /proc/[pid]/stat.
+type ProcCPU struct {
+	PID    int
+	Name   string
+	CPUPct float64 // percent of one CPU
+}
+
+// TopIOConsumers samples /proc/*/io twice, interval apart, and returns the
+// top N processes by bytes read and written in between.
+func TopIOConsumers(ctx context.Context, interval time.Duration, n int) ([]ProcIO, error) {
+	return topIOConsumers(ctx, "/proc", interval, n)
+}
+
+func topIOConsumers(ctx context.Context, procRoot string, interval time.Duration, n int) ([]ProcIO, error) {
+	deltas, err := sampleProcs(ctx, procRoot, interval, readProcIO)
+	if err != nil {
+		return nil, err
+	}
+	secs := interval.Seconds()
+	var procs []ProcIO
+	for pid, d := range deltas {
+		if d[0] == 0 && d[1] == 0 {
+			continue
+		}
+		procs = append(procs, ProcIO{
+			PID:       pid,
+			Name:      readCommName(filepath.Join(procRoot, strconv.Itoa(pid), "comm")),
+			ReadRate:  float64(d[0]) / secs,
+			WriteRate: float64(d[1]) / secs,
+		})
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		a, b := procs[i].ReadRate+procs[i].WriteRate, procs[j].ReadRate+procs[j].WriteRate
+		if a != b {
+			return a > b
+		}
+		return procs[i].PID < procs[j].PID
+	})
+	if n > 0 && len(procs) > n {
+		procs = procs[:n]
+	}
+	return procs, nil
+}
+
+// TopCPUConsumers samples /proc/*/stat twice, interval apart, and returns
+// the top N processes by CPU time used in between.
+func TopCPUConsumers(ctx context.Context, interval time.Duration, n int) ([]ProcCPU, error) {
+	return topCPUConsumers(ctx, "/proc", interval, n)
+}
+
+func topCPUConsumers(ctx context.Context, procRoot string, interval time.Duration, n int) ([]ProcCPU, error) {
+	deltas, err := sampleProcs(ctx, procRoot, interval, readProcCPU)
+	if err != nil {
+		return nil, err
+	}
+	var procs []ProcCPU
+	for pid, d := range deltas {
+		if d[0] == 0 {
+			continue
+		}
+		procs = append(procs, ProcCPU{
+			PID:    pid,
+			Name:   readCommName(filepath.Join(procRoot, strconv.Itoa(pid), "comm")),
+			CPUPct: float64(d[0]) / userHZ / interval.Seconds() * 100,
+		})
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].CPUPct != procs[j].CPUPct {
+			return procs[i].CPUPct > procs[j].CPUPct
+		}
+		return procs[i].PID < procs[j].PID
+	})
+	if n > 0 && len(procs) > n {
+		procs = procs[:n]
+	}
+	return procs, nil
+}
+
+// sampleProcs reads two counters of every process with read, twice,
+// interval apart, and returns how much they grew for the processes present
+// both times.
+func sampleProcs(ctx context.Context, procRoot string, interval time.Duration, read func(dir string) ([2]int64, bool)) (map[int][2]int64, error) {
+	before, err := readProcCounters(procRoot, read)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(interval):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	after, err := readProcCounters(procRoot, read)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := make(map[int][2]int64, len(after))
+	for pid, a := range after {
+		b, ok := before[pid]
+		if !ok {
+			continue
+		}
+		deltas[pid] = [2]int64{max(0, a[0]-b[0]), max(0, a[1]-b[1])}
+	}
+	return deltas, nil
+}
+
+func readProcCounters(procRoot string, read func(dir string) ([2]int64, bool)) (map[int][2]int64, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", procRoot, err)
+	}
+	counters := make(map[int][2]int64, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // not a PID directory
+		}
+		if c, ok := read(filepath.Join(procRoot, entry.Name())); ok {
+			counters[pid] = c
+		}
+	}
+	return counters, nil
+}
+
+// readProcIO reads the bytes a process read from and wrote to storage.
+func readProcIO(dir string) ([2]int64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "io"))
+	if err != nil {
+		return [2]int64{}, false // process may have exited, or is not ours to read
+	}
+	var c [2]int64
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		v, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		switch key {
+		case "read_bytes":
+			c[0] = v
+		case "write_bytes":
+			c[1] = v
+		}
+	}
+	return c, true
+}
+
+// readProcCPU reads a process's user and system CPU time, in the first
+// counter.
+func readProcCPU(dir string) ([2]int64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return [2]int64{}, false
+	}
+	// The command name, in parentheses, may contain spaces.
+	s := string(data)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 {
+		return [2]int64{}, false
+	}
+	// Fields after the name start at the state, field 3; utime and stime
+	// are fields 14 and 15.
+	fields := strings.Fields(s[i+1:])
+	if len(fields) < 13 {
+		return [2]int64{}, false
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return [2]int64{}, false
+	}
+	return [2]int64{utime + stime}, true
+}
+
+// FormatTopIO formats a list of ProcIO as human-readable lines.
+func FormatTopIO(procs []ProcIO) string {
+	var b strings.Builder
+	for i, p := range procs {
+		fmt.Fprintf(&b, "  %d. %-20s read %s/s, write %s/s\n", i+1, p.Name,
+			format.Bytes(int64(p.ReadRate)), format.Bytes(int64(p.WriteRate)))
+	}
+	return b.String()
+}
+
+// FormatTopCPU formats a list of ProcCPU as human-readable lines.
+func FormatTopCPU(procs []ProcCPU) string {
+	var b strings.Builder
+	for i, p := range procs {
+		fmt.Fprintf(&b, "  %d. %-20s %.0f%% CPU\n", i+1, p.Name, p.CPUPct)
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadProcIOAndCPU(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "io"), []byte("rchar: 5000\nwchar: 300\nsyscr: 10\nsyscw: 3\n"+
+		"read_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "stat"), []byte("1234 (tmux: server) S 1 1234 1234 0 -1 4194560 "+
+		"1700 0 0 0 250 50 0 0 20 0 1 0 12345 10000000 500 18446744073709551615\n"), 0o644)
+
+	if c, ok := readProcIO(dir); !ok || c != [2]int64{4096, 8192} {
+		t.Errorf("readProcIO = %v, %v, want [4096 8192]", c, ok)
+	}
+	if c, ok := readProcCPU(dir); !ok || c[0] != 300 {
+		t.Errorf("readProcCPU = %v, %v, want 300 ticks", c, ok)
+	}
+	if _, ok := readProcIO(filepath.Join(dir, "gone")); ok {
+		t.Error("readProcIO of a missing process succeeded")
+	}
+}
+
+func TestSampleProcs(t *testing.T) {
+	procRoot := t.TempDir()
+	for _, pid := range []string{"10", "20", "30"} {
+		os.MkdirAll(filepath.Join(procRoot, pid), 0o755)
+	}
+	os.MkdirAll(filepath.Join(procRoot, "self"), 0o755)
+
+	// Counters grow between the samples; 30 exits after the first.
+	samples := map[string][][2]int64{
+		"10": {{100, 0}, {600, 50}},
+		"20": {{1000, 1000}, {1000, 1000}},
+		"30": {{0, 0}},
+	}
+	read := func(dir string) ([2]int64, bool) {
+		s := samples[filepath.Base(dir)]
+		if len(s) == 0 {
+			return [2]int64{}, false
+		}
+		samples[filepath.Base(dir)] = s[1:]
+		return s[0], true
+	}
+
+	deltas, err := sampleProcs(context.Background(), procRoot, time.Millisecond, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 || deltas[10] != [2]int64{500, 50} || deltas[20] != [2]int64{} {
+		t.Errorf("deltas = %v", deltas)
+	}
+}
+
+func TestFormatTopIO(t *testing.T) {
+	out := FormatTopIO([]ProcIO{{PID: 7, Name: "rsync", ReadRate: 50 << 20, WriteRate: 1 << 20}})
+	if out != "  1. rsync                read 50.0 MB/s, write 1.0 MB/s\n" {
+		t.Errorf("FormatTopIO = %q", out)
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/setevik/logtriage/internal/selfstat"
 )
 
-// PSIStats holds parsed /proc/pressure/memory values.
+// PSIStats holds parsed /proc/pressure/{memory,cpu,io} values.
 type PSIStats struct {
 	SomeAvg10  float64
 	SomeAvg60  float64
@@ -23,35 +26,86 @@ type PSIStats struct {
 	FullAvg300 float64
 }
 
-// PSIEvent is emitted by the PSI monitor when pressure thresholds are exceeded.
+// PSI resources, each with its file under /proc/pressure.
+const (
+	PSIMemory = "memory"
+	PSICPU    = "cpu"
+	PSIIO     = "io"
+)
+
+// psiHysteresis is the fraction of its thresholds pressure must fall below
+// to end an episode, so pressure hovering at a threshold is one episode.
+const psiHysteresis = 0.8
+
+// PSI event phases.
+const (
+	PSIStarted  = "started"
+	PSIResolved = "resolved"
+)
+
+// PSIEvent is emitted by the PSI monitor when a pressure episode starts and
+// when it ends. Of the top consumers, the list for the monitor's resource
+// is filled when the episode starts.
 type PSIEvent struct {
 	Timestamp    time.Time
+	Resource     string // PSIMemory, PSICPU, or PSIIO
+	Phase        string // PSIStarted or PSIResolved
 	Stats        PSIStats
-	TopConsumers []ProcMem // filled during high-pressure episodes
+	Peak         PSIStats  // the highest of each value in the episode so far
+	Started      time.Time // when the episode started
+	TopConsumers []ProcMem
+	TopIO        []ProcIO
+	TopCPU       []ProcCPU
+}
+
+// Duration returns how long the episode lasted, up to the event.
+func (e PSIEvent) Duration() time.Duration {
+	return e.Timestamp.Sub(e.Started)
 }
 
-// PSIMonitor polls /proc/pressure/memory and emits events when thresholds
-// are exceeded. Under pressure, it switches to high-frequency polling and
-// captures top memory consumers.
+// PSIMonitor polls one /proc/pressure file and emits an event when pressure
+// rises above a threshold and another when it falls back below, with the
+// episode's duration and peak values. Under pressure, it switches to
+// high-frequency polling to track the peak, and captures the top consumers
+// of the resource when the episode starts.
 type PSIMonitor struct {
+	liveness
+
+	resource      string
 	pollInterval  time.Duration
+	mu            sync.Mutex // guards the thresholds, which SetThresholds changes
 	warnSomeAvg10 float64
 	warnFullAvg10 float64
 	procPath      string // override for testing
+
+	started time.Time // start of the episode in progress; zero if none
+	peak    PSIStats
 }
 
-// NewPSIMonitor creates a PSI monitor with the given thresholds.
-func NewPSIMonitor(pollInterval time.Duration, warnSome, warnFull float64) *PSIMonitor {
+// NewPSIMonitor creates a monitor of the pressure of resource, PSIMemory,
+// PSICPU, or PSIIO, with the given thresholds. A zero threshold never
+// fires.
+func NewPSIMonitor(resource string, pollInterval time.Duration, warnSome, warnFull float64) *PSIMonitor {
 	return &PSIMonitor{
+		resource:      resource,
 		pollInterval:  pollInterval,
 		warnSomeAvg10: warnSome,
 		warnFullAvg10: warnFull,
-		procPath:      "/proc/pressure/memory",
+		procPath:      "/proc/pressure/" + resource,
 	}
 }
 
-// Events starts the PSI polling loop and returns a channel of pressure events.
-// Only events that exceed thresholds are emitted.
+// SetThresholds replaces the thresholds of a running monitor, e.g. on a
+// config reload. An episode in progress ends at the next poll below the
+// new ones' hysteresis.
+func (m *PSIMonitor) SetThresholds(warnSome, warnFull float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warnSomeAvg10, m.warnFullAvg10 = warnSome, warnFull
+}
+
+// Events starts the PSI polling loop and returns a channel of pressure
+// episode events.
 func (m *PSIMonitor) Events(ctx context.Context) <-chan PSIEvent {
 	ch := make(chan PSIEvent, 8)
 	go m.poll(ctx, ch)
@@ -64,7 +118,6 @@ func (m *PSIMonitor) poll(ctx context.Context, ch chan<- PSIEvent) {
 	ticker := time.NewTicker(m.pollInterval)
 	defer ticker.Stop()
 
-	var inPressure bool
 	highFreqTicker := time.NewTicker(1 * time.Second)
 	highFreqTicker.Stop() // not started yet
 
@@ -73,59 +126,111 @@ func (m *PSIMonitor) poll(ctx context.Context, ch chan<- PSIEvent) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			m.check(ctx, ch, &inPressure, ticker, highFreqTicker)
+			m.check(ctx, ch, ticker, highFreqTicker)
 		case <-highFreqTicker.C:
-			m.check(ctx, ch, &inPressure, ticker, highFreqTicker)
+			m.check(ctx, ch, ticker, highFreqTicker)
 		}
 	}
 }
 
-func (m *PSIMonitor) check(ctx context.Context, ch chan<- PSIEvent, inPressure *bool, normalTicker, highFreqTicker *time.Ticker) {
+func (m *PSIMonitor) check(ctx context.Context, ch chan<- PSIEvent, normalTicker, highFreqTicker *time.Ticker) {
+	defer m.markPoll()
+
 	stats, err := m.readPSI()
 	if err != nil {
 		slog.Debug("failed to read PSI stats", "error", err)
 		return
 	}
 
-	exceeded := stats.SomeAvg10 > m.warnSomeAvg10 || stats.FullAvg10 > m.warnFullAvg10
+	ev, ok := m.observe(stats, time.Now())
+	if !ok {
+		return
+	}
 
-	if exceeded && !*inPressure {
+	switch ev.Phase {
+	case PSIStarted:
 		// Transition to high-pressure mode.
-		*inPressure = true
 		normalTicker.Stop()
 		highFreqTicker.Reset(1 * time.Second)
 
-		slog.Info("memory pressure detected, switching to high-frequency polling",
+		slog.Info(m.resource+" pressure detected, switching to high-frequency polling",
 			"some_avg10", stats.SomeAvg10,
 			"full_avg10", stats.FullAvg10,
 		)
-	} else if !exceeded && *inPressure {
+
+		// Capture top consumers as pressure builds.
+		switch m.resource {
+		case PSIMemory:
+			if consumers, err := TopMemConsumers(5); err == nil {
+				ev.TopConsumers = consumers
+			}
+		case PSIIO:
+			if consumers, err := TopIOConsumers(ctx, time.Second, 5); err == nil {
+				ev.TopIO = consumers
+			}
+		case PSICPU:
+			if consumers, err := TopCPUConsumers(ctx, time.Second, 5); err == nil {
+				ev.TopCPU = consumers
+			}
+		}
+
+	case PSIResolved:
 		// Transition back to normal.
-		*inPressure = false
 		highFreqTicker.Stop()
 		normalTicker.Reset(m.pollInterval)
 
-		slog.Info("memory pressure subsided, returning to normal polling")
+		slog.Info(m.resource+" pressure subsided, returning to normal polling",
+			"duration", ev.Duration().Round(time.Second),
+			"peak_some_avg10", ev.Peak.SomeAvg10,
+			"peak_full_avg10", ev.Peak.FullAvg10,
+		)
 	}
 
-	if exceeded {
-		ev := PSIEvent{
-			Timestamp: time.Now(),
-			Stats:     stats,
-		}
+	select {
+	case ch <- ev:
+	case <-ctx.Done():
+		return
+	default:
+		// Channel full, drop event.
+		selfstat.Drop(1)
+	}
+}
 
-		// Capture top memory consumers during pressure.
-		if consumers, err := TopMemConsumers(5); err == nil {
-			ev.TopConsumers = consumers
-		}
+// observe tracks pressure episodes with a reading taken at now. It returns
+// an event when an episode starts or ends.
+func (m *PSIMonitor) observe(stats PSIStats, now time.Time) (PSIEvent, bool) {
+	m.mu.Lock()
+	warnSome, warnFull := m.warnSomeAvg10, m.warnFullAvg10
+	m.mu.Unlock()
 
-		select {
-		case ch <- ev:
-		case <-ctx.Done():
-			return
-		default:
-			// Channel full, drop event.
+	ev := PSIEvent{Timestamp: now, Resource: m.resource, Stats: stats}
+	if m.started.IsZero() {
+		if !PSIExceeded(stats, warnSome, warnFull) {
+			return PSIEvent{}, false
 		}
+		m.started, m.peak = now, stats
+		ev.Phase, ev.Peak, ev.Started = PSIStarted, stats, now
+		return ev, true
+	}
+
+	m.peak = maxPSIStats(m.peak, stats)
+	if PSIExceeded(stats, warnSome*psiHysteresis, warnFull*psiHysteresis) {
+		return PSIEvent{}, false
+	}
+	ev.Phase, ev.Peak, ev.Started = PSIResolved, m.peak, m.started
+	m.started, m.peak = time.Time{}, PSIStats{}
+	return ev, true
+}
+
+// maxPSIStats returns the higher of each value of a and b.
+func maxPSIStats(a, b PSIStats) PSIStats {
+	return PSIStats{
+		SomeAvg10:  max(a.SomeAvg10, b.SomeAvg10),
+		SomeAvg60:  max(a.SomeAvg60, b.SomeAvg60),
+		SomeAvg300: max(a.SomeAvg300, b.SomeAvg300),
+		FullAvg10:  max(a.FullAvg10, b.FullAvg10),
+		FullAvg60:  max(a.FullAvg60, b.FullAvg60),
+		FullAvg300: max(a.FullAvg300, b.FullAvg300),
 	}
 }
 
@@ -133,7 +238,13 @@ func (m *PSIMonitor) readPSI() (PSIStats, error) {
 	return ReadPSI(m.procPath)
 }
 
-// ReadPSI parses /proc/pressure/memory (or a test file at the given path).
+// PSIExceeded reports whether stats are above either threshold. A zero
+// threshold never fires.
+func PSIExceeded(stats PSIStats, warnSome, warnFull float64) bool {
+	return (warnSome > 0 && stats.SomeAvg10 > warnSome) || (warnFull > 0 && stats.FullAvg10 > warnFull)
+}
+
+// ReadPSI parses a /proc/pressure file (or a test file at the given path).
 // Format:
 //
 //	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestReadPSI(t *testing.T) {
@@ -88,3 +89,58 @@ func TestParsePSILine(t *testing.T) {
 		}
 	}
 }
+
+func TestPSIExceeded(t *testing.T) {
+	stats := PSIStats{SomeAvg10: 60, FullAvg10: 5}
+	tests := []struct {
+		some, full float64
+		want       bool
+	}{
+		{50, 10, true},
+		{70, 4, true},
+		{70, 10, false},
+		{0, 10, false}, // a zero threshold never fires
+		{0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := PSIExceeded(stats, tt.some, tt.full); got != tt.want {
+			t.Errorf("PSIExceeded(some > %g, full > %g) = %v, want %v", tt.some, tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestPSIObserveEpisode(t *testing.T) {
+	m := NewPSIMonitor(PSIIO, time.Minute, 50, 0)
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(secs int) time.Time { return t0.Add(time.Duration(secs) * time.Second) }
+
+	if _, ok := m.observe(PSIStats{SomeAvg10: 30}, at(0)); ok {
+		t.Fatal("event below threshold")
+	}
+	ev, ok := m.observe(PSIStats{SomeAvg10: 55}, at(1))
+	if !ok || ev.Phase != PSIStarted || ev.Resource != PSIIO || !ev.Started.Equal(at(1)) {
+		t.Fatalf("start = %+v, %v", ev, ok)
+	}
+	// Higher, then within the hysteresis band: the episode goes on.
+	for i, some := range []float64{72, 45, 41} {
+		if ev, ok := m.observe(PSIStats{SomeAvg10: some, SomeAvg60: some / 2}, at(2+i)); ok {
+			t.Fatalf("event at some=%g: %+v", some, ev)
+		}
+	}
+	ev, ok = m.observe(PSIStats{SomeAvg10: 39}, at(30))
+	if !ok || ev.Phase != PSIResolved {
+		t.Fatalf("resolve = %+v, %v", ev, ok)
+	}
+	if ev.Peak.SomeAvg10 != 72 || ev.Peak.SomeAvg60 != 36 {
+		t.Errorf("peak = %+v, want some avg10=72 avg60=36", ev.Peak)
+	}
+	if ev.Duration() != 29*time.Second {
+		t.Errorf("duration = %s, want 29s", ev.Duration())
+	}
+
+	// The next episode starts afresh.
+	ev, ok = m.observe(PSIStats{SomeAvg10: 51}, at(40))
+	if !ok || ev.Phase != PSIStarted || ev.Peak.SomeAvg10 != 51 {
+		t.Errorf("second start = %+v, %v", ev, ok)
+	}
+}
@@ -0,0 +1,401 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/sysdep"
+)
+
+// QuotaUsage is one subject's usage and limits on one filesystem.
+// Block values are in KiB; a limit of 0 means no limit.
+type QuotaUsage struct {
+	Kind       string // "user", "group", or "project"
+	Subject    string // name, or "#id" when unresolved
+	Filesystem string // device or mountpoint the report covers
+
+	BlocksUsed int64
+	BlocksSoft int64
+	BlocksHard int64
+	FilesUsed  int64
+	FilesSoft  int64
+	FilesHard  int64
+}
+
+// QuotaLevel describes how close a subject is to its limits.
+type QuotaLevel int
+
+const (
+	QuotaOK       QuotaLevel = iota
+	QuotaWarning             // usage at or above warn_pct of the effective limit
+	QuotaExceeded            // usage at or above the effective limit
+)
+
+// String returns "ok", "warning", or "exceeded".
+func (l QuotaLevel) String() string {
+	switch l {
+	case QuotaWarning:
+		return "warning"
+	case QuotaExceeded:
+		return "exceeded"
+	default:
+		return "ok"
+	}
+}
+
+// QuotaEvent is emitted when a subject's quota level rises.
+type QuotaEvent struct {
+	Timestamp time.Time
+	Usage     QuotaUsage
+	Level     QuotaLevel
+	Resource  string  // "blocks" or "files", whichever is worse
+	Percent   float64 // usage as a percentage of the effective limit
+}
+
+// QuotaMonitor polls repquota (or xfs_quota as a fallback) and emits events
+// when configured subjects approach or exceed their limits.
+type QuotaMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	mu           sync.Mutex // guards warnPct, which SetWarnPct changes
+	warnPct      float64
+	subjects     []string // "kind:name" filters; empty means all subjects with limits
+	lastLevel    map[string]QuotaLevel
+}
+
+// NewQuotaMonitor creates a quota monitor. subjects are filters of the form
+// "user:alice", "group:media", or "project:web"; the name may be "*".
+func NewQuotaMonitor(pollInterval time.Duration, warnPct float64, subjects []string) *QuotaMonitor {
+	return &QuotaMonitor{
+		pollInterval: pollInterval,
+		warnPct:      warnPct,
+		subjects:     subjects,
+		lastLevel:    make(map[string]QuotaLevel),
+	}
+}
+
+// SetWarnPct replaces the warning threshold of a running monitor, e.g. on
+// a config reload.
+func (m *QuotaMonitor) SetWarnPct(warnPct float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warnPct = warnPct
+}
+
+// Events starts the quota polling loop and returns a channel of quota events.
+func (m *QuotaMonitor) Events(ctx context.Context) <-chan QuotaEvent {
+	ch := make(chan QuotaEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *QuotaMonitor) poll(ctx context.Context, ch chan<- QuotaEvent) {
+	defer close(ch)
+
+	// Initial poll.
+	m.checkAll(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx, ch)
+		}
+	}
+}
+
+func (m *QuotaMonitor) checkAll(ctx context.Context, ch chan<- QuotaEvent) {
+	defer m.markPoll()
+
+	usages, err := readQuotas(ctx)
+	if err != nil {
+		slog.Debug("quota report failed", "error", err)
+		return
+	}
+	m.mu.Lock()
+	warnPct := m.warnPct
+	m.mu.Unlock()
+
+	for _, u := range usages {
+		if !m.matchSubject(u) {
+			continue
+		}
+
+		key := u.Kind + ":" + u.Subject + "@" + u.Filesystem
+		level, resource, pct := EvaluateQuota(u, warnPct)
+		prev := m.lastLevel[key]
+		m.lastLevel[key] = level
+
+		// Only emit when the level rises; recovery resets silently.
+		if level <= prev {
+			continue
+		}
+
+		select {
+		case ch <- QuotaEvent{
+			Timestamp: time.Now(),
+			Usage:     u,
+			Level:     level,
+			Resource:  resource,
+			Percent:   pct,
+		}:
+		case <-ctx.Done():
+			return
+		default:
+			selfstat.Drop(1)
+		}
+	}
+}
+
+// matchSubject reports whether a usage row is selected by the subject filters.
+func (m *QuotaMonitor) matchSubject(u QuotaUsage) bool {
+	if len(m.subjects) == 0 {
+		return true
+	}
+	for _, s := range m.subjects {
+		kind, name, ok := strings.Cut(s, ":")
+		if !ok || kind != u.Kind {
+			continue
+		}
+		if name == "*" || name == u.Subject {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluateQuota returns the quota level for a usage row along with the
+// resource ("blocks" or "files") and percentage that determined it. The
+// effective limit is the soft limit if set, otherwise the hard limit.
+func EvaluateQuota(u QuotaUsage, warnPct float64) (QuotaLevel, string, float64) {
+	blockPct := quotaPercent(u.BlocksUsed, u.BlocksSoft, u.BlocksHard)
+	filePct := quotaPercent(u.FilesUsed, u.FilesSoft, u.FilesHard)
+
+	resource, pct := "blocks", blockPct
+	if filePct > blockPct {
+		resource, pct = "files", filePct
+	}
+
+	switch {
+	case pct >= 100:
+		return QuotaExceeded, resource, pct
+	case pct >= warnPct:
+		return QuotaWarning, resource, pct
+	default:
+		return QuotaOK, resource, pct
+	}
+}
+
+func quotaPercent(used, soft, hard int64) float64 {
+	limit := soft
+	if limit == 0 {
+		limit = hard
+	}
+	if limit == 0 {
+		return 0
+	}
+	return float64(used) * 100 / float64(limit)
+}
+
+// readQuotas collects user, group, and project quota reports from repquota,
+// falling back to xfs_quota when repquota is not installed.
+func readQuotas(ctx context.Context) ([]QuotaUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if sysdep.Have("repquota") {
+		var all []QuotaUsage
+		for _, flag := range []string{"-u", "-g", "-P"} {
+			out, err := exec.CommandContext(ctx, "repquota", "-a", flag).Output()
+			if err != nil && len(out) == 0 {
+				// Project quotas are often unsupported; not an error.
+				continue
+			}
+			all = append(all, parseRepquota(out)...)
+		}
+		return all, nil
+	}
+
+	if sysdep.Have("xfs_quota") {
+		var all []QuotaUsage
+		for _, flag := range []string{"-u", "-g", "-p"} {
+			out, err := exec.CommandContext(ctx, "xfs_quota", "-x", "-c", "report "+flag+" -b -i").Output()
+			if err != nil && len(out) == 0 {
+				continue
+			}
+			all = append(all, parseXFSQuota(out)...)
+		}
+		return all, nil
+	}
+
+	return nil, fmt.Errorf("neither repquota nor xfs_quota found in PATH")
+}
+
+// repquotaHeaderRe matches repquota's per-filesystem header.
+// Example: "*** Report for user quotas on device /dev/sda1"
+var repquotaHeaderRe = regexp.MustCompile(`^\*\*\* Report for (user|group|project) quotas on device (\S+)`)
+
+// parseRepquota parses the default text output of repquota:
+//
+//	*** Report for user quotas on device /dev/sda1
+//	Block grace time: 7days; Inode grace time: 7days
+//	                        Block limits                File limits
+//	User            used    soft    hard  grace    used  soft  hard  grace
+//	----------------------------------------------------------------------
+//	alice     +-  1048576 1000000 1200000  6days     10     0     0
+//
+// The two flag characters mark the block and file soft limits as exceeded,
+// which is also when the corresponding grace column is present.
+func parseRepquota(data []byte) []QuotaUsage {
+	var usages []QuotaUsage
+	var kind, fs string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := repquotaHeaderRe.FindStringSubmatch(line); m != nil {
+			kind, fs = m[1], m[2]
+			continue
+		}
+		if kind == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 8 || len(fields[1]) != 2 || strings.Trim(fields[1], "+-") != "" {
+			continue
+		}
+
+		u := QuotaUsage{Kind: kind, Subject: fields[0], Filesystem: fs}
+		rest := fields[2:]
+		var ok bool
+		if u.BlocksUsed, u.BlocksSoft, u.BlocksHard, rest, ok = takeQuotaTriple(rest, fields[1][0] == '+'); !ok {
+			continue
+		}
+		if u.FilesUsed, u.FilesSoft, u.FilesHard, _, ok = takeQuotaTriple(rest, fields[1][1] == '+'); !ok {
+			continue
+		}
+		usages = append(usages, u)
+	}
+	return usages
+}
+
+// takeQuotaTriple consumes "used soft hard [grace]" from fields.
+func takeQuotaTriple(fields []string, hasGrace bool) (used, soft, hard int64, rest []string, ok bool) {
+	if len(fields) < 3 {
+		return 0, 0, 0, nil, false
+	}
+	var err error
+	if used, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return 0, 0, 0, nil, false
+	}
+	if soft, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return 0, 0, 0, nil, false
+	}
+	if hard, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return 0, 0, 0, nil, false
+	}
+	rest = fields[3:]
+	if hasGrace && len(rest) > 0 {
+		rest = rest[1:]
+	}
+	return used, soft, hard, rest, true
+}
+
+// xfsQuotaHeaderRe matches xfs_quota's per-filesystem report header.
+// Example: "User quota on /data (/dev/sdb1)"
+var xfsQuotaHeaderRe = regexp.MustCompile(`^(User|Group|Project) quota on (\S+)`)
+
+// xfsGraceRe matches bracketed grace/warn columns like "[--------]" or "[6 days]".
+var xfsGraceRe = regexp.MustCompile(`\[[^\]]*\]`)
+
+// parseXFSQuota parses `xfs_quota -x -c 'report -u -b -i'` output. Each data
+// row is: name used soft hard warn [grace] used soft hard warn [grace].
+func parseXFSQuota(data []byte) []QuotaUsage {
+	var usages []QuotaUsage
+	var kind, fs string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := xfsQuotaHeaderRe.FindStringSubmatch(line); m != nil {
+			kind, fs = strings.ToLower(m[1]), m[2]
+			continue
+		}
+		if kind == "" {
+			continue
+		}
+
+		fields := strings.Fields(xfsGraceRe.ReplaceAllString(line, ""))
+		if len(fields) != 9 {
+			continue
+		}
+		var nums [8]int64
+		valid := true
+		for i := range nums {
+			v, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			nums[i] = v
+		}
+		if !valid {
+			continue
+		}
+
+		usages = append(usages, QuotaUsage{
+			Kind:       kind,
+			Subject:    fields[0],
+			Filesystem: fs,
+			BlocksUsed: nums[0],
+			BlocksSoft: nums[1],
+			BlocksHard: nums[2],
+			FilesUsed:  nums[4],
+			FilesSoft:  nums[5],
+			FilesHard:  nums[6],
+		})
+	}
+	return usages
+}
+
+// FormatQuotaUsage returns a human-readable summary of a quota row.
+func FormatQuotaUsage(u QuotaUsage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subject: %s %s\nFilesystem: %s\n", u.Kind, u.Subject, u.Filesystem)
+	fmt.Fprintf(&b, "  Blocks: %s used (soft %s, hard %s)\n",
+		format.Bytes(u.BlocksUsed*1024), formatQuotaKiB(u.BlocksSoft), formatQuotaKiB(u.BlocksHard))
+	fmt.Fprintf(&b, "  Files:  %d used (soft %s, hard %s)\n",
+		u.FilesUsed, formatQuotaCount(u.FilesSoft), formatQuotaCount(u.FilesHard))
+	return b.String()
+}
+
+func formatQuotaKiB(kib int64) string {
+	if kib == 0 {
+		return "none"
+	}
+	return format.Bytes(kib * 1024)
+}
+
+func formatQuotaCount(n int64) string {
+	if n == 0 {
+		return "none"
+	}
+	return strconv.FormatInt(n, 10)
+}
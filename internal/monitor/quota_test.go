@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleRepquota = `*** Report for user quotas on device /dev/sda1
+Block grace time: 7days; Inode grace time: 7days
+                        Block limits                File limits
+User            used    soft    hard  grace    used  soft  hard  grace
+----------------------------------------------------------------------
+root      --      20       0       0              2     0     0
+alice     +-  1048576 1000000 1200000  6days     10     0     0
+bob       --   450000  500000  600000            95   100   120
+carol     -+       12       0       0            130   100   120  none
+
+*** Report for group quotas on device /dev/sda1
+Block grace time: 7days; Inode grace time: 7days
+                        Block limits                File limits
+Group           used    soft    hard  grace    used  soft  hard  grace
+----------------------------------------------------------------------
+media     --   900000       0 1000000              5     0     0
+`
+
+func TestParseRepquota(t *testing.T) {
+	usages := parseRepquota([]byte(sampleRepquota))
+	if len(usages) != 5 {
+		t.Fatalf("got %d rows, want 5: %+v", len(usages), usages)
+	}
+
+	alice := usages[1]
+	if alice.Kind != "user" || alice.Subject != "alice" || alice.Filesystem != "/dev/sda1" {
+		t.Errorf("alice row = %+v", alice)
+	}
+	if alice.BlocksUsed != 1048576 || alice.BlocksSoft != 1000000 || alice.BlocksHard != 1200000 {
+		t.Errorf("alice blocks = %d/%d/%d", alice.BlocksUsed, alice.BlocksSoft, alice.BlocksHard)
+	}
+	if alice.FilesUsed != 10 {
+		t.Errorf("alice files used = %d, want 10 (grace column not skipped?)", alice.FilesUsed)
+	}
+
+	carol := usages[3]
+	if carol.FilesUsed != 130 || carol.FilesSoft != 100 || carol.FilesHard != 120 {
+		t.Errorf("carol files = %d/%d/%d", carol.FilesUsed, carol.FilesSoft, carol.FilesHard)
+	}
+
+	media := usages[4]
+	if media.Kind != "group" || media.Subject != "media" || media.BlocksHard != 1000000 {
+		t.Errorf("media row = %+v", media)
+	}
+}
+
+func TestParseXFSQuota(t *testing.T) {
+	input := `User quota on /data (/dev/sdb1)
+                               Blocks                                          Inodes
+User ID          Used       Soft       Hard    Warn/Grace           Used       Soft       Hard    Warn/ Grace
+---------- -------------------------------------------------- --------------------------------------------------
+root                0          0          0     00 [--------]          3          0          0     00 [--------]
+alice          524288     500000     600000     00  [6 days]         42          0          0     00 [--------]
+
+Project quota on /data (/dev/sdb1)
+                               Blocks                                          Inodes
+Project ID       Used       Soft       Hard    Warn/Grace           Used       Soft       Hard    Warn/ Grace
+---------- -------------------------------------------------- --------------------------------------------------
+web             10240          0      20480     00 [--------]        100          0          0     00 [--------]
+`
+	usages := parseXFSQuota([]byte(input))
+	if len(usages) != 3 {
+		t.Fatalf("got %d rows, want 3: %+v", len(usages), usages)
+	}
+
+	alice := usages[1]
+	if alice.Kind != "user" || alice.Subject != "alice" || alice.Filesystem != "/data" {
+		t.Errorf("alice row = %+v", alice)
+	}
+	if alice.BlocksUsed != 524288 || alice.BlocksSoft != 500000 || alice.FilesUsed != 42 {
+		t.Errorf("alice usage = %+v", alice)
+	}
+
+	web := usages[2]
+	if web.Kind != "project" || web.BlocksHard != 20480 || web.FilesUsed != 100 {
+		t.Errorf("web row = %+v", web)
+	}
+}
+
+func TestEvaluateQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		u        QuotaUsage
+		level    QuotaLevel
+		resource string
+	}{
+		{"no limits", QuotaUsage{BlocksUsed: 100}, QuotaOK, "blocks"},
+		{"under warn", QuotaUsage{BlocksUsed: 50, BlocksSoft: 100}, QuotaOK, "blocks"},
+		{"warn on soft", QuotaUsage{BlocksUsed: 95, BlocksSoft: 100, BlocksHard: 200}, QuotaWarning, "blocks"},
+		{"hard only", QuotaUsage{BlocksUsed: 95, BlocksHard: 100}, QuotaWarning, "blocks"},
+		{"exceeded soft", QuotaUsage{BlocksUsed: 105, BlocksSoft: 100, BlocksHard: 200}, QuotaExceeded, "blocks"},
+		{"files worse", QuotaUsage{BlocksUsed: 10, BlocksSoft: 100, FilesUsed: 100, FilesSoft: 100}, QuotaExceeded, "files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, resource, _ := EvaluateQuota(tt.u, 90)
+			if level != tt.level {
+				t.Errorf("level = %s, want %s", level, tt.level)
+			}
+			if resource != tt.resource {
+				t.Errorf("resource = %q, want %q", resource, tt.resource)
+			}
+		})
+	}
+}
+
+func TestQuotaMatchSubject(t *testing.T) {
+	m := NewQuotaMonitor(0, 90, []string{"user:alice", "project:*"})
+
+	tests := []struct {
+		u    QuotaUsage
+		want bool
+	}{
+		{QuotaUsage{Kind: "user", Subject: "alice"}, true},
+		{QuotaUsage{Kind: "user", Subject: "bob"}, false},
+		{QuotaUsage{Kind: "group", Subject: "alice"}, false},
+		{QuotaUsage{Kind: "project", Subject: "web"}, true},
+	}
+	for _, tt := range tests {
+		if got := m.matchSubject(tt.u); got != tt.want {
+			t.Errorf("matchSubject(%s:%s) = %v, want %v", tt.u.Kind, tt.u.Subject, got, tt.want)
+		}
+	}
+
+	all := NewQuotaMonitor(0, 90, nil)
+	if !all.matchSubject(QuotaUsage{Kind: "group", Subject: "media"}) {
+		t.Error("empty subject list should match everything")
+	}
+}
+
+func TestFormatQuotaUsage(t *testing.T) {
+	out := FormatQuotaUsage(QuotaUsage{
+		Kind: "user", Subject: "alice", Filesystem: "/dev/sda1",
+		BlocksUsed: 1024, BlocksSoft: 0, BlocksHard: 2048,
+		FilesUsed: 3,
+	})
+	if !strings.Contains(out, "Subject: user alice") {
+		t.Errorf("missing subject: %q", out)
+	}
+	if !strings.Contains(out, "soft none") {
+		t.Errorf("zero limit should render as none: %q", out)
+	}
+}
@@ -10,7 +10,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/sysdep"
 )
 
 // SMARTStatus represents the health status of a disk.
@@ -19,32 +23,106 @@ type SMARTStatus struct {
 	ModelName    string
 	Healthy      bool
 	Temperature  int
+	Rotational   bool // spinning disk; false for SSDs and NVMe
 	ReallocCount int
 	PendCount    int
 	ErrorCount   int
 }
 
-// SMARTEvent is emitted when a disk's SMART status changes or has errors.
+// Kind returns "HDD" for spinning disks and "SSD" otherwise.
+func (s SMARTStatus) Kind() string {
+	if s.Rotational {
+		return "HDD"
+	}
+	return "SSD"
+}
+
+// SMART event reasons.
+const (
+	SMARTReasonHealth      = "health"      // health failed, or error counters are non-zero or changed
+	SMARTReasonTemperature = "temperature" // sustained temperature above the drive's limit
+)
+
+// SMARTEvent is emitted when a disk's SMART status changes or has errors,
+// or when it stays too hot.
 type SMARTEvent struct {
 	Timestamp time.Time
 	Status    SMARTStatus
-	Changed   bool // true if status changed since last poll
+	Changed   bool   // true if status changed since last poll
+	Recovered bool   // true if the drive is clean again after it was not; see smartClean
+	Reason    string // SMARTReasonHealth or SMARTReasonTemperature
+
+	// For temperature events: the drive's limit and when it was first
+	// seen above it.
+	TempLimit int
+	HotSince  time.Time
+}
+
+// SMARTTempLimits are the temperatures above which drives are reported.
+// Spinning disks run much cooler than SSDs, so each has its own default. A
+// zero limit disables temperature alerts for that kind of drive.
+type SMARTTempLimits struct {
+	HDD     int
+	SSD     int
+	Devices []SMARTDeviceLimit // per-device overrides; the first match wins
+	Sustain time.Duration      // how long a drive must stay above its limit
+}
+
+// SMARTDeviceLimit overrides the temperature limit for devices matching a
+// glob such as "/dev/nvme*".
+type SMARTDeviceLimit struct {
+	Pattern string
+	Limit   int
+}
+
+// Limit returns the temperature limit for a drive.
+func (l SMARTTempLimits) Limit(s SMARTStatus) int {
+	for _, d := range l.Devices {
+		if ok, _ := filepath.Match(d.Pattern, s.Device); ok {
+			return d.Limit
+		}
+	}
+	if s.Rotational {
+		return l.HDD
+	}
+	return l.SSD
 }
 
 // SMARTMonitor polls smartctl for disk health and emits events on changes.
 type SMARTMonitor struct {
+	liveness
+
 	pollInterval time.Duration
+	mu           sync.Mutex // guards temps, which SetTempLimits changes
+	temps        SMARTTempLimits
+	record       func(SMARTStatus) // called with every reading; may be nil
 	lastStatus   map[string]SMARTStatus
+	hotSince     map[string]time.Time // device -> first poll above its limit
+	hotReported  map[string]bool      // device -> temperature event sent for this episode
 }
 
-// NewSMARTMonitor creates a SMART monitor with the given poll interval.
-func NewSMARTMonitor(pollInterval time.Duration) *SMARTMonitor {
+// NewSMARTMonitor creates a SMART monitor with the given poll interval and
+// temperature limits. record, if not nil, is called from the polling
+// goroutine with every successful reading, e.g. to keep temperature history.
+func NewSMARTMonitor(pollInterval time.Duration, temps SMARTTempLimits, record func(SMARTStatus)) *SMARTMonitor {
 	return &SMARTMonitor{
 		pollInterval: pollInterval,
+		temps:        temps,
+		record:       record,
 		lastStatus:   make(map[string]SMARTStatus),
+		hotSince:     make(map[string]time.Time),
+		hotReported:  make(map[string]bool),
 	}
 }
 
+// SetTempLimits replaces the temperature limits of a running monitor, e.g.
+// on a config reload, keeping the drives' hot episodes.
+func (m *SMARTMonitor) SetTempLimits(temps SMARTTempLimits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.temps = temps
+}
+
 // Events starts the SMART polling loop and returns a channel of disk events.
 // Only events with status changes or errors are emitted.
 func (m *SMARTMonitor) Events(ctx context.Context) <-chan SMARTEvent {
@@ -73,39 +151,107 @@ func (m *SMARTMonitor) poll(ctx context.Context, ch chan<- SMARTEvent) {
 }
 
 func (m *SMARTMonitor) checkAll(ctx context.Context, ch chan<- SMARTEvent) {
+	defer m.markPoll()
+
 	devices, err := detectDisks()
 	if err != nil {
-		slog.Debug("failed to detect disks", "error", err)
+		slog.Warn("failed to detect disks", "error", err)
 		return
 	}
 
 	for _, dev := range devices {
 		status, err := querySMART(ctx, dev)
 		if err != nil {
-			slog.Debug("smartctl query failed", "device", dev, "error", err)
+			slog.Warn("smartctl query failed", "device", dev, "error", err)
 			continue
 		}
 
+		if m.record != nil {
+			m.record(status)
+		}
+
 		prev, seen := m.lastStatus[dev]
 		changed := !seen || statusChanged(prev, status)
+		m.lastStatus[dev] = status
 
+		var events []SMARTEvent
 		if changed || !status.Healthy || status.ReallocCount > 0 || status.PendCount > 0 {
-			ev := SMARTEvent{
+			events = append(events, SMARTEvent{
 				Timestamp: time.Now(),
 				Status:    status,
 				Changed:   changed,
-			}
+				Recovered: seen && !smartClean(prev) && smartClean(status),
+				Reason:    SMARTReasonHealth,
+			})
+		}
+		if ev, ok := m.checkTemp(status, time.Now()); ok {
+			events = append(events, ev)
+		}
 
+		for _, ev := range events {
 			select {
 			case ch <- ev:
 			case <-ctx.Done():
 				return
 			default:
+				selfstat.Drop(1)
 			}
 		}
+	}
+}
 
-		m.lastStatus[dev] = status
+// checkTemp tracks how long a drive has been above its temperature limit
+// and returns an event once it has stayed there for the sustain period. A
+// single hot sample is not reported; each episode is reported once and
+// ends when the drive cools below the limit.
+func (m *SMARTMonitor) checkTemp(s SMARTStatus, now time.Time) (SMARTEvent, bool) {
+	m.mu.Lock()
+	temps := m.temps
+	m.mu.Unlock()
+
+	limit := temps.Limit(s)
+	if limit <= 0 || s.Temperature <= 0 || s.Temperature < limit {
+		delete(m.hotSince, s.Device)
+		delete(m.hotReported, s.Device)
+		return SMARTEvent{}, false
+	}
+
+	since, hot := m.hotSince[s.Device]
+	if !hot {
+		since = now
+		m.hotSince[s.Device] = now
+	}
+	// A single sample is never sustained, however short the period.
+	if !hot || now.Sub(since) < temps.Sustain || m.hotReported[s.Device] {
+		return SMARTEvent{}, false
+	}
+	m.hotReported[s.Device] = true
+	return SMARTEvent{
+		Timestamp: now,
+		Status:    s,
+		Reason:    SMARTReasonTemperature,
+		TempLimit: limit,
+		HotSince:  since,
+	}, true
+}
+
+// ReadSMARTAll queries every detected disk once. Disks smartctl cannot read
+// are skipped.
+func ReadSMARTAll(ctx context.Context) []SMARTStatus {
+	devices, err := detectDisks()
+	if err != nil {
+		return nil
+	}
+
+	var statuses []SMARTStatus
+	for _, dev := range devices {
+		status, err := querySMART(ctx, dev)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, status)
 	}
+	return statuses
 }
 
 // detectDisks finds block devices that support SMART.
@@ -132,6 +278,9 @@ func detectDisks() ([]string, error) {
 
 // querySMART runs smartctl and parses the JSON output.
 func querySMART(ctx context.Context, device string) (SMARTStatus, error) {
+	if !sysdep.Have("smartctl") {
+		return SMARTStatus{}, fmt.Errorf("smartctl not installed")
+	}
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
@@ -159,6 +308,7 @@ type smartJSON struct {
 	Temperature struct {
 		Current int `json:"current"`
 	} `json:"temperature"`
+	RotationRate       int `json:"rotation_rate"` // rpm; 0 for solid state, absent for NVMe
 	ATASmartAttributes struct {
 		Table []struct {
 			ID    int    `json:"id"`
@@ -182,6 +332,7 @@ func parseSMARTJSON(device string, data []byte) (SMARTStatus, error) {
 		ModelName:   j.ModelName,
 		Healthy:     j.SmartStatus.Passed,
 		Temperature: j.Temperature.Current,
+		Rotational:  j.RotationRate > 0,
 	}
 
 	// Extract key SMART attributes.
@@ -199,6 +350,12 @@ func parseSMARTJSON(device string, data []byte) (SMARTStatus, error) {
 	return status, nil
 }
 
+// smartClean reports whether a drive passes its health check with no
+// reallocated or pending sectors, the state that is not alerted on.
+func smartClean(s SMARTStatus) bool {
+	return s.Healthy && s.ReallocCount == 0 && s.PendCount == 0
+}
+
 func statusChanged(prev, curr SMARTStatus) bool {
 	return prev.Healthy != curr.Healthy ||
 		prev.ReallocCount != curr.ReallocCount ||
@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSMARTJSONRotation(t *testing.T) {
+	hdd, err := parseSMARTJSON("/dev/sda", []byte(`{"model_name":"WDC WD40EFRX","smart_status":{"passed":true},"temperature":{"current":41},"rotation_rate":5400}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hdd.Rotational || hdd.Kind() != "HDD" || hdd.Temperature != 41 {
+		t.Errorf("hdd = %+v", hdd)
+	}
+
+	nvme, err := parseSMARTJSON("/dev/nvme0", []byte(`{"model_name":"Samsung 980","smart_status":{"passed":true},"temperature":{"current":55}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nvme.Rotational || nvme.Kind() != "SSD" {
+		t.Errorf("nvme = %+v", nvme)
+	}
+}
+
+func TestSMARTTempLimit(t *testing.T) {
+	l := SMARTTempLimits{
+		HDD: 50,
+		SSD: 70,
+		Devices: []SMARTDeviceLimit{
+			{Pattern: "/dev/sdb", Limit: 45},
+			{Pattern: "/dev/nvme*", Limit: 0},
+		},
+	}
+	tests := []struct {
+		status SMARTStatus
+		want   int
+	}{
+		{SMARTStatus{Device: "/dev/sda", Rotational: true}, 50},
+		{SMARTStatus{Device: "/dev/sdc"}, 70},
+		{SMARTStatus{Device: "/dev/sdb", Rotational: true}, 45},
+		{SMARTStatus{Device: "/dev/nvme0"}, 0},
+	}
+	for _, tt := range tests {
+		if got := l.Limit(tt.status); got != tt.want {
+			t.Errorf("Limit(%s) = %d, want %d", tt.status.Device, got, tt.want)
+		}
+	}
+}
+
+func TestSMARTCheckTemp(t *testing.T) {
+	m := NewSMARTMonitor(time.Hour, SMARTTempLimits{HDD: 50, SSD: 70, Sustain: 30 * time.Minute}, nil)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hot := SMARTStatus{Device: "/dev/sda", Rotational: true, Temperature: 55}
+	cool := SMARTStatus{Device: "/dev/sda", Rotational: true, Temperature: 45}
+
+	steps := []struct {
+		status SMARTStatus
+		at     time.Duration
+		want   bool
+	}{
+		{hot, 0, false},                 // a single sample is not sustained
+		{hot, 20 * time.Minute, false},  // not yet long enough
+		{hot, 30 * time.Minute, true},   // sustained
+		{hot, 60 * time.Minute, false},  // reported once per episode
+		{cool, 70 * time.Minute, false}, // episode ends
+		{hot, 80 * time.Minute, false},  // a new episode starts over
+		{hot, 110 * time.Minute, true},
+	}
+	for i, s := range steps {
+		ev, ok := m.checkTemp(s.status, start.Add(s.at))
+		if ok != s.want {
+			t.Fatalf("step %d: event = %v, want %v", i, ok, s.want)
+		}
+		if ok && (ev.Reason != SMARTReasonTemperature || ev.TempLimit != 50 || ev.HotSince.IsZero()) {
+			t.Errorf("step %d: event = %+v", i, ev)
+		}
+	}
+
+	// An SSD at the same temperature is within its limit.
+	ssd := SMARTStatus{Device: "/dev/sdb", Temperature: 55}
+	for _, at := range []time.Duration{0, time.Hour, 2 * time.Hour} {
+		if _, ok := m.checkTemp(ssd, start.Add(at)); ok {
+			t.Error("SSD at 55°C reported against a 70°C limit")
+		}
+	}
+}
+
+func TestSMARTClean(t *testing.T) {
+	tests := []struct {
+		s    SMARTStatus
+		want bool
+	}{
+		{SMARTStatus{Healthy: true}, true},
+		{SMARTStatus{Healthy: false}, false},
+		{SMARTStatus{Healthy: true, PendCount: 2}, false},
+		{SMARTStatus{Healthy: true, ReallocCount: 8}, false},
+		{SMARTStatus{Healthy: true, ErrorCount: 3}, true}, // logged errors are history, not a condition
+	}
+	for _, tt := range tests {
+		if got := smartClean(tt.s); got != tt.want {
+			t.Errorf("smartClean(%+v) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// VMStat holds the cumulative paging counters from /proc/vmstat.
+type VMStat struct {
+	PgMajFault uint64 // major page faults: pages read back from disk or swap
+	PswpIn     uint64 // pages swapped in
+	PswpOut    uint64 // pages swapped out
+}
+
+// MemInfo holds the memory and swap totals from /proc/meminfo, in bytes.
+type MemInfo struct {
+	MemTotal     int64
+	MemAvailable int64
+	SwapTotal    int64
+	SwapFree     int64
+}
+
+// AvailablePct returns available memory as a percentage of the total, or
+// 100 if the total is unknown.
+func (mi MemInfo) AvailablePct() float64 {
+	if mi.MemTotal <= 0 {
+		return 100
+	}
+	return float64(mi.MemAvailable) / float64(mi.MemTotal) * 100
+}
+
+// Thrash event kinds.
+const (
+	ThrashPaging    = "paging"
+	ThrashLowMemory = "low_memory"
+)
+
+// ThrashThresholds are the limits of a ThrashMonitor. A zero SwapRate or
+// MemAvailablePct disables that check.
+type ThrashThresholds struct {
+	MajFaultRate    float64       // major faults per second
+	SwapRate        float64       // pages swapped in plus out per second
+	MemAvailablePct float64       // percent of MemTotal
+	Sustain         time.Duration // how long a limit must stay crossed
+}
+
+// ProcFaults is a process's major page fault rate during a thrash episode.
+type ProcFaults struct {
+	PID  int
+	Name string
+	Rate float64 // major faults per second
+}
+
+// ThrashEvent is emitted once per episode when paging has stayed above its
+// thresholds, or available memory below its threshold, for the sustain
+// period.
+type ThrashEvent struct {
+	Kind         string // ThrashPaging or ThrashLowMemory
+	Timestamp    time.Time
+	Since        time.Time // when the episode started
+	MajFaultRate float64   // system-wide major faults per second over the episode
+	SwapInRate   float64   // pages per second
+	SwapOutRate  float64
+	Mem          MemInfo      // at the time of the event, if readable
+	Top          []ProcFaults // processes faulting the most, worst first
+	TopMem       []ProcMem    // largest processes, for low memory
+}
+
+// procFault is a process's cumulative major fault count.
+type procFault struct {
+	name   string
+	majflt uint64
+}
+
+// ThrashMonitor samples /proc/vmstat and /proc/meminfo and reports
+// sustained swap thrashing, a high rate of major page faults or swapping,
+// and sustained low available memory, before the OOM killer steps in.
+// Unlike PSI averages, which lag by tens of seconds, the rates react within
+// one poll, and the per-process counters in /proc/<pid>/stat name the
+// processes doing the faulting.
+type ThrashMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	mu           sync.Mutex // guards thresholds, which SetThresholds changes
+	thresholds   ThrashThresholds
+
+	readVMStat  func() (VMStat, error)
+	readMemInfo func() (MemInfo, error)
+	readProcs   func() map[int]procFault
+	readTopMem  func() []ProcMem
+
+	// Sampling state.
+	prev     VMStat
+	prevTime time.Time
+	hotSince time.Time // zero when not thrashing
+	hotStart VMStat    // counters when the episode began
+	hotProcs map[int]procFault
+	reported bool
+
+	lowSince    time.Time // zero when available memory is not low
+	lowReported bool
+	lastRates   [3]float64 // major faults, swap in, swap out per second at the last sample
+}
+
+// NewThrashMonitor creates a thrash monitor that reports when paging stays
+// at or above its thresholds, or available memory below its threshold, for
+// the sustain period.
+func NewThrashMonitor(pollInterval time.Duration, thresholds ThrashThresholds) *ThrashMonitor {
+	return &ThrashMonitor{
+		pollInterval: pollInterval,
+		thresholds:   thresholds,
+		readVMStat:   func() (VMStat, error) { return ReadVMStat(filepath.Join(procRoot, "vmstat")) },
+		readMemInfo:  func() (MemInfo, error) { return ReadMemInfo(filepath.Join(procRoot, "meminfo")) },
+		readProcs:    func() map[int]procFault { return readProcFaults(procRoot) },
+		readTopMem: func() []ProcMem {
+			top, _ := topMemConsumers(procRoot, 5)
+			return top
+		},
+	}
+}
+
+// SetThresholds replaces the thresholds of a running monitor, e.g. on a
+// config reload, keeping any episode in progress.
+func (m *ThrashMonitor) SetThresholds(thresholds ThrashThresholds) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thresholds = thresholds
+}
+
+// Events starts the sampling loop and returns a channel of thrash events.
+func (m *ThrashMonitor) Events(ctx context.Context) <-chan ThrashEvent {
+	ch := make(chan ThrashEvent, 4)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *ThrashMonitor) poll(ctx context.Context, ch chan<- ThrashEvent) {
+	defer close(ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			for _, check := range []func(time.Time) (ThrashEvent, bool){m.check, m.checkMemory} {
+				ev, ok := check(now)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				default:
+					selfstat.Drop(1)
+				}
+			}
+		}
+	}
+}
+
+// check takes one paging sample and returns an event when a thrash
+// episode has just become sustained. Each episode is reported once; it ends
+// when a sample falls below the thresholds.
+func (m *ThrashMonitor) check(now time.Time) (ThrashEvent, bool) {
+	defer m.markPoll()
+	m.mu.Lock()
+	t := m.thresholds
+	m.mu.Unlock()
+
+	vs, err := m.readVMStat()
+	if err != nil {
+		slog.Debug("failed to read vmstat", "error", err)
+		return ThrashEvent{}, false
+	}
+	prev, prevTime := m.prev, m.prevTime
+	m.prev, m.prevTime = vs, now
+	if prevTime.IsZero() || vs.PgMajFault < prev.PgMajFault {
+		return ThrashEvent{}, false // first sample, or counters reset
+	}
+
+	secs := now.Sub(prevTime).Seconds()
+	rate := float64(vs.PgMajFault-prev.PgMajFault) / secs
+	swapIn, swapOut := counterRate(prev.PswpIn, vs.PswpIn, secs), counterRate(prev.PswpOut, vs.PswpOut, secs)
+	m.lastRates = [3]float64{rate, swapIn, swapOut}
+	if rate < t.MajFaultRate && (t.SwapRate <= 0 || swapIn+swapOut < t.SwapRate) {
+		if !m.hotSince.IsZero() {
+			slog.Debug("paging back to normal", "majfault_rate", rate, "swap_rate", swapIn+swapOut)
+		}
+		m.hotSince, m.hotProcs, m.reported = time.Time{}, nil, false
+		return ThrashEvent{}, false
+	}
+
+	if m.hotSince.IsZero() {
+		// The episode started during the last interval; count from its start.
+		m.hotSince, m.hotStart = prevTime, prev
+		m.hotProcs = m.readProcs()
+	}
+	if m.reported || now.Sub(m.hotSince) < t.Sustain {
+		return ThrashEvent{}, false
+	}
+	m.reported = true
+
+	elapsed := now.Sub(m.hotSince).Seconds()
+	mem, _ := m.readMemInfo()
+	return ThrashEvent{
+		Kind:         ThrashPaging,
+		Timestamp:    now,
+		Since:        m.hotSince,
+		MajFaultRate: float64(vs.PgMajFault-m.hotStart.PgMajFault) / elapsed,
+		SwapInRate:   counterRate(m.hotStart.PswpIn, vs.PswpIn, elapsed),
+		SwapOutRate:  counterRate(m.hotStart.PswpOut, vs.PswpOut, elapsed),
+		Mem:          mem,
+		Top:          topFaulters(m.hotProcs, m.readProcs(), elapsed, 5),
+	}, true
+}
+
+// checkMemory reads /proc/meminfo and returns an event when available
+// memory has just stayed below its threshold for the sustain period. Each
+// episode is reported once; it ends when available memory is back above
+// the threshold.
+func (m *ThrashMonitor) checkMemory(now time.Time) (ThrashEvent, bool) {
+	m.mu.Lock()
+	t := m.thresholds
+	m.mu.Unlock()
+	if t.MemAvailablePct <= 0 {
+		return ThrashEvent{}, false
+	}
+
+	mem, err := m.readMemInfo()
+	if err != nil {
+		slog.Debug("failed to read meminfo", "error", err)
+		return ThrashEvent{}, false
+	}
+	if mem.AvailablePct() >= t.MemAvailablePct {
+		if !m.lowSince.IsZero() {
+			slog.Debug("available memory back to normal", "available", mem.MemAvailable)
+		}
+		m.lowSince, m.lowReported = time.Time{}, false
+		return ThrashEvent{}, false
+	}
+
+	if m.lowSince.IsZero() {
+		m.lowSince = now
+	}
+	if m.lowReported || now.Sub(m.lowSince) < t.Sustain {
+		return ThrashEvent{}, false
+	}
+	m.lowReported = true
+	return ThrashEvent{
+		Kind:         ThrashLowMemory,
+		Timestamp:    now,
+		Since:        m.lowSince,
+		MajFaultRate: m.lastRates[0],
+		SwapInRate:   m.lastRates[1],
+		SwapOutRate:  m.lastRates[2],
+		Mem:          mem,
+		TopMem:       m.readTopMem(),
+	}, true
+}
+
+func counterRate(start, end uint64, seconds float64) float64 {
+	if end < start || seconds <= 0 {
+		return 0
+	}
+	return float64(end-start) / seconds
+}
+
+// topFaulters returns the n processes with the most major faults between
+// two snapshots. Processes that started in between count from zero; a PID
+// reused by a different command is skipped.
+func topFaulters(before, after map[int]procFault, seconds float64, n int) []ProcFaults {
+	var out []ProcFaults
+	for pid, a := range after {
+		var base uint64
+		if b, ok := before[pid]; ok {
+			if b.name != a.name || b.majflt > a.majflt {
+				continue
+			}
+			base = b.majflt
+		}
+		if d := a.majflt - base; d > 0 {
+			out = append(out, ProcFaults{PID: pid, Name: a.name, Rate: float64(d) / seconds})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rate != out[j].Rate {
+			return out[i].Rate > out[j].Rate
+		}
+		return out[i].PID < out[j].PID
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// ReadVMStat parses the paging counters from /proc/vmstat (or a test file).
+func ReadVMStat(path string) (VMStat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return VMStat{}, err
+	}
+	var vs VMStat
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "pgmajfault":
+			vs.PgMajFault = n
+		case "pswpin":
+			vs.PswpIn = n
+		case "pswpout":
+			vs.PswpOut = n
+		}
+	}
+	return vs, scanner.Err()
+}
+
+// ReadMemInfo parses the memory and swap totals from /proc/meminfo (or a
+// test file). A kernel too old for MemAvailable has it estimated as
+// MemFree plus Cached.
+func ReadMemInfo(path string) (MemInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MemInfo{}, err
+	}
+	var mi MemInfo
+	var free, cached int64
+	haveAvailable := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(firstField(rest), 10, 64)
+		if err != nil {
+			continue
+		}
+		v := kb * 1024
+		switch key {
+		case "MemTotal":
+			mi.MemTotal = v
+		case "MemAvailable":
+			mi.MemAvailable, haveAvailable = v, true
+		case "MemFree":
+			free = v
+		case "Cached":
+			cached = v
+		case "SwapTotal":
+			mi.SwapTotal = v
+		case "SwapFree":
+			mi.SwapFree = v
+		}
+	}
+	if !haveAvailable {
+		mi.MemAvailable = free + cached
+	}
+	return mi, scanner.Err()
+}
+
+// readProcFaults reads every process's cumulative major fault count.
+func readProcFaults(root string) map[int]procFault {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	procs := make(map[int]procFault)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // not a PID directory
+		}
+		data, err := os.ReadFile(filepath.Join(root, entry.Name(), "stat"))
+		if err != nil {
+			continue // process may have exited
+		}
+		if name, majflt, ok := parseProcStatMajflt(data); ok {
+			procs[pid] = procFault{name: name, majflt: majflt}
+		}
+	}
+	return procs
+}
+
+// parseProcStatMajflt extracts the command name and majflt (field 12) from
+// /proc/<pid>/stat. The name is in parentheses and may itself contain
+// spaces and parentheses, so fields are counted from the last ')'.
+//
+//	4521 (Web Content) S 4400 ... minflt cminflt majflt cmajflt ...
+func parseProcStatMajflt(data []byte) (name string, majflt uint64, ok bool) {
+	s := string(data)
+	open := strings.IndexByte(s, '(')
+	end := strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return "", 0, false
+	}
+	// Fields after the name start at field 3 (state); majflt is field 12.
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 10 {
+		return "", 0, false
+	}
+	majflt, err := strconv.ParseUint(fields[9], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return s[open+1 : end], majflt, true
+}
+
+// FormatThrash returns a human-readable description of a thrash event.
+func FormatThrash(ev ThrashEvent) string {
+	var b strings.Builder
+	if ev.Kind == ThrashLowMemory {
+		fmt.Fprintf(&b, "Available memory: %s of %s (%.1f%%) since %s\n", format.Bytes(ev.Mem.MemAvailable),
+			format.Bytes(ev.Mem.MemTotal), ev.Mem.AvailablePct(), ev.Since.Local().Format("15:04:05"))
+		fmt.Fprintf(&b, "Major page faults: %.0f/s\n", ev.MajFaultRate)
+	} else {
+		fmt.Fprintf(&b, "Major page faults: %.0f/s since %s\n", ev.MajFaultRate, ev.Since.Local().Format("15:04:05"))
+		if ev.Mem.MemTotal > 0 {
+			fmt.Fprintf(&b, "Available memory: %s of %s (%.1f%%)\n", format.Bytes(ev.Mem.MemAvailable),
+				format.Bytes(ev.Mem.MemTotal), ev.Mem.AvailablePct())
+		}
+	}
+	fmt.Fprintf(&b, "Swap: %.0f pages/s in, %.0f pages/s out\n", ev.SwapInRate, ev.SwapOutRate)
+	if ev.Mem.SwapTotal > 0 {
+		fmt.Fprintf(&b, "Swap free: %s of %s\n", format.Bytes(ev.Mem.SwapFree), format.Bytes(ev.Mem.SwapTotal))
+	}
+	if len(ev.TopMem) > 0 {
+		b.WriteString("\nTop memory consumers:\n")
+		b.WriteString(FormatTopConsumers(ev.TopMem))
+	}
+	if len(ev.Top) > 0 {
+		b.WriteString("\nProcesses faulting the most:\n")
+		for i, p := range ev.Top {
+			fmt.Fprintf(&b, "  %d. %-20s %8.0f faults/s (pid %d)\n", i+1, p.Name, p.Rate, p.PID)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadVMStat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vmstat")
+	data := "nr_free_pages 12345\npswpin 800\npswpout 1200\npgfault 99999\npgmajfault 4567\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vs, err := ReadVMStat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs != (VMStat{PgMajFault: 4567, PswpIn: 800, PswpOut: 1200}) {
+		t.Errorf("vmstat = %+v", vs)
+	}
+}
+
+func TestParseProcStatMajflt(t *testing.T) {
+	stat := "4521 (Web Content (x)) S 4400 4400 4400 0 -1 4194560 183202 0 7731 0 9001 2275 0 0 20 0 32 0"
+	name, majflt, ok := parseProcStatMajflt([]byte(stat))
+	if !ok || name != "Web Content (x)" || majflt != 7731 {
+		t.Errorf("got %q %d %v", name, majflt, ok)
+	}
+	if _, _, ok := parseProcStatMajflt([]byte("garbage")); ok {
+		t.Error("garbage parsed")
+	}
+}
+
+func TestThrashMonitorCheck(t *testing.T) {
+	m := NewThrashMonitor(5*time.Second, ThrashThresholds{MajFaultRate: 100, Sustain: 15 * time.Second})
+
+	var vs VMStat
+	m.readMemInfo = func() (MemInfo, error) { return MemInfo{MemTotal: 8 << 30, MemAvailable: 1 << 30}, nil }
+	procs := map[int]procFault{10: {"firefox", 1000}, 20: {"sshd", 5}}
+	m.readVMStat = func() (VMStat, error) { return vs, nil }
+	m.readProcs = func() map[int]procFault {
+		out := make(map[int]procFault, len(procs))
+		for pid, p := range procs {
+			out[pid] = p
+		}
+		return out
+	}
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	step := func(i int, faults uint64) (ThrashEvent, bool) {
+		vs.PgMajFault += faults
+		vs.PswpIn += faults / 2
+		return m.check(start.Add(time.Duration(i) * 5 * time.Second))
+	}
+
+	if _, ok := step(0, 0); ok {
+		t.Fatal("first sample reported")
+	}
+	if _, ok := step(1, 100); ok { // 20/s
+		t.Fatal("normal rate reported")
+	}
+	// 2000/s from here on; firefox does most of it. The episode counts from
+	// the previous sample, so it is sustained on the fourth.
+	for i := 2; i <= 3; i++ {
+		procs[10] = procFault{"firefox", procs[10].majflt + 9000}
+		procs[30] = procFault{"electron", procs[30].majflt + 500}
+		if _, ok := step(i, 10000); ok {
+			t.Fatalf("step %d: reported before the sustain period", i)
+		}
+	}
+	procs[10] = procFault{"firefox", procs[10].majflt + 9000}
+	ev, ok := step(4, 10000)
+	if !ok {
+		t.Fatal("sustained thrashing not reported")
+	}
+	if ev.Kind != ThrashPaging || ev.Mem.MemAvailable != 1<<30 {
+		t.Errorf("kind = %q, mem = %+v", ev.Kind, ev.Mem)
+	}
+	if ev.MajFaultRate < 1900 || ev.MajFaultRate > 2100 || ev.SwapInRate == 0 {
+		t.Errorf("rates = %+v", ev)
+	}
+	if ev.Since != start.Add(5*time.Second) {
+		t.Errorf("since = %v", ev.Since)
+	}
+	if len(ev.Top) != 2 || ev.Top[0].Name != "firefox" || ev.Top[1].Name != "electron" {
+		t.Errorf("top = %+v", ev.Top)
+	}
+
+	if _, ok := step(5, 10000); ok {
+		t.Error("same episode reported twice")
+	}
+	step(6, 10) // back to normal ends the episode
+	for i := 7; i <= 8; i++ {
+		if _, ok := step(i, 10000); ok {
+			t.Fatalf("step %d: new episode reported early", i)
+		}
+	}
+	if _, ok := step(9, 10000); !ok {
+		t.Error("new episode not reported")
+	}
+}
+
+func TestThrashMonitorSetThresholds(t *testing.T) {
+	m := NewThrashMonitor(5*time.Second, ThrashThresholds{MajFaultRate: 1000, Sustain: 15 * time.Second})
+	var vs VMStat
+	m.readVMStat = func() (VMStat, error) { return vs, nil }
+	m.readMemInfo = func() (MemInfo, error) { return MemInfo{}, nil }
+	m.readProcs = func() map[int]procFault { return nil }
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	step := func(i int) (ThrashEvent, bool) {
+		vs.PgMajFault += 2500 // 500/s
+		return m.check(start.Add(time.Duration(i) * 5 * time.Second))
+	}
+	for i := range 5 {
+		if _, ok := step(i); ok {
+			t.Fatalf("step %d: below the threshold but reported", i)
+		}
+	}
+
+	// The episode counts from the previous sample, so it is sustained at
+	// once.
+	m.SetThresholds(ThrashThresholds{MajFaultRate: 100, Sustain: 5 * time.Second})
+	if _, ok := step(5); !ok {
+		t.Error("not reported after lowering the threshold")
+	}
+}
+
+func TestThrashMonitorSwapRate(t *testing.T) {
+	m := NewThrashMonitor(5*time.Second, ThrashThresholds{MajFaultRate: 1000, SwapRate: 400, Sustain: 5 * time.Second})
+	var vs VMStat
+	m.readVMStat = func() (VMStat, error) { return vs, nil }
+	m.readMemInfo = func() (MemInfo, error) { return MemInfo{}, nil }
+	m.readProcs = func() map[int]procFault { return nil }
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.check(start)
+	// Few major faults, but 500 pages/s swapped out.
+	vs = VMStat{PgMajFault: 50, PswpOut: 2500}
+	ev, ok := m.check(start.Add(5 * time.Second))
+	if !ok || ev.SwapOutRate != 500 {
+		t.Errorf("swap-out thrashing: %+v, %v", ev, ok)
+	}
+}
+
+func TestReadMemInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meminfo")
+	data := "MemTotal:        8000000 kB\nMemFree:          100000 kB\nMemAvailable:     400000 kB\n" +
+		"Cached:           250000 kB\nSwapTotal:       2000000 kB\nSwapFree:          50000 kB\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mi, err := ReadMemInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MemInfo{MemTotal: 8000000 << 10, MemAvailable: 400000 << 10, SwapTotal: 2000000 << 10, SwapFree: 50000 << 10}
+	if mi != want {
+		t.Errorf("meminfo = %+v, want %+v", mi, want)
+	}
+	if pct := mi.AvailablePct(); pct != 5 {
+		t.Errorf("AvailablePct = %g, want 5", pct)
+	}
+
+	// Kernels before 3.14 have no MemAvailable.
+	data = "MemTotal:        8000000 kB\nMemFree:          100000 kB\nCached:           250000 kB\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if mi, _ := ReadMemInfo(path); mi.MemAvailable != 350000<<10 {
+		t.Errorf("estimated MemAvailable = %d", mi.MemAvailable)
+	}
+}
+
+func TestThrashMonitorCheckMemory(t *testing.T) {
+	m := NewThrashMonitor(5*time.Second, ThrashThresholds{MajFaultRate: 100, MemAvailablePct: 5, Sustain: 10 * time.Second})
+	mem := MemInfo{MemTotal: 1000, MemAvailable: 200}
+	m.readMemInfo = func() (MemInfo, error) { return mem, nil }
+	m.readTopMem = func() []ProcMem { return []ProcMem{{PID: 10, Name: "firefox", RSSBytes: 700}} }
+	m.lastRates = [3]float64{80, 40, 60}
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	step := func(i int, available int64) (ThrashEvent, bool) {
+		mem.MemAvailable = available
+		return m.checkMemory(start.Add(time.Duration(i) * 5 * time.Second))
+	}
+
+	if _, ok := step(0, 200); ok {
+		t.Fatal("reported with 20% available")
+	}
+	for i := 1; i <= 2; i++ {
+		if _, ok := step(i, 40); ok {
+			t.Fatalf("step %d: reported before the sustain period", i)
+		}
+	}
+	ev, ok := step(3, 30)
+	if !ok {
+		t.Fatal("sustained low memory not reported")
+	}
+	if ev.Kind != ThrashLowMemory || ev.Since != start.Add(5*time.Second) || ev.Mem.MemAvailable != 30 {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.MajFaultRate != 80 || ev.SwapOutRate != 60 || len(ev.TopMem) != 1 {
+		t.Errorf("rates = %+v, top = %+v", ev, ev.TopMem)
+	}
+	if _, ok := step(4, 30); ok {
+		t.Error("same episode reported twice")
+	}
+
+	step(5, 100) // back above the threshold ends the episode
+	m.SetThresholds(ThrashThresholds{MajFaultRate: 100})
+	for i := 6; i <= 9; i++ {
+		if _, ok := step(i, 10); ok {
+			t.Fatalf("step %d: reported with the check disabled", i)
+		}
+	}
+}
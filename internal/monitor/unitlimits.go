@@ -0,0 +1,346 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// Roots of the proc and cgroup filesystems, variables so tests can point
+// them at fixtures.
+var (
+	procRoot   = "/proc"
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// UnitUsage is a unit's file descriptor and task usage against its limits.
+// A limit of 0 means unlimited or unknown.
+type UnitUsage struct {
+	Unit     string
+	Tasks    uint64 // TasksCurrent: processes and threads in the unit's cgroup
+	TasksMax uint64
+
+	// The process whose open file descriptors are closest to its own
+	// RLIMIT_NOFILE (LimitNOFILE for the unit's processes).
+	FDPID   int
+	FDComm  string
+	FDs     uint64
+	FDLimit uint64
+}
+
+// FDPct returns the fd usage of the worst process as a percentage of its
+// soft limit, or 0 when unknown.
+func (u UnitUsage) FDPct() float64 {
+	if u.FDLimit == 0 {
+		return 0
+	}
+	return float64(u.FDs) / float64(u.FDLimit) * 100
+}
+
+// TasksPct returns the task count as a percentage of TasksMax, or 0 when
+// unlimited.
+func (u UnitUsage) TasksPct() float64 {
+	if u.TasksMax == 0 {
+		return 0
+	}
+	return float64(u.Tasks) / float64(u.TasksMax) * 100
+}
+
+// UnitLimitEvent is emitted when a unit's usage level rises, and with level
+// DiskOK when it has recovered.
+type UnitLimitEvent struct {
+	Timestamp time.Time
+	Usage     UnitUsage
+	Level     DiskLevel // same warning/critical scale as disk space
+	Resource  string    // "fds" or "tasks", whichever is worse
+	Percent   float64   // usage of that resource
+}
+
+// UnitLimitMonitor polls the configured systemd units and emits events when
+// their open file descriptors approach LimitNOFILE or their task count
+// approaches TasksMax. fd leaks usually only show up once the service has
+// already failed; this reports them while there is still headroom.
+type UnitLimitMonitor struct {
+	liveness
+
+	pollInterval time.Duration
+	units        []string   // unit names or globs
+	mu           sync.Mutex // guards thresholds, which SetThresholds changes
+	thresholds   DiskThresholds
+	lastLevel    map[string]DiskLevel
+}
+
+// NewUnitLimitMonitor creates a unit limit monitor. units are unit names or
+// globs such as "postgresql*.service"; globs match active units. Only the
+// WarnPct and CritPct thresholds are used, for both resources.
+func NewUnitLimitMonitor(pollInterval time.Duration, units []string, th DiskThresholds) *UnitLimitMonitor {
+	return &UnitLimitMonitor{
+		pollInterval: pollInterval,
+		units:        units,
+		thresholds:   th,
+		lastLevel:    make(map[string]DiskLevel),
+	}
+}
+
+// SetThresholds replaces the thresholds of a running monitor, e.g. on a
+// config reload.
+func (m *UnitLimitMonitor) SetThresholds(th DiskThresholds) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thresholds = th
+}
+
+// Events starts the polling loop and returns a channel of unit limit events.
+func (m *UnitLimitMonitor) Events(ctx context.Context) <-chan UnitLimitEvent {
+	ch := make(chan UnitLimitEvent, 8)
+	go m.poll(ctx, ch)
+	return ch
+}
+
+func (m *UnitLimitMonitor) poll(ctx context.Context, ch chan<- UnitLimitEvent) {
+	defer close(ch)
+
+	// Initial poll.
+	m.checkAll(ctx, ch)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx, ch)
+		}
+	}
+}
+
+func (m *UnitLimitMonitor) checkAll(ctx context.Context, ch chan<- UnitLimitEvent) {
+	defer m.markPoll()
+
+	units, err := expandUnits(ctx, m.units)
+	if err != nil {
+		slog.Debug("listing units failed", "error", err)
+		return
+	}
+	m.mu.Lock()
+	th := m.thresholds
+	m.mu.Unlock()
+
+	for _, unit := range units {
+		u, err := ReadUnitUsage(ctx, unit)
+		if err != nil {
+			slog.Debug("reading unit usage failed", "unit", unit, "error", err)
+			continue
+		}
+
+		level, resource, pct := EvaluateUnitLimits(u, th)
+		prev := m.lastLevel[unit]
+		m.lastLevel[unit] = level
+
+		// Emit when the level rises, and once when it is back to ok.
+		recovered := level == DiskOK && prev > DiskOK
+		if level <= prev && !recovered {
+			continue
+		}
+
+		select {
+		case ch <- UnitLimitEvent{
+			Timestamp: time.Now(),
+			Usage:     u,
+			Level:     level,
+			Resource:  resource,
+			Percent:   pct,
+		}:
+		case <-ctx.Done():
+			return
+		default:
+			selfstat.Drop(1)
+		}
+	}
+}
+
+// EvaluateUnitLimits returns the level for a unit along with the resource
+// ("fds" or "tasks") and percentage that determined it.
+func EvaluateUnitLimits(u UnitUsage, th DiskThresholds) (DiskLevel, string, float64) {
+	fdPct := u.FDPct()
+	tasksPct := u.TasksPct()
+
+	fdLevel := diskLevel(fdPct, th.WarnPct, th.CritPct)
+	tasksLevel := diskLevel(tasksPct, th.WarnPct, th.CritPct)
+
+	if tasksLevel > fdLevel {
+		return tasksLevel, "tasks", tasksPct
+	}
+	return fdLevel, "fds", fdPct
+}
+
+// expandUnits resolves globs among the configured units to the matching
+// active units. Plain names are kept as they are.
+func expandUnits(ctx context.Context, units []string) ([]string, error) {
+	var names, patterns []string
+	for _, u := range units {
+		if strings.ContainsAny(u, "*?[") {
+			patterns = append(patterns, u)
+		} else {
+			names = append(names, u)
+		}
+	}
+	if len(patterns) == 0 {
+		return names, nil
+	}
+
+	args := append([]string{"list-units", "--plain", "--no-legend", "--state=active", "--"}, patterns...)
+	out, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		return names, fmt.Errorf("systemctl list-units: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names, nil
+}
+
+// ReadUnitUsage reads a unit's task count from systemd and the open file
+// descriptors of each of its processes from /proc.
+func ReadUnitUsage(ctx context.Context, unit string) (UnitUsage, error) {
+	out, err := exec.CommandContext(ctx, "systemctl", "show",
+		"-p", "MainPID,ControlGroup,TasksCurrent,TasksMax", unit).Output()
+	if err != nil {
+		return UnitUsage{}, fmt.Errorf("systemctl show: %w", err)
+	}
+	props := parseSystemctlShow(out)
+
+	u := UnitUsage{
+		Unit:     unit,
+		Tasks:    parseSystemdCount(props["TasksCurrent"]),
+		TasksMax: parseSystemdCount(props["TasksMax"]),
+	}
+
+	pids := cgroupPIDs(props["ControlGroup"])
+	if len(pids) == 0 {
+		if pid, _ := strconv.Atoi(props["MainPID"]); pid > 0 {
+			pids = []int{pid}
+		}
+	}
+	for _, pid := range pids {
+		fds, limit, err := processFDUsage(pid)
+		if err != nil || limit == 0 {
+			continue
+		}
+		if u.FDLimit == 0 || float64(fds)/float64(limit) > u.FDPct()/100 {
+			u.FDPID, u.FDs, u.FDLimit = pid, fds, limit
+		}
+	}
+	if u.FDPID > 0 {
+		if comm, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(u.FDPID), "comm")); err == nil {
+			u.FDComm = strings.TrimSpace(string(comm))
+		}
+	}
+	return u, nil
+}
+
+// parseSystemctlShow parses "Key=value" lines from systemctl show.
+func parseSystemctlShow(data []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[k] = v
+		}
+	}
+	return props
+}
+
+// parseSystemdCount parses a systemd counter or limit. "infinity", the
+// all-ones "unset" value, and "[not set]" all read as 0.
+func parseSystemdCount(s string) uint64 {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n == ^uint64(0) {
+		return 0
+	}
+	return n
+}
+
+// cgroupPIDs returns the processes in a unit's cgroup (cgroup v2), or nil
+// if the cgroup cannot be read.
+func cgroupPIDs(cgroup string) []int {
+	if cgroup == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(cgroupRoot, cgroup, "cgroup.procs"))
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, f := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(f); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// processFDUsage returns the number of open file descriptors of a process
+// and its soft RLIMIT_NOFILE. Reading another user's fd table needs root.
+func processFDUsage(pid int) (fds, limit uint64, err error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	entries, err := os.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return 0, 0, err
+	}
+	limits, err := os.ReadFile(filepath.Join(dir, "limits"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(len(entries)), parseNOFILE(limits), nil
+}
+
+// parseNOFILE returns the soft limit from the "Max open files" line of
+// /proc/<pid>/limits, or 0 if it is unlimited or missing.
+//
+//	Max open files            1024                 524288               files
+func parseNOFILE(limits []byte) uint64 {
+	for _, line := range strings.Split(string(limits), "\n") {
+		rest, ok := strings.CutPrefix(line, "Max open files")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0
+		}
+		return parseSystemdCount(fields[0])
+	}
+	return 0
+}
+
+// FormatUnitUsage returns a human-readable description of a unit's usage.
+func FormatUnitUsage(u UnitUsage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Unit: %s\n", u.Unit)
+	if u.FDLimit > 0 {
+		fmt.Fprintf(&b, "  Open files: %d of %d (%.1f%%) in %s (pid %d)\n",
+			u.FDs, u.FDLimit, u.FDPct(), u.FDComm, u.FDPID)
+	}
+	if u.TasksMax > 0 {
+		fmt.Fprintf(&b, "  Tasks:      %d of %d (%.1f%%)\n", u.Tasks, u.TasksMax, u.TasksPct())
+	} else {
+		fmt.Fprintf(&b, "  Tasks:      %d (no limit)\n", u.Tasks)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseSystemctlShow(t *testing.T) {
+	props := parseSystemctlShow([]byte("MainPID=812\nControlGroup=/system.slice/nginx.service\nTasksCurrent=5\nTasksMax=infinity\n"))
+	if props["MainPID"] != "812" || props["ControlGroup"] != "/system.slice/nginx.service" {
+		t.Errorf("props = %v", props)
+	}
+	if n := parseSystemdCount(props["TasksCurrent"]); n != 5 {
+		t.Errorf("TasksCurrent = %d", n)
+	}
+	for _, s := range []string{"infinity", "18446744073709551615", "[not set]", ""} {
+		if n := parseSystemdCount(s); n != 0 {
+			t.Errorf("parseSystemdCount(%q) = %d, want 0", s, n)
+		}
+	}
+}
+
+func TestParseNOFILE(t *testing.T) {
+	limits := []byte(`Limit                     Soft Limit           Hard Limit           Units
+Max processes             63441                63441                processes
+Max open files            1024                 524288               files
+Max locked memory         8388608              8388608              bytes
+`)
+	if n := parseNOFILE(limits); n != 1024 {
+		t.Errorf("parseNOFILE = %d, want 1024", n)
+	}
+	if n := parseNOFILE([]byte("Max open files            unlimited            unlimited            files\n")); n != 0 {
+		t.Errorf("unlimited = %d, want 0", n)
+	}
+}
+
+func TestEvaluateUnitLimits(t *testing.T) {
+	th := DiskThresholds{WarnPct: 80, CritPct: 95}
+	tests := []struct {
+		name     string
+		usage    UnitUsage
+		level    DiskLevel
+		resource string
+	}{
+		{"ok", UnitUsage{FDs: 100, FDLimit: 1024, Tasks: 10, TasksMax: 100}, DiskOK, "fds"},
+		{"fd leak", UnitUsage{FDs: 900, FDLimit: 1024, Tasks: 10, TasksMax: 100}, DiskWarning, "fds"},
+		{"tasks worse", UnitUsage{FDs: 900, FDLimit: 1024, Tasks: 97, TasksMax: 100}, DiskCritical, "tasks"},
+		{"no limits", UnitUsage{FDs: 900, Tasks: 10000}, DiskOK, "fds"},
+	}
+	for _, tt := range tests {
+		level, resource, _ := EvaluateUnitLimits(tt.usage, th)
+		if level != tt.level || resource != tt.resource {
+			t.Errorf("%s: got %s/%s, want %s/%s", tt.name, level, resource, tt.level, tt.resource)
+		}
+	}
+}
+
+func TestProcessFDUsage(t *testing.T) {
+	root := t.TempDir()
+	procRoot = root
+	t.Cleanup(func() { procRoot = "/proc" })
+
+	dir := filepath.Join(root, strconv.Itoa(4242))
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(filepath.Join(dir, "fd", strconv.Itoa(i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "limits"), []byte("Max open files            4                    4096                 files\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fds, limit, err := processFDUsage(4242)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fds != 3 || limit != 4 {
+		t.Errorf("fds = %d, limit = %d; want 3, 4", fds, limit)
+	}
+	if _, _, err := processFDUsage(1); err == nil {
+		t.Error("missing process should be an error")
+	}
+}
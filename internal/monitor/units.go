@@ -0,0 +1,292 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+const (
+	systemdDest      = "org.freedesktop.systemd1"
+	systemdPath      = "/org/freedesktop/systemd1"
+	systemdUnitPath  = "/org/freedesktop/systemd1/unit"
+	systemdUnitIface = "org.freedesktop.systemd1.Unit"
+	systemdSvcIface  = "org.freedesktop.systemd1.Service"
+
+	// unitReconnectWait is the pause before reconnecting after the system
+	// bus connection is lost.
+	unitReconnectWait = 10 * time.Second
+)
+
+// UnitState is a unit's state as systemd reports it over D-Bus.
+type UnitState struct {
+	Unit           string
+	ActiveState    string // e.g. "active", "failed"
+	SubState       string // e.g. "running", "failed", "auto-restart"
+	Result         string // service result, e.g. "exit-code", "signal", "oom-kill"
+	NRestarts      uint32 // automatic restarts since the unit was last started manually
+	ExecMainCode   int32  // CLD_* code of the main process: 1 exited, 2 killed, 3 dumped
+	ExecMainStatus int32  // exit status or signal number of the main process
+}
+
+// UnitEvent is emitted when a unit enters the failed state, or when a failed
+// unit becomes active again.
+type UnitEvent struct {
+	Timestamp time.Time
+	State     UnitState
+	Recovered bool // the unit is active again after failing
+}
+
+// UnitMonitor subscribes to systemd's PropertiesChanged signals on the
+// system bus and emits an event each time a matching unit enters the
+// failed state, and again once it is back up. Unlike journal text matching, this does not depend on how
+// a given systemd version phrases its messages.
+type UnitMonitor struct {
+	match  []string // unit name globs to watch; empty means all
+	ignore []string // unit name globs to skip
+
+	mu        sync.Mutex
+	lastState map[string]string // unit -> last ActiveState seen
+	failed    map[string]bool   // units that failed and have not been active since
+	connected atomic.Bool
+}
+
+// NewUnitMonitor creates a unit monitor. match and ignore are glob patterns
+// on unit names such as "*.service" or "backup-*.timer".
+func NewUnitMonitor(match, ignore []string) *UnitMonitor {
+	return &UnitMonitor{
+		match:     match,
+		ignore:    ignore,
+		lastState: make(map[string]string),
+		failed:    make(map[string]bool),
+	}
+}
+
+// Connected reports whether the monitor currently holds a subscribed system
+// bus connection.
+func (m *UnitMonitor) Connected() bool {
+	return m.connected.Load()
+}
+
+// Events connects to the system bus and returns a channel of unit failure
+// and recovery events. The connection is retried in the background if it drops.
+func (m *UnitMonitor) Events(ctx context.Context) <-chan UnitEvent {
+	ch := make(chan UnitEvent, 16)
+	go m.run(ctx, ch)
+	return ch
+}
+
+func (m *UnitMonitor) run(ctx context.Context, ch chan<- UnitEvent) {
+	defer close(ch)
+
+	for {
+		if err := m.watch(ctx, ch); err != nil {
+			slog.Warn("systemd D-Bus unit watch failed", "error", err)
+		}
+		m.connected.Store(false)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(unitReconnectWait):
+		}
+	}
+}
+
+// watch subscribes and forwards failures until ctx is cancelled or the
+// connection drops.
+func (m *UnitMonitor) watch(ctx context.Context, ch chan<- UnitEvent) error {
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("connecting to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	// Without Subscribe, systemd only emits signals for units that some
+	// client already asked about.
+	if err := conn.Object(systemdDest, systemdPath).CallWithContext(ctx,
+		"org.freedesktop.systemd1.Manager.Subscribe", 0).Err; err != nil {
+		return fmt.Errorf("subscribing to systemd: %w", err)
+	}
+	if err := conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchPathNamespace(systemdUnitPath),
+		dbus.WithMatchArg(0, systemdUnitIface),
+	); err != nil {
+		return fmt.Errorf("adding signal match: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+	m.connected.Store(true)
+	slog.Debug("subscribed to systemd unit state changes")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("system bus connection closed")
+			}
+			m.handleSignal(ctx, conn, sig, ch)
+		}
+	}
+}
+
+func (m *UnitMonitor) handleSignal(ctx context.Context, conn *dbus.Conn, sig *dbus.Signal, ch chan<- UnitEvent) {
+	if len(sig.Body) < 2 {
+		return
+	}
+	changed, ok := sig.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return
+	}
+	active, ok := changed["ActiveState"].Value().(string)
+	if !ok {
+		return
+	}
+
+	unit := UnitNameFromPath(string(sig.Path))
+	if !m.watches(unit) {
+		return
+	}
+	failed, recovered := m.observe(unit, active)
+	if !failed && !recovered {
+		return
+	}
+
+	state := UnitState{Unit: unit, ActiveState: active}
+	if sub, ok := changed["SubState"].Value().(string); ok {
+		state.SubState = sub
+	}
+	if failed {
+		m.readServiceState(ctx, conn, sig.Path, &state)
+	}
+
+	select {
+	case ch <- UnitEvent{Timestamp: time.Now(), State: state, Recovered: recovered}:
+	case <-ctx.Done():
+	default:
+		selfstat.Drop(1)
+	}
+}
+
+// readServiceState fills in the service-specific failure details. Non-service
+// units (mounts, timers) do not have them and are left as they are.
+func (m *UnitMonitor) readServiceState(ctx context.Context, conn *dbus.Conn, unitPath dbus.ObjectPath, state *UnitState) {
+	if !strings.HasSuffix(state.Unit, ".service") {
+		return
+	}
+	var props map[string]dbus.Variant
+	err := conn.Object(systemdDest, unitPath).CallWithContext(ctx,
+		"org.freedesktop.DBus.Properties.GetAll", 0, systemdSvcIface).Store(&props)
+	if err != nil {
+		slog.Debug("reading service properties failed", "unit", state.Unit, "error", err)
+		return
+	}
+	if v, ok := props["Result"].Value().(string); ok {
+		state.Result = v
+	}
+	if v, ok := props["NRestarts"].Value().(uint32); ok {
+		state.NRestarts = v
+	}
+	if v, ok := props["ExecMainCode"].Value().(int32); ok {
+		state.ExecMainCode = v
+	}
+	if v, ok := props["ExecMainStatus"].Value().(int32); ok {
+		state.ExecMainStatus = v
+	}
+}
+
+// observe records a unit's ActiveState and reports whether it just entered
+// the failed state, or became active after failing. A unit restarting
+// automatically never enters the failed state, so a crash loop does not
+// read as a series of recoveries.
+func (m *UnitMonitor) observe(unit, active string) (failed, recovered bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.lastState[unit]
+	m.lastState[unit] = active
+	switch active {
+	case "failed":
+		m.failed[unit] = true
+		return prev != "failed", false
+	case "active":
+		recovered = m.failed[unit]
+		delete(m.failed, unit)
+		return false, recovered
+	}
+	return false, false
+}
+
+// watches reports whether a unit is selected by the match and ignore globs.
+func (m *UnitMonitor) watches(unit string) bool {
+	for _, pattern := range m.ignore {
+		if ok, _ := path.Match(pattern, unit); ok {
+			return false
+		}
+	}
+	if len(m.match) == 0 {
+		return true
+	}
+	for _, pattern := range m.match {
+		if ok, _ := path.Match(pattern, unit); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// UnitNameFromPath decodes a systemd unit object path such as
+// "/org/freedesktop/systemd1/unit/nginx_2eservice" into the unit name
+// ("nginx.service"). systemd escapes every byte outside [A-Za-z0-9] as
+// "_xx" in hex.
+func UnitNameFromPath(p string) string {
+	s := strings.TrimPrefix(p, systemdUnitPath+"/")
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FormatUnitState returns a human-readable description of a failed unit.
+func FormatUnitState(s UnitState) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Unit: %s\n", s.Unit)
+	fmt.Fprintf(&b, "State: %s (%s)\n", s.ActiveState, s.SubState)
+	if s.Result != "" {
+		fmt.Fprintf(&b, "Result: %s\n", s.Result)
+	}
+	switch s.ExecMainCode {
+	case 1:
+		fmt.Fprintf(&b, "Main process: exited with status %d\n", s.ExecMainStatus)
+	case 2:
+		fmt.Fprintf(&b, "Main process: killed by signal %d\n", s.ExecMainStatus)
+	case 3:
+		fmt.Fprintf(&b, "Main process: dumped core (signal %d)\n", s.ExecMainStatus)
+	}
+	if s.NRestarts > 0 {
+		fmt.Fprintf(&b, "Automatic restarts: %d\n", s.NRestarts)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnitNameFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/org/freedesktop/systemd1/unit/nginx_2eservice":                       "nginx.service",
+		"/org/freedesktop/systemd1/unit/user_401000_2eservice":                 "user@1000.service",
+		"/org/freedesktop/systemd1/unit/systemd_2dfsck_40dev_2dsda1_2eservice": "systemd-fsck@dev-sda1.service",
+		"/org/freedesktop/systemd1/unit/home_2emount":                          "home.mount",
+	}
+	for path, want := range tests {
+		if got := UnitNameFromPath(path); got != want {
+			t.Errorf("UnitNameFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestUnitMonitorObserve(t *testing.T) {
+	m := NewUnitMonitor(nil, nil)
+
+	steps := []struct {
+		state     string
+		emit      bool
+		recovered bool
+	}{
+		{"activating", false, false},
+		{"active", false, false}, // never failed
+		{"failed", true, false},
+		{"failed", false, false}, // repeated signal for the same failure
+		{"activating", false, false},
+		{"active", false, true},
+		{"active", false, false},
+		{"failed", true, false},
+		{"inactive", false, false}, // reset-failed
+		{"activating", false, false},
+		{"failed", true, false},
+		{"active", false, true},
+	}
+	for i, s := range steps {
+		emit, recovered := m.observe("nginx.service", s.state)
+		if emit != s.emit || recovered != s.recovered {
+			t.Errorf("step %d (%s): emit, recovered = %v, %v, want %v, %v",
+				i, s.state, emit, recovered, s.emit, s.recovered)
+		}
+	}
+	if emit, _ := m.observe("other.service", "failed"); !emit {
+		t.Error("first failure of another unit should emit")
+	}
+}
+
+func TestUnitMonitorWatches(t *testing.T) {
+	m := NewUnitMonitor([]string{"*.service", "backup-*.timer"}, []string{"user@*.service"})
+	tests := map[string]bool{
+		"nginx.service":     true,
+		"backup-home.timer": true,
+		"fstrim.timer":      false,
+		"user@1000.service": false,
+		"home.mount":        false,
+	}
+	for unit, want := range tests {
+		if got := m.watches(unit); got != want {
+			t.Errorf("watches(%q) = %v, want %v", unit, got, want)
+		}
+	}
+	if !NewUnitMonitor(nil, nil).watches("home.mount") {
+		t.Error("empty match list should watch every unit")
+	}
+}
+
+func TestFormatUnitState(t *testing.T) {
+	out := FormatUnitState(UnitState{
+		Unit:           "nginx.service",
+		ActiveState:    "failed",
+		SubState:       "failed",
+		Result:         "core-dump",
+		NRestarts:      4,
+		ExecMainCode:   3,
+		ExecMainStatus: 11,
+	})
+	for _, want := range []string{"Unit: nginx.service", "Result: core-dump", "dumped core (signal 11)", "Automatic restarts: 4"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package remediate runs the opt-in [[remediation.actions]], such as
+// restarting a failed unit, for the events they match. An action only runs
+// for allow-listed units and at most max_runs times per unit in its window;
+// what happened goes out with the event's notification.
+package remediate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+)
+
+// Defaults for an action that sets no command or rate limit. A user unit
+// is restarted in its owner's service manager.
+var (
+	defaultCommand     = []string{"systemctl", "restart", "{unit}"}
+	defaultUserCommand = []string{"systemctl", "--user", "--machine={uid}@", "restart", "{unit}"}
+)
+
+const (
+	defaultMaxRuns = 2
+	defaultPer     = time.Hour
+)
+
+// Result is what became of the action run for an event.
+type Result struct {
+	Action  string
+	Command []string
+	Limited bool  // the action had used up its runs, so it did not run
+	Err     error // nil if the command ran and exited 0
+	Output  string
+}
+
+// Summary describes the result in a line, e.g.
+// "auto-restart attempted: success".
+func (r Result) Summary() string {
+	switch {
+	case r.Limited:
+		return r.Action + " skipped: rate limit reached"
+	case r.Err != nil:
+		return r.Action + " attempted: failed: " + r.Err.Error()
+	default:
+		return r.Action + " attempted: success"
+	}
+}
+
+// action is a compiled [[remediation.actions]].
+type action struct {
+	name        string
+	tier        event.Tier
+	unit        *regexp.Regexp
+	command     []string
+	userCommand []string // for user units; nil if the action cannot act on them
+	maxRuns     int
+	per         time.Duration
+}
+
+// Remediator picks and runs the action for each event.
+type Remediator struct {
+	allowed []string
+	timeout time.Duration
+	actions []action
+
+	mu   sync.Mutex
+	runs map[string][]time.Time // action name and unit -> recent run times
+
+	// Overridable for testing.
+	now func() time.Time
+	run func(ctx context.Context, argv []string) ([]byte, error)
+}
+
+// New compiles the remediation config. Every invalid action is reported.
+func New(cfg config.RemediationConfig) (*Remediator, error) {
+	r := &Remediator{
+		allowed: cfg.AllowedUnits,
+		timeout: cfg.Timeout.Duration,
+		runs:    make(map[string][]time.Time),
+		now:     time.Now,
+		run:     runCommand,
+	}
+	var errs []error
+
+	for i, spec := range cfg.Actions {
+		a := action{
+			name:    spec.Name,
+			tier:    event.Tier(strings.ToUpper(spec.Tier)),
+			command: spec.Command,
+			maxRuns: spec.MaxRuns,
+			per:     spec.Per.Duration,
+		}
+		switch {
+		case len(a.command) == 0:
+			a.command, a.userCommand = defaultCommand, defaultUserCommand
+		case slices.ContainsFunc(a.command, func(arg string) bool { return strings.Contains(arg, "{uid}") }):
+			// Only a command that says whose manager to use can act
+			// on a user unit; any other would act on a system unit of
+			// the same name.
+			a.userCommand = a.command
+		}
+		if a.name == "" {
+			a.name = fmt.Sprintf("action #%d", i+1)
+			if len(spec.Command) == 0 {
+				a.name = "auto-restart"
+			}
+		}
+		if a.maxRuns == 0 {
+			a.maxRuns = defaultMaxRuns
+		}
+		if a.per == 0 {
+			a.per = defaultPer
+		}
+
+		var err error
+		if spec.Unit != "" {
+			if a.unit, err = regexp.Compile(spec.Unit); err != nil {
+				err = fmt.Errorf("invalid unit pattern: %w", err)
+			}
+		}
+		if err == nil && a.tier == "" && a.unit == nil {
+			err = errors.New("at least one of tier or unit is required")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("remediation action %s: %w", a.name, err))
+			continue
+		}
+		r.actions = append(r.actions, a)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Inherit takes over the run history of old, the remediator a reloaded
+// config replaces, so a reload does not reset the rate limits.
+func (r *Remediator) Inherit(old *Remediator) {
+	if old == nil {
+		return
+	}
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range old.runs {
+		r.runs[k] = v
+	}
+}
+
+// Run runs the first action matching ev, if its unit is allow-listed, and
+// reports whether one matched. It waits for the command, up to the
+// configured timeout.
+//
+// A user unit is acted on in the service manager of the user it belongs
+// to, whose uid replaces "{uid}" in the command. An action whose command
+// has no "{uid}" does not run for user units, nor does any for one whose
+// owner is unknown.
+func (r *Remediator) Run(ctx context.Context, ev *event.Event) (Result, bool) {
+	if ev.Unit == "" || !r.allows(ev.Unit) {
+		return Result{}, false
+	}
+	var a *action
+	for i := range r.actions {
+		if r.actions[i].matches(ev) {
+			a = &r.actions[i]
+			break
+		}
+	}
+	if a == nil {
+		return Result{}, false
+	}
+
+	command, uid, key := a.command, "", ev.Unit
+	if ev.RawFields["_user_unit"] != "" {
+		uid = cmp.Or(ev.RawFields["_SYSTEMD_OWNER_UID"], ev.RawFields["_UID"])
+		if a.userCommand == nil || uid == "" {
+			slog.Debug("remediation skipped for user unit",
+				"action", a.name, "unit", ev.Unit, "uid", uid)
+			return Result{}, false
+		}
+		command, key = a.userCommand, ev.Unit+"@"+uid
+	}
+
+	argv := make([]string, len(command))
+	for i, arg := range command {
+		argv[i] = strings.NewReplacer("{unit}", ev.Unit, "{uid}", uid).Replace(arg)
+	}
+	res := Result{Action: a.name, Command: argv}
+
+	if !r.take(a, key) {
+		res.Limited = true
+		slog.Warn("remediation rate limited",
+			"action", a.name, "unit", ev.Unit,
+			"max_runs", a.maxRuns, "per", format.Duration(a.per))
+		return res, true
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	out, err := r.run(runCtx, argv)
+	res.Output = strings.TrimSpace(string(out))
+	res.Err = err
+	if err != nil {
+		slog.Error("remediation failed",
+			"action", a.name, "unit", ev.Unit, "command", strings.Join(argv, " "),
+			"error", err, "output", res.Output)
+	} else {
+		slog.Info("remediation ran",
+			"action", a.name, "unit", ev.Unit, "command", strings.Join(argv, " "))
+	}
+	return res, true
+}
+
+// allows reports whether unit matches the allow-list.
+func (r *Remediator) allows(unit string) bool {
+	for _, pattern := range r.allowed {
+		if ok, _ := path.Match(pattern, unit); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// take records a run of a for unit, unless it has already run maxRuns
+// times within its window. A user unit is unit@uid.
+func (r *Remediator) take(a *action, unit string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := a.name + "\x00" + unit
+	now := r.now()
+	recent := r.runs[key][:0]
+	for _, t := range r.runs[key] {
+		if now.Sub(t) < a.per {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= a.maxRuns {
+		r.runs[key] = recent
+		return false
+	}
+	r.runs[key] = append(recent, now)
+	return true
+}
+
+func (a action) matches(ev *event.Event) bool {
+	if a.tier != "" && a.tier != ev.Tier {
+		return false
+	}
+	if a.unit != nil && !a.unit.MatchString(ev.Unit) {
+		return false
+	}
+	return true
+}
+
+// runCommand runs argv without a shell and returns its combined output. An
+// error includes the command's stderr, when it wrote any.
+func runCommand(ctx context.Context, argv []string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			if i := strings.IndexByte(msg, '\n'); i >= 0 {
+				msg = msg[:i]
+			}
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return out, err
+}
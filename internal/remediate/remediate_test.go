@@ -0,0 +1,178 @@
+package remediate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// testRemediator returns a Remediator that records the commands it runs
+// instead of running them.
+func testRemediator(t *testing.T, cfg config.RemediationConfig) (*Remediator, *[][]string) {
+	t.Helper()
+	cfg.Timeout = config.Duration{Duration: time.Second}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran [][]string
+	r.run = func(_ context.Context, argv []string) ([]byte, error) {
+		ran = append(ran, argv)
+		return nil, nil
+	}
+	return r, &ran
+}
+
+func failed(unit string) *event.Event {
+	ev := event.New("h", time.Now(), event.TierServiceFailure, event.SevHigh, unit+" failed")
+	ev.Unit = unit
+	return ev
+}
+
+func TestRunAllowList(t *testing.T) {
+	r, ran := testRemediator(t, config.RemediationConfig{
+		AllowedUnits: []string{"nginx.service", "myapp-*.service"},
+		Actions:      []config.RemediationAction{{Tier: "t3"}},
+	})
+
+	for _, unit := range []string{"nginx.service", "myapp-web.service"} {
+		res, ok := r.Run(context.Background(), failed(unit))
+		if !ok || res.Summary() != "auto-restart attempted: success" {
+			t.Errorf("%s: Run = %+v, %v", unit, res, ok)
+		}
+	}
+	for _, ev := range []*event.Event{failed("sshd.service"), failed("")} {
+		if res, ok := r.Run(context.Background(), ev); ok {
+			t.Errorf("%q: ran %+v for a unit not allow-listed", ev.Unit, res)
+		}
+	}
+	oom := failed("nginx.service")
+	oom.Tier = event.TierOOMKill
+	if _, ok := r.Run(context.Background(), oom); ok {
+		t.Error("ran for a T1 event")
+	}
+
+	want := [][]string{
+		{"systemctl", "restart", "nginx.service"},
+		{"systemctl", "restart", "myapp-web.service"},
+	}
+	if !slices.EqualFunc(*ran, want, slices.Equal) {
+		t.Errorf("ran %q, want %q", *ran, want)
+	}
+}
+
+func TestRunUserUnit(t *testing.T) {
+	r, ran := testRemediator(t, config.RemediationConfig{
+		AllowedUnits: []string{"*"},
+		Actions: []config.RemediationAction{
+			{Name: "reset", Unit: `^syncthing`, Command: []string{"/usr/local/bin/reset", "{unit}"}},
+			{Name: "reset-as", Unit: `^podman`, Command: []string{"/usr/local/bin/reset", "--uid={uid}", "{unit}"}},
+			{Tier: "T3"},
+		},
+	})
+	user := func(unit, uid string) *event.Event {
+		ev := failed(unit)
+		ev.RawFields = map[string]string{"_user_unit": "true", "_UID": uid}
+		return ev
+	}
+
+	for _, ev := range []*event.Event{user("pipewire.service", "1000"), user("podman.service", "1001")} {
+		if _, ok := r.Run(context.Background(), ev); !ok {
+			t.Errorf("%s: no action ran", ev.Unit)
+		}
+	}
+	// A command without {uid} would act on a system unit of the same
+	// name, and without an owner there is no manager to act in.
+	for _, ev := range []*event.Event{user("syncthing.service", "1000"), user("pipewire.service", "")} {
+		if res, ok := r.Run(context.Background(), ev); ok {
+			t.Errorf("%s: ran %+v for a user unit", ev.Unit, res)
+		}
+	}
+
+	want := [][]string{
+		{"systemctl", "--user", "--machine=1000@", "restart", "pipewire.service"},
+		{"/usr/local/bin/reset", "--uid=1001", "podman.service"},
+	}
+	if !slices.EqualFunc(*ran, want, slices.Equal) {
+		t.Errorf("ran %q, want %q", *ran, want)
+	}
+}
+
+func TestRunRateLimit(t *testing.T) {
+	r, ran := testRemediator(t, config.RemediationConfig{
+		AllowedUnits: []string{"*"},
+		Actions: []config.RemediationAction{{
+			Name:    "reset",
+			Unit:    `^nginx`,
+			Command: []string{"/usr/local/bin/reset", "--unit={unit}"},
+			MaxRuns: 2,
+			Per:     config.Duration{Duration: time.Hour},
+		}},
+	})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	for i := range 3 {
+		res, ok := r.Run(context.Background(), failed("nginx.service"))
+		if !ok || res.Limited != (i == 2) {
+			t.Fatalf("run %d: %+v, %v", i, res, ok)
+		}
+	}
+	res, _ := r.Run(context.Background(), failed("nginx.service"))
+	if got := res.Summary(); got != "reset skipped: rate limit reached" {
+		t.Errorf("Summary = %q", got)
+	}
+	if len(*ran) != 2 || (*ran)[0][1] != "--unit=nginx.service" {
+		t.Errorf("ran %q, want two resets of nginx.service", *ran)
+	}
+
+	// The window passes; a reload keeps the history.
+	now = now.Add(time.Hour)
+	next, _ := testRemediator(t, config.RemediationConfig{
+		AllowedUnits: []string{"*"},
+		Actions:      []config.RemediationAction{{Name: "reset", Unit: `^nginx`, MaxRuns: 2}},
+	})
+	next.now = r.now
+	next.Inherit(r)
+	if res, _ := next.Run(context.Background(), failed("nginx.service")); res.Limited {
+		t.Error("still limited after the window")
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	r, _ := testRemediator(t, config.RemediationConfig{
+		AllowedUnits: []string{"nginx.service"},
+		Actions:      []config.RemediationAction{{Unit: "nginx"}},
+	})
+	r.run = func(context.Context, []string) ([]byte, error) {
+		return []byte("Job failed\n"), errors.New("exit status 1")
+	}
+	res, ok := r.Run(context.Background(), failed("nginx.service"))
+	if !ok || res.Output != "Job failed" {
+		t.Fatalf("Run = %+v, %v", res, ok)
+	}
+	if got := res.Summary(); got != "auto-restart attempted: failed: exit status 1" {
+		t.Errorf("Summary = %q", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(config.RemediationConfig{Actions: []config.RemediationAction{
+		{Name: "bad", Unit: "("},
+		{Name: "empty"},
+	}})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"bad: invalid unit pattern", "empty: at least one of tier or unit"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package reporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Attempt is one delivery of a notification to a sink, as passed to the
+// function set with Multi.OnAttempt.
+type Attempt struct {
+	Sink    string
+	Events  []*event.Event // several for a merged message
+	At      time.Time
+	Latency time.Duration
+	Status  int // HTTP status the sink's server answered with; 0 if none
+	Err     error
+}
+
+// attemptKey carries the *attemptTrace of an audited delivery in its
+// context.
+type attemptKey struct{}
+
+// attemptTrace is what a sink notes about an audited delivery.
+type attemptTrace struct {
+	sent   bool
+	status int
+}
+
+// noteSend marks the delivery in ctx as attempted. Sinks call it before
+// contacting their server; a delivery that never does, such as one for a
+// tier the sink does not alert on, is not an attempt.
+func noteSend(ctx context.Context) {
+	if t, ok := ctx.Value(attemptKey{}).(*attemptTrace); ok {
+		t.sent = true
+	}
+}
+
+// noteStatus records the HTTP status a sink's server answered with.
+func noteStatus(ctx context.Context, code int) {
+	if t, ok := ctx.Value(attemptKey{}).(*attemptTrace); ok {
+		t.status = code
+	}
+}
+
+// auditor passes each delivery its sink attempts to record.
+type auditor struct {
+	inner  Reporter
+	record func(Attempt)
+}
+
+// batchAuditor is an auditor for a sink that can merge events, so it can
+// still be batched.
+type batchAuditor struct {
+	*auditor
+	batch BatchReporter
+}
+
+// audit wraps r so its delivery attempts are passed to record.
+func audit(r Reporter, record func(Attempt)) Reporter {
+	a := &auditor{inner: r, record: record}
+	if br, ok := r.(BatchReporter); ok {
+		return &batchAuditor{auditor: a, batch: br}
+	}
+	return a
+}
+
+func (a *auditor) Name() string {
+	return a.inner.Name()
+}
+
+func (a *auditor) Report(ctx context.Context, ev *event.Event) error {
+	return a.trace(ctx, []*event.Event{ev}, func(ctx context.Context) error {
+		return a.inner.Report(ctx, ev)
+	})
+}
+
+func (a *auditor) ReportTransition(ctx context.Context, t Transition, ev *event.Event) error {
+	return a.trace(ctx, []*event.Event{ev}, func(ctx context.Context) error {
+		return reportTransition(ctx, a.inner, t, ev)
+	})
+}
+
+func (a *batchAuditor) Accepts(ev *event.Event) bool {
+	return a.batch.Accepts(ev)
+}
+
+func (a *batchAuditor) ReportBatch(ctx context.Context, evs []*event.Event) error {
+	return a.trace(ctx, evs, func(ctx context.Context) error {
+		return a.batch.ReportBatch(ctx, evs)
+	})
+}
+
+// trace runs a delivery of evs and records it if the sink attempted it.
+func (a *auditor) trace(ctx context.Context, evs []*event.Event, deliver func(context.Context) error) error {
+	t := &attemptTrace{}
+	start := time.Now()
+	err := deliver(context.WithValue(ctx, attemptKey{}, t))
+	if t.sent || err != nil {
+		a.record(Attempt{
+			Sink:    a.inner.Name(),
+			Events:  evs,
+			At:      start,
+			Latency: time.Since(start),
+			Status:  t.status,
+			Err:     err,
+		})
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package reporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestMultiOnAttempt(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Ntfy.URL = server.URL
+	cfg.Ntfy.AlertTiers = []string{"T2"}
+
+	m := NewMulti(NewNtfy(cfg))
+	var attempts []Attempt
+	m.OnAttempt(func(a Attempt) { attempts = append(attempts, a) })
+
+	ctx := context.Background()
+	created := Transition{Kind: TransitionCreated, Count: 1}
+	crash := testEvent("Crash: vlc", event.SevHigh)
+	if err := m.ReportTransition(ctx, created, crash); err != nil {
+		t.Fatal(err)
+	}
+
+	// A tier ntfy does not alert on is no attempt.
+	oom := testEvent("OOM Kill: firefox", event.SevCritical)
+	oom.Tier = event.TierOOMKill
+	if err := m.ReportTransition(ctx, created, oom); err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := m.ReportTransition(ctx, created, crash); err == nil {
+		t.Fatal("want an error for status 503")
+	}
+
+	status = http.StatusOK
+	other := testEvent("Crash: mpv", event.SevHigh)
+	if err := m.ReportBatch(ctx, []Notification{{created, crash}, {created, other}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempts, want 3: %+v", len(attempts), attempts)
+	}
+	if a := attempts[0]; a.Sink != "ntfy" || a.Status != http.StatusOK || a.Err != nil || len(a.Events) != 1 || a.Events[0] != crash {
+		t.Errorf("delivered attempt = %+v", a)
+	}
+	if a := attempts[1]; a.Status != http.StatusServiceUnavailable || a.Err == nil {
+		t.Errorf("failed attempt = %+v", a)
+	}
+	if a := attempts[2]; len(a.Events) != 2 || a.Err != nil {
+		t.Errorf("merged attempt = %+v, want both events", a)
+	}
+}
@@ -0,0 +1,134 @@
+package reporter
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// Batcher merges notifications to one sink that arrive close together.
+//
+// The first event after a quiet period is delivered immediately, so isolated
+// events see no added latency. It opens a window; events arriving during the
+// window are held and sent together as a single message when it closes. If
+// anything was sent, a new window opens, so a sustained burst produces one
+// message per window until it stops.
+type Batcher struct {
+	inner  BatchReporter
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []*event.Event
+	timer   *time.Timer
+	gen     int // identifies the current window; stale timers are ignored
+
+	failed func(evs []*event.Event, err error)
+}
+
+// NewBatcher wraps inner with a batching window.
+func NewBatcher(inner BatchReporter, window time.Duration) *Batcher {
+	return &Batcher{
+		inner:  inner,
+		window: window,
+	}
+}
+
+// OnFailure sets a function called with the held events when a merged
+// message fails, so they can be retried later. Set it before use.
+func (b *Batcher) OnFailure(f func(evs []*event.Event, err error)) {
+	b.failed = f
+}
+
+// Name returns the wrapped sink's name.
+func (b *Batcher) Name() string {
+	return b.inner.Name()
+}
+
+// Report delivers the event immediately if no window is open, otherwise
+// queues it for the next merged message. Events the sink would not deliver
+// are dropped without opening a window.
+func (b *Batcher) Report(ctx context.Context, ev *event.Event) error {
+	if !b.inner.Accepts(ev) {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.timer != nil {
+		b.pending = append(b.pending, ev)
+		b.mu.Unlock()
+		return nil
+	}
+	b.startWindowLocked()
+	b.mu.Unlock()
+
+	return b.inner.Report(ctx, ev)
+}
+
+// Flush closes the current window and delivers any queued events.
+func (b *Batcher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+	evs := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	err := b.deliver(ctx, evs)
+	if err != nil && b.failed != nil {
+		b.failed(evs, err)
+	}
+	return err
+}
+
+// startWindowLocked opens a new batching window. b.mu must be held.
+func (b *Batcher) startWindowLocked() {
+	b.gen++
+	gen := b.gen
+	b.timer = time.AfterFunc(b.window, func() { b.closeWindow(gen) })
+}
+
+// closeWindow runs when a window expires. It sends whatever was queued and
+// keeps batching while events continue to arrive.
+func (b *Batcher) closeWindow(gen int) {
+	b.mu.Lock()
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+	evs := b.pending
+	b.pending = nil
+	if len(evs) == 0 {
+		b.timer = nil
+	} else {
+		b.startWindowLocked()
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := b.deliver(ctx, evs); err != nil {
+		slog.Error("batched notification failed", "reporter", b.inner.Name(), "events", len(evs), "error", err)
+		selfstat.ReporterFailure()
+		if b.failed != nil {
+			b.failed(evs, err)
+		}
+	}
+}
+
+func (b *Batcher) deliver(ctx context.Context, evs []*event.Event) error {
+	switch len(evs) {
+	case 0:
+		return nil
+	case 1:
+		return b.inner.Report(ctx, evs[0])
+	default:
+		return b.inner.ReportBatch(ctx, evs)
+	}
+}
@@ -0,0 +1,173 @@
+package reporter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// recordingSink is a BatchReporter that records what it was asked to send.
+type recordingSink struct {
+	mu      sync.Mutex
+	singles []*event.Event
+	batches [][]*event.Event
+	sent    chan struct{}
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{sent: make(chan struct{}, 16)}
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Accepts(ev *event.Event) bool {
+	return ev.Tier != event.TierMemPressure
+}
+
+func (s *recordingSink) Report(ctx context.Context, ev *event.Event) error {
+	s.mu.Lock()
+	s.singles = append(s.singles, ev)
+	s.mu.Unlock()
+	s.sent <- struct{}{}
+	return nil
+}
+
+func (s *recordingSink) ReportBatch(ctx context.Context, evs []*event.Event) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, evs)
+	s.mu.Unlock()
+	s.sent <- struct{}{}
+	return nil
+}
+
+func (s *recordingSink) counts() (singles, batches int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.singles), len(s.batches)
+}
+
+func testEvent(summary string, sev event.Severity) *event.Event {
+	return &event.Event{
+		InstanceID: "testhost",
+		Timestamp:  time.Now(),
+		Tier:       event.TierProcessCrash,
+		Severity:   sev,
+		Summary:    summary,
+		RawFields:  map[string]string{},
+	}
+}
+
+func waitSent(t *testing.T, s *recordingSink) {
+	t.Helper()
+	select {
+	case <-s.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+}
+
+func TestBatcherFirstEventImmediate(t *testing.T) {
+	sink := newRecordingSink()
+	b := NewBatcher(sink, time.Hour)
+
+	if err := b.Report(context.Background(), testEvent("first", event.SevHigh)); err != nil {
+		t.Fatal(err)
+	}
+	if singles, _ := sink.counts(); singles != 1 {
+		t.Errorf("first event should be sent immediately, singles = %d", singles)
+	}
+
+	b.Report(context.Background(), testEvent("second", event.SevHigh))
+	if singles, batches := sink.counts(); singles != 1 || batches != 0 {
+		t.Errorf("second event should be held, singles=%d batches=%d", singles, batches)
+	}
+	b.Flush(context.Background())
+}
+
+func TestBatcherMergesWindow(t *testing.T) {
+	sink := newRecordingSink()
+	b := NewBatcher(sink, 50*time.Millisecond)
+	ctx := context.Background()
+
+	b.Report(ctx, testEvent("first", event.SevHigh))
+	waitSent(t, sink)
+
+	b.Report(ctx, testEvent("second", event.SevHigh))
+	b.Report(ctx, testEvent("third", event.SevCritical))
+	waitSent(t, sink)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.batches) != 1 || len(sink.batches[0]) != 2 {
+		t.Fatalf("batches = %v, want one batch of 2", sink.batches)
+	}
+	if sink.batches[0][1].Summary != "third" {
+		t.Errorf("batch order not preserved: %q", sink.batches[0][1].Summary)
+	}
+}
+
+func TestBatcherSkipsUnacceptedEvents(t *testing.T) {
+	sink := newRecordingSink()
+	b := NewBatcher(sink, time.Hour)
+	ctx := context.Background()
+
+	ev := testEvent("pressure", event.SevWarning)
+	ev.Tier = event.TierMemPressure
+	b.Report(ctx, ev)
+
+	// An unaccepted event must not open a window that delays the next one.
+	b.Report(ctx, testEvent("crash", event.SevHigh))
+	if singles, _ := sink.counts(); singles != 1 {
+		t.Errorf("singles = %d, want 1", singles)
+	}
+	b.Flush(ctx)
+}
+
+func TestBatcherFlush(t *testing.T) {
+	sink := newRecordingSink()
+	b := NewBatcher(sink, time.Hour)
+	ctx := context.Background()
+
+	b.Report(ctx, testEvent("first", event.SevHigh))
+	b.Report(ctx, testEvent("second", event.SevHigh))
+
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if singles, batches := sink.counts(); singles != 2 || batches != 0 {
+		t.Errorf("flush of a single pending event should use Report, singles=%d batches=%d", singles, batches)
+	}
+
+	// After a flush, the next event starts a fresh window.
+	b.Report(ctx, testEvent("third", event.SevHigh))
+	if singles, _ := sink.counts(); singles != 3 {
+		t.Errorf("event after flush should be immediate, singles = %d", singles)
+	}
+	b.Flush(ctx)
+}
+
+func TestFormatBatch(t *testing.T) {
+	evs := []*event.Event{
+		testEvent("Crash: foo", event.SevHigh),
+		testEvent("OOM Kill: bar", event.SevCritical),
+		testEvent("Crash: baz", event.SevHigh),
+	}
+	evs[1].Tier = event.TierOOMKill
+
+	title := FormatBatchTitle(evs)
+	if !strings.Contains(title, "3 events: OOM Kill: bar (+2 more)") {
+		t.Errorf("title should lead with the most severe event: %q", title)
+	}
+
+	body := FormatBatchBody(evs)
+	if strings.Count(body, "• ") != 3 {
+		t.Errorf("body should have one bullet per event: %q", body)
+	}
+	if !strings.Contains(body, "[T1] OOM Kill: bar") {
+		t.Errorf("body missing tier/summary: %q", body)
+	}
+}
@@ -2,82 +2,404 @@ package reporter
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/store"
 )
 
-// DigestSummary holds aggregated event counts for a digest period.
+// DigestSummary holds aggregated event counts for a digest period. Its JSON
+// form is the output of "digest --format=json".
 type DigestSummary struct {
-	InstanceID string
-	Since      time.Time
-	Until      time.Time
-
-	OOMKills        int
-	OOMBreakdown    map[string]int // process -> count
-	Crashes         int
-	CrashBreakdown  map[string]int
-	ServiceFailures int
-	ServiceBreakdown map[string]int // unit -> count
-	KernelHWErrors  int
-	KernelBreakdown []string // unique summaries
-	MemPressure     int
-}
-
-// BuildDigest aggregates a list of events into a DigestSummary.
-func BuildDigest(instanceID string, events []*event.Event, since, until time.Time) *DigestSummary {
-	d := &DigestSummary{
-		InstanceID:       instanceID,
-		Since:            since,
-		Until:            until,
-		OOMBreakdown:     make(map[string]int),
-		CrashBreakdown:   make(map[string]int),
-		ServiceBreakdown: make(map[string]int),
+	InstanceID string    `json:"instance_id"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+
+	OOMKills          int            `json:"oom_kills"`
+	OOMBreakdown      map[string]int `json:"oom_breakdown,omitempty"` // process -> count
+	Crashes           int            `json:"crashes"`
+	CrashBreakdown    map[string]int `json:"crash_breakdown,omitempty"`
+	ServiceFailures   int            `json:"service_failures"`
+	ServiceBreakdown  map[string]int `json:"service_breakdown,omitempty"` // unit -> count
+	KernelHWErrors    int            `json:"kernel_hw_errors"`
+	KernelBreakdown   []string       `json:"kernel_breakdown,omitempty"` // unique summaries
+	MemPressure       int            `json:"mem_pressure"`
+	ResourceLimits    int            `json:"resource_limits"`
+	ResourceBreakdown map[string]int `json:"resource_breakdown,omitempty"` // subject -> count
+	Reboots           int            `json:"reboots"`
+	Security          int            `json:"security"`
+	SecurityBreakdown map[string]int `json:"security_breakdown,omitempty"` // subject -> count
+	Internal          int            `json:"internal"`
+	InternalBreakdown []string       `json:"internal_breakdown,omitempty"` // unique summaries
+
+	// Severe journal lines no pattern matched, with a few distinct samples,
+	// so gaps in pattern coverage are noticed.
+	Unclassified        int      `json:"unclassified"`
+	UnclassifiedSamples []string `json:"unclassified_samples,omitempty"`
+
+	DiskTemps []store.MetricStats `json:"disk_temps,omitempty"` // SMART drive temperatures by device
+	Health    *SelfHealth         `json:"health,omitempty"`     // nil omits the self-health section
+
+	// Hosts breaks a fleet digest down by instance, sorted by instance ID.
+	// It is empty unless the events came from more than one instance.
+	Hosts []*DigestSummary `json:"hosts,omitempty"`
+
+	// Previous summarizes the period of the same length before this one,
+	// when set by Compare, and NewCrashers lists the processes that crashed
+	// in this period but not in that one.
+	Previous    *DigestSummary `json:"previous,omitempty"`
+	NewCrashers []string       `json:"new_crashers,omitempty"`
+}
+
+// digestCounter is one of a digest's totals.
+type digestCounter struct {
+	metric string // CSV metric name
+	short  string // name in a fleet digest's host lines
+	count  int
+}
+
+// counters lists d's totals in display order.
+func (d *DigestSummary) counters() []digestCounter {
+	return []digestCounter{
+		{"oom_kills", "OOM", d.OOMKills},
+		{"crashes", "crashes", d.Crashes},
+		{"service_failures", "services", d.ServiceFailures},
+		{"kernel_hw_errors", "HW/kernel", d.KernelHWErrors},
+		{"mem_pressure", "memory", d.MemPressure},
+		{"resource_limits", "limits", d.ResourceLimits},
+		{"reboots", "reboots", d.Reboots},
+		{"security", "security", d.Security},
+		{"internal", "internal", d.Internal},
+		{"unclassified", "unclassified", d.Unclassified},
 	}
+}
 
-	kernelSeen := make(map[string]bool)
+// unclassifiedSamples is how many distinct unclassified lines a digest lists.
+const unclassifiedSamples = 5
+
+// runStaleAfter is how old a run's heartbeat may be before the daemon is
+// considered not running. Runs save their counters every minute.
+const runStaleAfter = 5 * time.Minute
+
+// SelfHealth describes logtriage's own health over a digest period, so slow
+// degradation of the monitor is reviewed along with the hosts it watches.
+type SelfHealth struct {
+	Running          bool          `json:"running"`
+	Uptime           time.Duration `json:"uptime_ns,omitzero"` // of the current run, when Running
+	LastSeen         time.Time     `json:"last_seen,omitzero"` // last heartbeat, when not Running
+	Restarts         int           `json:"restarts"`           // daemon starts within the period
+	UncleanExits     int           `json:"unclean_exits"`      // runs that ended without a clean shutdown
+	Dropped          int64         `json:"dropped"`
+	ReporterFailures int64         `json:"reporter_failures"`
+	DBSize           int64         `json:"db_size,omitzero"`   // bytes, 0 if unknown
+	Warnings         []string      `json:"warnings,omitempty"` // capability warnings from the latest run
+}
+
+// BuildSelfHealth summarizes the daemon runs overlapping a digest period.
+// Counters of a run that began before since are counted in full.
+func BuildSelfHealth(runs []*store.Run, since, now time.Time, dbSize int64) *SelfHealth {
+	h := &SelfHealth{DBSize: dbSize}
+	for i, r := range runs {
+		latest := i == len(runs)-1
+		if !r.Started.Before(since) {
+			h.Restarts++
+		}
+		h.Dropped += r.Dropped
+		h.ReporterFailures += r.ReporterFailures
+
+		alive := latest && r.Stopped.IsZero() && now.Sub(r.Heartbeat) <= runStaleAfter
+		if r.Stopped.IsZero() && !alive {
+			h.UncleanExits++
+		}
+		if latest {
+			h.Running = alive
+			h.Warnings = r.Warnings
+			if alive {
+				h.Uptime = now.Sub(r.Started)
+			} else {
+				h.LastSeen = r.Heartbeat
+			}
+		}
+	}
+	return h
+}
+
+// BuildDigest aggregates a list of events into a DigestSummary. When the
+// events come from more than one instance, as on a hub, the summary holds
+// the fleet totals and Hosts holds a summary for each instance.
+func BuildDigest(instanceID string, events []*event.Event, since, until time.Time) *DigestSummary {
+	d := newDigest(instanceID, countEvents(events), since, until)
 
+	byHost := make(map[string][]*event.Event)
 	for _, ev := range events {
-		switch ev.Tier {
-		case event.TierOOMKill:
-			d.OOMKills++
-			name := ev.Process
-			if name == "" {
-				name = "unknown"
+		byHost[ev.InstanceID] = append(byHost[ev.InstanceID], ev)
+	}
+	if len(byHost) > 1 {
+		for _, id := range slices.Sorted(maps.Keys(byHost)) {
+			d.Hosts = append(d.Hosts, newDigest(id, countEvents(byHost[id]), since, until))
+		}
+	}
+	return d
+}
+
+// QueryDigest is BuildDigest for the events f matches between f.Since and
+// f.Until, counted in the database rather than loaded, so a long period on
+// a busy host stays cheap.
+func QueryDigest(db *store.DB, instanceID string, f store.QueryFilter) (*DigestSummary, error) {
+	counts, err := queryCounts(db, f)
+	if err != nil {
+		return nil, err
+	}
+	d := newDigest(instanceID, counts, f.Since, f.Until)
+	if f.InstanceID != "" {
+		return d, nil
+	}
+
+	hosts, err := db.CountByInstance(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) > 1 {
+		for _, id := range slices.Sorted(maps.Keys(hosts)) {
+			f.InstanceID = id
+			counts, err := queryCounts(db, f)
+			if err != nil {
+				return nil, err
 			}
-			d.OOMBreakdown[name]++
-		case event.TierProcessCrash:
-			d.Crashes++
-			name := ev.Process
-			if name == "" {
-				name = "unknown"
+			d.Hosts = append(d.Hosts, newDigest(id, counts, f.Since, f.Until))
+		}
+	}
+	return d, nil
+}
+
+// PreviousPeriod returns the period of the same length just before d's,
+// whose events Compare takes.
+func (d *DigestSummary) PreviousPeriod() (since, until time.Time) {
+	return d.Since.Add(-d.Until.Sub(d.Since)), d.Since
+}
+
+// Compare sets d.Previous from the events of the previous period (see
+// PreviousPeriod) and lists the processes that newly crashed, so the
+// digest shows trends. Each host of a fleet digest is compared with its
+// own previous events.
+func (d *DigestSummary) Compare(prevEvents []*event.Event) {
+	since, until := d.PreviousPeriod()
+	d.setPrevious(newDigest(d.InstanceID, countEvents(prevEvents), since, until))
+
+	for _, h := range d.Hosts {
+		var own []*event.Event
+		for _, ev := range prevEvents {
+			if ev.InstanceID == h.InstanceID {
+				own = append(own, ev)
 			}
-			d.CrashBreakdown[name]++
-		case event.TierServiceFailure:
-			d.ServiceFailures++
-			unit := ev.Unit
-			if unit == "" {
-				unit = "unknown"
+		}
+		h.Compare(own)
+	}
+}
+
+// CompareQuery is Compare for the events f matches in the previous
+// period, counted in the database. The period of f is ignored. On error d
+// is left uncompared.
+func (d *DigestSummary) CompareQuery(db *store.DB, f store.QueryFilter) error {
+	f.Since, f.Until = d.PreviousPeriod()
+	digests := append([]*DigestSummary{d}, d.Hosts...)
+	prev := make([]*DigestSummary, len(digests))
+	for i, s := range digests {
+		if i > 0 {
+			f.InstanceID = s.InstanceID
+		}
+		counts, err := queryCounts(db, f)
+		if err != nil {
+			return err
+		}
+		prev[i] = newDigest(s.InstanceID, counts, f.Since, f.Until)
+	}
+	for i, s := range digests {
+		s.setPrevious(prev[i])
+	}
+	return nil
+}
+
+// setPrevious sets d.Previous and the processes that newly crashed.
+func (d *DigestSummary) setPrevious(prev *DigestSummary) {
+	d.Previous = prev
+	for name := range d.CrashBreakdown {
+		if name != "unknown" && prev.CrashBreakdown[name] == 0 {
+			d.NewCrashers = append(d.NewCrashers, name)
+		}
+	}
+	slices.Sort(d.NewCrashers)
+}
+
+// digestCounts are the aggregated events a digest is built from.
+type digestCounts struct {
+	tiers map[event.Tier]int
+	// names counts the events of the tiers with a breakdown by process, or
+	// by unit for service failures.
+	names map[event.Tier]map[string]int
+	// kernel lists the distinct kernel/hardware summaries, internal the
+	// distinct internal error ones, and unclassified up to
+	// unclassifiedSamples distinct unclassified ones, newest first.
+	kernel       []string
+	internal     []string
+	unclassified []string
+}
+
+// breakdownTiers are the tiers a digest breaks down by process or unit.
+var breakdownTiers = []event.Tier{
+	event.TierOOMKill,
+	event.TierProcessCrash,
+	event.TierServiceFailure,
+	event.TierResource,
+	event.TierSecurity,
+}
+
+// countEvents counts events, newest first, for a digest.
+func countEvents(events []*event.Event) digestCounts {
+	c := digestCounts{
+		tiers: make(map[event.Tier]int),
+		names: make(map[event.Tier]map[string]int),
+	}
+	for _, t := range breakdownTiers {
+		c.names[t] = make(map[string]int)
+	}
+
+	kernelSeen := make(map[string]bool)
+	internalSeen := make(map[string]bool)
+	unclassifiedSeen := make(map[string]bool)
+
+	for _, ev := range events {
+		c.tiers[ev.Tier]++
+		if names, ok := c.names[ev.Tier]; ok {
+			if ev.Tier == event.TierServiceFailure {
+				names[ev.Unit]++
+			} else {
+				names[ev.Process]++
 			}
-			d.ServiceBreakdown[unit]++
+		}
+		switch ev.Tier {
 		case event.TierKernelHW:
-			d.KernelHWErrors++
 			if !kernelSeen[ev.Summary] {
 				kernelSeen[ev.Summary] = true
-				d.KernelBreakdown = append(d.KernelBreakdown, ev.Summary)
+				c.kernel = append(c.kernel, ev.Summary)
+			}
+		case event.TierInternal:
+			if !internalSeen[ev.Summary] {
+				internalSeen[ev.Summary] = true
+				c.internal = append(c.internal, ev.Summary)
+			}
+		case event.TierUnclassified:
+			if len(c.unclassified) < unclassifiedSamples && !unclassifiedSeen[ev.Summary] {
+				unclassifiedSeen[ev.Summary] = true
+				c.unclassified = append(c.unclassified, ev.Summary)
 			}
-		case event.TierMemPressure:
-			d.MemPressure++
 		}
 	}
+	return c
+}
 
-	return d
+// queryCounts counts the events f matches in the database for a digest.
+func queryCounts(db *store.DB, f store.QueryFilter) (digestCounts, error) {
+	c := digestCounts{names: make(map[event.Tier]map[string]int)}
+	var err error
+	if c.tiers, err = db.CountByTier(f); err != nil {
+		return c, err
+	}
+
+	for _, t := range breakdownTiers {
+		c.names[t] = make(map[string]int)
+		if c.tiers[t] == 0 {
+			continue
+		}
+		tf := f
+		tf.Tier = string(t)
+		var names []store.NameCount
+		if t == event.TierServiceFailure {
+			names, err = db.TopUnits(tf, 0)
+		} else {
+			names, err = db.TopProcesses(tf, 0)
+		}
+		if err != nil {
+			return c, err
+		}
+		for _, n := range names {
+			c.names[t][n.Name] = n.Count
+		}
+	}
+
+	if c.tiers[event.TierKernelHW] > 0 {
+		tf := f
+		tf.Tier = string(event.TierKernelHW)
+		if c.kernel, err = db.RecentSummaries(tf, 0); err != nil {
+			return c, err
+		}
+	}
+	if c.tiers[event.TierInternal] > 0 {
+		tf := f
+		tf.Tier = string(event.TierInternal)
+		if c.internal, err = db.RecentSummaries(tf, 0); err != nil {
+			return c, err
+		}
+	}
+	if c.tiers[event.TierUnclassified] > 0 {
+		tf := f
+		tf.Tier = string(event.TierUnclassified)
+		if c.unclassified, err = db.RecentSummaries(tf, unclassifiedSamples); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// newDigest builds the digest of one instance, or of a fleet's totals,
+// from its counts.
+func newDigest(instanceID string, c digestCounts, since, until time.Time) *DigestSummary {
+	// Events without a process or unit are listed as "unknown".
+	breakdown := func(t event.Tier) map[string]int {
+		m := make(map[string]int)
+		for name, n := range c.names[t] {
+			if name == "" {
+				name = "unknown"
+			}
+			m[name] += n
+		}
+		return m
+	}
+	return &DigestSummary{
+		InstanceID: instanceID,
+		Since:      since,
+		Until:      until,
+
+		OOMKills:            c.tiers[event.TierOOMKill],
+		OOMBreakdown:        breakdown(event.TierOOMKill),
+		Crashes:             c.tiers[event.TierProcessCrash],
+		CrashBreakdown:      breakdown(event.TierProcessCrash),
+		ServiceFailures:     c.tiers[event.TierServiceFailure],
+		ServiceBreakdown:    breakdown(event.TierServiceFailure),
+		KernelHWErrors:      c.tiers[event.TierKernelHW],
+		KernelBreakdown:     c.kernel,
+		MemPressure:         c.tiers[event.TierMemPressure],
+		ResourceLimits:      c.tiers[event.TierResource],
+		ResourceBreakdown:   breakdown(event.TierResource),
+		Reboots:             c.tiers[event.TierReboot],
+		Security:            c.tiers[event.TierSecurity],
+		SecurityBreakdown:   breakdown(event.TierSecurity),
+		Internal:            c.tiers[event.TierInternal],
+		InternalBreakdown:   c.internal,
+		Unclassified:        c.tiers[event.TierUnclassified],
+		UnclassifiedSamples: c.unclassified,
+	}
 }
 
 // FormatDigest formats a DigestSummary as human-readable text suitable for
-// ntfy or stdout output.
+// ntfy or stdout output. A fleet digest starts with the fleet totals and a
+// line per host, followed by a section for each host.
 func FormatDigest(d *DigestSummary) string {
 	var b strings.Builder
 
@@ -85,43 +407,248 @@ func FormatDigest(d *DigestSummary) string {
 		d.Since.Local().Format("Jan 02"),
 		d.Until.Local().Format("Jan 02"))
 
-	fmt.Fprintf(&b, "=== %s ===\n", d.InstanceID)
+	if len(d.Hosts) > 0 {
+		fmt.Fprintf(&b, "=== Fleet: %d hosts (via %s) ===\n", len(d.Hosts), d.InstanceID)
+	} else {
+		fmt.Fprintf(&b, "=== %s ===\n", d.InstanceID)
+	}
 	fmt.Fprintf(&b, "Period: %s\n\n", dateRange)
 
+	// Changes since the previous period, when compared.
+	prev := d.Previous
+	if prev == nil {
+		prev = &DigestSummary{}
+	}
+	label := previousLabel(d.Until.Sub(d.Since))
+	trend := func(cur, was int) string {
+		if d.Previous == nil || cur == was {
+			return ""
+		}
+		if cur > was {
+			return fmt.Sprintf(" (\u2191%d vs %s)", cur-was, label)
+		}
+		return fmt.Sprintf(" (\u2193%d vs %s)", was-cur, label)
+	}
+
 	// OOM Kills
-	fmt.Fprintf(&b, "OOM Kills:        %d", d.OOMKills)
+	fmt.Fprintf(&b, "OOM Kills:        %d%s", d.OOMKills, trend(d.OOMKills, prev.OOMKills))
 	if d.OOMKills > 0 {
 		fmt.Fprintf(&b, " (%s)", formatBreakdown(d.OOMBreakdown))
 	}
 	b.WriteString("\n")
 
 	// Process Crashes
-	fmt.Fprintf(&b, "Process Crashes:  %d", d.Crashes)
+	fmt.Fprintf(&b, "Process Crashes:  %d%s", d.Crashes, trend(d.Crashes, prev.Crashes))
 	if d.Crashes > 0 {
 		fmt.Fprintf(&b, " (%s)", formatBreakdown(d.CrashBreakdown))
 	}
 	b.WriteString("\n")
+	if len(d.NewCrashers) > 0 {
+		fmt.Fprintf(&b, "  New since %s: %s\n", label, strings.Join(d.NewCrashers, ", "))
+	}
 
 	// Service Failures
-	fmt.Fprintf(&b, "Service Failures: %d", d.ServiceFailures)
+	fmt.Fprintf(&b, "Service Failures: %d%s", d.ServiceFailures, trend(d.ServiceFailures, prev.ServiceFailures))
 	if d.ServiceFailures > 0 {
 		fmt.Fprintf(&b, " (%s)", formatBreakdown(d.ServiceBreakdown))
 	}
 	b.WriteString("\n")
 
 	// HW/Kernel Errors
-	fmt.Fprintf(&b, "HW/Kernel Errors: %d", d.KernelHWErrors)
+	fmt.Fprintf(&b, "HW/Kernel Errors: %d%s", d.KernelHWErrors, trend(d.KernelHWErrors, prev.KernelHWErrors))
 	if d.KernelHWErrors > 0 && len(d.KernelBreakdown) > 0 {
 		fmt.Fprintf(&b, " (%s)", strings.Join(d.KernelBreakdown, ", "))
 	}
 	b.WriteString("\n")
 
 	// Memory Pressure
-	fmt.Fprintf(&b, "Memory Pressure:  %d warning episodes\n", d.MemPressure)
+	fmt.Fprintf(&b, "Memory Pressure:  %d warning episodes%s\n", d.MemPressure, trend(d.MemPressure, prev.MemPressure))
+
+	// Resource Limits
+	if d.ResourceLimits > 0 {
+		fmt.Fprintf(&b, "Resource Limits:  %d%s (%s)\n", d.ResourceLimits,
+			trend(d.ResourceLimits, prev.ResourceLimits), formatBreakdown(d.ResourceBreakdown))
+	}
+
+	// Unexpected Reboots
+	if d.Reboots > 0 {
+		fmt.Fprintf(&b, "Unexpected Reboots: %d%s\n", d.Reboots, trend(d.Reboots, prev.Reboots))
+	}
+
+	// Security
+	if d.Security > 0 {
+		fmt.Fprintf(&b, "Security Events:  %d%s (%s)\n", d.Security,
+			trend(d.Security, prev.Security), formatBreakdown(d.SecurityBreakdown))
+	}
+
+	// logtriage's own recurring failures
+	if d.Internal > 0 {
+		fmt.Fprintf(&b, "Internal Errors:  %d%s\n", d.Internal, trend(d.Internal, prev.Internal))
+		for _, s := range d.InternalBreakdown {
+			fmt.Fprintf(&b, "  %s\n", s)
+		}
+	}
+
+	if d.Unclassified > 0 {
+		fmt.Fprintf(&b, "\nUnclassified severe lines: %d%s (no pattern matched; consider a [[rules]] entry)\n",
+			d.Unclassified, trend(d.Unclassified, prev.Unclassified))
+		for _, s := range d.UnclassifiedSamples {
+			fmt.Fprintf(&b, "  %s\n", s)
+		}
+	}
+
+	if len(d.DiskTemps) > 0 {
+		b.WriteString("\nDrive temperatures:\n")
+		for _, t := range d.DiskTemps {
+			fmt.Fprintf(&b, "  %-14s max %.0f°C, avg %.0f°C\n", t.Subject, t.Max, t.Avg)
+		}
+	}
+
+	if d.Health != nil {
+		b.WriteString("\n")
+		formatSelfHealth(&b, d.Health)
+	}
+
+	if len(d.Hosts) > 0 {
+		b.WriteString("\nHosts:\n")
+		for _, h := range d.Hosts {
+			fmt.Fprintf(&b, "  %-20s %s\n", h.InstanceID, formatHostCounts(h))
+		}
+		for _, h := range d.Hosts {
+			b.WriteString("\n")
+			b.WriteString(FormatDigest(h))
+		}
+	}
 
 	return b.String()
 }
 
+// formatHostCounts summarizes a host's digest on one line, such as
+// "5 events (OOM ×2, crashes ×3)".
+func formatHostCounts(d *DigestSummary) string {
+	var total int
+	var parts []string
+	for _, c := range d.counters() {
+		if c.count > 0 {
+			total += c.count
+			parts = append(parts, fmt.Sprintf("%s \u00d7%d", c.short, c.count))
+		}
+	}
+	if total == 0 {
+		return "no events"
+	}
+	return fmt.Sprintf("%d events (%s)", total, strings.Join(parts, ", "))
+}
+
+func formatSelfHealth(b *strings.Builder, h *SelfHealth) {
+	b.WriteString("logtriage health:\n")
+	switch {
+	case h.Running:
+		fmt.Fprintf(b, "  Uptime:            %s\n", formatUptime(h.Uptime))
+	case h.LastSeen.IsZero():
+		b.WriteString("  Status:            NOT RUNNING (no runs recorded)\n")
+	default:
+		fmt.Fprintf(b, "  Status:            NOT RUNNING (last seen %s)\n",
+			h.LastSeen.Local().Format("Jan 02 15:04"))
+	}
+	fmt.Fprintf(b, "  Restarts:          %d", h.Restarts)
+	if h.UncleanExits > 0 {
+		fmt.Fprintf(b, " (%d unclean)", h.UncleanExits)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(b, "  Dropped events:    %d\n", h.Dropped)
+	fmt.Fprintf(b, "  Reporter failures: %d\n", h.ReporterFailures)
+	if h.DBSize > 0 {
+		fmt.Fprintf(b, "  Database size:     %s\n", format.Bytes(h.DBSize))
+	}
+	for _, w := range h.Warnings {
+		fmt.Fprintf(b, "  Warning: %s\n", w)
+	}
+}
+
+// previousLabel names the period of the given length before a digest's,
+// as in "vs last week".
+func previousLabel(period time.Duration) string {
+	hours := int(period.Round(time.Hour).Hours())
+	switch {
+	case hours == 7*24:
+		return "last week"
+	case hours == 24:
+		return "yesterday"
+	case hours%24 == 0:
+		return fmt.Sprintf("the previous %d days", hours/24)
+	}
+	return fmt.Sprintf("the previous %dh", hours)
+}
+
+// formatUptime formats a duration as days and hours, or hours and minutes
+// when under a day.
+func formatUptime(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+// DigestCSVHeader names the columns of DigestRecords.
+var DigestCSVHeader = []string{"metric", "subject", "value", "instance"}
+
+// DigestRecords flattens a digest into metric, subject, value, instance rows
+// for "digest --format=csv". Totals have an empty subject; breakdowns follow
+// their total, sorted by subject. In a fleet digest the fleet totals have an
+// empty instance and each host's rows follow, in the order of Hosts.
+func DigestRecords(d *DigestSummary) [][]string {
+	instance := d.InstanceID
+	if len(d.Hosts) > 0 {
+		instance = ""
+	}
+	var rows [][]string
+	add := func(metric, subject string, value any) {
+		rows = append(rows, []string{metric, subject, fmt.Sprint(value), instance})
+	}
+	addBreakdown := func(metric string, total int, m map[string]int) {
+		add(metric, "", total)
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			add(metric, k, m[k])
+		}
+	}
+
+	addBreakdown("oom_kills", d.OOMKills, d.OOMBreakdown)
+	addBreakdown("crashes", d.Crashes, d.CrashBreakdown)
+	addBreakdown("service_failures", d.ServiceFailures, d.ServiceBreakdown)
+	add("kernel_hw_errors", "", d.KernelHWErrors)
+	add("mem_pressure", "", d.MemPressure)
+	addBreakdown("resource_limits", d.ResourceLimits, d.ResourceBreakdown)
+	add("reboots", "", d.Reboots)
+	addBreakdown("security", d.Security, d.SecurityBreakdown)
+	add("internal", "", d.Internal)
+	add("unclassified", "", d.Unclassified)
+	for _, t := range d.DiskTemps {
+		add("disk_temp_max", t.Subject, t.Max)
+		add("disk_temp_avg", t.Subject, strconv.FormatFloat(t.Avg, 'f', 1, 64))
+	}
+	if h := d.Health; h != nil {
+		add("health_running", "", h.Running)
+		add("health_restarts", "", h.Restarts)
+		add("health_unclean_exits", "", h.UncleanExits)
+		add("health_dropped", "", h.Dropped)
+		add("health_reporter_failures", "", h.ReporterFailures)
+		add("health_db_size", "", h.DBSize)
+	}
+	if p := d.Previous; p != nil {
+		for _, c := range p.counters() {
+			add("previous_"+c.metric, "", c.count)
+		}
+		for _, name := range d.NewCrashers {
+			add("new_crasher", name, d.CrashBreakdown[name])
+		}
+	}
+	for _, h := range d.Hosts {
+		rows = append(rows, DigestRecords(h)...)
+	}
+	return rows
+}
+
 // FormatDigestTitle generates the ntfy title for a digest notification.
 func FormatDigestTitle(since, until time.Time) string {
 	return fmt.Sprintf("\U0001f4ca logtriage weekly digest (%s-%s)",
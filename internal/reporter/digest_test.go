@@ -1,11 +1,15 @@
 package reporter
 
 import (
+	"fmt"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
 )
 
 func TestBuildDigestEmpty(t *testing.T) {
@@ -38,6 +42,8 @@ func TestBuildDigestCounts(t *testing.T) {
 		{Tier: event.TierKernelHW, Summary: "EXT4 error on /dev/sdb"},
 		{Tier: event.TierMemPressure},
 		{Tier: event.TierMemPressure},
+		{Tier: event.TierInternal, Summary: "logtriage error: failed to send notification"},
+		{Tier: event.TierInternal, Summary: "logtriage error: failed to send notification"},
 	}
 
 	d := BuildDigest("testhost", events, since, until)
@@ -76,6 +82,32 @@ func TestBuildDigestCounts(t *testing.T) {
 	if len(d.KernelBreakdown) != 2 {
 		t.Errorf("KernelBreakdown len = %d, want 2", len(d.KernelBreakdown))
 	}
+	if d.Internal != 2 || len(d.InternalBreakdown) != 1 {
+		t.Errorf("Internal = %d %v, want 2 of one kind", d.Internal, d.InternalBreakdown)
+	}
+}
+
+func TestBuildDigestUnclassified(t *testing.T) {
+	var events []*event.Event
+	for i := range 8 {
+		events = append(events, &event.Event{
+			Tier:    event.TierUnclassified,
+			Summary: fmt.Sprintf("Unclassified crit from app%d: failure", max(i-1, 0)),
+		})
+	}
+
+	d := BuildDigest("host", events, time.Now(), time.Now())
+	if d.Unclassified != 8 {
+		t.Errorf("Unclassified = %d, want 8", d.Unclassified)
+	}
+	if len(d.UnclassifiedSamples) != 5 || d.UnclassifiedSamples[0] != "Unclassified crit from app0: failure" {
+		t.Errorf("UnclassifiedSamples = %q", d.UnclassifiedSamples)
+	}
+
+	out := FormatDigest(d)
+	if !strings.Contains(out, "Unclassified severe lines: 8") || !strings.Contains(out, "  Unclassified crit from app4: failure\n") {
+		t.Errorf("FormatDigest missing unclassified section:\n%s", out)
+	}
 }
 
 func TestBuildDigestUnknownProcess(t *testing.T) {
@@ -112,6 +144,9 @@ func TestFormatDigest(t *testing.T) {
 		KernelHWErrors:   0,
 		KernelBreakdown:  nil,
 		MemPressure:      4,
+		DiskTemps: []store.MetricStats{
+			{Subject: "/dev/sda", Samples: 168, Min: 38, Max: 52, Avg: 44.4},
+		},
 	}
 
 	out := FormatDigest(d)
@@ -129,6 +164,8 @@ func TestFormatDigest(t *testing.T) {
 		"vlc",
 		"gimp",
 		"docker.service",
+		"Drive temperatures:",
+		"/dev/sda       max 52°C, avg 44°C",
 	}
 
 	for _, check := range checks {
@@ -171,3 +208,258 @@ func TestFormatBreakdown(t *testing.T) {
 		t.Errorf("missing count marker: %q", out)
 	}
 }
+
+func TestBuildSelfHealth(t *testing.T) {
+	now := time.Date(2024, 2, 17, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-7 * 24 * time.Hour)
+
+	runs := []*store.Run{
+		// Began before the period and shut down cleanly.
+		{Started: since.Add(-time.Hour), Heartbeat: since.Add(time.Hour), Stopped: since.Add(time.Hour), Dropped: 2},
+		// Crashed: never recorded a stop.
+		{Started: since.Add(2 * time.Hour), Heartbeat: since.Add(3 * time.Hour), ReporterFailures: 4},
+		// Current run.
+		{Started: now.Add(-26 * time.Hour), Heartbeat: now.Add(-time.Minute), Dropped: 1,
+			Warnings: []string{"smartctl not found: SMART disk health disabled"}},
+	}
+
+	h := BuildSelfHealth(runs, since, now, 3*1024*1024)
+	if !h.Running || h.Uptime != 26*time.Hour {
+		t.Errorf("Running = %v, Uptime = %v", h.Running, h.Uptime)
+	}
+	if h.Restarts != 2 || h.UncleanExits != 1 {
+		t.Errorf("Restarts = %d, UncleanExits = %d, want 2, 1", h.Restarts, h.UncleanExits)
+	}
+	if h.Dropped != 3 || h.ReporterFailures != 4 {
+		t.Errorf("Dropped = %d, ReporterFailures = %d", h.Dropped, h.ReporterFailures)
+	}
+
+	d := BuildDigest("testhost", nil, since, now)
+	d.Health = h
+	out := FormatDigest(d)
+	for _, want := range []string{"Uptime:            1d 2h", "Restarts:          2 (1 unclean)",
+		"Database size:     3.0 MB", "Warning: smartctl not found"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("digest missing %q:\n%s", want, out)
+		}
+	}
+
+	// A stale heartbeat means the daemon is down, and its run was unclean.
+	h = BuildSelfHealth(runs, since, now.Add(time.Hour), 0)
+	if h.Running || h.UncleanExits != 2 || !h.LastSeen.Equal(runs[2].Heartbeat) {
+		t.Errorf("stale: Running = %v, UncleanExits = %d, LastSeen = %v", h.Running, h.UncleanExits, h.LastSeen)
+	}
+	d.Health = h
+	if out := FormatDigest(d); !strings.Contains(out, "NOT RUNNING") {
+		t.Errorf("digest should flag the daemon as down:\n%s", out)
+	}
+}
+
+func TestDigestRecords(t *testing.T) {
+	d := &DigestSummary{
+		InstanceID:   "desk",
+		OOMKills:     3,
+		OOMBreakdown: map[string]int{"firefox": 2, "electron": 1},
+		DiskTemps:    []store.MetricStats{{Subject: "/dev/sda", Max: 52, Avg: 44.44}},
+		Health:       &SelfHealth{Running: true, Restarts: 1},
+	}
+	rows := DigestRecords(d)
+
+	want := [][]string{
+		{"oom_kills", "", "3", "desk"},
+		{"oom_kills", "electron", "1", "desk"},
+		{"oom_kills", "firefox", "2", "desk"},
+		{"crashes", "", "0", "desk"},
+	}
+	for i, w := range want {
+		if strings.Join(rows[i], ",") != strings.Join(w, ",") {
+			t.Errorf("row %d = %q, want %q", i, rows[i], w)
+		}
+	}
+
+	var found []string
+	for _, r := range rows {
+		if len(r) != len(DigestCSVHeader) {
+			t.Fatalf("row %q has %d columns", r, len(r))
+		}
+		if strings.HasPrefix(r[0], "disk_temp") || r[0] == "health_running" {
+			found = append(found, strings.Join(r, ","))
+		}
+	}
+	if got := strings.Join(found, ";"); got != "disk_temp_max,/dev/sda,52,desk;disk_temp_avg,/dev/sda,44.4,desk;health_running,,true,desk" {
+		t.Errorf("temperature and health rows = %s", got)
+	}
+}
+
+func TestBuildDigestFleet(t *testing.T) {
+	since := time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)
+
+	events := []*event.Event{
+		{InstanceID: "web1", Tier: event.TierOOMKill, Process: "java"},
+		{InstanceID: "db1", Tier: event.TierServiceFailure, Unit: "postgresql.service"},
+		{InstanceID: "web1", Tier: event.TierOOMKill, Process: "java"},
+		{InstanceID: "db1", Tier: event.TierKernelHW, Summary: "I/O error on /dev/sda"},
+	}
+
+	d := BuildDigest("hub", events, since, until)
+	if d.OOMKills != 2 || d.ServiceFailures != 1 || d.KernelHWErrors != 1 {
+		t.Errorf("fleet totals = %d OOM, %d services, %d kernel", d.OOMKills, d.ServiceFailures, d.KernelHWErrors)
+	}
+	if len(d.Hosts) != 2 || d.Hosts[0].InstanceID != "db1" || d.Hosts[1].InstanceID != "web1" {
+		t.Fatalf("hosts = %+v, want db1 and web1", d.Hosts)
+	}
+	if db1 := d.Hosts[0]; db1.OOMKills != 0 || db1.ServiceFailures != 1 || db1.KernelHWErrors != 1 {
+		t.Errorf("db1 = %+v", db1)
+	}
+	if web1 := d.Hosts[1]; web1.OOMKills != 2 || web1.OOMBreakdown["java"] != 2 {
+		t.Errorf("web1 = %+v", web1)
+	}
+
+	out := FormatDigest(d)
+	for _, want := range []string{
+		"=== Fleet: 2 hosts (via hub) ===",
+		"web1                 2 events (OOM \u00d72)",
+		"=== db1 ===",
+		"=== web1 ===",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("digest missing %q:\n%s", want, out)
+		}
+	}
+
+	rows := DigestRecords(d)
+	if r := rows[0]; r[0] != "oom_kills" || r[2] != "2" || r[3] != "" {
+		t.Errorf("first row = %q, want the fleet OOM total", r)
+	}
+	hosts := make(map[string]bool)
+	for _, r := range rows {
+		hosts[r[3]] = true
+	}
+	if len(hosts) != 3 || !hosts["db1"] || !hosts["web1"] {
+		t.Errorf("row instances = %v, want fleet, db1, and web1", hosts)
+	}
+
+	// Events from a single instance have no per-host sections.
+	if d := BuildDigest("hub", events[:1], since, until); len(d.Hosts) != 0 {
+		t.Errorf("single-instance digest has hosts: %+v", d.Hosts)
+	}
+}
+
+func TestDigestCompare(t *testing.T) {
+	since := time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)
+
+	events := []*event.Event{
+		{Tier: event.TierOOMKill, Process: "firefox"},
+		{Tier: event.TierOOMKill, Process: "firefox"},
+		{Tier: event.TierOOMKill, Process: "java"},
+		{Tier: event.TierProcessCrash, Process: "vlc"},
+		{Tier: event.TierProcessCrash, Process: "gimp"},
+		{Tier: event.TierProcessCrash, Process: "blender"},
+		{Tier: event.TierMemPressure},
+	}
+	prev := []*event.Event{
+		{Tier: event.TierOOMKill, Process: "firefox"},
+		{Tier: event.TierProcessCrash, Process: "vlc"},
+		{Tier: event.TierServiceFailure, Unit: "docker.service"},
+		{Tier: event.TierMemPressure},
+	}
+
+	d := BuildDigest("testhost", events, since, until)
+	if s, u := d.PreviousPeriod(); !s.Equal(since.AddDate(0, 0, -7)) || !u.Equal(since) {
+		t.Errorf("PreviousPeriod = %v - %v", s, u)
+	}
+	d.Compare(prev)
+	if d.Previous.OOMKills != 1 || d.Previous.ServiceFailures != 1 {
+		t.Errorf("Previous = %+v", d.Previous)
+	}
+	if got := strings.Join(d.NewCrashers, ","); got != "blender,gimp" {
+		t.Errorf("NewCrashers = %s, want blender,gimp", got)
+	}
+
+	out := FormatDigest(d)
+	for _, want := range []string{
+		"OOM Kills:        3 (\u21912 vs last week) (",
+		"Process Crashes:  3 (\u21912 vs last week) (",
+		"  New since last week: blender, gimp\n",
+		"Service Failures: 0 (\u21931 vs last week)\n",
+		"Memory Pressure:  1 warning episodes\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("digest missing %q:\n%s", want, out)
+		}
+	}
+
+	// Without a comparison there are no trends.
+	if out := FormatDigest(BuildDigest("testhost", events, since, until)); strings.Contains(out, "vs last week") {
+		t.Errorf("uncompared digest shows trends:\n%s", out)
+	}
+
+	for period, want := range map[time.Duration]string{
+		7 * 24 * time.Hour:  "last week",
+		24 * time.Hour:      "yesterday",
+		30 * 24 * time.Hour: "the previous 30 days",
+		12 * time.Hour:      "the previous 12h",
+	} {
+		if got := previousLabel(period); got != want {
+			t.Errorf("previousLabel(%v) = %q, want %q", period, got, want)
+		}
+	}
+}
+
+func TestQueryDigest(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	until := time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)
+	since := until.AddDate(0, 0, -7)
+	var events, prev []*event.Event // newest first, as the store returns them
+	add := func(age time.Duration, instance string, tier event.Tier, process, unit, summary string) {
+		ev := event.New(instance, until.Add(-age), tier, event.SevHigh, summary)
+		ev.Process, ev.Unit = process, unit
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Timestamp.Before(since) {
+			prev = append(prev, ev)
+		} else {
+			events = append(events, ev)
+		}
+	}
+	add(1*time.Hour, "web1", event.TierOOMKill, "java", "", "OOM Kill: java")
+	add(2*time.Hour, "web1", event.TierOOMKill, "", "", "OOM Kill")
+	add(3*time.Hour, "db1", event.TierServiceFailure, "", "postgresql.service", "Service failed: postgresql.service")
+	add(4*time.Hour, "db1", event.TierKernelHW, "", "", "I/O error on /dev/sdb")
+	add(5*time.Hour, "db1", event.TierKernelHW, "", "", "I/O error on /dev/sda")
+	add(6*time.Hour, "db1", event.TierKernelHW, "", "", "I/O error on /dev/sdb")
+	add(7*time.Hour, "web1", event.TierProcessCrash, "vlc", "", "Crash: vlc")
+	add(8*time.Hour, "web1", event.TierProcessCrash, "gimp", "", "Crash: gimp")
+	add(8*time.Hour+time.Minute, "db1", event.TierInternal, "logtriage", "", "logtriage error: failed to send notification")
+	add(8*time.Hour+2*time.Minute, "db1", event.TierInternal, "logtriage", "", "logtriage warning: smartctl query failed (device=/dev/sda)")
+	add(8*time.Hour+3*time.Minute, "db1", event.TierInternal, "logtriage", "", "logtriage error: failed to send notification")
+	for i := range unclassifiedSamples + 2 {
+		add(time.Duration(9+i)*time.Hour, "db1", event.TierUnclassified, "", "", fmt.Sprintf("line %d", i%6))
+	}
+	add(8*24*time.Hour, "web1", event.TierProcessCrash, "vlc", "", "Crash: vlc")
+	add(9*24*time.Hour, "db1", event.TierMemPressure, "", "", "Memory pressure")
+
+	want := BuildDigest("hub", events, since, until)
+	want.Compare(prev)
+	got, err := QueryDigest(db, "hub", store.QueryFilter{Since: since, Until: until})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.CompareQuery(db, store.QueryFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Hosts) != 2 || len(got.NewCrashers) != 1 {
+		t.Fatalf("digest = %+v, want two hosts and a new crasher", got)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryDigest = %+v\nwant as from BuildDigest %+v", got, want)
+	}
+}
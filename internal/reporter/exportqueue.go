@@ -0,0 +1,80 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// exportMaxQueue is the most records an exportQueue holds. Further ones
+// are dropped until the collector is reachable again.
+const exportMaxQueue = 10000
+
+// exportQueue holds a batching exporter's records between sends. It is
+// bounded so an unreachable collector cannot exhaust memory, and a batch
+// that fails to send is put back ahead of the records queued since. It is
+// safe for concurrent use.
+type exportQueue[T any] struct {
+	mu      sync.Mutex
+	items   []T
+	dropped int // records dropped for lack of room since the last take
+}
+
+// add queues item, or drops it if the queue is full.
+func (q *exportQueue[T]) add(item T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= exportMaxQueue {
+		q.dropped++
+		return
+	}
+	q.items = append(q.items, item)
+}
+
+// take empties the queue, returning its records and how many were dropped
+// since the last take.
+func (q *exportQueue[T]) take() ([]T, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, dropped := q.items, q.dropped
+	q.items, q.dropped = nil, 0
+	return items, dropped
+}
+
+// putBack requeues records that failed to send ahead of those queued
+// since, as far as there is room. The oldest are dropped first.
+func (q *exportQueue[T]) putBack(failed []T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	room := max(exportMaxQueue-len(q.items), 0)
+	if len(failed) > room {
+		q.dropped += len(failed) - room
+		failed = failed[len(failed)-room:]
+	}
+	q.items = append(slices.Clone(failed), q.items...)
+}
+
+// exportStatusError is a non-2xx response from a collector.
+type exportStatusError struct {
+	url  string
+	code int
+	body string
+}
+
+func (e *exportStatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.url, e.code, e.body)
+}
+
+// exportRetryable reports whether a batch that failed to export may be
+// taken later. Transport errors, throttling, and server errors are
+// retried; any other response means the collector will never take the
+// batch, and requeueing it would block everything behind it.
+func exportRetryable(err error) bool {
+	var se *exportStatusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.code == http.StatusTooManyRequests || se.code >= 500
+}
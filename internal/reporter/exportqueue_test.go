@@ -0,0 +1,31 @@
+package reporter
+
+import "testing"
+
+func TestExportQueue(t *testing.T) {
+	var q exportQueue[int]
+	for i := range exportMaxQueue + 5 {
+		q.add(i)
+	}
+	items, dropped := q.take()
+	if len(items) != exportMaxQueue || dropped != 5 {
+		t.Fatalf("took %d, dropped %d", len(items), dropped)
+	}
+
+	// Failed records go back ahead of newer ones.
+	q.add(100)
+	q.putBack([]int{1, 2})
+	if items, _ := q.take(); len(items) != 3 || items[0] != 1 || items[2] != 100 {
+		t.Errorf("after putBack: %v", items)
+	}
+
+	// Without room, the oldest failed records are dropped.
+	for i := range exportMaxQueue - 1 {
+		q.add(i)
+	}
+	q.putBack([]int{-2, -1})
+	items, dropped = q.take()
+	if len(items) != exportMaxQueue || items[0] != -1 || dropped != 1 {
+		t.Errorf("putBack into full queue: first %d, len %d, dropped %d", items[0], len(items), dropped)
+	}
+}
@@ -1,10 +1,14 @@
 package reporter
 
 import (
+	"cmp"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/format"
+	"github.com/setevik/logtriage/internal/store"
 )
 
 // tierEmoji maps event tiers to display emojis for ntfy titles.
@@ -14,6 +18,11 @@ var tierEmoji = map[event.Tier]string{
 	event.TierServiceFailure: "\U0001f6d1", // stop sign
 	event.TierKernelHW:       "\U0001f6a8", // rotating light
 	event.TierMemPressure:    "\U0001f7e1", // yellow circle
+	event.TierResource:       "\U0001f4e6", // package
+	event.TierReboot:         "\U0001f504", // counterclockwise arrows
+	event.TierSecurity:       "\U0001f512", // lock
+	event.TierInternal:       "\U0001f527", // wrench
+	event.TierUnclassified:   "\u2754",     // white question mark
 }
 
 // tierTags maps event tiers to ntfy tag names.
@@ -23,11 +32,33 @@ var tierTags = map[event.Tier]string{
 	event.TierServiceFailure: "rotating_light,service",
 	event.TierKernelHW:       "computer,disk",
 	event.TierMemPressure:    "warning,memory",
+	event.TierResource:       "warning,package",
+	event.TierReboot:         "boom,arrows_counterclockwise",
+	event.TierSecurity:       "lock,shield",
+	event.TierInternal:       "wrench",
+	event.TierUnclassified:   "grey_question",
+}
+
+// tierNoun names one occurrence of each tier in alert history lines, e.g.
+// "3rd OOM kill of firefox this week".
+var tierNoun = map[event.Tier]string{
+	event.TierOOMKill:        "OOM kill",
+	event.TierProcessCrash:   "crash",
+	event.TierServiceFailure: "failure",
+	event.TierKernelHW:       "hardware error",
+	event.TierMemPressure:    "memory pressure alert",
+	event.TierResource:       "resource alert",
+	event.TierReboot:         "unexpected reboot",
+	event.TierSecurity:       "security event",
+	event.TierInternal:       "internal error",
 }
 
 // FormatTitle builds the ntfy notification title for an event.
 func FormatTitle(ev *event.Event) string {
 	emoji := tierEmoji[ev.Tier]
+	if IsResolution(ev) {
+		emoji = "\u2705" // check mark
+	}
 	if emoji == "" {
 		emoji = "\u2757" // exclamation mark
 	}
@@ -49,6 +80,126 @@ func FormatBody(ev *event.Event) string {
 	return b.String()
 }
 
+// FormatHistory describes the earlier occurrences of ev's problem in the
+// last week and month, e.g. "3rd OOM kill of firefox this week; last one
+// 2d 4h ago". It returns "" when there were none.
+func FormatHistory(ev *event.Event, week, month store.History) string {
+	if month.Count == 0 {
+		return ""
+	}
+
+	noun := tierNoun[ev.Tier]
+	if noun == "" {
+		noun = "occurrence"
+	}
+	if subject := cmp.Or(ev.Process, ev.Unit); subject != "" {
+		noun += " of " + subject
+	}
+
+	var b strings.Builder
+	if week.Count > 0 {
+		fmt.Fprintf(&b, "%s %s this week", ordinal(week.Count+1), noun)
+		if month.Count > week.Count {
+			fmt.Fprintf(&b, " (%s in 30 days)", ordinal(month.Count+1))
+		}
+	} else {
+		fmt.Fprintf(&b, "%s %s in 30 days", ordinal(month.Count+1), noun)
+	}
+	fmt.Fprintf(&b, "; last one %s ago", format.Duration(ev.Timestamp.Sub(month.Last)))
+	return b.String()
+}
+
+// ordinal formats n as "1st", "2nd", "3rd", "4th", ..., "11th", "21st".
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// FormatBatchTitle builds the title for a merged notification covering
+// several events. It leads with the most severe event.
+func FormatBatchTitle(evs []*event.Event) string {
+	top := MostSevere(evs)
+	emoji := tierEmoji[top.Tier]
+	if emoji == "" {
+		emoji = "\u2757" // exclamation mark
+	}
+	return fmt.Sprintf("%s [%s] %d events: %s (+%d more)",
+		emoji, top.InstanceID, len(evs), top.Summary, len(evs)-1)
+}
+
+// FormatBatchBody builds the body for a merged notification: a count
+// followed by one bullet line per event in arrival order.
+func FormatBatchBody(evs []*event.Event) string {
+	var b strings.Builder
+
+	multiHost := false
+	for _, ev := range evs {
+		if ev.InstanceID != evs[0].InstanceID {
+			multiHost = true
+			break
+		}
+	}
+
+	if !multiHost {
+		fmt.Fprintf(&b, "Host: %s\n", evs[0].InstanceID)
+	}
+	fmt.Fprintf(&b, "%d events\n\n", len(evs))
+
+	for _, ev := range evs {
+		fmt.Fprintf(&b, "\u2022 %s [%s] ", ev.Timestamp.Format("15:04:05"), ev.Tier)
+		if multiHost {
+			fmt.Fprintf(&b, "%s: ", ev.InstanceID)
+		}
+		b.WriteString(ev.Summary)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// MostSevere returns the event with the highest severity, preferring the
+// earliest on ties. evs must not be empty.
+func MostSevere(evs []*event.Event) *event.Event {
+	top := evs[0]
+	for _, ev := range evs[1:] {
+		if ev.Severity.Rank() > top.Severity.Rank() {
+			top = ev
+		}
+	}
+	return top
+}
+
+// NewResolution builds the notice that an incident was resolved at, when
+// its problem cleared. It refers to alert, the incident's first notified
+// event, and has the lowest severity.
+func NewResolution(inc *store.Incident, alert *event.Event, at time.Time) *event.Event {
+	ev := event.New(inc.InstanceID, at, inc.Tier, event.SevWarning, "Resolved: "+alert.Summary)
+	ev.Unit, ev.Process, ev.DedupKey = alert.Unit, alert.Process, alert.DedupKey
+	ev.IncidentID = inc.ID
+	ev.Detail = fmt.Sprintf("Alerted: %s\nOpen for %s, %d events\nIncident: %s",
+		alert.Timestamp.Format("2006-01-02 15:04:05 MST"), format.Duration(at.Sub(inc.OpenedAt)),
+		inc.EventCount, inc.ID)
+	ev.RawFields["_resolves"] = alert.ID
+	return ev
+}
+
+// IsResolution reports whether ev is a resolution notice from
+// NewResolution.
+func IsResolution(ev *event.Event) bool {
+	return ev.RawFields["_resolves"] != ""
+}
+
 // TagsForTier returns the ntfy tags string for an event tier.
 func TagsForTier(tier event.Tier) string {
 	if tags, ok := tierTags[tier]; ok {
@@ -0,0 +1,221 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// forwardBatchSize is the most events replayed from the spool per request.
+const forwardBatchSize = 100
+
+// ForwardReporter sends classified events to a hub's ingest API. Unlike the
+// notification sinks it forwards every event regardless of tier, so the
+// hub's store holds the full history for the fleet.
+//
+// When the hub cannot be reached, events are queued in an on-disk spool and
+// replayed in order by Run once it is reachable again. While anything is
+// spooled, new events queue behind it to preserve ordering.
+type ForwardReporter struct {
+	cfg    *config.Config
+	client *http.Client
+	spool  *spool
+	wake   chan struct{}
+}
+
+// NewForward creates a ForwardReporter, opening the spool directory and
+// picking up any events left from a previous run.
+func NewForward(cfg *config.Config) (*ForwardReporter, error) {
+	sp, err := openSpool(cfg.SpoolPath(), int64(cfg.Agent.SpoolMaxMB)<<20)
+	if err != nil {
+		return nil, err
+	}
+	return &ForwardReporter{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		spool: sp,
+		wake:  make(chan struct{}, 1),
+	}, nil
+}
+
+// Name returns "forward".
+func (f *ForwardReporter) Name() string {
+	return "forward"
+}
+
+// Report forwards the event to the hub, spooling it if the hub is
+// unreachable. Only events the hub rejects outright produce an error.
+func (f *ForwardReporter) Report(ctx context.Context, ev *event.Event) error {
+	if f.spool.len() > 0 {
+		if err := f.spool.push(ev); err != nil {
+			return fmt.Errorf("spooling event: %w", err)
+		}
+		f.signal()
+		return nil
+	}
+
+	err := f.post(ctx, []*event.Event{ev})
+	if err == nil {
+		slog.Debug("event forwarded to hub", "id", ev.ID, "tier", ev.Tier)
+		return nil
+	}
+	if !retryable(err) {
+		return err
+	}
+
+	slog.Warn("hub unreachable, spooling event", "error", err)
+	if err := f.spool.push(ev); err != nil {
+		return fmt.Errorf("spooling event: %w", err)
+	}
+	return nil
+}
+
+// Spooled returns the number of events waiting to be replayed.
+func (f *ForwardReporter) Spooled() int {
+	return f.spool.len()
+}
+
+// Run replays spooled events until ctx is cancelled, retrying every
+// agent.retry_interval and whenever a new event is spooled.
+func (f *ForwardReporter) Run(ctx context.Context) {
+	interval := f.cfg.Agent.RetryInterval.Duration
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if n := f.spool.len(); n > 0 {
+		slog.Info("replaying spooled events", "count", n)
+		f.drain(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-f.wake:
+		}
+		f.drain(ctx)
+	}
+}
+
+// drain sends spooled events in order until the spool is empty or the hub
+// stops accepting them.
+func (f *ForwardReporter) drain(ctx context.Context) {
+	sent := 0
+	batch := forwardBatchSize
+	for ctx.Err() == nil {
+		evs, names := f.spool.peek(batch)
+		if len(evs) == 0 {
+			break
+		}
+
+		if err := f.post(ctx, evs); err != nil {
+			if retryable(err) {
+				slog.Debug("hub still unreachable", "spooled", f.spool.len(), "error", err)
+				break
+			}
+			// The hub rejects a whole request for one invalid event, or
+			// for its size: halve the batch until the event at fault is
+			// sent alone.
+			if len(evs) > 1 && splittable(err) {
+				batch = len(evs) / 2
+				continue
+			}
+			// The hub will never take this batch; drop it rather than
+			// blocking everything queued behind it.
+			slog.Error("hub rejected spooled events, dropping", "count", len(evs), "error", err)
+			selfstat.Drop(len(evs))
+		} else {
+			sent += len(evs)
+		}
+		f.spool.remove(names)
+		batch = forwardBatchSize
+	}
+
+	if sent > 0 {
+		slog.Info("replayed spooled events to hub", "count", sent, "remaining", f.spool.len())
+	}
+}
+
+func (f *ForwardReporter) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// hubStatusError is a non-2xx response from the hub.
+type hubStatusError struct {
+	code int
+}
+
+func (e *hubStatusError) Error() string {
+	return fmt.Sprintf("hub returned status %d", e.code)
+}
+
+// retryable reports whether a failed post may succeed later. Transport
+// errors, server errors, throttling, and auth failures (which usually mean
+// the hub's token is being rotated) are retried; other 4xx are not.
+func retryable(err error) bool {
+	var se *hubStatusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	switch se.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return se.code >= 500
+}
+
+// splittable reports whether the hub may accept part of a batch it
+// rejected: it refuses a request with any invalid event, or one over its
+// size limit.
+func splittable(err error) bool {
+	var se *hubStatusError
+	return errors.As(err, &se) &&
+		(se.code == http.StatusBadRequest || se.code == http.StatusRequestEntityTooLarge)
+}
+
+// post sends a batch of events to the hub's ingest endpoint.
+func (f *ForwardReporter) post(ctx context.Context, evs []*event.Event) error {
+	data, err := json.Marshal(evs)
+	if err != nil {
+		return fmt.Errorf("encoding events: %w", err)
+	}
+
+	url := strings.TrimRight(f.cfg.Agent.HubURL, "/") + "/api/v1/events"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating hub request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.cfg.Agent.Token)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("forwarding to hub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &hubStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
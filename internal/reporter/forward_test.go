@@ -0,0 +1,307 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// fakeHub records forwarded events and can be switched between accepting
+// and failing requests.
+type fakeHub struct {
+	mu     sync.Mutex
+	status int
+	auth   string
+	path   string
+	got    []*event.Event
+}
+
+func (h *fakeHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.auth = r.Header.Get("Authorization")
+	h.path = r.URL.Path
+	if h.status != http.StatusAccepted {
+		w.WriteHeader(h.status)
+		return
+	}
+
+	var evs []*event.Event
+	if err := json.NewDecoder(r.Body).Decode(&evs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.got = append(h.got, evs...)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *fakeHub) setStatus(code int) {
+	h.mu.Lock()
+	h.status = code
+	h.mu.Unlock()
+}
+
+func (h *fakeHub) summaries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []string
+	for _, ev := range h.got {
+		out = append(out, ev.Summary)
+	}
+	return out
+}
+
+func newTestForward(t *testing.T, hubURL string) *ForwardReporter {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Agent.HubURL = hubURL
+	cfg.Agent.Token = "tok"
+	cfg.Agent.SpoolDir = t.TempDir()
+	cfg.Ntfy.AlertTiers = []string{"T1"}
+
+	f, err := NewForward(cfg)
+	if err != nil {
+		t.Fatalf("NewForward: %v", err)
+	}
+	return f
+}
+
+func TestForwardReporter(t *testing.T) {
+	hub := &fakeHub{status: http.StatusAccepted}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	f := newTestForward(t, server.URL+"/")
+
+	ev := event.New("laptop", time.Now(), event.TierMemPressure, event.SevWarning, "PSI warning")
+	if err := f.Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+
+	if hub.path != "/api/v1/events" {
+		t.Errorf("path = %q", hub.path)
+	}
+	if hub.auth != "Bearer tok" {
+		t.Errorf("Authorization = %q", hub.auth)
+	}
+	if len(hub.got) != 1 || hub.got[0].ID != ev.ID || hub.got[0].InstanceID != "laptop" {
+		t.Errorf("forwarded = %+v, want the event regardless of alert tiers", hub.got)
+	}
+}
+
+func TestForwardSpoolsAndReplaysInOrder(t *testing.T) {
+	hub := &fakeHub{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	f := newTestForward(t, server.URL)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		ev := event.New("laptop", time.Now(), event.TierOOMKill, event.SevCritical, fmt.Sprintf("ev%d", i))
+		if err := f.Report(ctx, ev); err != nil {
+			t.Fatalf("Report() while hub down should spool, got: %v", err)
+		}
+	}
+	if f.Spooled() != 3 {
+		t.Fatalf("Spooled() = %d, want 3", f.Spooled())
+	}
+
+	// Nothing is delivered while the hub keeps failing.
+	f.drain(ctx)
+	if f.Spooled() != 3 {
+		t.Errorf("Spooled() after failed drain = %d, want 3", f.Spooled())
+	}
+
+	hub.setStatus(http.StatusAccepted)
+	f.drain(ctx)
+
+	if f.Spooled() != 0 {
+		t.Errorf("Spooled() after drain = %d, want 0", f.Spooled())
+	}
+	got := hub.summaries()
+	want := []string{"ev1", "ev2", "ev3"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+}
+
+func TestForwardQueuesBehindSpool(t *testing.T) {
+	hub := &fakeHub{status: http.StatusBadGateway}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	f := newTestForward(t, server.URL)
+	ctx := context.Background()
+
+	f.Report(ctx, event.New("laptop", time.Now(), event.TierOOMKill, event.SevCritical, "first"))
+	hub.setStatus(http.StatusAccepted)
+
+	// The hub is back, but "second" must not overtake the spooled "first".
+	f.Report(ctx, event.New("laptop", time.Now(), event.TierOOMKill, event.SevCritical, "second"))
+	if len(hub.summaries()) != 0 {
+		t.Fatalf("event sent ahead of spool: %v", hub.summaries())
+	}
+
+	f.drain(ctx)
+	if got := hub.summaries(); fmt.Sprint(got) != "[first second]" {
+		t.Errorf("replayed %v, want [first second]", got)
+	}
+}
+
+func TestForwardRejectedNotSpooled(t *testing.T) {
+	hub := &fakeHub{status: http.StatusBadRequest}
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	f := newTestForward(t, server.URL)
+
+	ev := event.New("laptop", time.Now(), event.TierOOMKill, event.SevCritical, "OOM")
+	if err := f.Report(context.Background(), ev); err == nil {
+		t.Error("expected error on 400")
+	}
+	if f.Spooled() != 0 {
+		t.Errorf("rejected event should not be spooled, Spooled() = %d", f.Spooled())
+	}
+}
+
+func TestSpoolPersistsAndCaps(t *testing.T) {
+	dir := t.TempDir()
+	// A fixed timestamp keeps every encoded event the same size.
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	sp, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := sp.push(event.New("h", ts, event.TierOOMKill, event.SevCritical, fmt.Sprintf("ev%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopening picks up the same events in order.
+	sp, err = openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs, _ := sp.peek(10)
+	if len(evs) != 3 || evs[0].Summary != "ev0" || evs[2].Summary != "ev2" {
+		t.Fatalf("reopened spool = %v", evs)
+	}
+
+	// A cap smaller than the current contents drops the oldest on push.
+	entries, _ := os.ReadDir(dir)
+	info, _ := entries[0].Info()
+	sp.maxBytes = info.Size() * 2
+	sp.push(event.New("h", ts, event.TierOOMKill, event.SevCritical, "ev3"))
+
+	evs, _ = sp.peek(10)
+	if len(evs) != 2 || evs[0].Summary != "ev2" || evs[1].Summary != "ev3" {
+		var got []string
+		for _, ev := range evs {
+			got = append(got, ev.Summary)
+		}
+		t.Errorf("after cap, spool = %v, want [ev2 ev3]", got)
+	}
+}
+
+func TestForwardDrainWithConcurrentPush(t *testing.T) {
+	hub := &fakeHub{status: http.StatusAccepted}
+	posting := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(posting)
+			<-release
+		})
+		hub.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	f := newTestForward(t, server.URL)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	push := func(summary string) {
+		t.Helper()
+		if err := f.spool.push(event.New("h", ts, event.TierOOMKill, event.SevCritical, summary)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push("ev0")
+	push("ev1")
+	// Room for two events: pushing more drops the oldest.
+	f.spool.maxBytes = f.spool.size
+
+	done := make(chan struct{})
+	go func() {
+		f.drain(context.Background())
+		close(done)
+	}()
+
+	// While ev0 and ev1 are in flight, two pushes drop them from the
+	// spool. The drain must not then remove ev2 and ev3 in their place.
+	<-posting
+	push("ev2")
+	push("ev3")
+	close(release)
+	<-done
+
+	if got := hub.summaries(); fmt.Sprint(got) != "[ev0 ev1 ev2 ev3]" {
+		t.Errorf("hub got %v, want [ev0 ev1 ev2 ev3]", got)
+	}
+	if n := f.spool.len(); n != 0 {
+		t.Errorf("spool has %d events after drain, want 0", n)
+	}
+}
+
+func TestForwardDrainSplitsRejectedBatch(t *testing.T) {
+	hub := &fakeHub{status: http.StatusAccepted}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		requests++
+		// Like the hub, reject the whole request for one invalid event.
+		var evs []*event.Event
+		json.NewDecoder(r.Body).Decode(&evs)
+		for _, ev := range evs {
+			if ev.Summary == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		hub.got = append(hub.got, evs...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	f := newTestForward(t, server.URL)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []string{"ev0", "ev1", "bad", "ev3", "ev4"} {
+		if err := f.spool.push(event.New("h", ts, event.TierOOMKill, event.SevCritical, s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.drain(context.Background())
+	if got := hub.summaries(); fmt.Sprint(got) != "[ev0 ev1 ev3 ev4]" {
+		t.Errorf("hub got %v, want every event but the bad one", got)
+	}
+	if n := f.spool.len(); n != 0 {
+		t.Errorf("spool has %d events after drain, want 0", n)
+	}
+	if requests > 8 {
+		t.Errorf("drain made %d requests", requests)
+	}
+}
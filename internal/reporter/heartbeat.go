@@ -0,0 +1,59 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+)
+
+// Heartbeat pings a dead man's switch, such as a healthchecks.io check,
+// which alerts when the pings stop. A plain GET of the URL is all that is
+// needed, so other services with ping URLs work too.
+type Heartbeat struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewHeartbeat creates a Heartbeat for the URLs under [heartbeat].
+func NewHeartbeat(cfg *config.Config) *Heartbeat {
+	return &Heartbeat{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Ping pings heartbeat.url, if set.
+func (h *Heartbeat) Ping(ctx context.Context) error {
+	return h.ping(ctx, h.cfg.Heartbeat.URL)
+}
+
+// PingDigest reports a delivered digest by pinging heartbeat.digest_url,
+// or heartbeat.url if that is not set.
+func (h *Heartbeat) PingDigest(ctx context.Context) error {
+	url := h.cfg.Heartbeat.DigestURL
+	if url == "" {
+		url = h.cfg.Heartbeat.URL
+	}
+	return h.ping(ctx, url)
+}
+
+func (h *Heartbeat) ping(ctx context.Context, url string) error {
+	if url == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating heartbeat request: %w", err)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pinging %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
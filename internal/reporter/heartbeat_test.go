@@ -0,0 +1,59 @@
+package reporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/setevik/logtriage/internal/config"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	h := NewHeartbeat(cfg)
+	ctx := context.Background()
+
+	// Nothing is pinged without a URL.
+	if err := h.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PingDigest(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Heartbeat.URL = server.URL + "/alive"
+	if err := h.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PingDigest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Heartbeat.DigestURL = server.URL + "/digest"
+	if err := h.PingDigest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Heartbeat.URL = server.URL + "/gone"
+	if err := h.Ping(ctx); err == nil {
+		t.Error("Ping succeeded on a 404")
+	}
+
+	want := []string{"/alive", "/alive", "/digest", "/gone"}
+	if len(got) != len(want) {
+		t.Fatalf("pinged %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ping %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package reporter
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// LokiReporter pushes classified events to Grafana Loki's push API, so they
+// can be queried beside the raw logs they came from. Like syslog export it
+// covers every event of the configured tiers, not only those that alert.
+//
+// Each event is a log line holding its JSON encoding, without raw fields,
+// in a stream labelled job="logtriage" with its instance, tier, and
+// severity; `{job="logtriage"} | json` then exposes the rest. Events are
+// queued and pushed in batches by Run. A batch that fails for want of a
+// connection, or that Loki throttles or fails on, is pushed again with the
+// next one; one Loki rejects is dropped.
+type LokiReporter struct {
+	cfg    *config.Config
+	client *http.Client
+	queue  exportQueue[lokiEntry]
+}
+
+// lokiEntry is an event waiting to be pushed.
+type lokiEntry struct {
+	labels lokiLabels
+	at     time.Time
+	line   string
+}
+
+// lokiLabels are the stream labels of an event. They are few and of low
+// cardinality, as Loki indexes streams by them.
+type lokiLabels struct {
+	instance string
+	tier     string
+	severity string
+}
+
+// NewLoki creates a LokiReporter pushing to loki.url.
+func NewLoki(cfg *config.Config) *LokiReporter {
+	return &LokiReporter{cfg: cfg, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Name returns "loki".
+func (l *LokiReporter) Name() string {
+	return "loki"
+}
+
+// Report queues the event if its tier is selected.
+func (l *LokiReporter) Report(ctx context.Context, ev *event.Event) error {
+	if !l.cfg.LokiShouldSend(string(ev.Tier)) {
+		return nil
+	}
+	line, err := lokiLine(ev)
+	if err != nil {
+		return err
+	}
+	l.queue.add(lokiEntry{
+		labels: lokiLabels{instance: ev.InstanceID, tier: string(ev.Tier), severity: string(ev.Severity)},
+		at:     ev.Timestamp,
+		line:   line,
+	})
+	return nil
+}
+
+// lokiLine encodes the event as a log line. Raw fields are left out, as
+// the journal entry they came from is usually in Loki already.
+func lokiLine(ev *event.Event) (string, error) {
+	e := *ev
+	e.RawFields = nil
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return "", fmt.Errorf("encoding event for loki: %w", err)
+	}
+	return string(b), nil
+}
+
+// Run pushes the queued events every loki.batch_interval until ctx is
+// done. Close pushes what is left.
+func (l *LokiReporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.Loki.BatchInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Flush(ctx); err != nil {
+				slog.Warn("failed to push events to Loki, will retry", "error", err)
+				selfstat.ReporterFailure()
+			}
+		}
+	}
+}
+
+// Close pushes the events still queued, giving up after 10 seconds.
+func (l *LokiReporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return l.Flush(ctx)
+}
+
+// Flush pushes the queued events. If the push fails they stay queued,
+// unless Loki rejected them.
+func (l *LokiReporter) Flush(ctx context.Context) error {
+	entries, dropped := l.queue.take()
+	if dropped > 0 {
+		slog.Warn("Loki push queue full, events dropped", "count", dropped)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := l.push(ctx, lokiPushRequest(entries)); err != nil {
+		if !exportRetryable(err) {
+			slog.Warn("Loki rejected events, dropping", "count", len(entries), "error", err)
+			selfstat.Drop(len(entries))
+			return nil
+		}
+		l.queue.putBack(entries)
+		return fmt.Errorf("pushing %d events: %w", len(entries), err)
+	}
+	return nil
+}
+
+func (l *LokiReporter) push(ctx context.Context, payload lokiPush) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.cfg.Loki.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.cfg.Loki.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", l.cfg.Loki.Tenant)
+	}
+	if l.cfg.Loki.Username != "" {
+		req.SetBasicAuth(l.cfg.Loki.Username, l.cfg.Loki.Password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending to %s: %w", l.cfg.Loki.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &exportStatusError{url: l.cfg.Loki.URL, code: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// lokiPush is the JSON body of a push API request. Each value is a
+// timestamp in Unix nanoseconds, as a string, and a log line.
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiPushRequest groups entries into streams by their labels, in label
+// order, with each stream's lines in time order.
+func lokiPushRequest(entries []lokiEntry) lokiPush {
+	byLabels := make(map[lokiLabels][]lokiEntry)
+	var order []lokiLabels
+	for _, e := range entries {
+		if _, ok := byLabels[e.labels]; !ok {
+			order = append(order, e.labels)
+		}
+		byLabels[e.labels] = append(byLabels[e.labels], e)
+	}
+	slices.SortFunc(order, func(a, b lokiLabels) int {
+		return cmp.Or(cmp.Compare(a.instance, b.instance), cmp.Compare(a.tier, b.tier), cmp.Compare(a.severity, b.severity))
+	})
+
+	var req lokiPush
+	for _, labels := range order {
+		stream := byLabels[labels]
+		slices.SortStableFunc(stream, func(a, b lokiEntry) int { return a.at.Compare(b.at) })
+		values := make([][2]string, len(stream))
+		for i, e := range stream {
+			values[i] = [2]string{strconv.FormatInt(e.at.UnixNano(), 10), e.line}
+		}
+		req.Streams = append(req.Streams, lokiStream{
+			Stream: map[string]string{
+				"job":      "logtriage",
+				"instance": labels.instance,
+				"tier":     labels.tier,
+				"severity": labels.severity,
+			},
+			Values: values,
+		})
+	}
+	return req
+}
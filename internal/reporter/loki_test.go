@@ -0,0 +1,151 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestLokiReporter(t *testing.T) {
+	var got []lokiPush
+	status := http.StatusBadGateway
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if org := r.Header.Get("X-Scope-OrgID"); org != "home" {
+			t.Errorf("X-Scope-OrgID = %q, want home", org)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "1234" || pass != "glc_token" {
+			t.Errorf("basic auth = %q %q %v", user, pass, ok)
+		}
+		var p lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decoding push: %v", err)
+		}
+		got = append(got, p)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Loki.Enabled = true
+	cfg.Loki.URL = server.URL + "/loki/api/v1/push"
+	cfg.Loki.Tenant = "home"
+	cfg.Loki.Username, cfg.Loki.Password = "1234", "glc_token"
+	cfg.Loki.Tiers = []string{"T1"}
+	l := NewLoki(cfg)
+	ctx := context.Background()
+
+	now := time.Now()
+	later := event.New("nas", now, event.TierOOMKill, event.SevCritical, "OOM Kill: smbd")
+	later.RawFields = map[string]string{"MESSAGE": "Out of memory: Killed process 4242 (smbd)"}
+	earlier := event.New("nas", now.Add(-time.Second), event.TierOOMKill, event.SevCritical, "OOM Kill: rsync")
+	other := event.New("desk", now, event.TierOOMKill, event.SevHigh, "OOM Kill: firefox")
+	skipped := event.New("nas", now, event.TierProcessCrash, event.SevHigh, "Segfault: vlc")
+	for _, ev := range []*event.Event{later, earlier, other, skipped} {
+		if err := l.Report(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failed push is retried with the next flush.
+	if err := l.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded against a failing Loki")
+	}
+	status = http.StatusNoContent
+	if err := l.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d pushes, want 2", len(got))
+	}
+
+	streams := got[1].Streams
+	if len(streams) != 2 {
+		t.Fatalf("got %d streams, want 2", len(streams))
+	}
+	want := map[string]string{"job": "logtriage", "instance": "nas", "tier": "T1", "severity": "critical"}
+	for k, v := range want {
+		if streams[1].Stream[k] != v {
+			t.Errorf("label %s = %q, want %q", k, streams[1].Stream[k], v)
+		}
+	}
+	values := streams[1].Values
+	if len(values) != 2 {
+		t.Fatalf("got %d lines for nas, want 2", len(values))
+	}
+	if values[0][0] != strconv.FormatInt(earlier.Timestamp.UnixNano(), 10) {
+		t.Errorf("first line at %s, want the earlier event", values[0][0])
+	}
+
+	var line event.Event
+	if err := json.Unmarshal([]byte(values[1][1]), &line); err != nil {
+		t.Fatalf("line is not an event: %v", err)
+	}
+	if line.ID != later.ID || line.Summary != "OOM Kill: smbd" || line.RawFields != nil {
+		t.Errorf("line = %+v", line)
+	}
+	if later.RawFields == nil {
+		t.Error("Report cleared the event's raw fields")
+	}
+}
+
+func TestLokiDropsRejectedBatch(t *testing.T) {
+	var pushes int
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushes++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Loki.Enabled = true
+	cfg.Loki.URL = server.URL + "/loki/api/v1/push"
+	cfg.Loki.Tiers = []string{"T1"}
+	l := NewLoki(cfg)
+	ctx := context.Background()
+
+	report := func(summary string) {
+		t.Helper()
+		if err := l.Report(ctx, event.New("nas", time.Now(), event.TierOOMKill, event.SevCritical, summary)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Loki will never take a batch it rejects, e.g. for out-of-order
+	// lines, so it is dropped rather than blocking the queue.
+	report("OOM Kill: smbd")
+	if err := l.Flush(ctx); err != nil {
+		t.Fatalf("Flush of a rejected batch = %v, want nil", err)
+	}
+	if entries, _ := l.queue.take(); len(entries) != 0 {
+		t.Fatalf("%d entries requeued after a 400, want 0", len(entries))
+	}
+
+	// Throttling is retried.
+	status = http.StatusTooManyRequests
+	report("OOM Kill: rsync")
+	if err := l.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded against a throttling Loki")
+	}
+	status = http.StatusNoContent
+	if err := l.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pushes != 3 {
+		t.Errorf("got %d pushes, want 3", pushes)
+	}
+}
@@ -2,6 +2,7 @@ package reporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -28,11 +29,22 @@ func NewNtfy(cfg *config.Config) *NtfyReporter {
 	}
 }
 
+// Name returns "ntfy".
+func (r *NtfyReporter) Name() string {
+	return "ntfy"
+}
+
+// Accepts reports whether ntfy has a topic for the event's tier and the tier
+// is in the configured alert tiers.
+func (r *NtfyReporter) Accepts(ev *event.Event) bool {
+	return r.cfg.NtfyTopic(string(ev.Tier)) != "" && r.cfg.ShouldAlert(string(ev.Tier))
+}
+
 // Report sends an event notification to ntfy if the event's tier is in the
 // configured alert tiers.
 func (r *NtfyReporter) Report(ctx context.Context, ev *event.Event) error {
-	if r.cfg.Ntfy.URL == "" {
-		slog.Debug("ntfy URL not configured, skipping notification")
+	if r.cfg.NtfyTopic(string(ev.Tier)) == "" {
+		slog.Debug("ntfy URL not configured, skipping notification", "tier", ev.Tier)
 		return nil
 	}
 
@@ -41,12 +53,81 @@ func (r *NtfyReporter) Report(ctx context.Context, ev *event.Event) error {
 		return nil
 	}
 
-	title := FormatTitle(ev)
-	body := FormatBody(ev)
-	priority := r.cfg.NtfyPriority(string(ev.Severity))
-	tags := TagsForTier(ev.Tier)
+	url, err := r.topicURL(ev)
+	if err != nil {
+		return err
+	}
+
+	priority, tags, actions := r.cfg.NtfyPriority(string(ev.Severity)), TagsForTier(ev.Tier), r.ackAction(ev)
+	if IsResolution(ev) {
+		priority, tags, actions = "low", "white_check_mark", ""
+	}
+	if err := r.post(ctx, url, FormatTitle(ev), FormatBody(ev), priority, tags, actions); err != nil {
+		return err
+	}
+
+	slog.Info("notification sent", "tier", ev.Tier, "summary", ev.Summary, "priority", priority)
+	return nil
+}
+
+// ReportBatch sends one ntfy notification listing all events. Priority and
+// tags follow the most severe event. When the topic is templated, events
+// are split by the topic they render to and each topic gets its own batch.
+func (r *NtfyReporter) ReportBatch(ctx context.Context, evs []*event.Event) error {
+	var urls []string
+	groups := make(map[string][]*event.Event)
+	for _, ev := range evs {
+		url, err := r.topicURL(ev)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[url]; !ok {
+			urls = append(urls, url)
+		}
+		groups[url] = append(groups[url], ev)
+	}
+
+	var errs []error
+	for _, url := range urls {
+		group := groups[url]
+		if len(group) == 1 {
+			errs = append(errs, r.Report(ctx, group[0]))
+			continue
+		}
+		top := MostSevere(group)
+		priority := r.cfg.NtfyPriority(string(top.Severity))
+		if err := r.post(ctx, url, FormatBatchTitle(group), FormatBatchBody(group), priority, TagsForTier(top.Tier), ""); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("batched notification sent", "events", len(group), "priority", priority)
+	}
+	return errors.Join(errs...)
+}
+
+// topicURL renders the configured topic URL for an event's tier.
+func (r *NtfyReporter) topicURL(ev *event.Event) (string, error) {
+	return r.cfg.ExpandTopic(r.cfg.NtfyTopic(string(ev.Tier)), config.TopicData{
+		Instance: ev.InstanceID,
+		Tier:     string(ev.Tier),
+		Severity: string(ev.Severity),
+	})
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Ntfy.URL, strings.NewReader(body))
+// ackAction returns the ntfy action that acknowledges ev (see
+// config.AckConfig), or "" when acks are disabled. Tapping it posts the
+// event's signed ack link and clears the notification.
+func (r *NtfyReporter) ackAction(ev *event.Event) string {
+	if !r.cfg.Ack.Enabled || ev.ID == "" {
+		return ""
+	}
+	return "http, Ack, " + r.cfg.Ack.Link(ev.ID) + ", method=POST, clear=true"
+}
+
+// post publishes a single message to a topic URL, with action buttons when
+// actions is not empty.
+func (r *NtfyReporter) post(ctx context.Context, url, title, body, priority, tags, actions string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating ntfy request: %w", err)
 	}
@@ -54,17 +135,41 @@ func (r *NtfyReporter) Report(ctx context.Context, ev *event.Event) error {
 	req.Header.Set("Title", title)
 	req.Header.Set("Priority", priority)
 	req.Header.Set("Tags", tags)
+	if actions != "" {
+		req.Header.Set("Actions", actions)
+	}
+	SetNtfyOptions(req, r.cfg.Ntfy)
 
+	noteSend(ctx)
 	resp, err := r.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("sending ntfy notification: %w", err)
 	}
 	defer resp.Body.Close()
+	noteStatus(ctx, resp.StatusCode)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("ntfy returned status %d", resp.StatusCode)
 	}
-
-	slog.Info("notification sent", "tier", ev.Tier, "summary", ev.Summary, "priority", priority)
 	return nil
 }
+
+// SetNtfyOptions adds the configured credentials, click URL, icon, and
+// email forwarding to a request publishing to ntfy.
+func SetNtfyOptions(req *http.Request, cfg config.NtfyConfig) {
+	switch {
+	case cfg.Token != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	case cfg.Username != "":
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	if cfg.Click != "" {
+		req.Header.Set("Click", cfg.Click)
+	}
+	if cfg.Icon != "" {
+		req.Header.Set("Icon", cfg.Icon)
+	}
+	if cfg.Email != "" {
+		req.Header.Set("Email", cfg.Email)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/setevik/logtriage/internal/config"
 	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
 )
 
 func TestFormatTitle(t *testing.T) {
@@ -47,6 +49,36 @@ func TestFormatBody(t *testing.T) {
 	}
 }
 
+func TestFormatHistory(t *testing.T) {
+	now := time.Date(2026, 2, 19, 14, 32, 5, 0, time.UTC)
+	ev := &event.Event{Timestamp: now, Tier: event.TierOOMKill, Process: "firefox"}
+	last := now.Add(-(52*time.Hour + 10*time.Minute))
+
+	tests := []struct {
+		name        string
+		ev          *event.Event
+		week, month store.History
+		want        string
+	}{
+		{"first", ev, store.History{}, store.History{}, ""},
+		{"this week", ev, store.History{Count: 2, Last: last}, store.History{Count: 2, Last: last},
+			"3rd OOM kill of firefox this week; last one 2d 4h ago"},
+		{"this month too", ev, store.History{Count: 1, Last: last}, store.History{Count: 10, Last: last},
+			"2nd OOM kill of firefox this week (11th in 30 days); last one 2d 4h ago"},
+		{"this month", &event.Event{Timestamp: now, Tier: event.TierServiceFailure, Unit: "backup.service"},
+			store.History{}, store.History{Count: 20, Last: now.Add(-12 * 24 * time.Hour)},
+			"21st failure of backup.service in 30 days; last one 12d 0h ago"},
+		{"no subject", &event.Event{Timestamp: now, Tier: event.TierUnclassified},
+			store.History{}, store.History{Count: 1, Last: now.Add(-90 * time.Minute)},
+			"2nd occurrence in 30 days; last one 1h 30m ago"},
+	}
+	for _, tt := range tests {
+		if got := FormatHistory(tt.ev, tt.week, tt.month); got != tt.want {
+			t.Errorf("%s: FormatHistory = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestTagsForTier(t *testing.T) {
 	if tags := TagsForTier(event.TierOOMKill); tags != "skull,memory" {
 		t.Errorf("T1 tags = %q, want %q", tags, "skull,memory")
@@ -158,3 +190,164 @@ func TestNtfyReporterNoURL(t *testing.T) {
 		t.Fatalf("Report() with no URL should not error, got: %v", err)
 	}
 }
+
+func TestNtfyReporterTemplatedTopic(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Ntfy.URL = server.URL + "/logs-{{.Instance}}-{{.Tier | lower}}"
+	rep := NewNtfy(cfg)
+
+	mk := func(host string, tier event.Tier) *event.Event {
+		return &event.Event{InstanceID: host, Tier: tier, Severity: event.SevHigh, Summary: "x", RawFields: map[string]string{}}
+	}
+	evs := []*event.Event{
+		mk("nas", event.TierOOMKill),
+		mk("nas", event.TierOOMKill),
+		mk("nas", event.TierProcessCrash),
+		mk("desk", event.TierOOMKill),
+	}
+	if err := rep.ReportBatch(context.Background(), evs); err != nil {
+		t.Fatalf("ReportBatch: %v", err)
+	}
+
+	want := map[string]int{"/logs-nas-t1": 1, "/logs-nas-t2": 1, "/logs-desk-t1": 1}
+	if len(paths) != len(want) {
+		t.Fatalf("posted to %v, want %v", paths, want)
+	}
+	for p, n := range want {
+		if paths[p] != n {
+			t.Errorf("%s: %d posts, want %d", p, paths[p], n)
+		}
+	}
+}
+
+func TestNtfyReporterOptions(t *testing.T) {
+	var mu sync.Mutex
+	headers := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Ntfy.URL = server.URL + "/logs"
+	cfg.Ntfy.TierTopics = map[string]string{"t1": server.URL + "/urgent"}
+	cfg.Ntfy.Token = "tk_secret"
+	cfg.Ntfy.Click = "http://dashboard.lan:9247/"
+	cfg.Ntfy.Icon = "https://example.com/icon.png"
+	cfg.Ntfy.Email = "me@example.com"
+	rep := NewNtfy(cfg)
+
+	for _, tier := range []event.Tier{event.TierOOMKill, event.TierProcessCrash} {
+		ev := &event.Event{InstanceID: "h", Tier: tier, Severity: event.SevHigh, Summary: "x", RawFields: map[string]string{}}
+		if err := rep.Report(context.Background(), ev); err != nil {
+			t.Fatalf("Report(%s): %v", tier, err)
+		}
+	}
+
+	if len(headers) != 2 || headers["/urgent"] == nil || headers["/logs"] == nil {
+		t.Fatalf("posted to %v, want /urgent and /logs", headers)
+	}
+	h := headers["/urgent"]
+	checks := map[string]string{
+		"Authorization": "Bearer tk_secret",
+		"Click":         "http://dashboard.lan:9247/",
+		"Icon":          "https://example.com/icon.png",
+		"Email":         "me@example.com",
+	}
+	for name, want := range checks {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	SetNtfyOptions(req, config.NtfyConfig{Username: "alerts", Password: "pw"})
+	if user, pass, ok := req.BasicAuth(); !ok || user != "alerts" || pass != "pw" {
+		t.Errorf("basic auth = %q %q %v", user, pass, ok)
+	}
+}
+
+func TestNtfyReporterAckAction(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actions = append(actions, r.Header.Get("Actions"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Ntfy.URL = server.URL
+	rep := NewNtfy(cfg)
+	ev := &event.Event{ID: "e1", InstanceID: "h", Tier: event.TierOOMKill, Severity: event.SevHigh, Summary: "x", RawFields: map[string]string{}}
+	if err := rep.Report(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Ack = config.AckConfig{Enabled: true, URL: "https://host.example.net:9247", Secret: "s3cret"}
+	if err := rep.Report(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || actions[0] != "" {
+		t.Fatalf("actions = %q, want none without ack", actions)
+	}
+	want := "http, Ack, " + cfg.Ack.Link("e1") + ", method=POST, clear=true"
+	if actions[1] != want {
+		t.Errorf("Actions = %q, want %q", actions[1], want)
+	}
+}
+
+func TestNtfyReporterResolution(t *testing.T) {
+	var title, priority, tags, actions, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title, priority = r.Header.Get("Title"), r.Header.Get("Priority")
+		tags, actions = r.Header.Get("Tags"), r.Header.Get("Actions")
+		buf := make([]byte, 4096)
+		n, _ := r.Body.Read(buf)
+		body = string(buf[:n])
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Ntfy.URL = server.URL
+	cfg.Ntfy.AlertTiers = []string{"T3"}
+	cfg.Ack = config.AckConfig{Enabled: true, URL: "https://host.example.net:9247", Secret: "s3cret"}
+
+	opened := time.Date(2026, 2, 19, 14, 0, 0, 0, time.UTC)
+	inc := &store.Incident{ID: "inc-1", InstanceID: "testhost", Tier: event.TierServiceFailure, OpenedAt: opened, EventCount: 4}
+	alert := &event.Event{ID: "e1", InstanceID: "testhost", Timestamp: opened, Tier: event.TierServiceFailure,
+		Severity: event.SevMedium, Summary: "Service failed: backup.service", Unit: "backup.service"}
+	ev := NewResolution(inc, alert, opened.Add(42*time.Minute))
+
+	if !IsResolution(ev) || IsResolution(alert) {
+		t.Fatal("IsResolution does not tell the notice from the alert")
+	}
+	if ev.Unit != "backup.service" || ev.IncidentID != "inc-1" || ev.Severity != event.SevWarning {
+		t.Errorf("resolution = %+v", ev)
+	}
+	if err := NewNtfy(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(title, "✅") || !strings.Contains(title, "Resolved: Service failed: backup.service") {
+		t.Errorf("title = %q", title)
+	}
+	if priority != "low" || tags != "white_check_mark" || actions != "" {
+		t.Errorf("priority = %q, tags = %q, actions = %q", priority, tags, actions)
+	}
+	if !strings.Contains(body, "Open for 42m, 4 events") || !strings.Contains(body, "inc-1") {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,393 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// otelScope is the instrumentation scope of everything exported.
+const otelScope = "logtriage"
+
+// OTelExporter sends classified events as OpenTelemetry log records, and
+// closed incidents as spans, to an OTLP/HTTP collector in its JSON
+// encoding. Like syslog export it covers every event of the configured
+// tiers, not only those that alert.
+//
+// Records are queued and sent in batches by Run, so a slow or unreachable
+// collector does not hold up the pipeline. A batch that fails for want of
+// a connection, or that the collector throttles or fails on, is kept and
+// sent again with the next one; one the collector rejects is dropped. Records are grouped by instance, whose ID
+// is the resource's host.name. An event's record carries the trace and span
+// ID of its incident's span, so a trace backend can link the two.
+type OTelExporter struct {
+	cfg       *config.Config
+	client    *http.Client
+	logsURL   string
+	tracesURL string
+
+	logs  exportQueue[otelQueued[otlpLogRecord]]
+	spans exportQueue[otelQueued[otlpSpan]]
+}
+
+// otelQueued is a record waiting to be sent, with its instance.
+type otelQueued[T any] struct {
+	instance string
+	record   T
+}
+
+// NewOTel creates an OTelExporter for the collector at otel.endpoint.
+func NewOTel(cfg *config.Config) *OTelExporter {
+	base := strings.TrimRight(cfg.OTel.Endpoint, "/")
+	return &OTelExporter{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		logsURL:   base + "/v1/logs",
+		tracesURL: base + "/v1/traces",
+	}
+}
+
+// Name returns "otel".
+func (x *OTelExporter) Name() string {
+	return "otel"
+}
+
+// Report queues the event as a log record if its tier is selected.
+func (x *OTelExporter) Report(ctx context.Context, ev *event.Event) error {
+	if !x.cfg.OTelShouldSend(string(ev.Tier)) {
+		return nil
+	}
+	x.logs.add(otelQueued[otlpLogRecord]{ev.InstanceID, otelLogRecord(ev, x.cfg.OTel.Spans)})
+	return nil
+}
+
+// ReportIncident queues a closed incident as a span from its opening to
+// its close. Open incidents are skipped, as a span is only sent once it
+// has ended.
+func (x *OTelExporter) ReportIncident(inc *store.Incident) {
+	if !x.cfg.OTel.Spans || inc.IsOpen() {
+		return
+	}
+	x.spans.add(otelQueued[otlpSpan]{inc.InstanceID, otelSpan(inc)})
+}
+
+// Run sends the queued records every otel.batch_interval until ctx is
+// done. Close sends what is left.
+func (x *OTelExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(x.cfg.OTel.BatchInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := x.Flush(ctx); err != nil {
+				slog.Warn("failed to export to OpenTelemetry collector, will retry", "error", err)
+				selfstat.ReporterFailure()
+			}
+		}
+	}
+}
+
+// Close sends the records still queued, giving up after 10 seconds.
+func (x *OTelExporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return x.Flush(ctx)
+}
+
+// Flush sends the queued log records and spans. Those that fail to send
+// stay queued, unless the collector rejected them.
+func (x *OTelExporter) Flush(ctx context.Context) error {
+	logs, droppedLogs := x.logs.take()
+	spans, droppedSpans := x.spans.take()
+	if n := droppedLogs + droppedSpans; n > 0 {
+		slog.Warn("OpenTelemetry export queue full, records dropped", "count", n)
+	}
+
+	var errs []error
+	if len(logs) > 0 {
+		if err := x.post(ctx, x.logsURL, otelLogsRequest(logs)); err != nil {
+			if exportRetryable(err) {
+				errs = append(errs, fmt.Errorf("exporting %d log records: %w", len(logs), err))
+				x.logs.putBack(logs)
+			} else {
+				slog.Warn("OpenTelemetry collector rejected log records, dropping", "count", len(logs), "error", err)
+				selfstat.Drop(len(logs))
+			}
+		}
+	}
+	if len(spans) > 0 {
+		if err := x.post(ctx, x.tracesURL, otelTracesRequest(spans)); err != nil {
+			if exportRetryable(err) {
+				errs = append(errs, fmt.Errorf("exporting %d spans: %w", len(spans), err))
+				x.spans.putBack(spans)
+			} else {
+				slog.Warn("OpenTelemetry collector rejected spans, dropping", "count", len(spans), "error", err)
+				selfstat.Drop(len(spans))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends one OTLP/HTTP JSON request.
+func (x *OTelExporter) post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range x.cfg.OTel.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &exportStatusError{url: url, code: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding. 64-bit integers, including timestamps, are
+// strings, and trace and span IDs are hex rather than base64.
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpValue      `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogs struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// otelSeverity maps event severities to OpenTelemetry severity numbers:
+// FATAL, ERROR, WARN2 and WARN.
+var otelSeverity = map[event.Severity]int{
+	event.SevCritical: 21,
+	event.SevHigh:     17,
+	event.SevMedium:   14,
+	event.SevWarning:  13,
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int) otlpKeyValue {
+	s := strconv.Itoa(value)
+	return otlpKeyValue{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otelIDs derives the trace and span ID of an incident's span from its ID,
+// so its events' log records can name the span before it is sent.
+func otelIDs(incidentID string) (traceID, spanID string) {
+	sum := sha256.Sum256([]byte(incidentID))
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:24])
+}
+
+// otelLogRecord maps an event to a log record. Its optional fields become
+// attributes only when set. With linkSpan, an event in an incident carries
+// the IDs of the incident's span.
+func otelLogRecord(ev *event.Event, linkSpan bool) otlpLogRecord {
+	summary := ev.Summary
+	rec := otlpLogRecord{
+		TimeUnixNano:         otlpTime(ev.Timestamp),
+		ObservedTimeUnixNano: otlpTime(time.Now()),
+		SeverityNumber:       otelSeverity[ev.Severity],
+		SeverityText:         string(ev.Severity),
+		Body:                 otlpValue{StringValue: &summary},
+		Attributes: []otlpKeyValue{
+			otlpString("logtriage.event.id", ev.ID),
+			otlpString("logtriage.tier", string(ev.Tier)),
+		},
+	}
+	optional := []struct{ key, value string }{
+		{"process.executable.name", ev.Process},
+		{"logtriage.unit", ev.Unit},
+		{"container.id", ev.ContainerID},
+		{"container.name", ev.ContainerName},
+		{"logtriage.cgroup", ev.CGroup},
+		{"logtriage.fingerprint", ev.Fingerprint},
+		{"logtriage.detail", ev.Detail},
+	}
+	for _, a := range optional {
+		if a.value != "" {
+			rec.Attributes = append(rec.Attributes, otlpString(a.key, a.value))
+		}
+	}
+	if ev.PID != 0 {
+		rec.Attributes = append(rec.Attributes, otlpInt("process.pid", ev.PID))
+	}
+	if ev.IncidentID != "" {
+		rec.Attributes = append(rec.Attributes, otlpString("logtriage.incident.id", ev.IncidentID))
+		if linkSpan {
+			rec.TraceID, rec.SpanID = otelIDs(ev.IncidentID)
+		}
+	}
+	return rec
+}
+
+// otelSpan maps a closed incident to a span covering it.
+func otelSpan(inc *store.Incident) otlpSpan {
+	traceID, spanID := otelIDs(inc.ID)
+	return otlpSpan{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		Name:              inc.Title,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(inc.OpenedAt),
+		EndTimeUnixNano:   otlpTime(inc.ClosedAt),
+		Attributes: []otlpKeyValue{
+			otlpString("logtriage.incident.id", inc.ID),
+			otlpString("logtriage.tier", string(inc.Tier)),
+			otlpString("logtriage.severity", string(inc.Severity)),
+			otlpInt("logtriage.incident.event_count", inc.EventCount),
+		},
+		Status: otlpStatus{Code: otlpStatusError, Message: inc.Title},
+	}
+}
+
+// otelResource describes the instance records come from.
+func otelResource(instance string) otlpResource {
+	return otlpResource{Attributes: []otlpKeyValue{
+		otlpString("service.name", "logtriage"),
+		otlpString("host.name", instance),
+	}}
+}
+
+// groupOTel splits queued records by instance, in instance order.
+func groupOTel[T any](q []otelQueued[T]) (instances []string, records map[string][]T) {
+	records = make(map[string][]T)
+	for _, item := range q {
+		if _, ok := records[item.instance]; !ok {
+			instances = append(instances, item.instance)
+		}
+		records[item.instance] = append(records[item.instance], item.record)
+	}
+	slices.Sort(instances)
+	return instances, records
+}
+
+func otelLogsRequest(q []otelQueued[otlpLogRecord]) otlpLogs {
+	instances, records := groupOTel(q)
+	var req otlpLogs
+	for _, inst := range instances {
+		req.ResourceLogs = append(req.ResourceLogs, otlpResourceLogs{
+			Resource:  otelResource(inst),
+			ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otelScope}, LogRecords: records[inst]}},
+		})
+	}
+	return req
+}
+
+func otelTracesRequest(q []otelQueued[otlpSpan]) otlpTraces {
+	instances, spans := groupOTel(q)
+	var req otlpTraces
+	for _, inst := range instances {
+		req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+			Resource:   otelResource(inst),
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otelScope}, Spans: spans[inst]}},
+		})
+	}
+	return req
+}
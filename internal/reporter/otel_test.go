@@ -0,0 +1,189 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+func TestOTelExporter(t *testing.T) {
+	var mu sync.Mutex
+	var logs []otlpLogs
+	var traces []otlpTraces
+	fail := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/logs":
+			var req otlpLogs
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding logs: %v", err)
+			}
+			logs = append(logs, req)
+		case "/v1/traces":
+			var req otlpTraces
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding traces: %v", err)
+			}
+			traces = append(traces, req)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.OTel.Enabled = true
+	cfg.OTel.Endpoint = server.URL + "/"
+	cfg.OTel.Headers = map[string]string{"Authorization": "Bearer t0ken"}
+	cfg.OTel.Tiers = []string{"T1", "T2"}
+	x := NewOTel(cfg)
+	ctx := context.Background()
+
+	now := time.Now()
+	oom := event.New("nas", now, event.TierOOMKill, event.SevCritical, "OOM Kill: smbd")
+	oom.Process, oom.PID, oom.IncidentID = "smbd", 4242, "inc-1"
+	segv := event.New("desk", now, event.TierProcessCrash, event.SevHigh, "Segfault: vlc")
+	skipped := event.New("nas", now, event.TierKernelHW, event.SevHigh, "I/O error on sda")
+	for _, ev := range []*event.Event{oom, segv, skipped} {
+		if err := x.Report(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := &store.Incident{ID: "inc-2", InstanceID: "nas", Title: "still open", OpenedAt: now}
+	closed := &store.Incident{ID: "inc-1", InstanceID: "nas", Tier: event.TierOOMKill, Severity: event.SevCritical,
+		Title: "OOM Kill: smbd", OpenedAt: now.Add(-time.Minute), ClosedAt: now, EventCount: 3}
+	x.ReportIncident(open)
+	x.ReportIncident(closed)
+
+	// A failed batch stays queued for the next flush.
+	if err := x.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded against a failing collector")
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	if err := x.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || len(traces) != 1 {
+		t.Fatalf("got %d logs and %d traces requests, want 1 each", len(logs), len(traces))
+	}
+	rl := logs[0].ResourceLogs
+	if len(rl) != 2 {
+		t.Fatalf("got %d resources, want 2", len(rl))
+	}
+	if host := otelAttr(rl[0].Resource.Attributes, "host.name"); host != "desk" {
+		t.Errorf("first resource host.name = %q, want desk", host)
+	}
+	recs := rl[1].ScopeLogs[0].LogRecords
+	if len(recs) != 1 {
+		t.Fatalf("got %d records for nas, want 1", len(recs))
+	}
+	rec := recs[0]
+	if *rec.Body.StringValue != "OOM Kill: smbd" || rec.SeverityNumber != 21 || rec.SeverityText != "critical" {
+		t.Errorf("record = %q, severity %d %q", *rec.Body.StringValue, rec.SeverityNumber, rec.SeverityText)
+	}
+	if pid := otelAttr(rec.Attributes, "process.pid"); pid != "4242" {
+		t.Errorf("process.pid = %q, want 4242", pid)
+	}
+	if otelAttr(rec.Attributes, "logtriage.event.id") != oom.ID {
+		t.Errorf("logtriage.event.id missing")
+	}
+
+	spans := traces[0].ResourceSpans
+	if len(spans) != 1 || len(spans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("got %+v, want the closed incident only", spans)
+	}
+	span := spans[0].ScopeSpans[0].Spans[0]
+	if span.Name != "OOM Kill: smbd" || span.TraceID != rec.TraceID || span.SpanID != rec.SpanID {
+		t.Errorf("span %q %s/%s, record %s/%s", span.Name, span.TraceID, span.SpanID, rec.TraceID, rec.SpanID)
+	}
+	if len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+		t.Errorf("trace ID %q, span ID %q: wrong length", span.TraceID, span.SpanID)
+	}
+	if n := otelAttr(span.Attributes, "logtriage.incident.event_count"); n != "3" {
+		t.Errorf("event_count = %q, want 3", n)
+	}
+}
+
+// otelAttr returns the attribute's value as a string, or "" if missing.
+func TestOTelDropsRejectedBatch(t *testing.T) {
+	var mu sync.Mutex
+	posts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts[r.URL.Path]++
+		// The collector rejects log records and is throttling traces.
+		if r.URL.Path == "/v1/logs" {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.OTel.Enabled = true
+	cfg.OTel.Endpoint = server.URL
+	cfg.OTel.Tiers = []string{"T1"}
+	x := NewOTel(cfg)
+	ctx := context.Background()
+
+	if err := x.Report(ctx, event.New("nas", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: smbd")); err != nil {
+		t.Fatal(err)
+	}
+	x.ReportIncident(&store.Incident{ID: "inc-1", InstanceID: "nas", Title: "OOM Kill: smbd",
+		OpenedAt: time.Now().Add(-time.Minute), ClosedAt: time.Now()})
+
+	if err := x.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded against a throttling collector")
+	}
+	if logs, _ := x.logs.take(); len(logs) != 0 {
+		t.Errorf("%d log records requeued after a 400, want 0", len(logs))
+	}
+	spans, _ := x.spans.take()
+	if len(spans) != 1 {
+		t.Errorf("%d spans requeued after a 429, want 1", len(spans))
+	}
+	if posts["/v1/logs"] != 1 || posts["/v1/traces"] != 1 {
+		t.Errorf("posts = %v", posts)
+	}
+}
+
+func otelAttr(attrs []otlpKeyValue, key string) string {
+	for _, a := range attrs {
+		if a.Key != key {
+			continue
+		}
+		if a.Value.StringValue != nil {
+			return *a.Value.StringValue
+		}
+		if a.Value.IntValue != nil {
+			return *a.Value.IntValue
+		}
+	}
+	return ""
+}
@@ -0,0 +1,276 @@
+// Package reporter delivers event notifications and digests to external sinks.
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Reporter delivers event notifications to a single sink.
+type Reporter interface {
+	// Name identifies the sink in logs (e.g. "ntfy", "slack").
+	Name() string
+
+	// Report sends a notification for the event. Implementations decide
+	// for themselves whether the event's tier is alert-worthy.
+	Report(ctx context.Context, ev *event.Event) error
+}
+
+// BatchReporter is a Reporter that can merge several events into a single
+// notification.
+type BatchReporter interface {
+	Reporter
+
+	// Accepts reports whether the sink would deliver a notification for
+	// the event (i.e. it is configured and the tier is alert-worthy).
+	Accepts(ev *event.Event) bool
+
+	// ReportBatch sends one notification summarizing all of evs. Callers
+	// only pass events the sink accepts.
+	ReportBatch(ctx context.Context, evs []*event.Event) error
+}
+
+// Flusher is implemented by reporters that hold notifications back and
+// must deliver them before shutdown.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// TransitionKind names a stage in an event's lifecycle.
+type TransitionKind string
+
+const (
+	// TransitionCreated is the first alert for a new problem.
+	TransitionCreated TransitionKind = "created"
+	// TransitionAggregated fires when repeats reach the aggregate threshold.
+	TransitionAggregated TransitionKind = "aggregated"
+	// TransitionEscalated fires when a repeat is more severe than before.
+	TransitionEscalated TransitionKind = "escalated"
+	// TransitionAcked fires when someone acknowledges the event.
+	TransitionAcked TransitionKind = "acked"
+	// TransitionResolved fires when the underlying problem clears. Its
+	// event is the resolution notice; see NewResolution.
+	TransitionResolved TransitionKind = "resolved"
+)
+
+// Transition describes a lifecycle change for an event.
+type Transition struct {
+	Kind TransitionKind
+	// Count is the number of similar events in the cooldown window.
+	Count int
+}
+
+// Alerting reports whether the transition warrants a notification on sinks
+// that only understand plain alerts.
+func (t Transition) Alerting() bool {
+	switch t.Kind {
+	case TransitionCreated, TransitionAggregated, TransitionEscalated, TransitionResolved:
+		return true
+	default:
+		return false
+	}
+}
+
+// TransitionReporter is implemented by sinks that track the full event
+// lifecycle rather than only new alerts.
+type TransitionReporter interface {
+	ReportTransition(ctx context.Context, t Transition, ev *event.Event) error
+}
+
+// Multi fans out notifications to several reporters.
+type Multi struct {
+	reporters []Reporter
+}
+
+// NewMulti creates a Multi that reports to each of the given reporters.
+func NewMulti(reporters ...Reporter) *Multi {
+	return &Multi{reporters: reporters}
+}
+
+// Name returns "multi".
+func (m *Multi) Name() string {
+	return "multi"
+}
+
+// Sinks returns the names of the reporters.
+func (m *Multi) Sinks() []string {
+	names := make([]string, len(m.reporters))
+	for i, r := range m.reporters {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+// SinkError is a delivery failure of one of a Multi's sinks.
+type SinkError struct {
+	Sink string
+	Err  error
+}
+
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Sink, e.Err)
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// FailedSinks returns the names of the sinks that failed in an error
+// returned by Multi.
+func FailedSinks(err error) []string {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+	var sinks []string
+	for _, err := range errs {
+		var se *SinkError
+		if errors.As(err, &se) {
+			sinks = append(sinks, se.Sink)
+		}
+	}
+	return sinks
+}
+
+// Report sends the event to every reporter. A failure in one sink does not
+// prevent delivery to the others; all errors are joined and returned, each
+// as a *SinkError.
+func (m *Multi) Report(ctx context.Context, ev *event.Event) error {
+	var errs []error
+	for _, r := range m.reporters {
+		if err := r.Report(ctx, ev); err != nil {
+			errs = append(errs, &SinkError{Sink: r.Name(), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReportTransition delivers a lifecycle transition. Sinks implementing
+// TransitionReporter receive every transition; other sinks receive alerting
+// transitions as ordinary reports. Errors are as for Report.
+func (m *Multi) ReportTransition(ctx context.Context, t Transition, ev *event.Event) error {
+	var errs []error
+	for _, r := range m.reporters {
+		if err := reportTransition(ctx, r, t, ev); err != nil {
+			errs = append(errs, &SinkError{Sink: r.Name(), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notification is an event with the transition it is notified for.
+type Notification struct {
+	Transition Transition
+	Event      *event.Event
+}
+
+// ReportBatch delivers several notifications at once, such as those held
+// back during quiet hours. Each batching-capable sink gets one message
+// summarizing the alerting events it accepts, bypassing its batching
+// window; other sinks get each transition as from ReportTransition. Errors
+// are as for Report.
+func (m *Multi) ReportBatch(ctx context.Context, ns []Notification) error {
+	var errs []error
+	for _, r := range m.reporters {
+		if b, ok := r.(*Batcher); ok {
+			r = b.inner
+		}
+		var err error
+		if br, ok := r.(BatchReporter); ok {
+			var evs []*event.Event
+			for _, n := range ns {
+				if n.Transition.Alerting() && br.Accepts(n.Event) {
+					evs = append(evs, n.Event)
+				}
+			}
+			switch len(evs) {
+			case 0:
+			case 1:
+				err = br.Report(ctx, evs[0])
+			default:
+				err = br.ReportBatch(ctx, evs)
+			}
+		} else {
+			var sinkErrs []error
+			for _, n := range ns {
+				sinkErrs = append(sinkErrs, reportTransition(ctx, r, n.Transition, n.Event))
+			}
+			err = errors.Join(sinkErrs...)
+		}
+		if err != nil {
+			errs = append(errs, &SinkError{Sink: r.Name(), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Retry delivers a transition to the named sink only, bypassing any
+// batching window so a failure is returned rather than held. It is for
+// resending a notification that sink failed to deliver.
+func (m *Multi) Retry(ctx context.Context, sink string, t Transition, ev *event.Event) error {
+	for _, r := range m.reporters {
+		if r.Name() != sink {
+			continue
+		}
+		if b, ok := r.(*Batcher); ok {
+			r = b.inner
+		}
+		return reportTransition(ctx, r, t, ev)
+	}
+	return fmt.Errorf("no %s sink configured", sink)
+}
+
+// OnBatchFailure sets f on every batching sink to receive the events of a
+// merged message that failed; see Batcher.OnFailure.
+func (m *Multi) OnBatchFailure(f func(sink string, evs []*event.Event, err error)) {
+	for _, r := range m.reporters {
+		if b, ok := r.(*Batcher); ok {
+			name := b.Name()
+			b.OnFailure(func(evs []*event.Event, err error) { f(name, evs, err) })
+		}
+	}
+}
+
+// OnAttempt sets f to receive every delivery a sink attempts, whether it
+// succeeded or not, such as for an audit log. Deliveries a sink skips,
+// e.g. for a tier it does not alert on, are not attempts. Merged messages
+// are reported once, with all their events. Set it before use.
+func (m *Multi) OnAttempt(f func(Attempt)) {
+	for i, r := range m.reporters {
+		if b, ok := r.(*Batcher); ok {
+			b.inner = audit(b.inner, f).(BatchReporter)
+		} else {
+			m.reporters[i] = audit(r, f)
+		}
+	}
+}
+
+func reportTransition(ctx context.Context, r Reporter, t Transition, ev *event.Event) error {
+	if tr, ok := r.(TransitionReporter); ok {
+		return tr.ReportTransition(ctx, t, ev)
+	}
+	if t.Alerting() {
+		return r.Report(ctx, ev)
+	}
+	return nil
+}
+
+// Flush flushes every reporter that buffers notifications.
+func (m *Multi) Flush(ctx context.Context) error {
+	var errs []error
+	for _, r := range m.reporters {
+		f, ok := r.(Flusher)
+		if !ok {
+			continue
+		}
+		if err := f.Flush(ctx); err != nil {
+			errs = append(errs, &SinkError{Sink: r.Name(), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,204 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// severityColor maps event severity to Slack attachment colors.
+var severityColor = map[event.Severity]string{
+	event.SevCritical: "#d00000",
+	event.SevHigh:     "#ff8c00",
+	event.SevMedium:   "#f2c744",
+	event.SevWarning:  "#439fe0",
+}
+
+// SlackReporter posts event notifications to a Slack or Mattermost
+// incoming webhook. Both accept the same legacy attachment format.
+type SlackReporter struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewSlack creates a new SlackReporter.
+func NewSlack(cfg *config.Config) *SlackReporter {
+	return &SlackReporter{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Name returns "slack".
+func (r *SlackReporter) Name() string {
+	return "slack"
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text,omitempty"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Footer   string       `json:"footer,omitempty"`
+	Ts       int64        `json:"ts"`
+}
+
+type slackPayload struct {
+	Username    string            `json:"username,omitempty"`
+	Channel     string            `json:"channel,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// Accepts reports whether the webhook is configured and the event's tier is
+// in the Slack alert tiers.
+func (r *SlackReporter) Accepts(ev *event.Event) bool {
+	return r.cfg.Slack.WebhookURL != "" && r.cfg.SlackShouldAlert(string(ev.Tier))
+}
+
+// Report posts an event to the configured webhook if the event's tier is in
+// the Slack alert tiers.
+func (r *SlackReporter) Report(ctx context.Context, ev *event.Event) error {
+	if r.cfg.Slack.WebhookURL == "" {
+		slog.Debug("slack webhook not configured, skipping notification")
+		return nil
+	}
+
+	if !r.cfg.SlackShouldAlert(string(ev.Tier)) {
+		slog.Debug("event tier not in slack alert tiers, skipping", "tier", ev.Tier)
+		return nil
+	}
+
+	if err := r.post(ctx, buildSlackPayload(r.cfg, ev)); err != nil {
+		return err
+	}
+
+	slog.Info("slack notification sent", "tier", ev.Tier, "summary", ev.Summary)
+	return nil
+}
+
+// ReportBatch posts one message listing all events.
+func (r *SlackReporter) ReportBatch(ctx context.Context, evs []*event.Event) error {
+	if err := r.post(ctx, buildSlackBatchPayload(r.cfg, evs)); err != nil {
+		return err
+	}
+
+	slog.Info("batched slack notification sent", "events", len(evs))
+	return nil
+}
+
+// post sends a payload to the configured webhook.
+func (r *SlackReporter) post(ctx context.Context, payload slackPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding slack payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Slack.WebhookURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	noteSend(ctx)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending slack notification: %w", err)
+	}
+	defer resp.Body.Close()
+	noteStatus(ctx, resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// buildSlackPayload builds the webhook payload for an event: a single
+// attachment color-coded by severity with host/tier/unit/process fields.
+func buildSlackPayload(cfg *config.Config, ev *event.Event) slackPayload {
+	color := slackColor(ev.Severity)
+	if IsResolution(ev) {
+		color = "#2eb886" // green
+	}
+
+	fields := []slackField{
+		{Title: "Host", Value: ev.InstanceID, Short: true},
+		{Title: "Tier", Value: fmt.Sprintf("%s %s", ev.Tier, ev.Tier.Label()), Short: true},
+		{Title: "Severity", Value: ev.Severity.Label(), Short: true},
+	}
+	if ev.Unit != "" {
+		fields = append(fields, slackField{Title: "Unit", Value: ev.Unit, Short: true})
+	}
+	if ev.Process != "" {
+		proc := ev.Process
+		if ev.PID > 0 {
+			proc = fmt.Sprintf("%s (pid %d)", ev.Process, ev.PID)
+		}
+		fields = append(fields, slackField{Title: "Process", Value: proc, Short: true})
+	}
+
+	text := ""
+	if ev.Detail != "" {
+		text = "```\n" + ev.Detail + "\n```"
+	}
+
+	return slackPayload{
+		Username:  cfg.Slack.Username,
+		Channel:   cfg.Slack.Channel,
+		IconEmoji: cfg.Slack.IconEmoji,
+		Attachments: []slackAttachment{{
+			Fallback: FormatTitle(ev),
+			Color:    color,
+			Title:    FormatTitle(ev),
+			Text:     text,
+			Fields:   fields,
+			Footer:   "logtriage",
+			Ts:       ev.Timestamp.Unix(),
+		}},
+	}
+}
+
+// buildSlackBatchPayload builds a single attachment listing several events,
+// colored by the most severe one.
+func buildSlackBatchPayload(cfg *config.Config, evs []*event.Event) slackPayload {
+	top := MostSevere(evs)
+	title := FormatBatchTitle(evs)
+
+	return slackPayload{
+		Username:  cfg.Slack.Username,
+		Channel:   cfg.Slack.Channel,
+		IconEmoji: cfg.Slack.IconEmoji,
+		Attachments: []slackAttachment{{
+			Fallback: title,
+			Color:    slackColor(top.Severity),
+			Title:    title,
+			Text:     FormatBatchBody(evs),
+			Footer:   "logtriage",
+			Ts:       evs[len(evs)-1].Timestamp.Unix(),
+		}},
+	}
+}
+
+func slackColor(sev event.Severity) string {
+	if color, ok := severityColor[sev]; ok {
+		return color
+	}
+	return "#808080"
+}
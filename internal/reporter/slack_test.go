@@ -0,0 +1,131 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestBuildSlackPayload(t *testing.T) {
+	cfg := config.Default()
+	cfg.Slack.Channel = "#alerts"
+
+	ev := &event.Event{
+		InstanceID: "nas",
+		Timestamp:  time.Date(2026, 2, 19, 14, 32, 5, 0, time.UTC),
+		Tier:       event.TierServiceFailure,
+		Severity:   event.SevMedium,
+		Summary:    "Service failed: smbd.service",
+		Unit:       "smbd.service",
+		Detail:     "smbd.service failed.",
+	}
+
+	p := buildSlackPayload(cfg, ev)
+	if p.Channel != "#alerts" {
+		t.Errorf("channel = %q, want #alerts", p.Channel)
+	}
+	if len(p.Attachments) != 1 {
+		t.Fatalf("attachments = %d, want 1", len(p.Attachments))
+	}
+
+	a := p.Attachments[0]
+	if a.Color != "#f2c744" {
+		t.Errorf("color = %q, want medium color", a.Color)
+	}
+	if a.Ts != ev.Timestamp.Unix() {
+		t.Errorf("ts = %d, want %d", a.Ts, ev.Timestamp.Unix())
+	}
+
+	fields := make(map[string]string)
+	for _, f := range a.Fields {
+		fields[f.Title] = f.Value
+	}
+	if fields["Host"] != "nas" {
+		t.Errorf("Host field = %q, want nas", fields["Host"])
+	}
+	if fields["Unit"] != "smbd.service" {
+		t.Errorf("Unit field = %q, want smbd.service", fields["Unit"])
+	}
+	if _, ok := fields["Process"]; ok {
+		t.Error("Process field should be omitted when empty")
+	}
+}
+
+func TestSlackReporterSend(t *testing.T) {
+	var got slackPayload
+	var contentType string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Slack.WebhookURL = server.URL
+
+	ev := &event.Event{
+		InstanceID: "workstation",
+		Timestamp:  time.Now(),
+		Tier:       event.TierOOMKill,
+		Severity:   event.SevCritical,
+		Summary:    "OOM Kill: firefox (pid 4521)",
+		Process:    "firefox",
+		PID:        4521,
+		RawFields:  map[string]string{},
+	}
+
+	if err := NewSlack(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].Color != "#d00000" {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
+
+func TestSlackAlertTiersFallback(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Slack.WebhookURL = server.URL
+	cfg.Ntfy.AlertTiers = []string{"T1"}
+
+	ev := &event.Event{
+		Tier:      event.TierProcessCrash,
+		Severity:  event.SevHigh,
+		RawFields: map[string]string{},
+	}
+
+	if err := NewSlack(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+	if called {
+		t.Error("slack should inherit ntfy alert tiers when its own are unset")
+	}
+
+	cfg.Slack.AlertTiers = []string{"T2"}
+	if err := NewSlack(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+	if !called {
+		t.Error("slack.alert_tiers should override ntfy alert tiers")
+	}
+}
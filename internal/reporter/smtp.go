@@ -0,0 +1,164 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// SMTPReporter sends event notifications and digests by email.
+type SMTPReporter struct {
+	cfg     *config.Config
+	timeout time.Duration
+}
+
+// NewSMTP creates a new SMTPReporter.
+func NewSMTP(cfg *config.Config) *SMTPReporter {
+	return &SMTPReporter{
+		cfg:     cfg,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Name returns "email".
+func (r *SMTPReporter) Name() string {
+	return "email"
+}
+
+// Accepts reports whether email is configured and the event's tier is in the
+// email alert tiers.
+func (r *SMTPReporter) Accepts(ev *event.Event) bool {
+	return r.cfg.Email.Host != "" && len(r.cfg.Email.To) > 0 && r.cfg.EmailShouldAlert(string(ev.Tier))
+}
+
+// Report emails an event notification if the event's tier is in the email
+// alert tiers.
+func (r *SMTPReporter) Report(ctx context.Context, ev *event.Event) error {
+	if r.cfg.Email.Host == "" || len(r.cfg.Email.To) == 0 {
+		slog.Debug("email not configured, skipping notification")
+		return nil
+	}
+
+	if !r.cfg.EmailShouldAlert(string(ev.Tier)) {
+		slog.Debug("event tier not in email alert tiers, skipping", "tier", ev.Tier)
+		return nil
+	}
+
+	if err := r.send(ctx, FormatTitle(ev), FormatBody(ev)); err != nil {
+		return err
+	}
+
+	slog.Info("email notification sent", "tier", ev.Tier, "summary", ev.Summary)
+	return nil
+}
+
+// ReportBatch sends one email listing all events.
+func (r *SMTPReporter) ReportBatch(ctx context.Context, evs []*event.Event) error {
+	if err := r.send(ctx, FormatBatchTitle(evs), FormatBatchBody(evs)); err != nil {
+		return err
+	}
+
+	slog.Info("batched email notification sent", "events", len(evs))
+	return nil
+}
+
+// SendDigest emails a formatted digest.
+func (r *SMTPReporter) SendDigest(ctx context.Context, title, body string) error {
+	if r.cfg.Email.Host == "" || len(r.cfg.Email.To) == 0 {
+		return fmt.Errorf("email.host and email.to must be configured")
+	}
+	return r.send(ctx, title, body)
+}
+
+// send delivers a single plain-text message to all configured recipients.
+func (r *SMTPReporter) send(ctx context.Context, subject, body string) error {
+	ec := r.cfg.Email
+	addr := net.JoinHostPort(ec.Host, strconv.Itoa(ec.Port))
+
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	noteSend(ctx)
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	tlsConfig := &tls.Config{ServerName: ec.Host}
+	if ec.TLS == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, ec.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if ec.TLS == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if ec.Username != "" {
+		auth := smtp.PlainAuth("", ec.Username, ec.Password, ec.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(ec.From); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range ec.To {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(buildMessage(ec.From, ec.To, subject, body, time.Now())); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// buildMessage renders an RFC 5322 plain-text message. The subject is
+// Q-encoded since titles carry emoji.
+func buildMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
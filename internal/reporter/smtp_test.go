@@ -0,0 +1,152 @@
+package reporter
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestBuildMessage(t *testing.T) {
+	date := time.Date(2026, 2, 19, 14, 32, 5, 0, time.UTC)
+	msg := string(buildMessage("logtriage@nas", []string{"a@example.com", "b@example.com"},
+		"\U0001f534 [nas] OOM Kill", "line one\nline two", date))
+
+	if !strings.Contains(msg, "To: a@example.com, b@example.com\r\n") {
+		t.Errorf("missing To header: %q", msg)
+	}
+	if !strings.Contains(msg, "Subject: =?utf-8?q?") {
+		t.Errorf("subject should be Q-encoded: %q", msg)
+	}
+	if !strings.Contains(msg, "Date: Thu, 19 Feb 2026 14:32:05 +0000\r\n") {
+		t.Errorf("missing Date header: %q", msg)
+	}
+	if !strings.HasSuffix(msg, "\r\n\r\nline one\r\nline two") {
+		t.Errorf("body should use CRLF line endings: %q", msg)
+	}
+}
+
+// fakeSMTP runs a minimal plaintext SMTP server that accepts one message
+// and sends its DATA section on the returned channel.
+func fakeSMTP(t *testing.T) (host string, port int, data <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+
+		var msg strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					ch <- msg.String()
+					reply("250 OK")
+					continue
+				}
+				msg.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 fake")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestSMTPReporterSend(t *testing.T) {
+	host, port, data := fakeSMTP(t)
+
+	cfg := config.Default()
+	cfg.Email.Host = host
+	cfg.Email.Port = port
+	cfg.Email.TLS = "none"
+	cfg.Email.From = "logtriage@testhost"
+	cfg.Email.To = []string{"me@example.com"}
+
+	ev := &event.Event{
+		InstanceID: "testhost",
+		Timestamp:  time.Now(),
+		Tier:       event.TierOOMKill,
+		Severity:   event.SevCritical,
+		Summary:    "OOM Kill: firefox (pid 4521)",
+		Detail:     "Firefox was killed by OOM killer.",
+		RawFields:  map[string]string{},
+	}
+
+	if err := NewSMTP(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() error: %v", err)
+	}
+
+	select {
+	case msg := <-data:
+		if !strings.Contains(msg, "Firefox was killed") {
+			t.Errorf("message body missing detail: %q", msg)
+		}
+		if !strings.Contains(msg, "From: logtriage@testhost") {
+			t.Errorf("message missing From header: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by fake SMTP server")
+	}
+}
+
+func TestSMTPReporterSkipsNonAlertTier(t *testing.T) {
+	cfg := config.Default()
+	cfg.Email.Host = "127.0.0.1"
+	cfg.Email.Port = 1 // would fail if dialed
+	cfg.Email.To = []string{"me@example.com"}
+
+	ev := &event.Event{
+		Tier:      event.TierMemPressure,
+		Severity:  event.SevWarning,
+		RawFields: map[string]string{},
+	}
+
+	if err := NewSMTP(cfg).Report(context.Background(), ev); err != nil {
+		t.Fatalf("Report() for non-alert tier should not dial, got: %v", err)
+	}
+}
+
+func TestSMTPSendDigestRequiresConfig(t *testing.T) {
+	cfg := config.Default()
+	if err := NewSMTP(cfg).SendDigest(context.Background(), "title", "body"); err == nil {
+		t.Error("SendDigest without host/to should error")
+	}
+}
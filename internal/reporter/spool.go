@@ -0,0 +1,171 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// spool is an on-disk FIFO of events, one JSON file per event. File names
+// start with a zero-padded nanosecond timestamp so lexical order is arrival
+// order, and the queue survives restarts.
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	files []spoolFile // oldest first
+	size  int64
+	last  int64 // last sequence number handed out
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// openSpool opens (creating if needed) the spool directory and indexes any
+// events left over from a previous run.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading spool directory: %w", err)
+	}
+
+	s := &spool{dir: dir, maxBytes: maxBytes}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s.files = append(s.files, spoolFile{name: e.Name(), size: info.Size()})
+		s.size += info.Size()
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].name < s.files[j].name })
+
+	return s, nil
+}
+
+// push appends an event to the spool, dropping the oldest events if the
+// spool would exceed its size limit.
+func (s *spool) push(ev *event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := time.Now().UnixNano()
+	if seq <= s.last {
+		seq = s.last + 1
+	}
+	s.last = seq
+
+	name := fmt.Sprintf("%020d-%s.json", seq, ev.ID)
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("writing spool file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing spool file: %w", err)
+	}
+
+	s.files = append(s.files, spoolFile{name: name, size: int64(len(data))})
+	s.size += int64(len(data))
+
+	dropped := 0
+	for s.maxBytes > 0 && s.size > s.maxBytes && len(s.files) > 1 {
+		s.deleteLocked(s.files[0])
+		s.files = s.files[1:]
+		dropped++
+	}
+	if dropped > 0 {
+		selfstat.Drop(dropped)
+		slog.Warn("spool full, dropped oldest events", "dropped", dropped, "max_bytes", s.maxBytes)
+	}
+	return nil
+}
+
+// peek returns up to n of the oldest events, with the names of their
+// files to remove them by once sent. Unreadable files are discarded.
+func (s *spool) peek(n int) ([]*event.Event, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var evs []*event.Event
+	var names []string
+	for len(evs) < n && len(evs) < len(s.files) {
+		f := s.files[len(evs)]
+		data, err := os.ReadFile(filepath.Join(s.dir, f.name))
+		var ev event.Event
+		if err == nil {
+			err = json.Unmarshal(data, &ev)
+		}
+		if err != nil {
+			slog.Warn("discarding unreadable spool file", "file", f.name, "error", err)
+			s.files = append(s.files[:len(evs)], s.files[len(evs)+1:]...)
+			s.size -= f.size
+			os.Remove(filepath.Join(s.dir, f.name))
+			continue
+		}
+		evs = append(evs, &ev)
+		names = append(names, f.name)
+	}
+	return evs, names
+}
+
+// remove deletes the events of the named files. Those already gone, such
+// as ones a push dropped for room since they were peeked, are skipped.
+func (s *spool) remove(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gone := make(map[string]bool, len(names))
+	for _, name := range names {
+		gone[name] = true
+	}
+	kept := s.files[:0]
+	for _, f := range s.files {
+		if !gone[f.name] {
+			kept = append(kept, f)
+			continue
+		}
+		s.deleteLocked(f)
+	}
+	s.files = kept
+}
+
+// deleteLocked removes f's file and takes it out of the spool's size; the
+// caller takes it out of s.files.
+func (s *spool) deleteLocked(f spoolFile) {
+	if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("removing spool file", "file", f.name, "error", err)
+	}
+	s.size -= f.size
+}
+
+// len returns the number of spooled events.
+func (s *spool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
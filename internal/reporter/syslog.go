@@ -0,0 +1,189 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// syslogSDID is the structured data ID of the event fields. 32473 is the
+// private enterprise number reserved for examples (RFC 5612).
+const syslogSDID = "logtriage@32473"
+
+// syslogDialTimeout bounds connecting to a remote collector.
+const syslogDialTimeout = 10 * time.Second
+
+// syslogFacilities maps facility names to their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogReporter re-emits events as RFC 5424 syslog messages to the local
+// syslog socket or a remote collector. Like forwarding to a hub, it sends
+// every event of the configured tiers, not only those that alert, so the
+// collector sees the full classified history.
+type SyslogReporter struct {
+	cfg      *config.Config
+	facility int
+	pid      int
+
+	mu   sync.Mutex
+	conn net.Conn // nil until the first send, and after a write error
+}
+
+// NewSyslog creates a SyslogReporter. It fails on an unknown network or
+// facility; the connection is made on the first event.
+func NewSyslog(cfg *config.Config) (*SyslogReporter, error) {
+	switch cfg.Syslog.Network {
+	case "unix", "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("syslog.network %q: must be unix, udp, or tcp", cfg.Syslog.Network)
+	}
+	facility, ok := syslogFacilities[cfg.Syslog.Facility]
+	if !ok {
+		return nil, fmt.Errorf("syslog.facility %q: unknown facility", cfg.Syslog.Facility)
+	}
+	return &SyslogReporter{cfg: cfg, facility: facility, pid: os.Getpid()}, nil
+}
+
+// Name returns "syslog".
+func (r *SyslogReporter) Name() string {
+	return "syslog"
+}
+
+// Report sends the event if its tier is selected. A broken connection is
+// redialled once before giving up.
+func (r *SyslogReporter) Report(ctx context.Context, ev *event.Event) error {
+	if !r.cfg.SyslogShouldSend(string(ev.Tier)) {
+		return nil
+	}
+	msg := r.format(ev)
+	if r.cfg.Syslog.Network == "tcp" {
+		// Octet-counting framing (RFC 6587), so messages may hold newlines.
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if r.conn == nil {
+			if r.conn, err = r.dial(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err = r.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		r.conn.Close()
+		r.conn = nil
+	}
+	return fmt.Errorf("writing to syslog: %w", err)
+}
+
+// Close closes the connection, if any.
+func (r *SyslogReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func (r *SyslogReporter) dial(ctx context.Context) (net.Conn, error) {
+	network := r.cfg.Syslog.Network
+	if network == "unix" {
+		network = "unixgram" // /dev/log is a datagram socket
+	}
+	d := net.Dialer{Timeout: syslogDialTimeout}
+	conn, err := d.DialContext(ctx, network, r.cfg.Syslog.Address)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog at %s: %w", r.cfg.Syslog.Address, err)
+	}
+	return conn, nil
+}
+
+// format renders ev as an RFC 5424 message. The host name is the event's
+// instance, so events a hub re-emits keep their origin; the message ID is
+// the tier and the structured data carries the event fields.
+//
+//	<130>1 2026-02-19T14:32:05.123Z nas logtriage 812 T1 [logtriage@32473 id="..." ...] OOM Kill: smbd (pid 4521)
+func (r *SyslogReporter) format(ev *event.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s logtriage %d %s ",
+		r.facility*8+syslogSeverity(ev.Severity),
+		ev.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		syslogHeaderField(ev.InstanceID),
+		r.pid,
+		syslogHeaderField(string(ev.Tier)))
+
+	b.WriteString("[" + syslogSDID)
+	param := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, name, syslogEscaper.Replace(value))
+		}
+	}
+	param("id", ev.ID)
+	param("tier", string(ev.Tier))
+	param("severity", string(ev.Severity))
+	param("process", ev.Process)
+	if ev.PID > 0 {
+		param("pid", strconv.Itoa(ev.PID))
+	}
+	param("unit", ev.Unit)
+	param("container", ev.ContainerName)
+	param("incident", ev.IncidentID)
+	param("suppressed", ev.RawFields["_suppressed"])
+	b.WriteString("] ")
+
+	b.WriteString(ev.Summary)
+	return b.String()
+}
+
+// syslogEscaper escapes structured data parameter values (RFC 5424 6.3.3).
+var syslogEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderField returns s as a header field: printable ASCII without
+// spaces, or "-" when empty.
+func syslogHeaderField(s string) string {
+	s = strings.Map(func(c rune) rune {
+		if c <= ' ' || c > '~' {
+			return '_'
+		}
+		return c
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogSeverity maps an event severity to a syslog severity code:
+// critical to crit, high to err, medium to warning, warning to notice.
+func syslogSeverity(s event.Severity) int {
+	switch s {
+	case event.SevCritical:
+		return 2
+	case event.SevHigh:
+		return 3
+	case event.SevMedium:
+		return 4
+	default:
+		return 5
+	}
+}
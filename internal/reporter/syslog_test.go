@@ -0,0 +1,125 @@
+package reporter
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	cfg := config.Default()
+	cfg.Syslog.Facility = "daemon"
+	r, err := NewSyslog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.pid = 812
+
+	ts := time.Date(2026, 2, 19, 14, 32, 5, 123000000, time.UTC)
+	ev := event.New("nas box", ts, event.TierOOMKill, event.SevCritical, "OOM Kill: smbd (pid 4521)")
+	ev.ID = "e1"
+	ev.Process = "smbd"
+	ev.PID = 4521
+	ev.Unit = `smb"d].service`
+
+	want := `<26>1 2026-02-19T14:32:05.123000Z nas_box logtriage 812 T1 ` +
+		`[logtriage@32473 id="e1" tier="T1" severity="critical" process="smbd" pid="4521" unit="smb\"d\].service"] ` +
+		`OOM Kill: smbd (pid 4521)`
+	if got := r.format(ev); got != want {
+		t.Errorf("format =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSyslogConfigErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Syslog.Facility = "local9"
+	if _, err := NewSyslog(cfg); err == nil {
+		t.Error("unknown facility accepted")
+	}
+	cfg = config.Default()
+	cfg.Syslog.Network = "tls"
+	if _, err := NewSyslog(cfg); err == nil {
+		t.Error("unknown network accepted")
+	}
+}
+
+func TestSyslogUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	cfg := config.Default()
+	cfg.Syslog.Address = path
+	cfg.Syslog.Tiers = []string{"T1"}
+	r, err := NewSyslog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Report(ctx, event.New("nas", time.Now(), event.TierMemPressure, event.SevWarning, "pressure")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Report(ctx, event.New("nas", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: smbd")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The T5 event is not in the tiers, so the first datagram is the OOM.
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<130>1 ") || !strings.HasSuffix(msg, "] OOM Kill: smbd") {
+		t.Errorf("datagram = %q", msg)
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cfg := config.Default()
+	cfg.Syslog.Network = "tcp"
+	cfg.Syslog.Address = ln.Addr().String()
+	r, err := NewSyslog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ev := event.New("nas", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: nginx.service")
+	if err := r.Report(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	length, err := bufio.NewReader(conn).ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(r.format(ev)); strings.TrimSpace(length) != strconv.Itoa(want) {
+		t.Errorf("frame length = %q, want %d", length, want)
+	}
+}
@@ -0,0 +1,124 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// WebhookReporter posts event lifecycle transitions as JSON to a generic
+// webhook, so downstream incident tooling can track state changes rather
+// than only openings.
+type WebhookReporter struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewWebhook creates a new WebhookReporter.
+func NewWebhook(cfg *config.Config) *WebhookReporter {
+	return &WebhookReporter{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Name returns "webhook".
+func (r *WebhookReporter) Name() string {
+	return "webhook"
+}
+
+// webhookPayload is the JSON body posted for every transition.
+type webhookPayload struct {
+	Transition TransitionKind `json:"transition"`
+	Count      int            `json:"count,omitempty"`
+	Title      string         `json:"title"`
+	Event      *event.Event   `json:"event"`
+	SentAt     time.Time      `json:"sent_at"`
+}
+
+// Report posts a "created" transition for the event.
+func (r *WebhookReporter) Report(ctx context.Context, ev *event.Event) error {
+	return r.ReportTransition(ctx, Transition{Kind: TransitionCreated}, ev)
+}
+
+// ReportTransition posts a lifecycle transition if the event's tier is in
+// the webhook alert tiers and the transition is enabled.
+func (r *WebhookReporter) ReportTransition(ctx context.Context, t Transition, ev *event.Event) error {
+	if r.cfg.Webhook.URL == "" {
+		return nil
+	}
+	if !r.cfg.WebhookShouldAlert(string(ev.Tier)) {
+		slog.Debug("event tier not in webhook alert tiers, skipping", "tier", ev.Tier)
+		return nil
+	}
+	if !r.wantsTransition(t.Kind) {
+		return nil
+	}
+
+	data, err := json.Marshal(webhookPayload{
+		Transition: t.Kind,
+		Count:      t.Count,
+		Title:      FormatTitle(ev),
+		Event:      ev,
+		SentAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Logtriage-Transition", string(t.Kind))
+	if r.cfg.Webhook.Secret != "" {
+		req.Header.Set("X-Logtriage-Signature", "sha256="+signPayload(r.cfg.Webhook.Secret, data))
+	}
+
+	noteSend(ctx)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	noteStatus(ctx, resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	slog.Info("webhook sent", "transition", t.Kind, "tier", ev.Tier, "summary", ev.Summary)
+	return nil
+}
+
+func (r *WebhookReporter) wantsTransition(kind TransitionKind) bool {
+	if len(r.cfg.Webhook.Transitions) == 0 {
+		return true
+	}
+	for _, k := range r.cfg.Webhook.Transitions {
+		if TransitionKind(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// signPayload returns the hex HMAC-SHA256 of body keyed by secret.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,204 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestWebhookTransitions(t *testing.T) {
+	type received struct {
+		header  http.Header
+		body    []byte
+		payload webhookPayload
+	}
+	var got []received
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p webhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		got = append(got, received{header: r.Header, body: body, payload: p})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Webhook.URL = server.URL
+	cfg.Webhook.Secret = "hush"
+
+	ev := event.New("nas", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: smbd")
+	r := NewWebhook(cfg)
+	ctx := context.Background()
+
+	if err := r.Report(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReportTransition(ctx, Transition{Kind: TransitionAggregated, Count: 4}, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("received %d requests, want 2", len(got))
+	}
+	if got[0].payload.Transition != TransitionCreated || got[1].payload.Transition != TransitionAggregated {
+		t.Errorf("transitions = %q, %q", got[0].payload.Transition, got[1].payload.Transition)
+	}
+	if got[1].payload.Count != 4 {
+		t.Errorf("count = %d, want 4", got[1].payload.Count)
+	}
+	if got[0].payload.Event == nil || got[0].payload.Event.ID != ev.ID {
+		t.Errorf("payload event = %+v", got[0].payload.Event)
+	}
+	if h := got[1].header.Get("X-Logtriage-Transition"); h != "aggregated" {
+		t.Errorf("X-Logtriage-Transition = %q", h)
+	}
+	if sig := got[0].header.Get("X-Logtriage-Signature"); sig != "sha256="+signPayload("hush", got[0].body) {
+		t.Errorf("signature = %q does not match body", sig)
+	}
+}
+
+func TestWebhookTransitionFilter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Webhook.URL = server.URL
+	cfg.Webhook.Transitions = []string{"created", "resolved"}
+
+	ev := event.New("nas", time.Now(), event.TierProcessCrash, event.SevHigh, "Crash: vlc")
+	r := NewWebhook(cfg)
+	ctx := context.Background()
+
+	r.ReportTransition(ctx, Transition{Kind: TransitionEscalated}, ev)
+	r.ReportTransition(ctx, Transition{Kind: TransitionResolved}, ev)
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (only resolved enabled here)", calls)
+	}
+
+	// Tiers outside the alert tiers are never posted.
+	low := event.New("nas", time.Now(), event.TierMemPressure, event.SevWarning, "PSI")
+	r.Report(ctx, low)
+	if calls != 1 {
+		t.Errorf("non-alert tier was posted, calls = %d", calls)
+	}
+}
+
+func TestMultiReportTransition(t *testing.T) {
+	plain := newRecordingSink()
+	hook := &transitionSink{}
+	m := NewMulti(plain, hook)
+	ev := testEvent("Crash: vlc", event.SevHigh)
+	ctx := context.Background()
+
+	m.ReportTransition(ctx, Transition{Kind: TransitionEscalated}, ev)
+	m.ReportTransition(ctx, Transition{Kind: TransitionAcked}, ev)
+	m.ReportTransition(ctx, Transition{Kind: TransitionResolved}, ev)
+
+	if singles, _ := plain.counts(); singles != 2 {
+		t.Errorf("plain sink got %d reports, want 2 (acked is not alerting)", singles)
+	}
+	if len(hook.kinds) != 3 {
+		t.Errorf("transition sink got %v, want every transition", hook.kinds)
+	}
+}
+
+func TestMultiFailedSinksAndRetry(t *testing.T) {
+	plain := newRecordingSink()
+	batched := NewBatcher(plain, time.Hour)
+	down := &failingSink{err: errors.New("connection refused")}
+	m := NewMulti(batched, down)
+	ev := testEvent("Crash: vlc", event.SevHigh)
+	ctx := context.Background()
+
+	err := m.ReportTransition(ctx, Transition{Kind: TransitionCreated}, ev)
+	if got := FailedSinks(err); len(got) != 1 || got[0] != "down" {
+		t.Errorf("FailedSinks = %v, want [down]", got)
+	}
+	if !strings.Contains(err.Error(), "down: connection refused") {
+		t.Errorf("error = %q", err)
+	}
+	if FailedSinks(nil) != nil {
+		t.Error("FailedSinks(nil) is not empty")
+	}
+
+	// The batching window is open, yet a retry is delivered at once.
+	if err := m.Retry(ctx, "recording", Transition{Kind: TransitionCreated}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if singles, _ := plain.counts(); singles != 2 {
+		t.Errorf("recording sink got %d reports, want 2", singles)
+	}
+	if err := m.Retry(ctx, "down", Transition{Kind: TransitionCreated}, ev); err == nil {
+		t.Error("retry to a failing sink succeeded")
+	}
+	if err := m.Retry(ctx, "gone", Transition{Kind: TransitionCreated}, ev); err == nil {
+		t.Error("retry to an unknown sink succeeded")
+	}
+}
+
+func TestMultiReportBatch(t *testing.T) {
+	plain := newRecordingSink()
+	batched := NewBatcher(plain, time.Hour)
+	tr := &transitionSink{}
+	m := NewMulti(batched, tr)
+	pressure := testEvent("Memory pressure", event.SevWarning)
+	pressure.Tier = event.TierMemPressure // not accepted by the recording sink
+	ns := []Notification{
+		{Transition{Kind: TransitionCreated}, testEvent("Crash: vlc", event.SevHigh)},
+		{Transition{Kind: TransitionAggregated, Count: 3}, testEvent("Crash: mpv", event.SevHigh)},
+		{Transition{Kind: TransitionCreated}, pressure},
+	}
+	if err := m.ReportBatch(context.Background(), ns); err != nil {
+		t.Fatal(err)
+	}
+	if singles, batches := plain.counts(); singles != 0 || batches != 1 || len(plain.batches[0]) != 2 {
+		t.Errorf("recording sink got %d reports and batches %v, want one batch of 2", singles, plain.batches)
+	}
+	if len(tr.kinds) != 3 || tr.kinds[1] != TransitionAggregated {
+		t.Errorf("transition sink got %v", tr.kinds)
+	}
+	if got := m.Sinks(); len(got) != 2 || got[0] != "recording" || got[1] != "transitions" {
+		t.Errorf("Sinks = %v", got)
+	}
+}
+
+type failingSink struct {
+	err error
+}
+
+func (s *failingSink) Name() string { return "down" }
+
+func (s *failingSink) Report(ctx context.Context, ev *event.Event) error {
+	return s.err
+}
+
+type transitionSink struct {
+	kinds []TransitionKind
+}
+
+func (s *transitionSink) Name() string { return "transitions" }
+
+func (s *transitionSink) Report(ctx context.Context, ev *event.Event) error {
+	return s.ReportTransition(ctx, Transition{Kind: TransitionCreated}, ev)
+}
+
+func (s *transitionSink) ReportTransition(ctx context.Context, t Transition, ev *event.Event) error {
+	s.kinds = append(s.kinds, t.Kind)
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package selfmon watches logtriage's own log for warnings and errors that
+// keep recurring, such as a sink rejecting every notification or a monitor
+// failing every poll, and raises each as a T0 internal event. The event is
+// stored like any other, so a chronic problem with the monitor itself shows
+// in digests and the dashboard rather than only in the journal.
+package selfmon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/selfstat"
+)
+
+// subjectKeys are the log attributes that tell apart failures logged with
+// the same message, such as the sink of a failed notification. They go in
+// the event's summary and dedup key; other attributes only in its detail.
+var subjectKeys = []string{"sink", "reporter", "hook", "source", "device", "monitor", "section"}
+
+// eventBuffer is how many raised events may wait for the pipeline. More
+// are dropped and counted as such.
+const eventBuffer = 16
+
+// Monitor counts recurring warnings and errors. Each distinct failure, by
+// message and subject, raises an event once it has been logged threshold
+// times within window, and at most once per window after that. It is safe
+// for concurrent use.
+type Monitor struct {
+	instanceID string
+	threshold  int
+	window     time.Duration
+	now        func() time.Time // for tests
+	events     chan *event.Event
+
+	mu      sync.Mutex
+	tallies map[string]*tally
+}
+
+// tally is the count of one failure in its current window.
+type tally struct {
+	start  time.Time // of the window
+	count  int
+	raised bool // an event was raised in this window
+}
+
+// New creates a Monitor that raises events for instanceID.
+func New(instanceID string, threshold int, window time.Duration) *Monitor {
+	return &Monitor{
+		instanceID: instanceID,
+		threshold:  threshold,
+		window:     window,
+		now:        time.Now,
+		events:     make(chan *event.Event, eventBuffer),
+		tallies:    make(map[string]*tally),
+	}
+}
+
+// Events returns the channel raised events are sent on.
+func (m *Monitor) Events() <-chan *event.Event {
+	return m.events
+}
+
+// Handler returns a slog.Handler that passes records on to next and counts
+// those at warning level or above. A nil Monitor returns next.
+func (m *Monitor) Handler(next slog.Handler) slog.Handler {
+	if m == nil {
+		return next
+	}
+	return &handler{m: m, next: next}
+}
+
+// observe counts a warning or error, with attrs the logger's own
+// attributes, and raises an event if it has recurred often enough. It must
+// not log, as it runs inside the log handler.
+func (m *Monitor) observe(r slog.Record, attrs []slog.Attr) {
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	var subject []string
+	for _, key := range subjectKeys {
+		for _, a := range attrs {
+			if a.Key == key {
+				subject = append(subject, key+"="+a.Value.String())
+				break
+			}
+		}
+	}
+	key := strings.Join(append([]string{"message=" + r.Message}, subject...), ", ")
+
+	now := m.now()
+	m.mu.Lock()
+	t := m.tallies[key]
+	if t == nil || now.Sub(t.start) >= m.window {
+		t = &tally{start: now}
+		m.tallies[key] = t
+	}
+	t.count++
+	raise := !t.raised && t.count >= m.threshold
+	if raise {
+		t.raised = true
+	}
+	count, since := t.count, t.start
+	m.pruneLocked(now)
+	m.mu.Unlock()
+
+	if !raise {
+		return
+	}
+	select {
+	case m.events <- m.newEvent(r, attrs, subject, key, count, since):
+	default:
+		selfstat.Drop(1)
+	}
+}
+
+// pruneLocked forgets failures not logged for a window, so the tallies of
+// one-off messages do not pile up.
+func (m *Monitor) pruneLocked(now time.Time) {
+	for key, t := range m.tallies {
+		if now.Sub(t.start) >= m.window {
+			delete(m.tallies, key)
+		}
+	}
+}
+
+// newEvent builds the T0 event for a recurring failure, from the record
+// that crossed the threshold.
+func (m *Monitor) newEvent(r slog.Record, attrs []slog.Attr, subject []string, key string, count int, since time.Time) *event.Event {
+	kind, sev := "warning", event.SevWarning
+	if r.Level >= slog.LevelError {
+		kind, sev = "error", event.SevMedium
+	}
+	summary := fmt.Sprintf("logtriage %s: %s", kind, r.Message)
+	if len(subject) > 0 {
+		summary += " (" + strings.Join(subject, ", ") + ")"
+	}
+
+	ev := event.New(m.instanceID, r.Time, event.TierInternal, sev, summary)
+	ev.Process = "logtriage"
+	ev.DedupKey = key
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "logtriage logged this %s %d times since %s.\n\nLast occurrence:\n",
+		kind, count, since.Local().Format("15:04"))
+	for _, a := range attrs {
+		fmt.Fprintf(&b, "  %s: %s\n", a.Key, a.Value.String())
+		ev.RawFields[a.Key] = a.Value.String()
+	}
+	b.WriteString("\nThe daemon's log has every occurrence.")
+	ev.Detail = b.String()
+	return ev
+}
+
+// handler is the slog.Handler returned by Monitor.Handler.
+type handler struct {
+	m    *Monitor
+	next slog.Handler
+
+	// attrs are those added by WithAttrs outside any group; grouped ones
+	// are only passed on.
+	attrs   []slog.Attr
+	grouped bool
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		h.m.observe(r, slices.Clone(h.attrs))
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	if !h.grouped {
+		c.attrs = append(slices.Clone(h.attrs), attrs...)
+	}
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	c.grouped = true
+	return &c
+}
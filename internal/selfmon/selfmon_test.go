@@ -0,0 +1,94 @@
+package selfmon
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// drain returns the events raised so far.
+func drain(m *Monitor) []*event.Event {
+	var evs []*event.Event
+	for {
+		select {
+		case ev := <-m.Events():
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+func TestMonitorRaisesRecurringFailures(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := New("nas", 3, time.Hour)
+	m.now = func() time.Time { return now }
+
+	var out bytes.Buffer
+	log := slog.New(m.Handler(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelError})))
+	authErr := errors.New("ntfy returned status 401")
+
+	for range 2 {
+		log.Error("failed to send notification", "sink", "ntfy", "error", authErr)
+		log.Info("event classified", "tier", "T1")
+	}
+	log.Warn("smartctl query failed", "device", "/dev/sda")
+	if evs := drain(m); len(evs) != 0 {
+		t.Fatalf("raised %d events below the threshold", len(evs))
+	}
+
+	// The third occurrence raises one event; later ones in the window none.
+	log.Error("failed to send notification", "sink", "ntfy", "error", authErr)
+	log.Error("failed to send notification", "sink", "ntfy", "error", authErr)
+	// The same message for another sink is counted apart.
+	log.With("sink", "slack").Error("failed to send notification")
+	evs := drain(m)
+	if len(evs) != 1 {
+		t.Fatalf("raised %d events, want 1", len(evs))
+	}
+	ev := evs[0]
+	if ev.Tier != event.TierInternal || ev.Severity != event.SevMedium || ev.InstanceID != "nas" {
+		t.Errorf("event = %s %s on %s", ev.Tier, ev.Severity, ev.InstanceID)
+	}
+	if ev.Summary != "logtriage error: failed to send notification (sink=ntfy)" {
+		t.Errorf("summary = %q", ev.Summary)
+	}
+	if !strings.Contains(ev.Detail, "3 times") || !strings.Contains(ev.Detail, "status 401") {
+		t.Errorf("detail = %q", ev.Detail)
+	}
+	if ev.DedupKey != "message=failed to send notification, sink=ntfy" {
+		t.Errorf("dedup key = %q", ev.DedupKey)
+	}
+
+	// Warnings are counted though the log only shows errors.
+	if strings.Contains(out.String(), "smartctl") {
+		t.Error("warning passed to a handler at error level")
+	}
+	if !strings.Contains(out.String(), "failed to send notification") {
+		t.Error("error not passed on")
+	}
+
+	// After the window, the count starts over.
+	now = now.Add(time.Hour)
+	for range 3 {
+		log.Warn("smartctl query failed", "device", "/dev/sda")
+	}
+	evs = drain(m)
+	if len(evs) != 1 || evs[0].Severity != event.SevWarning ||
+		evs[0].Summary != "logtriage warning: smartctl query failed (device=/dev/sda)" {
+		t.Fatalf("after the window: %+v", evs)
+	}
+}
+
+func TestNilMonitorHandler(t *testing.T) {
+	var m *Monitor
+	h := slog.NewTextHandler(&bytes.Buffer{}, nil)
+	if m.Handler(h) != slog.Handler(h) {
+		t.Error("nil Monitor wrapped the handler")
+	}
+}
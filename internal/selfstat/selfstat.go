@@ -0,0 +1,37 @@
+// Package selfstat counts the daemon's own losses: events dropped because a
+// queue or the agent spool was full, and notifications that could not be
+// delivered. The counters are process-wide so any stage can record a loss
+// without threading a handle through; the daemon persists them for the
+// digest's self-health section.
+package selfstat
+
+import "sync/atomic"
+
+var (
+	dropped          atomic.Int64
+	reporterFailures atomic.Int64
+)
+
+// Counters is a snapshot of the counters since the process started.
+type Counters struct {
+	Dropped          int64
+	ReporterFailures int64
+}
+
+// Drop records n events discarded before they were stored or delivered.
+func Drop(n int) {
+	dropped.Add(int64(n))
+}
+
+// ReporterFailure records a notification or hub forward that failed.
+func ReporterFailure() {
+	reporterFailures.Add(1)
+}
+
+// Snapshot returns the current counter values.
+func Snapshot() Counters {
+	return Counters{
+		Dropped:          dropped.Load(),
+		ReporterFailures: reporterFailures.Load(),
+	}
+}
@@ -0,0 +1,183 @@
+// Package server exposes logtriage's HTTP API, including the hub endpoint
+// that agents forward classified events to.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// maxIngestBytes caps the size of a single ingest request body.
+const maxIngestBytes = 4 << 20
+
+// enqueueTimeout bounds how long an ingest request waits for the daemon to
+// accept its events before answering 503 so the agent retries later.
+const enqueueTimeout = 10 * time.Second
+
+// Server is the logtriage HTTP API server.
+type Server struct {
+	addr   string
+	token  string
+	mux    *http.ServeMux
+	events chan *event.Event
+}
+
+// New creates a server listening on addr. Every API request must carry
+// "Authorization: Bearer <token>".
+func New(addr, token string) *Server {
+	s := &Server{
+		addr:   addr,
+		token:  token,
+		mux:    http.NewServeMux(),
+		events: make(chan *event.Event, 64),
+	}
+	s.mux.Handle("POST /api/v1/events", s.requireToken(http.HandlerFunc(s.handleIngest)))
+	return s
+}
+
+// Events returns the channel of events received from agents.
+func (s *Server) Events() <-chan *event.Event {
+	return s.events
+}
+
+// Start binds the listen address and serves in the background until ctx is
+// cancelled. Bind errors are returned immediately.
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// requireToken rejects requests without the configured bearer token.
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleIngest accepts a single event object or an array of events.
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	evs, err := decodeEvents(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, ev := range evs {
+		if err := validateEvent(ev); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	timeout := time.NewTimer(enqueueTimeout)
+	defer timeout.Stop()
+	for _, ev := range evs {
+		select {
+		case s.events <- ev:
+		case <-timeout.C:
+			writeError(w, http.StatusServiceUnavailable, "event queue full")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	slog.Debug("ingested events", "count", len(evs), "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(evs)})
+}
+
+// decodeEvents parses either a JSON array of events or a single event.
+func decodeEvents(body []byte) ([]*event.Event, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("empty request body")
+	}
+
+	var evs []*event.Event
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &evs); err != nil {
+			return nil, fmt.Errorf("decoding events: %w", err)
+		}
+		return evs, nil
+	}
+
+	var ev event.Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, fmt.Errorf("decoding event: %w", err)
+	}
+	return []*event.Event{&ev}, nil
+}
+
+// validateEvent checks the fields the store and cooldown logic rely on.
+func validateEvent(ev *event.Event) error {
+	switch {
+	case ev == nil:
+		return errors.New("null event")
+	case ev.ID == "":
+		return errors.New("event missing id")
+	case ev.InstanceID == "":
+		return fmt.Errorf("event %s missing instance_id", ev.ID)
+	case ev.Tier == "":
+		return fmt.Errorf("event %s missing tier", ev.ID)
+	case ev.Timestamp.IsZero():
+		return fmt.Errorf("event %s missing timestamp", ev.ID)
+	}
+	if ev.RawFields == nil {
+		ev.RawFields = make(map[string]string)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
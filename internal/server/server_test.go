@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "s3cret"
+
+func postEvents(t *testing.T, s *Server, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIngestRequiresToken(t *testing.T) {
+	s := New("127.0.0.1:0", testToken)
+
+	if rec := postEvents(t, s, "", `{}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", rec.Code)
+	}
+	if rec := postEvents(t, s, "wrong", `{}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestIngestSingleEvent(t *testing.T) {
+	s := New("127.0.0.1:0", testToken)
+
+	body := `{"id":"e1","instance_id":"nas","timestamp":"2026-02-19T14:32:05Z","tier":"T1","severity":"critical","summary":"OOM Kill: smbd"}`
+	rec := postEvents(t, s, testToken, body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	ev := <-s.Events()
+	if ev.ID != "e1" || ev.InstanceID != "nas" || ev.Tier != "T1" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.RawFields == nil {
+		t.Error("RawFields should be initialized")
+	}
+}
+
+func TestIngestArray(t *testing.T) {
+	s := New("127.0.0.1:0", testToken)
+
+	body := `[
+		{"id":"e1","instance_id":"a","timestamp":"2026-02-19T14:32:05Z","tier":"T2","severity":"high","summary":"crash"},
+		{"id":"e2","instance_id":"b","timestamp":"2026-02-19T14:32:06Z","tier":"T3","severity":"medium","summary":"failed"}
+	]`
+	rec := postEvents(t, s, testToken, body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"accepted":2`) {
+		t.Errorf("body = %s", rec.Body)
+	}
+
+	if ev := <-s.Events(); ev.InstanceID != "a" {
+		t.Errorf("first event instance = %q", ev.InstanceID)
+	}
+	if ev := <-s.Events(); ev.InstanceID != "b" {
+		t.Errorf("second event instance = %q", ev.InstanceID)
+	}
+}
+
+func TestIngestValidation(t *testing.T) {
+	s := New("127.0.0.1:0", testToken)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty", ``},
+		{"malformed", `{"id":`},
+		{"missing id", `{"instance_id":"a","timestamp":"2026-02-19T14:32:05Z","tier":"T1"}`},
+		{"missing instance", `{"id":"e1","timestamp":"2026-02-19T14:32:05Z","tier":"T1"}`},
+		{"missing timestamp", `{"id":"e1","instance_id":"a","tier":"T1"}`},
+		{"null in array", `[null]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := postEvents(t, s, testToken, tt.body); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+
+	select {
+	case ev := <-s.Events():
+		t.Errorf("invalid request should not enqueue events, got %+v", ev)
+	default:
+	}
+}
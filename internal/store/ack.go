@@ -0,0 +1,80 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Ack is an acknowledged alert. Until it ends, further events with the
+// same instance, tier, and dedup key are stored but not notified.
+type Ack struct {
+	ID         int64
+	InstanceID string
+	Tier       event.Tier
+	Key        string // the dedup key, as from DedupKey
+	EventID    string // the event whose alert was acknowledged
+	AckedBy    string
+	AckedAt    time.Time
+	Until      time.Time
+}
+
+// AckEvent acknowledges the alert for ev: it records who acknowledged it
+// and when on the event, and quiets its dedup key until the given time.
+func (d *DB) AckEvent(ev *event.Event, by string, at, until time.Time) (*Ack, error) {
+	a := &Ack{
+		InstanceID: ev.InstanceID,
+		Tier:       ev.Tier,
+		Key:        d.DedupKey(ev),
+		EventID:    ev.ID,
+		AckedBy:    by,
+		AckedAt:    at,
+		Until:      until,
+	}
+	d.await(a.EventID)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("acking event: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO acks (instance_id, tier, dedup_key, event_id, acked_by, acked_at, until)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		a.InstanceID, string(a.Tier), a.Key, a.EventID, a.AckedBy, formatTime(a.AckedAt), formatTime(a.Until),
+	).Scan(&a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("acking event: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE events SET acked_by = ?, acked_at = ? WHERE id = ?`,
+		a.AckedBy, formatTime(a.AckedAt), a.EventID); err != nil {
+		return nil, fmt.Errorf("acking event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("acking event: %w", err)
+	}
+	ev.AckedBy, ev.AckedAt = a.AckedBy, a.AckedAt
+	return a, nil
+}
+
+// AckedBy returns the latest-ending ack quieting ev at now, or nil.
+func (d *DB) AckedBy(ev *event.Event, now time.Time) (*Ack, error) {
+	var a Ack
+	var ackedAt, until string
+	err := d.db.QueryRow(`SELECT id, instance_id, tier, dedup_key, event_id, acked_by, acked_at, until FROM acks
+		WHERE instance_id = ? AND tier = ? AND dedup_key = ? AND until > ?
+		ORDER BY until DESC LIMIT 1`,
+		ev.InstanceID, string(ev.Tier), d.DedupKey(ev), formatTime(now),
+	).Scan(&a.ID, &a.InstanceID, &a.Tier, &a.Key, &a.EventID, &a.AckedBy, &ackedAt, &until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking acks: %w", err)
+	}
+	a.AckedAt, _ = time.Parse(time.RFC3339Nano, ackedAt)
+	a.Until, _ = time.Parse(time.RFC3339Nano, until)
+	return &a, nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// The queries here count events in the database rather than loading them,
+// so summaries of a long period on a busy host stay cheap. Each ignores the
+// filter's limit.
+
+// NameCount is a process, unit, or other name with how many events carry
+// it.
+type NameCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// CountByTier returns how many events f matches in each tier.
+func (d *DB) CountByTier(f QueryFilter) (map[event.Tier]int, error) {
+	counts, err := d.countBy("tier", f, 0)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[event.Tier]int, len(counts))
+	for _, c := range counts {
+		m[event.Tier(c.Name)] = c.Count
+	}
+	return m, nil
+}
+
+// CountByInstance returns how many events f matches from each instance.
+func (d *DB) CountByInstance(f QueryFilter) (map[string]int, error) {
+	counts, err := d.countBy("instance_id", f, 0)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]int, len(counts))
+	for _, c := range counts {
+		m[c.Name] = c.Count
+	}
+	return m, nil
+}
+
+// TopProcesses returns the n processes with the most events f matches,
+// most first and then by name, or all of them if n is 0. Events without a
+// process are counted under "".
+func (d *DB) TopProcesses(f QueryFilter, n int) ([]NameCount, error) {
+	return d.countBy("process", f, n)
+}
+
+// TopUnits is TopProcesses for systemd units.
+func (d *DB) TopUnits(f QueryFilter, n int) ([]NameCount, error) {
+	return d.countBy("unit", f, n)
+}
+
+// countBy counts the events f matches by the value of column, as for
+// TopProcesses.
+func (d *DB) countBy(column string, f QueryFilter, n int) ([]NameCount, error) {
+	d.flush()
+	where, args := d.filterClause(f)
+	query := `SELECT COALESCE(` + column + `, '') AS name, COUNT(*) AS n FROM events WHERE 1=1` + where +
+		` GROUP BY name ORDER BY n DESC, name`
+	if n > 0 {
+		query += " LIMIT ?"
+		args = append(args, n)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("counting events by %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	var counts []NameCount
+	for rows.Next() {
+		var c NameCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, fmt.Errorf("scanning event count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// RecentSummaries returns up to n distinct summaries of the events f
+// matches, the most recently seen first, or all of them if n is 0.
+func (d *DB) RecentSummaries(f QueryFilter, n int) ([]string, error) {
+	d.flush()
+	where, args := d.filterClause(f)
+	query := `SELECT summary FROM events WHERE 1=1` + where +
+		` GROUP BY summary ORDER BY MAX(timestamp) DESC, summary`
+	if n > 0 {
+		query += " LIMIT ?"
+		args = append(args, n)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying event summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("scanning event summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// Histogram counts the events f matches in each bucket between
+// consecutive edges, from edges[i] up to but excluding edges[i+1]. The
+// edges must be ascending; buckets need not be of equal length, so they
+// can follow local calendar days.
+func (d *DB) Histogram(f QueryFilter, edges []time.Time) ([]int, error) {
+	if len(edges) < 2 {
+		return nil, nil
+	}
+	counts := make([]int, len(edges)-1)
+
+	// Bucket in SQL so only one row per bucket comes back.
+	var bucket strings.Builder
+	var args []interface{}
+	bucket.WriteString("CASE")
+	for i, e := range edges[1:] {
+		fmt.Fprintf(&bucket, " WHEN timestamp < ? THEN %d", i)
+		args = append(args, formatTime(e))
+	}
+	bucket.WriteString(" END")
+
+	d.flush()
+	where, filterArgs := d.filterClause(f)
+	args = append(args, filterArgs...)
+	args = append(args, formatTime(edges[0]), formatTime(edges[len(edges)-1]))
+	rows, err := d.db.Query(`SELECT `+bucket.String()+` AS bucket, COUNT(*) FROM events
+		WHERE 1=1`+where+` AND timestamp >= ? AND timestamp < ? GROUP BY bucket`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("counting events over time: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i, n int
+		if err := rows.Scan(&i, &n); err != nil {
+			return nil, fmt.Errorf("scanning event count: %w", err)
+		}
+		counts[i] = n
+	}
+	return counts, rows.Err()
+}
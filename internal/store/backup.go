@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// backupPrefix and backupLayout name scheduled backups, so they sort by
+// the time they were taken.
+const (
+	backupPrefix = "events-"
+	backupLayout = "20060102T150405Z"
+)
+
+// Backup copies the database to path with SQLite's online backup, which
+// takes a consistent snapshot while the daemon keeps writing. The copy is
+// written beside path and renamed into place, so path is never a partial
+// database. PostgreSQL databases are backed up with pg_dump instead.
+func (d *DB) Backup(ctx context.Context, path string) error {
+	if d.path == "" {
+		return fmt.Errorf("%w: back up a PostgreSQL database with pg_dump", errors.ErrUnsupported)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := backupFile(ctx, d.path, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backing up database: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backing up database: %w", err)
+	}
+	return nil
+}
+
+// BackupRotating backs the database up into dir, named for now, and
+// deletes the oldest backups there beyond keep. It returns the new
+// backup's path.
+func (d *DB) BackupRotating(ctx context.Context, dir string, keep int, now time.Time) (string, error) {
+	path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupLayout)+".db")
+	if err := d.Backup(ctx, path); err != nil {
+		return "", err
+	}
+	backups, err := listBackups(dir)
+	if err != nil {
+		return path, err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return path, fmt.Errorf("pruning backups: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return path, nil
+}
+
+// LastBackup returns when the newest backup in dir was taken, or the zero
+// time if there is none.
+func LastBackup(dir string) time.Time {
+	backups, _ := listBackups(dir)
+	if len(backups) == 0 {
+		return time.Time{}
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(backups[len(backups)-1], backupPrefix), ".db")
+	t, _ := time.Parse(backupLayout, name)
+	return t
+}
+
+// listBackups returns the names of the scheduled backups in dir, oldest
+// first.
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, backupPrefix)
+		if !ok || !strings.HasSuffix(stamp, ".db") {
+			continue
+		}
+		if _, err := time.Parse(backupLayout, strings.TrimSuffix(stamp, ".db")); err == nil {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Vacuum rebuilds the database file, returning the space of deleted events
+// to the filesystem. On PostgreSQL it runs a plain VACUUM, which leaves the
+// space for reuse.
+func (d *DB) Vacuum() error {
+	d.flush()
+	if _, err := d.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+	return nil
+}
+
+// Verify runs SQLite's integrity check and returns the problems it finds;
+// none means the database is sound. PostgreSQL has no equivalent built in.
+func (d *DB) Verify() ([]string, error) {
+	if d.path == "" {
+		return nil, fmt.Errorf("%w: check a PostgreSQL database with its amcheck extension", errors.ErrUnsupported)
+	}
+	d.flush()
+	rows, err := d.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("checking database integrity: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, fmt.Errorf("checking database integrity: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
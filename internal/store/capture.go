@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Capture is a debug bundle recorded when an incident opened: journal
+// lines from the involved unit at a raised priority and periodic system
+// stat samples. It belongs to the incident, and is kept under the event
+// that opened it.
+type Capture struct {
+	EventID    string
+	IncidentID string // empty for captures taken before they were per incident
+	Started    time.Time
+	Ended      time.Time
+	Scope      string // journalctl match used, e.g. "-u nginx.service"
+	Journal    string
+	Samples    string
+}
+
+// SaveCapture stores a capture bundle, replacing any earlier one for the
+// same event.
+func (d *DB) SaveCapture(c *Capture) error {
+	_, err := d.db.Exec(`
+		INSERT INTO captures (event_id, incident_id, started, ended, scope, journal, samples)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (event_id) DO UPDATE SET incident_id = excluded.incident_id, started = excluded.started,
+			ended = excluded.ended, scope = excluded.scope, journal = excluded.journal, samples = excluded.samples`,
+		c.EventID,
+		nullString(c.IncidentID),
+		c.Started.UTC().Format(time.RFC3339Nano),
+		c.Ended.UTC().Format(time.RFC3339Nano),
+		c.Scope,
+		c.Journal,
+		c.Samples,
+	)
+	if err != nil {
+		return fmt.Errorf("saving capture: %w", err)
+	}
+	return nil
+}
+
+// GetCapture returns the capture bundle of an incident, or of the event
+// that opened it, given either's ID, or nil if there is none.
+func (d *DB) GetCapture(id string) (*Capture, error) {
+	var c Capture
+	var incident sql.NullString
+	var started, ended string
+	err := d.db.QueryRow(`
+		SELECT event_id, incident_id, started, ended, scope, journal, samples
+		FROM captures WHERE event_id = ? OR incident_id = ?
+		ORDER BY started DESC LIMIT 1`, id, id,
+	).Scan(&c.EventID, &incident, &started, &ended, &c.Scope, &c.Journal, &c.Samples)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading capture: %w", err)
+	}
+	c.IncidentID = incident.String
+	c.Started, _ = time.Parse(time.RFC3339Nano, started)
+	c.Ended, _ = time.Parse(time.RFC3339Nano, ended)
+	return &c, nil
+}
+
+// ListCaptures returns the capture bundles without their contents, most
+// recent first.
+func (d *DB) ListCaptures(limit int) ([]*Capture, error) {
+	query := `SELECT event_id, incident_id, started, ended, scope FROM captures ORDER BY started DESC`
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing captures: %w", err)
+	}
+	defer rows.Close()
+
+	var captures []*Capture
+	for rows.Next() {
+		var c Capture
+		var incident sql.NullString
+		var started, ended string
+		if err := rows.Scan(&c.EventID, &incident, &started, &ended, &c.Scope); err != nil {
+			return nil, fmt.Errorf("scanning capture row: %w", err)
+		}
+		c.IncidentID = incident.String
+		c.Started, _ = time.Parse(time.RFC3339Nano, started)
+		c.Ended, _ = time.Parse(time.RFC3339Nano, ended)
+		captures = append(captures, &c)
+	}
+	return captures, rows.Err()
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// CrashHistory reports how many other crashes of ev's process are stored,
+// and whether any of them had ev's crash signature (the _crash_signature
+// raw field). The event itself is excluded, so it may be checked either
+// before or after it is inserted.
+func (d *DB) CrashHistory(ev *event.Event) (count int, seen bool, err error) {
+	sig := ev.RawFields["_crash_signature"]
+	d.flush()
+	err = d.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(MAX(CASE WHEN `+d.db.dialect.jsonText("raw_json", "_crash_signature")+` = ? THEN 1 ELSE 0 END), 0)
+		FROM events
+		WHERE instance_id = ? AND tier = ? AND process = ? AND id != ?`,
+		sig, ev.InstanceID, string(event.TierProcessCrash), ev.Process, ev.ID,
+	).Scan(&count, &seen)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading crash history: %w", err)
+	}
+	return count, seen && sig != "", nil
+}
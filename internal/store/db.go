@@ -1,26 +1,52 @@
-// Package store provides SQLite-backed event storage with dedup/cooldown.
+// Package store provides SQLite- or PostgreSQL-backed event storage with
+// dedup/cooldown.
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// DB wraps an SQLite connection for event storage.
+// ErrDuplicate is returned by Insert when an event with the same ID is
+// already stored, e.g. when an agent retries a forward the hub already took.
+var ErrDuplicate = errors.New("event already stored")
+
+// ErrNoDriver is returned by Open when the binary was built without a
+// SQLite driver.
+var ErrNoDriver = errors.New("this build has no SQLite driver (built with CGO_ENABLED=0)")
+
+// DB wraps the event database: an SQLite file, or a PostgreSQL database
+// for a hub (see OpenPostgres).
 type DB struct {
-	db *sql.DB
+	db   *conn
+	path string // the SQLite file; empty for PostgreSQL
+	dsn  string // the PostgreSQL connection string
+	fts  bool   // events_fts full-text index available
+
+	mu        sync.RWMutex
+	dedupKeys map[string][]string // see SetDedupKeys
+
+	w *writer // nil unless StartWriter was called
 }
 
 // Open opens or creates an SQLite database at the given path.
 func Open(path string) (*DB, error) {
+	if Driver == "" {
+		return nil, ErrNoDriver
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o750); err != nil {
 		return nil, fmt.Errorf("creating db directory: %w", err)
@@ -34,32 +60,66 @@ func Open(path string) (*DB, error) {
 	// Single writer connection to avoid SQLITE_BUSY.
 	db.SetMaxOpenConns(1)
 
-	if err := migrate(db); err != nil {
+	d, err := open(db, sqliteDialect)
+	if err != nil {
+		return nil, err
+	}
+	d.path = path
+	return d, nil
+}
+
+// open migrates a newly opened database and returns it ready for use. It
+// closes db if that fails.
+func open(db *sql.DB, dl *dialect) (*DB, error) {
+	c := &conn{DB: db, dialect: dl}
+	if err := migrate(c); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("migrating database: %w", err)
 	}
+	fts, err := setupFTS(c)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+	return &DB{db: c, fts: fts}, nil
+}
 
-	return &DB{db: db}, nil
+// Location describes where the database is, for logs and status output:
+// the SQLite file, or the PostgreSQL connection string without its
+// password.
+func (d *DB) Location() string {
+	if d.dsn != "" {
+		return redactDSN(d.dsn)
+	}
+	return d.path
 }
 
-// Close closes the database.
+// Close commits any queued writes (see StartWriter) and closes the
+// database.
 func (d *DB) Close() error {
+	d.stopWriter()
 	return d.db.Close()
 }
 
-// Insert stores a new event in the database.
+// Insert stores a new event in the database. It returns ErrDuplicate if an
+// event with the same ID already exists.
 func (d *DB) Insert(ev *event.Event) error {
+	return insertEvent(d.db, ev)
+}
+
+func insertEvent(x execer, ev *event.Event) error {
 	rawJSON, err := json.Marshal(ev.RawFields)
 	if err != nil {
 		rawJSON = []byte("{}")
 	}
 
-	_, err = d.db.Exec(`
-		INSERT INTO events (id, instance_id, timestamp, tier, severity, summary, process, pid, unit, detail, raw_json, notified)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	result, err := x.Exec(`
+		INSERT INTO events (id, instance_id, timestamp, tier, severity, summary, process, pid, unit, detail, raw_json, notified, incident_id, container_id, container_name, cgroup, dedup_key, fingerprint)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
 		ev.ID,
 		ev.InstanceID,
-		ev.Timestamp.UTC().Format(time.RFC3339Nano),
+		formatTime(ev.Timestamp),
 		string(ev.Tier),
 		string(ev.Severity),
 		ev.Summary,
@@ -69,32 +129,144 @@ func (d *DB) Insert(ev *event.Event) error {
 		ev.Detail,
 		string(rawJSON),
 		false,
+		nullString(ev.IncidentID),
+		nullString(ev.ContainerID),
+		nullString(ev.ContainerName),
+		nullString(ev.CGroup),
+		nullString(ev.DedupKey),
+		nullString(ev.Fingerprint),
 	)
 	if err != nil {
 		return fmt.Errorf("inserting event: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDuplicate
+	}
 	return nil
 }
 
 // MarkNotified marks an event as having been sent to ntfy.
 func (d *DB) MarkNotified(id string) error {
+	d.await(id)
 	_, err := d.db.Exec(`UPDATE events SET notified = TRUE WHERE id = ?`, id)
 	return err
 }
 
+// SetSeverity changes the stored severity of an event, e.g. when repeats
+// escalate it.
+func (d *DB) SetSeverity(id string, sev event.Severity) error {
+	d.await(id)
+	_, err := d.db.Exec(`UPDATE events SET severity = ? WHERE id = ?`, string(sev), id)
+	return err
+}
+
 // QueryFilter controls which events are returned by Query.
 type QueryFilter struct {
 	Since      time.Time
 	Until      time.Time
 	Tier       string
 	InstanceID string
+	IncidentID string
+	Unit       string
+	Process    string
+	Severity   string
+	Search     string // words that must all appear in the summary or detail
 	Limit      int
+
+	// Offset skips that many of the matching events, and Cursor starts
+	// after the event a cursor from EventCursor was made for, to page
+	// through the results of Query. A cursor keeps its place when newer
+	// events arrive; an offset does not. Both apply only to Query.
+	Offset int
+	Cursor string
+}
+
+// ErrBadCursor is returned by Query for a QueryFilter.Cursor that is not
+// one EventCursor made.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// EventCursor returns the cursor for continuing a query after ev, the
+// last event of a page.
+func EventCursor(ev *event.Event) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(formatTime(ev.Timestamp) + "|" + ev.ID))
+}
+
+// parseCursor returns the timestamp and ID of the event cursor was made for.
+func parseCursor(cursor string) (timestamp, id string, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", ErrBadCursor
+	}
+	timestamp, id, ok := strings.Cut(string(b), "|")
+	if _, err := time.Parse(time.RFC3339Nano, timestamp); !ok || err != nil || id == "" {
+		return "", "", ErrBadCursor
+	}
+	return timestamp, id, nil
 }
 
-// Query returns events matching the filter, ordered by timestamp descending.
+// Query returns events matching the filter, ordered by timestamp
+// descending and then by ID, so pages of the results do not overlap.
 func (d *DB) Query(f QueryFilter) ([]*event.Event, error) {
-	query := `SELECT id, instance_id, timestamp, tier, severity, summary, process, pid, unit, detail, raw_json
-		FROM events WHERE 1=1`
+	d.flush()
+	where, args := d.filterClause(f)
+	if f.Cursor != "" {
+		ts, id, err := parseCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (timestamp < ? OR (timestamp = ? AND id < ?))"
+		args = append(args, ts, ts, id)
+	}
+	query := `SELECT ` + eventColumns + ` FROM events WHERE 1=1` + where + " ORDER BY timestamp DESC, id DESC"
+
+	switch {
+	case f.Limit > 0:
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	case f.Offset > 0:
+		// An offset needs a limit in SQLite.
+		query += " LIMIT ?"
+		args = append(args, math.MaxInt64)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, f.Offset)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*event.Event
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// QueryPage is Query for a page of f.Limit events, which must be positive.
+// It also returns the cursor for the next page, or "" if this is the last.
+func (d *DB) QueryPage(f QueryFilter) ([]*event.Event, string, error) {
+	size := f.Limit
+	f.Limit++ // one more tells whether there is a next page
+	events, err := d.Query(f)
+	if err != nil || len(events) <= size {
+		return events, "", err
+	}
+	events = events[:size]
+	return events, EventCursor(events[size-1]), nil
+}
+
+// filterClause returns the conditions selecting the events f matches, each
+// prefixed with AND, and their arguments. The limit is not included.
+func (d *DB) filterClause(f QueryFilter) (string, []interface{}) {
+	var query string
 	var args []interface{}
 
 	if !f.Since.IsZero() {
@@ -113,15 +285,59 @@ func (d *DB) Query(f QueryFilter) ([]*event.Event, error) {
 		query += " AND instance_id = ?"
 		args = append(args, f.InstanceID)
 	}
+	if f.IncidentID != "" {
+		query += " AND incident_id = ?"
+		args = append(args, f.IncidentID)
+	}
+	if f.Unit != "" {
+		query += " AND unit = ?"
+		args = append(args, f.Unit)
+	}
+	if f.Process != "" {
+		query += " AND process = ?"
+		args = append(args, f.Process)
+	}
+	if f.Severity != "" {
+		query += " AND severity = ?"
+		args = append(args, f.Severity)
+	}
+	if clause, searchArgs := d.searchClause(f.Search); clause != "" {
+		query += clause
+		args = append(args, searchArgs...)
+	}
+	return query, args
+}
 
-	query += " ORDER BY timestamp DESC"
-
-	if f.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, f.Limit)
+// DeleteEvents deletes every event the filter matches, ignoring its limit,
+// along with their captures and queued notifications and any incident left
+// without events. It returns how many events were deleted.
+func (d *DB) DeleteEvents(f QueryFilter) (int64, error) {
+	d.flush()
+	where, args := d.filterClause(f)
+	result, err := d.db.Exec(`DELETE FROM events WHERE 1=1`+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("deleting events: %w", err)
 	}
+	if err := d.deleteOrphans(); err != nil {
+		return 0, err
+	}
+	if _, err := d.db.Exec(`DELETE FROM incidents
+		WHERE id NOT IN (SELECT incident_id FROM events WHERE incident_id IS NOT NULL)`); err != nil {
+		return 0, fmt.Errorf("deleting empty incidents: %w", err)
+	}
+	return result.RowsAffected()
+}
 
-	rows, err := d.db.Query(query, args...)
+// EventsAfter returns up to limit events stored after the event with the
+// given ID, oldest first by insertion rather than timestamp, so events that
+// arrived late (e.g. from an agent's spool) are not skipped. It returns nil
+// if the event is not in the database.
+func (d *DB) EventsAfter(id string, limit int) ([]*event.Event, error) {
+	d.flush()
+	seq := d.db.dialect.seq
+	rows, err := d.db.Query(`SELECT `+eventColumns+` FROM events
+		WHERE `+seq+` > (SELECT `+seq+` FROM events WHERE id = ?)
+		ORDER BY `+seq+` LIMIT ?`, id, limit)
 	if err != nil {
 		return nil, fmt.Errorf("querying events: %w", err)
 	}
@@ -138,8 +354,23 @@ func (d *DB) Query(f QueryFilter) ([]*event.Event, error) {
 	return events, rows.Err()
 }
 
+// GetEvent returns the event with the given ID, or nil if there is none.
+func (d *DB) GetEvent(id string) (*event.Event, error) {
+	d.await(id)
+	rows, err := d.db.Query(`SELECT `+eventColumns+` FROM events WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("loading event: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanEvent(rows)
+}
+
 // Count returns the total number of events in the database.
 func (d *DB) Count() (int64, error) {
+	d.flush()
 	var count int64
 	err := d.db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&count)
 	if err != nil {
@@ -148,20 +379,116 @@ func (d *DB) Count() (int64, error) {
 	return count, nil
 }
 
+// Size returns the size of the database in bytes: the SQLite file, or
+// what PostgreSQL reports for the whole database.
+func (d *DB) Size() (int64, error) {
+	if d.path == "" {
+		var n int64
+		if err := d.db.QueryRow(`SELECT pg_database_size(current_database())`).Scan(&n); err != nil {
+			return 0, fmt.Errorf("reading database size: %w", err)
+		}
+		return n, nil
+	}
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return 0, fmt.Errorf("reading database size: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Stored reports whether an event of the same instance, tier, timestamp,
+// and summary as ev is already in the database, such as the daemon's own
+// copy of an event being replayed.
+func (d *DB) Stored(ev *event.Event) (bool, error) {
+	d.flush()
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM events
+		WHERE instance_id = ? AND tier = ? AND timestamp = ? AND summary = ?`,
+		ev.InstanceID, string(ev.Tier), formatTime(ev.Timestamp), ev.Summary,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("checking for stored event: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CheckWritable verifies the database can take a write lock, without
+// changing anything. It fails on a read-only or full filesystem, a lost
+// file, or a lock held elsewhere for longer than ctx allows. On
+// PostgreSQL it fails when the server is unreachable or read-only, such as
+// a standby.
+func (d *DB) CheckWritable(ctx context.Context) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("checking database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, d.db.dialect.beginWrite); err != nil {
+		return fmt.Errorf("database not writable: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, `ROLLBACK`); err != nil {
+		return fmt.Errorf("database not writable: %w", err)
+	}
+	return nil
+}
+
 // Purge deletes events older than the given retention duration.
 func (d *DB) Purge(retention time.Duration) (int64, error) {
+	d.flush()
 	cutoff := time.Now().Add(-retention).UTC().Format(time.RFC3339Nano)
 	result, err := d.db.Exec(`DELETE FROM events WHERE timestamp < ?`, cutoff)
 	if err != nil {
 		return 0, fmt.Errorf("purging old events: %w", err)
 	}
+	if err := d.deleteOrphans(); err != nil {
+		return 0, err
+	}
+	if _, err := d.db.Exec(`DELETE FROM incidents WHERE closed_at IS NOT NULL
+		AND id NOT IN (SELECT incident_id FROM events WHERE incident_id IS NOT NULL)`); err != nil {
+		return 0, fmt.Errorf("purging empty incidents: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM runs WHERE heartbeat < ?`, cutoff); err != nil {
+		return 0, fmt.Errorf("purging old runs: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM metrics WHERE timestamp < ?`, cutoff); err != nil {
+		return 0, fmt.Errorf("purging old metrics: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM snoozes WHERE until < ?`, cutoff); err != nil {
+		return 0, fmt.Errorf("purging old snoozes: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM acks WHERE until < ?`, cutoff); err != nil {
+		return 0, fmt.Errorf("purging old acks: %w", err)
+	}
 	return result.RowsAffected()
 }
 
+// deleteOrphans removes the captures, queued notifications, decisions, and
+// logged notification attempts of events that no longer exist.
+func (d *DB) deleteOrphans() error {
+	// Capture bundles go with the events they are attached to.
+	if _, err := d.db.Exec(`DELETE FROM captures WHERE event_id NOT IN (SELECT id FROM events)`); err != nil {
+		return fmt.Errorf("purging orphaned captures: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM notification_queue WHERE event_id NOT IN (SELECT id FROM events)`); err != nil {
+		return fmt.Errorf("purging orphaned notifications: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM decisions WHERE event_id NOT IN (SELECT id FROM events)`); err != nil {
+		return fmt.Errorf("purging orphaned decisions: %w", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM notification_log WHERE event_id NOT IN (SELECT id FROM events)`); err != nil {
+		return fmt.Errorf("purging orphaned notification attempts: %w", err)
+	}
+	return nil
+}
+
+// eventColumns is the column list scanEvent expects.
+const eventColumns = `id, instance_id, timestamp, tier, severity, summary, process, pid, unit, detail, raw_json, incident_id, container_id, container_name, cgroup, acked_by, acked_at, dedup_key, fingerprint`
+
 func scanEvent(rows *sql.Rows) (*event.Event, error) {
 	var ev event.Event
 	var tsStr, rawJSON string
-	var process, unit, detail sql.NullString
+	var process, unit, detail, incident, containerID, containerName, cgroup, ackedBy, ackedAt, dedupKey, fingerprint sql.NullString
 
 	err := rows.Scan(
 		&ev.ID,
@@ -175,6 +502,14 @@ func scanEvent(rows *sql.Rows) (*event.Event, error) {
 		&unit,
 		&detail,
 		&rawJSON,
+		&incident,
+		&containerID,
+		&containerName,
+		&cgroup,
+		&ackedBy,
+		&ackedAt,
+		&dedupKey,
+		&fingerprint,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("scanning event row: %w", err)
@@ -184,6 +519,16 @@ func scanEvent(rows *sql.Rows) (*event.Event, error) {
 	ev.Process = process.String
 	ev.Unit = unit.String
 	ev.Detail = detail.String
+	ev.IncidentID = incident.String
+	ev.ContainerID = containerID.String
+	ev.ContainerName = containerName.String
+	ev.CGroup = cgroup.String
+	ev.AckedBy = ackedBy.String
+	ev.DedupKey = dedupKey.String
+	ev.Fingerprint = fingerprint.String
+	if ackedAt.Valid {
+		ev.AckedAt, _ = time.Parse(time.RFC3339Nano, ackedAt.String)
+	}
 	ev.RawFields = make(map[string]string)
 	if rawJSON != "" {
 		_ = json.Unmarshal([]byte(rawJSON), &ev.RawFields)
@@ -192,33 +537,7 @@ func scanEvent(rows *sql.Rows) (*event.Event, error) {
 	return &ev, nil
 }
 
-func migrate(db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS events (
-			id          TEXT PRIMARY KEY,
-			instance_id TEXT NOT NULL,
-			timestamp   TEXT NOT NULL,
-			tier        TEXT NOT NULL,
-			severity    TEXT NOT NULL,
-			summary     TEXT NOT NULL,
-			process     TEXT,
-			pid         INTEGER,
-			unit        TEXT,
-			detail      TEXT,
-			raw_json    TEXT,
-			notified    BOOLEAN DEFAULT FALSE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_events_instance_ts ON events(instance_id, timestamp)`,
-		`CREATE INDEX IF NOT EXISTS idx_events_tier ON events(tier, timestamp)`,
-		`CREATE INDEX IF NOT EXISTS idx_events_dedup ON events(instance_id, tier, process, unit)`,
-	}
-
-	for _, m := range migrations {
-		if _, err := db.Exec(m); err != nil {
-			return fmt.Errorf("migration failed: %w\nSQL: %s", err, m)
-		}
-	}
-
-	slog.Debug("database schema up to date")
-	return nil
+// nullString maps "" to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
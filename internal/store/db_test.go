@@ -1,15 +1,32 @@
 package store
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
 )
 
+// postgresDSN, if set, names a PostgreSQL database the tests using testDB
+// run against instead of SQLite, each in a schema of its own, e.g.
+//
+//	LOGTRIAGE_TEST_POSTGRES_DSN='postgres://postgres@localhost/test?sslmode=disable' go test ./internal/store/
+var postgresDSN = os.Getenv("LOGTRIAGE_TEST_POSTGRES_DSN")
+
 func testDB(t *testing.T) *DB {
 	t.Helper()
+	if postgresDSN != "" {
+		return testPostgres(t)
+	}
 	path := filepath.Join(t.TempDir(), "test.db")
 	db, err := Open(path)
 	if err != nil {
@@ -19,6 +36,50 @@ func testDB(t *testing.T) *DB {
 	return db
 }
 
+// testPostgres opens a fresh schema of the database postgresDSN names,
+// dropped when the test ends.
+func testPostgres(t *testing.T) *DB {
+	t.Helper()
+	admin, err := sql.Open("postgres", postgresDSN)
+	if err != nil {
+		t.Fatalf("opening test postgres: %v", err)
+	}
+	schema := fmt.Sprintf("logtriage_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatalf("creating test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("dropping test schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	// lib/pq sends parameters it does not know as run-time settings.
+	dsn := postgresDSN + " search_path=" + schema
+	if u, err := url.Parse(postgresDSN); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	}
+	db, err := OpenPostgres(dsn)
+	if err != nil {
+		t.Fatalf("opening test postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// skipOnPostgres skips a test of something only SQLite databases do.
+func skipOnPostgres(t *testing.T) {
+	t.Helper()
+	if postgresDSN != "" {
+		t.Skip("SQLite only")
+	}
+}
+
 func makeEvent(instanceID, tier, severity, summary, process, unit string) *event.Event {
 	ev := event.New(instanceID, time.Now(), event.Tier(tier), event.Severity(severity), summary)
 	ev.Process = process
@@ -32,6 +93,9 @@ func TestInsertAndQuery(t *testing.T) {
 	ev := makeEvent("host1", "T1", "critical", "OOM Kill: firefox", "firefox", "")
 	ev.Detail = "Firefox was killed"
 	ev.PID = 4521
+	ev.ContainerID = "3c5b1f0e9a2d"
+	ev.ContainerName = "web"
+	ev.CGroup = "/system.slice/docker-3c5b1f0e9a2d.scope"
 
 	if err := db.Insert(ev); err != nil {
 		t.Fatalf("Insert: %v", err)
@@ -67,6 +131,12 @@ func TestInsertAndQuery(t *testing.T) {
 	if got.Detail != "Firefox was killed" {
 		t.Errorf("Detail = %q", got.Detail)
 	}
+	if got.ContainerID != "3c5b1f0e9a2d" || got.ContainerName != "web" {
+		t.Errorf("container = %q %q", got.ContainerID, got.ContainerName)
+	}
+	if got.CGroup != ev.CGroup {
+		t.Errorf("CGroup = %q", got.CGroup)
+	}
 }
 
 func TestQueryFilters(t *testing.T) {
@@ -108,6 +178,18 @@ func TestQueryFilters(t *testing.T) {
 		t.Errorf("instance filter: got %d events, want 1", len(events))
 	}
 
+	// Filter by unit.
+	events, err = db.Query(QueryFilter{
+		Since: time.Now().Add(-1 * time.Hour),
+		Unit:  "docker.service",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != ev4.ID {
+		t.Errorf("unit filter: got %d events, want 1", len(events))
+	}
+
 	// Filter by limit.
 	events, err = db.Query(QueryFilter{
 		Since: time.Now().Add(-1 * time.Hour),
@@ -121,6 +203,118 @@ func TestQueryFilters(t *testing.T) {
 	}
 }
 
+func TestQuerySearch(t *testing.T) {
+	db := testDB(t)
+
+	ev1 := makeEvent("host1", "T1", "critical", "OOM Kill: firefox", "firefox", "")
+	ev1.Detail = "Killed process 4521 (firefox) total-vm:8GB"
+	ev2 := makeEvent("host1", "T3", "medium", "Service failed: backup.service", "restic", "backup.service")
+	ev2.Detail = "Fatal: unable to open repository at /mnt/backup"
+	ev3 := makeEvent("host1", "T6", "warning", "Disk /var at 91% used", "", "")
+	ev3.Detail = "Largest directories: /var/log"
+
+	for _, ev := range []*event.Event{ev1, ev2, ev3} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(f QueryFilter) []string {
+		t.Helper()
+		f.Since = time.Now().Add(-1 * time.Hour)
+		events, err := db.Query(f)
+		if err != nil {
+			t.Fatalf("Query(%+v): %v", f, err)
+		}
+		var ids []string
+		for _, ev := range events {
+			ids = append(ids, ev.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   []string
+	}{
+		{"summary", QueryFilter{Search: "firefox"}, []string{ev1.ID}},
+		{"detail", QueryFilter{Search: "repository"}, []string{ev2.ID}},
+		{"case-insensitive", QueryFilter{Search: "FATAL"}, []string{ev2.ID}},
+		{"all terms", QueryFilter{Search: "backup fatal"}, []string{ev2.ID}},
+		{"no match", QueryFilter{Search: "backup firefox"}, nil},
+		{"syntax is literal", QueryFilter{Search: `"var" OR`}, nil},
+		{"with process", QueryFilter{Search: "killed", Process: "restic"}, nil},
+		{"process", QueryFilter{Process: "restic"}, []string{ev2.ID}},
+		{"severity", QueryFilter{Severity: "warning"}, []string{ev3.ID}},
+	}
+	for _, tt := range tests {
+		got := search(tt.filter)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The LIKE fallback treats wildcards literally.
+	db.fts = false
+	if got := search(QueryFilter{Search: "91%"}); !slices.Equal(got, []string{ev3.ID}) {
+		t.Errorf("LIKE 91%%: got %v, want [%s]", got, ev3.ID)
+	}
+	if got := search(QueryFilter{Search: "%"}); !slices.Equal(got, []string{ev3.ID}) {
+		t.Errorf("LIKE %%: got %v, want [%s]", got, ev3.ID)
+	}
+	if got := search(QueryFilter{Search: "total_vm"}); got != nil {
+		t.Errorf("LIKE total_vm: got %v, want none", got)
+	}
+}
+
+func TestEventsAfter(t *testing.T) {
+	db := testDB(t)
+
+	// Inserted out of timestamp order, as a late agent spool would be.
+	now := time.Now()
+	ev1 := makeEvent("host1", "T1", "critical", "first", "a", "")
+	ev2 := makeEvent("host1", "T2", "high", "second", "b", "")
+	ev2.Timestamp = now.Add(-time.Hour)
+	ev3 := makeEvent("host1", "T3", "medium", "third", "", "c.service")
+	for _, ev := range []*event.Event{ev1, ev2, ev3} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := db.EventsAfter(ev1.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != ev2.ID || events[1].ID != ev3.ID {
+		t.Errorf("EventsAfter = %d events, want second and third in insertion order", len(events))
+	}
+
+	if events, _ := db.EventsAfter(ev1.ID, 1); len(events) != 1 {
+		t.Errorf("limit: got %d events, want 1", len(events))
+	}
+	if events, _ := db.EventsAfter("unknown", 10); events != nil {
+		t.Errorf("unknown ID: got %d events, want none", len(events))
+	}
+}
+
+func TestInsertDuplicate(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T1", "critical", "OOM", "firefox", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(ev); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second Insert = %v, want ErrDuplicate", err)
+	}
+
+	if n, _ := db.Count(); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+}
+
 func TestMarkNotified(t *testing.T) {
 	db := testDB(t)
 
@@ -134,6 +328,58 @@ func TestMarkNotified(t *testing.T) {
 	}
 }
 
+func TestNotificationQueue(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T1", "critical", "OOM", "firefox", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.GetEvent(ev.ID); err != nil || got == nil || got.Summary != "OOM" {
+		t.Fatalf("GetEvent = %+v, %v", got, err)
+	}
+	if got, _ := db.GetEvent("unknown"); got != nil {
+		t.Errorf("GetEvent(unknown) = %+v, want nil", got)
+	}
+
+	base := time.Now()
+	queue := func(sink string, queued, next time.Time) {
+		t.Helper()
+		err := db.QueueNotification(&QueuedNotification{
+			EventID: ev.ID, Sink: sink, Transition: "created", Count: 1, Summary: "[x3] OOM",
+			Attempts: 1, QueuedAt: queued, NextAttempt: next, LastError: "timeout",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue("ntfy", base, base.Add(time.Minute))
+	queue("ntfy", base, base) // already queued for this sink: ignored
+	queue("slack", base.Add(-48*time.Hour), base.Add(-time.Hour))
+
+	due, err := db.DueNotifications(base, 0)
+	if err != nil || len(due) != 1 || due[0].Sink != "slack" || due[0].Summary != "[x3] OOM" {
+		t.Fatalf("DueNotifications = %v, %v, want the slack one", due, err)
+	}
+	if err := db.RescheduleNotification(due[0].ID, base.Add(2*time.Minute), "refused"); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := db.DueNotifications(time.Time{}, 0)
+	if len(all) != 2 || all[0].Sink != "ntfy" || all[1].Attempts != 2 || all[1].LastError != "refused" {
+		t.Errorf("after reschedule: %+v", all)
+	}
+
+	if n, err := db.ExpireNotifications(base.Add(-24 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("ExpireNotifications = %d, %v, want 1", n, err)
+	}
+	if err := db.DeleteNotification(all[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.QueuedNotificationCount(); n != 0 {
+		t.Errorf("QueuedNotificationCount = %d, want 0", n)
+	}
+}
+
 func TestPurge(t *testing.T) {
 	db := testDB(t)
 
@@ -166,6 +412,22 @@ func TestPurge(t *testing.T) {
 	}
 }
 
+func TestCheckWritable(t *testing.T) {
+	db := testDB(t)
+	if err := db.CheckWritable(context.Background()); err != nil {
+		t.Fatalf("CheckWritable on fresh db: %v", err)
+	}
+	// The probe leaves no transaction behind.
+	if err := db.Insert(makeEvent("host1", "T1", "critical", "OOM", "firefox", "")); err != nil {
+		t.Fatalf("Insert after CheckWritable: %v", err)
+	}
+
+	db.Close()
+	if err := db.CheckWritable(context.Background()); err == nil {
+		t.Error("CheckWritable on closed db should fail")
+	}
+}
+
 func TestCount(t *testing.T) {
 	db := testDB(t)
 
@@ -194,6 +456,63 @@ func TestCount(t *testing.T) {
 	}
 }
 
+func TestStored(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T2", "high", "Crash: app (SIGSEGV)", "app", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	// A replayed copy has a new ID but the same journal timestamp.
+	again := makeEvent("host1", "T2", "high", ev.Summary, "app", "")
+	again.Timestamp = ev.Timestamp
+	if stored, err := db.Stored(again); err != nil || !stored {
+		t.Errorf("Stored(copy) = %v, %v; want true", stored, err)
+	}
+
+	again.Timestamp = ev.Timestamp.Add(time.Second)
+	if stored, err := db.Stored(again); err != nil || stored {
+		t.Errorf("Stored(later event) = %v, %v; want false", stored, err)
+	}
+}
+
+func TestDeleteEvents(t *testing.T) {
+	db := testDB(t)
+
+	old := makeEvent("host1", "T5", "warning", "Memory pressure", "", "")
+	old.Timestamp = time.Now().Add(-40 * 24 * time.Hour)
+	recent := makeEvent("host1", "T5", "warning", "Memory pressure", "", "")
+	other := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	for _, ev := range []*event.Event{old, recent, other} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.OpenIncident(old); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.DeleteEvents(QueryFilter{Tier: "T5", Until: time.Now().Add(-30 * 24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d events, want 1", n)
+	}
+	if ev, _ := db.GetEvent(old.ID); ev != nil {
+		t.Error("old T5 event not deleted")
+	}
+	for _, ev := range []*event.Event{recent, other} {
+		if got, _ := db.GetEvent(ev.ID); got == nil {
+			t.Errorf("event %s deleted", ev.Summary)
+		}
+	}
+	if incs, _ := db.ListIncidents(IncidentFilter{}); len(incs) != 0 {
+		t.Errorf("incident of deleted event kept: %+v", incs[0])
+	}
+}
+
 func TestCheckCooldownFirstOccurrence(t *testing.T) {
 	db := testDB(t)
 
@@ -261,6 +580,86 @@ func TestCheckCooldownAggregation(t *testing.T) {
 	}
 }
 
+func TestCheckCooldownIgnoresSelf(t *testing.T) {
+	db := testDB(t)
+
+	// The daemon stores an event before checking its cooldown.
+	ev := makeEvent("host1", "T2", "high", "Crash: vlc", "vlc", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.CheckCooldown(ev, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatalf("CheckCooldown: %v", err)
+	}
+	if !result.ShouldAlert || result.RecentCount != 0 {
+		t.Errorf("stored first occurrence should alert, got %+v", result)
+	}
+}
+
+func TestCheckCooldownStoredThreshold(t *testing.T) {
+	db := testDB(t)
+
+	// Stored before each check, as the daemon does: with the event itself
+	// left out of the count, the aggregate alert is the occurrence after
+	// threshold earlier ones, the 4th for a threshold of 3.
+	var alerted []int
+	for i := 1; i <= 6; i++ {
+		ev := makeEvent("host1", "T2", "high", "Crash: vlc", "vlc", "")
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+		result, err := db.CheckCooldown(ev, 5*time.Minute, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RecentCount != i-1 {
+			t.Errorf("occurrence %d: recent count %d, want %d", i, result.RecentCount, i-1)
+		}
+		if result.ShouldAlert {
+			alerted = append(alerted, i)
+		}
+		if result.Aggregated != (i == 4) {
+			t.Errorf("occurrence %d: aggregated = %v", i, result.Aggregated)
+		}
+	}
+	if len(alerted) != 2 || alerted[0] != 1 || alerted[1] != 4 {
+		t.Errorf("alerted on occurrences %v, want [1 4]", alerted)
+	}
+}
+
+func TestCheckCooldownEscalation(t *testing.T) {
+	db := testDB(t)
+
+	ev1 := makeEvent("host1", "T6", "warning", "Quota warning: user alice", "user alice", "")
+	if err := db.Insert(ev1); err != nil {
+		t.Fatal(err)
+	}
+
+	ev2 := makeEvent("host1", "T6", "high", "Quota exceeded: user alice", "user alice", "")
+	result, err := db.CheckCooldown(ev2, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldAlert || !result.Escalated {
+		t.Errorf("more severe repeat should escalate, got %+v", result)
+	}
+	if err := db.Insert(ev2); err != nil {
+		t.Fatal(err)
+	}
+
+	// A repeat at the already-escalated severity stays suppressed.
+	ev3 := makeEvent("host1", "T6", "high", "Quota exceeded: user alice", "user alice", "")
+	result, err = db.CheckCooldown(ev3, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldAlert {
+		t.Errorf("repeat at same severity should be suppressed, got %+v", result)
+	}
+}
+
 func TestCheckCooldownByUnit(t *testing.T) {
 	db := testDB(t)
 
@@ -290,3 +689,1231 @@ func TestCheckCooldownByUnit(t *testing.T) {
 		t.Error("different unit should alert")
 	}
 }
+
+func TestCheckCooldownKeys(t *testing.T) {
+	db := testDB(t)
+	db.SetDedupKeys(map[string][]string{"t4": {"device"}})
+
+	// Kernel I/O errors have no unit or process; without keys every disk
+	// would share one cooldown.
+	ev1 := makeEvent("host1", "T4", "high", "I/O error on /dev/sda", "", "")
+	ev1.RawFields["_device"] = "sda"
+	if err := db.Insert(ev1); err != nil {
+		t.Fatal(err)
+	}
+
+	ev2 := makeEvent("host1", "T4", "high", "I/O error on /dev/sda", "", "")
+	ev2.RawFields["_device"] = "sda"
+	result, err := db.CheckCooldown(ev2, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldAlert || result.RecentCount != 1 {
+		t.Errorf("same device should be suppressed, got %+v", result)
+	}
+
+	ev3 := makeEvent("host1", "T4", "high", "I/O error on /dev/sdb", "", "")
+	ev3.RawFields["_device"] = "sdb"
+	result, err = db.CheckCooldown(ev3, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldAlert || result.RecentCount != 0 {
+		t.Errorf("different device should alert, got %+v", result)
+	}
+
+	// Other tiers keep the unit/process key.
+	ev4 := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if err := db.Insert(ev4); err != nil {
+		t.Fatal(err)
+	}
+	ev5 := makeEvent("host1", "T3", "medium", "Service failed: b.service", "", "b.service")
+	if result, err = db.CheckCooldown(ev5, 5*time.Minute, 3); err != nil || !result.ShouldAlert {
+		t.Errorf("different unit in unkeyed tier should alert, got %+v, %v", result, err)
+	}
+}
+
+func TestCheckCooldownDedupKey(t *testing.T) {
+	db := testDB(t)
+
+	// The classifier's dedup key keeps disks apart without [cooldown.keys].
+	sda := makeEvent("host1", "T4", "high", "I/O error on /dev/sda", "", "")
+	sda.DedupKey = "device=sda"
+	if err := db.Insert(sda); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetEvent(sda.ID)
+	if err != nil || got.DedupKey != "device=sda" {
+		t.Fatalf("stored dedup key = %q, %v", got.DedupKey, err)
+	}
+
+	again := makeEvent("host1", "T4", "high", "I/O error on /dev/sda", "", "")
+	again.DedupKey = "device=sda"
+	result, err := db.CheckCooldown(again, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldAlert || result.RecentCount != 1 || result.Key != "device=sda" {
+		t.Errorf("same device should be suppressed, got %+v", result)
+	}
+
+	sdb := makeEvent("host1", "T4", "high", "I/O error on /dev/sdb", "", "")
+	sdb.DedupKey = "device=sdb"
+	if result, err = db.CheckCooldown(sdb, 5*time.Minute, 3); err != nil || !result.ShouldAlert {
+		t.Errorf("different device should alert, got %+v, %v", result, err)
+	}
+
+	// A kernel error naming no device is not a repeat of one that does.
+	mce := makeEvent("host1", "T4", "high", "Machine check error", "", "")
+	if result, err = db.CheckCooldown(mce, 5*time.Minute, 3); err != nil || !result.ShouldAlert {
+		t.Errorf("unkeyed event counted keyed ones, got %+v, %v", result, err)
+	}
+
+	// Queued writes are matched the same way.
+	db.StartWriter(16)
+	if err := db.InsertAsync(sdb); err != nil {
+		t.Fatal(err)
+	}
+	sdb2 := makeEvent("host1", "T4", "high", "I/O error on /dev/sdb", "", "")
+	sdb2.DedupKey = "device=sdb"
+	if result, err = db.CheckCooldown(sdb2, 5*time.Minute, 3); err != nil || result.RecentCount != 1 {
+		t.Errorf("queued repeat not counted, got %+v, %v", result, err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	db := testDB(t)
+
+	now := time.Now()
+	segv := func(age time.Duration, summary string) *event.Event {
+		ev := makeEvent("host1", "T4", "high", summary, "", "")
+		ev.Timestamp = now.Add(-age)
+		ev.Fingerprint = event.ComputeFingerprint(ev)
+		return ev
+	}
+	for _, ev := range []*event.Event{
+		segv(40*24*time.Hour, "general protection fault ip:7f3a12"),
+		segv(3*24*time.Hour, "general protection fault ip:7f9b04"),
+		segv(2*time.Minute, "general protection fault ip:55d1a0"),
+		segv(time.Minute, "machine check error"),
+	} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Kernel errors without unit, process, or dedup key repeat by fingerprint.
+	ev := segv(0, "general protection fault ip:7f0000")
+	result, err := db.CheckCooldown(ev, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldAlert || result.RecentCount != 1 || result.Key != "fingerprint="+ev.Fingerprint {
+		t.Errorf("same fingerprint should be suppressed, got %+v", result)
+	}
+	if got := GroupKey(ev); got != "T4|fingerprint:"+ev.Fingerprint {
+		t.Errorf("GroupKey = %q", got)
+	}
+
+	h, err := db.FingerprintHistory(ev, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Count != 2 || !h.First.Equal(now.Add(-3*24*time.Hour).UTC()) || !h.Last.Equal(now.Add(-2*time.Minute).UTC()) {
+		t.Errorf("history = %+v, want the 2 occurrences of the last 30 days", h)
+	}
+}
+
+func TestProcessHistory(t *testing.T) {
+	db := testDB(t)
+
+	now := time.Now()
+	for _, e := range []struct {
+		age     time.Duration
+		tier    string
+		process string
+	}{
+		{10 * 24 * time.Hour, "T1", "firefox"},
+		{2 * 24 * time.Hour, "T1", "firefox"},
+		{time.Hour, "T2", "firefox"},
+		{time.Hour, "T1", "java"},
+	} {
+		ev := makeEvent("host1", e.tier, "critical", "OOM Kill: "+e.process, e.process, "")
+		ev.Timestamp = now.Add(-e.age)
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The same program's OOM kills count whatever their messages say.
+	ev := makeEvent("host1", "T1", "critical", "OOM Kill: firefox (pid 4521)", "firefox", "")
+	ev.Timestamp = now
+	h, err := db.ProcessHistory(ev, now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Count != 1 || !h.Last.Equal(now.Add(-2*24*time.Hour).UTC()) {
+		t.Errorf("week = %+v, want the OOM kill of 2 days ago", h)
+	}
+	if h, _ := db.ProcessHistory(ev, now.Add(-30*24*time.Hour)); h.Count != 2 {
+		t.Errorf("month count = %d, want 2", h.Count)
+	}
+}
+
+func TestCountSimilarAndSetSeverity(t *testing.T) {
+	db := testDB(t)
+
+	now := time.Now()
+	for _, age := range []time.Duration{90 * time.Minute, 40 * time.Minute, 20 * time.Minute, time.Minute} {
+		ev := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+		ev.Timestamp = now.Add(-age)
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := makeEvent("host1", "T3", "medium", "Service failed: b.service", "", "b.service")
+	if err := db.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	n, start, err := db.CountSimilar(ev, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || !start.Equal(ev.Timestamp.Add(-time.Hour)) {
+		t.Errorf("CountSimilar = %d since %v, want 3 since %v", n, start, ev.Timestamp.Add(-time.Hour))
+	}
+
+	if err := db.SetSeverity(ev.ID, event.SevHigh); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetEvent(ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Severity != event.SevHigh {
+		t.Errorf("severity = %q, want high", got.Severity)
+	}
+}
+
+func TestAcks(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+
+	ev := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	a, err := db.AckEvent(ev, "oncall", now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Key != "unit=a.service" {
+		t.Errorf("ack key = %q", a.Key)
+	}
+	got, err := db.GetEvent(ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AckedBy != "oncall" || !got.AckedAt.Equal(now) {
+		t.Errorf("stored ack = %q at %v", got.AckedBy, got.AckedAt)
+	}
+
+	repeat := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if a, err := db.AckedBy(repeat, now); err != nil || a == nil || a.EventID != ev.ID {
+		t.Errorf("repeat should be acked, got %+v, %v", a, err)
+	}
+	if a, _ := db.AckedBy(repeat, now.Add(2*time.Hour)); a != nil {
+		t.Errorf("expired ack still applies: %+v", a)
+	}
+	other := makeEvent("host1", "T3", "medium", "Service failed: b.service", "", "b.service")
+	if a, _ := db.AckedBy(other, now); a != nil {
+		t.Errorf("other unit acked: %+v", a)
+	}
+
+	// Repeats quieted by the ack do not count toward the cooldown, so the
+	// first failure after it ends alerts.
+	if err := db.Insert(repeat); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordDecision(&Decision{EventID: repeat.ID, DecidedAt: now, Outcome: DecisionAcked}); err != nil {
+		t.Fatal(err)
+	}
+	next := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if result, err := db.CheckCooldown(next, 5*time.Minute, 3); err != nil || result.RecentCount != 1 {
+		t.Errorf("cooldown = %+v, %v; want only the acked event counted", result, err)
+	}
+}
+
+func TestDecisions(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T3", "medium", "Service failed: a.service", "", "a.service")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	dedup, err := db.CheckCooldown(ev, 5*time.Minute, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dedup.Key != "unit=a.service" || !dedup.WindowStart.Equal(ev.Timestamp.Add(-5*time.Minute)) {
+		t.Errorf("key = %q, window start = %v", dedup.Key, dedup.WindowStart)
+	}
+
+	if got, err := db.GetDecision(ev.ID); err != nil || got != nil {
+		t.Fatalf("GetDecision before recording = %+v, %v", got, err)
+	}
+	want := &Decision{
+		EventID:     ev.ID,
+		DecidedAt:   time.Now().UTC(),
+		Outcome:     DecisionSuppressed,
+		Reason:      "1 similar event(s) since 12:00:00 were already alerted",
+		RecentCount: 1,
+		Threshold:   3,
+		Window:      5 * time.Minute,
+		WindowStart: dedup.WindowStart.UTC(),
+		Key:         dedup.Key,
+	}
+	if err := db.RecordDecision(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetDecision(ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("GetDecision = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryPagination(t *testing.T) {
+	db := testDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	for i := range 5 {
+		ev := makeEvent("host1", "T2", "high", fmt.Sprintf("Crash: app%d", i), "app", "")
+		ev.Timestamp = now.Add(-time.Duration(i/2) * time.Minute) // pairs share a timestamp
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := db.Query(QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, ev := range all {
+		want = append(want, ev.ID)
+	}
+
+	var paged []string
+	f := QueryFilter{Limit: 2}
+	for {
+		page, next, err := db.QueryPage(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range page {
+			paged = append(paged, ev.ID)
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+		if len(paged) == 2 {
+			// A newer event does not shift a cursor's place.
+			late := makeEvent("host1", "T2", "high", "Crash: late", "app", "")
+			late.Timestamp = now.Add(time.Minute)
+			if err := db.Insert(late); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !slices.Equal(paged, want) {
+		t.Errorf("paged by cursor = %v, want %v", paged, want)
+	}
+	if page, next, err := db.QueryPage(QueryFilter{Limit: 6, Until: now}); err != nil || len(page) != 5 || next != "" {
+		t.Errorf("QueryPage of all = %d events, next %q, %v; want 5 and no next page", len(page), next, err)
+	}
+
+	page, err := db.Query(QueryFilter{Limit: 2, Offset: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != want[3] || page[1].ID != want[4] {
+		t.Errorf("page at offset 4 = %d events, want the 4th and 5th oldest", len(page))
+	}
+	if rest, err := db.Query(QueryFilter{Offset: 5}); err != nil || len(rest) != 1 {
+		t.Errorf("query at offset 5 without a limit = %d events, %v; want 1", len(rest), err)
+	}
+
+	for _, cursor := range []string{"not a cursor!", "bm9waXBl"} {
+		if _, err := db.Query(QueryFilter{Cursor: cursor}); !errors.Is(err, ErrBadCursor) {
+			t.Errorf("Query with cursor %q: err = %v, want ErrBadCursor", cursor, err)
+		}
+	}
+}
+
+func TestAggregates(t *testing.T) {
+	db := testDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	for i, ev := range []*event.Event{
+		makeEvent("host1", "T1", "critical", "OOM Kill: firefox", "firefox", ""),
+		makeEvent("host1", "T1", "critical", "OOM Kill: firefox", "firefox", ""),
+		makeEvent("host1", "T1", "critical", "OOM Kill: chrome", "chrome", ""),
+		makeEvent("host2", "T3", "high", "Service failed: a.service", "", "a.service"),
+		makeEvent("host2", "T4", "critical", "I/O error on sda", "", ""),
+		makeEvent("host2", "T4", "critical", "I/O error on sdb", "", ""),
+		makeEvent("host2", "T4", "critical", "I/O error on sda", "", ""),
+	} {
+		ev.Timestamp = now.Add(-time.Duration(i) * time.Hour)
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tiers, err := db.CountByTier(QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiers) != 3 || tiers[event.TierOOMKill] != 3 || tiers[event.TierServiceFailure] != 1 || tiers[event.TierKernelHW] != 3 {
+		t.Errorf("CountByTier = %v", tiers)
+	}
+	if hosts, err := db.CountByInstance(QueryFilter{}); err != nil || hosts["host1"] != 3 || hosts["host2"] != 4 {
+		t.Errorf("CountByInstance = %v, %v", hosts, err)
+	}
+
+	procs, err := db.TopProcesses(QueryFilter{Tier: "T1"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NameCount{{"firefox", 2}}; !slices.Equal(procs, want) {
+		t.Errorf("TopProcesses = %v, want %v", procs, want)
+	}
+	units, err := db.TopUnits(QueryFilter{InstanceID: "host2"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NameCount{{"", 3}, {"a.service", 1}}; !slices.Equal(units, want) {
+		t.Errorf("TopUnits = %v, want %v", units, want)
+	}
+
+	summaries, err := db.RecentSummaries(QueryFilter{Tier: "T4"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"I/O error on sda", "I/O error on sdb"}; !slices.Equal(summaries, want) {
+		t.Errorf("RecentSummaries = %q, want %q", summaries, want)
+	}
+
+	// Hourly buckets over the last 6 hours; the oldest event is outside.
+	var edges []time.Time
+	for h := 6; h >= 0; h-- {
+		edges = append(edges, now.Add(-time.Duration(h)*time.Hour+time.Second))
+	}
+	counts, err := db.Histogram(QueryFilter{}, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 1, 1, 1, 1, 1}; !slices.Equal(counts, want) {
+		t.Errorf("Histogram = %v, want %v", counts, want)
+	}
+	counts, err = db.Histogram(QueryFilter{Tier: "T1"}, []time.Time{now.Add(-3 * time.Hour), now.Add(-time.Hour), now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !slices.Equal(counts, want) {
+		t.Errorf("Histogram of T1 = %v, want %v", counts, want)
+	}
+}
+
+func TestNotificationAttempts(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T2", "high", "Crash: vlc", "vlc", "")
+	other := makeEvent("host2", "T2", "high", "Crash: mpv", "mpv", "")
+	for _, e := range []*event.Event{ev, other} {
+		if err := db.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC()
+	for _, a := range []*NotificationAttempt{
+		{EventID: ev.ID, Sink: "ntfy", AttemptedAt: now.Add(-2 * time.Hour), Status: 200, Latency: 120 * time.Millisecond, Batched: 1},
+		{EventID: ev.ID, Sink: "ntfy", AttemptedAt: now.Add(-time.Minute), Status: 503, Latency: 2 * time.Second, Batched: 1, Error: "ntfy returned status 503"},
+		{EventID: other.ID, Sink: "email", AttemptedAt: now, Batched: 2},
+	} {
+		if err := db.RecordNotificationAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := db.NotificationAttempts(NotificationFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Sink != "email" || all[0].Summary != "Crash: mpv" || all[0].Batched != 2 {
+		t.Fatalf("attempts = %+v, want most recent first with its event", all)
+	}
+	if a := all[1]; a.Status != 503 || a.Latency != 2*time.Second || a.Error == "" || a.InstanceID != "host1" {
+		t.Errorf("failed attempt = %+v", a)
+	}
+
+	tests := []struct {
+		name   string
+		filter NotificationFilter
+		want   int
+	}{
+		{"since", NotificationFilter{Since: now.Add(-time.Hour)}, 2},
+		{"instance", NotificationFilter{InstanceID: "host1"}, 2},
+		{"event", NotificationFilter{EventID: other.ID}, 1},
+		{"sink", NotificationFilter{Sink: "ntfy"}, 2},
+		{"failed", NotificationFilter{FailedOnly: true}, 1},
+		{"limit", NotificationFilter{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		got, err := db.NotificationAttempts(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: got %d attempts, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	// Attempts go with their event.
+	if _, err := db.DeleteEvents(QueryFilter{InstanceID: "host1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.NotificationAttempts(NotificationFilter{}); err != nil || len(got) != 1 {
+		t.Errorf("attempts after deleting the event = %d, %v; want 1", len(got), err)
+	}
+}
+
+func TestSnoozes(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+
+	for _, s := range []*Snooze{
+		{Tier: "T3", Unit: "nginx.service", CreatedAt: now, Until: now.Add(2 * time.Hour)},
+		{InstanceID: "host2", CreatedAt: now, Until: now.Add(time.Hour)},
+		{CreatedAt: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour)}, // ended
+	} {
+		if _, err := db.AddSnooze(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active, err := db.ActiveSnoozes(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 || active[0].InstanceID != "host2" {
+		t.Errorf("active snoozes = %+v", active)
+	}
+
+	tests := []struct {
+		ev      *event.Event
+		snoozed bool
+	}{
+		{makeEvent("host1", "T3", "medium", "Service failed: nginx.service", "", "nginx.service"), true},
+		{makeEvent("host1", "T3", "medium", "Service failed: sshd.service", "", "sshd.service"), false},
+		{makeEvent("host1", "T2", "high", "Crash: nginx", "nginx", "nginx.service"), false},
+		{makeEvent("host2", "T2", "high", "Crash: vlc", "vlc", ""), true},
+	}
+	for _, tt := range tests {
+		s, err := db.SnoozedBy(tt.ev, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (s != nil) != tt.snoozed {
+			t.Errorf("SnoozedBy(%s) = %+v, want snoozed %v", tt.ev.Summary, s, tt.snoozed)
+		}
+	}
+
+	// A snoozed event does not count toward the cooldown of the next one.
+	ev1 := tests[0].ev
+	if err := db.Insert(ev1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordDecision(&Decision{EventID: ev1.ID, DecidedAt: now, Outcome: DecisionSnoozed}); err != nil {
+		t.Fatal(err)
+	}
+	ev2 := makeEvent("host1", "T3", "medium", "Service failed: nginx.service", "", "nginx.service")
+	if result, err := db.CheckCooldown(ev2, 5*time.Minute, 3); err != nil || !result.ShouldAlert {
+		t.Errorf("event after a snoozed one = %+v, %v; want alert", result, err)
+	}
+
+	if n, err := db.ClearSnoozes("T3", ""); err != nil || n != 1 {
+		t.Errorf("ClearSnoozes(T3) = %d, %v", n, err)
+	}
+	if n, err := db.ClearSnoozes("", ""); err != nil || n != 2 {
+		t.Errorf("ClearSnoozes() = %d, %v", n, err)
+	}
+}
+
+func TestCheckCooldownAfterRecovery(t *testing.T) {
+	db := testDB(t)
+	base := time.Now().Add(-10 * time.Minute)
+
+	failed := makeEvent("host1", "T3", "medium", "Service failed: docker.service", "", "docker.service")
+	failed.Timestamp = base
+	if err := db.Insert(failed); err != nil {
+		t.Fatal(err)
+	}
+	inc, _, err := db.GroupEvent(failed, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A recovery of the unit in every tier, and one for an unrelated process.
+	recovered := base.Add(2 * time.Minute)
+	closed, err := db.RecordRecovery(Recovery{InstanceID: "host1", Unit: "docker.service", At: recovered})
+	if err != nil || len(closed) != 1 || closed[0] != inc.ID {
+		t.Fatalf("RecordRecovery = %v, %v, want incident %s closed", closed, err, inc.ID)
+	}
+	if _, err := db.RecordRecovery(Recovery{InstanceID: "host1", Tier: event.TierProcessCrash, Process: "vlc", At: recovered}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RecordRecovery(Recovery{InstanceID: "host1", At: recovered}); err == nil {
+		t.Error("recovery without a unit or process should fail")
+	}
+
+	got, _ := db.GetIncident(inc.ID)
+	if got.IsOpen() || !got.ClosedAt.Equal(recovered) {
+		t.Errorf("incident closed_at = %v, want %v", got.ClosedAt, recovered)
+	}
+
+	// A new failure after the recovery is a first occurrence again.
+	again := makeEvent("host1", "T3", "medium", "Service failed: docker.service", "", "docker.service")
+	again.Timestamp = base.Add(5 * time.Minute)
+	result, err := db.CheckCooldown(again, time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldAlert || result.RecentCount != 0 {
+		t.Errorf("failure after recovery should alert, got %+v", result)
+	}
+
+	// An event from before the recovery still counts its predecessors.
+	late := makeEvent("host1", "T3", "medium", "Service failed: docker.service", "", "docker.service")
+	late.Timestamp = base.Add(time.Minute)
+	result, err = db.CheckCooldown(late, time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldAlert || result.RecentCount != 1 {
+		t.Errorf("event before recovery should be suppressed, got %+v", result)
+	}
+
+	// An older recovery does not replace a newer one.
+	if _, err := db.RecordRecovery(Recovery{InstanceID: "host1", Unit: "docker.service", At: base}); err != nil {
+		t.Fatal(err)
+	}
+	result, _ = db.CheckCooldown(again, time.Hour, 3)
+	if !result.ShouldAlert {
+		t.Errorf("older recovery replaced newer one, got %+v", result)
+	}
+}
+
+func TestRecoveryByDedupKey(t *testing.T) {
+	db := testDB(t)
+	base := time.Now().Add(-10 * time.Minute)
+
+	failing := makeEvent("host1", "T4", "high", "SMART FAILING: /dev/sda", "", "")
+	failing.DedupKey = "device=/dev/sda"
+	failing.Timestamp = base
+	if err := db.Insert(failing); err != nil {
+		t.Fatal(err)
+	}
+	inc, _, err := db.GroupEvent(failing, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.RecordRecovery(Recovery{InstanceID: "host1", DedupKey: "device=/dev/sda", At: base}); err == nil {
+		t.Error("recovery by dedup key without a tier should fail")
+	}
+	recovered := base.Add(2 * time.Minute)
+	closed, err := db.RecordRecovery(Recovery{InstanceID: "host1", Tier: event.TierKernelHW, DedupKey: "device=/dev/sda", At: recovered})
+	if err != nil || len(closed) != 1 || closed[0] != inc.ID {
+		t.Fatalf("RecordRecovery = %v, %v, want incident %s closed", closed, err, inc.ID)
+	}
+
+	again := makeEvent("host1", "T4", "high", "SMART FAILING: /dev/sda", "", "")
+	again.DedupKey = "device=/dev/sda"
+	again.Timestamp = base.Add(5 * time.Minute)
+	result, err := db.CheckCooldown(again, time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldAlert || result.RecentCount != 0 {
+		t.Errorf("failure after recovery should alert, got %+v", result)
+	}
+}
+
+func TestFirstNotified(t *testing.T) {
+	db := testDB(t)
+	base := time.Now().Add(-time.Hour)
+
+	var inc *Incident
+	var evs []*event.Event
+	for i := range 3 {
+		ev := makeEvent("host1", "T3", "medium", "Service failed: docker.service", "", "docker.service")
+		ev.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := db.GroupEvent(ev, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inc, evs = got, append(evs, ev)
+	}
+
+	if ev, err := db.FirstNotified(inc.ID); err != nil || ev != nil {
+		t.Fatalf("FirstNotified before any notification = %v, %v", ev, err)
+	}
+	for _, ev := range evs[1:] {
+		if err := db.MarkNotified(ev.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev, err := db.FirstNotified(inc.ID)
+	if err != nil || ev == nil || ev.ID != evs[1].ID {
+		t.Errorf("FirstNotified = %v, %v, want event %s", ev, err, evs[1].ID)
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	db := testDB(t)
+
+	ev := makeEvent("host1", "T1", "critical", "OOM Kill: java", "java", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := db.GetCapture(ev.ID); err != nil || c != nil {
+		t.Fatalf("GetCapture before save = %v, %v", c, err)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	want := &Capture{
+		EventID:    ev.ID,
+		IncidentID: "inc-1",
+		Started:    start,
+		Ended:      start.Add(2 * time.Minute),
+		Scope:      "-t java",
+		Journal:    "line 1\nline 2",
+		Samples:    "12:00:00 load=1.00,1.00,1.00",
+	}
+	if err := db.SaveCapture(want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetCapture(ev.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetCapture = %v, %v", got, err)
+	}
+	if got.Journal != want.Journal || got.Scope != want.Scope || got.IncidentID != "inc-1" || !got.Ended.Equal(want.Ended) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// An incident's capture is found by its ID too.
+	if got, err := db.GetCapture("inc-1"); err != nil || got == nil || got.EventID != ev.ID {
+		t.Errorf("GetCapture by incident = %+v, %v", got, err)
+	}
+
+	list, err := db.ListCaptures(0)
+	if err != nil || len(list) != 1 || list[0].EventID != ev.ID || list[0].IncidentID != "inc-1" {
+		t.Errorf("ListCaptures = %v, %v", list, err)
+	}
+}
+
+func TestPurgeRemovesCaptures(t *testing.T) {
+	db := testDB(t)
+
+	old := makeEvent("host1", "T1", "critical", "OOM Kill: java", "java", "")
+	old.Timestamp = time.Now().Add(-48 * time.Hour)
+	if err := db.Insert(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveCapture(&Capture{EventID: old.ID, Started: old.Timestamp, Ended: old.Timestamp}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Purge(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := db.GetCapture(old.ID); c != nil {
+		t.Error("capture of purged event still present")
+	}
+}
+
+func TestRuns(t *testing.T) {
+	db := testDB(t)
+
+	first, err := db.StartRun("host1", []string{"smartctl not found: SMART disk health disabled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Dropped = 3
+	first.ReporterFailures = 1
+	first.Stopped = time.Now()
+	if err := db.SaveRun(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := db.StartRun("host1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.StartRun("host2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := db.Runs("host1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != first.ID || runs[1].ID != second.ID {
+		t.Fatalf("runs = %+v", runs)
+	}
+	r := runs[0]
+	if r.Dropped != 3 || r.ReporterFailures != 1 || r.Stopped.IsZero() || len(r.Warnings) != 1 {
+		t.Errorf("first run = %+v", r)
+	}
+	if !runs[1].Stopped.IsZero() || runs[1].Warnings != nil {
+		t.Errorf("second run = %+v", runs[1])
+	}
+
+	if runs, _ := db.Runs("host1", time.Now().Add(time.Hour)); len(runs) != 0 {
+		t.Errorf("runs after the window = %d, want 0", len(runs))
+	}
+}
+
+func TestCrashHistory(t *testing.T) {
+	db := testDB(t)
+
+	first := makeEvent("host1", "T2", "high", "Crash: app", "app", "")
+	first.RawFields["_crash_signature"] = "aaaa"
+	if err := db.Insert(first); err != nil {
+		t.Fatal(err)
+	}
+
+	same := makeEvent("host1", "T2", "high", "Crash: app", "app", "")
+	same.RawFields["_crash_signature"] = "aaaa"
+	if n, seen, err := db.CrashHistory(same); err != nil || n != 1 || !seen {
+		t.Errorf("same signature: count=%d seen=%v err=%v", n, seen, err)
+	}
+
+	other := makeEvent("host1", "T2", "high", "Crash: app", "app", "")
+	other.RawFields["_crash_signature"] = "bbbb"
+	if err := db.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+	if n, seen, err := db.CrashHistory(other); err != nil || n != 1 || seen {
+		t.Errorf("new signature: count=%d seen=%v err=%v", n, seen, err)
+	}
+
+	unsigned := makeEvent("host1", "T2", "high", "Crash: app", "app", "")
+	if n, seen, _ := db.CrashHistory(unsigned); n != 2 || seen {
+		t.Errorf("no signature: count=%d seen=%v", n, seen)
+	}
+
+	// A signature seen more than once is still just seen.
+	if err := db.Insert(same); err != nil {
+		t.Fatal(err)
+	}
+	third := makeEvent("host1", "T2", "high", "Crash: app", "app", "")
+	third.RawFields["_crash_signature"] = "aaaa"
+	if n, seen, err := db.CrashHistory(third); err != nil || n != 3 || !seen {
+		t.Errorf("repeated signature: count=%d seen=%v err=%v", n, seen, err)
+	}
+}
+
+func TestMetricStats(t *testing.T) {
+	db := testDB(t)
+	now := time.Now()
+
+	samples := []struct {
+		instance, subject string
+		age               time.Duration
+		value             float64
+	}{
+		{"host1", "/dev/sda", 3 * time.Hour, 40},
+		{"host1", "/dev/sda", 2 * time.Hour, 44},
+		{"host1", "/dev/sda", time.Hour, 48},
+		{"host1", "/dev/nvme0", time.Hour, 60},
+		{"host1", "/dev/sda", 10 * 24 * time.Hour, 70}, // outside the window
+		{"host2", "/dev/sda", time.Hour, 90},
+	}
+	for _, s := range samples {
+		if err := db.RecordMetric(s.instance, MetricDiskTemp, s.subject, now.Add(-s.age), s.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.MetricStats("host1", MetricDiskTemp, now.Add(-7*24*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if s := stats[0]; s.Subject != "/dev/nvme0" || s.Samples != 1 || s.Max != 60 {
+		t.Errorf("nvme0 = %+v", s)
+	}
+	if s := stats[1]; s.Subject != "/dev/sda" || s.Samples != 3 || s.Min != 40 || s.Max != 48 || s.Avg != 44 {
+		t.Errorf("sda = %+v", s)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	db := testDB(t)
+
+	data, _, err := db.LoadInventory("host1")
+	if err != nil || data != nil {
+		t.Fatalf("empty LoadInventory = %q, %v", data, err)
+	}
+
+	first := time.Now().Add(-24 * time.Hour).UTC()
+	if err := db.SaveInventory("host1", first, []byte(`{"disks":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	second := first.Add(24 * time.Hour)
+	if err := db.SaveInventory("host1", second, []byte(`{"disks":[{"id":"SER1"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, at, err := db.LoadInventory("host1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"disks":[{"id":"SER1"}]}` || !at.Equal(second) {
+		t.Errorf("LoadInventory = %s at %v, want the latest snapshot at %v", data, at, second)
+	}
+	if data, _, _ := db.LoadInventory("host2"); data != nil {
+		t.Errorf("host2 inventory = %s, want none", data)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	db := testDB(t)
+	db.StartWriter(4)
+
+	var events []*event.Event
+	for i := range 20 {
+		ev := makeEvent("host1", "T4", "high", "I/O error", "", "")
+		ev.Timestamp = ev.Timestamp.Add(time.Duration(i) * time.Millisecond)
+		ev.RawFields["_device"] = "sda"
+		if err := db.InsertAsync(ev); err != nil {
+			t.Fatalf("InsertAsync: %v", err)
+		}
+		if err := db.RecordDecision(&Decision{EventID: ev.ID, DecidedAt: ev.Timestamp, Outcome: DecisionSuppressed}); err != nil {
+			t.Fatalf("RecordDecision: %v", err)
+		}
+		// The pipeline may go on changing its copy.
+		ev.Summary = "changed"
+		events = append(events, ev)
+	}
+
+	// Reads see every queued write.
+	if n, err := db.Count(); err != nil || n != 20 {
+		t.Errorf("Count = %d, %v; want 20", n, err)
+	}
+	got, err := db.GetEvent(events[19].ID)
+	if err != nil || got == nil || got.Summary != "I/O error" || got.RawFields["_device"] != "sda" {
+		t.Errorf("GetEvent = %+v, %v", got, err)
+	}
+	if dec, err := db.GetDecision(events[19].ID); err != nil || dec == nil || dec.Outcome != DecisionSuppressed {
+		t.Errorf("GetDecision = %+v, %v", dec, err)
+	}
+
+	// Close commits what is still queued.
+	last := makeEvent("host1", "T1", "critical", "OOM Kill: java", "java", "")
+	path := filepath.Join(t.TempDir(), "closed.db")
+	db2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2.StartWriter(4)
+	if err := db2.InsertAsync(last); err != nil {
+		t.Fatal(err)
+	}
+	if err := db2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db2, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	if n, _ := db2.Count(); n != 1 {
+		t.Errorf("after Close, Count = %d, want 1", n)
+	}
+}
+
+func TestWriterCloseWhileInserting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "closing.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.StartWriter(4)
+
+	// Writes racing Close are queued before it, or applied directly after
+	// it, or fail once the database is closed; none panics or is lost
+	// while reported stored.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var stored int64
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if err := db.InsertAsync(makeEvent("host1", "T4", "high", "I/O error", "", "")); err == nil {
+					mu.Lock()
+					stored++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n, err := db.Count(); err != nil || n != stored {
+		t.Errorf("Count = %d, %v; want the %d inserts that succeeded", n, err, stored)
+	}
+}
+
+func TestCheckCooldownCountsQueued(t *testing.T) {
+	db := testDB(t)
+
+	// Queue writes by hand, with no writer running to commit them.
+	w := &writer{queue: make(chan pendingWrite, 8), done: make(chan struct{})}
+	w.cond = sync.NewCond(&w.mu)
+	db.w = w
+	t.Cleanup(func() { db.w = nil })
+
+	stored := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	if err := db.Insert(stored); err != nil {
+		t.Fatal(err)
+	}
+	queued := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	snoozed := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	other := makeEvent("host1", "T3", "high", "sshd failed", "", "sshd.service")
+	w.pending = []pendingWrite{
+		{ev: queued},
+		{ev: snoozed},
+		{dec: &Decision{EventID: snoozed.ID, Outcome: DecisionSnoozed}},
+		{ev: other},
+		{ev: stored}, // committed but not yet dropped from the queue
+	}
+
+	ev := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	result, err := db.CheckCooldown(ev, 5*time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RecentCount != 2 {
+		t.Errorf("RecentCount = %d, want 2 (stored and queued, once each)", result.RecentCount)
+	}
+}
+
+func TestCheckCooldownSkipsMuted(t *testing.T) {
+	db := testDB(t)
+
+	// An event muted by a suppression rule was never alerted, so the next
+	// one is not a repeat of it.
+	muted := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	if err := db.Insert(muted); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordDecision(&Decision{EventID: muted.ID, DecidedAt: time.Now(), Outcome: DecisionMuted}); err != nil {
+		t.Fatal(err)
+	}
+	ev := makeEvent("host1", "T3", "high", "nginx failed", "", "nginx.service")
+	if result, err := db.CheckCooldown(ev, 5*time.Minute, 3); err != nil || !result.ShouldAlert || result.RecentCount != 0 {
+		t.Errorf("event after a muted one = %+v, %v; want alert", result, err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := testDB(t)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	var evs []*event.Event
+	for i := range 2 {
+		ev := makeEvent("agent1", "T3", "high", "Service failed: nginx.service", "", "nginx.service")
+		ev.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		ev.PID = 42
+		ev.RawFields["_exit"] = "1"
+		if _, _, err := src.GroupEvent(ev, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+		evs = append(evs, ev)
+	}
+	if err := src.MarkNotified(evs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := src.Export(QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != evs[0].ID || !records[0].Notified || records[1].Notified {
+		t.Fatalf("Export = %+v, want both events oldest first, the first notified", records)
+	}
+	if inc := records[1].Incident; inc == nil || inc.ID != evs[0].IncidentID || inc.GroupKey != "T3|unit:nginx.service" {
+		t.Fatalf("incident = %+v", inc)
+	}
+
+	// Through CSV, as import reads it back.
+	var parsed []*Record
+	for _, r := range records {
+		p, err := ParseCSVRecord(RecordCSVHeader, r.CSVRecord())
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, p)
+	}
+
+	dst := testDB(t)
+	if n, err := dst.Import(parsed); err != nil || n != 2 {
+		t.Fatalf("Import = %d, %v, want 2", n, err)
+	}
+	if n, err := dst.Import(parsed); err != nil || n != 0 {
+		t.Errorf("second Import = %d, %v, want 0", n, err)
+	}
+
+	got, err := dst.Export(QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("imported %d events, want 2", len(got))
+	}
+	for i, r := range got {
+		want := records[i]
+		if r.ID != want.ID || r.Notified != want.Notified || r.IncidentID != want.IncidentID ||
+			r.PID != 42 || r.RawFields["_exit"] != "1" || !r.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("record %d = %+v, want %+v", i, r, want)
+		}
+		if r.Incident == nil || r.Incident.EventCount != 2 || !r.Incident.OpenedAt.Equal(base) {
+			t.Errorf("record %d incident = %+v", i, r.Incident)
+		}
+	}
+	if ev, err := dst.FirstNotified(got[0].IncidentID); err != nil || ev == nil || ev.ID != evs[0].ID {
+		t.Errorf("FirstNotified after import = %v, %v", ev, err)
+	}
+}
+
+func TestBackupRotating(t *testing.T) {
+	skipOnPostgres(t)
+	db := testDB(t)
+	if err := db.Insert(makeEvent("host1", "T1", "critical", "OOM Kill: java", "java", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	if got := LastBackup(dir); !got.IsZero() {
+		t.Errorf("LastBackup of a missing dir = %s", got)
+	}
+	base := time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range 3 {
+		path, err := db.BackupRotating(context.Background(), dir, 2, base.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	if names, _ := listBackups(dir); len(names) != 2 || names[0] != filepath.Base(paths[1]) {
+		t.Errorf("backups = %v, want the newest two", names)
+	}
+	if got := LastBackup(dir); !got.Equal(base.Add(2 * time.Hour)) {
+		t.Errorf("LastBackup = %s", got)
+	}
+
+	backup, err := Open(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if n, err := backup.Count(); err != nil || n != 1 {
+		t.Errorf("backup has %d events, %v; want 1", n, err)
+	}
+	if problems, err := backup.Verify(); err != nil || len(problems) > 0 {
+		t.Errorf("Verify = %v, %v", problems, err)
+	}
+	if err := backup.Vacuum(); err != nil {
+		t.Errorf("Vacuum: %v", err)
+	}
+}
+
+func TestMigrateUnversioned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An events table from before versioning, without the later columns.
+	if _, err := raw.Exec(`CREATE TABLE events (
+		id TEXT PRIMARY KEY, instance_id TEXT NOT NULL, timestamp TEXT NOT NULL,
+		tier TEXT NOT NULL, severity TEXT NOT NULL, summary TEXT NOT NULL,
+		process TEXT, pid INTEGER, unit TEXT, detail TEXT, raw_json TEXT,
+		notified BOOLEAN DEFAULT FALSE)`); err != nil {
+		t.Fatal(err)
+	}
+	raw.Close()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if v, err := db.SchemaVersion(); err != nil || v != LatestSchema {
+		t.Errorf("SchemaVersion = %d, %v, want %d", v, err, LatestSchema)
+	}
+	ev := makeEvent("host1", "T3", "high", "Service failed", "", "nginx.service")
+	ev.DedupKey = "unit=nginx.service"
+	if err := db.Insert(ev); err != nil {
+		t.Errorf("Insert after migrating: %v", err)
+	}
+	db.Close()
+
+	// Reopening applies nothing more.
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	db.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&n)
+	if n != len(migrations) {
+		t.Errorf("%d schema_version rows, want %d", n, len(migrations))
+	}
+
+	// A newer logtriage migrated it further: refuse it.
+	if _, err := db.db.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'future', '')`, LatestSchema+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := Open(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Open of a newer schema = %v, want ErrSchemaTooNew", err)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outcomes of the notification decision for an event.
+const (
+	DecisionAlerted    = "alerted"    // first occurrence in the cooldown window
+	DecisionAggregated = "aggregated" // repeats reached the aggregate threshold
+	DecisionEscalated  = "escalated"  // a repeat more severe than the rest, or past the escalation threshold
+	DecisionSuppressed = "suppressed" // a repeat within the cooldown window
+	DecisionMuted      = "muted"      // a suppression rule or known-crashy process
+	DecisionLooping    = "looping"    // alerted once as its unit's restart loop
+	DecisionHeld       = "held"       // held for quiet hours
+	DecisionSnoozed    = "snoozed"    // covered by a snooze; see AddSnooze
+	DecisionAcked      = "acked"      // a repeat of an acknowledged alert; see AckEvent
+	DecisionForwarded  = "forwarded"  // left to the hub to notify
+	DecisionReplayed   = "replayed"   // stored by logtriage replay without --notify
+)
+
+// Decision records whether an event was notified and why, with the
+// cooldown counts behind it, so a missing or unexpected alert can be
+// explained later.
+type Decision struct {
+	EventID     string
+	DecidedAt   time.Time
+	Outcome     string // one of the Decision constants
+	Reason      string // a sentence explaining the outcome
+	RecentCount int    // similar events in the cooldown window
+	Threshold   int    // aggregate threshold in effect
+	Window      time.Duration
+	WindowStart time.Time // later than DecidedAt-Window after a recovery
+	Key         string    // what similar events share, e.g. "unit=nginx.service"
+	Error       string    // why delivery failed, if it did
+}
+
+// RecordDecision stores the notification decision for an event, replacing
+// any earlier one. With a background writer (see StartWriter) it is
+// queued like the event.
+func (d *DB) RecordDecision(dec *Decision) error {
+	if d.w != nil {
+		cp := *dec
+		return d.enqueue(pendingWrite{dec: &cp})
+	}
+	return recordDecision(d.db, dec)
+}
+
+func recordDecision(x execer, dec *Decision) error {
+	var start string
+	if !dec.WindowStart.IsZero() {
+		start = formatTime(dec.WindowStart)
+	}
+	_, err := x.Exec(`INSERT INTO decisions
+		(event_id, decided_at, outcome, reason, recent_count, threshold, "window", window_start, dedup_key, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (event_id) DO UPDATE SET decided_at = excluded.decided_at, outcome = excluded.outcome,
+			reason = excluded.reason, recent_count = excluded.recent_count, threshold = excluded.threshold,
+			"window" = excluded."window", window_start = excluded.window_start, dedup_key = excluded.dedup_key,
+			error = excluded.error`,
+		dec.EventID, formatTime(dec.DecidedAt), dec.Outcome, dec.Reason, dec.RecentCount,
+		dec.Threshold, dec.Window.String(), start, dec.Key, dec.Error)
+	if err != nil {
+		return fmt.Errorf("recording decision: %w", err)
+	}
+	return nil
+}
+
+// GetDecision returns the notification decision recorded for an event, or
+// nil if there is none.
+func (d *DB) GetDecision(eventID string) (*Decision, error) {
+	d.await(eventID)
+	dec := Decision{EventID: eventID}
+	var decided, window, start string
+	err := d.db.QueryRow(`SELECT decided_at, outcome, reason, recent_count, threshold, "window", window_start, dedup_key, error
+		FROM decisions WHERE event_id = ?`, eventID).Scan(
+		&decided, &dec.Outcome, &dec.Reason, &dec.RecentCount, &dec.Threshold,
+		&window, &start, &dec.Key, &dec.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading decision: %w", err)
+	}
+	dec.DecidedAt, _ = time.Parse(time.RFC3339Nano, decided)
+	dec.Window, _ = time.ParseDuration(window)
+	if start != "" {
+		dec.WindowStart, _ = time.Parse(time.RFC3339Nano, start)
+	}
+	return &dec, nil
+}
@@ -1,9 +1,9 @@
 package store
 
 import (
-	"database/sql"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/setevik/logtriage/internal/event"
@@ -18,40 +18,43 @@ type DedupResult struct {
 	// Aggregated is true if the alert was suppressed during cooldown but the
 	// aggregate threshold was just reached, so a summary alert should fire.
 	Aggregated bool
+	// Escalated is true if the event would be suppressed by cooldown but is
+	// more severe than every similar event in the window.
+	Escalated bool
+	// WindowStart is when counting started: the start of the cooldown
+	// window, or the last recovery if that is later.
+	WindowStart time.Time
+	// Key describes what the similar events share, e.g. "unit=nginx.service".
+	Key string
 }
 
 // CheckCooldown determines whether an event should trigger an alert based on
-// how many similar events (same instance, tier, process/unit) have occurred
-// within the cooldown window.
+// how many similar events have occurred within the cooldown window. Similar
+// events share the instance, the tier, and the tier's key fields (see
+// SetDedupKeys), or by default the event's DedupKey, or the unit, or the
+// process, or when it has none of those its fingerprint. The window starts
+// no earlier than the last recovery of the event's unit or process (see
+// RecordRecovery), so a failure after a genuine recovery is not counted as
+// a repeat of the previous one. Events held back by a snooze, an ack, or a
+// suppression rule are not counted either.
+//
+// The event itself is excluded from the count, so it may be checked either
+// before or after it is inserted.
 //
 // Logic:
 //   - If no prior events within window: alert (first occurrence).
-//   - If prior events exist but count < threshold: suppress (within cooldown).
 //   - If count == threshold: alert as aggregated (crash-looping summary).
+//   - If the event is more severe than all prior events: alert as escalated.
+//   - If prior events exist but count < threshold: suppress (within cooldown).
 //   - If count > threshold: suppress (already sent aggregate alert).
 func (d *DB) CheckCooldown(ev *event.Event, window time.Duration, threshold int) (DedupResult, error) {
-	since := ev.Timestamp.Add(-window).UTC().Format(time.RFC3339Nano)
-
-	// Build dedup key: match on instance + tier + (process or unit).
-	query := `SELECT COUNT(*) FROM events
-		WHERE instance_id = ? AND tier = ? AND timestamp >= ?`
-	args := []interface{}{ev.InstanceID, string(ev.Tier), since}
-
-	if ev.Unit != "" {
-		query += " AND unit = ?"
-		args = append(args, ev.Unit)
-	} else if ev.Process != "" {
-		query += " AND process = ?"
-		args = append(args, ev.Process)
-	}
-
-	var count int
-	err := d.db.QueryRow(query, args...).Scan(&count)
-	if err != nil && err != sql.ErrNoRows {
+	count, maxRank, start, err := d.similar(ev, window)
+	if err != nil {
 		return DedupResult{}, fmt.Errorf("checking cooldown: %w", err)
 	}
+	keys := d.keyFields(ev.Tier)
 
-	result := DedupResult{RecentCount: count}
+	result := DedupResult{RecentCount: count, WindowStart: start, Key: describeKey(ev, keys)}
 
 	switch {
 	case count == 0:
@@ -61,6 +64,10 @@ func (d *DB) CheckCooldown(ev *event.Event, window time.Duration, threshold int)
 		// Hit the aggregate threshold — send a summary alert.
 		result.ShouldAlert = true
 		result.Aggregated = true
+	case ev.Severity.Rank() > maxRank:
+		// Same problem, but worse than anything alerted so far.
+		result.ShouldAlert = true
+		result.Escalated = true
 	default:
 		// Within cooldown (either still accumulating or already aggregated).
 		result.ShouldAlert = false
@@ -70,6 +77,7 @@ func (d *DB) CheckCooldown(ev *event.Event, window time.Duration, threshold int)
 		"tier", ev.Tier,
 		"process", ev.Process,
 		"unit", ev.Unit,
+		"keys", keys,
 		"recent_count", count,
 		"threshold", threshold,
 		"should_alert", result.ShouldAlert,
@@ -77,3 +85,212 @@ func (d *DB) CheckCooldown(ev *event.Event, window time.Duration, threshold int)
 
 	return result, nil
 }
+
+// CountSimilar returns how many events within window before ev are
+// repeats of it, counted as CheckCooldown counts them, and when counting
+// started.
+func (d *DB) CountSimilar(ev *event.Event, window time.Duration) (int, time.Time, error) {
+	count, _, start, err := d.similar(ev, window)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("counting similar events: %w", err)
+	}
+	return count, start, nil
+}
+
+// similar counts the repeats of ev within window, or since the last
+// recovery if that is later, and returns the highest severity rank among
+// them and when counting started.
+func (d *DB) similar(ev *event.Event, window time.Duration) (count, maxRank int, start time.Time, err error) {
+	start = ev.Timestamp.Add(-window)
+	since := start.UTC().Format(time.RFC3339Nano)
+	recovered, err := d.lastRecovery(ev)
+	if err != nil {
+		return 0, 0, start, err
+	}
+	if recovered > since && recovered <= formatTime(ev.Timestamp) {
+		since = recovered
+		start, _ = time.Parse(time.RFC3339Nano, recovered)
+	}
+
+	// Build dedup key: match on instance + tier + the tier's key fields,
+	// or the event's own dedup key, unit, or process when the tier has none
+	// configured.
+	// Snoozed, acked, and muted events were never alerted, so they must not
+	// make the first failure after a maintenance window, an ack, or a
+	// suppression rule look like a repeat.
+	query := `SELECT ` + eventColumns + ` FROM events
+		WHERE instance_id = ? AND tier = ? AND timestamp >= ? AND id != ?
+		AND id NOT IN (SELECT event_id FROM decisions WHERE outcome IN (?, ?, ?))`
+	args := []interface{}{ev.InstanceID, string(ev.Tier), since, ev.ID, DecisionSnoozed, DecisionAcked, DecisionMuted}
+
+	keys := d.keyFields(ev.Tier)
+	if keys == nil {
+		// Events with a dedup key are about something more specific than
+		// their unit or process, so they only repeat each other.
+		switch {
+		case ev.DedupKey != "":
+			query += " AND dedup_key = ?"
+			args = append(args, ev.DedupKey)
+		case ev.Unit != "":
+			query += " AND dedup_key IS NULL AND unit = ?"
+			args = append(args, ev.Unit)
+		case ev.Process != "":
+			query += " AND dedup_key IS NULL AND process = ?"
+			args = append(args, ev.Process)
+		case ev.Fingerprint != "":
+			query += " AND dedup_key IS NULL AND COALESCE(unit, '') = '' AND COALESCE(process, '') = '' AND fingerprint = ?"
+			args = append(args, ev.Fingerprint)
+		default:
+			query += " AND dedup_key IS NULL"
+		}
+	}
+
+	// Writes still queued for the background writer are counted without
+	// waiting for them. They are read first, so a write committed during
+	// the query is seen one way or the other, and counted once.
+	queued := d.queued()
+	quiet := make(map[string]bool)
+	for _, pw := range queued {
+		if pw.dec == nil {
+			continue
+		}
+		switch pw.dec.Outcome {
+		case DecisionSnoozed, DecisionAcked, DecisionMuted:
+			quiet[pw.dec.EventID] = true
+		}
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return 0, 0, start, err
+	}
+	defer rows.Close()
+
+	counted := make(map[string]bool)
+	for rows.Next() {
+		prior, err := scanEvent(rows)
+		if err != nil {
+			return 0, 0, start, err
+		}
+		if !sameKey(ev, prior, keys) || quiet[prior.ID] {
+			continue
+		}
+		counted[prior.ID] = true
+		count++
+		maxRank = max(maxRank, prior.Severity.Rank())
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, start, err
+	}
+
+	for _, pw := range queued {
+		prior := pw.ev
+		if prior == nil || prior.ID == ev.ID || counted[prior.ID] || quiet[prior.ID] ||
+			prior.InstanceID != ev.InstanceID || prior.Tier != ev.Tier || formatTime(prior.Timestamp) < since {
+			continue
+		}
+		if keys == nil && prior.DedupKey != ev.DedupKey {
+			continue
+		}
+		if keys == nil && ev.DedupKey == "" && ((ev.Unit != "" && prior.Unit != ev.Unit) ||
+			(ev.Unit == "" && ev.Process != "" && prior.Process != ev.Process)) {
+			continue
+		}
+		if keys == nil && ev.DedupKey == "" && ev.Unit == "" && ev.Process == "" && ev.Fingerprint != "" &&
+			(prior.Unit != "" || prior.Process != "" || prior.Fingerprint != ev.Fingerprint) {
+			continue
+		}
+		if !sameKey(ev, prior, keys) {
+			continue
+		}
+		counted[prior.ID] = true
+		count++
+		maxRank = max(maxRank, prior.Severity.Rank())
+	}
+	return count, maxRank, start, nil
+}
+
+// SetDedupKeys sets, per tier, the fields whose values must all match for
+// CheckCooldown to count an earlier event as a repeat. A field is "unit",
+// "process", "container", "cgroup", "summary", or a raw field name; a raw
+// field is also looked up with a leading underscore, so "device" matches
+// the _device field classifiers record and "match_dev" a rule's "dev"
+// capture group; "fingerprint" is the event's fingerprint. Tiers without
+// keys use the event's DedupKey, the unit, the process, or the fingerprint.
+func (d *DB) SetDedupKeys(keys map[string][]string) {
+	m := make(map[string][]string, len(keys))
+	for tier, fields := range keys {
+		m[strings.ToUpper(tier)] = fields
+	}
+	d.mu.Lock()
+	d.dedupKeys = m
+	d.mu.Unlock()
+}
+
+// keyFields returns the dedup key fields configured for tier, or nil.
+func (d *DB) keyFields(tier event.Tier) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.dedupKeys[string(tier)]
+}
+
+// sameKey reports whether a and b agree on every key field. Without key
+// fields the query has already matched them.
+func sameKey(a, b *event.Event, fields []string) bool {
+	for _, f := range fields {
+		if keyValue(a, f) != keyValue(b, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// DedupKey returns the dedup key of ev as "field=value" pairs, e.g.
+// "unit=nginx.service": what its repeats share besides instance and tier.
+func (d *DB) DedupKey(ev *event.Event) string {
+	return describeKey(ev, d.keyFields(ev.Tier))
+}
+
+// describeKey returns the dedup key of ev as "field=value" pairs.
+func describeKey(ev *event.Event, fields []string) string {
+	if fields == nil {
+		switch {
+		case ev.DedupKey != "":
+			return ev.DedupKey
+		case ev.Unit != "":
+			return "unit=" + ev.Unit
+		case ev.Process != "":
+			return "process=" + ev.Process
+		case ev.Fingerprint != "":
+			return "fingerprint=" + ev.Fingerprint
+		}
+		return ""
+	}
+	pairs := make([]string, len(fields))
+	for i, f := range fields {
+		pairs[i] = f + "=" + keyValue(ev, f)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// keyValue returns the value of a dedup key field of ev.
+func keyValue(ev *event.Event, field string) string {
+	switch field {
+	case "unit":
+		return ev.Unit
+	case "process":
+		return ev.Process
+	case "container":
+		return ev.ContainerName
+	case "cgroup":
+		return ev.CGroup
+	case "summary":
+		return ev.Summary
+	case "fingerprint":
+		return ev.Fingerprint
+	}
+	if v, ok := ev.RawFields[field]; ok {
+		return v
+	}
+	return ev.RawFields["_"+field]
+}
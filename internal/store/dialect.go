@@ -0,0 +1,165 @@
+package store
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dialect describes how a database engine differs from the SQL the store
+// is written in, which is SQLite's with ? placeholders.
+type dialect struct {
+	name string // "sqlite" or "postgres"
+
+	// numbered placeholders ($1, $2, ...) rather than ?.
+	numbered bool
+
+	// seq is the column giving the events' insertion order.
+	seq string
+
+	// strpos finds a substring: instr in SQLite, strpos in PostgreSQL.
+	// Both take the string first.
+	strpos string
+
+	// like is the case-insensitive LIKE operator.
+	like string
+
+	// columns lists a table's column names, given the table name.
+	columns string
+
+	// beginWrite starts a transaction that must be able to write.
+	beginWrite string
+
+	// ddl rewrites a CREATE or ALTER statement for the engine.
+	ddl func(stmt string) string
+
+	// jsonText returns the expression for a top-level text field of the
+	// JSON object in column.
+	jsonText func(column, field string) string
+}
+
+var sqliteDialect = &dialect{
+	name:       "sqlite",
+	seq:        "rowid",
+	strpos:     "instr",
+	like:       "LIKE",
+	columns:    `SELECT name FROM pragma_table_info(?)`,
+	beginWrite: `BEGIN IMMEDIATE`,
+	ddl:        func(stmt string) string { return stmt },
+	jsonText: func(column, field string) string {
+		return "json_extract(" + column + ", '$." + field + "')"
+	},
+}
+
+var postgresDialect = &dialect{
+	name:       "postgres",
+	numbered:   true,
+	seq:        "seq",
+	strpos:     "strpos",
+	like:       "ILIKE",
+	columns:    `SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?`,
+	beginWrite: `BEGIN READ WRITE`,
+	ddl:        postgresDDL,
+	jsonText: func(column, field string) string {
+		return "(" + column + "::jsonb ->> '" + field + "')"
+	},
+}
+
+var (
+	autoincrementRe = regexp.MustCompile(`\bINTEGER PRIMARY KEY AUTOINCREMENT\b`)
+	integerRe       = regexp.MustCompile(`\bINTEGER\b`)
+	realRe          = regexp.MustCompile(`\bREAL\b`)
+	textRe          = regexp.MustCompile(`\bTEXT\b`)
+
+	// reservedColumnRe matches the definitions of columns named after
+	// words PostgreSQL reserves and SQLite does not. Shipped migrations
+	// cannot change, so they are quoted here.
+	reservedColumnRe = regexp.MustCompile(`(?m)^(\s*)(window)(\s)`)
+)
+
+// postgresDDL rewrites SQLite column types for PostgreSQL. Text compares
+// byte by byte, as in SQLite, so timestamps stored as RFC 3339 text sort
+// in time order whatever the database's locale.
+func postgresDDL(stmt string) string {
+	stmt = reservedColumnRe.ReplaceAllString(stmt, `$1"$2"$3`)
+	stmt = autoincrementRe.ReplaceAllString(stmt, "BIGSERIAL PRIMARY KEY")
+	stmt = integerRe.ReplaceAllString(stmt, "BIGINT")
+	stmt = realRe.ReplaceAllString(stmt, "DOUBLE PRECISION")
+	return textRe.ReplaceAllString(stmt, `TEXT COLLATE "C"`)
+}
+
+// rebind rewrites the ? placeholders of query for the dialect. Question
+// marks in quoted strings and identifiers are left alone.
+func (dl *dialect) rebind(query string) string {
+	if !dl.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// conn is the store's database handle. Statements are written with ?
+// placeholders, which it rewrites for the dialect.
+type conn struct {
+	*sql.DB
+	dialect *dialect
+}
+
+func (c *conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.DB.Exec(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.DB.Query(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.DB.QueryRow(c.dialect.rebind(query), args...)
+}
+
+func (c *conn) Begin() (*txn, error) {
+	t, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: t, dialect: c.dialect}, nil
+}
+
+// txn is a transaction on a conn, rewriting placeholders the same way.
+type txn struct {
+	*sql.Tx
+	dialect *dialect
+}
+
+func (t *txn) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *txn) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *txn) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), args...)
+}
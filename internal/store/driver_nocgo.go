@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package store
+
+import "context"
+
+// Driver is empty in builds without cgo: go-sqlite3 needs a C toolchain and
+// no pure-Go SQLite is compiled in instead, so Open fails with ErrNoDriver
+// and only OpenPostgres can store events.
+const Driver = ""
+
+func backupFile(context.Context, string, string) error {
+	return ErrNoDriver
+}
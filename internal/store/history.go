@@ -0,0 +1,56 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// History describes the earlier occurrences of a problem.
+type History struct {
+	Count int       // earlier occurrences in the period
+	First time.Time // earliest of them; zero if none
+	Last  time.Time // latest of them; zero if none
+}
+
+// FingerprintHistory returns the events on ev's instance with ev's
+// fingerprint from since up to ev. The event itself is excluded, so it may
+// be checked either before or after it is inserted.
+func (d *DB) FingerprintHistory(ev *event.Event, since time.Time) (History, error) {
+	if ev.Fingerprint == "" {
+		return History{}, nil
+	}
+	return d.history(ev, since, "fingerprint = ?", ev.Fingerprint)
+}
+
+// ProcessHistory returns the events on ev's instance of ev's tier and
+// process from since up to ev, such as the earlier OOM kills of the same
+// program whatever their messages. The event itself is excluded.
+func (d *DB) ProcessHistory(ev *event.Event, since time.Time) (History, error) {
+	if ev.Process == "" {
+		return History{}, nil
+	}
+	return d.history(ev, since, "tier = ? AND process = ?", string(ev.Tier), ev.Process)
+}
+
+func (d *DB) history(ev *event.Event, since time.Time, where string, args ...any) (History, error) {
+	d.flush()
+	var h History
+	var first, last sql.NullString
+	args = append(args, ev.InstanceID, formatTime(since), formatTime(ev.Timestamp), ev.ID)
+	err := d.db.QueryRow(`
+		SELECT COUNT(*), MIN(timestamp), MAX(timestamp) FROM events
+		WHERE `+where+` AND instance_id = ? AND timestamp >= ? AND timestamp <= ? AND id != ?`,
+		args...,
+	).Scan(&h.Count, &first, &last)
+	if err != nil {
+		return History{}, fmt.Errorf("reading event history: %w", err)
+	}
+	if first.Valid {
+		h.First, _ = time.Parse(time.RFC3339Nano, first.String)
+		h.Last, _ = time.Parse(time.RFC3339Nano, last.String)
+	}
+	return h, nil
+}
@@ -0,0 +1,339 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Incident groups related events: repeats of the same problem on one host
+// (same tier and unit or process) that keep arriving without a long gap.
+type Incident struct {
+	ID         string         `json:"id"`
+	InstanceID string         `json:"instance_id"`
+	GroupKey   string         `json:"group_key"`
+	Tier       event.Tier     `json:"tier"`
+	Severity   event.Severity `json:"severity"` // highest severity of any attached event
+	Title      string         `json:"title"`    // summary of the opening event
+	OpenedAt   time.Time      `json:"opened_at"`
+	LastSeen   time.Time      `json:"last_seen"`
+	ClosedAt   time.Time      `json:"closed_at,omitzero"` // zero while open
+	EventCount int            `json:"event_count"`        // filled by GetIncident and ListIncidents
+}
+
+// IsOpen reports whether the incident has not been closed.
+func (i *Incident) IsOpen() bool {
+	return i.ClosedAt.IsZero()
+}
+
+// GroupKey returns the key shared by events that belong to the same
+// incident on a host: the tier plus the event's DedupKey, or the unit, or
+// the process, or the fingerprint when it has none of those. It mirrors
+// the default cooldown dedup key.
+func GroupKey(ev *event.Event) string {
+	if ev.DedupKey != "" {
+		return string(ev.Tier) + "|" + ev.DedupKey
+	}
+	if s := groupSubject(ev.Unit, ev.Process); s != "" {
+		return string(ev.Tier) + "|" + s
+	}
+	if ev.Fingerprint != "" {
+		return string(ev.Tier) + "|fingerprint:" + ev.Fingerprint
+	}
+	return string(ev.Tier)
+}
+
+// groupSubject returns the part of a group key after the tier: "unit:" and
+// the unit, or "process:" and the process when there is no unit.
+func groupSubject(unit, process string) string {
+	switch {
+	case unit != "":
+		return "unit:" + unit
+	case process != "":
+		return "process:" + process
+	}
+	return ""
+}
+
+// OpenIncident creates an incident starting at ev and attaches ev to it.
+func (d *DB) OpenIncident(ev *event.Event) (*Incident, error) {
+	inc := &Incident{
+		ID:         uuid.New().String(),
+		InstanceID: ev.InstanceID,
+		GroupKey:   GroupKey(ev),
+		Tier:       ev.Tier,
+		Severity:   ev.Severity,
+		Title:      ev.Summary,
+		OpenedAt:   ev.Timestamp,
+		LastSeen:   ev.Timestamp,
+	}
+
+	_, err := d.db.Exec(`
+		INSERT INTO incidents (id, instance_id, group_key, tier, severity, title, opened_at, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		inc.ID,
+		inc.InstanceID,
+		inc.GroupKey,
+		string(inc.Tier),
+		string(inc.Severity),
+		inc.Title,
+		formatTime(inc.OpenedAt),
+		formatTime(inc.LastSeen),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("opening incident: %w", err)
+	}
+
+	if err := d.AttachEvent(inc.ID, ev); err != nil {
+		return nil, err
+	}
+	inc.EventCount = 1
+	return inc, nil
+}
+
+// AttachEvent adds ev to an incident, raising the incident's severity and
+// last-seen time as needed. The event's IncidentID is set; if the event is
+// already stored, its row is updated too.
+func (d *DB) AttachEvent(incidentID string, ev *event.Event) error {
+	inc, err := d.GetIncident(incidentID)
+	if err != nil {
+		return err
+	}
+	if inc == nil {
+		return fmt.Errorf("attaching event: incident %s not found", incidentID)
+	}
+
+	sev := inc.Severity
+	if ev.Severity.Rank() > sev.Rank() {
+		sev = ev.Severity
+	}
+	lastSeen := inc.LastSeen
+	if ev.Timestamp.After(lastSeen) {
+		lastSeen = ev.Timestamp
+	}
+
+	if _, err := d.db.Exec(`UPDATE incidents SET severity = ?, last_seen = ? WHERE id = ?`,
+		string(sev), formatTime(lastSeen), incidentID); err != nil {
+		return fmt.Errorf("attaching event: %w", err)
+	}
+	d.await(ev.ID)
+	if _, err := d.db.Exec(`UPDATE events SET incident_id = ? WHERE id = ?`, incidentID, ev.ID); err != nil {
+		return fmt.Errorf("attaching event: %w", err)
+	}
+	ev.IncidentID = incidentID
+	return nil
+}
+
+// FirstNotified returns the earliest event of an incident that was
+// notified, or nil if none was.
+func (d *DB) FirstNotified(incidentID string) (*event.Event, error) {
+	d.flush()
+	rows, err := d.db.Query(`SELECT `+eventColumns+` FROM events
+		WHERE incident_id = ? AND notified ORDER BY timestamp LIMIT 1`, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("loading notified event: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanEvent(rows)
+}
+
+// CloseIncident marks an incident closed at the given time. Closing an
+// already-closed incident is a no-op.
+func (d *DB) CloseIncident(id string, at time.Time) error {
+	_, err := d.db.Exec(`UPDATE incidents SET closed_at = ? WHERE id = ? AND closed_at IS NULL`,
+		formatTime(at), id)
+	if err != nil {
+		return fmt.Errorf("closing incident: %w", err)
+	}
+	return nil
+}
+
+// CloseIdleIncidents closes open incidents with no event since before.
+// Each is closed at its last event. It returns the IDs of those closed.
+func (d *DB) CloseIdleIncidents(before time.Time) ([]string, error) {
+	rows, err := d.db.Query(`UPDATE incidents SET closed_at = last_seen
+		WHERE closed_at IS NULL AND last_seen < ? RETURNING id`, formatTime(before))
+	if err != nil {
+		return nil, fmt.Errorf("closing idle incidents: %w", err)
+	}
+	ids, err := scanIDs(rows)
+	if err != nil {
+		return nil, fmt.Errorf("closing idle incidents: %w", err)
+	}
+	return ids, nil
+}
+
+// scanIDs reads a single text column from every row and closes rows.
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GroupEvent attaches ev to the open incident for its group key if that
+// incident has seen an event within idle, and otherwise opens a new one.
+// It returns the incident and whether it was newly opened.
+func (d *DB) GroupEvent(ev *event.Event, idle time.Duration) (*Incident, bool, error) {
+	inc, err := d.findOpenIncident(ev.InstanceID, GroupKey(ev))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if inc != nil && ev.Timestamp.Sub(inc.LastSeen) <= idle {
+		if err := d.AttachEvent(inc.ID, ev); err != nil {
+			return nil, false, err
+		}
+		// Re-read it for the severity and last-seen time ev may have raised.
+		inc, err = d.GetIncident(inc.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		return inc, false, nil
+	}
+
+	// A stale open incident for this group ends where it went quiet.
+	if inc != nil {
+		if err := d.CloseIncident(inc.ID, inc.LastSeen); err != nil {
+			return nil, false, err
+		}
+	}
+	inc, err = d.OpenIncident(ev)
+	if err != nil {
+		return nil, false, err
+	}
+	return inc, true, nil
+}
+
+func (d *DB) findOpenIncident(instanceID, key string) (*Incident, error) {
+	rows, err := d.db.Query(`SELECT `+incidentColumns+` FROM incidents i
+		WHERE instance_id = ? AND group_key = ? AND closed_at IS NULL
+		ORDER BY opened_at DESC LIMIT 1`, instanceID, key)
+	if err != nil {
+		return nil, fmt.Errorf("finding open incident: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanIncident(rows)
+}
+
+// GetIncident returns an incident by ID, or nil if it does not exist.
+func (d *DB) GetIncident(id string) (*Incident, error) {
+	rows, err := d.db.Query(`SELECT `+incidentColumns+` FROM incidents i WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("loading incident: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanIncident(rows)
+}
+
+// IncidentFilter controls which incidents are returned by ListIncidents.
+type IncidentFilter struct {
+	Since      time.Time // incidents with activity at or after this time
+	InstanceID string
+	Tier       string
+	OpenOnly   bool
+	Limit      int
+}
+
+// ListIncidents returns incidents matching the filter, most recently
+// active first.
+func (d *DB) ListIncidents(f IncidentFilter) ([]*Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents i WHERE 1=1`
+	var args []interface{}
+
+	if !f.Since.IsZero() {
+		query += " AND last_seen >= ?"
+		args = append(args, formatTime(f.Since))
+	}
+	if f.InstanceID != "" {
+		query += " AND instance_id = ?"
+		args = append(args, f.InstanceID)
+	}
+	if f.Tier != "" {
+		query += " AND tier = ?"
+		args = append(args, f.Tier)
+	}
+	if f.OpenOnly {
+		query += " AND closed_at IS NULL"
+	}
+
+	query += " ORDER BY last_seen DESC"
+
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []*Incident
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, rows.Err()
+}
+
+// incidentColumns is the column list scanIncident expects; the incidents
+// table must be aliased as i.
+const incidentColumns = `id, instance_id, group_key, tier, severity, title, opened_at, last_seen, closed_at,
+	(SELECT COUNT(*) FROM events e WHERE e.incident_id = i.id)`
+
+func scanIncident(rows *sql.Rows) (*Incident, error) {
+	var inc Incident
+	var opened, lastSeen string
+	var closed sql.NullString
+
+	err := rows.Scan(
+		&inc.ID,
+		&inc.InstanceID,
+		&inc.GroupKey,
+		&inc.Tier,
+		&inc.Severity,
+		&inc.Title,
+		&opened,
+		&lastSeen,
+		&closed,
+		&inc.EventCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("scanning incident row: %w", err)
+	}
+
+	inc.OpenedAt, _ = time.Parse(time.RFC3339Nano, opened)
+	inc.LastSeen, _ = time.Parse(time.RFC3339Nano, lastSeen)
+	if closed.Valid {
+		inc.ClosedAt, _ = time.Parse(time.RFC3339Nano, closed.String)
+	}
+	return &inc, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+func TestIncidentLifecycle(t *testing.T) {
+	db := testDB(t)
+	base := time.Now().Add(-time.Hour)
+
+	first := makeEvent("host1", "T2", "high", "Crash: vlc", "vlc", "")
+	first.Timestamp = base
+	if err := db.Insert(first); err != nil {
+		t.Fatal(err)
+	}
+	inc, err := db.OpenIncident(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.IncidentID != inc.ID || !inc.IsOpen() {
+		t.Fatalf("incident = %+v, event incident = %q", inc, first.IncidentID)
+	}
+
+	second := makeEvent("host1", "T2", "critical", "Crash: vlc", "vlc", "")
+	second.Timestamp = base.Add(time.Minute)
+	if err := db.Insert(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AttachEvent(inc.ID, second); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetIncident(inc.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetIncident = %v, %v", got, err)
+	}
+	if got.EventCount != 2 || got.Severity != event.SevCritical || !got.LastSeen.Equal(second.Timestamp) {
+		t.Errorf("after attach: %+v", got)
+	}
+
+	// Events come back with their incident and can be filtered by it.
+	other := makeEvent("host1", "T2", "high", "Crash: mpv", "mpv", "")
+	db.Insert(other)
+	events, err := db.Query(QueryFilter{IncidentID: inc.ID})
+	if err != nil || len(events) != 2 || events[0].IncidentID != inc.ID {
+		t.Errorf("Query by incident = %d events, %v", len(events), err)
+	}
+
+	closedAt := base.Add(10 * time.Minute)
+	if err := db.CloseIncident(inc.ID, closedAt); err != nil {
+		t.Fatal(err)
+	}
+	// A second close does not move the close time.
+	db.CloseIncident(inc.ID, closedAt.Add(time.Hour))
+	got, _ = db.GetIncident(inc.ID)
+	if got.IsOpen() || !got.ClosedAt.Equal(closedAt) {
+		t.Errorf("closed_at = %v, want %v", got.ClosedAt, closedAt)
+	}
+}
+
+func TestGroupEvent(t *testing.T) {
+	db := testDB(t)
+	base := time.Now().Add(-time.Hour)
+	idle := 5 * time.Minute
+
+	group := func(ts time.Time, process string) (*Incident, bool) {
+		t.Helper()
+		ev := makeEvent("host1", "T2", "high", "Crash: "+process, process, "")
+		ev.Timestamp = ts
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+		inc, opened, err := db.GroupEvent(ev, idle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inc, opened
+	}
+
+	a, opened := group(base, "vlc")
+	if !opened {
+		t.Fatal("first event should open an incident")
+	}
+	b, opened := group(base.Add(3*time.Minute), "vlc")
+	if opened || b.ID != a.ID {
+		t.Error("event within idle window should join the open incident")
+	}
+	if !b.LastSeen.Equal(base.Add(3*time.Minute)) || b.EventCount != 2 {
+		t.Errorf("joined incident = %+v, want it to include the new event", b)
+	}
+	c, opened := group(base.Add(4*time.Minute), "mpv")
+	if !opened || c.ID == a.ID {
+		t.Error("different process should open its own incident")
+	}
+
+	// After a quiet gap the old incident closes and a new one opens.
+	d, opened := group(base.Add(20*time.Minute), "vlc")
+	if !opened || d.ID == a.ID {
+		t.Error("event after idle gap should open a new incident")
+	}
+	old, _ := db.GetIncident(a.ID)
+	if old.IsOpen() || !old.ClosedAt.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("stale incident = %+v, want closed at its last event", old)
+	}
+
+	closed, err := db.CloseIdleIncidents(base.Add(30 * time.Minute))
+	if err != nil || len(closed) != 2 || slices.Contains(closed, a.ID) {
+		t.Errorf("CloseIdleIncidents = %v, %v, want the 2 still open", closed, err)
+	}
+
+	all, err := db.ListIncidents(IncidentFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListIncidents = %d, %v", len(all), err)
+	}
+	if all[0].ID != d.ID {
+		t.Errorf("incidents not ordered by last activity: first = %s", all[0].Title)
+	}
+	open, _ := db.ListIncidents(IncidentFilter{OpenOnly: true})
+	if len(open) != 0 {
+		t.Errorf("open incidents = %d, want 0", len(open))
+	}
+}
+
+func TestMigrateAddsIncidentColumn(t *testing.T) {
+	// A database created before incidents existed gains the column on open.
+	path := filepath.Join(t.TempDir(), "old.db")
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = raw.Exec(`CREATE TABLE events (
+		id TEXT PRIMARY KEY, instance_id TEXT NOT NULL, timestamp TEXT NOT NULL,
+		tier TEXT NOT NULL, severity TEXT NOT NULL, summary TEXT NOT NULL,
+		process TEXT, pid INTEGER, unit TEXT, detail TEXT, raw_json TEXT,
+		notified BOOLEAN DEFAULT FALSE)`)
+	raw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open on old schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ev := makeEvent("host1", "T1", "critical", "OOM Kill: java", "java", "")
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.GroupEvent(ev, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening is idempotent.
+	db.Close()
+	if db, err = Open(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+}
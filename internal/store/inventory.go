@@ -0,0 +1,35 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveInventory stores the latest hardware inventory snapshot of an
+// instance, replacing the previous one. data is the snapshot as JSON.
+func (d *DB) SaveInventory(instanceID string, at time.Time, data []byte) error {
+	_, err := d.db.Exec(`INSERT INTO inventory (instance_id, taken_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (instance_id) DO UPDATE SET taken_at = excluded.taken_at, data = excluded.data`,
+		instanceID, formatTime(at), string(data))
+	if err != nil {
+		return fmt.Errorf("saving inventory: %w", err)
+	}
+	return nil
+}
+
+// LoadInventory returns the latest hardware inventory snapshot of an
+// instance and when it was taken, or nil data if there is none.
+func (d *DB) LoadInventory(instanceID string) ([]byte, time.Time, error) {
+	var data, at string
+	err := d.db.QueryRow(`SELECT data, taken_at FROM inventory WHERE instance_id = ?`, instanceID).Scan(&data, &at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("loading inventory: %w", err)
+	}
+	taken, _ := time.Parse(time.RFC3339Nano, at)
+	return []byte(data), taken, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metric names recorded by the monitors.
+const (
+	MetricDiskTemp = "disk_temp" // SMART drive temperature in degrees C, by device
+)
+
+// MetricStats summarizes one subject's samples of a metric over a window.
+type MetricStats struct {
+	Subject string  `json:"subject"`
+	Samples int     `json:"samples"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+}
+
+// RecordMetric stores one sample of a numeric metric, such as a drive's
+// temperature, for trends in the digest.
+func (d *DB) RecordMetric(instanceID, name, subject string, ts time.Time, value float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO metrics (instance_id, name, subject, timestamp, value)
+		VALUES (?, ?, ?, ?, ?)`,
+		instanceID, name, subject, formatTime(ts), value,
+	)
+	if err != nil {
+		return fmt.Errorf("recording metric %s: %w", name, err)
+	}
+	return nil
+}
+
+// MetricStats returns the min, max, and average of a metric per subject for
+// samples in [since, until), ordered by subject.
+func (d *DB) MetricStats(instanceID, name string, since, until time.Time) ([]MetricStats, error) {
+	rows, err := d.db.Query(`
+		SELECT subject, COUNT(*), MIN(value), MAX(value), AVG(value)
+		FROM metrics
+		WHERE instance_id = ? AND name = ? AND timestamp >= ? AND timestamp < ?
+		GROUP BY subject ORDER BY subject`,
+		instanceID, name, formatTime(since), formatTime(until),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying metric %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	var stats []MetricStats
+	for rows.Next() {
+		var s MetricStats
+		if err := rows.Scan(&s.Subject, &s.Samples, &s.Min, &s.Max, &s.Avg); err != nil {
+			return nil, fmt.Errorf("scanning metric row: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrSchemaTooNew is returned by Open when the database was migrated by a
+// newer logtriage than this one, whose schema this build cannot safely
+// read or write.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// migration is one step of the schema's history. Each step runs in a
+// transaction with its schema_version row, so it is applied whole or not
+// at all. Steps must be idempotent: a database from before versioning
+// already has some of them.
+type migration struct {
+	version int
+	name    string
+	apply   func(tx *txn) error
+}
+
+// migrations is the schema's history, in order. Append new steps with the
+// next version; never change or reorder shipped ones.
+var migrations = []migration{
+	{1, "initial tables", execAll(initialTables...)},
+	{2, "event incident, container, ack, dedup key, and fingerprint columns", func(tx *txn) error {
+		for _, col := range []string{"incident_id", "container_id", "container_name", "cgroup", "acked_by", "acked_at", "dedup_key", "fingerprint"} {
+			if err := addColumn(tx, "events", col, "TEXT"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{3, "event incident, dedup key, and fingerprint indexes", execAll(
+		`CREATE INDEX IF NOT EXISTS idx_events_incident ON events(incident_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_dedup_key ON events(instance_id, tier, dedup_key, timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_fingerprint ON events(instance_id, fingerprint, timestamp)`,
+	)},
+	{4, "event insertion sequence", func(tx *txn) error {
+		// SQLite's rowid already orders events by insertion.
+		if tx.dialect.seq == "rowid" {
+			return nil
+		}
+		return addColumn(tx, "events", tx.dialect.seq, "BIGSERIAL")
+	}},
+	{5, "notification audit log", execAll(
+		`CREATE TABLE IF NOT EXISTS notification_log (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id     TEXT NOT NULL,
+			sink         TEXT NOT NULL,
+			attempted_at TEXT NOT NULL,
+			status       INTEGER NOT NULL,
+			latency_ms   INTEGER NOT NULL,
+			batched      INTEGER NOT NULL,
+			error        TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_log_at ON notification_log(attempted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_log_event ON notification_log(event_id)`,
+	)},
+	{6, "capture incident", func(tx *txn) error {
+		if err := addColumn(tx, "captures", "incident_id", "TEXT"); err != nil {
+			return err
+		}
+		return execAll(`CREATE INDEX IF NOT EXISTS idx_captures_incident ON captures(incident_id)`)(tx)
+	}},
+}
+
+// LatestSchema is the schema version this build migrates databases to.
+var LatestSchema = migrations[len(migrations)-1].version
+
+// initialTables are the tables and indexes of the first versioned schema.
+var initialTables = []string{
+	`CREATE TABLE IF NOT EXISTS events (
+		id          TEXT PRIMARY KEY,
+		instance_id TEXT NOT NULL,
+		timestamp   TEXT NOT NULL,
+		tier        TEXT NOT NULL,
+		severity    TEXT NOT NULL,
+		summary     TEXT NOT NULL,
+		process     TEXT,
+		pid         INTEGER,
+		unit        TEXT,
+		detail      TEXT,
+		raw_json    TEXT,
+		notified    BOOLEAN DEFAULT FALSE
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_instance_ts ON events(instance_id, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_tier ON events(tier, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_dedup ON events(instance_id, tier, process, unit)`,
+	`CREATE TABLE IF NOT EXISTS captures (
+		event_id TEXT PRIMARY KEY,
+		started  TEXT NOT NULL,
+		ended    TEXT NOT NULL,
+		scope    TEXT,
+		journal  TEXT,
+		samples  TEXT
+	)`,
+	`CREATE TABLE IF NOT EXISTS incidents (
+		id          TEXT PRIMARY KEY,
+		instance_id TEXT NOT NULL,
+		group_key   TEXT NOT NULL,
+		tier        TEXT NOT NULL,
+		severity    TEXT NOT NULL,
+		title       TEXT NOT NULL,
+		opened_at   TEXT NOT NULL,
+		last_seen   TEXT NOT NULL,
+		closed_at   TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_incidents_open ON incidents(instance_id, group_key, closed_at)`,
+	`CREATE TABLE IF NOT EXISTS runs (
+		id                TEXT PRIMARY KEY,
+		instance_id       TEXT NOT NULL,
+		started_at        TEXT NOT NULL,
+		heartbeat         TEXT NOT NULL,
+		stopped_at        TEXT,
+		dropped           INTEGER NOT NULL DEFAULT 0,
+		reporter_failures INTEGER NOT NULL DEFAULT 0,
+		warnings          TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_runs_instance ON runs(instance_id, heartbeat)`,
+	`CREATE TABLE IF NOT EXISTS metrics (
+		instance_id TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		subject     TEXT NOT NULL,
+		timestamp   TEXT NOT NULL,
+		value       REAL NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_metrics_name_ts ON metrics(instance_id, name, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS recoveries (
+		instance_id  TEXT NOT NULL,
+		tier         TEXT NOT NULL,
+		subject      TEXT NOT NULL,
+		recovered_at TEXT NOT NULL,
+		PRIMARY KEY (instance_id, tier, subject)
+	)`,
+	`CREATE TABLE IF NOT EXISTS notification_queue (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id     TEXT NOT NULL,
+		sink         TEXT NOT NULL,
+		transition   TEXT NOT NULL,
+		count        INTEGER NOT NULL,
+		summary      TEXT NOT NULL,
+		attempts     INTEGER NOT NULL,
+		queued_at    TEXT NOT NULL,
+		next_attempt TEXT NOT NULL,
+		last_error   TEXT NOT NULL,
+		UNIQUE (event_id, sink)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_notification_queue_next ON notification_queue(next_attempt)`,
+	`CREATE TABLE IF NOT EXISTS decisions (
+		event_id     TEXT PRIMARY KEY,
+		decided_at   TEXT NOT NULL,
+		outcome      TEXT NOT NULL,
+		reason       TEXT NOT NULL,
+		recent_count INTEGER NOT NULL,
+		threshold    INTEGER NOT NULL,
+		window       TEXT NOT NULL,
+		window_start TEXT NOT NULL,
+		dedup_key    TEXT NOT NULL,
+		error        TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS snoozes (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		instance_id TEXT NOT NULL,
+		tier        TEXT NOT NULL,
+		unit        TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		until       TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acks (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		instance_id TEXT NOT NULL,
+		tier        TEXT NOT NULL,
+		dedup_key   TEXT NOT NULL,
+		event_id    TEXT NOT NULL,
+		acked_by    TEXT NOT NULL,
+		acked_at    TEXT NOT NULL,
+		until       TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS inventory (
+		instance_id TEXT PRIMARY KEY,
+		taken_at    TEXT NOT NULL,
+		data        TEXT NOT NULL
+	)`,
+}
+
+// migrate brings the schema up to LatestSchema, applying the steps the
+// database has not had. It refuses a database with a newer schema.
+func migrate(db *conn) error {
+	if _, err := db.Exec(db.dialect.ddl(`CREATE TABLE IF NOT EXISTS schema_version (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)); err != nil {
+		return fmt.Errorf("creating schema_version: %w", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestSchema {
+		return fmt.Errorf("%w: the database is at version %d, this build knows up to %d; upgrade logtriage",
+			ErrSchemaTooNew, current, LatestSchema)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		slog.Info("database migrated", "version", m.version, "migration", m.name)
+	}
+
+	slog.Debug("database schema up to date", "version", LatestSchema)
+	return nil
+}
+
+func applyMigration(db *conn, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, formatTime(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the newest version applied to db, 0 for none.
+func schemaVersion(db *conn) (int, error) {
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return int(v.Int64), nil
+}
+
+// SchemaVersion returns the schema version of the database.
+func (d *DB) SchemaVersion() (int, error) {
+	return schemaVersion(d.db)
+}
+
+// execAll returns a migration step running stmts in order.
+func execAll(stmts ...string) func(tx *txn) error {
+	return func(tx *txn) error {
+		for _, stmt := range stmts {
+			stmt = tx.dialect.ddl(stmt)
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("%w\nSQL: %s", err, stmt)
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn adds a column to an existing table unless it is already there.
+func addColumn(tx *txn, table, column, decl string) error {
+	rows, err := tx.Query(tx.dialect.columns, table)
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("inspecting %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(tx.dialect.ddl(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))); err != nil {
+		return fmt.Errorf("adding %s.%s: %w", table, column, err)
+	}
+	return nil
+}
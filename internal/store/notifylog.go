@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// NotificationAttempt is one delivery of an event's notification to a
+// sink, successful or not, kept so the alerts that actually went out can
+// be audited and a flaky sink told apart from a quiet one.
+type NotificationAttempt struct {
+	ID          int64         `json:"id"`
+	EventID     string        `json:"event_id"`
+	Sink        string        `json:"sink"` // reporter name, e.g. "ntfy"
+	AttemptedAt time.Time     `json:"attempted_at"`
+	Status      int           `json:"status,omitempty"` // HTTP status; 0 if none, e.g. for email
+	Latency     time.Duration `json:"latency_ns"`
+	Batched     int           `json:"batched"`         // events in the message, more than 1 if merged
+	Error       string        `json:"error,omitempty"` // empty if delivered
+
+	// Filled by NotificationAttempts from the event, if still stored.
+	InstanceID string     `json:"instance_id,omitempty"`
+	Tier       event.Tier `json:"tier,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+}
+
+// RecordNotificationAttempt adds a delivery attempt to the notification
+// audit log.
+func (d *DB) RecordNotificationAttempt(a *NotificationAttempt) error {
+	_, err := d.db.Exec(`INSERT INTO notification_log
+		(event_id, sink, attempted_at, status, latency_ms, batched, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.EventID, a.Sink, formatTime(a.AttemptedAt), a.Status, a.Latency.Milliseconds(), a.Batched, a.Error)
+	if err != nil {
+		return fmt.Errorf("recording notification attempt: %w", err)
+	}
+	return nil
+}
+
+// NotificationFilter controls which attempts NotificationAttempts returns.
+type NotificationFilter struct {
+	Since      time.Time
+	InstanceID string
+	EventID    string
+	Sink       string
+	FailedOnly bool
+	Limit      int
+}
+
+// NotificationAttempts returns the logged delivery attempts f matches,
+// most recent first.
+func (d *DB) NotificationAttempts(f NotificationFilter) ([]*NotificationAttempt, error) {
+	query := `SELECT n.id, n.event_id, n.sink, n.attempted_at, n.status, n.latency_ms, n.batched, n.error,
+			COALESCE(e.instance_id, ''), COALESCE(e.tier, ''), COALESCE(e.summary, '')
+		FROM notification_log n LEFT JOIN events e ON e.id = n.event_id WHERE 1=1`
+	var args []interface{}
+	if !f.Since.IsZero() {
+		query += " AND n.attempted_at >= ?"
+		args = append(args, formatTime(f.Since))
+	}
+	if f.InstanceID != "" {
+		query += " AND e.instance_id = ?"
+		args = append(args, f.InstanceID)
+	}
+	if f.EventID != "" {
+		query += " AND n.event_id = ?"
+		args = append(args, f.EventID)
+	}
+	if f.Sink != "" {
+		query += " AND n.sink = ?"
+		args = append(args, f.Sink)
+	}
+	if f.FailedOnly {
+		query += " AND n.error != ''"
+	}
+	query += " ORDER BY n.attempted_at DESC, n.id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying notification log: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*NotificationAttempt
+	for rows.Next() {
+		var a NotificationAttempt
+		var at string
+		var latency int64
+		if err := rows.Scan(&a.ID, &a.EventID, &a.Sink, &at, &a.Status, &latency, &a.Batched, &a.Error,
+			&a.InstanceID, &a.Tier, &a.Summary); err != nil {
+			return nil, fmt.Errorf("scanning notification attempt: %w", err)
+		}
+		a.AttemptedAt, _ = time.Parse(time.RFC3339Nano, at)
+		a.Latency = time.Duration(latency) * time.Millisecond
+		out = append(out, &a)
+	}
+	return out, rows.Err()
+}
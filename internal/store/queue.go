@@ -0,0 +1,114 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueuedNotification is a notification a sink failed to deliver, kept so it
+// can be retried after a network or server outage instead of being lost.
+type QueuedNotification struct {
+	ID          int64
+	EventID     string
+	Sink        string // reporter name, e.g. "ntfy"
+	Transition  string // lifecycle transition, e.g. "created"
+	Count       int    // similar events in the cooldown window
+	Summary     string // the summary as notified, e.g. with an aggregate count
+	Attempts    int    // deliveries tried so far, including the first
+	QueuedAt    time.Time
+	NextAttempt time.Time
+	LastError   string
+}
+
+// QueueNotification stores a failed notification for retry. A notification
+// already queued for the same event and sink is left as it is.
+func (d *DB) QueueNotification(q *QueuedNotification) error {
+	_, err := d.db.Exec(`INSERT INTO notification_queue
+		(event_id, sink, transition, count, summary, attempts, queued_at, next_attempt, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (event_id, sink) DO NOTHING`,
+		q.EventID, q.Sink, q.Transition, q.Count, q.Summary, q.Attempts,
+		formatTime(q.QueuedAt), formatTime(q.NextAttempt), q.LastError)
+	if err != nil {
+		return fmt.Errorf("queueing notification: %w", err)
+	}
+	return nil
+}
+
+// DueNotifications returns up to limit queued notifications whose next
+// attempt is at or before now, most overdue first. A zero now returns every
+// queued notification.
+func (d *DB) DueNotifications(now time.Time, limit int) ([]*QueuedNotification, error) {
+	query := `SELECT id, event_id, sink, transition, count, summary, attempts, queued_at, next_attempt, last_error
+		FROM notification_queue`
+	var args []interface{}
+	if !now.IsZero() {
+		query += " WHERE next_attempt <= ?"
+		args = append(args, formatTime(now))
+	}
+	query += " ORDER BY next_attempt, id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("loading queued notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*QueuedNotification
+	for rows.Next() {
+		var q QueuedNotification
+		var queued, next string
+		if err := rows.Scan(&q.ID, &q.EventID, &q.Sink, &q.Transition, &q.Count, &q.Summary,
+			&q.Attempts, &queued, &next, &q.LastError); err != nil {
+			return nil, fmt.Errorf("scanning queued notification: %w", err)
+		}
+		q.QueuedAt, _ = time.Parse(time.RFC3339Nano, queued)
+		q.NextAttempt, _ = time.Parse(time.RFC3339Nano, next)
+		out = append(out, &q)
+	}
+	return out, rows.Err()
+}
+
+// RescheduleNotification records another failed attempt at a queued
+// notification and when to try next.
+func (d *DB) RescheduleNotification(id int64, next time.Time, lastErr string) error {
+	_, err := d.db.Exec(`UPDATE notification_queue
+		SET attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?`,
+		formatTime(next), lastErr, id)
+	if err != nil {
+		return fmt.Errorf("rescheduling notification: %w", err)
+	}
+	return nil
+}
+
+// DeleteNotification removes a notification from the queue, once delivered
+// or given up on.
+func (d *DB) DeleteNotification(id int64) error {
+	if _, err := d.db.Exec(`DELETE FROM notification_queue WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting queued notification: %w", err)
+	}
+	return nil
+}
+
+// ExpireNotifications gives up on notifications queued before cutoff and
+// returns how many were dropped.
+func (d *DB) ExpireNotifications(cutoff time.Time) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM notification_queue WHERE queued_at < ?`, formatTime(cutoff))
+	if err != nil {
+		return 0, fmt.Errorf("expiring queued notifications: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// QueuedNotificationCount returns how many notifications await retry.
+func (d *DB) QueuedNotificationCount() (int, error) {
+	var n int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM notification_queue`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting queued notifications: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Recovery marks the end of a problem on a host: a failed unit running
+// again, a GPU back under its temperature limit. Events before a recovery
+// no longer count toward the cooldown of the events after it, so a new
+// failure alerts as a first occurrence.
+type Recovery struct {
+	InstanceID string
+	Tier       event.Tier // empty applies to every tier
+	Unit       string
+	Process    string // the subject when there is no unit
+	At         time.Time
+
+	// DedupKey is the DedupKey of the events the recovery ends, for events
+	// grouped by one, such as SMART failures. It needs a Tier, and is the
+	// subject when there is no unit or process.
+	DedupKey string
+}
+
+// RecordRecovery stores a recovery and closes the open incidents it ends,
+// returning their IDs. Only the latest recovery per instance, tier, and
+// subject is kept.
+func (d *DB) RecordRecovery(r Recovery) ([]string, error) {
+	subject := recoverySubject(r.Unit, r.Process, r.DedupKey)
+	if subject == "" {
+		return nil, errors.New("recording recovery: no unit, process, or dedup key")
+	}
+	if r.DedupKey != "" && r.Tier == "" {
+		return nil, errors.New("recording recovery: a dedup key needs a tier")
+	}
+	at := formatTime(r.At)
+
+	_, err := d.db.Exec(`INSERT INTO recoveries (instance_id, tier, subject, recovered_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (instance_id, tier, subject) DO UPDATE SET recovered_at = excluded.recovered_at
+		WHERE excluded.recovered_at > recoveries.recovered_at`,
+		r.InstanceID, string(r.Tier), subject, at)
+	if err != nil {
+		return nil, fmt.Errorf("recording recovery: %w", err)
+	}
+
+	// Incidents ended by the recovery close when it happened. Group keys are
+	// "<tier>|<subject>", so without a tier any tier's key matches.
+	query := `UPDATE incidents SET closed_at = ?
+		WHERE instance_id = ? AND closed_at IS NULL AND opened_at <= ?`
+	args := []interface{}{at, r.InstanceID, at}
+	switch {
+	case r.DedupKey != "":
+		query += " AND group_key IN (?, ?)"
+		args = append(args, string(r.Tier)+"|"+r.DedupKey, string(r.Tier)+"|"+subject)
+	case r.Tier != "":
+		query += " AND group_key = ?"
+		args = append(args, string(r.Tier)+"|"+subject)
+	default:
+		query += " AND substr(group_key, " + d.db.dialect.strpos + "(group_key, '|') + 1) = ?"
+		args = append(args, subject)
+	}
+	rows, err := d.db.Query(query+" RETURNING id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("recording recovery: %w", err)
+	}
+	closed, err := scanIDs(rows)
+	if err != nil {
+		return nil, fmt.Errorf("recording recovery: %w", err)
+	}
+	return closed, nil
+}
+
+// lastRecovery returns when the subject of ev last recovered, as stored, or
+// "" if it never has. Recoveries recorded for ev's tier and for every tier
+// both apply.
+func (d *DB) lastRecovery(ev *event.Event) (string, error) {
+	subject := recoverySubject(ev.Unit, ev.Process, ev.DedupKey)
+	if subject == "" {
+		return "", nil
+	}
+	var at sql.NullString
+	err := d.db.QueryRow(`SELECT MAX(recovered_at) FROM recoveries
+		WHERE instance_id = ? AND tier IN (?, '') AND subject = ?`,
+		ev.InstanceID, string(ev.Tier), subject).Scan(&at)
+	if err != nil {
+		return "", fmt.Errorf("loading last recovery: %w", err)
+	}
+	return at.String, nil
+}
+
+// recoverySubject returns what a recovery is stored under: the group
+// subject of the unit or process, or the dedup key when there is neither.
+func recoverySubject(unit, process, dedupKey string) string {
+	if s := groupSubject(unit, process); s != "" {
+		return s
+	}
+	if dedupKey != "" {
+		return "dedup:" + dedupKey
+	}
+	return ""
+}
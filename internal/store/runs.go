@@ -0,0 +1,98 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Run is one lifetime of the daemon process, kept so the digest can report
+// on logtriage's own health: restarts, unclean exits, and losses.
+type Run struct {
+	ID               string
+	InstanceID       string
+	Started          time.Time
+	Heartbeat        time.Time // last time the run saved its counters
+	Stopped          time.Time // zero until a clean shutdown
+	Dropped          int64
+	ReporterFailures int64
+	Warnings         []string // capability warnings logged at startup
+}
+
+// StartRun records the start of a daemon run.
+func (d *DB) StartRun(instanceID string, warnings []string) (*Run, error) {
+	now := time.Now()
+	r := &Run{
+		ID:         uuid.NewString(),
+		InstanceID: instanceID,
+		Started:    now,
+		Heartbeat:  now,
+		Warnings:   warnings,
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO runs (id, instance_id, started_at, heartbeat, dropped, reporter_failures, warnings)
+		VALUES (?, ?, ?, ?, 0, 0, ?)`,
+		r.ID, r.InstanceID, formatTime(now), formatTime(now), strings.Join(warnings, "\n"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("recording run start: %w", err)
+	}
+	return r, nil
+}
+
+// SaveRun stores a run's counters and stop time, and advances its heartbeat.
+func (d *DB) SaveRun(r *Run) error {
+	r.Heartbeat = time.Now()
+	var stopped sql.NullString
+	if !r.Stopped.IsZero() {
+		stopped = nullString(formatTime(r.Stopped))
+	}
+	_, err := d.db.Exec(`
+		UPDATE runs SET heartbeat = ?, stopped_at = ?, dropped = ?, reporter_failures = ?
+		WHERE id = ?`,
+		formatTime(r.Heartbeat), stopped, r.Dropped, r.ReporterFailures, r.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("saving run: %w", err)
+	}
+	return nil
+}
+
+// Runs returns the runs of an instance that were alive at or after since,
+// oldest first.
+func (d *DB) Runs(instanceID string, since time.Time) ([]*Run, error) {
+	rows, err := d.db.Query(`
+		SELECT id, instance_id, started_at, heartbeat, stopped_at, dropped, reporter_failures, warnings
+		FROM runs WHERE instance_id = ? AND heartbeat >= ?
+		ORDER BY started_at`,
+		instanceID, formatTime(since),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	for rows.Next() {
+		var r Run
+		var started, heartbeat string
+		var stopped, warnings sql.NullString
+		if err := rows.Scan(&r.ID, &r.InstanceID, &started, &heartbeat, &stopped,
+			&r.Dropped, &r.ReporterFailures, &warnings); err != nil {
+			return nil, fmt.Errorf("scanning run row: %w", err)
+		}
+		r.Started, _ = time.Parse(time.RFC3339Nano, started)
+		r.Heartbeat, _ = time.Parse(time.RFC3339Nano, heartbeat)
+		if stopped.Valid {
+			r.Stopped, _ = time.Parse(time.RFC3339Nano, stopped.String)
+		}
+		if warnings.String != "" {
+			r.Warnings = strings.Split(warnings.String, "\n")
+		}
+		runs = append(runs, &r)
+	}
+	return runs, rows.Err()
+}
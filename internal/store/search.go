@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ftsStatements create the FTS5 index over event summaries and details and
+// the triggers that keep it in step with the events table.
+var ftsStatements = []string{
+	`CREATE VIRTUAL TABLE events_fts USING fts5(summary, detail, content='events', content_rowid='rowid')`,
+	`CREATE TRIGGER IF NOT EXISTS events_fts_insert AFTER INSERT ON events BEGIN
+		INSERT INTO events_fts(rowid, summary, detail) VALUES (new.rowid, new.summary, new.detail);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS events_fts_delete AFTER DELETE ON events BEGIN
+		INSERT INTO events_fts(events_fts, rowid, summary, detail) VALUES ('delete', old.rowid, old.summary, old.detail);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS events_fts_update AFTER UPDATE OF summary, detail ON events BEGIN
+		INSERT INTO events_fts(events_fts, rowid, summary, detail) VALUES ('delete', old.rowid, old.summary, old.detail);
+		INSERT INTO events_fts(rowid, summary, detail) VALUES (new.rowid, new.summary, new.detail);
+	END`,
+	`INSERT INTO events_fts(events_fts) VALUES ('rebuild')`,
+}
+
+// setupFTS creates the full-text index if this SQLite build has FTS5 and
+// reports whether it is available. Without FTS5 (go-sqlite3 needs the
+// sqlite_fts5 build tag), and on PostgreSQL, searches fall back to LIKE.
+func setupFTS(db *conn) (bool, error) {
+	if db.dialect.name != "sqlite" {
+		return false, nil
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'events_fts'`).Scan(&n); err != nil {
+		return false, fmt.Errorf("checking for full-text index: %w", err)
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	for _, stmt := range ftsStatements {
+		if _, err := tx.Exec(stmt); err != nil {
+			if strings.Contains(err.Error(), "no such module") {
+				slog.Debug("SQLite built without FTS5, using LIKE for search")
+				return false, nil
+			}
+			return false, fmt.Errorf("creating full-text index: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("creating full-text index: %w", err)
+	}
+	slog.Info("database migrated", "added", "full-text index")
+	return true, nil
+}
+
+// searchClause returns the WHERE condition and arguments matching events
+// whose summary or detail contain every whitespace-separated term of
+// search, case-insensitively. With FTS5 each term is a prefix match on
+// whole words; with LIKE it is a substring match.
+func (d *DB) searchClause(search string) (string, []interface{}) {
+	terms := strings.Fields(search)
+	if len(terms) == 0 {
+		return "", nil
+	}
+
+	if d.fts {
+		quoted := make([]string, len(terms))
+		for i, t := range terms {
+			// Quoting makes FTS5 syntax characters in the input literal.
+			quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"*`
+		}
+		return " AND rowid IN (SELECT rowid FROM events_fts WHERE events_fts MATCH ?)",
+			[]interface{}{strings.Join(quoted, " ")}
+	}
+
+	var clause strings.Builder
+	var args []interface{}
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, t := range terms {
+		pattern := "%" + escaper.Replace(t) + "%"
+		like := d.db.dialect.like
+		clause.WriteString(` AND (summary ` + like + ` ? ESCAPE '\' OR detail ` + like + ` ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	return clause.String(), args
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Snooze holds back notifications for a while, e.g. during planned
+// maintenance. Events are still stored; snoozed ones do not count toward
+// the cooldown of events after the snooze.
+type Snooze struct {
+	ID         int64
+	InstanceID string     // empty matches every instance
+	Tier       event.Tier // empty matches every tier
+	Unit       string     // empty matches every unit
+	CreatedAt  time.Time
+	Until      time.Time
+}
+
+// Matches reports whether the snooze covers ev at the given time.
+func (s *Snooze) Matches(ev *event.Event, at time.Time) bool {
+	return at.Before(s.Until) &&
+		(s.InstanceID == "" || s.InstanceID == ev.InstanceID) &&
+		(s.Tier == "" || s.Tier == ev.Tier) &&
+		(s.Unit == "" || s.Unit == ev.Unit)
+}
+
+// AddSnooze stores a snooze and returns its ID.
+func (d *DB) AddSnooze(s *Snooze) (int64, error) {
+	var id int64
+	err := d.db.QueryRow(`INSERT INTO snoozes (instance_id, tier, unit, created_at, until)
+		VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		s.InstanceID, string(s.Tier), s.Unit, formatTime(s.CreatedAt), formatTime(s.Until),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("adding snooze: %w", err)
+	}
+	return id, nil
+}
+
+// ActiveSnoozes returns the snoozes that have not ended by now, ending
+// soonest first.
+func (d *DB) ActiveSnoozes(now time.Time) ([]*Snooze, error) {
+	rows, err := d.db.Query(`SELECT id, instance_id, tier, unit, created_at, until FROM snoozes
+		WHERE until > ? ORDER BY until, id`, formatTime(now))
+	if err != nil {
+		return nil, fmt.Errorf("loading snoozes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Snooze
+	for rows.Next() {
+		var s Snooze
+		var created, until string
+		if err := rows.Scan(&s.ID, &s.InstanceID, &s.Tier, &s.Unit, &created, &until); err != nil {
+			return nil, fmt.Errorf("scanning snooze: %w", err)
+		}
+		s.CreatedAt, _ = time.Parse(time.RFC3339Nano, created)
+		s.Until, _ = time.Parse(time.RFC3339Nano, until)
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+// SnoozedBy returns the active snooze covering ev at now, or nil.
+func (d *DB) SnoozedBy(ev *event.Event, now time.Time) (*Snooze, error) {
+	snoozes, err := d.ActiveSnoozes(now)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snoozes {
+		if s.Matches(ev, now) {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// ClearSnoozes deletes the snoozes for the given tier and unit, or every
+// snooze when both are empty. It returns how many were deleted.
+func (d *DB) ClearSnoozes(tier event.Tier, unit string) (int64, error) {
+	query := `DELETE FROM snoozes WHERE 1=1`
+	var args []interface{}
+	if tier != "" {
+		query += " AND tier = ?"
+		args = append(args, string(tier))
+	}
+	if unit != "" {
+		query += " AND unit = ?"
+		args = append(args, unit)
+	}
+	result, err := d.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("clearing snoozes: %w", err)
+	}
+	return result.RowsAffected()
+}
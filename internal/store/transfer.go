@@ -0,0 +1,237 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// Record is an event as logtriage export writes it and logtriage import
+// reads it back: the event with its ID, whether it was notified, and the
+// incident it belongs to. In JSON the event's fields are at the top level,
+// so each line of an export reads like any other event.
+type Record struct {
+	*event.Event
+	Notified bool      `json:"notified"`
+	Incident *Incident `json:"incident,omitempty"`
+}
+
+// Export returns the events f matches, oldest first, with their notified
+// flags and incidents.
+func (d *DB) Export(f QueryFilter) ([]*Record, error) {
+	events, err := d.Query(f)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := d.filterClause(f)
+	rows, err := d.db.Query(`SELECT id FROM events WHERE notified = TRUE`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying notified events: %w", err)
+	}
+	notified := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning notified event: %w", err)
+		}
+		notified[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying notified events: %w", err)
+	}
+
+	incidents := make(map[string]*Incident)
+	records := make([]*Record, 0, len(events))
+	for _, ev := range slices.Backward(events) {
+		r := &Record{Event: ev, Notified: notified[ev.ID]}
+		if id := ev.IncidentID; id != "" {
+			inc, ok := incidents[id]
+			if !ok {
+				if inc, err = d.GetIncident(id); err != nil {
+					return nil, err
+				}
+				incidents[id] = inc
+			}
+			r.Incident = inc
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Import stores records as they were exported, keeping their IDs,
+// incidents, notified flags, and acks. An event already stored is skipped,
+// as is an incident already stored. It returns how many events were
+// stored; the batch is written in one transaction.
+func (d *DB) Import(records []*Record) (int, error) {
+	d.flush()
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("importing events: %w", err)
+	}
+	defer tx.Rollback()
+
+	stored := 0
+	for _, r := range records {
+		if inc := r.Incident; inc != nil && inc.ID == r.IncidentID {
+			if _, err := tx.Exec(`
+				INSERT INTO incidents (id, instance_id, group_key, tier, severity, title, opened_at, last_seen, closed_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT DO NOTHING`,
+				inc.ID, inc.InstanceID, inc.GroupKey, string(inc.Tier), string(inc.Severity), inc.Title,
+				formatTime(inc.OpenedAt), formatTime(inc.LastSeen), nullTime(inc.ClosedAt),
+			); err != nil {
+				return 0, fmt.Errorf("importing incident %s: %w", inc.ID, err)
+			}
+		}
+
+		err := insertEvent(tx, r.Event)
+		if errors.Is(err, ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("importing event %s: %w", r.ID, err)
+		}
+		if _, err := tx.Exec(`UPDATE events SET notified = ?, acked_by = ?, acked_at = ? WHERE id = ?`,
+			r.Notified, nullString(r.AckedBy), nullTime(r.AckedAt), r.ID); err != nil {
+			return 0, fmt.Errorf("importing event %s: %w", r.ID, err)
+		}
+		stored++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("importing events: %w", err)
+	}
+	return stored, nil
+}
+
+// RecordCSVHeader names the columns of Record.CSVRecord: the event's
+// CSVHeader followed by what an import needs to restore it.
+var RecordCSVHeader = append(slices.Clone(event.CSVHeader),
+	"dedup_key", "fingerprint", "raw_fields", "notified",
+	"incident_group_key", "incident_tier", "incident_severity", "incident_title",
+	"incident_opened_at", "incident_last_seen", "incident_closed_at",
+)
+
+// CSVRecord returns the record as a CSV row in RecordCSVHeader order. Raw
+// fields are a JSON object.
+func (r *Record) CSVRecord() []string {
+	raw := ""
+	if len(r.RawFields) > 0 {
+		data, _ := json.Marshal(r.RawFields)
+		raw = string(data)
+	}
+	row := append(r.Event.CSVRecord(), r.DedupKey, r.Fingerprint, raw, strconv.FormatBool(r.Notified))
+	if inc := r.Incident; inc != nil {
+		return append(row, inc.GroupKey, string(inc.Tier), string(inc.Severity), inc.Title,
+			csvTime(inc.OpenedAt), csvTime(inc.LastSeen), csvTime(inc.ClosedAt))
+	}
+	return append(row, "", "", "", "", "", "", "")
+}
+
+// ParseCSVRecord reads a row written by CSVRecord, with columns named by
+// header in any order. Unknown columns are ignored.
+func ParseCSVRecord(header, row []string) (*Record, error) {
+	if len(row) != len(header) {
+		return nil, fmt.Errorf("%d fields, want %d", len(row), len(header))
+	}
+	col := make(map[string]string, len(header))
+	for i, name := range header {
+		col[name] = row[i]
+	}
+	if col["id"] == "" {
+		return nil, errors.New("no id")
+	}
+
+	var errs []error
+	parseTime := func(name string) time.Time {
+		if col[name] == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339Nano, col[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return t
+	}
+
+	ev := &event.Event{
+		ID:            col["id"],
+		InstanceID:    col["instance_id"],
+		Timestamp:     parseTime("timestamp"),
+		Tier:          event.Tier(col["tier"]),
+		Severity:      event.Severity(col["severity"]),
+		Summary:       col["summary"],
+		Process:       col["process"],
+		Unit:          col["unit"],
+		ContainerID:   col["container_id"],
+		ContainerName: col["container_name"],
+		CGroup:        col["cgroup"],
+		IncidentID:    col["incident_id"],
+		Detail:        col["detail"],
+		AckedBy:       col["acked_by"],
+		AckedAt:       parseTime("acked_at"),
+		DedupKey:      col["dedup_key"],
+		Fingerprint:   col["fingerprint"],
+		RawFields:     make(map[string]string),
+	}
+	if s := col["pid"]; s != "" {
+		pid, err := strconv.Atoi(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pid: %w", err))
+		}
+		ev.PID = pid
+	}
+	if s := col["raw_fields"]; s != "" {
+		if err := json.Unmarshal([]byte(s), &ev.RawFields); err != nil {
+			errs = append(errs, fmt.Errorf("raw_fields: %w", err))
+		}
+	}
+	r := &Record{Event: ev}
+	if s := col["notified"]; s != "" {
+		notified, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notified: %w", err))
+		}
+		r.Notified = notified
+	}
+	if ev.IncidentID != "" && col["incident_group_key"] != "" {
+		r.Incident = &Incident{
+			ID:         ev.IncidentID,
+			InstanceID: ev.InstanceID,
+			GroupKey:   col["incident_group_key"],
+			Tier:       event.Tier(col["incident_tier"]),
+			Severity:   event.Severity(col["incident_severity"]),
+			Title:      col["incident_title"],
+			OpenedAt:   parseTime("incident_opened_at"),
+			LastSeen:   parseTime("incident_last_seen"),
+			ClosedAt:   parseTime("incident_closed_at"),
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return formatTime(t)
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTime(t)
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// writeBatch is the most queued writes committed in one transaction.
+const writeBatch = 256
+
+// execer runs a statement on the database or within a transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// pendingWrite is an event insert or a decision waiting for the writer.
+// It holds copies, so the caller may go on changing its own.
+type pendingWrite struct {
+	ev  *event.Event
+	dec *Decision
+}
+
+// eventID returns the ID of the event the write concerns.
+func (w pendingWrite) eventID() string {
+	if w.ev != nil {
+		return w.ev.ID
+	}
+	return w.dec.EventID
+}
+
+func (w pendingWrite) apply(x execer) error {
+	if w.ev != nil {
+		err := insertEvent(x, w.ev)
+		if errors.Is(err, ErrDuplicate) {
+			return nil
+		}
+		return err
+	}
+	return recordDecision(x, w.dec)
+}
+
+// writer commits queued writes in the background; see StartWriter.
+type writer struct {
+	queue  chan pendingWrite
+	done   chan struct{}
+	send   sync.Mutex // keeps pending in queue order
+	closed bool       // queue is closed; guarded by send
+
+	mu      sync.Mutex
+	cond    *sync.Cond     // broadcast when pending shrinks
+	pending []pendingWrite // queued or being committed, oldest first
+}
+
+// StartWriter has InsertAsync and RecordDecision queue their writes for a
+// background writer, which commits whatever has queued up in one
+// transaction, so a burst of events costs a few commits rather than one
+// each. Once size writes are queued, callers wait for the writer to catch
+// up. Reads wait for the queued writes they could observe, except the
+// cooldown check, which counts queued events as they are. Close commits
+// what is still queued.
+func (d *DB) StartWriter(size int) {
+	w := &writer{
+		queue: make(chan pendingWrite, size),
+		done:  make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	d.w = w
+	go d.runWriter()
+}
+
+// InsertAsync stores a new event like Insert, through the background
+// writer when one is started. Errors in the background are logged, and an
+// event already stored is ignored.
+func (d *DB) InsertAsync(ev *event.Event) error {
+	if d.w == nil {
+		return d.Insert(ev)
+	}
+	cp := *ev
+	cp.RawFields = maps.Clone(ev.RawFields)
+	return d.enqueue(pendingWrite{ev: &cp})
+}
+
+// enqueue hands a write to the writer, waiting while the queue is full.
+// Once the writer has stopped, as the database is closing, the write is
+// applied at once instead and its error returned.
+func (d *DB) enqueue(pw pendingWrite) error {
+	w := d.w
+	w.send.Lock()
+	if w.closed {
+		w.send.Unlock()
+		return pw.apply(d.db)
+	}
+	defer w.send.Unlock()
+	w.mu.Lock()
+	w.pending = append(w.pending, pw)
+	w.mu.Unlock()
+	w.queue <- pw
+	return nil
+}
+
+func (d *DB) runWriter() {
+	w := d.w
+	defer close(w.done)
+	for first := range w.queue {
+		batch := []pendingWrite{first}
+	fill:
+		for len(batch) < writeBatch {
+			select {
+			case pw, ok := <-w.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, pw)
+			default:
+				break fill
+			}
+		}
+		d.commit(batch)
+
+		w.mu.Lock()
+		w.pending = slices.Delete(w.pending, 0, len(batch))
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// commit applies a batch of writes in one transaction. If that fails, the
+// writes are retried one at a time, so one bad write does not cost the
+// rest.
+func (d *DB) commit(batch []pendingWrite) {
+	err := func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, pw := range batch {
+			if err := pw.apply(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}()
+	if err == nil {
+		return
+	}
+
+	slog.Warn("batched write failed, retrying one at a time", "writes", len(batch), "error", err)
+	for _, pw := range batch {
+		if err := pw.apply(d.db); err != nil {
+			slog.Error("failed to store event", "id", pw.eventID(), "error", fmt.Errorf("background write: %w", err))
+		}
+	}
+}
+
+// stopWriter commits what is still queued and stops the writer. Writes
+// enqueued after it are applied directly.
+func (d *DB) stopWriter() {
+	w := d.w
+	if w == nil {
+		return
+	}
+	w.send.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.send.Unlock()
+	<-w.done
+}
+
+// flush waits until every queued write is committed.
+func (d *DB) flush() {
+	d.waitFor(func(pw pendingWrite) bool { return true })
+}
+
+// await waits until no queued write concerns the event with the given ID.
+func (d *DB) await(id string) {
+	d.waitFor(func(pw pendingWrite) bool { return pw.eventID() == id })
+}
+
+// waitFor waits until no queued write matches.
+func (d *DB) waitFor(match func(pendingWrite) bool) {
+	w := d.w
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for slices.ContainsFunc(w.pending, match) {
+		w.cond.Wait()
+	}
+}
+
+// queued returns the writes not yet committed, oldest first.
+func (d *DB) queued() []pendingWrite {
+	w := d.w
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.pending)
+}
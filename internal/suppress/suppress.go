@@ -0,0 +1,161 @@
+// Package suppress silences known-benign journal entries and events using
+// user-configured ignore rules.
+package suppress
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+// Stages at which a rule is evaluated.
+const (
+	StageClassify = "classify" // drop the journal entry before classification
+	StageAlert    = "alert"    // store the event but do not notify
+)
+
+// rule is a compiled suppression rule.
+type rule struct {
+	name    string
+	message *regexp.Regexp
+	unit    *regexp.Regexp
+	process *regexp.Regexp
+	tier    event.Tier
+}
+
+// Matcher evaluates suppression rules. A nil *Matcher matches nothing.
+type Matcher struct {
+	classify []rule
+	alert    []rule
+}
+
+// New compiles suppression rules. Every invalid rule is reported.
+func New(specs []config.SuppressRule) (*Matcher, error) {
+	m := &Matcher{}
+	var errs []error
+
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		r, err := compile(name, spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("suppress rule %s: %w", name, err))
+			continue
+		}
+
+		switch strings.ToLower(spec.Stage) {
+		case "", StageAlert:
+			m.alert = append(m.alert, r)
+		case StageClassify:
+			if r.tier != "" {
+				errs = append(errs, fmt.Errorf("suppress rule %s: tier cannot be used at the classify stage", name))
+				continue
+			}
+			m.classify = append(m.classify, r)
+		default:
+			errs = append(errs, fmt.Errorf("suppress rule %s: unknown stage %q (want %q or %q)",
+				name, spec.Stage, StageClassify, StageAlert))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func compile(name string, spec config.SuppressRule) (rule, error) {
+	r := rule{name: name, tier: event.Tier(strings.ToUpper(spec.Tier))}
+
+	var err error
+	if r.message, err = compileOptional("message", spec.Message); err != nil {
+		return rule{}, err
+	}
+	if r.unit, err = compileOptional("unit", spec.Unit); err != nil {
+		return rule{}, err
+	}
+	if r.process, err = compileOptional("process", spec.Process); err != nil {
+		return rule{}, err
+	}
+
+	if r.message == nil && r.unit == nil && r.process == nil && r.tier == "" {
+		return rule{}, errors.New("at least one of message, unit, process, or tier is required")
+	}
+	return r, nil
+}
+
+func compileOptional(field, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", field, err)
+	}
+	return re, nil
+}
+
+// Len returns the number of compiled rules.
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.classify) + len(m.alert)
+}
+
+// MatchEntry returns the name of the first classify-stage rule matching a
+// journal entry, or "" if none does.
+func (m *Matcher) MatchEntry(entry watcher.JournalEntry) string {
+	if m == nil {
+		return ""
+	}
+	for _, r := range m.classify {
+		if r.matches(entry.Message, entry.SystemdUnit, entry.SyslogIdentifier, "") {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// MatchEvent returns the name of the first alert-stage rule matching a
+// classified event, or "" if none does. The message matcher is applied to
+// the original journal message when the event has one, else its summary.
+func (m *Matcher) MatchEvent(ev *event.Event) string {
+	if m == nil {
+		return ""
+	}
+	msg, ok := ev.RawFields["MESSAGE"]
+	if !ok {
+		msg = ev.Summary
+	}
+	for _, r := range m.alert {
+		if r.matches(msg, ev.Unit, ev.Process, ev.Tier) {
+			return r.name
+		}
+	}
+	return ""
+}
+
+func (r rule) matches(msg, unit, process string, tier event.Tier) bool {
+	if r.tier != "" && r.tier != tier {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(msg) {
+		return false
+	}
+	if r.unit != nil && !r.unit.MatchString(unit) {
+		return false
+	}
+	if r.process != nil && !r.process.MatchString(process) {
+		return false
+	}
+	return true
+}
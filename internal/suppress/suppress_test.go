@@ -0,0 +1,103 @@
+package suppress
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/watcher"
+)
+
+func TestMatchEvent(t *testing.T) {
+	m, err := New([]config.SuppressRule{
+		{Name: "usb-dock", Message: `I/O error, dev sd[c-d]`, Tier: "t4"},
+		{Name: "flaky-unit", Unit: `^backup-.*\.service$`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dock := event.New("h", time.Now(), event.TierKernelHW, event.SevHigh, "Disk I/O error: sdc")
+	dock.RawFields["MESSAGE"] = "blk_update_request: I/O error, dev sdc, sector 1234"
+	if got := m.MatchEvent(dock); got != "usb-dock" {
+		t.Errorf("dock event matched %q, want usb-dock", got)
+	}
+
+	// Same message under a different tier is not suppressed.
+	other := event.New("h", time.Now(), event.TierProcessCrash, event.SevHigh, "Crash")
+	other.RawFields["MESSAGE"] = dock.RawFields["MESSAGE"]
+	if got := m.MatchEvent(other); got != "" {
+		t.Errorf("tier filter ignored, matched %q", got)
+	}
+
+	// A different disk is still reported.
+	sda := event.New("h", time.Now(), event.TierKernelHW, event.SevHigh, "Disk I/O error: sda")
+	sda.RawFields["MESSAGE"] = "blk_update_request: I/O error, dev sda, sector 1"
+	if got := m.MatchEvent(sda); got != "" {
+		t.Errorf("sda should not be suppressed, matched %q", got)
+	}
+
+	svc := event.New("h", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: backup-nightly.service")
+	svc.Unit = "backup-nightly.service"
+	if got := m.MatchEvent(svc); got != "flaky-unit" {
+		t.Errorf("unit rule matched %q, want flaky-unit", got)
+	}
+}
+
+func TestMatchEventFallsBackToSummary(t *testing.T) {
+	m, err := New([]config.SuppressRule{{Message: `GPU VRAM high: card1`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := event.New("h", time.Now(), event.TierKernelHW, event.SevHigh, "GPU VRAM high: card1 93%")
+	if got := m.MatchEvent(ev); got != "#1" {
+		t.Errorf("matched %q, want #1", got)
+	}
+}
+
+func TestMatchEntry(t *testing.T) {
+	m, err := New([]config.SuppressRule{
+		{Name: "noisy", Process: `^gnome-shell$`, Message: `JS ERROR`, Stage: "classify"},
+		{Name: "alert-only", Message: `JS ERROR`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := watcher.JournalEntry{Message: "JS ERROR: TypeError", SyslogIdentifier: "gnome-shell"}
+	if got := m.MatchEntry(entry); got != "noisy" {
+		t.Errorf("MatchEntry = %q, want noisy", got)
+	}
+
+	entry.SyslogIdentifier = "plasmashell"
+	if got := m.MatchEntry(entry); got != "" {
+		t.Errorf("alert-stage rule should not apply to entries, matched %q", got)
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.MatchEntry(watcher.JournalEntry{}) != "" || m.MatchEvent(&event.Event{}) != "" || m.Len() != 0 {
+		t.Error("nil matcher should match nothing")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New([]config.SuppressRule{
+		{Name: "empty"},
+		{Name: "bad-re", Message: "("},
+		{Name: "tier-early", Tier: "T4", Stage: "classify"},
+		{Name: "bad-stage", Message: "x", Stage: "later"},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"empty", "bad-re", "tier-early", "bad-stage"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package sysdep tracks the external tools logtriage shells out to. Every
+// tool is optional: features that need a missing tool are skipped, so the
+// same binary runs on desktops, servers, and minimal router or SBC images.
+package sysdep
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// Tool is an external command and the feature that depends on it.
+type Tool struct {
+	Name    string
+	Feature string
+}
+
+// Tools lists every external command logtriage may run.
+var Tools = []Tool{
+	{"journalctl", "journal watching and event enrichment"},
+	{"coredumpctl", "crash backtraces"},
+	{"gdb", "symbolized crash backtraces (crashes.debugger)"},
+	{"systemctl", "restart counts of looping units"},
+	{"docker", "Docker container names and images"},
+	{"podman", "Podman container names and images"},
+	{"crictl", "Kubernetes pod container exits, names, and logs"},
+	{"smartctl", "SMART disk health"},
+	{"zpool", "ZFS pool health"},
+	{"btrfs", "btrfs device error counters"},
+	{"nvidia-smi", "NVIDIA GPU temperature and VRAM"},
+	{"repquota", "filesystem quotas"},
+	{"xfs_quota", "XFS quotas (fallback for repquota)"},
+	{"ausearch", "audit log records where journald does not collect them (audit.source)"},
+	{"bpftrace", "eBPF tracing of OOM kills and crashes (ebpf)"},
+	{"dmesg", "kernel ring buffer in diagnostic bundles"},
+}
+
+var (
+	mu    sync.Mutex
+	found = map[string]bool{}
+
+	// lookPath is overridable for testing.
+	lookPath = exec.LookPath
+)
+
+// Have reports whether name is in PATH. Results are cached for the life of
+// the process, so callers may check on every poll.
+func Have(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	ok, cached := found[name]
+	if !cached {
+		_, err := lookPath(name)
+		ok = err == nil
+		found[name] = ok
+	}
+	return ok
+}
+
+// Available returns the availability of every known tool, keyed by name.
+func Available() map[string]bool {
+	out := make(map[string]bool, len(Tools))
+	for _, t := range Tools {
+		out[t.Name] = Have(t.Name)
+	}
+	return out
+}
+
+// Missing returns the known tools that are not in PATH.
+func Missing() []Tool {
+	var missing []Tool
+	for _, t := range Tools {
+		if !Have(t.Name) {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
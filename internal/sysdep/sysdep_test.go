@@ -0,0 +1,40 @@
+package sysdep
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHaveCaches(t *testing.T) {
+	calls := 0
+	orig := lookPath
+	lookPath = func(name string) (string, error) {
+		calls++
+		if name == "smartctl" {
+			return "/usr/sbin/smartctl", nil
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() {
+		lookPath = orig
+		found = map[string]bool{}
+	})
+
+	if !Have("smartctl") || !Have("smartctl") {
+		t.Error("smartctl should be found")
+	}
+	if Have("nvidia-smi") {
+		t.Error("nvidia-smi should be missing")
+	}
+	if calls != 2 {
+		t.Errorf("lookPath called %d times, want 2 (cached)", calls)
+	}
+
+	missing := Missing()
+	if len(missing) != len(Tools)-1 {
+		t.Errorf("Missing() = %v, want all but smartctl", missing)
+	}
+	if avail := Available(); !avail["smartctl"] || avail["journalctl"] {
+		t.Errorf("Available() = %v", avail)
+	}
+}
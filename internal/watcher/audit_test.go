@@ -0,0 +1,45 @@
+package watcher
+
+import "testing"
+
+const sampleAuditLog = `----
+type=AVC msg=audit(1708300000.123:456): avc:  denied  { read } for  pid=1234 comm="nginx" name="index.html" scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=0
+----
+node=web01 type=SECCOMP msg=audit(1708300005.900:457): auid=4294967295 uid=33 gid=33 ses=4294967295 pid=4242 comm="php-fpm" exe="/usr/sbin/php-fpm8.2" sig=31 arch=c000003e syscall=59 compat=0 ip=0x7f0e1c2d3e4f code=0x80000000
+<no matches>
+`
+
+func TestParseAuditLog(t *testing.T) {
+	entries := ParseAuditLog([]byte(sampleAuditLog))
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	avc := entries[0]
+	if avc.Transport != AuditTransport || avc.Fields["_AUDIT_TYPE_NAME"] != "AVC" || avc.Fields["_AUDIT_ID"] != "456" {
+		t.Errorf("avc entry = %+v", avc)
+	}
+	if want := `AVC avc:  denied  { read } for  pid=1234 comm="nginx"`; avc.Message[:len(want)] != want {
+		t.Errorf("message = %q", avc.Message)
+	}
+	if got := avc.Time().UnixMilli(); got != 1708300000123 {
+		t.Errorf("time = %d, want 1708300000123", got)
+	}
+
+	if seccomp := entries[1]; seccomp.Fields["_AUDIT_TYPE_NAME"] != "SECCOMP" {
+		t.Errorf("node-prefixed record type = %q", seccomp.Fields["_AUDIT_TYPE_NAME"])
+	}
+}
+
+func TestAuditIDAfter(t *testing.T) {
+	a := auditID{sec: 1708300000, serial: 456}
+	if !(auditID{sec: 1708300000, serial: 457}).after(a) {
+		t.Error("later serial in the same second not after")
+	}
+	if !(auditID{sec: 1708300001, serial: 1}).after(a) {
+		t.Error("later second not after")
+	}
+	if a.after(a) {
+		t.Error("record after itself")
+	}
+}
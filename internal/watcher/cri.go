@@ -0,0 +1,239 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CRIIdentifier is the SYSLOG_IDENTIFIER of the entries CRISource emits.
+const CRIIdentifier = "cri"
+
+// Pod metadata labels the kubelet sets on every container it creates.
+const (
+	labelPodName      = "io.kubernetes.pod.name"
+	labelPodNamespace = "io.kubernetes.pod.namespace"
+	labelPodUID       = "io.kubernetes.pod.uid"
+	labelContainer    = "io.kubernetes.container.name"
+)
+
+// CRISource implements JournalSource by polling the container runtime of
+// a Kubernetes node with crictl. Pod containers that exit with a non-zero
+// code or are OOM killed become entries whose fields carry the pod
+// metadata: CRI_CONTAINER_ID, CRI_CONTAINER_NAME, CRI_POD_NAME,
+// CRI_POD_NAMESPACE, CRI_POD_UID, CRI_IMAGE, CRI_EXIT_CODE, CRI_REASON
+// (e.g. "OOMKilled" or "Error"), and CRI_RESTART_COUNT. Containers that
+// had already exited when the source started are not reported.
+type CRISource struct {
+	interval time.Duration
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+}
+
+// NewCRISource creates a CRISource polling every interval.
+func NewCRISource(interval time.Duration) *CRISource {
+	return &CRISource{interval: interval}
+}
+
+func (s *CRISource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	exited, err := exitedCRIContainers(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	known := make(map[string]bool, len(exited))
+	for _, c := range exited {
+		known[c.ID] = true
+	}
+
+	ch := make(chan JournalEntry, 16)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		failing := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			exited, err := exitedCRIContainers(ctx)
+			if err != nil {
+				if !failing {
+					slog.Warn("listing pod containers failed", "error", err)
+				}
+				failing = true
+				continue
+			}
+			failing = false
+
+			// Exited containers are forgotten once the kubelet removes them.
+			next := make(map[string]bool, len(exited))
+			for _, c := range exited {
+				if known[c.ID] {
+					next[c.ID] = true
+					continue
+				}
+				status, err := inspectCRIContainer(ctx, c.ID)
+				if err != nil {
+					slog.Debug("inspecting pod container failed", "id", c.ID, "error", err)
+					continue // retried on the next poll
+				}
+				next[c.ID] = true
+				entry, ok := criEntry(c, status)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = next
+		}
+	}()
+
+	slog.Info("pod container watcher started", "interval", s.interval)
+	return ch, nil
+}
+
+func (s *CRISource) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// criContainer is a container as crictl ps -o json lists it.
+type criContainer struct {
+	ID       string `json:"id"`
+	Metadata struct {
+		Name    string `json:"name"`
+		Attempt int    `json:"attempt"`
+	} `json:"metadata"`
+	Image struct {
+		Image string `json:"image"`
+	} `json:"image"`
+	Labels map[string]string `json:"labels"`
+}
+
+// criStatus is the status of a container as crictl inspect -o json reports it.
+type criStatus struct {
+	ExitCode   int       `json:"exitCode"`
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Image      struct {
+		Image string `json:"image"`
+	} `json:"image"`
+}
+
+// exitedCRIContainers lists the exited containers the runtime still keeps.
+func exitedCRIContainers(ctx context.Context) ([]criContainer, error) {
+	out, err := exec.CommandContext(ctx, "crictl", "ps", "-a", "--state", "exited", "-o", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("crictl ps: %w", err)
+	}
+	return parseCRIContainers(out)
+}
+
+// parseCRIContainers parses the output of crictl ps -o json.
+func parseCRIContainers(data []byte) ([]criContainer, error) {
+	var list struct {
+		Containers []criContainer `json:"containers"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing crictl ps output: %w", err)
+	}
+	return list.Containers, nil
+}
+
+// inspectCRIContainer returns the status of the container with id.
+func inspectCRIContainer(ctx context.Context, id string) (criStatus, error) {
+	out, err := exec.CommandContext(ctx, "crictl", "inspect", "-o", "json", id).Output()
+	if err != nil {
+		return criStatus{}, fmt.Errorf("crictl inspect: %w", err)
+	}
+	return parseCRIStatus(out)
+}
+
+// parseCRIStatus parses the output of crictl inspect -o json.
+func parseCRIStatus(data []byte) (criStatus, error) {
+	var doc struct {
+		Status criStatus `json:"status"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return criStatus{}, fmt.Errorf("parsing crictl inspect output: %w", err)
+	}
+	return doc.Status, nil
+}
+
+// criEntry builds the entry for a container that exited with status. ok is
+// false for clean exits, which are not reported.
+func criEntry(c criContainer, status criStatus) (entry JournalEntry, ok bool) {
+	if status.ExitCode == 0 && status.Reason != "OOMKilled" {
+		return JournalEntry{}, false
+	}
+
+	name := c.Labels[labelContainer]
+	if name == "" {
+		name = c.Metadata.Name
+	}
+	image := status.Image.Image
+	if image == "" {
+		image = c.Image.Image
+	}
+	ns, pod := c.Labels[labelPodNamespace], c.Labels[labelPodName]
+
+	msg := fmt.Sprintf("container %s in pod %s/%s exited with code %d", name, ns, pod, status.ExitCode)
+	if status.Reason != "" {
+		msg += " (" + status.Reason + ")"
+	}
+	ts := status.FinishedAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	fields := map[string]string{
+		"MESSAGE":              msg,
+		"PRIORITY":             "3",
+		"SYSLOG_IDENTIFIER":    CRIIdentifier,
+		"__REALTIME_TIMESTAMP": strconv.FormatInt(ts.UnixMicro(), 10),
+		"CRI_CONTAINER_ID":     c.ID,
+		"CRI_CONTAINER_NAME":   name,
+		"CRI_POD_NAME":         pod,
+		"CRI_POD_NAMESPACE":    ns,
+		"CRI_POD_UID":          c.Labels[labelPodUID],
+		"CRI_IMAGE":            image,
+		"CRI_EXIT_CODE":        strconv.Itoa(status.ExitCode),
+		"CRI_REASON":           status.Reason,
+		"CRI_RESTART_COUNT":    strconv.Itoa(c.Metadata.Attempt),
+	}
+	if status.Message != "" {
+		fields["CRI_MESSAGE"] = status.Message
+	}
+	return JournalEntry{
+		Message:           msg,
+		Priority:          3,
+		SyslogIdentifier:  CRIIdentifier,
+		RealtimeTimestamp: fields["__REALTIME_TIMESTAMP"],
+		Fields:            fields,
+	}, true
+}
@@ -0,0 +1,80 @@
+package watcher
+
+import "testing"
+
+const sampleCRIPs = `{
+  "containers": [
+    {
+      "id": "3c5b1f0e9a2d7c4b8e6f1a0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b",
+      "podSandboxId": "9f8e7d6c5b4a",
+      "metadata": {"name": "app", "attempt": 4},
+      "image": {"image": "sha256:abc"},
+      "imageRef": "sha256:abc",
+      "state": "CONTAINER_EXITED",
+      "createdAt": "1708300000000000000",
+      "labels": {
+        "io.kubernetes.container.name": "app",
+        "io.kubernetes.pod.name": "web-7d9f8-abcde",
+        "io.kubernetes.pod.namespace": "default",
+        "io.kubernetes.pod.uid": "1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901"
+      }
+    }
+  ]
+}`
+
+const sampleCRIInspect = `{
+  "status": {
+    "id": "3c5b1f0e9a2d7c4b8e6f1a0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b",
+    "state": "CONTAINER_EXITED",
+    "finishedAt": "2024-02-19T00:06:40.5Z",
+    "exitCode": 137,
+    "image": {"image": "docker.io/library/app:1.2"},
+    "reason": "OOMKilled",
+    "message": ""
+  },
+  "info": {}
+}`
+
+func TestCRIEntry(t *testing.T) {
+	containers, err := parseCRIContainers([]byte(sampleCRIPs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(containers))
+	}
+	status, err := parseCRIStatus([]byte(sampleCRIInspect))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, ok := criEntry(containers[0], status)
+	if !ok {
+		t.Fatal("OOM killed container not reported")
+	}
+	if entry.SyslogIdentifier != CRIIdentifier || entry.Message != "container app in pod default/web-7d9f8-abcde exited with code 137 (OOMKilled)" {
+		t.Errorf("entry = %q from %q", entry.Message, entry.SyslogIdentifier)
+	}
+	if entry.RealtimeTimestamp != "1708301200500000" {
+		t.Errorf("timestamp = %s", entry.RealtimeTimestamp)
+	}
+	want := map[string]string{
+		"CRI_POD_NAME":      "web-7d9f8-abcde",
+		"CRI_POD_NAMESPACE": "default",
+		"CRI_POD_UID":       "1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901",
+		"CRI_IMAGE":         "docker.io/library/app:1.2",
+		"CRI_EXIT_CODE":     "137",
+		"CRI_REASON":        "OOMKilled",
+		"CRI_RESTART_COUNT": "4",
+	}
+	for k, v := range want {
+		if entry.Fields[k] != v {
+			t.Errorf("%s = %q, want %q", k, entry.Fields[k], v)
+		}
+	}
+
+	status.ExitCode, status.Reason = 0, "Completed"
+	if _, ok := criEntry(containers[0], status); ok {
+		t.Error("clean exit reported")
+	}
+}
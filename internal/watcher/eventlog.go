@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventLogTransport is the _TRANSPORT of the entries EventLogSource emits.
+const EventLogTransport = "eventlog"
+
+// eventLogQuery selects critical, error, and warning events. Warnings are
+// included for Windows' own low-memory diagnosis, which is one.
+const eventLogQuery = "*[System[(Level=1 or Level=2 or Level=3)]]"
+
+// winEvent is an event as wevtutil qe /f:RenderedXml prints it.
+type winEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     string `xml:"EventID"`
+		Level       int    `xml:"Level"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID string `xml:"EventRecordID"`
+		Execution     struct {
+			ProcessID string `xml:"ProcessID,attr"`
+		} `xml:"Execution"`
+		Channel  string `xml:"Channel"`
+		Computer string `xml:"Computer"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+	} `xml:"RenderingInfo"`
+}
+
+// eventLogPriority maps Event Log levels to syslog priorities.
+var eventLogPriority = map[int]int{
+	1: 2, // Critical: crit
+	2: 3, // Error: err
+	3: 4, // Warning: warning
+	4: 6, // Information: info
+	5: 7, // Verbose: debug
+}
+
+// parseEventLog parses a sequence of events, optionally wrapped in an
+// <Events> element, into entries. The fields are named like the journal's
+// where there is a counterpart (MESSAGE, PRIORITY, SYSLOG_IDENTIFIER for
+// the provider, _PID, _HOSTNAME), plus WINDOWS_EVENT_ID, WINDOWS_CHANNEL,
+// WINDOWS_RECORD_ID, and a WINDOWS_DATA_ field per event data item, named
+// after it or numbered from 1.
+func parseEventLog(data []byte) ([]JournalEntry, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var entries []JournalEntry
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, fmt.Errorf("parsing event log XML: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Event" {
+			continue
+		}
+		var ev winEvent
+		if err := dec.DecodeElement(&ev, &start); err != nil {
+			return entries, fmt.Errorf("parsing event log XML: %w", err)
+		}
+		entries = append(entries, ev.entry())
+	}
+}
+
+func (ev winEvent) entry() JournalEntry {
+	sys := ev.System
+	priority, ok := eventLogPriority[sys.Level]
+	if !ok {
+		priority = 6 // level 0 is "LogAlways", used for information
+	}
+
+	fields := map[string]string{
+		"PRIORITY":          strconv.Itoa(priority),
+		"SYSLOG_IDENTIFIER": sys.Provider.Name,
+		"_TRANSPORT":        EventLogTransport,
+		"_PID":              sys.Execution.ProcessID,
+		"_HOSTNAME":         sys.Computer,
+		"WINDOWS_EVENT_ID":  strings.TrimSpace(sys.EventID),
+		"WINDOWS_CHANNEL":   sys.Channel,
+		"WINDOWS_RECORD_ID": sys.EventRecordID,
+	}
+	var data []string
+	for i, d := range ev.EventData.Data {
+		name := d.Name
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		fields["WINDOWS_DATA_"+name] = d.Value
+		data = append(data, d.Value)
+	}
+
+	// Events of providers whose message files are missing render no
+	// message; their data is all there is.
+	msg := strings.TrimSpace(ev.RenderingInfo.Message)
+	if msg == "" {
+		msg = strings.Join(data, " ")
+	}
+	fields["MESSAGE"] = msg
+
+	if t, err := time.Parse(time.RFC3339Nano, sys.TimeCreated.SystemTime); err == nil {
+		fields["__REALTIME_TIMESTAMP"] = strconv.FormatInt(t.UnixMicro(), 10)
+	}
+
+	return JournalEntry{
+		Message:           msg,
+		Priority:          priority,
+		SyslogIdentifier:  sys.Provider.Name,
+		PID:               sys.Execution.ProcessID,
+		Transport:         EventLogTransport,
+		RealtimeTimestamp: fields["__REALTIME_TIMESTAMP"],
+		Fields:            fields,
+	}
+}
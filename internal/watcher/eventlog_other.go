@@ -0,0 +1,25 @@
+//go:build !windows
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// EventLogSource is the Windows Event Log source. Elsewhere it only
+// reports that it is unavailable.
+type EventLogSource struct{}
+
+// NewEventLogSource creates an EventLogSource, which fails to start on
+// platforms other than Windows.
+func NewEventLogSource(string, []string, time.Duration) *EventLogSource {
+	return &EventLogSource{}
+}
+
+func (s *EventLogSource) Entries(context.Context) (<-chan JournalEntry, error) {
+	return nil, errors.New("the Windows Event Log is only available on Windows")
+}
+
+func (s *EventLogSource) Stop() {}
@@ -0,0 +1,50 @@
+package watcher
+
+import "testing"
+
+const sampleEventLog = `<Events>
+<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Service Control Manager' Guid='{555908d1-a6d7-4695-8e1e-26931d2012f4}' EventSourceName='Service Control Manager'/><EventID Qualifiers='49152'>7034</EventID><Version>0</Version><Level>2</Level><Task>0</Task><Opcode>0</Opcode><Keywords>0x8080000000000000</Keywords><TimeCreated SystemTime='2024-02-19T00:06:40.5000000Z'/><EventRecordID>12345</EventRecordID><Correlation/><Execution ProcessID='812' ThreadID='4444'/><Channel>System</Channel><Computer>WIN-SRV01</Computer><Security/></System><EventData><Data Name='param1'>Print Spooler</Data><Data Name='param2'>1</Data></EventData><RenderingInfo Culture='en-US'><Message>The Print Spooler service terminated unexpectedly.  It has done this 1 time(s).</Message><Level>Error</Level><Provider>Service Control Manager</Provider></RenderingInfo></Event>
+<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='Application Error'/><EventID Qualifiers='0'>1000</EventID><Level>2</Level><TimeCreated SystemTime='2024-02-19T00:07:00.0000000Z'/><EventRecordID>12346</EventRecordID><Execution ProcessID='0' ThreadID='0'/><Channel>Application</Channel><Computer>WIN-SRV01</Computer></System><EventData><Data>app.exe</Data><Data>1.2.0.0</Data></EventData></Event>
+</Events>`
+
+func TestParseEventLog(t *testing.T) {
+	entries, err := parseEventLog([]byte(sampleEventLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	scm := entries[0]
+	if scm.SyslogIdentifier != "Service Control Manager" || scm.Priority != 3 || scm.Transport != EventLogTransport {
+		t.Errorf("entry = %+v", scm)
+	}
+	if scm.Message != "The Print Spooler service terminated unexpectedly.  It has done this 1 time(s)." {
+		t.Errorf("message = %q", scm.Message)
+	}
+	if scm.RealtimeTimestamp != "1708301200500000" || scm.PID != "812" {
+		t.Errorf("timestamp = %s, pid = %s", scm.RealtimeTimestamp, scm.PID)
+	}
+	for k, v := range map[string]string{
+		"WINDOWS_EVENT_ID":    "7034",
+		"WINDOWS_CHANNEL":     "System",
+		"WINDOWS_RECORD_ID":   "12345",
+		"WINDOWS_DATA_param1": "Print Spooler",
+		"_HOSTNAME":           "WIN-SRV01",
+	} {
+		if scm.Fields[k] != v {
+			t.Errorf("%s = %q, want %q", k, scm.Fields[k], v)
+		}
+	}
+
+	// Without a rendered message, the data stands in for it.
+	crash := entries[1]
+	if crash.Message != "app.exe 1.2.0.0" || crash.Fields["WINDOWS_DATA_1"] != "app.exe" {
+		t.Errorf("message = %q, fields = %v", crash.Message, crash.Fields)
+	}
+
+	if _, err := parseEventLog([]byte("<Events><Event><System>")); err == nil {
+		t.Error("truncated XML accepted")
+	}
+}
@@ -0,0 +1,132 @@
+//go:build windows
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventLogSource implements JournalSource by polling Windows Event Log
+// channels with wevtutil. A bookmark per channel, kept in bookmarkDir, plays
+// the part of the journal cursor, so events are neither lost nor repeated
+// across restarts. On first start, only events logged from then on are read.
+type EventLogSource struct {
+	bookmarkDir string
+	channels    []string
+	interval    time.Duration
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+}
+
+// NewEventLogSource creates an EventLogSource polling channels, such as
+// "System" and "Application", every interval.
+func NewEventLogSource(bookmarkDir string, channels []string, interval time.Duration) *EventLogSource {
+	return &EventLogSource{bookmarkDir: bookmarkDir, channels: channels, interval: interval}
+}
+
+func (s *EventLogSource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	for _, channel := range s.channels {
+		if err := s.initBookmark(ctx, channel); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	ch := make(chan JournalEntry, 64)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, channel := range s.channels {
+				entries, err := s.read(ctx, channel)
+				if err != nil {
+					slog.Warn("reading event log failed", "channel", channel, "error", err)
+					continue
+				}
+				for _, entry := range entries {
+					select {
+					case ch <- entry:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	slog.Info("event log watcher started", "channels", s.channels, "interval", s.interval)
+	return ch, nil
+}
+
+func (s *EventLogSource) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// bookmark returns the bookmark file of channel. Channels such as
+// "Microsoft-Windows-Kernel-Power/Thermal-Operational" contain a slash.
+func (s *EventLogSource) bookmark(channel string) string {
+	return filepath.Join(s.bookmarkDir, "eventlog-"+strings.ReplaceAll(channel, "/", "-")+".xml")
+}
+
+// initBookmark bookmarks the newest event of channel if it has no bookmark
+// yet, so the first read does not replay the whole log.
+func (s *EventLogSource) initBookmark(ctx context.Context, channel string) error {
+	path := s.bookmark(channel)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	err := exec.CommandContext(ctx, "wevtutil", "qe", channel, "/c:1", "/rd:true", "/f:xml", "/sbm:"+path).Run()
+	if err != nil {
+		return fmt.Errorf("bookmarking event log %s: %w", channel, err)
+	}
+	return nil
+}
+
+// read returns the events logged to channel since its bookmark and moves the
+// bookmark past them. The new bookmark is written to a temporary file and
+// kept only after events were read, so a read that matched nothing cannot
+// move it.
+func (s *EventLogSource) read(ctx context.Context, channel string) ([]JournalEntry, error) {
+	path := s.bookmark(channel)
+	next := path + ".new"
+	out, err := exec.CommandContext(ctx, "wevtutil", "qe", channel,
+		"/q:"+eventLogQuery, "/bm:"+path, "/sbm:"+next, "/f:RenderedXml", "/e:Events").Output()
+	if err != nil {
+		return nil, fmt.Errorf("wevtutil qe: %w", err)
+	}
+	entries, err := parseEventLog(out)
+	if len(entries) > 0 {
+		if err := os.Rename(next, path); err != nil {
+			return entries, fmt.Errorf("saving event log bookmark: %w", err)
+		}
+	} else {
+		os.Remove(next)
+	}
+	return entries, err
+}
@@ -2,6 +2,7 @@ package watcher
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,20 +10,44 @@ import (
 	"os/exec"
 	"strconv"
 	"sync"
+	"time"
 )
 
 // PipeSource implements JournalSource by tailing journalctl --follow -o json.
 type PipeSource struct {
 	cursorFile string
+	priority   string   // journalctl -p range; empty follows every priority
+	matches    []string // journalctl field matches, e.g. "SYSLOG_IDENTIFIER=podman"
+	user       bool     // follow the per-user journal (--user)
+	userUnits  []string // --user-unit filters; empty means every user unit
 	mu         sync.Mutex
 	cmd        *exec.Cmd
 	cancel     context.CancelFunc
 }
 
-// NewPipeSource creates a new PipeSource. cursorFile is the path to a file
-// where journalctl stores its cursor for crash-safe resume. Pass "" to disable.
+// NewPipeSource creates a new PipeSource following error-priority entries.
+// cursorFile is the path to a file where journalctl stores its cursor for
+// crash-safe resume. Pass "" to disable.
 func NewPipeSource(cursorFile string) *PipeSource {
-	return &PipeSource{cursorFile: cursorFile}
+	return &PipeSource{cursorFile: cursorFile, priority: "0..3"}
+}
+
+// NewMatchSource creates a PipeSource following only entries that match
+// the given journalctl field matches, up to priority. It is used for
+// sources that report failures at info level, such as container runtimes.
+// An empty priority follows entries of any priority, and those without
+// one, such as audit records. Matches on the same field are alternatives.
+// cursorFile must differ from the main source's.
+func NewMatchSource(cursorFile, priority string, matches []string) *PipeSource {
+	return &PipeSource{cursorFile: cursorFile, priority: priority, matches: matches}
+}
+
+// NewUserSource creates a PipeSource following the journal of the user
+// logtriage runs as, where user services such as pipewire log, up to
+// priority. units limits it to those user units and the user service
+// manager's messages about them; empty follows every user unit.
+func NewUserSource(cursorFile, priority string, units []string) *PipeSource {
+	return &PipeSource{cursorFile: cursorFile, priority: priority, user: true, userUnits: units}
 }
 
 func (p *PipeSource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
@@ -35,11 +60,20 @@ func (p *PipeSource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
 		"--follow",
 		"-o", "json",
 		"--no-pager",
-		"-p", "0..3", // emerg..err
+	}
+	if p.priority != "" {
+		args = append(args, "-p", p.priority)
 	}
 	if p.cursorFile != "" {
 		args = append(args, "--cursor-file", p.cursorFile)
 	}
+	if p.user {
+		args = append(args, "--user")
+	}
+	for _, unit := range p.userUnits {
+		args = append(args, "--user-unit", unit)
+	}
+	args = append(args, p.matches...)
 
 	cmd := exec.CommandContext(ctx, "journalctl", args...)
 	p.mu.Lock()
@@ -71,7 +105,7 @@ func (p *PipeSource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
 
 		for scanner.Scan() {
 			line := scanner.Bytes()
-			entry, err := parseJournalJSON(line)
+			entry, err := ParseJournalJSON(line)
 			if err != nil {
 				slog.Debug("skipping unparseable journal line", "error", err)
 				continue
@@ -89,7 +123,7 @@ func (p *PipeSource) Entries(ctx context.Context) (<-chan JournalEntry, error) {
 		}
 	}()
 
-	slog.Info("journal watcher started", "priority_filter", "0..3")
+	slog.Info("journal watcher started", "priority_filter", p.priority, "matches", p.matches, "user", p.user, "user_units", p.userUnits)
 	return ch, nil
 }
 
@@ -101,8 +135,8 @@ func (p *PipeSource) Stop() {
 	}
 }
 
-// parseJournalJSON parses a single JSON line from journalctl -o json.
-func parseJournalJSON(data []byte) (JournalEntry, error) {
+// ParseJournalJSON parses a single JSON line from journalctl -o json.
+func ParseJournalJSON(data []byte) (JournalEntry, error) {
 	var raw map[string]interface{}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return JournalEntry{}, err
@@ -139,3 +173,21 @@ func parseJournalJSON(data []byte) (JournalEntry, error) {
 		Fields:            fields,
 	}, nil
 }
+
+// LatestTimestamp returns the time of the newest journal entry in the
+// priority range the pipe follows, or the zero time if there is none.
+func LatestTimestamp(ctx context.Context) (time.Time, error) {
+	out, err := exec.CommandContext(ctx, "journalctl", "-n", "1", "-o", "json", "--no-pager", "-p", "0..3").Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("journalctl: %w", err)
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return time.Time{}, nil
+	}
+	entry, err := ParseJournalJSON(out)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return entry.Time(), nil
+}
@@ -16,9 +16,9 @@ func TestParseJournalJSON(t *testing.T) {
 	}
 
 	data, _ := json.Marshal(raw)
-	entry, err := parseJournalJSON(data)
+	entry, err := ParseJournalJSON(data)
 	if err != nil {
-		t.Fatalf("parseJournalJSON error: %v", err)
+		t.Fatalf("ParseJournalJSON error: %v", err)
 	}
 
 	if entry.Message != "Out of memory: Killed process 4521 (firefox)" {
@@ -50,9 +50,9 @@ func TestParseJournalJSONWithArrayField(t *testing.T) {
 	}
 
 	data, _ := json.Marshal(raw)
-	entry, err := parseJournalJSON(data)
+	entry, err := ParseJournalJSON(data)
 	if err != nil {
-		t.Fatalf("parseJournalJSON error: %v", err)
+		t.Fatalf("ParseJournalJSON error: %v", err)
 	}
 
 	if entry.Fields["_SOME_ARRAY_FIELD"] != "first" {
@@ -61,7 +61,7 @@ func TestParseJournalJSONWithArrayField(t *testing.T) {
 }
 
 func TestParseJournalJSONInvalid(t *testing.T) {
-	_, err := parseJournalJSON([]byte("not json"))
+	_, err := ParseJournalJSON([]byte("not json"))
 	if err == nil {
 		t.Error("expected error for invalid JSON")
 	}
@@ -75,9 +75,9 @@ func TestParseJournalJSONNumericPriority(t *testing.T) {
 	}
 
 	data, _ := json.Marshal(raw)
-	entry, err := parseJournalJSON(data)
+	entry, err := ParseJournalJSON(data)
 	if err != nil {
-		t.Fatalf("parseJournalJSON error: %v", err)
+		t.Fatalf("ParseJournalJSON error: %v", err)
 	}
 
 	if entry.Priority != 3 {
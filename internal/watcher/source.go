@@ -3,6 +3,8 @@ package watcher
 
 import (
 	"context"
+	"strconv"
+	"time"
 )
 
 // JournalEntry represents a parsed journal log entry.
@@ -21,6 +23,16 @@ type JournalEntry struct {
 	Fields map[string]string
 }
 
+// Time returns the entry's realtime timestamp, or the zero time if it is
+// missing or malformed.
+func (e JournalEntry) Time() time.Time {
+	usec, err := strconv.ParseInt(e.RealtimeTimestamp, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMicro(usec)
+}
+
 // JournalSource is the interface for receiving journal entries.
 // Implementations include the real journalctl pipe and test mocks.
 type JournalSource interface {
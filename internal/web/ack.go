@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+)
+
+// EnableAck serves the endpoint the Ack button of ntfy notifications posts
+// to (see config.AckConfig). Call it before Start.
+func (s *Server) EnableAck(cfg config.AckConfig) {
+	s.ack = &cfg
+	s.acks = make(chan *event.Event, 16)
+	s.mux.HandleFunc("POST /api/v1/events/{id}/ack", s.handleAck)
+}
+
+// Acks returns a channel of the events acknowledged through the server, or
+// nil if acks are not enabled.
+func (s *Server) Acks() <-chan *event.Event {
+	return s.acks
+}
+
+// handleAck acknowledges an event's alert, quieting its repeats for the
+// ack duration. The Ack button's link is authorized by its signature;
+// without one, a token with the ack role is needed.
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var by string
+	if sig := r.URL.Query().Get("sig"); sig != "" {
+		if !s.ack.Verify(id, sig) {
+			http.Error(w, "invalid ack signature", http.StatusForbidden)
+			return
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		by = "ntfy (" + host + ")"
+	} else {
+		t, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="logtriage"`)
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		if t.role < RoleAck {
+			http.Error(w, fmt.Sprintf("token %q has role %s; acking needs %s", t.name, t.role, RoleAck), http.StatusForbidden)
+			return
+		}
+		by = t.name
+	}
+
+	ev, err := s.db.GetEvent(id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if ev == nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+	a, err := s.db.AckEvent(ev, by, now, now.Add(s.ack.Duration.Duration))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	slog.Info("alert acknowledged", "event_id", ev.ID, "by", by, "key", a.Key, "until", a.Until)
+
+	select {
+	case s.acks <- ev:
+	default:
+		slog.Warn("ack queue full, acked transition not reported", "event_id", ev.ID)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Acknowledged %s\nRepeats are quiet until %s.\n", ev.Summary, a.Until.Local().Format("2006-01-02 15:04"))
+}
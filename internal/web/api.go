@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// Pages of GET /api/v1/events hold defaultPage events unless the client
+// asks for up to maxPage.
+const (
+	defaultPage = 100
+	maxPage     = 1000
+)
+
+// eventsPage is a page of GET /api/v1/events.
+type eventsPage struct {
+	Events     []*event.Event `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// handleEvents serves stored events as JSON, newest first, a page at a
+// time. The query parameters are last (a dashboard window such as 7d, or a
+// duration such as 90m), tier, instance, unit, search, and limit. The next
+// page is requested with cursor set to the previous page's next_cursor, or
+// with offset.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.QueryFilter{
+		Tier:       strings.ToUpper(q.Get("tier")),
+		InstanceID: q.Get("instance"),
+		Unit:       q.Get("unit"),
+		Search:     q.Get("search"),
+		Limit:      defaultPage,
+		Cursor:     q.Get("cursor"),
+	}
+	if last := q.Get("last"); last != "" {
+		window, err := parseLast(last)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Since = time.Now().Add(-window)
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || (name == "limit" && (n == 0 || n > maxPage)) {
+				http.Error(w, fmt.Sprintf("invalid %s %q", name, v), http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	events, next, err := s.db.QueryPage(f)
+	if errors.Is(err, store.ErrBadCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if events == nil {
+		events = []*event.Event{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(eventsPage{Events: events, NextCursor: next})
+}
+
+// parseLast reads the last parameter: a dashboard window or a duration.
+func parseLast(s string) (time.Duration, error) {
+	for _, win := range windows {
+		if win.Name == s {
+			return win.Duration, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid last %q: want 1h, 24h, 7d, 30d, or a duration such as 90m", s)
+	}
+	return d, nil
+}
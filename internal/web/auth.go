@@ -0,0 +1,215 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/setevik/logtriage/internal/config"
+)
+
+// tokenCookie holds the token of a browser that signed in on /login.
+const tokenCookie = "logtriage_token"
+
+// Role is what a token may do. Each role includes the ones before it.
+type Role int
+
+const (
+	RoleRead  Role = iota + 1 // view pages and streams
+	RoleAck                   // also acknowledge: close incidents
+	RoleAdmin                 // everything
+)
+
+var roleNames = map[Role]string{
+	RoleRead:  "read",
+	RoleAck:   "ack",
+	RoleAdmin: "admin",
+}
+
+// String returns "read", "ack", or "admin".
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// ParseRole parses a role name.
+func ParseRole(s string) (Role, error) {
+	for r, name := range roleNames {
+		if name == s {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q (want read, ack, or admin)", s)
+}
+
+// token is a configured bearer token with its parsed role.
+type token struct {
+	name   string
+	secret []byte
+	role   Role
+}
+
+// parseTokens validates the configured tokens.
+func parseTokens(cfg []config.WebToken) ([]token, error) {
+	out := make([]token, 0, len(cfg))
+	for i, t := range cfg {
+		if t.Token == "" {
+			return nil, fmt.Errorf("web token %d (%s): empty token", i+1, t.Name)
+		}
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("web token %d (%s): %w", i+1, t.Name, err)
+		}
+		out = append(out, token{name: t.Name, secret: []byte(t.Token), role: role})
+	}
+	return out, nil
+}
+
+// listenAddr binds a listen address without a host to localhost, so the
+// dashboard is only exposed beyond this machine when asked for explicitly.
+func listenAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "127.0.0.1" + addr
+	}
+	return addr
+}
+
+// isLoopback reports whether a listen address only accepts local clients.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type roleKey struct{}
+
+// roleOf returns the role a request was authorized with.
+func roleOf(ctx context.Context) Role {
+	r, _ := ctx.Value(roleKey{}).(Role)
+	return r
+}
+
+// authenticate returns the role of the token a request carries, either as
+// "Authorization: Bearer <token>" or in the sign-in cookie. Without
+// configured tokens every request is an admin: the dashboard then only
+// listens on localhost.
+func (s *Server) authenticate(r *http.Request) (token, bool) {
+	if len(s.tokens) == 0 {
+		return token{name: "local", role: RoleAdmin}, true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		c, err := r.Cookie(tokenCookie)
+		if err != nil {
+			return token{}, false
+		}
+		got = c.Value
+	}
+	return s.lookup(got)
+}
+
+// lookup finds the token with the given secret. Every token is compared so
+// the time taken does not reveal which one matched.
+func (s *Server) lookup(secret string) (token, bool) {
+	var found token
+	ok := false
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(secret), t.secret) == 1 {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// require wraps h so it only runs for requests with at least role. Pages
+// send a browser without a valid token to the sign-in form; API requests
+// get 401.
+func (s *Server) require(role Role, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := s.authenticate(r)
+		if !ok {
+			if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="logtriage"`)
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		if t.role < role {
+			slog.Info("web request denied", "token", t.name, "role", t.role, "need", role, "path", r.URL.Path)
+			http.Error(w, fmt.Sprintf("token %q has role %s; %s needs %s", t.name, t.role, r.URL.Path, role),
+				http.StatusForbidden)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, t.role)))
+	})
+}
+
+type loginPage struct {
+	Instance string
+	Page     string
+	SignOut  bool
+	Next     string
+	Failed   bool
+}
+
+func (s *Server) handleLoginForm(w http.ResponseWriter, r *http.Request) {
+	if len(s.tokens) == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	render(w, "login", loginPage{
+		Instance: s.instanceID,
+		Page:     "login",
+		Next:     safeNext(r.URL.Query().Get("next")),
+	})
+}
+
+// handleLogin checks the submitted token and stores it in a cookie, so the
+// browser's page loads and event stream carry it.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+	t, ok := s.lookup(r.FormValue("token"))
+	if !ok {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		render(w, "login", loginPage{Instance: s.instanceID, Page: "login", Next: next, Failed: true})
+		return
+	}
+	slog.Info("web sign-in", "token", t.name, "role", t.role, "remote", r.RemoteAddr)
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    r.FormValue("token"),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// handleLogout forgets the sign-in cookie.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: tokenCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// safeNext returns a local path to continue to after signing in, so the
+// form cannot be used to redirect to another site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
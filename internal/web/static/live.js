@@ -0,0 +1,44 @@
+// Live tail: prepends each event pushed over /api/v1/events/stream. The
+// browser resends Last-Event-ID on reconnect, so missed events are replayed.
+(function () {
+  var list = document.getElementById("events");
+  var status = document.getElementById("status");
+  var waiting = document.getElementById("waiting");
+  var maxRows = 500;
+
+  function text(tag, cls, value) {
+    var el = document.createElement(tag);
+    if (cls) el.className = cls;
+    el.textContent = value;
+    return el;
+  }
+
+  function render(ev) {
+    var row = document.createElement("details");
+    row.className = "event sev-" + ev.severity;
+    var summary = document.createElement("summary");
+    var ts = new Date(ev.timestamp);
+    summary.appendChild(text("span", "time", ts.toLocaleString()));
+    summary.appendChild(text("span", "tier tier-" + ev.tier, ev.tier));
+    summary.appendChild(text("span", "sev", ev.severity));
+    summary.appendChild(text("span", "text", ev.summary));
+    if (ev.unit || ev.process) summary.appendChild(text("span", "meta", ev.unit || ev.process));
+    row.appendChild(summary);
+    if (ev.incident_id) {
+      var link = text("a", "", "incident " + ev.incident_id);
+      link.href = "/incidents/" + ev.incident_id;
+      row.appendChild(link);
+    }
+    if (ev.detail) row.appendChild(text("pre", "", ev.detail));
+    return row;
+  }
+
+  var source = new EventSource("/api/v1/events/stream");
+  source.onopen = function () { status.textContent = "connected"; };
+  source.onerror = function () { status.textContent = "reconnecting…"; };
+  source.addEventListener("event", function (msg) {
+    waiting.hidden = true;
+    list.insertBefore(render(JSON.parse(msg.data)), list.firstChild);
+    while (list.children.length > maxRows) list.removeChild(list.lastChild);
+  });
+})();
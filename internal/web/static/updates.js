@@ -0,0 +1,52 @@
+// Pushed updates for the timeline and incident list. Incident rows on the
+// page are updated in place from /api/v1/incidents/stream; new events and
+// newly opened incidents only raise a reload link, so the page's charts and
+// filters stay consistent with what the server rendered.
+(function () {
+  var script = document.currentScript;
+  var kind = script.dataset.stream;
+  var tier = script.dataset.tier;
+  var unit = script.dataset.unit || "";
+  var terms = (script.dataset.search || "").toLowerCase().split(/\s+/).filter(Boolean);
+  var banner = document.getElementById("updates");
+  var link = banner.querySelector("a");
+  var fresh = 0;
+  var announced = {};
+
+  function announce(noun, id) {
+    if (announced[id]) return;
+    announced[id] = true;
+    fresh++;
+    link.textContent = fresh + " new " + noun + (fresh == 1 ? "" : "s") + " — reload";
+    banner.hidden = false;
+  }
+
+  function update(row, inc) {
+    row.className = "sev-" + inc.severity;
+    row.querySelector(".sev").textContent = inc.severity;
+    row.querySelector(".count").textContent = inc.event_count;
+    var state = row.querySelector(".state");
+    if (inc.closed_at) {
+      state.textContent = "closed " + new Date(inc.closed_at).toLocaleString();
+    }
+  }
+
+  var url = "/api/v1/" + kind + "/stream" + (tier ? "?tier=" + encodeURIComponent(tier) : "");
+  var source = new EventSource(url);
+  source.addEventListener("event", function (msg) {
+    var ev = JSON.parse(msg.data);
+    var text = (ev.summary + " " + (ev.detail || "")).toLowerCase();
+    if (unit && ev.unit != unit) return;
+    if (!terms.every(function (t) { return text.indexOf(t) >= 0; })) return;
+    announce("event", ev.id);
+  });
+  source.addEventListener("incident", function (msg) {
+    var inc = JSON.parse(msg.data);
+    var row = document.getElementById("incident-" + inc.id);
+    if (row) {
+      update(row, inc);
+    } else if (!inc.closed_at) {
+      announce("incident", inc.id);
+    }
+  });
+})();
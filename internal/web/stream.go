@@ -0,0 +1,245 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+// streamKeepalive is how often an idle stream sends a comment line, so
+// proxies and browsers do not time the connection out.
+const streamKeepalive = 30 * time.Second
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it starts missing them.
+const subscriberBuffer = 32
+
+// maxReplay caps how many missed events are replayed to a client that
+// reconnects with Last-Event-ID.
+const maxReplay = 1000
+
+// published is an event or incident as sent to subscribers: the fields
+// filters look at, and the JSON encoded once, when published, so later
+// changes the pipeline makes to it are not raced with.
+type published struct {
+	kind     string // SSE message type: "event" or "incident"
+	id       string
+	instance string
+	tier     event.Tier
+	severity event.Severity
+	data     []byte
+}
+
+func newPublished(ev *event.Event) (published, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return published{}, err
+	}
+	return published{kind: "event", id: ev.ID, instance: ev.InstanceID, tier: ev.Tier, severity: ev.Severity, data: data}, nil
+}
+
+func newPublishedIncident(inc *store.Incident) (published, error) {
+	data, err := json.Marshal(inc)
+	if err != nil {
+		return published{}, err
+	}
+	return published{kind: "incident", id: inc.ID, instance: inc.InstanceID, tier: inc.Tier, severity: inc.Severity, data: data}, nil
+}
+
+// broker fans published events or incidents out to the open streams.
+type broker struct {
+	mu   sync.Mutex
+	subs map[chan published]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[chan published]struct{})}
+}
+
+func (b *broker) subscribe() chan published {
+	ch := make(chan published, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[ch] = struct{}{}
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan published) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, ch)
+}
+
+// publish sends the result of encode to every subscriber. Nothing is
+// encoded while no stream is open.
+func (b *broker) publish(encode func() (published, error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subs) == 0 {
+		return
+	}
+	p, err := encode()
+	if err != nil {
+		slog.Error("encoding message for stream", "error", err)
+		return
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- p:
+		default:
+			slog.Debug("stream subscriber behind, message skipped", "kind", p.kind, "id", p.id)
+		}
+	}
+}
+
+// streamFilter selects the events or incidents a stream receives.
+type streamFilter struct {
+	tiers       []event.Tier // empty means all
+	minSeverity int          // event.Severity rank; 0 means all
+	instance    string
+}
+
+// parseStreamFilter reads the tier (comma-separated), severity (minimum),
+// and instance query parameters.
+func parseStreamFilter(r *http.Request) (streamFilter, error) {
+	q := r.URL.Query()
+	var f streamFilter
+	for _, t := range strings.Split(q.Get("tier"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.tiers = append(f.tiers, event.Tier(strings.ToUpper(t)))
+		}
+	}
+	if s := q.Get("severity"); s != "" {
+		f.minSeverity = event.Severity(strings.ToLower(s)).Rank()
+		if f.minSeverity == 0 {
+			return f, fmt.Errorf("unknown severity %q", s)
+		}
+	}
+	f.instance = q.Get("instance")
+	return f, nil
+}
+
+func (f streamFilter) match(p published) bool {
+	if len(f.tiers) > 0 && !slices.Contains(f.tiers, p.tier) {
+		return false
+	}
+	if p.severity.Rank() < f.minSeverity {
+		return false
+	}
+	return f.instance == "" || f.instance == p.instance
+}
+
+// handleStream serves newly stored events as Server-Sent Events: one
+// "event" message per event, with the event ID as the message ID and its
+// JSON as data. A client reconnecting with Last-Event-ID first receives the
+// events it missed.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	f, err := parseStreamFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Subscribe before replaying so nothing stored in between is lost;
+	// replayed events are then skipped when they also arrive live.
+	ch := s.live.subscribe()
+	defer s.live.unsubscribe(ch)
+
+	var missed []*event.Event
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if missed, err = s.db.EventsAfter(last, maxReplay); err != nil {
+			slog.Error("replaying missed events", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	replayed := make(map[string]bool, len(missed))
+	for _, ev := range missed {
+		p, err := newPublished(ev)
+		if err != nil || !f.match(p) {
+			continue
+		}
+		replayed[p.id] = true
+		if writeMessage(w, p) != nil {
+			return
+		}
+	}
+	serveStream(w, r, rc, ch, f, replayed)
+}
+
+// handleIncidentStream serves incident changes as Server-Sent Events: an
+// "incident" message with the incident's JSON whenever one opens, gains an
+// event, or closes. There is no replay; a reconnecting client reloads the
+// incident list instead.
+func (s *Server) handleIncidentStream(w http.ResponseWriter, r *http.Request) {
+	f, err := parseStreamFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	ch := s.incidents.subscribe()
+	defer s.incidents.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	serveStream(w, r, rc, ch, f, nil)
+}
+
+// serveStream writes the messages from ch that match f until the client
+// goes away, skipping IDs already sent as replay.
+func serveStream(w http.ResponseWriter, r *http.Request, rc *http.ResponseController,
+	ch chan published, f streamFilter, replayed map[string]bool) {
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case p := <-ch:
+			if !f.match(p) || replayed[p.id] {
+				continue
+			}
+			if writeMessage(w, p) != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeMessage writes one SSE message. Only events carry an ID: incidents
+// change after they are sent, so there is nothing to resume from.
+func writeMessage(w http.ResponseWriter, p published) error {
+	if p.kind != "event" {
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", p.kind, p.data)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", p.id, p.data)
+	return err
+}
@@ -0,0 +1,473 @@
+// Package web serves the optional local dashboard: an event timeline with
+// per-tier and per-day charts, incident timelines, and a live tail of new
+// events. New events and incident changes are pushed to open pages over
+// Server-Sent Events, so they never poll the database.
+package web
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+//go:embed templates static
+var assets embed.FS
+
+// maxTimelineEvents caps the events listed on a page of the timeline; the
+// charts still count every event in the window.
+const maxTimelineEvents = 500
+
+// windows are the time ranges the timeline and incident pages offer.
+var windows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// tiers are listed in the tier filter and charts in this order.
+var tiers = []event.Tier{
+	event.TierOOMKill,
+	event.TierProcessCrash,
+	event.TierServiceFailure,
+	event.TierKernelHW,
+	event.TierMemPressure,
+	event.TierResource,
+	event.TierReboot,
+	event.TierSecurity,
+	event.TierInternal,
+	event.TierUnclassified,
+}
+
+var funcs = template.FuncMap{
+	"time":  func(t time.Time) string { return t.Local().Format("Jan 02 15:04:05") },
+	"label": func(t event.Tier) string { return t.Label() },
+}
+
+// pages holds each page template parsed together with the shared layout.
+var pages = map[string]*template.Template{}
+
+func init() {
+	for _, name := range []string{"timeline", "incidents", "incident", "live", "login"} {
+		pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(assets,
+			"templates/layout.html", "templates/"+name+".html"))
+	}
+}
+
+// Server is the dashboard HTTP server.
+type Server struct {
+	addr       string
+	instanceID string
+	db         *store.DB
+	tokens     []token
+	mux        *http.ServeMux
+	live       *broker // new events
+	incidents  *broker // incident changes
+
+	ack  *config.AckConfig // nil unless EnableAck was called
+	acks chan *event.Event // acknowledged events, for the daemon to report
+}
+
+// New creates a dashboard server listening on addr and reading from db. An
+// addr without a host binds to localhost. With tokens, every request needs
+// one with a sufficient role; without, addr must be a loopback address.
+func New(addr, instanceID string, db *store.DB, tokens []config.WebToken) (*Server, error) {
+	parsed, err := parseTokens(tokens)
+	if err != nil {
+		return nil, err
+	}
+	addr = listenAddr(addr)
+	if len(parsed) == 0 && !isLoopback(addr) {
+		return nil, fmt.Errorf("%s is reachable from other machines; configure web tokens or listen on localhost", addr)
+	}
+
+	s := &Server{
+		addr:       addr,
+		instanceID: instanceID,
+		db:         db,
+		tokens:     parsed,
+		mux:        http.NewServeMux(),
+		live:       newBroker(),
+		incidents:  newBroker(),
+	}
+	static, _ := fs.Sub(assets, "static")
+	s.mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	s.mux.HandleFunc("GET /login", s.handleLoginForm)
+	s.mux.HandleFunc("POST /login", s.handleLogin)
+	s.mux.HandleFunc("GET /logout", s.handleLogout)
+	s.mux.Handle("GET /{$}", s.require(RoleRead, s.handleTimeline))
+	s.mux.Handle("GET /incidents", s.require(RoleRead, s.handleIncidents))
+	s.mux.Handle("GET /incidents/{id}", s.require(RoleRead, s.handleIncident))
+	s.mux.Handle("POST /incidents/{id}/close", s.require(RoleAck, s.handleCloseIncident))
+	s.mux.Handle("GET /live", s.require(RoleRead, s.handleLive))
+	s.mux.Handle("GET /api/v1/events", s.require(RoleRead, s.handleEvents))
+	s.mux.Handle("GET /api/v1/events/stream", s.require(RoleRead, s.handleStream))
+	s.mux.Handle("GET /api/v1/incidents/stream", s.require(RoleRead, s.handleIncidentStream))
+	s.mux.Handle("POST /api/v1/incidents/{id}/close", s.require(RoleAck, s.handleCloseIncident))
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Publish sends a newly stored event to every open stream. It does not
+// block; a subscriber that has fallen behind misses the event, and can
+// catch up by reconnecting with Last-Event-ID.
+func (s *Server) Publish(ev *event.Event) {
+	s.live.publish(func() (published, error) { return newPublished(ev) })
+}
+
+// PublishIncident sends the current state of an incident to every open
+// incident stream, without blocking.
+func (s *Server) PublishIncident(inc *store.Incident) {
+	s.incidents.publish(func() (published, error) { return newPublishedIncident(inc) })
+}
+
+// Start binds the listen address and serves in the background until ctx is
+// cancelled. Bind errors are returned immediately.
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("web server failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// bar is one row of a horizontal bar chart.
+type bar struct {
+	Label string
+	Tier  event.Tier // empty for non-tier charts
+	Count int
+	Pct   int // width relative to the largest bar
+}
+
+// filters are the timeline query parameters, echoed back into the form.
+type filters struct {
+	Window string
+	Tier   string
+	Search string
+	Unit   string
+	Cursor string
+}
+
+type timelinePage struct {
+	Instance string
+	Page     string
+	SignOut  bool
+	Windows  []string
+	Tiers    []event.Tier
+	Filters  filters
+	ByTier   []bar
+	ByDay    []bar
+	Events   []*event.Event
+	Total    int
+	Next     string // cursor of the page of older events, if any
+}
+
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	f := filters{
+		Window: r.URL.Query().Get("last"),
+		Tier:   r.URL.Query().Get("tier"),
+		Search: strings.TrimSpace(r.URL.Query().Get("search")),
+		Unit:   strings.TrimSpace(r.URL.Query().Get("unit")),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	window := parseWindow(&f.Window)
+	now := time.Now()
+	since := now.Add(-window)
+
+	filter := store.QueryFilter{
+		Since:  since,
+		Tier:   f.Tier,
+		Search: f.Search,
+		Unit:   f.Unit,
+	}
+	counts, err := s.db.CountByTier(filter)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	days, edges := dayBuckets(since, now)
+	perDay, err := s.db.Histogram(filter, edges)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	filter.Limit, filter.Cursor = maxTimelineEvents, f.Cursor
+	events, next, err := s.db.QueryPage(filter)
+	if errors.Is(err, store.ErrBadCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	p := timelinePage{
+		Instance: s.instanceID,
+		Page:     "timeline",
+		SignOut:  len(s.tokens) > 0,
+		Windows:  windowNames(),
+		Tiers:    tiers,
+		Filters:  f,
+		ByTier:   tierBars(counts),
+		ByDay:    dayBars(days, perDay),
+		Events:   events,
+		Next:     next,
+	}
+	for _, n := range counts {
+		p.Total += n
+	}
+	render(w, "timeline", p)
+}
+
+type incidentsPage struct {
+	Instance  string
+	Page      string
+	SignOut   bool
+	Windows   []string
+	Tiers     []event.Tier
+	Filters   filters
+	OpenOnly  bool
+	Incidents []*store.Incident
+}
+
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request) {
+	f := filters{
+		Window: r.URL.Query().Get("last"),
+		Tier:   r.URL.Query().Get("tier"),
+	}
+	window := parseWindow(&f.Window)
+	openOnly := r.URL.Query().Get("open") != ""
+
+	incidents, err := s.db.ListIncidents(store.IncidentFilter{
+		Since:    time.Now().Add(-window),
+		Tier:     f.Tier,
+		OpenOnly: openOnly,
+		Limit:    maxTimelineEvents,
+	})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	render(w, "incidents", incidentsPage{
+		Instance:  s.instanceID,
+		Page:      "incidents",
+		SignOut:   len(s.tokens) > 0,
+		Windows:   windowNames(),
+		Tiers:     tiers,
+		Filters:   f,
+		OpenOnly:  openOnly,
+		Incidents: incidents,
+	})
+}
+
+type incidentPage struct {
+	Instance string
+	Page     string
+	SignOut  bool
+	CanAck   bool
+	Incident *store.Incident
+	Events   []*event.Event // oldest first
+}
+
+func (s *Server) handleIncident(w http.ResponseWriter, r *http.Request) {
+	inc, err := s.db.GetIncident(r.PathValue("id"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if inc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	events, err := s.db.Query(store.QueryFilter{IncidentID: inc.ID})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	slices.Reverse(events)
+	render(w, "incident", incidentPage{
+		Instance: s.instanceID,
+		Page:     "incidents",
+		SignOut:  len(s.tokens) > 0,
+		CanAck:   roleOf(r.Context()) >= RoleAck,
+		Incident: inc,
+		Events:   events,
+	})
+}
+
+// handleCloseIncident closes an incident now. The dashboard's form is sent
+// back to the incident; API clients get 204.
+func (s *Server) handleCloseIncident(w http.ResponseWriter, r *http.Request) {
+	inc, err := s.db.GetIncident(r.PathValue("id"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if inc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if inc.IsOpen() {
+		if err := s.db.CloseIncident(inc.ID, time.Now()); err != nil {
+			serverError(w, err)
+			return
+		}
+		slog.Info("incident closed from the dashboard", "incident", inc.ID, "remote", r.RemoteAddr)
+		if closed, err := s.db.GetIncident(inc.ID); err == nil && closed != nil {
+			s.PublishIncident(closed)
+		}
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/incidents/"+inc.ID, http.StatusSeeOther)
+}
+
+type livePage struct {
+	Instance string
+	Page     string
+	SignOut  bool
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	render(w, "live", livePage{Instance: s.instanceID, Page: "live", SignOut: len(s.tokens) > 0})
+}
+
+// parseWindow returns the duration of the named window, resetting an
+// unknown name to the 24h default.
+func parseWindow(name *string) time.Duration {
+	for _, win := range windows {
+		if win.Name == *name {
+			return win.Duration
+		}
+	}
+	*name = "24h"
+	return 24 * time.Hour
+}
+
+func windowNames() []string {
+	names := make([]string, len(windows))
+	for i, win := range windows {
+		names[i] = win.Name
+	}
+	return names
+}
+
+// tierBars charts events per tier, omitting tiers with none.
+func tierBars(counts map[event.Tier]int) []bar {
+	var bars []bar
+	for _, t := range tiers {
+		if n := counts[t]; n > 0 {
+			bars = append(bars, bar{Label: string(t) + " " + t.Label(), Tier: t, Count: n})
+		}
+	}
+	return scaleBars(bars)
+}
+
+// dayBuckets returns the empty bars of the chart of events per local
+// calendar day from since to now, oldest first, and the edges of their
+// periods for store.Histogram. Windows shorter than two days are counted
+// per hour instead.
+func dayBuckets(since, now time.Time) ([]bar, []time.Time) {
+	key, label := "2006-01-02", "Mon Jan 02"
+	next := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	since = since.Local()
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+	if now.Sub(since) < 48*time.Hour {
+		key, label = "2006-01-02 15", "15:00"
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+		start = since.Truncate(time.Hour)
+	}
+
+	var bars []bar
+	var edges []time.Time
+	seen := make(map[string]bool)
+	t := start
+	for ; !t.After(now); t = next(t) {
+		k := t.Format(key)
+		if seen[k] {
+			continue // the repeated hour when DST ends
+		}
+		seen[k] = true
+		bars = append(bars, bar{Label: t.Format(label)})
+		edges = append(edges, t)
+	}
+	return bars, append(edges, t)
+}
+
+// dayBars fills in the bars from dayBuckets with their counts.
+func dayBars(bars []bar, counts []int) []bar {
+	for i := range bars {
+		bars[i].Count = counts[i]
+	}
+	return scaleBars(bars)
+}
+
+// scaleBars sets each bar's width as a percentage of the largest count.
+func scaleBars(bars []bar) []bar {
+	largest := 0
+	for _, b := range bars {
+		largest = max(largest, b.Count)
+	}
+	if largest == 0 {
+		return bars
+	}
+	for i := range bars {
+		bars[i].Pct = bars[i].Count * 100 / largest
+	}
+	return bars
+}
+
+func render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages[name].ExecuteTemplate(w, "layout", data); err != nil {
+		slog.Error("rendering dashboard page", "page", name, "error", err)
+	}
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	slog.Error("dashboard query failed", "error", err)
+	http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+}
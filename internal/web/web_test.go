@@ -0,0 +1,515 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/setevik/logtriage/internal/config"
+	"github.com/setevik/logtriage/internal/event"
+	"github.com/setevik/logtriage/internal/store"
+)
+
+func testServer(t *testing.T) (*Server, *store.DB) {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("opening test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := New("127.0.0.1:0", "testhost", db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+func get(t *testing.T, s *Server, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	return rec
+}
+
+func TestTimeline(t *testing.T) {
+	s, db := testServer(t)
+
+	oom := event.New("testhost", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: <firefox>")
+	oom.Detail = "Killed process 4521"
+	disk := event.New("testhost", time.Now(), event.TierResource, event.SevWarning, "Disk /var at 91% used")
+	for _, ev := range []*event.Event{oom, disk} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := get(t, s, "/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"OOM Kill: &lt;firefox&gt;", "Disk /var at 91% used", "T1 OOM Kill", "T6 Resource Limit"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("timeline missing %q", want)
+		}
+	}
+
+	body = get(t, s, "/?tier=T6&last=7d").Body.String()
+	if strings.Contains(body, "firefox") || !strings.Contains(body, "Disk /var") {
+		t.Error("tier filter not applied")
+	}
+	if !strings.Contains(body, "<option selected>7d</option>") {
+		t.Error("window not kept in the form")
+	}
+}
+
+func TestEventsAPI(t *testing.T) {
+	s, db := testServer(t)
+
+	now := time.Now()
+	for i, tier := range []event.Tier{event.TierOOMKill, event.TierProcessCrash, event.TierOOMKill} {
+		ev := event.New("testhost", now.Add(-time.Duration(i)*time.Minute), tier, event.SevHigh, fmt.Sprintf("event %d", i))
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page := func(url string) eventsPage {
+		t.Helper()
+		rec := get(t, s, url)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", url, rec.Code, rec.Body)
+		}
+		var p eventsPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	first := page("/api/v1/events?limit=2")
+	if len(first.Events) != 2 || first.Events[0].Summary != "event 0" || first.NextCursor == "" {
+		t.Fatalf("first page = %+v", first)
+	}
+	second := page("/api/v1/events?limit=2&cursor=" + first.NextCursor)
+	if len(second.Events) != 1 || second.Events[0].Summary != "event 2" || second.NextCursor != "" {
+		t.Errorf("second page = %+v", second)
+	}
+	if p := page("/api/v1/events?limit=2&offset=1"); len(p.Events) != 2 || p.Events[0].Summary != "event 1" {
+		t.Errorf("page at offset 1 = %+v", p)
+	}
+	if p := page("/api/v1/events?tier=t1&last=1h"); len(p.Events) != 2 {
+		t.Errorf("T1 events = %+v", p)
+	}
+
+	for _, url := range []string{
+		"/api/v1/events?cursor=bogus",
+		"/api/v1/events?limit=0",
+		"/api/v1/events?limit=5000",
+		"/api/v1/events?offset=-1",
+		"/api/v1/events?last=forever",
+		"/?cursor=bogus",
+	} {
+		if rec := get(t, s, url); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400", url, rec.Code)
+		}
+	}
+}
+
+func TestIncidentPages(t *testing.T) {
+	s, db := testServer(t)
+
+	ev := event.New("testhost", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: nginx.service")
+	ev.Unit = "nginx.service"
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	inc, _, err := db.GroupEvent(ev, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := get(t, s, "/incidents").Body.String(); !strings.Contains(body, "/incidents/"+inc.ID) {
+		t.Errorf("incident list does not link %s", inc.ID)
+	}
+	rec := get(t, s, "/incidents/"+inc.ID)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Service failed: nginx.service") {
+		t.Errorf("incident page: status %d", rec.Code)
+	}
+	if rec := get(t, s, "/incidents/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown incident: status = %d, want 404", rec.Code)
+	}
+}
+
+// openStream connects to the event stream and returns a function reading
+// the next streamed event.
+func openStream(t *testing.T, s *Server, query, lastID string) func() event.Event {
+	t.Helper()
+	ts := httptest.NewServer(s.mux)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/events/stream"+query, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	return func() event.Event {
+		t.Helper()
+		var id string
+		for scanner.Scan() {
+			line := scanner.Text()
+			if v, ok := strings.CutPrefix(line, "id: "); ok {
+				id = v
+			}
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var ev event.Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				t.Fatal(err)
+			}
+			if id != ev.ID {
+				t.Errorf("message id %q, event id %q", id, ev.ID)
+			}
+			return ev
+		}
+		t.Fatalf("stream ended without an event: %v", scanner.Err())
+		return event.Event{}
+	}
+}
+
+func TestStream(t *testing.T) {
+	s, _ := testServer(t)
+	next := openStream(t, s, "", "")
+
+	// The handler subscribes before sending headers, so this is not lost.
+	ev := event.New("testhost", time.Now(), event.TierProcessCrash, event.SevHigh, "Crash: vlc")
+	s.Publish(ev)
+
+	if got := next(); got.ID != ev.ID || got.Summary != "Crash: vlc" {
+		t.Errorf("streamed event = %+v", got)
+	}
+}
+
+func TestStreamReplayAndFilter(t *testing.T) {
+	s, db := testServer(t)
+
+	seen := event.New("testhost", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: seen")
+	pressure := event.New("testhost", time.Now(), event.TierMemPressure, event.SevWarning, "Memory pressure")
+	missed := event.New("testhost", time.Now(), event.TierOOMKill, event.SevCritical, "OOM Kill: missed")
+	for _, ev := range []*event.Event{seen, pressure, missed} {
+		if err := db.Insert(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next := openStream(t, s, "?tier=t1,T2&severity=high", seen.ID)
+	if got := next(); got.ID != missed.ID {
+		t.Errorf("replayed %q, want %q", got.Summary, missed.Summary)
+	}
+
+	// Published again live, the replayed event is not sent twice.
+	s.Publish(missed)
+	s.Publish(event.New("testhost", time.Now(), event.TierProcessCrash, event.SevMedium, "too mild"))
+	s.Publish(event.New("testhost", time.Now(), event.TierResource, event.SevHigh, "wrong tier"))
+	crash := event.New("testhost", time.Now(), event.TierProcessCrash, event.SevHigh, "Crash: vlc")
+	s.Publish(crash)
+	if got := next(); got.ID != crash.ID {
+		t.Errorf("next live event %q, want %q", got.Summary, crash.Summary)
+	}
+}
+
+func TestIncidentStream(t *testing.T) {
+	s, db := testServer(t)
+	ts := httptest.NewServer(s.mux)
+	t.Cleanup(ts.Close)
+
+	ev := event.New("testhost", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: nginx.service")
+	ev.Unit = "nginx.service"
+	if err := db.Insert(ev); err != nil {
+		t.Fatal(err)
+	}
+	inc, _, err := db.GroupEvent(ev, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/incidents/stream?tier=T3", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	s.PublishIncident(&store.Incident{ID: "other", Tier: event.TierOOMKill, Severity: event.SevCritical})
+	closeReq, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/incidents/"+inc.ID+"/close", nil)
+	if resp, err := http.DefaultClient.Do(closeReq); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("closing incident: %v %v", resp, err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var kind string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "id: ") {
+			t.Errorf("incident message has an ID: %q", line)
+		}
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			kind = v
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var got store.Incident
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if kind != "incident" || got.ID != inc.ID || got.IsOpen() || got.EventCount != 1 {
+			t.Errorf("streamed %s %+v, want closed incident %s", kind, got, inc.ID)
+		}
+		return
+	}
+	t.Fatalf("stream ended without an incident: %v", scanner.Err())
+}
+
+func TestStreamBadFilter(t *testing.T) {
+	s, _ := testServer(t)
+	if rec := get(t, s, "/api/v1/events/stream?severity=urgent"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestDayBars(t *testing.T) {
+	_, db := testServer(t)
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.Local)
+	for _, ts := range []time.Time{now.Add(-time.Hour), now.Add(-time.Hour), now.AddDate(0, 0, -2)} {
+		if err := db.Insert(event.New("host", ts, event.TierOOMKill, event.SevCritical, "OOM Kill: firefox")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chart := func(since time.Time) []bar {
+		t.Helper()
+		bars, edges := dayBuckets(since, now)
+		if len(edges) != len(bars)+1 || !edges[len(edges)-1].After(now) {
+			t.Fatalf("%d bars with edges %v", len(bars), edges)
+		}
+		counts, err := db.Histogram(store.QueryFilter{Since: since}, edges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dayBars(bars, counts)
+	}
+
+	bars := chart(now.AddDate(0, 0, -7))
+	if len(bars) != 8 {
+		t.Fatalf("got %d daily bars, want 8", len(bars))
+	}
+	if bars[7].Count != 2 || bars[7].Pct != 100 || bars[5].Count != 1 || bars[5].Pct != 50 {
+		t.Errorf("daily bars = %+v", bars)
+	}
+
+	bars = chart(now.Add(-24 * time.Hour))
+	if len(bars) != 25 || bars[23].Label != "14:00" || bars[23].Count != 2 {
+		t.Errorf("hourly bars = %+v", bars)
+	}
+}
+
+func TestNewListenAddress(t *testing.T) {
+	s, _ := testServer(t)
+	tokens := []config.WebToken{{Name: "ops", Token: "secret", Role: "read"}}
+
+	tests := []struct {
+		addr   string
+		tokens []config.WebToken
+		want   string // empty when New should fail
+	}{
+		{":9247", nil, "127.0.0.1:9247"},
+		{"localhost:9247", nil, "localhost:9247"},
+		{"[::1]:9247", nil, "[::1]:9247"},
+		{"0.0.0.0:9247", nil, ""},
+		{"192.168.1.5:9247", nil, ""},
+		{"0.0.0.0:9247", tokens, "0.0.0.0:9247"},
+		{":9247", []config.WebToken{{Name: "bad", Token: "x", Role: "owner"}}, ""},
+		{":9247", []config.WebToken{{Name: "empty", Role: "read"}}, ""},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.addr, "testhost", s.db, tt.tokens)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("New(%q, %d tokens) succeeded, want error", tt.addr, len(tt.tokens))
+		case tt.want != "" && err != nil:
+			t.Errorf("New(%q, %d tokens): %v", tt.addr, len(tt.tokens), err)
+		case tt.want != "" && got.Addr() != tt.want:
+			t.Errorf("New(%q).Addr() = %q, want %q", tt.addr, got.Addr(), tt.want)
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	_, db := testServer(t)
+	s, err := New("0.0.0.0:0", "testhost", db, []config.WebToken{
+		{Name: "viewer", Token: "read-token", Role: "read"},
+		{Name: "oncall", Token: "ack-token", Role: "ack"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := event.New("testhost", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: nginx.service")
+	db.Insert(ev)
+	inc, err := db.OpenIncident(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, url, bearer string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a token, pages redirect to the sign-in form and the API is 401.
+	if rec := do("GET", "/incidents", "", nil); rec.Code != http.StatusSeeOther ||
+		rec.Header().Get("Location") != "/login?next=%2Fincidents" {
+		t.Errorf("anonymous page = %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := do("GET", "/api/v1/events/stream", "wrong", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad token stream = %d, want 401", rec.Code)
+	}
+	if rec := do("GET", "/static/style.css", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("static = %d, want 200", rec.Code)
+	}
+
+	// A read token can view but not close; the close button is hidden.
+	rec := do("GET", "/incidents/"+inc.ID, "read-token", nil)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Close incident") {
+		t.Errorf("read token incident page = %d, close button shown: %v",
+			rec.Code, strings.Contains(rec.Body.String(), "Close incident"))
+	}
+	if rec := do("POST", "/api/v1/incidents/"+inc.ID+"/close", "read-token", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("read token close = %d, want 403", rec.Code)
+	}
+
+	// Signing in with the ack token sets a cookie that can close incidents.
+	form := httptest.NewRequest("POST", "/login", strings.NewReader("token=ack-token&next=%2Fincidents%2F"+inc.ID))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, form)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/incidents/"+inc.ID || len(cookies) != 1 {
+		t.Fatalf("login = %d %q, %d cookies", rec.Code, rec.Header().Get("Location"), len(cookies))
+	}
+	if rec := do("GET", "/incidents/"+inc.ID, "", cookies[0]); !strings.Contains(rec.Body.String(), "Close incident") {
+		t.Error("ack token incident page has no close button")
+	}
+	if rec := do("POST", "/incidents/"+inc.ID+"/close", "", cookies[0]); rec.Code != http.StatusSeeOther {
+		t.Errorf("ack close = %d, want 303", rec.Code)
+	}
+	if got, _ := db.GetIncident(inc.ID); got.IsOpen() {
+		t.Error("incident still open after close")
+	}
+
+	// A wrong token is rejected, and next cannot leave the site.
+	form = httptest.NewRequest("POST", "/login", strings.NewReader("token=nope"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, form)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "not valid") {
+		t.Errorf("bad login = %d", rec.Code)
+	}
+	for _, next := range []string{"https://evil.example", "//evil.example", "/\\evil.example"} {
+		if got := safeNext(next); got != "/" {
+			t.Errorf("safeNext(%q) = %q", next, got)
+		}
+	}
+}
+
+func TestAck(t *testing.T) {
+	_, db := testServer(t)
+	s, err := New("0.0.0.0:0", "testhost", db, []config.WebToken{
+		{Name: "viewer", Token: "read-token", Role: "read"},
+		{Name: "oncall", Token: "ack-token", Role: "ack"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := config.AckConfig{Enabled: true, URL: "https://host.example.net:9247/", Secret: "s3cret", Duration: config.Duration{Duration: time.Hour}}
+	s.EnableAck(ack)
+
+	ev := event.New("testhost", time.Now(), event.TierServiceFailure, event.SevMedium, "Service failed: nginx.service")
+	ev.Unit = "nginx.service"
+	db.Insert(ev)
+
+	post := func(url, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	path := "/api/v1/events/" + ev.ID + "/ack"
+	if rec := post(path+"?sig=bogus", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("bad signature = %d, want 403", rec.Code)
+	}
+	if rec := post(path, "read-token"); rec.Code != http.StatusForbidden {
+		t.Errorf("read token = %d, want 403", rec.Code)
+	}
+
+	link := strings.TrimPrefix(ack.Link(ev.ID), "https://host.example.net:9247")
+	if rec := post(link, ""); rec.Code != http.StatusOK {
+		t.Fatalf("signed ack = %d: %s", rec.Code, rec.Body)
+	}
+	select {
+	case got := <-s.Acks():
+		if got.ID != ev.ID {
+			t.Errorf("acked event = %s, want %s", got.ID, ev.ID)
+		}
+	default:
+		t.Error("ack not reported to the daemon")
+	}
+	got, _ := db.GetEvent(ev.ID)
+	if !strings.HasPrefix(got.AckedBy, "ntfy (") || got.AckedAt.IsZero() {
+		t.Errorf("acked by %q at %v", got.AckedBy, got.AckedAt)
+	}
+
+	if rec := post(path, "ack-token"); rec.Code != http.StatusOK {
+		t.Errorf("ack token = %d, want 200", rec.Code)
+	}
+	if got, _ := db.GetEvent(ev.ID); got.AckedBy != "oncall" {
+		t.Errorf("acked by %q, want oncall", got.AckedBy)
+	}
+	if rec := post("/api/v1/events/nope/ack", "ack-token"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown event = %d, want 404", rec.Code)
+	}
+}